@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/agent"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/session"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// NewAgentCmd creates the agent subcommand, run on the remote host of --via.
+func NewAgentCmd(version string) *cobra.Command {
+	var handshake bool
+
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Run probes for a gtrace --via session (started over SSH)",
+		Long: `Run the probes of an MTR session for a gtrace started with --via on another
+host. The session is requested on standard input and its results streamed on
+standard output. gtrace --via starts it over SSH, it is not meant to be run
+by hand.`,
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if handshake {
+				agent.WriteHandshake(cmd.OutOrStdout(), version)
+				return nil
+			}
+			if err := trace.CheckPrivileges(); err != nil {
+				return fmt.Errorf("probing from this host requires root or CAP_NET_RAW: use --via-sudo or grant gtrace the capability (setcap cap_net_raw+ep)")
+			}
+			return agent.Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVar(&handshake, "protocol", false, "Print the agent protocol version and gtrace release, and exit")
+
+	return cmd
+}
+
+// agentRequest returns the session the remote agent of --via runs.
+func agentRequest(cfg *Config, interval, timeout time.Duration) agent.Request {
+	req := agent.Request{
+		Target:      cfg.Target,
+		IPVersion:   getIPVersion(cfg),
+		Protocol:    cfg.Protocol,
+		Port:        cfg.Port,
+		MaxHops:     cfg.MaxHops,
+		Timeout:     timeout,
+		Packets:     mtrPacketsPerHop(cfg),
+		ProbeSize:   cfg.ProbeSize,
+		Interval:    interval,
+		DetectNAT:   cfg.DetectNAT,
+		ECMPFlows:   cfg.ECMPFlows,
+		DiscoverMTU: cfg.DiscoverMTU,
+		Decode:      cfg.Decode,
+	}
+	if cfg.Cycles > 0 {
+		req.Cycles = cfg.Cycles + cfg.Warmup
+	}
+	return req
+}
+
+// runAgentMTR runs the MTR session on the --via host through a gtrace agent
+// started over SSH and shows its results in the MTR TUI. Hops are enriched
+// locally.
+func runAgentMTR(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.TraceResult, error) {
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval: %w", err)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	// The agent starts before the TUI, so that ssh can prompt for a
+	// password and a failure is reported as a plain error
+	fmt.Fprintf(cmd.OutOrStdout(), "Starting gtrace agent on %s...\n", cfg.via)
+	client, err := agent.Start(ctx, *cfg.via, agentRequest(cfg, interval, timeout), agent.Options{Sudo: cfg.ViaSudo, Release: cfg.version})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	h := client.Header
+
+	// --record logs the session as received from the agent
+	var rec *session.Recorder
+	if cfg.Record != "" {
+		rec, err = session.Create(cfg.Record, session.Header{
+			Target:   h.Target,
+			TargetIP: h.TargetIP,
+			Protocol: h.Protocol,
+			Interval: h.Interval,
+			History:  cfg.History,
+			Warmup:   cfg.Warmup,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := rec.Close(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+				return
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Session recorded to %s (replay with: gtrace replay %s)\n", cfg.Record, cfg.Record)
+		}()
+	}
+
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	feed := make(chan tea.Msg, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(feed)
+		send := func(msg tea.Msg) bool {
+			select {
+			case feed <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		enriched := make(map[string]bool)
+		for {
+			ev, err := client.Next()
+			if err == io.EOF {
+				send(display.NoticeMsg(fmt.Sprintf("Agent on %s finished", cfg.via.Host)))
+				return
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					send(display.NoticeMsg(fmt.Sprintf("Agent on %s stopped: %v", cfg.via.Host, err)))
+				}
+				return
+			}
+
+			switch msg := ev.Msg().(type) {
+			case display.ProbeResultMsg:
+				// Enrich the first occurrence of each IP
+				if msg.IP != nil && enricher != nil && !enriched[msg.IP.String()] {
+					enriched[msg.IP.String()] = true
+					hp := hop.NewHop(msg.TTL)
+					hp.AddProbe(msg.IP, msg.RTT)
+					enricher.EnrichHop(ctx, hp)
+					msg.Enrichment = hp.Enrichment
+				}
+				rec.Probe(msg)
+				if !send(msg) {
+					return
+				}
+			case display.CycleCompleteMsg:
+				rec.Cycle(msg)
+				if !send(msg) {
+					return
+				}
+			}
+		}
+	}()
+
+	controls := display.MTRControls{
+		Pause:        client.SetPaused,
+		SetInterval:  client.SetInterval,
+		Interval:     interval,
+		SetProbeSize: client.SetProbeSize,
+		ProbeSize:    cfg.ProbeSize,
+	}
+	target := fmt.Sprintf("%s via %s", cfg.Target, cfg.via.Host)
+	err = display.ReplayMTR(cmd.OutOrStdout(), target, h.TargetIP, cfg.History, cfg.Warmup, feed, controls)
+
+	// Stop the agent before the recording is closed
+	cancel()
+	client.Close()
+	<-done
+	if err != nil {
+		return nil, fmt.Errorf("TUI error: %w", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAgentCmd_Protocol(t *testing.T) {
+	cmd := NewAgentCmd("v1.4.0")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--protocol"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "protocol=1\nversion=v1.4.0\n" {
+		t.Errorf("unexpected handshake: %q", buf.String())
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/baseline"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// NewBaselineCmd creates the baseline subcommand.
+func NewBaselineCmd() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Record a reference path and check later traces against it",
+		Long: `Record the path to a target and the latency envelope of each hop, then
+check later traces against it. Run checks from CI or after network changes:
+the exit code tells whether the path still matches.
+
+Baselines are stored in ~/.gtr/baselines/<target>.json unless --file is given.
+Requires root privileges (raw sockets), like local traces.`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newBaselineSaveCmd(&file))
+	cmd.AddCommand(newBaselineCheckCmd(&file))
+	cmd.PersistentFlags().StringVar(&file, "file", "", "Baseline file (default: ~/.gtr/baselines/<target>.json)")
+
+	return cmd
+}
+
+// newBaselineSaveCmd creates the baseline save subcommand.
+func newBaselineSaveCmd(file *string) *cobra.Command {
+	var (
+		protocol string
+		port     int
+		maxHops  int
+		packets  int
+		timeout  string
+		cycles   int
+		interval time.Duration
+		offline  bool
+		ipv4     bool
+		ipv6     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "save <target>",
+		Short: "Trace a target and record its path as the baseline",
+		Long: `Trace the target --cycles times and record the canonical path (the address
+answering most often at each hop, plus its ECMP alternatives) and the RTT
+envelope of each hop.
+
+Examples:
+  sudo gtrace baseline save example.com
+  sudo gtrace baseline save example.com --cycles 20 --protocol tcp --port 443
+  sudo gtrace baseline save example.com --file baselines/example.json`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !validProtocols[protocol] {
+				return fmt.Errorf("invalid protocol %q: must be icmp, udp, or tcp", protocol)
+			}
+			if ipv4 && ipv6 {
+				return fmt.Errorf("-4/--ipv4 and -6/--ipv6 are mutually exclusive")
+			}
+			if cycles < 1 {
+				return fmt.Errorf("--cycles must be >= 1")
+			}
+			path, err := baselinePath(*file, args[0])
+			if err != nil {
+				return err
+			}
+			if err := trace.CheckPrivileges(); err != nil {
+				return err
+			}
+
+			family := getAddressFamily(&Config{IPv4Only: ipv4, IPv6Only: ipv6})
+			targetIP, err := trace.ResolveTarget(args[0], family)
+			if err != nil {
+				return fmt.Errorf("failed to resolve target: %w", err)
+			}
+
+			cfg := defaultConfig()
+			cfg.Target = args[0]
+			cfg.Protocol = protocol
+			cfg.Port = port
+			cfg.MaxHops = maxHops
+			cfg.Packets = packets
+			cfg.Timeout = timeout
+			cfg.Offline = offline
+
+			w := cmd.OutOrStdout()
+			traces, err := traceCycles(w, &cfg, targetIP, cycles, interval)
+			if err != nil {
+				return err
+			}
+
+			b, err := baseline.Build(traces)
+			if err != nil {
+				return err
+			}
+			b.Port = port
+			b.MaxHops = maxHops
+			b.Packets = packets
+			if err := b.Save(path); err != nil {
+				return err
+			}
+
+			status := "reached"
+			if !b.ReachedTarget {
+				status = "not reached"
+			}
+			fmt.Fprintf(w, "Baseline for %s (%s): %d hops, %s, from %d traces\n", b.Target, b.TargetIP, len(b.Hops), status, b.Samples)
+			fmt.Fprintf(w, "Saved to %s\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&protocol, "protocol", "icmp", "Protocol: icmp|udp|tcp")
+	cmd.Flags().IntVar(&port, "port", 33434, "Port for TCP/UDP")
+	cmd.Flags().IntVar(&maxHops, "max-hops", 30, "Maximum hops")
+	cmd.Flags().IntVar(&packets, "packets", 3, "Packets per hop")
+	cmd.Flags().StringVar(&timeout, "timeout", "500ms", "Per-hop timeout")
+	cmd.Flags().IntVar(&cycles, "cycles", 5, "Number of traces the baseline is built from")
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "Time between traces")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Use only local enrichment DBs")
+	cmd.Flags().BoolVarP(&ipv4, "ipv4", "4", false, "Use IPv4 only")
+	cmd.Flags().BoolVarP(&ipv6, "ipv6", "6", false, "Use IPv6 only")
+
+	return cmd
+}
+
+// newBaselineCheckCmd creates the baseline check subcommand.
+func newBaselineCheckCmd(file *string) *cobra.Command {
+	var (
+		cycles     int
+		interval   time.Duration
+		tolerance  time.Duration
+		timeout    string
+		offline    bool
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "check <target>",
+		Short: "Trace a target and compare the path with its baseline",
+		Long: `Trace the baseline's target address with the baseline's protocol and port,
+and report deviations: hops inserted, removed or replaced, AS changes, the
+target no longer answering, and RTT above the baseline envelope (its worst
+RTT, or three standard deviations above the average, plus --tolerance).
+
+Only the RTT to the target decides a latency regression; intermediate hops
+out of their envelope are listed to show where the latency was added.
+
+Exit codes:
+  0  path and latency match the baseline
+  1  error (no baseline, trace failed)
+  2  same path, but the target RTT is above the envelope
+  3  path changed or the target is no longer reached
+
+Examples:
+  sudo gtrace baseline check example.com
+  sudo gtrace baseline check example.com --tolerance 20ms --json`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cycles < 1 {
+				return fmt.Errorf("--cycles must be >= 1")
+			}
+			path, err := baselinePath(*file, args[0])
+			if err != nil {
+				return err
+			}
+			b, err := baseline.Load(path)
+			if err != nil {
+				return err
+			}
+			if err := trace.CheckPrivileges(); err != nil {
+				return err
+			}
+			targetIP := net.ParseIP(b.TargetIP)
+			if targetIP == nil {
+				return fmt.Errorf("baseline %s has no target address", path)
+			}
+
+			cfg := defaultConfig()
+			cfg.Target = b.Target
+			cfg.Protocol = b.Protocol
+			if b.Port > 0 {
+				cfg.Port = b.Port
+			}
+			if b.MaxHops > 0 {
+				cfg.MaxHops = b.MaxHops
+			}
+			if b.Packets > 0 {
+				cfg.Packets = b.Packets
+			}
+			cfg.Timeout = timeout
+			cfg.Offline = offline
+
+			w := cmd.OutOrStdout()
+			progress := w
+			if jsonOutput {
+				progress = io.Discard
+			}
+			traces, err := traceCycles(progress, &cfg, targetIP, cycles, interval)
+			if err != nil {
+				return err
+			}
+
+			report, err := b.Check(traces, tolerance)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printBaselineReport(w, report)
+			}
+			return baselineExit(report)
+		},
+	}
+
+	cmd.Flags().IntVar(&cycles, "cycles", 3, "Number of traces to check")
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "Time between traces")
+	cmd.Flags().DurationVar(&tolerance, "tolerance", 10*time.Millisecond, "RTT allowed above the baseline envelope")
+	cmd.Flags().StringVar(&timeout, "timeout", "500ms", "Per-hop timeout")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Use only local enrichment DBs")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the report as JSON")
+
+	return cmd
+}
+
+// baselinePath returns file, or the default baseline path of target.
+func baselinePath(file, target string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+	return baseline.DefaultPath(target)
+}
+
+// traceCycles traces targetIP cycles times, interval apart, reporting
+// progress on w. Interrupting stops early with the traces completed so far.
+func traceCycles(w io.Writer, cfg *Config, targetIP net.IP, cycles int, interval time.Duration) ([]*hop.TraceResult, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var traces []*hop.TraceResult
+	for i := 0; i < cycles; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(w, "Tracing %s (%s) %d/%d...\n", cfg.Target, targetIP, i+1, cycles)
+		result, err := runLocalTraceToIP(ctx, cfg, targetIP)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		result.Target = cfg.Target
+		result.TargetIP = targetIP.String()
+		result.Protocol = cfg.Protocol
+		traces = append(traces, result)
+	}
+	if len(traces) == 0 {
+		return nil, fmt.Errorf("interrupted before any trace completed")
+	}
+	return traces, nil
+}
+
+// printBaselineReport writes a baseline check report.
+func printBaselineReport(w io.Writer, r *baseline.Report) {
+	fmt.Fprintf(w, "\nBaseline check for %s (%s), baseline from %s\n", r.Target, r.TargetIP, r.BaselineTime.Local().Format("2006-01-02 15:04"))
+
+	if !r.ReachedTarget {
+		fmt.Fprintln(w, "  Target not reached")
+	}
+	if len(r.PathChanges) > 0 {
+		fmt.Fprintln(w, "  Path changes:")
+		for _, c := range r.PathChanges {
+			fmt.Fprintf(w, "    %s\n", c)
+		}
+	}
+	if r.TargetUpper > 0 {
+		fmt.Fprintf(w, "  Target RTT: %.1fms (baseline envelope up to %.1fms)\n", r.TargetRTT, r.TargetUpper)
+	}
+	if len(r.Deviations) > 0 {
+		fmt.Fprintln(w, "  Hops above their envelope:")
+		for _, d := range r.Deviations {
+			fmt.Fprintf(w, "    TTL %d %s: %.1fms (up to %.1fms)\n", d.TTL, d.IP, d.RTT, d.Upper)
+		}
+	}
+
+	switch r.Status {
+	case baseline.StatusOK:
+		fmt.Fprintln(w, "Result: OK, path matches the baseline")
+	case baseline.StatusLatency:
+		fmt.Fprintln(w, "Result: LATENCY, target RTT above the baseline envelope")
+	case baseline.StatusPath:
+		fmt.Fprintln(w, "Result: PATH, path deviates from the baseline")
+	}
+}
+
+// baselineExit returns the error setting the exit code of a check.
+func baselineExit(r *baseline.Report) error {
+	switch r.Status {
+	case baseline.StatusLatency:
+		return &exitError{code: exitLatency, err: fmt.Errorf("%s: latency above the baseline envelope", r.Target)}
+	case baseline.StatusPath:
+		return &exitError{code: exitPath, err: fmt.Errorf("%s: path deviates from the baseline", r.Target)}
+	}
+	return nil
+}
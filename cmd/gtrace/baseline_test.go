@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/internal/baseline"
+)
+
+func runBaselineCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"baseline"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestBaselineCommand_Validation(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"save without target", []string{"save"}, "accepts 1 arg(s)"},
+		{"save invalid protocol", []string{"save", "example.com", "--protocol", "sctp"}, "invalid protocol"},
+		{"save no cycles", []string{"save", "example.com", "--cycles", "0"}, "--cycles must be >= 1"},
+		{"save both families", []string{"save", "example.com", "-4", "-6"}, "mutually exclusive"},
+		{"check missing baseline", []string{"check", "example.com", "--file", missing}, "no baseline at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runBaselineCmd(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBaselineExit(t *testing.T) {
+	tests := []struct {
+		status baseline.Status
+		code   int
+	}{
+		{baseline.StatusOK, 0},
+		{baseline.StatusLatency, exitLatency},
+		{baseline.StatusPath, exitPath},
+	}
+	for _, tt := range tests {
+		err := baselineExit(&baseline.Report{Target: "example.com", Status: tt.status})
+		code := 0
+		var exit *exitError
+		if errors.As(err, &exit) {
+			code = exit.code
+		}
+		if code != tt.code {
+			t.Errorf("status %s: expected exit code %d, got %d", tt.status, tt.code, code)
+		}
+	}
+}
+
+func TestPrintBaselineReport(t *testing.T) {
+	var buf bytes.Buffer
+	printBaselineReport(&buf, &baseline.Report{
+		Target:        "example.com",
+		TargetIP:      "93.184.216.34",
+		Status:        baseline.StatusLatency,
+		ReachedTarget: true,
+		TargetRTT:     60,
+		TargetUpper:   35,
+		Deviations:    []baseline.Deviation{{TTL: 3, IP: "93.184.216.34", RTT: 60, Upper: 35}},
+	})
+	out := buf.String()
+	for _, want := range []string{"Target RTT: 60.0ms (baseline envelope up to 35.0ms)", "TTL 3 93.184.216.34: 60.0ms", "Result: LATENCY"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
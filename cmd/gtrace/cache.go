@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hervehildenbrand/gtrace/internal/enrich"
+	"github.com/spf13/cobra"
+)
+
+// NewCacheCmd creates the cache subcommand for managing the enrichment cache.
+func NewCacheCmd() *cobra.Command {
+	var cacheDir string
+
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the on-disk enrichment cache",
+		Long: `ASN, geolocation and reverse DNS results are cached on disk for a week,
+so repeated traces over the same path don't query Team Cymru, ip-api.com
+and DNS for every hop again.`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Delete all cached enrichment results",
+		Long: `Delete all cached enrichment results.
+
+Examples:
+  gtrace cache clear
+  gtrace cache clear --cache-dir /tmp/gtrace-cache`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := resolveCacheDir(cacheDir)
+			if err != nil {
+				return err
+			}
+			n, err := enrich.ClearDiskCache(dir)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Cleared %d cached entries from %s\n", n, dir)
+			return nil
+		},
+	})
+
+	cmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Enrichment cache directory (default: ~/.gtr/cache)")
+
+	return cmd
+}
+
+// resolveCacheDir returns dir, or the default cache directory when empty.
+func resolveCacheDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	return enrich.DefaultCacheDir()
+}
+
+// openDiskCache opens the enrichment cache in dir (default when empty).
+// Returns nil, after a warning on w, if the cache directory is unavailable.
+func openDiskCache(w io.Writer, dir string) *enrich.DiskCache {
+	dir, err := resolveCacheDir(dir)
+	if err != nil {
+		fmt.Fprintf(w, "Warning: enrichment cache disabled: %v\n", err)
+		return nil
+	}
+	return enrich.OpenDiskCache(dir, enrich.DefaultDiskCacheTTL)
+}
+
+// saveDiskCache writes the enrichment cache, warning on w on failure.
+func saveDiskCache(w io.Writer, c *enrich.DiskCache) {
+	if c == nil {
+		return
+	}
+	if err := c.Save(); err != nil {
+		fmt.Fprintf(w, "Warning: %v\n", err)
+	}
+}
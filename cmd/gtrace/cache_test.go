@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/enrich"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestCacheClearCommand(t *testing.T) {
+	dir := t.TempDir()
+	c := enrich.OpenDiskCache(dir, time.Hour)
+	c.Set("8.8.8.8", &hop.Enrichment{ASN: 15169})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"cache", "clear", "--cache-dir", dir})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Cleared 1 cached entries") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+	if enrich.OpenDiskCache(dir, time.Hour).Len() != 0 {
+		t.Error("expected cache to be empty")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/collector"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/spf13/cobra"
+)
+
+// NewCollectorCmd creates the collector subcommand, which receives the
+// results of gtrace agents and compares their paths.
+func NewCollectorCmd() *cobra.Command {
+	var (
+		listen      string
+		certFile    string
+		keyFile     string
+		caFile      string
+		trustDomain string
+		interval    string
+		noColor     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "collector",
+		Short: "Receive trace results from gtrace agents and compare their paths",
+		Long: `Accept trace results pushed by gtrace agents running --monitor with a
+"collector" metric sink, and print a fleet comparison every interval: for
+each target, the latest path from every agent side by side, with where the
+paths diverge.
+
+Agents stream their results over gRPC with mutual TLS. Both ends present
+X.509 SVIDs, certificates naming them by SPIFFE ID (spiffe://domain/path),
+e.g. issued by SPIRE. The collector accepts any agent whose SVID is issued
+by --ca with an ID in --trust-domain, and names agents by the path of their
+ID. Agents check the SPIFFE ID of the collector instead of a host name.
+
+No privileges are needed: the collector sends no probes.
+
+Examples:
+  gtrace collector --trust-domain example.org --cert svid.pem --key svid.key --ca bundle.pem
+  gtrace collector --trust-domain example.org --cert svid.pem --key svid.key --ca bundle.pem --listen :9443 --interval 1m`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, _, err := net.SplitHostPort(listen); err != nil {
+				return fmt.Errorf("invalid --listen %q: must be host:port", listen)
+			}
+			every, err := time.ParseDuration(interval)
+			if err != nil || every <= 0 {
+				return fmt.Errorf("invalid interval %q", interval)
+			}
+			if trustDomain == "" {
+				return fmt.Errorf("--trust-domain is required: agents are accepted from this SPIFFE trust domain only")
+			}
+			if id, err := collector.ParseID("spiffe://" + trustDomain); err != nil || id.Path != "" {
+				return fmt.Errorf("invalid --trust-domain %q", trustDomain)
+			}
+			tlsCfg, err := collector.ServerTLSConfig(collector.TLSFiles{Cert: certFile, Key: keyFile, CA: caFile}, trustDomain)
+			if err != nil {
+				return fmt.Errorf("%w (--cert, --key, --ca)", err)
+			}
+
+			ln, err := net.Listen("tcp", listen)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigCh
+				cancel()
+			}()
+
+			c := collector.New()
+			w := cmd.OutOrStdout()
+			go func() {
+				ticker := time.NewTicker(every)
+				defer ticker.Stop()
+				shown := 0
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+					// Nothing new since the last comparison
+					if v := c.Version(); v != shown {
+						shown = v
+						writeFleetComparison(w, c, noColor)
+					}
+				}
+			}()
+
+			fmt.Fprintf(w, "Collecting from agents of spiffe://%s on %s\n", trustDomain, ln.Addr())
+			fmt.Fprintf(w, "Comparing their paths every %v\n", every)
+			fmt.Fprintln(w, "Press Ctrl+C to stop")
+			return collector.Serve(ctx, ln, tlsCfg, c)
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", ":9443", "Address agents connect to")
+	cmd.Flags().StringVar(&certFile, "cert", "", "X.509 SVID of the collector, PEM certificate chain")
+	cmd.Flags().StringVar(&keyFile, "key", "", "Private key of the SVID, PEM")
+	cmd.Flags().StringVar(&caFile, "ca", "", "Trust bundle agent SVIDs are verified against, PEM")
+	cmd.Flags().StringVar(&trustDomain, "trust-domain", "", "SPIFFE trust domain agents must belong to (e.g. example.org)")
+	cmd.Flags().StringVar(&interval, "interval", "30s", "Time between fleet comparisons")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colors")
+
+	return cmd
+}
+
+// writeFleetComparison prints the agents of c and, for each target, their
+// latest paths side by side.
+func writeFleetComparison(w io.Writer, c *collector.Collector, noColor bool) {
+	agents := c.Agents()
+	fmt.Fprintf(w, "\n=== Fleet of %d agent(s) at %s ===\n", len(agents), time.Now().Format("15:04:05"))
+	for _, a := range agents {
+		fmt.Fprintf(w, "  %-40s %4d result(s), last %s ago\n", a.ID, a.Reports, time.Since(a.LastSeen).Round(time.Second))
+	}
+
+	renderer := display.NewCompareRenderer(w, noColor)
+	for _, target := range c.Targets() {
+		fmt.Fprintln(w)
+		if err := renderer.RenderAll(c.Results(target)); err != nil {
+			fmt.Fprintf(w, "%s: %v\n", target, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCollectorCmd_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"bad listen", []string{"--listen", "9443", "--trust-domain", "example.org"}, "invalid --listen"},
+		{"bad interval", []string{"--interval", "soon", "--trust-domain", "example.org"}, "invalid interval"},
+		{"no trust domain", nil, "--trust-domain is required"},
+		{"trust domain with path", []string{"--trust-domain", "example.org/gtrace"}, "invalid --trust-domain"},
+		{"no certificate", []string{"--trust-domain", "example.org"}, "required for mutual TLS"},
+		{"missing files", []string{"--trust-domain", "example.org", "--cert", "/nonexistent/svid.pem", "--key", "/nonexistent/svid.key", "--ca", "/nonexistent/ca.pem"}, "failed to load certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewCollectorCmd()
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/diff"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/export"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// NewCompareCmd creates the compare subcommand.
+func NewCompareCmd() *cobra.Command {
+	var (
+		latencyIncrease time.Duration
+		latencyAbove    string
+		lossAbove       string
+		output          string
+		outputFormat    string
+		noColor         bool
+		alignBy         string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare <file> <file> [file...]",
+		Short: "Compare traces saved with --output json",
+		Long: `Load traces previously exported as JSON (-o trace.json) and show them side
+by side, then list what changed from the first trace to each of the others:
+hops inserted, removed or replaced, AS and MPLS changes, and latency or loss
+regressions (see gtrace diff). Nothing is traced, so today's path can be checked against last week's.
+
+A file holding several traces (multi-target or --compare exports) adds all
+of them. Up to 5 traces can be compared.`,
+		Example: `  gtrace compare last-week.json today.json
+  gtrace compare monday.json tuesday.json wednesday.json
+  gtrace compare before.json after.json --latency-above 20ms --loss-above 5%
+  gtrace compare london.json tokyo.json --align-by asn`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := diffOptions(latencyIncrease, latencyAbove, lossAbove)
+			if err != nil {
+				return err
+			}
+			if alignBy != "ttl" && alignBy != "asn" {
+				return fmt.Errorf("invalid --align-by %q: must be ttl or asn", alignBy)
+			}
+
+			var results []*hop.TraceResult
+			for _, path := range args {
+				traces, err := readSavedTraces(path)
+				if err != nil {
+					return err
+				}
+				results = append(results, traces...)
+			}
+			if len(results) > maxTargets {
+				return fmt.Errorf("%d traces to compare, at most %d can be shown side by side", len(results), maxTargets)
+			}
+
+			w := cmd.OutOrStdout()
+			renderer := display.NewCompareRenderer(w, noColor)
+			renderer.AlignByASN = alignBy == "asn"
+			if err := renderer.RenderAll(results); err != nil {
+				return err
+			}
+
+			printTraceChanges(w, results, opts)
+
+			if output != "" {
+				if err := export.ExportAllToFile(output, export.Format(outputFormat), results); err != nil {
+					return fmt.Errorf("failed to export: %w", err)
+				}
+				fmt.Fprintf(w, "Results exported to %s\n", output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&latencyIncrease, "latency-increase", diff.DefaultOptions().LatencyIncrease, "Report hops whose RTT went up by at least this much (0 to disable)")
+	cmd.Flags().StringVar(&latencyAbove, "latency-above", "", "Only report latency regressions ending above this (e.g., 100ms)")
+	cmd.Flags().StringVar(&lossAbove, "loss-above", "", "Report hops whose packet loss went up past this (e.g., 5%)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Export the compared traces to a file (format from extension)")
+	cmd.Flags().StringVar(&outputFormat, "output-format", "", "Explicit export format: json|csv|text|dot|d2|scamper")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colors")
+	cmd.Flags().StringVar(&alignBy, "align-by", "ttl", "Line rows up by ttl, or by asn so paths through the same providers match")
+
+	return cmd
+}
+
+// readSavedTraces loads the traces of a JSON export and labels each with
+// the file it came from and when it was traced.
+func readSavedTraces(path string) ([]*hop.TraceResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	defer f.Close()
+
+	results, err := export.ReadJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	name := filepath.Base(path)
+	for _, tr := range results {
+		label := name
+		if tr.Source != "" && tr.Source != "Local" {
+			label += " " + tr.Source
+		}
+		if !tr.StartTime.IsZero() {
+			label += " (" + tr.StartTime.UTC().Format("2006-01-02 15:04 UTC") + ")"
+		}
+		tr.Source = label
+	}
+	return results, nil
+}
+
+// printTraceChanges lists the changes from the first trace to each of the
+// others.
+func printTraceChanges(w io.Writer, results []*hop.TraceResult, opts diff.Options) {
+	base := results[0]
+	for _, tr := range results[1:] {
+		fmt.Fprintf(w, "\nChanges from %s to %s:\n", base.Source, tr.Source)
+		if base.TargetIP != tr.TargetIP {
+			fmt.Fprintf(w, "  Note: different targets (%s vs %s)\n", base.TargetIP, tr.TargetIP)
+		}
+		printChanges(w, diff.Compare(base, tr, opts).Changes)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/export"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// writeSavedTrace exports a trace to target through the given routers to a
+// JSON file, like gtrace -o trace.json does.
+func writeSavedTrace(t *testing.T, name string, start time.Time, routers ...string) string {
+	t.Helper()
+	tr := hop.NewTraceResult("example.com", "93.184.216.34")
+	tr.Protocol = "icmp"
+	tr.StartTime = start
+	for i, ip := range append(routers, "93.184.216.34") {
+		h := hop.NewHop(i + 1)
+		h.AddProbe(net.ParseIP(ip), time.Duration(i+1)*time.Millisecond)
+		tr.AddHop(h)
+	}
+	tr.ReachedTarget = true
+
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := export.NewJSONExporter().Export(f, tr); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCompareCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"compare"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestCompareCommand_ShowsRouteChanges(t *testing.T) {
+	lastWeek := writeSavedTrace(t, "last-week.json", time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), "192.168.1.1", "10.0.0.1")
+	today := writeSavedTrace(t, "today.json", time.Date(2024, 3, 8, 9, 30, 0, 0, time.UTC), "192.168.1.1", "10.0.0.9")
+
+	out, err := runCompareCmd(t, lastWeek, today, "--no-color")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"Changes from last-week.json (2024-03-01 09:30 UTC) to today.json (2024-03-08 09:30 UTC)",
+		"10.0.0.9",
+		"[replaced] TTL 2: 10.0.0.1 replaced by 10.0.0.9",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestCompareCommand_NoChanges(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	a := writeSavedTrace(t, "a.json", start, "192.168.1.1")
+	b := writeSavedTrace(t, "b.json", start.Add(time.Hour), "192.168.1.1")
+
+	out, err := runCompareCmd(t, a, b, "--no-color")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "No changes") {
+		t.Errorf("expected no changes in output:\n%s", out)
+	}
+}
+
+func TestCompareCommand_Errors(t *testing.T) {
+	a := writeSavedTrace(t, "a.json", time.Now(), "192.168.1.1")
+	notTrace := filepath.Join(t.TempDir(), "other.json")
+	if err := os.WriteFile(notTrace, []byte(`{"foo":1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"single file", []string{a}, "requires at least 2 arg(s)"},
+		{"missing file", []string{a, filepath.Join(t.TempDir(), "missing.json")}, "failed to open trace file"},
+		{"not a trace", []string{a, notTrace}, "not a gtrace JSON export"},
+		{"too many traces", []string{a, a, a, a, a, a}, "at most 5"},
+		{"bad threshold", []string{a, a, "--loss-above", "lots"}, "invalid loss threshold"},
+		{"bad alignment", []string{a, a, "--align-by", "hop"}, "must be ttl or asn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runCompareCmd(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
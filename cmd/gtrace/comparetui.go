@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// maxLiveCompareSources is the most sources the live compare TUI shows; it
+// matches the column limit of the static side-by-side table.
+const maxLiveCompareSources = 3
+
+// liveCompare reports whether compare mode runs in the live TUI: an
+// interactive session with few enough sources to show side by side, aligned
+// by TTL.
+func liveCompare(cfg *Config, interactive bool) bool {
+	if !interactive || cfg.Simple || cfg.Output != "" || cfg.AlignBy == "asn" {
+		return false
+	}
+	return len(compareSourceNames(cfg)) <= maxLiveCompareSources
+}
+
+// compareSourceNames returns a placeholder column name for every source a
+// comparison expects, until the probes report their own locations.
+func compareSourceNames(cfg *Config) []string {
+	var names []string
+	if !cfg.NoLocal {
+		names = append(names, "Local")
+	}
+	remote := 0
+	for _, loc := range fromLocations(cfg) {
+		remote += max(loc.Limit, 1)
+	}
+	if remote == 1 {
+		return append(names, cfg.From)
+	}
+	for i := range remote {
+		names = append(names, fmt.Sprintf("Remote %d", i+1))
+	}
+	return names
+}
+
+// runCompareTUI runs compare mode in the live compare TUI: local hops and
+// partial GlobalPing results are shown side by side as they arrive.
+func runCompareTUI(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgChan := make(chan tea.Msg, 100)
+	send := func(msg tea.Msg) {
+		select {
+		case msgChan <- msg:
+		case <-ctx.Done():
+		}
+	}
+
+	names := compareSourceNames(cfg)
+	remoteOffset := 0
+	remoteStarted := make(chan struct{})
+	markStarted := sync.OnceFunc(func() { close(remoteStarted) })
+	var wg sync.WaitGroup
+
+	if !cfg.NoLocal {
+		remoteOffset = 1
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// With --align the local trace waits until the remote measurement
+			// exists (or failed to start)
+			if cfg.Align {
+				select {
+				case <-remoteStarted:
+				case <-ctx.Done():
+				}
+			}
+			localCfg := *cfg
+			localCfg.Simple = true
+			localCfg.From = ""
+			result, err := runLocalTraceForCompare(ctx, &localCfg, func(h *hop.Hop) {
+				send(display.CompareHopMsg{Source: 0, Hop: h})
+			})
+			if result != nil {
+				result.Source = "Local"
+				send(display.CompareResultMsg{Source: 0, Result: result})
+			}
+			send(display.CompareDoneMsg{Source: 0, Err: err})
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer markStarted()
+		results, err := runGlobalPingTraceForCompare(ctx, io.Discard, cfg, markStarted, func(partial []*hop.TraceResult) {
+			for i, tr := range partial {
+				send(display.CompareResultMsg{Source: remoteOffset + i, Result: tr})
+			}
+		})
+		if err != nil {
+			for i := remoteOffset; i < len(names); i++ {
+				send(display.CompareDoneMsg{Source: i, Err: err})
+			}
+			return
+		}
+		for i, tr := range results {
+			send(display.CompareResultMsg{Source: remoteOffset + i, Result: tr})
+			send(display.CompareDoneMsg{Source: remoteOffset + i})
+		}
+		// Probes the location could not provide leave their column empty
+		for i := remoteOffset + len(results); i < len(names); i++ {
+			send(display.CompareDoneMsg{Source: i, Err: fmt.Errorf("no probe available")})
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(msgChan)
+	}()
+
+	sources, err := display.RunCompareTUI(cfg.Target, names, cfg.NoColor, msgChan)
+	if err != nil {
+		return fmt.Errorf("TUI error: %w", err)
+	}
+
+	// Quitting early stops the traces still running
+	cancel()
+	wg.Wait()
+
+	warnCompareSkew(cmd.OutOrStdout(), cfg, sources)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareSourceNames(t *testing.T) {
+	tests := []struct {
+		cfg  *Config
+		want []string
+	}{
+		{&Config{From: "London"}, []string{"Local", "London"}},
+		{&Config{From: "London", NoLocal: true, Limit: 2}, []string{"Remote 1", "Remote 2"}},
+		{&Config{From: "London,Tokyo"}, []string{"Local", "Remote 1", "Remote 2"}},
+		{&Config{From: "country:US@3", NoLocal: true}, []string{"Remote 1", "Remote 2", "Remote 3"}},
+	}
+	for _, tt := range tests {
+		if got := compareSourceNames(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("compareSourceNames(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestLiveCompare_RequiresTerminalAndFewSources(t *testing.T) {
+	if !liveCompare(&Config{From: "London"}, true) {
+		t.Error("expected live compare of local and one location")
+	}
+	if !liveCompare(&Config{From: "London,Tokyo"}, true) {
+		t.Error("expected live compare of three sources")
+	}
+
+	if liveCompare(&Config{From: "London"}, false) {
+		t.Error("expected no live compare when output is not a terminal")
+	}
+	for _, cfg := range []*Config{
+		{From: "London", Simple: true},
+		{From: "London", Output: "out.json"},
+		{From: "London,Tokyo,Paris"},
+		{From: "London", Limit: 4, NoLocal: true},
+	} {
+		if liveCompare(cfg, true) {
+			t.Errorf("expected no live compare for %+v", cfg)
+		}
+	}
+}
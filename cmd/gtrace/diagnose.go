@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// runFilterDiagnosis traces the path of result, a tcp or udp trace that did
+// not reach the target, again with ICMP probes and reports the hop after
+// which only the tcp or udp probes go unanswered.
+func runFilterDiagnosis(ctx context.Context, cmd *cobra.Command, cfg *Config, result *hop.TraceResult) error {
+	targetIP := net.ParseIP(result.TargetIP)
+	if targetIP == nil {
+		var err error
+		if targetIP, err = trace.ResolveTarget(cfg.Target, getAddressFamily(cfg)); err != nil {
+			return fmt.Errorf("failed to resolve target: %w", err)
+		}
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "\nDiagnosing: tracing %s again with icmp probes\n", targetIP)
+	icmpCfg := *cfg
+	icmpCfg.Protocol = string(trace.ProtocolICMP)
+	icmpCfg.EndToEnd = false
+	icmp, err := runLocalTraceToIP(ctx, &icmpCfg, targetIP)
+	if err != nil {
+		return err
+	}
+
+	d := trace.DiagnoseFilter(result, icmp)
+	fmt.Fprint(cmd.OutOrStdout(), formatFilterDiagnosis(d, cfg.Port, cfg.Protocol))
+	return nil
+}
+
+// formatFilterDiagnosis formats the verdict of a filter diagnosis of probes
+// of protocol to port.
+func formatFilterDiagnosis(d *trace.FilterDiagnosis, port int, protocol string) string {
+	var b strings.Builder
+	service := fmt.Sprintf("%d/%s", port, protocol)
+	b.WriteString("\n")
+	switch {
+	case d.ProbeReached:
+		fmt.Fprintf(&b, "Diagnosis: port %s reaches the target, nothing filters it\n", service)
+	case d.Filtered():
+		reach := fmt.Sprintf("hop %d (%s)", d.ICMPLastHop.TTL, hopLabel(d.ICMPLastHop))
+		if d.ICMPReached {
+			reach = "the target"
+		}
+		if d.LastHop == nil {
+			fmt.Fprintf(&b, "Diagnosis: port %s appears filtered on this host or at the first hop\n", service)
+			fmt.Fprintf(&b, "  icmp probes are answered up to %s, no %s probe is answered at all\n", reach, protocol)
+			break
+		}
+		fmt.Fprintf(&b, "Diagnosis: port %s appears filtered at hop %d (%s)\n", service, d.LastHop.TTL, hopLabel(d.LastHop))
+		fmt.Fprintf(&b, "  icmp probes are answered up to %s, %s probes get no answer past hop %d\n", reach, protocol, d.LastHop.TTL)
+		fmt.Fprintf(&b, "  A firewall or ACL on hop %d or on the link into hop %d (%s) drops them\n",
+			d.LastHop.TTL, d.NextICMPHop.TTL, hopLabel(d.NextICMPHop))
+	case d.ICMPLastHop == nil:
+		fmt.Fprintf(&b, "Diagnosis: icmp probes get no answer either, the path is down or all traffic from this host is blocked, not only port %s\n", service)
+	default:
+		fmt.Fprintf(&b, "Diagnosis: icmp probes stop at hop %d (%s) too, the path or the target is unreachable, not only port %s\n",
+			d.ICMPLastHop.TTL, hopLabel(d.ICMPLastHop), service)
+	}
+	return b.String()
+}
+
+// hopLabel returns the address of h followed by its AS number, if known.
+func hopLabel(h *hop.Hop) string {
+	label := h.PrimaryIP().String()
+	if h.Enrichment.ASN > 0 {
+		label += fmt.Sprintf(", AS%d", h.Enrichment.ASN)
+	}
+	return label
+}
+
+// formatGatewayDiagnosis formats the verdict of a gateway diagnosis, or ""
+// when nothing points at this host or its LAN.
+func formatGatewayDiagnosis(d *trace.GatewayDiagnosis) string {
+	var b strings.Builder
+	switch {
+	case d.LocalError != "":
+		fmt.Fprintf(&b, "\nDiagnosis: probes could not leave this host: %s\n", d.LocalError)
+		b.WriteString("  The problem is on this host or its LAN, not upstream\n")
+	case d.GatewaySilent:
+		b.WriteString("\nDiagnosis: nothing answered, not even the gateway (hop 1)\n")
+		b.WriteString("  The problem is likely on this host or its LAN (gateway down, Wi-Fi or cable), not upstream\n")
+	case d.GatewayLoss > 0:
+		fmt.Fprintf(&b, "\nDiagnosis: loss starts at the gateway (hop 1, %s): %.0f%% lost there, %.0f%% at the target\n",
+			hopLabel(d.Gateway), d.GatewayLoss, d.TargetLoss)
+		b.WriteString("  The problem is likely on the LAN (Wi-Fi, cable or an overloaded router), not upstream\n")
+	}
+	return b.String()
+}
+
+// formatOverlay explains a VPN or overlay network on the path, or returns ""
+// when there is none.
+func formatOverlay(o *hop.Overlay) string {
+	if o == nil {
+		return ""
+	}
+	evidence := strings.Join(o.Evidence, ", ")
+	if o.Kind == trace.OverlayCGNAT {
+		return fmt.Sprintf("\nOverlay: carrier-grade NAT or an overlay network (%s)\n", evidence) +
+			"  Addresses in 100.64.0.0/10 belong to your provider's NAT or to an overlay like Tailscale, not the public internet\n"
+	}
+	tunnel := o.Kind + " tunnel"
+	if o.Kind == trace.OverlayTailscale {
+		tunnel = "Tailscale network"
+	}
+	return fmt.Sprintf("\nOverlay: the path goes through a %s (%s)\n", tunnel, evidence) +
+		"  Routers inside the tunnel are hidden: the first hops are the tunnel's, so a short trace to the internet is expected\n"
+}
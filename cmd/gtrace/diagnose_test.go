@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// diagnoseTestHop returns a hop answered from ip, announced by asn.
+func diagnoseTestHop(ttl int, ip string, asn uint32) *hop.Hop {
+	h := hop.NewHop(ttl)
+	h.AddProbe(net.ParseIP(ip), 0)
+	h.Enrichment.ASN = asn
+	return h
+}
+
+func TestFormatFilterDiagnosis_Filtered(t *testing.T) {
+	d := &trace.FilterDiagnosis{
+		LastHop:     diagnoseTestHop(9, "62.115.0.1", 1299),
+		ICMPLastHop: diagnoseTestHop(14, "192.0.2.1", 64500),
+		NextICMPHop: diagnoseTestHop(10, "62.115.0.2", 1299),
+		ICMPReached: true,
+		ICMPFurther: true,
+	}
+	out := formatFilterDiagnosis(d, 443, "tcp")
+
+	for _, want := range []string{
+		"port 443/tcp appears filtered at hop 9 (62.115.0.1, AS1299)",
+		"icmp probes are answered up to the target, tcp probes get no answer past hop 9",
+		"link into hop 10 (62.115.0.2, AS1299)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diagnosis missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatFilterDiagnosis_NotFiltered(t *testing.T) {
+	d := &trace.FilterDiagnosis{
+		LastHop:     diagnoseTestHop(5, "10.0.0.5", 0),
+		ICMPLastHop: diagnoseTestHop(5, "10.0.0.5", 0),
+	}
+	out := formatFilterDiagnosis(d, 33434, "udp")
+	if !strings.Contains(out, "icmp probes stop at hop 5 (10.0.0.5) too") {
+		t.Errorf("diagnosis should blame the path:\n%s", out)
+	}
+
+	out = formatFilterDiagnosis(&trace.FilterDiagnosis{}, 33434, "udp")
+	if !strings.Contains(out, "icmp probes get no answer either") {
+		t.Errorf("diagnosis should report icmp unanswered:\n%s", out)
+	}
+}
+
+func TestFormatGatewayDiagnosis(t *testing.T) {
+	tests := []struct {
+		name string
+		d    *trace.GatewayDiagnosis
+		want string
+	}{
+		{"local error", &trace.GatewayDiagnosis{LocalError: "network interface down"},
+			"probes could not leave this host: network interface down"},
+		{"silent", &trace.GatewayDiagnosis{GatewaySilent: true},
+			"nothing answered, not even the gateway"},
+		{"loss", &trace.GatewayDiagnosis{Gateway: diagnoseTestHop(1, "192.168.1.1", 0), GatewayLoss: 30, TargetLoss: 40},
+			"loss starts at the gateway (hop 1, 192.168.1.1): 30% lost there, 40% at the target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out := formatGatewayDiagnosis(tt.d); !strings.Contains(out, tt.want) {
+				t.Errorf("diagnosis missing %q:\n%s", tt.want, out)
+			}
+		})
+	}
+
+	if out := formatGatewayDiagnosis(&trace.GatewayDiagnosis{TargetLoss: 0}); out != "" {
+		t.Errorf("expected no diagnosis for a healthy gateway, got %q", out)
+	}
+}
+
+func TestFormatOverlay(t *testing.T) {
+	if out := formatOverlay(nil); out != "" {
+		t.Errorf("expected nothing without an overlay, got %q", out)
+	}
+
+	out := formatOverlay(&hop.Overlay{Kind: trace.OverlayWireGuard, Evidence: []string{"route through wg0", "MTU 1420, the WireGuard default"}})
+	for _, want := range []string{"through a WireGuard tunnel (route through wg0, MTU 1420", "short trace to the internet is expected"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out = formatOverlay(&hop.Overlay{Kind: trace.OverlayCGNAT, Evidence: []string{"hop 2 100.72.0.1 in 100.64.0.0/10"}})
+	if !strings.Contains(out, "carrier-grade NAT or an overlay network (hop 2 100.72.0.1") {
+		t.Errorf("unexpected CGNAT output:\n%s", out)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/diff"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// NewDiffCmd creates the diff subcommand.
+func NewDiffCmd() *cobra.Command {
+	var (
+		latencyIncrease time.Duration
+		latencyAbove    string
+		lossAbove       string
+		jsonOutput      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff <old.json> <new.json>",
+		Short: "Show how the path changed between two saved traces",
+		Long: `Align two traces saved with -o trace.json by hop address and AS (not just
+by TTL) and list what changed: hops inserted, removed or replaced, AS
+changes, MPLS label changes, and latency or loss regressions.
+
+Use --json for a machine-readable diff.`,
+		Example: `  gtrace diff last-week.json today.json
+  gtrace diff before.json after.json --latency-increase 10ms --loss-above 5%
+  gtrace diff before.json after.json --json | jq '.changes[].kind'`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := diffOptions(latencyIncrease, latencyAbove, lossAbove)
+			if err != nil {
+				return err
+			}
+
+			var traces [2]*hop.TraceResult
+			for i, path := range args {
+				results, err := readSavedTraces(path)
+				if err != nil {
+					return err
+				}
+				if len(results) != 1 {
+					return fmt.Errorf("%s holds %d traces, diff needs one per file", path, len(results))
+				}
+				traces[i] = results[0]
+			}
+
+			res := diff.Compare(traces[0], traces[1], opts)
+			w := cmd.OutOrStdout()
+			if jsonOutput {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(res)
+			}
+			printDiff(w, res)
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&latencyIncrease, "latency-increase", diff.DefaultOptions().LatencyIncrease, "Report hops whose RTT went up by at least this much (0 to disable)")
+	cmd.Flags().StringVar(&latencyAbove, "latency-above", "", "Only report latency regressions ending above this (e.g., 100ms)")
+	cmd.Flags().StringVar(&lossAbove, "loss-above", "", "Report hops whose packet loss went up past this (e.g., 5%)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the diff as JSON")
+
+	return cmd
+}
+
+// diffOptions builds the diff options from the command line thresholds.
+func diffOptions(latencyIncrease time.Duration, latencyAbove, lossAbove string) (diff.Options, error) {
+	latencyThreshold, err := parseLatencyThreshold(latencyAbove)
+	if err != nil {
+		return diff.Options{}, fmt.Errorf("invalid latency threshold: %w", err)
+	}
+	lossThreshold, err := parseLossThreshold(lossAbove)
+	if err != nil {
+		return diff.Options{}, fmt.Errorf("invalid loss threshold: %w", err)
+	}
+	return diff.Options{
+		LatencyIncrease:  latencyIncrease,
+		LatencyThreshold: latencyThreshold,
+		LossThreshold:    lossThreshold,
+	}, nil
+}
+
+// printDiff writes the aligned paths followed by the list of changes.
+func printDiff(w io.Writer, res *diff.Result) {
+	fmt.Fprintf(w, "Path to %s (%s)\n", res.New.Target, res.New.TargetIP)
+	fmt.Fprintf(w, "  old: %s\n", describeTrace(res.Old))
+	fmt.Fprintf(w, "  new: %s\n", describeTrace(res.New))
+	if oldPath, newPath := formatASPath(res.Old.ASPath), formatASPath(res.New.ASPath); oldPath != newPath {
+		fmt.Fprintf(w, "  AS path: %s -> %s\n", oldPath, newPath)
+	}
+	fmt.Fprintln(w)
+	for _, line := range res.Divergence.Summary() {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	fmt.Fprintln(w)
+
+	replaced := make(map[diff.Step]bool)
+	for _, c := range res.Changes {
+		if c.Kind == diff.KindReplaced {
+			replaced[diff.Step{Old: c.Old, New: c.New}] = true
+		}
+	}
+	fmt.Fprintf(w, "    %-4s %-30s %-4s %s\n", "TTL", "Old", "TTL", "New")
+	for _, step := range res.Alignment {
+		marker := " "
+		switch {
+		case step.Old == nil:
+			marker = "+"
+		case step.New == nil:
+			marker = "-"
+		case replaced[step]:
+			marker = "~"
+		}
+		fmt.Fprintf(w, "  %s %-4s %-30s %-4s %s\n", marker, stepTTL(step.Old), stepHop(step.Old), stepTTL(step.New), stepHop(step.New))
+	}
+
+	fmt.Fprintln(w)
+	printChanges(w, res.Changes)
+}
+
+// printChanges lists the changes of a diff, one per line.
+func printChanges(w io.Writer, changes []diff.Change) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "  No changes")
+		return
+	}
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
+
+// describeTrace summarizes one side of a diff.
+func describeTrace(info diff.TraceInfo) string {
+	status := "not reached"
+	if info.ReachedTarget {
+		status = "reached"
+	}
+	return fmt.Sprintf("%s, %d hops, %s", info.Source, info.Hops, status)
+}
+
+// formatASPath formats an AS path as "AS3356 AS15169", or "unknown".
+func formatASPath(path []uint32) string {
+	if len(path) == 0 {
+		return "unknown"
+	}
+	parts := make([]string, len(path))
+	for i, asn := range path {
+		parts[i] = fmt.Sprintf("AS%d", asn)
+	}
+	return strings.Join(parts, " ")
+}
+
+func stepTTL(ref *diff.HopRef) string {
+	if ref == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", ref.TTL)
+}
+
+func stepHop(ref *diff.HopRef) string {
+	if ref == nil {
+		return ""
+	}
+	return ref.String()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/diff"
+)
+
+func runDiffCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"diff"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestDiffCommand_PrintsAlignedPaths(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	prev := writeSavedTrace(t, "old.json", start, "192.168.1.1", "10.0.0.1")
+	curr := writeSavedTrace(t, "new.json", start, "192.168.1.1", "10.0.0.5", "10.0.0.1")
+
+	out, err := runDiffCmd(t, prev, curr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"Path to example.com (93.184.216.34)",
+		"old: old.json (2024-03-01 09:30 UTC), 3 hops, reached",
+		"  + ",
+		"[inserted] TTL 2: hop inserted: 10.0.0.5",
+		"Diverges at TTL -/2: - vs 10.0.0.5",
+		"Last common hop: TTL 1 192.168.1.1",
+		"Converges at TTL 2/3: 10.0.0.1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestDiffCommand_JSON(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	prev := writeSavedTrace(t, "old.json", start, "192.168.1.1", "10.0.0.1")
+	curr := writeSavedTrace(t, "new.json", start, "192.168.1.1", "10.0.0.9")
+
+	out, err := runDiffCmd(t, prev, curr, "--json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var res diff.Result
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(res.Changes) != 1 || res.Changes[0].Kind != diff.KindReplaced {
+		t.Errorf("expected one replacement, got %+v", res.Changes)
+	}
+}
+
+func TestDiffCommand_Errors(t *testing.T) {
+	a := writeSavedTrace(t, "a.json", time.Now(), "192.168.1.1")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"one file", []string{a}, "accepts 2 arg(s)"},
+		{"bad threshold", []string{a, a, "--latency-above", "fast"}, "invalid latency threshold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runDiffCmd(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -127,7 +127,7 @@ Examples:
 				fmt.Fprintln(cmd.ErrOrStderr(), "Waiting for results...")
 			}
 
-			result, err := client.WaitForDNSMeasurement(ctx, resp.ID)
+			result, err := client.WaitForMeasurement(ctx, resp.ID)
 			if err != nil {
 				return fmt.Errorf("failed to get results: %w", err)
 			}
@@ -183,13 +183,16 @@ func dnsStatusCodeName(code int) string {
 	}
 }
 
-func displayDNSResult(cmd *cobra.Command, pr *globalping.DNSProbeResult, traceMode bool) {
+func displayDNSResult(cmd *cobra.Command, pr *globalping.ProbeResult, traceMode bool) {
 	w := cmd.OutOrStdout()
 
 	loc := formatPingProbeLocation(&pr.Probe)
 	fmt.Fprintf(w, "\n=== From %s ===\n", loc)
 
-	r := pr.Result
+	r := pr.DNS
+	if r == nil {
+		return
+	}
 
 	// For trace mode, show raw output
 	if traceMode && r.RawOutput != "" {
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Exit codes shared by every command reporting an outcome rather than an
+// error (1). When several conditions hold, the most severe one decides the
+// code, in the order of failPrecedence.
+const (
+	exitLatency     = 2 // Latency above a threshold or baseline envelope
+	exitPath        = 3 // Path changed: route, AS or MPLS alert, baseline path deviation
+	exitUnreachable = 4 // Target not reached (--fail-on unreached)
+	exitLoss        = 5 // Packet loss to the target above a threshold
+)
+
+// failPrecedence orders exit codes from most to least severe.
+var failPrecedence = []int{exitUnreachable, exitPath, exitLoss, exitLatency}
+
+// failCondition is a parsed --fail-on expression.
+type failCondition struct {
+	kind    string        // unreached, loss, latency or alert
+	loss    float64       // loss: percent the target loss must exceed
+	latency time.Duration // latency: RTT the target average must exceed
+}
+
+// parseFailOn parses --fail-on expressions: unreached, alert, loss>N[%]
+// and latency>DURATION.
+func parseFailOn(exprs []string) ([]failCondition, error) {
+	var conds []failCondition
+	for _, expr := range exprs {
+		e := strings.ToLower(strings.ReplaceAll(expr, " ", ""))
+		var c failCondition
+		name, value, hasValue := strings.Cut(e, ">")
+		switch {
+		case e == "unreached" || e == "alert":
+			c.kind = e
+		case hasValue && name == "loss":
+			loss, err := parseLossThreshold(value)
+			if err != nil || loss < 0 || loss >= 100 {
+				return nil, fmt.Errorf("invalid --fail-on %q: loss must be a percentage below 100", expr)
+			}
+			c.kind, c.loss = name, loss
+		case hasValue && name == "latency":
+			latency, err := parseLatencyThreshold(value)
+			if err != nil || latency <= 0 {
+				return nil, fmt.Errorf("invalid --fail-on %q: latency must be a positive duration (e.g. 100ms)", expr)
+			}
+			c.kind, c.latency = name, latency
+		default:
+			return nil, fmt.Errorf("invalid --fail-on %q: must be unreached, alert, loss>N%% or latency>DURATION", expr)
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// failTracker collects trace results and monitor alerts of a run, then
+// decides its exit code from the --fail-on conditions. Its methods do
+// nothing on a nil tracker, so callers need not check whether --fail-on
+// was given.
+type failTracker struct {
+	conditions []failCondition
+	flag       string // Prefix of the error of check
+
+	traces    int
+	unreached int
+	lossSum   float64 // Target loss summed over traces, 100% when unreached
+	rttSum    time.Duration
+	rttCount  int
+	alerts    []monitor.Change
+}
+
+// newFailTracker returns a tracker for conds.
+func newFailTracker(conds []failCondition) *failTracker {
+	return &failTracker{conditions: conds, flag: "fail-on"}
+}
+
+// hasCondition reports whether a condition of kind was given.
+func (f *failTracker) hasCondition(kind string) bool {
+	if f == nil {
+		return false
+	}
+	for _, c := range f.conditions {
+		if c.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// observe records a completed trace.
+func (f *failTracker) observe(tr *hop.TraceResult) {
+	if f == nil || tr == nil {
+		return
+	}
+	f.traces++
+	if !tr.ReachedTarget || len(tr.Hops) == 0 {
+		f.unreached++
+		f.lossSum += 100
+		return
+	}
+	target := tr.Hops[len(tr.Hops)-1]
+	f.lossSum += target.LossPercent()
+	if rtt := target.AvgRTT(); rtt > 0 {
+		f.rttSum += rtt
+		f.rttCount++
+	}
+}
+
+// alert records monitor alerts.
+func (f *failTracker) alert(changes []monitor.Change) {
+	if f == nil {
+		return
+	}
+	f.alerts = append(f.alerts, changes...)
+}
+
+// check evaluates the conditions against what was observed. It returns nil
+// when none holds, or an *exitError listing every condition that does with
+// the exit code of the most severe one.
+func (f *failTracker) check() error {
+	if f == nil {
+		return nil
+	}
+
+	failed := make(map[int][]string)
+	for _, c := range f.conditions {
+		switch c.kind {
+		case "unreached":
+			if f.unreached > 0 {
+				failed[exitUnreachable] = append(failed[exitUnreachable],
+					fmt.Sprintf("target not reached in %d of %d traces", f.unreached, f.traces))
+			}
+		case "loss":
+			if f.traces == 0 {
+				continue
+			}
+			if loss := f.lossSum / float64(f.traces); loss > c.loss {
+				failed[exitLoss] = append(failed[exitLoss],
+					fmt.Sprintf("loss %.1f%% > %g%%", loss, c.loss))
+			}
+		case "latency":
+			if f.rttCount == 0 {
+				continue
+			}
+			if avg := f.rttSum / time.Duration(f.rttCount); avg > c.latency {
+				failed[exitLatency] = append(failed[exitLatency],
+					fmt.Sprintf("latency %s > %s", formatMs(avg), c.latency))
+			}
+		case "alert":
+			for _, a := range f.alerts {
+				code := alertExitCode(a.Type)
+				failed[code] = append(failed[code], "alert: "+a.String())
+			}
+		}
+	}
+
+	var reasons []string
+	code := 0
+	for _, c := range failPrecedence {
+		if len(failed[c]) == 0 {
+			continue
+		}
+		if code == 0 {
+			code = c
+		}
+		reasons = append(reasons, failed[c]...)
+	}
+	if code == 0 {
+		return nil
+	}
+	return &exitError{code: code, err: fmt.Errorf("%s: %s", f.flag, strings.Join(reasons, "; "))}
+}
+
+// alertExitCode returns the exit code of a monitor alert.
+func alertExitCode(t monitor.ChangeType) int {
+	switch t {
+	case monitor.ChangeTypeLatency:
+		return exitLatency
+	case monitor.ChangeTypeLoss:
+		return exitLoss
+	default:
+		return exitPath
+	}
+}
+
+// isExitError reports whether err carries a specific exit code.
+func isExitError(err error) bool {
+	var exit *exitError
+	return errors.As(err, &exit)
+}
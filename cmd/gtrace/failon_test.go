@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// createFailOnTrace builds a two-hop trace; the target answers with rtt
+// unless reached is false, losing lost of its three probes.
+func createFailOnTrace(reached bool, rtt time.Duration, lost int) *hop.TraceResult {
+	tr := hop.NewTraceResult("example.com", "93.184.216.34")
+	h := hop.NewHop(1)
+	h.AddProbe(net.ParseIP("192.168.1.1"), time.Millisecond)
+	tr.AddHop(h)
+
+	target := hop.NewHop(2)
+	for i := 0; i < 3; i++ {
+		if !reached || i < lost {
+			target.AddTimeout()
+		} else {
+			target.AddProbe(net.ParseIP("93.184.216.34"), rtt)
+		}
+	}
+	tr.AddHop(target)
+	tr.ReachedTarget = reached
+	return tr
+}
+
+func TestParseFailOn(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    failCondition
+		wantErr bool
+	}{
+		{"unreached", failCondition{kind: "unreached"}, false},
+		{"alert", failCondition{kind: "alert"}, false},
+		{"loss>5%", failCondition{kind: "loss", loss: 5}, false},
+		{"Loss > 2.5", failCondition{kind: "loss", loss: 2.5}, false},
+		{"latency>150ms", failCondition{kind: "latency", latency: 150 * time.Millisecond}, false},
+		{"loss>100%", failCondition{}, true},
+		{"latency>fast", failCondition{}, true},
+		{"latency<10ms", failCondition{}, true},
+		{"jitter>5ms", failCondition{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			conds, err := parseFailOn([]string{tt.expr})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", conds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if conds[0] != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, conds[0])
+			}
+		})
+	}
+}
+
+func TestFailTracker_NoConditionHolds(t *testing.T) {
+	conds, _ := parseFailOn([]string{"unreached", "loss>50%", "latency>100ms"})
+	f := newFailTracker(conds)
+	f.observe(createFailOnTrace(true, 20*time.Millisecond, 1))
+
+	if err := f.check(); err != nil {
+		t.Errorf("expected no failure, got %v", err)
+	}
+}
+
+func TestFailTracker_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		conds    []string
+		traces   []*hop.TraceResult
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name:     "unreached",
+			conds:    []string{"unreached"},
+			traces:   []*hop.TraceResult{createFailOnTrace(true, 10*time.Millisecond, 0), createFailOnTrace(false, 0, 0)},
+			wantCode: exitUnreachable,
+			wantMsg:  "target not reached in 1 of 2 traces",
+		},
+		{
+			name:     "loss averaged over traces",
+			conds:    []string{"loss>10%"},
+			traces:   []*hop.TraceResult{createFailOnTrace(true, 10*time.Millisecond, 0), createFailOnTrace(true, 10*time.Millisecond, 1)},
+			wantCode: exitLoss,
+			wantMsg:  "loss 16.7% > 10%",
+		},
+		{
+			name:     "latency",
+			conds:    []string{"latency>50ms"},
+			traces:   []*hop.TraceResult{createFailOnTrace(true, 40*time.Millisecond, 0), createFailOnTrace(true, 80*time.Millisecond, 0)},
+			wantCode: exitLatency,
+			wantMsg:  "latency 60.0ms > 50ms",
+		},
+		{
+			name:     "unreached outranks latency",
+			conds:    []string{"latency>50ms", "unreached"},
+			traces:   []*hop.TraceResult{createFailOnTrace(true, 80*time.Millisecond, 0), createFailOnTrace(false, 0, 0)},
+			wantCode: exitUnreachable,
+			wantMsg:  "traces; latency 80.0ms > 50ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, err := parseFailOn(tt.conds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := newFailTracker(conds)
+			for _, tr := range tt.traces {
+				f.observe(tr)
+			}
+
+			var exit *exitError
+			if err := f.check(); !errors.As(err, &exit) {
+				t.Fatalf("expected an exit error, got %v", err)
+			}
+			if exit.code != tt.wantCode {
+				t.Errorf("expected exit code %d, got %d", tt.wantCode, exit.code)
+			}
+			if !strings.Contains(exit.Error(), tt.wantMsg) {
+				t.Errorf("expected %q in %q", tt.wantMsg, exit.Error())
+			}
+		})
+	}
+}
+
+func TestFailTracker_MonitorAlerts(t *testing.T) {
+	conds, _ := parseFailOn([]string{"alert"})
+	f := newFailTracker(conds)
+	f.alert([]monitor.Change{{Type: monitor.ChangeTypeLatency, Hop: 5, Message: "latency up"}})
+	f.alert([]monitor.Change{{Type: monitor.ChangeTypeRoute, Hop: 3, Message: "route changed"}})
+
+	var exit *exitError
+	if err := f.check(); !errors.As(err, &exit) || exit.code != exitPath {
+		t.Fatalf("expected path exit code for a route alert, got %v", err)
+	}
+	if !strings.Contains(exit.Error(), "route changed") || !strings.Contains(exit.Error(), "latency up") {
+		t.Errorf("expected both alerts reported, got %q", exit.Error())
+	}
+}
+
+func TestFailTracker_NilIsNoop(t *testing.T) {
+	var f *failTracker
+	f.observe(createFailOnTrace(false, 0, 0))
+	f.alert([]monitor.Change{{Type: monitor.ChangeTypeRoute}})
+	if err := f.check(); err != nil {
+		t.Errorf("expected nil tracker to never fail, got %v", err)
+	}
+}
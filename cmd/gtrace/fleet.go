@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/enrich"
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// NewFleetCmd creates the fleet subcommand for monitoring many targets at once.
+func NewFleetCmd() *cobra.Command {
+	var (
+		interval     string
+		workers      int
+		protocol     string
+		port         int
+		maxHops      int
+		packets      int
+		timeout      string
+		alerts       []string
+		offline      bool
+		geoProvider  string
+		geoDB        string
+		cacheDir     string
+		bgp          bool
+		lookingGlass string
+		simple       bool
+		themeName    string
+		configFile   string
+		ipv4         bool
+		ipv6         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fleet <targets-file>",
+		Short: "Monitor a list of targets with a live dashboard",
+		Long: `Read targets from a file (one per line, # comments) and trace each of them
+every interval with a pool of workers. The dashboard shows reachability,
+the slowest hop and the most recent route/latency/loss alerts per target.
+
+Requires root privileges (raw sockets), like local traces.
+
+Examples:
+  sudo gtrace fleet targets.txt
+  sudo gtrace fleet targets.txt --interval 1m --workers 8 --alert 'hop(last).loss > 5%'
+  sudo gtrace fleet targets.txt --simple > fleet.log`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !validProtocols[protocol] {
+				return fmt.Errorf("invalid protocol %q: must be icmp, udp, or tcp", protocol)
+			}
+			if ipv4 && ipv6 {
+				return fmt.Errorf("-4/--ipv4 and -6/--ipv6 are mutually exclusive")
+			}
+			if workers < 1 {
+				return fmt.Errorf("--workers must be >= 1")
+			}
+
+			every, err := time.ParseDuration(interval)
+			if err != nil || every <= 0 {
+				return fmt.Errorf("invalid interval %q", interval)
+			}
+			perHop, err := time.ParseDuration(timeout)
+			if err != nil {
+				return fmt.Errorf("invalid timeout: %w", err)
+			}
+			rules, err := parseAlertRules(alerts)
+			if err != nil {
+				return err
+			}
+			theme, err := loadTheme(themeName, configFile)
+			if err != nil {
+				return err
+			}
+
+			targets, err := collectTargets(nil, args[0])
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				return fmt.Errorf("no targets found in %s", args[0])
+			}
+
+			if err := trace.CheckPrivileges(); err != nil {
+				return err
+			}
+
+			family := getAddressFamily(&Config{IPv4Only: ipv4, IPv6Only: ipv6})
+
+			traceCfg := &trace.Config{
+				Protocol:      trace.Protocol(protocol),
+				MaxHops:       maxHops,
+				PacketsPerHop: packets,
+				Timeout:       perHop,
+				Port:          port,
+				ProbeSize:     64,
+			}
+			if err := traceCfg.Validate(); err != nil {
+				return fmt.Errorf("invalid config: %w", err)
+			}
+
+			geo, err := newGeoProvider(geoProvider, geoDB)
+			if err != nil {
+				return err
+			}
+			if offline && (bgp || lookingGlass != "") {
+				return fmt.Errorf("--bgp and --looking-glass cannot be combined with --offline")
+			}
+			bgpLookup, err := newBGPLookup(bgp, lookingGlass)
+			if err != nil {
+				return err
+			}
+			var cache *enrich.DiskCache
+			if !offline {
+				cache = openDiskCache(cmd.ErrOrStderr(), cacheDir)
+				defer saveDiskCache(cmd.ErrOrStderr(), cache)
+			}
+			enricher := newEnricher(offline, geo, cache, bgpLookup, nil, nil)
+
+			// Resolve on every run so DNS changes are picked up
+			traceFn := func(ctx context.Context, target string) (*hop.TraceResult, error) {
+				targetIP, err := trace.ResolveTarget(target, family)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve target: %w", err)
+				}
+				tracer, err := trace.NewLocalTracer(traceCfg)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create tracer: %w", err)
+				}
+				result, err := tracer.Trace(ctx, targetIP, func(h *hop.Hop) {
+					if enricher != nil {
+						enricher.EnrichHop(ctx, h)
+					}
+				})
+				if err != nil {
+					return nil, fmt.Errorf("trace failed: %w", err)
+				}
+				result.TargetIP = targetIP.String()
+				return result, nil
+			}
+
+			fleetCfg := monitor.DefaultFleetConfig()
+			fleetCfg.Interval = every
+			fleetCfg.Workers = workers
+			fleetCfg.Monitor.Rules = rules
+			fleet := monitor.NewFleet(targets, fleetCfg)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigCh
+				cancel()
+			}()
+
+			// Alerts are printed in simple mode only: the dashboard owns the terminal
+			fleet.SetCallback(func(target string, changes []monitor.Change) {
+				for _, c := range changes {
+					if simple {
+						fmt.Fprintf(cmd.OutOrStdout(), "ALERT %s: %s\n", target, c.String())
+					}
+					if c.Rule == nil {
+						continue
+					}
+					if err := c.Rule.Notify(ctx, target, c); err != nil && simple {
+						fmt.Fprintf(cmd.ErrOrStderr(), "Warning: alert notification failed: %v\n", err)
+					}
+				}
+			})
+
+			if simple {
+				return runFleetSimple(ctx, cmd, fleet, traceFn, every)
+			}
+
+			display.ApplyTheme(theme)
+			go fleet.Run(ctx, traceFn)
+			if err := display.RunFleet(fleet.Snapshot, every); err != nil {
+				return fmt.Errorf("TUI error: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&interval, "interval", "30s", "Time between traces of each target")
+	cmd.Flags().IntVar(&workers, "workers", 4, "Maximum concurrent traces")
+	cmd.Flags().StringVar(&protocol, "protocol", "icmp", "Protocol: icmp|udp|tcp")
+	cmd.Flags().IntVar(&port, "port", 33434, "Port for TCP/UDP")
+	cmd.Flags().IntVar(&maxHops, "max-hops", 30, "Maximum hops")
+	cmd.Flags().IntVar(&packets, "packets", 3, "Packets per hop")
+	cmd.Flags().StringVar(&timeout, "timeout", "500ms", "Per-hop timeout")
+	cmd.Flags().StringArrayVar(&alerts, "alert", nil, "Alert rule evaluated for every target, repeatable (see gtrace --help), e.g. 'hop(last).loss > 5% for 3 cycles'")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Use only local enrichment DBs")
+	cmd.Flags().StringVar(&geoProvider, "geo-provider", enrich.ProviderMaxMind, "Offline GeoIP database: maxmind|ipinfo|dbip")
+	cmd.Flags().StringVar(&geoDB, "geo-db", "", "GeoIP database file (default: the provider's file in ~/.gtr/data)")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Enrichment cache directory (default: ~/.gtr/cache)")
+	cmd.Flags().BoolVar(&bgp, "bgp", false, "Look up each hop's BGP prefix, AS path and visibility (RIPEstat)")
+	cmd.Flags().StringVar(&lookingGlass, "looking-glass", "", "RIPEstat-compatible looking glass URL for --bgp")
+	cmd.Flags().BoolVar(&simple, "simple", false, "Print a status table every interval instead of the dashboard")
+	cmd.Flags().StringVar(&themeName, "theme", "", "Color theme: dark|light|high-contrast|colorblind or a theme from the config file")
+	cmd.Flags().StringVar(&configFile, "config", defaultUserConfigPath(), "User configuration file (themes)")
+	cmd.Flags().BoolVarP(&ipv4, "ipv4", "4", false, "Use IPv4 only")
+	cmd.Flags().BoolVarP(&ipv6, "ipv6", "6", false, "Use IPv6 only")
+
+	return cmd
+}
+
+// runFleetSimple prints the fleet table every interval.
+func runFleetSimple(ctx context.Context, cmd *cobra.Command, fleet *monitor.Fleet, traceFn monitor.FleetTraceFunc, every time.Duration) error {
+	w := cmd.OutOrStdout()
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				display.RenderFleet(w, fleet.Snapshot())
+				fmt.Fprintln(w)
+			}
+		}
+	}()
+
+	if err := fleet.Run(ctx, traceFn); err != nil && ctx.Err() == nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nFleet monitoring stopped")
+	return nil
+}
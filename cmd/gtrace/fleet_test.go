@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runFleetCmd(t *testing.T, args ...string) error {
+	t.Helper()
+	cmd := NewFleetCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(args)
+	return cmd.Execute()
+}
+
+func TestFleetCommand_RequiresTargetsFile(t *testing.T) {
+	if err := runFleetCmd(t); err == nil {
+		t.Error("expected error when no targets file provided")
+	}
+}
+
+func TestFleetCommand_RejectsMissingFile(t *testing.T) {
+	err := runFleetCmd(t, filepath.Join(t.TempDir(), "nope.txt"))
+
+	if err == nil || !strings.Contains(err.Error(), "targets file") {
+		t.Errorf("expected targets file error, got: %v", err)
+	}
+}
+
+func TestFleetCommand_RejectsEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("# nothing yet\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runFleetCmd(t, path)
+
+	if err == nil || !strings.Contains(err.Error(), "no targets") {
+		t.Errorf("expected no targets error, got: %v", err)
+	}
+}
+
+func TestFleetCommand_ValidatesFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"protocol", []string{"--protocol", "sctp"}, "invalid protocol"},
+		{"workers", []string{"--workers", "0"}, "--workers"},
+		{"interval", []string{"--interval", "soon"}, "invalid interval"},
+		{"ip family", []string{"-4", "-6"}, "mutually exclusive"},
+		{"alert", []string{"--alert", "hop(last).loss above 5%"}, "invalid alert rule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runFleetCmd(t, append([]string{path}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSetupCmd_RegistersFleet(t *testing.T) {
+	cmd := SetupCmd("dev")
+
+	found, _, err := cmd.Find([]string{"fleet"})
+	if err != nil || found.Name() != "fleet" {
+		t.Errorf("expected fleet subcommand, got %v (%v)", found, err)
+	}
+}
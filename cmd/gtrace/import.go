@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/hervehildenbrand/gtrace/internal/atlas"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/export"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// importFormatRIPEAtlas is the only import format supported so far.
+const importFormatRIPEAtlas = "ripe-atlas"
+
+// NewImportCmd creates the import subcommand.
+func NewImportCmd() *cobra.Command {
+	var (
+		format       string
+		probes       []int
+		compare      bool
+		local        bool
+		enrichHops   bool
+		output       string
+		outputFormat string
+		noColor      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Render, compare and convert traceroute results from other tools",
+		Long: `Read traceroute results produced by another platform and show them like
+gtrace traces, compare them side by side (optionally against a local trace
+to the same target) or re-export them in any gtrace format.
+
+Supported formats:
+  ripe-atlas  RIPE Atlas traceroute results (API or download: JSON array,
+              single result, or one result per line)
+
+Use "-" to read from stdin.`,
+		Example: `  gtrace import --format ripe-atlas results.json
+  gtrace import results.json --probe 6012 --probe 1001 --compare
+  sudo gtrace import results.json --probe 6012 --local
+  gtrace import results.json -o results.csv`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != importFormatRIPEAtlas {
+				return fmt.Errorf("unsupported import format %q (supported: %s)", format, importFormatRIPEAtlas)
+			}
+
+			results, err := readAtlasResults(cmd.InOrStdin(), args[0], probes)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			if local {
+				if err := trace.CheckPrivileges(); err != nil {
+					return err
+				}
+				localResult, err := runLocalTraceLike(ctx, results[0])
+				if err != nil {
+					return err
+				}
+				results = append([]*hop.TraceResult{localResult}, results...)
+				compare = true
+			}
+			if compare && len(results) > maxTargets {
+				return fmt.Errorf("%d traces to compare, at most %d can be shown side by side (select them with --probe)", len(results), maxTargets)
+			}
+
+			if enrichHops {
+				enricher := newEnricher(false, nil, nil, nil, nil, nil)
+				for _, tr := range results {
+					if tr.Source != "Local" {
+						enricher.EnrichTrace(ctx, tr)
+					}
+				}
+			}
+
+			w := cmd.OutOrStdout()
+			if compare {
+				if err := display.NewCompareRenderer(w, noColor).RenderAll(results); err != nil {
+					return err
+				}
+			} else {
+				renderer := display.NewSimpleRenderer()
+				for i, tr := range results {
+					if i > 0 {
+						fmt.Fprintln(w)
+					}
+					if tr.StartTime.IsZero() {
+						fmt.Fprintln(w, tr.Source)
+					} else {
+						fmt.Fprintf(w, "%s, started %s\n", tr.Source, tr.StartTime.UTC().Format("2006-01-02 15:04:05 UTC"))
+					}
+					renderer.RenderTrace(w, tr)
+				}
+			}
+
+			if output != "" {
+				if err := export.ExportAllToFile(output, export.Format(outputFormat), results); err != nil {
+					return fmt.Errorf("failed to export: %w", err)
+				}
+				fmt.Fprintf(w, "Results exported to %s\n", output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", importFormatRIPEAtlas, "Input format: ripe-atlas")
+	cmd.Flags().IntSliceVar(&probes, "probe", nil, "Only import results from these probe IDs (repeatable)")
+	cmd.Flags().BoolVar(&compare, "compare", false, "Show the traces side by side (max 5)")
+	cmd.Flags().BoolVar(&local, "local", false, "Also trace the target locally and compare (requires root)")
+	cmd.Flags().BoolVar(&enrichHops, "enrich", false, "Look up ASN, hostname and location of imported hops")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Export the imported traces to a file (format from extension)")
+	cmd.Flags().StringVar(&outputFormat, "output-format", "", "Explicit export format: json|csv|text|dot|d2|scamper")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colors in the compare view")
+
+	return cmd
+}
+
+// readAtlasResults reads RIPE Atlas traceroute results from the file at
+// path, or from stdin when path is "-", keeping only the given probes.
+func readAtlasResults(stdin io.Reader, path string, probes []int) ([]*hop.TraceResult, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	parsed, err := atlas.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*hop.TraceResult
+	for _, r := range parsed {
+		if len(probes) > 0 && !slices.Contains(probes, r.PrbID) {
+			continue
+		}
+		results = append(results, r.ToTraceResult())
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results from probes %v", probes)
+	}
+	return results, nil
+}
+
+// runLocalTraceLike traces the target of an imported result from this host
+// with the same protocol, so both can be compared.
+func runLocalTraceLike(ctx context.Context, imported *hop.TraceResult) (*hop.TraceResult, error) {
+	targetIP := net.ParseIP(imported.TargetIP)
+	if targetIP == nil {
+		return nil, fmt.Errorf("imported trace has no target address")
+	}
+
+	cfg := defaultConfig()
+	cfg.Target = imported.Target
+	if imported.Protocol != "" {
+		cfg.Protocol = imported.Protocol
+	}
+	if cfg.Protocol == "tcp" {
+		cfg.Port = 80
+	}
+
+	result, err := runLocalTraceToIP(ctx, &cfg, targetIP)
+	if err != nil {
+		return nil, fmt.Errorf("local trace failed: %w", err)
+	}
+	result.Source = "Local"
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const atlasResults = `[
+{"af":4,"dst_addr":"93.184.216.34","dst_name":"example.com","from":"198.51.100.7","msm_id":5051,"prb_id":6012,"proto":"ICMP","timestamp":1700000000,"type":"traceroute",
+ "result":[{"hop":1,"result":[{"from":"192.168.1.1","rtt":1.2,"ttl":64}]},{"hop":2,"result":[{"from":"93.184.216.34","rtt":12.5,"ttl":56}]}]},
+{"af":4,"dst_addr":"93.184.216.34","dst_name":"example.com","from":"203.0.113.9","msm_id":5051,"prb_id":1001,"proto":"ICMP","timestamp":1700000003,"type":"traceroute",
+ "result":[{"hop":1,"result":[{"from":"10.10.0.1","rtt":0.8,"ttl":64}]},{"hop":2,"result":[{"x":"*"}]},{"hop":3,"result":[{"from":"93.184.216.34","rtt":40.1,"ttl":52}]}]}
+]`
+
+func runImportCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetArgs(append([]string{"import"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestImportCommand_RendersAtlasResults(t *testing.T) {
+	out, err := runImportCmd(t, atlasResults, "--format", "ripe-atlas", "-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"Atlas probe 6012 (198.51.100.7), started 2023-11-14 22:13:20 UTC", "Atlas probe 1001", "192.168.1.1", "10.10.0.1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestImportCommand_FiltersProbesAndCompares(t *testing.T) {
+	out, err := runImportCmd(t, atlasResults, "-", "--probe", "1001", "--compare", "--no-color")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "192.168.1.1") || !strings.Contains(out, "10.10.0.1") {
+		t.Errorf("expected only probe 1001 in output:\n%s", out)
+	}
+}
+
+func TestImportCommand_ReexportsResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atlas.csv")
+	if _, err := runImportCmd(t, atlasResults, "-", "-o", path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Atlas probe 1001 (203.0.113.9)") {
+		t.Errorf("expected sources in export:\n%s", data)
+	}
+}
+
+func TestImportCommand_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unknown format", []string{"--format", "warts", "-"}, "unsupported import format"},
+		{"missing file", []string{filepath.Join(t.TempDir(), "nope.json")}, "failed to read results"},
+		{"unknown probe", []string{"-", "--probe", "42"}, "no results from probes [42]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runImportCmd(t, atlasResults, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
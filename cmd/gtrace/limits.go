@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/globalping"
+	"github.com/spf13/cobra"
+)
+
+// NewLimitsCmd creates the limits subcommand for inspecting GlobalPing quotas.
+func NewLimitsCmd() *cobra.Command {
+	var (
+		apiKey     string
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "limits",
+		Short: "Show remaining GlobalPing rate limit and credits",
+		Long: `Show how many GlobalPing measurements you can still create in the
+current rate-limit window, and the credits left on your account when an
+API key is given.
+
+Examples:
+  gtrace limits
+  gtrace limits --api-key $GLOBALPING_TOKEN
+  gtrace limits --json`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			client := globalping.NewClient(apiKey)
+			limits, err := client.GetLimits(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get limits: %w", err)
+			}
+
+			if jsonOutput {
+				data, err := json.MarshalIndent(limits, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal JSON: %w", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
+
+			formatLimits(cmd.OutOrStdout(), limits)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "GlobalPing API key (shows per-user limits and credits)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+
+	return cmd
+}
+
+// formatLimits writes a human-readable summary of GlobalPing limits.
+func formatLimits(w io.Writer, limits *globalping.Limits) {
+	create := limits.RateLimit.Measurements.Create
+
+	scope := "per IP, anonymous"
+	if create.Type == "user" {
+		scope = "per user"
+	}
+
+	fmt.Fprintf(w, "GlobalPing limits (%s)\n", scope)
+	fmt.Fprintf(w, "  Measurements: %d/%d remaining", create.Remaining, create.Limit)
+	if create.Reset > 0 {
+		fmt.Fprintf(w, ", resets in %v", time.Duration(create.Reset)*time.Second)
+	}
+	fmt.Fprintln(w)
+
+	if limits.Credits != nil {
+		fmt.Fprintf(w, "  Credits:      %d remaining\n", limits.Credits.Remaining)
+	}
+}
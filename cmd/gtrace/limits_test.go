@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/internal/globalping"
+)
+
+func TestLimitsCommand_RejectsArguments(t *testing.T) {
+	cmd := NewLimitsCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"extra"})
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for positional argument")
+	}
+}
+
+func TestFormatLimits_Anonymous(t *testing.T) {
+	var limits globalping.Limits
+	limits.RateLimit.Measurements.Create = globalping.CreateLimit{Type: "ip", Limit: 250, Remaining: 240, Reset: 90}
+
+	var buf bytes.Buffer
+	formatLimits(&buf, &limits)
+	out := buf.String()
+
+	if !strings.Contains(out, "anonymous") || !strings.Contains(out, "240/250") || !strings.Contains(out, "1m30s") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if strings.Contains(out, "Credits") {
+		t.Error("anonymous limits should not show credits")
+	}
+}
+
+func TestFormatLimits_WithCredits(t *testing.T) {
+	limits := globalping.Limits{Credits: &globalping.CreditsInfo{Remaining: 1000}}
+	limits.RateLimit.Measurements.Create = globalping.CreateLimit{Type: "user", Limit: 500, Remaining: 500}
+
+	var buf bytes.Buffer
+	formatLimits(&buf, &limits)
+
+	if !strings.Contains(buf.String(), "1000 remaining") {
+		t.Errorf("expected credits in output:\n%s", buf.String())
+	}
+}
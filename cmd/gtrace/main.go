@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -18,16 +19,42 @@ func SetupCmd(version string) *cobra.Command {
 	cmd.AddCommand(NewInfoCmd())
 	cmd.AddCommand(NewMCPCmd())
 	cmd.AddCommand(NewProbesCmd())
+	cmd.AddCommand(NewLimitsCmd())
 	cmd.AddCommand(NewPingCmd())
 	cmd.AddCommand(NewDNSCmd())
+	cmd.AddCommand(NewFleetCmd())
+	cmd.AddCommand(NewRunCmd())
+	cmd.AddCommand(NewCacheCmd())
+	cmd.AddCommand(NewImportCmd())
+	cmd.AddCommand(NewCompareCmd())
+	cmd.AddCommand(NewDiffCmd())
+	cmd.AddCommand(NewBaselineCmd())
+	cmd.AddCommand(NewServeCmd())
+	cmd.AddCommand(NewReplayCmd())
+	cmd.AddCommand(NewAgentCmd(version))
+	cmd.AddCommand(NewCollectorCmd())
 	return cmd
 }
 
+// exitError is returned by commands whose outcome is reported through a
+// specific exit code rather than 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
 func main() {
 	cmd := SetupCmd(Version)
 
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		os.Exit(1)
 	}
 }
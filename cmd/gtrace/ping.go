@@ -112,7 +112,7 @@ Examples:
 				fmt.Fprintln(cmd.ErrOrStderr(), "Waiting for results...")
 			}
 
-			result, err := client.WaitForPingMeasurement(ctx, resp.ID)
+			result, err := client.WaitForMeasurement(ctx, resp.ID)
 			if err != nil {
 				return fmt.Errorf("failed to get results: %w", err)
 			}
@@ -146,7 +146,7 @@ Examples:
 	return cmd
 }
 
-func displayPingResult(cmd *cobra.Command, pr *globalping.PingProbeResult, target string) {
+func displayPingResult(cmd *cobra.Command, pr *globalping.ProbeResult, target string) {
 	w := cmd.OutOrStdout()
 
 	// Probe location header
@@ -154,7 +154,10 @@ func displayPingResult(cmd *cobra.Command, pr *globalping.PingProbeResult, targe
 	fmt.Fprintf(w, "\n=== From %s ===\n", loc)
 
 	// Target info
-	r := pr.Result
+	r := pr.Ping
+	if r == nil {
+		return
+	}
 	if r.ResolvedHostname != "" && r.ResolvedHostname != r.ResolvedAddress {
 		fmt.Fprintf(w, "Target: %s (%s)\n", r.ResolvedAddress, r.ResolvedHostname)
 	} else if r.ResolvedAddress != "" {
@@ -14,6 +14,7 @@ import (
 // NewProbesCmd creates the probes subcommand for discovering GlobalPing probes.
 func NewProbesCmd() *cobra.Command {
 	var (
+		from       string
 		country    string
 		city       string
 		asn        int
@@ -28,11 +29,15 @@ func NewProbesCmd() *cobra.Command {
 		Short: "Discover available GlobalPing probe locations",
 		Long: `List available GlobalPing probes worldwide with optional filtering.
 
-Filter by country (ISO code), city, ASN, network name, or tag.
+Filter by country (ISO code), city, ASN, network name, or tag. Use --from
+with the same location syntax as tracing to preview which probes a
+measurement could select before spending credits.
 Provide a positional keyword to search across all fields.
 
 Examples:
   gtrace probes                    # List probes (default limit 50)
+  gtrace probes --from Germany     # Probes matching a --from location
+  gtrace probes --from "AWS+eu-central-1;AS13335"
   gtrace probes --country JP       # Filter by country
   gtrace probes --asn 13335        # Filter by ASN (Cloudflare)
   gtrace probes --city London      # Filter by city
@@ -54,6 +59,9 @@ Examples:
 				Network: network,
 				Tag:     tag,
 			}
+			if from != "" {
+				filter.From = globalping.ParseLocationStrings(from)
+			}
 
 			// If a positional keyword is given, use it as a broad search
 			keyword := ""
@@ -120,6 +128,7 @@ Examples:
 		},
 	}
 
+	cmd.Flags().StringVar(&from, "from", "", "Filter by GlobalPing location (same syntax as trace --from)")
 	cmd.Flags().StringVar(&country, "country", "", "Filter by country code (e.g., JP, US, DE)")
 	cmd.Flags().StringVar(&city, "city", "", "Filter by city name (substring match)")
 	cmd.Flags().IntVar(&asn, "asn", 0, "Filter by ASN number")
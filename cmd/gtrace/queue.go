@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+// queuePolicingLoss is how many percentage points more large probes than
+// small ones a hop must lose before the report suspects policing.
+const queuePolicingLoss = 20.0
+
+// runQueueMode traces the target with small and large probes in alternating
+// rounds and reports, hop by hop, how much longer the large probes take. The
+// hop where that inflation jumps is where large packets start to queue
+// (bufferbloat) or get policed.
+func runQueueMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	w := cmd.OutOrStdout()
+
+	targetIP, err := trace.ResolveTarget(cfg.Target, getAddressFamily(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	prober, err := trace.NewQueueProber(&trace.Config{
+		Protocol:      trace.Protocol(cfg.Protocol),
+		MaxHops:       cfg.MaxHops,
+		PacketsPerHop: cfg.Packets,
+		Timeout:       timeout,
+		Port:          cfg.Port,
+		ProbeSize:     cfg.ProbeSize,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
+		Parallel:      !cfg.Sequential,
+	}, cfg.QueueSize)
+	if err != nil {
+		return fmt.Errorf("failed to create tracer: %w", err)
+	}
+
+	fmt.Fprintf(w, "Queueing test to %s (%s): %d rounds of %d and %d byte %s probes\n",
+		cfg.Target, targetIP, cfg.QueueRounds, cfg.ProbeSize, cfg.QueueSize, cfg.Protocol)
+
+	stderr := cmd.ErrOrStderr()
+	hops, err := prober.Run(ctx, targetIP, cfg.QueueRounds, func(round int) {
+		fmt.Fprintf(stderr, "\rRound %d/%d", round, cfg.QueueRounds)
+	})
+	fmt.Fprintln(stderr)
+	if err != nil {
+		return fmt.Errorf("trace failed: %w", err)
+	}
+
+	labels := make(map[int]string)
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
+	for _, q := range hops {
+		if q.IP == nil {
+			continue
+		}
+		labels[q.TTL] = q.IP.String()
+		if enricher == nil {
+			continue
+		}
+		if e, err := enricher.EnrichIP(ctx, q.IP); err == nil && e != nil && e.ASN > 0 {
+			labels[q.TTL] += fmt.Sprintf(" [AS%d]", e.ASN)
+		}
+	}
+
+	fmt.Fprint(w, formatQueueReport(hops, labels))
+	return nil
+}
+
+// formatQueueReport formats the per-hop queueing table followed by the
+// diagnosis. labels holds the display address of each answering TTL.
+func formatQueueReport(hops []*trace.HopQueueing, labels map[int]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%3s  %-32s %8s %8s %10s %9s  %s\n", "Hop", "Address", "Small", "Large", "Inflation", "Step", "Loss S/L")
+	for _, q := range hops {
+		if q.IP == nil {
+			fmt.Fprintf(&b, "%3d  *\n", q.TTL)
+			continue
+		}
+		small, large, inflation, step := "-", "-", "-", "-"
+		if len(q.Small) > 0 {
+			small = formatMs(q.SmallMedian())
+		}
+		if len(q.Large) > 0 {
+			large = formatMs(q.LargeMedian())
+		}
+		if q.Answered() {
+			inflation = formatMsDelta(q.Inflation())
+			step = formatMsDelta(q.Step)
+		}
+		fmt.Fprintf(&b, "%3d  %-32s %8s %8s %10s %9s  %.0f%%/%.0f%%\n",
+			q.TTL, labels[q.TTL], small, large, inflation, step, q.SmallLoss(), q.LargeLoss())
+	}
+
+	b.WriteString("\n")
+	if worst := trace.QueueBottleneck(hops); worst != nil {
+		fmt.Fprintf(&b, "Largest inflation step: %s at hop %d (%s), large packets start queueing on the link into this hop (bufferbloat or shaping)\n",
+			formatMsDelta(worst.Step), worst.TTL, worst.IP)
+	} else {
+		b.WriteString("No hop adds 1ms or more of inflation: no queueing detected along the path\n")
+	}
+	for _, q := range hops {
+		if q.IP != nil && q.LargeLoss()-q.SmallLoss() >= queuePolicingLoss {
+			fmt.Fprintf(&b, "Large probes lost from hop %d (%s): %.0f%% vs %.0f%% of small probes, policing or a size limit on the link into this hop\n",
+				q.TTL, q.IP, q.LargeLoss(), q.SmallLoss())
+			break
+		}
+	}
+	return b.String()
+}
+
+// formatMsDelta formats a duration difference in milliseconds with its sign.
+func formatMsDelta(d time.Duration) string {
+	return fmt.Sprintf("%+.1fms", float64(d)/float64(time.Millisecond))
+}
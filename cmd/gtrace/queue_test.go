@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+)
+
+func TestFormatQueueReport(t *testing.T) {
+	ms := time.Millisecond
+	hops := trace.SummarizeQueueing(map[int]*trace.HopQueueing{
+		1: {TTL: 1, IP: net.IPv4(192, 168, 1, 1), Small: []time.Duration{ms}, Large: []time.Duration{ms + 200*time.Microsecond}, SmallSent: 1, LargeSent: 1},
+		2: {TTL: 2, SmallSent: 1, LargeSent: 1},
+		3: {TTL: 3, IP: net.IPv4(10, 0, 0, 3), Small: []time.Duration{10 * ms}, Large: []time.Duration{40 * ms}, SmallSent: 1, LargeSent: 2},
+	})
+	out := formatQueueReport(hops, map[int]string{1: "192.168.1.1", 3: "10.0.0.3 [AS64500]"})
+
+	for _, want := range []string{
+		"  2  *\n",
+		"10.0.0.3 [AS64500]",
+		"+30.0ms",
+		"Largest inflation step: +29.8ms at hop 3 (10.0.0.3)",
+		"Large probes lost from hop 3 (10.0.0.3): 50% vs 0%",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatQueueReport_NoQueueing(t *testing.T) {
+	hops := trace.SummarizeQueueing(map[int]*trace.HopQueueing{
+		1: {TTL: 1, IP: net.IPv4(192, 168, 1, 1), Small: []time.Duration{time.Millisecond}, Large: []time.Duration{time.Millisecond}, SmallSent: 1, LargeSent: 1},
+	})
+	out := formatQueueReport(hops, map[int]string{1: "192.168.1.1"})
+	if !strings.Contains(out, "no queueing detected") {
+		t.Errorf("report should find no queueing:\n%s", out)
+	}
+	if strings.Contains(out, "policing") {
+		t.Errorf("report should not suspect policing:\n%s", out)
+	}
+}
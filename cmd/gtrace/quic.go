@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+// runQUICCompareMode traces the target with QUIC and plain UDP probes in
+// alternating rounds and reports, hop by hop, which of them got answered.
+// The hop where plain UDP is still answered but QUIC no longer is sits
+// behind a middlebox that drops HTTP/3.
+func runQUICCompareMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	w := cmd.OutOrStdout()
+
+	targetIP, err := trace.ResolveTarget(cfg.Target, getAddressFamily(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	comparer, err := trace.NewQUICComparer(&trace.Config{
+		Protocol:      trace.ProtocolQUIC,
+		MaxHops:       cfg.MaxHops,
+		PacketsPerHop: cfg.Packets,
+		Timeout:       timeout,
+		Port:          cfg.Port,
+		ProbeSize:     cfg.ProbeSize,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		ServerName:    cfg.Target,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tracer: %w", err)
+	}
+
+	fmt.Fprintf(w, "QUIC comparison to %s (%s): %d rounds of QUIC Initial and plain UDP probes to port %d\n",
+		cfg.Target, targetIP, cfg.QUICRounds, cfg.Port)
+
+	stderr := cmd.ErrOrStderr()
+	hops, err := comparer.Run(ctx, targetIP, cfg.QUICRounds, func(round int) {
+		fmt.Fprintf(stderr, "\rRound %d/%d", round, cfg.QUICRounds)
+	})
+	fmt.Fprintln(stderr)
+	if err != nil {
+		return fmt.Errorf("trace failed: %w", err)
+	}
+
+	labels := make(map[int]string)
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
+	for _, q := range hops {
+		if q.IP == nil {
+			continue
+		}
+		labels[q.TTL] = q.IP.String()
+		if enricher == nil {
+			continue
+		}
+		if e, err := enricher.EnrichIP(ctx, q.IP); err == nil && e != nil && e.ASN > 0 {
+			labels[q.TTL] += fmt.Sprintf(" [AS%d]", e.ASN)
+		}
+	}
+
+	fmt.Fprint(w, formatQUICReport(hops, labels, targetIP.String()))
+	return nil
+}
+
+// formatQUICReport formats the per-hop answer table followed by the
+// diagnosis. labels holds the display address of each answering TTL.
+func formatQUICReport(hops []*trace.HopQUIC, labels map[int]string, target string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%3s  %-32s %6s %6s  %s\n", "Hop", "Address", "QUIC", "UDP", "Loss Q/U")
+	quicReached := false
+	for _, q := range hops {
+		if q.IP == nil {
+			fmt.Fprintf(&b, "%3d  *\n", q.TTL)
+			continue
+		}
+		quic := fmt.Sprintf("%d/%d", q.QUICAnswered, q.QUICSent)
+		udp := fmt.Sprintf("%d/%d", q.UDPAnswered, q.UDPSent)
+		fmt.Fprintf(&b, "%3d  %-32s %6s %6s  %.0f%%/%.0f%%\n",
+			q.TTL, labels[q.TTL], quic, udp, q.QUICLoss(), q.UDPLoss())
+		if q.QUICAnswered > 0 && q.IP.String() == target {
+			quicReached = true
+		}
+	}
+
+	b.WriteString("\n")
+	switch drop := trace.QUICDropHop(hops); {
+	case drop != nil:
+		fmt.Fprintf(&b, "QUIC dropped from hop %d (%s): plain UDP is still answered there but QUIC is not, a middlebox on the link into this hop filters QUIC\n",
+			drop.TTL, drop.IP)
+	case quicReached:
+		b.WriteString("QUIC reaches the target: no hop treats QUIC differently from plain UDP\n")
+	default:
+		b.WriteString("QUIC gets as far as plain UDP: no QUIC-specific filtering detected along the path\n")
+	}
+	return b.String()
+}
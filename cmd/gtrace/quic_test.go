@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+)
+
+func TestFormatQUICReport_Dropped(t *testing.T) {
+	hops := []*trace.HopQUIC{
+		{TTL: 1, IP: net.IPv4(192, 168, 1, 1), QUICSent: 3, QUICAnswered: 3, UDPSent: 3, UDPAnswered: 3},
+		{TTL: 2, QUICSent: 3, UDPSent: 3},
+		{TTL: 3, IP: net.IPv4(10, 0, 0, 3), QUICSent: 3, UDPSent: 3, UDPAnswered: 2},
+	}
+	out := formatQUICReport(hops, map[int]string{1: "192.168.1.1", 3: "10.0.0.3 [AS64500]"}, "192.0.2.1")
+
+	for _, want := range []string{
+		"  2  *\n",
+		"10.0.0.3 [AS64500]",
+		"100%/33%",
+		"QUIC dropped from hop 3 (10.0.0.3)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatQUICReport_Reached(t *testing.T) {
+	hops := []*trace.HopQUIC{
+		{TTL: 1, IP: net.IPv4(192, 168, 1, 1), QUICSent: 1, QUICAnswered: 1, UDPSent: 1, UDPAnswered: 1},
+		{TTL: 2, IP: net.IPv4(192, 0, 2, 1), QUICSent: 1, QUICAnswered: 1, UDPSent: 1},
+	}
+	out := formatQUICReport(hops, map[int]string{1: "192.168.1.1", 2: "192.0.2.1"}, "192.0.2.1")
+	if !strings.Contains(out, "QUIC reaches the target") {
+		t.Errorf("report should find QUIC reaching the target:\n%s", out)
+	}
+}
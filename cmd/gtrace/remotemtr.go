@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/globalping"
+	"github.com/hervehildenbrand/gtrace/internal/logging"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// remoteMTRMinInterval is the shortest pause between live remote MTR
+// measurements, which keeps a long session within the GlobalPing quota.
+const remoteMTRMinInterval = 10 * time.Second
+
+// liveRemoteMTR reports whether a --from trace runs as a live MTR in the
+// TUI: an interactive session measuring from a single probe.
+func liveRemoteMTR(cfg *Config, interactive bool) bool {
+	if !interactive || cfg.Simple || cfg.Output != "" || cfg.failOn != nil {
+		return false
+	}
+	locations := fromLocations(cfg)
+	return len(locations) == 1 && locations[0].Limit <= 1
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// runGlobalPingLiveMTR repeats GlobalPing MTR measurements from one probe and
+// streams each into the MTR TUI as a cycle. The first measurement picks the
+// probe; later ones reuse it, so every cycle measures the same path.
+func runGlobalPingLiveMTR(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.TraceResult, error) {
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval: %w", err)
+	}
+	interval = max(interval, remoteMTRMinInterval)
+
+	log := logging.FromContext(ctx)
+	client := newGlobalPingClient(log, cfg.APIKey)
+	req := &globalping.MeasurementRequest{
+		Type:      globalping.MeasurementTypeMTR,
+		Target:    cfg.Target,
+		Locations: fromLocations(cfg),
+		Options: globalping.MeasurementOptions{
+			Protocol:  strings.ToUpper(cfg.Protocol),
+			IPVersion: getIPVersion(cfg),
+		},
+	}
+
+	// The first measurement runs before the TUI starts, so an unknown
+	// location or exhausted quota is reported as a plain error
+	fmt.Fprintf(cmd.OutOrStdout(), "MTR to %s from %s via GlobalPing\n", cfg.Target, cfg.From)
+	fmt.Fprintln(cmd.OutOrStdout(), "Waiting for the first measurement...")
+	first, err := measureRemoteMTR(ctx, client, req)
+	if err != nil {
+		return nil, err
+	}
+	req.ProbesFrom = first.id
+	source := first.result.ToTraceResult(cfg.Target).Source
+
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
+
+	resultChan := make(chan display.ProbeResultMsg, 100)
+	cycleChan := make(chan display.CycleCompleteMsg, 10)
+	doneChan := make(chan struct{})
+	resetChan := make(chan struct{}, 1)
+	var paused atomic.Bool
+
+	go func() {
+		defer close(resultChan)
+		defer close(cycleChan)
+
+		enriched := make(map[string]bool)
+		mtr := first.result.MTR
+		for cycle := 1; ; cycle++ {
+			select {
+			case <-resetChan:
+				enriched = make(map[string]bool)
+			default:
+			}
+
+			msgs, reached := remoteMTRProbes(mtr)
+			for _, msg := range msgs {
+				// Enrich the first occurrence of each IP, keeping the probe's own data
+				if msg.IP != nil && enricher != nil && !enriched[msg.IP.String()] {
+					enriched[msg.IP.String()] = true
+					h := hop.NewHop(msg.TTL)
+					h.AddProbe(msg.IP, msg.RTT)
+					enricher.EnrichHop(ctx, h)
+					if h.Enrichment.ASN == 0 {
+						h.Enrichment.ASN = msg.Enrichment.ASN
+					}
+					if h.Enrichment.Hostname == "" {
+						h.Enrichment.Hostname = msg.Enrichment.Hostname
+					}
+					msg.Enrichment = h.Enrichment
+				}
+				select {
+				case resultChan <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case cycleChan <- display.CycleCompleteMsg{Cycle: cycle, Reached: reached}:
+			case <-ctx.Done():
+				return
+			}
+
+			if cfg.Cycles > 0 && cycle >= cfg.Cycles+cfg.Warmup {
+				return
+			}
+
+			// Failed measurements (rate limits, an offline probe) are retried
+			// at the next interval rather than recorded as loss. No
+			// measurement is requested while the display is paused.
+			for mtr = nil; mtr == nil; {
+				select {
+				case <-time.After(interval):
+				case <-ctx.Done():
+					return
+				}
+				if paused.Load() {
+					continue
+				}
+				next, err := measureRemoteMTR(ctx, client, req)
+				if err != nil {
+					log.Debug("remote MTR measurement failed", "err", err)
+					continue
+				}
+				mtr = next.result.MTR
+			}
+		}
+	}()
+
+	// Run MTR TUI (blocks until user quits)
+	target := fmt.Sprintf("%s from %s", cfg.Target, source)
+	if err := display.RunMTR(cmd.OutOrStdout(), target, first.result.MTR.ResolvedAddress, cfg.History, cfg.Warmup, resultChan, cycleChan, nil, doneChan, resetChan, display.MTRControls{Pause: paused.Store}); err != nil {
+		return nil, fmt.Errorf("TUI error: %w", err)
+	}
+	return nil, nil
+}
+
+// remoteMeasurement is a finished single-probe MTR measurement.
+type remoteMeasurement struct {
+	id     string
+	result globalping.ProbeResult
+}
+
+// measureRemoteMTR creates the MTR measurement req and waits for its result.
+func measureRemoteMTR(ctx context.Context, client *globalping.Client, req *globalping.MeasurementRequest) (*remoteMeasurement, error) {
+	resp, err := client.CreateMeasurement(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create measurement: %w", err)
+	}
+	measurement, err := client.WaitForMeasurement(ctx, resp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get results: %w", err)
+	}
+	if len(measurement.Results) == 0 || measurement.Results[0].MTR == nil {
+		return nil, fmt.Errorf("no probe results")
+	}
+	return &remoteMeasurement{id: resp.ID, result: measurement.Results[0]}, nil
+}
+
+// remoteMTRProbes converts one GlobalPing MTR result into per-packet probe
+// messages for the TUI, and reports whether the target answered. Packets a
+// hop did not answer become timeouts, so loss accumulates across cycles.
+func remoteMTRProbes(mtr *globalping.MTRResult) ([]display.ProbeResultMsg, bool) {
+	var msgs []display.ProbeResultMsg
+	reached := false
+	for i, mh := range mtr.Hops {
+		ttl := i + 1
+		ip := net.ParseIP(mh.ResolvedAddress)
+		if ip == nil {
+			for range max(mh.Stats.Total, 1) {
+				msgs = append(msgs, display.ProbeResultMsg{TTL: ttl, Timeout: true, OriginalTTL: -1})
+			}
+			continue
+		}
+		if mh.ResolvedAddress == mtr.ResolvedAddress {
+			reached = true
+		}
+
+		var enrichment hop.Enrichment
+		if len(mh.ASN) > 0 {
+			enrichment.ASN = mh.ASN[0]
+		}
+		enrichment.Hostname = mh.ResolvedHostname
+
+		rtts := make([]float64, 0, len(mh.Timings))
+		for _, t := range mh.Timings {
+			rtts = append(rtts, t.RTT)
+		}
+		if len(rtts) == 0 && mh.Stats.Avg > 0 {
+			rtts = append(rtts, mh.Stats.Avg)
+		}
+		for _, rtt := range rtts {
+			msgs = append(msgs, display.ProbeResultMsg{
+				TTL:         ttl,
+				IP:          ip,
+				RTT:         time.Duration(rtt * float64(time.Millisecond)),
+				Enrichment:  enrichment,
+				OriginalTTL: -1,
+			})
+		}
+		for range max(mh.Stats.Total-len(rtts), 0) {
+			msgs = append(msgs, display.ProbeResultMsg{TTL: ttl, Timeout: true, OriginalTTL: -1})
+		}
+	}
+	return msgs, reached
+}
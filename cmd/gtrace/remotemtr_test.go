@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/globalping"
+)
+
+func TestRemoteMTRProbes(t *testing.T) {
+	mtr := &globalping.MTRResult{
+		ResolvedAddress: "8.8.8.8",
+		Hops: []globalping.MTRHop{
+			{
+				ResolvedAddress:  "10.0.0.1",
+				ResolvedHostname: "gw.example.net",
+				Stats:            globalping.MTRStats{Total: 3, Rcv: 2},
+				Timings:          []globalping.HopTiming{{RTT: 1.5}, {RTT: 2.5}},
+			},
+			{Stats: globalping.MTRStats{Total: 3}}, // No reply
+			{
+				ResolvedAddress: "8.8.8.8",
+				ASN:             []uint32{15169},
+				Stats:           globalping.MTRStats{Total: 3, Rcv: 3},
+				Timings:         []globalping.HopTiming{{RTT: 9}, {RTT: 10}, {RTT: 11}},
+			},
+		},
+	}
+
+	msgs, reached := remoteMTRProbes(mtr)
+
+	if !reached {
+		t.Error("expected target reached")
+	}
+	if len(msgs) != 9 {
+		t.Fatalf("expected 9 probe messages (3 per hop), got %d", len(msgs))
+	}
+
+	var perTTL [4]struct{ answered, lost int }
+	for _, m := range msgs {
+		if m.Timeout {
+			perTTL[m.TTL].lost++
+		} else {
+			perTTL[m.TTL].answered++
+		}
+	}
+	if perTTL[1].answered != 2 || perTTL[1].lost != 1 {
+		t.Errorf("hop 1: %+v, want 2 answered and 1 lost", perTTL[1])
+	}
+	if perTTL[2].lost != 3 {
+		t.Errorf("hop 2: %+v, want 3 lost", perTTL[2])
+	}
+	if perTTL[3].answered != 3 {
+		t.Errorf("hop 3: %+v, want 3 answered", perTTL[3])
+	}
+
+	if msgs[0].RTT != 1500*time.Microsecond || msgs[0].Enrichment.Hostname != "gw.example.net" {
+		t.Errorf("unexpected first probe: %+v", msgs[0])
+	}
+	if last := msgs[len(msgs)-1]; last.Enrichment.ASN != 15169 || last.IP.String() != "8.8.8.8" {
+		t.Errorf("unexpected target probe: %+v", last)
+	}
+}
+
+func TestRemoteMTRProbes_NotReached(t *testing.T) {
+	mtr := &globalping.MTRResult{
+		ResolvedAddress: "8.8.8.8",
+		Hops:            []globalping.MTRHop{{ResolvedAddress: "10.0.0.1", Stats: globalping.MTRStats{Avg: 4, Total: 1, Rcv: 1}}},
+	}
+
+	msgs, reached := remoteMTRProbes(mtr)
+
+	if reached {
+		t.Error("expected target not reached")
+	}
+	if len(msgs) != 1 || msgs[0].RTT != 4*time.Millisecond {
+		t.Errorf("expected one probe at the average RTT, got %+v", msgs)
+	}
+}
+
+func TestLiveRemoteMTR_RequiresTerminalAndSingleProbe(t *testing.T) {
+	if !liveRemoteMTR(&Config{From: "London"}, true) {
+		t.Error("expected live MTR from a single location")
+	}
+	if !liveRemoteMTR(&Config{From: "London", Limit: 1}, true) {
+		t.Error("expected live MTR with --limit 1")
+	}
+
+	// Output that is not a terminal keeps the one-shot table
+	if liveRemoteMTR(&Config{From: "London"}, false) {
+		t.Error("expected no live MTR when output is not a terminal")
+	}
+	for _, cfg := range []*Config{
+		{From: "London", Simple: true},
+		{From: "London", Output: "out.json"},
+		{From: "London,Tokyo"},
+		{From: "London", Limit: 3},
+		{From: "country:US@2"},
+	} {
+		if liveRemoteMTR(cfg, true) {
+			t.Errorf("expected no live MTR for %+v", cfg)
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	if isTerminal(new(bytes.Buffer)) {
+		t.Error("a buffer is not a terminal")
+	}
+}
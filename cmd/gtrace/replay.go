@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// NewReplayCmd creates the replay subcommand.
+func NewReplayCmd() *cobra.Command {
+	var (
+		speed  float64
+		report bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay <session.gtr>",
+		Short: "Replay a session recorded with --record through the MTR TUI",
+		Long: `Replay an MTR session recorded with gtrace --record, probe by probe with its
+original timing, so that a capture made on another machine can be explored
+interactively: select hops, open the RTT chart or the heatmap, export.
+
+--speed accelerates the replay (10 = ten times faster, 0 = show the final
+state at once). 'p' pauses and resumes the replay.
+
+--report prints the final statistics without the TUI.`,
+		Example: `  gtrace google.com --record session.gtr
+  gtrace replay session.gtr
+  gtrace replay session.gtr --speed 20
+  gtrace replay session.gtr --report`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if speed < 0 {
+				return fmt.Errorf("--speed must be >= 0")
+			}
+
+			s, err := session.Open(args[0])
+			if err != nil {
+				return err
+			}
+
+			if report {
+				writeReplayReport(cmd.OutOrStdout(), s)
+				return nil
+			}
+			return runReplay(cmd.Context(), cmd.OutOrStdout(), s, speed)
+		},
+	}
+
+	cmd.Flags().Float64Var(&speed, "speed", 1, "Replay speed factor (1 = real time, 10 = ten times faster, 0 = no delays)")
+	cmd.Flags().BoolVar(&report, "report", false, "Print the final statistics of the session without the TUI")
+
+	return cmd
+}
+
+// runReplay plays s through the MTR TUI.
+func runReplay(ctx context.Context, w io.Writer, s *session.Session, speed float64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	player := session.NewPlayer(s, speed)
+	feed := make(chan tea.Msg, 100)
+	go func() {
+		defer close(feed)
+		feed <- display.NoticeMsg(replayNotice(s, speed))
+		if err := player.Play(ctx, feed); err != nil {
+			return
+		}
+		select {
+		case feed <- display.NoticeMsg(fmt.Sprintf("Replay finished (%d cycles)", s.Cycles())):
+		case <-ctx.Done():
+		}
+	}()
+
+	h := s.Header
+	return display.ReplayMTR(w, h.Target, h.TargetIP, replayHistory(h), h.Warmup, feed, display.MTRControls{
+		Pause: player.SetPaused,
+	})
+}
+
+// replayHistory returns the RTT history size the session was recorded with.
+func replayHistory(h session.Header) int {
+	if h.History > 0 {
+		return h.History
+	}
+	return display.RTTHistorySize
+}
+
+// replayNotice describes the replayed session in the status bar.
+func replayNotice(s *session.Session, speed float64) string {
+	notice := "Replay of " + s.Header.Started.Format("2006-01-02 15:04:05")
+	if s.Header.Host != "" {
+		notice += " on " + s.Header.Host
+	}
+	switch {
+	case speed == 0:
+		notice += " (no delays)"
+	case speed != 1:
+		notice += fmt.Sprintf(" (x%g)", speed)
+	}
+	return notice
+}
+
+// writeReplayReport prints the statistics of s at the end of the session.
+func writeReplayReport(w io.Writer, s *session.Session) {
+	h := s.Header
+	fmt.Fprintf(w, "Session recorded %s", h.Started.Format("2006-01-02 15:04:05 MST"))
+	if h.Host != "" {
+		fmt.Fprintf(w, " on %s", h.Host)
+	}
+	fmt.Fprintf(w, ": %d cycles over %v\n", s.Cycles(), s.Duration().Round(time.Second))
+
+	model := display.NewMTRModel(h.Target, h.TargetIP)
+	model.SetHistorySize(replayHistory(h))
+	model.SetWarmup(h.Warmup)
+	for i := range s.Events {
+		model.Update(s.Events[i].Msg())
+	}
+	if model.HopCount() == 0 {
+		fmt.Fprintln(w, "No probes recorded")
+		return
+	}
+	fmt.Fprint(w, model.PlainText())
+}
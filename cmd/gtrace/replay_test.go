@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/session"
+)
+
+// writeSession records two cycles to example.com in a session file.
+func writeSession(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.gtr")
+	rec, err := session.Create(path, session.Header{
+		Target:   "example.com",
+		TargetIP: "93.184.216.34",
+		Host:     "laptop",
+		Started:  time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for cycle := 1; cycle <= 2; cycle++ {
+		rec.Probe(display.ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 2 * time.Millisecond, OriginalTTL: -1})
+		rec.Probe(display.ProbeResultMsg{TTL: 2, IP: net.ParseIP("93.184.216.34"), RTT: 20 * time.Millisecond, OriginalTTL: -1})
+		rec.Cycle(display.CycleCompleteMsg{Cycle: cycle, Reached: true})
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return path
+}
+
+func runReplayCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(append([]string{"replay"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestReplayCommand_Report(t *testing.T) {
+	out, err := runReplayCmd(t, writeSession(t), "--report")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"Session recorded 2024-03-01 09:30:00 UTC on laptop: 2 cycles",
+		"192.168.1.1",
+		"93.184.216.34",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestReplayCommand_Errors(t *testing.T) {
+	if _, err := runReplayCmd(t, writeSession(t), "--speed", "-1"); err == nil || !strings.Contains(err.Error(), "--speed must be >= 0") {
+		t.Errorf("expected speed error, got %v", err)
+	}
+	if _, err := runReplayCmd(t, filepath.Join(t.TempDir(), "missing.gtr")); err == nil || !strings.Contains(err.Error(), "failed to open session") {
+		t.Errorf("expected open error, got %v", err)
+	}
+}
+
+func TestReplayNotice(t *testing.T) {
+	s := &session.Session{Header: session.Header{Host: "laptop", Started: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)}}
+	if got := replayNotice(s, 1); got != "Replay of 2024-03-01 09:30:00 on laptop" {
+		t.Errorf("unexpected notice: %q", got)
+	}
+	if got := replayNotice(s, 10); !strings.HasSuffix(got, "(x10)") {
+		t.Errorf("unexpected notice at 10x: %q", got)
+	}
+	if got := replayNotice(s, 0); !strings.HasSuffix(got, "(no delays)") {
+		t.Errorf("unexpected notice without delays: %q", got)
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
@@ -13,60 +16,158 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/agent"
 	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/dnsdiag"
 	"github.com/hervehildenbrand/gtrace/internal/enrich"
 	"github.com/hervehildenbrand/gtrace/internal/export"
+	"github.com/hervehildenbrand/gtrace/internal/geocheck"
 	"github.com/hervehildenbrand/gtrace/internal/globalping"
+	"github.com/hervehildenbrand/gtrace/internal/hoststats"
+	"github.com/hervehildenbrand/gtrace/internal/logging"
 	"github.com/hervehildenbrand/gtrace/internal/monitor"
+	"github.com/hervehildenbrand/gtrace/internal/netwatch"
+	"github.com/hervehildenbrand/gtrace/internal/session"
 	"github.com/hervehildenbrand/gtrace/internal/trace"
 	"github.com/hervehildenbrand/gtrace/internal/update"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
 )
 
 // Config holds the parsed CLI configuration.
 type Config struct {
-	Target   string
-	Targets  []string // Multiple targets for split-pane MTR
-	From     string
-	Protocol string
-	Port     int
-	MaxHops  int
-	Packets  int
-	Timeout  string
-	Interval string // MTR mode: interval between trace cycles
-	Cycles   int    // MTR mode: number of cycles (0 = infinite)
-	Compare  bool
-	NoLocal  bool
-	View     string
-	Monitor  bool
-	AlertLatency string
-	AlertLoss    string
-	Simple   bool
-	NoColor  bool
-	Output   string
-	Format   string
-	APIKey   string
-	Offline  bool
-	Verbose  bool
-	DryRun   bool
-	DownloadDB bool
-	DBStatus   bool
-	IPv4Only    bool // Force IPv4 only
-	IPv6Only    bool // Force IPv6 only
-	DetectNAT   bool // Enable NAT detection via TTL analysis
-	ECMPFlows   int  // ECMP flow variations per hop (0=disabled)
-	DiscoverMTU bool // Enable Path MTU Discovery
-	ProbeSize   int  // Probe packet size in bytes
-	Decode      bool // Extract transport header info from ICMP errors
-
+	Target           string
+	Targets          []string // Multiple targets for split-pane MTR
+	TargetsFile      string   // File with one target per line
+	From             string
+	Limit            int // GlobalPing probes per --from location (0 = one)
+	Protocol         string
+	Port             int
+	MaxHops          int
+	Packets          int
+	Timeout          string
+	Interval         string // MTR mode: interval between trace cycles
+	Cycles           int    // MTR and monitor modes: number of cycles (0 = infinite)
+	History          int    // MTR mode: RTT samples kept per hop for StDev and the RTT chart
+	Warmup           int    // MTR mode: initial cycles shown but left out of the statistics
+	Compare          bool
+	NoLocal          bool
+	Reverse          bool   // Also trace from a probe near the target back to our public IP
+	Align            bool   // Compare mode: start the local trace once remote measurements are created
+	AlignBy          string // Compare tables: line rows up by "ttl" or "asn"
+	View             string
+	Monitor          bool
+	Schedule         string // Monitor mode: trace at the times of this cron expression
+	ResolveEvery     int    // Monitor mode: re-resolve the target hostname every this many cycles (0 = never)
+	AlertLatency     string
+	AlertLoss        string
+	AlertFlap        string   // Monitor mode: alert when a hop's address changes in this share of recent cycles
+	Alerts           []string // Monitor mode: alert rules, e.g. "hop(last).loss > 5% for 3 cycles"
+	JSON             bool     // Monitor mode: emit per-cycle summaries as JSON lines
+	HistoryFile      string   // Monitor mode: append the trace of every cycle to this file
+	ConfirmAnomalies bool     // Monitor mode: re-trace before alerting on latency or loss anomalies
+	Duration         string   // Monitor mode: stop after this long (e.g. 10m)
+	UntilLoss        string   // Monitor mode: stop once the loss to the target exceeds this (e.g. 5%)
+	UntilLatency     string   // Monitor mode: stop once the average RTT to the target exceeds this (e.g. 100ms)
+	FailOn           []string // Conditions that make the run exit non-zero
+	Simple           bool
+	NoColor          bool
+	ASCII            bool // Force ASCII glyphs and basic colors
+	Output           string
+	Format           string
+	OTLP             string // Send traces as OpenTelemetry spans to this OTLP/HTTP endpoint
+	APIKey           string
+	Offline          bool
+	Verbose          bool
+	DryRun           bool
+	DownloadDB       bool
+	DBStatus         bool
+	UpdateIX         bool     // Download PeeringDB IXP prefixes for offline IX detection
+	LicenseKey       string   // MaxMind license key for --download-db / --db-auto-update
+	DBAutoUpdate     int      // Refresh GeoIP databases older than this many days (0 = disabled)
+	GeoProvider      string   // Offline GeoIP database provider: maxmind|ipinfo|dbip
+	GeoDB            string   // GeoIP database path (default: provider's file in ~/.gtr/data)
+	CacheDir         string   // Enrichment cache directory (default: ~/.gtr/cache)
+	BGP              bool     // Query a looking glass for each hop's prefix, AS path and visibility
+	LookingGlass     string   // RIPEstat-compatible looking glass URL (implies BGP)
+	Reputation       bool     // Check hops against the Spamhaus DROP and bogon lists
+	Blocklists       []string // Custom blocklist files or URLs (implies Reputation)
+	IPv4Only         bool     // Force IPv4 only
+	IPv6Only         bool     // Force IPv6 only
+	DualStack        bool     // Trace IPv4 and IPv6 concurrently and compare
+	AllIPs           bool     // Trace every address of the target and compare
+	DetectNAT        bool     // Enable NAT detection via TTL analysis
+	ECMPFlows        int      // ECMP flow variations per hop (0=disabled)
+	DiscoverMTU      bool     // Enable Path MTU Discovery
+	ProbeSize        int      // Probe packet size in bytes
+	Queue            bool     // Compare small and large probe RTTs per hop to locate queueing
+	QueueRounds      int      // Rounds of small and large probe traces in --queue mode
+	QueueSize        int      // Size of the large probes in --queue mode
+	QUICCompare      bool     // Compare QUIC and plain UDP probes per hop to locate where QUIC is dropped
+	QUICRounds       int      // Rounds of QUIC and plain UDP traces in --quic-compare mode
+	ServiceMatrix    bool     // Trace over several protocols and ports at once and compare the paths
+	Services         []string // Protocols and ports of --service-matrix, e.g. icmp, tcp:443
+	Diagnose         bool     // Locate the filter when TCP/UDP probes never reach the target
+	Decode           bool     // Extract transport header info from ICMP errors
+	PCAP             string   // Write probe/response packets to a pcap file
+	Record           string   // MTR mode: log every probe event to this session file for gtrace replay
+	Shards           int      // Concurrent UDP/TCP probe workers per trace
+	Sequential       bool     // Probe TTLs one at a time in single-shot traces
+	NetNS            string   // Linux network namespace to trace from
+	FlowLabel        int      // IPv6 flow label of every probe (-1 = kernel default)
+	IPv6Ext          string   // IPv6 options headers added to every probe (hbh, dst, with optional :size)
+	Rate             float64  // Maximum probes per second across all traces (0 = unlimited)
+	Burst            int      // Probes sent back to back before Rate applies
+	MaxInFlight      int      // Maximum probes awaiting a reply across all traces (0 = unlimited)
+	TTLInterval      string   // Pause before probing each TTL after the first
+	ViaSOCKS5        string   // TCP connect probes through this SOCKS5 proxy (host:port)
+	ViaSSH           string   // TCP connect probes through an ssh -D tunnel to this bastion (user@host)
+	Via              string   // Run the MTR session on this host over SSH (ssh://[user@]host[:port])
+	ViaSudo          bool     // Run the remote agent with sudo -n
+	EndToEnd         bool     // TCP: also time a TLS handshake with the target
+	GeoValidate      bool     // Flag hop geolocations that are impossible given the RTTs
+	HostStats        bool     // Correlate local interface errors and drops with hop loss
+	ResolveDebug     bool     // Show how hostname targets resolve before tracing
+	DNS              string   // DNS server for target resolution and reverse DNS (address, tls://host or https:// URL)
+	Theme            string   // TUI color theme (built-in or defined in the config file)
+	ConfigFile       string   // User configuration file (default: ~/.gtr/config.json)
+	LogFormat        string   // Format of the diagnostic logs on stderr: text|json
+
+	theme        display.Theme
+	capture      trace.CaptureSink
+	geoProvider  enrich.Provider
+	bgpLookup    *enrich.BGPLookup
+	diskCache    *enrich.DiskCache
+	reputation   *enrich.ReputationLookup
+	dnsServer    *dnsdiag.Server
+	ipv6Opts     *trace.IPv6Options
+	resolver     *net.Resolver // nil for the system resolver
 	updateResult <-chan *update.CheckResult
+	failOn       *failTracker
+	alertRules   []*monitor.Rule
+	schedule     *monitor.Schedule
+	metricSinks  []monitor.MetricSink
+	logger       *slog.Logger
+	scheduler    trace.Scheduler
+	packetsSet   bool // --packets given; MTR mode otherwise probes each hop once per cycle
+	duration     time.Duration
+	until        *failTracker // --until-loss and --until-latency, nil when not given
+	services     []trace.Service
+	via          *agent.Dest // --via host, nil when not given
+	version      string      // gtrace release, which the --via agent must match
 }
 
+// maxTargets is the maximum number of targets traced in one invocation.
+const maxTargets = 5
+
 var validProtocols = map[string]bool{
 	"icmp": true,
 	"udp":  true,
 	"tcp":  true,
+	"quic": true,
+	"auto": true,
 }
 
 // getAddressFamily returns the AddressFamily based on config flags.
@@ -91,13 +192,112 @@ func getIPVersion(cfg *Config) int {
 	return 0 // Auto - let GlobalPing decide
 }
 
-// newEnricher creates an enricher based on configuration.
-// Returns nil if offline mode is enabled (no enrichment).
-func newEnricher(offline bool) enrich.EnricherInterface {
-	if offline {
+// collectTargets returns the positional targets followed by those read from
+// the targets file. Blank lines and lines starting with # are ignored.
+func collectTargets(args []string, file string) ([]string, error) {
+	targets := append([]string{}, args...)
+	if file == "" {
+		return targets, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read targets file: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	return targets, nil
+}
+
+// newEnricher creates an enricher based on configuration. In offline mode
+// it only uses local databases and resolvers; cache and bgp are ignored.
+// rep, when set, flags hops on reputation lists; resolver, when set, answers
+// reverse DNS lookups.
+func newEnricher(offline bool, geo enrich.Provider, cache *enrich.DiskCache, bgp *enrich.BGPLookup, rep *enrich.ReputationLookup, resolver *net.Resolver) enrich.EnricherInterface {
+	var e *enrich.Enricher
+	switch {
+	case offline:
+		e = enrich.NewOfflineEnricher(geo)
+	case geo != nil:
+		e = enrich.NewEnricherWithProvider(geo)
+	default:
+		e = enrich.NewEnricher()
+	}
+	if cache != nil && !offline {
+		e.SetDiskCache(cache)
+	}
+	if bgp != nil && !offline {
+		e.SetBGPLookup(bgp)
+	}
+	if rep != nil {
+		e.SetReputation(rep)
+	}
+	if resolver != nil && !offline {
+		e.SetResolver(resolver)
+	}
+	return e
+}
+
+// loadReputation loads the reputation feeds selected by --reputation and
+// --blocklist, warning on w about feeds that cannot be loaded. Returns nil
+// when reputation checks are disabled.
+func loadReputation(ctx context.Context, w io.Writer, cfg *Config) *enrich.ReputationLookup {
+	if !cfg.Reputation && len(cfg.Blocklists) == 0 {
 		return nil
 	}
-	return enrich.NewEnricher()
+	feeds := append([]enrich.ReputationFeed(nil), enrich.DefaultReputationFeeds...)
+	for _, source := range cfg.Blocklists {
+		feeds = append(feeds, enrich.CustomReputationFeed(source))
+	}
+	rep, errs := enrich.LoadReputation(ctx, feeds, enrich.ReputationOptions{Offline: cfg.Offline})
+	for _, err := range errs {
+		fmt.Fprintf(w, "Warning: %v\n", err)
+	}
+	return rep
+}
+
+// reportOfflineGaps tells on w which enrichment fields offline mode leaves
+// empty for lack of local data.
+func reportOfflineGaps(w io.Writer, geo enrich.Provider) {
+	for _, gap := range enrich.OfflineGaps(geo) {
+		fmt.Fprintf(w, "Offline: %s\n", gap)
+	}
+}
+
+// newBGPLookup creates the looking glass client selected by --bgp and
+// --looking-glass. Returns nil when BGP lookups are disabled.
+func newBGPLookup(enabled bool, lookingGlass string) (*enrich.BGPLookup, error) {
+	if lookingGlass == "" {
+		if !enabled {
+			return nil, nil
+		}
+		return enrich.NewBGPLookup(), nil
+	}
+	u, err := url.Parse(lookingGlass)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid --looking-glass %q: must be an http(s) URL", lookingGlass)
+	}
+	return enrich.NewBGPLookupWithURL(lookingGlass), nil
+}
+
+// newGeoProvider creates the GeoIP provider selected by --geo-provider and
+// --geo-db. An explicit database path must exist.
+func newGeoProvider(name, path string) (enrich.Provider, error) {
+	provider, err := enrich.NewProvider(name, path)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("--geo-db: %w", err)
+		}
+	}
+	return provider, nil
 }
 
 // NewRootCmd creates and returns the root cobra command.
@@ -110,77 +310,21 @@ func NewRootCmd(version string) *cobra.Command {
 		Long: `gtrace combines local traceroute with GlobalPing's distributed probe network,
 featuring advanced diagnostics (MPLS, ECMP, MTU, NAT detection),
 rich hop enrichment (ASN, geo, hostnames), and real-time MTR-style TUI.`,
-		Args: cobra.RangeArgs(0, 5),
+		Args: cobra.RangeArgs(0, maxTargets),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			// Skip validation for special commands
-			if cfg.DBStatus || cfg.DownloadDB {
+			if cfg.DBStatus || cfg.DownloadDB || cfg.UpdateIX {
 				return nil
 			}
 
-			// Require at least one target for normal operation
-			if len(args) == 0 {
-				return fmt.Errorf("requires a target argument")
-			}
-
-			// Validate max targets
-			if len(args) > 5 {
-				return fmt.Errorf("too many targets: %d (maximum 5)", len(args))
-			}
-
-			// Validate protocol
-			if !validProtocols[cfg.Protocol] {
-				return fmt.Errorf("invalid protocol %q: must be icmp, udp, or tcp", cfg.Protocol)
-			}
-
-			// --compare requires --from
-			if cfg.Compare && cfg.From == "" {
-				return fmt.Errorf("--compare requires --from to specify remote location")
-			}
-
-			// Validate --from location count
-			if cfg.From != "" {
-				locations := globalping.ParseLocationStrings(cfg.From)
-				if len(locations) > globalping.MaxLocations {
-					return fmt.Errorf("too many --from locations: %d (maximum %d)", len(locations), globalping.MaxLocations)
-				}
-			}
-
-			// --no-local requires --from with >= 2 locations and implies --compare
-			if cfg.NoLocal {
-				if cfg.From == "" {
-					return fmt.Errorf("--no-local requires --from to specify remote locations")
-				}
-				locations := globalping.ParseLocationStrings(cfg.From)
-				if len(locations) < 2 {
-					return fmt.Errorf("--no-local requires --from with at least 2 locations")
-				}
-				cfg.Compare = true
-			}
-
-			// -4 and -6 are mutually exclusive
-			if cfg.IPv4Only && cfg.IPv6Only {
-				return fmt.Errorf("-4/--ipv4 and -6/--ipv6 are mutually exclusive")
-			}
-
-			// Validate diagnostic flags
-			if cfg.ECMPFlows < 0 {
-				return fmt.Errorf("--ecmp-flows must be >= 0")
-			}
-			if cfg.ProbeSize < 1 {
-				return fmt.Errorf("--probe-size must be >= 1")
-			}
-
-			// Check privileges early for local traces
-			// Skip for: --from only (GlobalPing API), --dry-run, --compare (checked at runtime)
-			needsLocalTrace := (cfg.From == "" || cfg.Compare) && !cfg.NoLocal
-			if needsLocalTrace && !cfg.DryRun {
-				if err := trace.CheckPrivileges(); err != nil {
-					return err
-				}
+			cfg.packetsSet = cmd.Flags().Changed("packets")
+			cfg.version = version
+			if err := prepareConfig(&cfg, args); err != nil {
+				return err
 			}
 
 			// Start non-blocking update check
-			if os.Getenv("GTRACE_NO_UPDATE_CHECK") != "1" {
+			if os.Getenv("GTRACE_NO_UPDATE_CHECK") != "1" && !cfg.Offline {
 				cfg.updateResult = startUpdateCheck(version)
 			}
 
@@ -193,95 +337,814 @@ rich hop enrichment (ASN, geo, hostnames), and real-time MTR-style TUI.`,
 				return nil
 			}
 
+			// Handle --update-ix
+			if cfg.UpdateIX {
+				return updateIXPrefixes(cmd.Context(), cmd.OutOrStdout())
+			}
+
 			// Handle --download-db
 			if cfg.DownloadDB {
+				licenseKey := enrich.ResolveLicenseKey(cfg.LicenseKey)
+				if licenseKey != "" {
+					return downloadGeoDatabases(cmd.OutOrStdout(), licenseKey, nil)
+				}
+
 				fmt.Fprintln(cmd.OutOrStdout(), "Database Download")
 				fmt.Fprintln(cmd.OutOrStdout(), "")
 				fmt.Fprintln(cmd.OutOrStdout(), "MaxMind GeoLite2 databases require a free license key.")
 				fmt.Fprintln(cmd.OutOrStdout(), "")
 				fmt.Fprintln(cmd.OutOrStdout(), "To set up GeoIP databases:")
 				fmt.Fprintln(cmd.OutOrStdout(), "  1. Register at https://www.maxmind.com/en/geolite2/signup")
-				fmt.Fprintln(cmd.OutOrStdout(), "  2. Download GeoLite2-City.mmdb from your account")
-				fmt.Fprintln(cmd.OutOrStdout(), "  3. Place it at: "+enrich.DefaultGeoDBPath())
+				fmt.Fprintln(cmd.OutOrStdout(), "  2. Run: gtrace --download-db --license-key YOUR_KEY")
+				fmt.Fprintln(cmd.OutOrStdout(), "     (or set "+enrich.LicenseKeyEnv+", or add 'LicenseKey YOUR_KEY' to ~/.gtr/GeoIP.conf)")
+				fmt.Fprintln(cmd.OutOrStdout(), "  Or download GeoLite2-City.mmdb manually and place it at: "+enrich.DefaultGeoDBPath())
 				fmt.Fprintln(cmd.OutOrStdout(), "")
 				fmt.Fprintln(cmd.OutOrStdout(), "Current status:")
 				fmt.Fprint(cmd.OutOrStdout(), enrich.PrintDBStatus())
 				return nil
 			}
 
-			cfg.Target = args[0]
-			cfg.Targets = args
-
-			if cfg.DryRun {
-				// Just validate args and return
-				return nil
-			}
-
-			err := runTrace(cmd, &cfg)
-			printUpdateNotification(cmd.ErrOrStderr(), cfg.updateResult)
-			return err
+			return executeConfig(cmd, &cfg)
 		},
 	}
 
+	registerRootFlags(cmd.Flags(), &cfg)
+	cmd.Flags().MarkDeprecated("alert-latency", "use an --alert rule instead: --alert-latency 100ms is --alert 'any(hop).rtt > 100ms'")
+	cmd.Flags().MarkDeprecated("alert-loss", "use an --alert rule instead: --alert-loss 5% is --alert 'any(hop).loss > 5%'")
+
+	return cmd
+}
+
+// registerRootFlags defines the trace flags on flags, storing values and
+// defaults in cfg.
+func registerRootFlags(flags *pflag.FlagSet, cfg *Config) {
 	// Source location flags
-	cmd.Flags().StringVar(&cfg.From, "from", "", "Run from GlobalPing location(s), max 5. Simple: Paris;Tokyo;DE. Structured: city:Tokyo,asn:2497. Use 'gtrace probes' to discover locations")
-	cmd.Flags().BoolVar(&cfg.Compare, "compare", false, "Compare local + remote traces")
-	cmd.Flags().BoolVar(&cfg.NoLocal, "no-local", false, "Skip local trace, compare remote locations only")
-	cmd.Flags().StringVar(&cfg.View, "view", "side", "Display mode: side|tabs|unified")
+	flags.StringVar(&cfg.From, "from", "", "Run from GlobalPing location(s), max 5. Simple: Paris;Tokyo;DE. Structured: city:Tokyo,asn:2497. Use 'gtrace probes' to discover locations")
+	flags.IntVar(&cfg.Limit, "limit", 0, "GlobalPing probes per --from location, all compared side by side (implies --no-local unless --compare)")
+	flags.BoolVar(&cfg.Compare, "compare", false, "Compare local + remote traces")
+	flags.BoolVar(&cfg.NoLocal, "no-local", false, "Skip local trace, compare remote locations only (needs no root privileges)")
+	flags.BoolVar(&cfg.Align, "align", false, "Compare mode: delay the local trace until remote measurements start so all sources cover the same time window")
+	flags.StringVar(&cfg.AlignBy, "align-by", "ttl", "Compare mode: line rows up by ttl, or by asn so paths through the same providers match")
+	flags.BoolVar(&cfg.Reverse, "reverse", false, "Compare forward path with a reverse trace from a probe near the target (--from overrides the probe)")
+	flags.StringVar(&cfg.TargetsFile, "targets-file", "", "Read additional targets from a file (one per line, # comments)")
+	flags.StringVar(&cfg.View, "view", "side", "Display mode: side|tabs|unified")
 
 	// Protocol flags
-	cmd.Flags().StringVar(&cfg.Protocol, "protocol", "icmp", "Protocol: icmp|udp|tcp")
-	cmd.Flags().IntVar(&cfg.Port, "port", 33434, "Port for TCP/UDP")
-	cmd.Flags().IntVar(&cfg.MaxHops, "max-hops", 30, "Maximum hops")
-	cmd.Flags().IntVar(&cfg.Packets, "packets", 3, "Packets per hop")
-	cmd.Flags().StringVar(&cfg.Timeout, "timeout", "500ms", "Per-hop timeout (MTR default: 500ms)")
+	flags.StringVar(&cfg.Protocol, "protocol", "icmp", "Protocol: icmp|udp|tcp|quic|auto (QUIC Initial packets, to port 443 unless --port is given; auto falls back from icmp to udp, then tcp/443, when probes are blackholed)")
+	flags.IntVar(&cfg.Port, "port", 33434, "Port for TCP/UDP/QUIC")
+	flags.IntVar(&cfg.MaxHops, "max-hops", 30, "Maximum hops")
+	flags.IntVar(&cfg.Packets, "packets", 3, "Packets per hop")
+	flags.StringVar(&cfg.Timeout, "timeout", "500ms", "Per-hop timeout (MTR default: 500ms)")
 
 	// MTR mode flags
-	cmd.Flags().StringVar(&cfg.Interval, "interval", "1s", "Interval between trace cycles (MTR mode)")
-	cmd.Flags().IntVar(&cfg.Cycles, "cycles", 0, "Number of cycles (0 = infinite, MTR mode)")
+	flags.StringVar(&cfg.Interval, "interval", "1s", "Interval between trace cycles (MTR mode)")
+	flags.IntVar(&cfg.Cycles, "cycles", 0, "Number of cycles (0 = infinite, MTR and monitor modes)")
+	flags.IntVar(&cfg.History, "history", display.RTTHistorySize, "RTT samples kept per hop for StDev and the RTT chart (MTR mode)")
+	flags.IntVar(&cfg.Warmup, "warmup", 0, "Initial cycles shown but excluded from the statistics (MTR mode)")
 
 	// Monitoring flags
-	cmd.Flags().BoolVar(&cfg.Monitor, "monitor", false, "Continuous monitoring mode")
-	cmd.Flags().StringVar(&cfg.AlertLatency, "alert-latency", "", "Alert on latency threshold (e.g., 100ms)")
-	cmd.Flags().StringVar(&cfg.AlertLoss, "alert-loss", "", "Alert on packet loss threshold (e.g., 5%)")
+	flags.BoolVar(&cfg.Monitor, "monitor", false, "Continuous monitoring mode")
+	flags.StringVar(&cfg.Schedule, "schedule", "", "Trace at the times of a cron expression, e.g. '*/5 * * * *' (implies --monitor)")
+	flags.StringVar(&cfg.HistoryFile, "history-file", "", "Append the trace of every monitor cycle to this file, one JSON export per line")
+	flags.IntVar(&cfg.ResolveEvery, "resolve-every", 10, "Re-resolve the target hostname every N monitor cycles and rebase on a new address (0 to disable)")
+	flags.StringVar(&cfg.AlertLatency, "alert-latency", "", "Alert when a hop's RTT exceeds this (e.g., 100ms), as the rule any(hop).rtt > VALUE")
+	flags.StringVar(&cfg.AlertLoss, "alert-loss", "", "Alert when a hop's packet loss exceeds this (e.g., 5%), as the rule any(hop).loss > VALUE")
+	flags.StringVar(&cfg.AlertFlap, "alert-flap", fmt.Sprintf("%.0f%%", monitor.DefaultFlapThreshold), fmt.Sprintf("Alert when a hop's address changes in this share of the last %d cycles (0 to disable)", monitor.FlapWindow))
+	flags.StringArrayVar(&cfg.Alerts, "alert", nil, "Alert rule, repeatable: SELECTOR.METRIC OP VALUE [for N cycles] [then exec CMD|webhook URL], e.g. 'hop(last).loss > 5% for 3 cycles'")
+	flags.BoolVar(&cfg.JSON, "json", false, "Emit monitor cycle summaries as JSON lines")
+	flags.BoolVar(&cfg.ConfirmAnomalies, "confirm-anomalies", false, "Re-trace when a monitor cycle shows a latency or loss anomaly and alert only if the re-trace reproduces it")
+	flags.StringVar(&cfg.Duration, "duration", "", "Stop monitoring after this long (e.g. 10m)")
+	flags.StringVar(&cfg.UntilLoss, "until-loss", "", "Stop monitoring once the loss to the target, over all cycles, exceeds this (e.g. 5%); exits 5")
+	flags.StringVar(&cfg.UntilLatency, "until-latency", "", "Stop monitoring once the average RTT to the target, over all cycles, exceeds this (e.g. 100ms); exits 2")
+	flags.StringSliceVar(&cfg.FailOn, "fail-on", nil, "Exit non-zero when a condition holds: unreached, loss>N%, latency>DURATION, alert (repeatable)")
 
 	// Display flags
-	cmd.Flags().BoolVar(&cfg.Simple, "simple", false, "Simple output (no TUI)")
-	cmd.Flags().BoolVar(&cfg.NoColor, "no-color", false, "Disable colors")
+	flags.BoolVar(&cfg.Simple, "simple", false, "Simple output (no TUI)")
+	flags.BoolVar(&cfg.NoColor, "no-color", false, "Disable colors")
+	flags.BoolVar(&cfg.ASCII, "ascii", false, "Force ASCII rendering with basic colors (auto-detected on limited terminals)")
+	flags.StringVar(&cfg.Theme, "theme", "", "Color theme: dark|light|high-contrast|colorblind or a theme from the config file")
+	flags.StringVar(&cfg.ConfigFile, "config", defaultUserConfigPath(), "User configuration file (themes)")
+
+	// Export flags
+	flags.StringVarP(&cfg.Output, "output", "o", "", "Export to file (json/csv/txt)")
+	flags.StringVar(&cfg.Format, "format", "", "Explicit export format: json|csv|text|dot|d2|scamper|otlp")
+	flags.StringVar(&cfg.OTLP, "otlp", "", "Send each trace as OpenTelemetry spans to an OTLP/HTTP endpoint, e.g. http://localhost:4318 (implies --simple)")
+
+	// Other flags
+	flags.StringVar(&cfg.APIKey, "api-key", "", "GlobalPing API key")
+	flags.BoolVar(&cfg.Offline, "offline", false, "Use only local enrichment DBs and resolvers, never the network")
+	flags.StringVar(&cfg.CacheDir, "cache-dir", "", "Enrichment cache directory (default: ~/.gtr/cache)")
+	flags.BoolVar(&cfg.BGP, "bgp", false, "Look up each hop's BGP prefix, AS path and visibility (RIPEstat)")
+	flags.BoolVar(&cfg.Reputation, "reputation", false, "Flag bogon and hijack-suspicious hops using the Spamhaus DROP and Team Cymru bogon lists")
+	flags.StringArrayVar(&cfg.Blocklists, "blocklist", nil, "Also flag hops on this blocklist file or URL, one prefix per line (repeatable, implies --reputation)")
+	flags.StringVar(&cfg.LookingGlass, "looking-glass", "", "RIPEstat-compatible looking glass URL for --bgp (default: "+enrich.DefaultLookingGlassURL+")")
+	flags.BoolVarP(&cfg.Verbose, "verbose", "v", false, "Verbose output, including debug logs")
+	flags.StringVar(&cfg.LogFormat, "log-format", logging.FormatText, "Diagnostic log format on stderr: text|json")
+	flags.BoolVar(&cfg.DryRun, "dry-run", false, "Validate args without running trace")
+
+	// Database management flags
+	flags.BoolVar(&cfg.DownloadDB, "download-db", false, "Download GeoIP databases (needs a MaxMind license key, shows instructions otherwise)")
+	flags.StringVar(&cfg.LicenseKey, "license-key", "", "MaxMind license key (default: $"+enrich.LicenseKeyEnv+" or LicenseKey in ~/.gtr/GeoIP.conf)")
+	flags.IntVar(&cfg.DBAutoUpdate, "db-auto-update", 0, "Refresh GeoIP databases older than N days before tracing (0 = disabled)")
+	flags.BoolVar(&cfg.DBStatus, "db-status", false, "Show GeoIP database status")
+	flags.BoolVar(&cfg.UpdateIX, "update-ix", false, "Download PeeringDB IXP prefixes for offline IX detection")
+	flags.StringVar(&cfg.GeoProvider, "geo-provider", enrich.ProviderMaxMind, "Offline GeoIP database: maxmind|ipinfo|dbip")
+	flags.StringVar(&cfg.GeoDB, "geo-db", "", "GeoIP database file (default: GeoLite2-City.mmdb, ipinfo_lite.mmdb or dbip-city-lite.mmdb in ~/.gtr/data)")
+
+	// IP version flags
+	flags.BoolVarP(&cfg.IPv4Only, "ipv4", "4", false, "Use IPv4 only")
+	flags.BoolVarP(&cfg.IPv6Only, "ipv6", "6", false, "Use IPv6 only")
+	flags.BoolVar(&cfg.DualStack, "dual-stack", false, "Trace IPv4 and IPv6 concurrently and compare side by side")
+	flags.BoolVar(&cfg.AllIPs, "all-ips", false, "Trace every A/AAAA address of the target and compare side by side (one at a time with --sequential)")
+
+	// Advanced diagnostics flags
+	flags.BoolVar(&cfg.DetectNAT, "detect-nat", false, "Enable NAT detection via TTL analysis")
+	flags.IntVar(&cfg.ECMPFlows, "ecmp-flows", 0, "ECMP flow variations per hop (0=disabled, 8=recommended)")
+	flags.BoolVar(&cfg.DiscoverMTU, "discover-mtu", false, "Enable Path MTU Discovery")
+	flags.IntVar(&cfg.ProbeSize, "probe-size", 64, "Probe packet size in bytes")
+	flags.BoolVar(&cfg.Queue, "queue", false, "Interleave small and large probes and report RTT inflation per hop to locate bufferbloat or policing")
+	flags.IntVar(&cfg.QueueRounds, "queue-rounds", 10, "Rounds of small and large probe traces in --queue mode")
+	flags.IntVar(&cfg.QueueSize, "queue-size", trace.DefaultQueueProbeSize, "Size of the large probes in --queue mode, in bytes")
+	flags.BoolVar(&cfg.QUICCompare, "quic-compare", false, "Alternate QUIC and plain UDP probes of the same size and report the hop where QUIC gets dropped (implies --protocol quic)")
+	flags.IntVar(&cfg.QUICRounds, "quic-rounds", 3, "Rounds of QUIC and plain UDP traces in --quic-compare mode")
+	flags.BoolVar(&cfg.ServiceMatrix, "service-matrix", false, "Trace the target over several protocols and ports at once and show reachability and path differences in a matrix")
+	flags.StringSliceVar(&cfg.Services, "services", []string{"icmp", "udp:53", "tcp:80", "tcp:443"}, "Protocols and ports of --service-matrix: icmp, udp:PORT, tcp:PORT or quic:PORT")
+	flags.BoolVar(&cfg.Diagnose, "diagnose", false, "When a tcp or udp trace does not reach the target, trace the path with ICMP and report the hop where the port is filtered")
+	flags.BoolVarP(&cfg.Decode, "decode", "D", false, "Decode transport headers from ICMP error bodies")
+	flags.StringVar(&cfg.PCAP, "pcap", "", "Record probe and response packets to a pcap file")
+	flags.StringVar(&cfg.Record, "record", "", "Record every probe event of the MTR session with timestamps to a file for gtrace replay")
+	flags.StringVar(&cfg.ViaSOCKS5, "via-socks5", "", "Measure TCP connect latency through a SOCKS5 proxy (host:port, requires --protocol tcp)")
+	flags.StringVar(&cfg.ViaSSH, "via-ssh", "", "Measure TCP connect latency through an SSH bastion (user@host, requires --protocol tcp)")
+	flags.StringVar(&cfg.Via, "via", "", "Run the MTR session from another host over SSH (ssh://[user@]host[:port]) and show it in the local TUI")
+	flags.BoolVar(&cfg.ViaSudo, "via-sudo", false, "Run the gtrace agent of --via with sudo -n, for hosts where probing needs root")
+	flags.BoolVar(&cfg.EndToEnd, "end-to-end", false, "TCP: time a TLS handshake at the target to separate network RTT from server response time")
+	flags.BoolVar(&cfg.GeoValidate, "geo-validate", false, "Flag hops whose geolocation is impossible given the measured RTTs and print a report (implies --simple)")
+	flags.BoolVar(&cfg.HostStats, "host-stats", false, "Sample the outgoing interface's error and drop counters during the trace and report whether loss coincided with them (Linux/macOS, implies --simple)")
+	flags.StringVar(&cfg.DNS, "dns", "", "Resolve the target and hop names with this DNS server: an address (9.9.9.9), tls://host for DNS over TLS or an https:// DoH URL")
+	flags.BoolVar(&cfg.ResolveDebug, "resolve-debug", false, "Before tracing, show which resolver answered, the CNAME chain, TTLs, all addresses and EDNS Client Subnet behavior")
+	flags.IntVar(&cfg.Shards, "shards", 1, "Concurrent probe workers per trace, each with its own sockets (udp/tcp only)")
+	flags.BoolVar(&cfg.Sequential, "sequential", false, "Probe TTLs one at a time instead of all at once (single-shot icmp/udp traces)")
+	flags.StringVar(&cfg.NetNS, "netns", "", "Trace from inside a Linux network namespace (ip netns name or path)")
+	flags.IntVar(&cfg.FlowLabel, "flow-label", -1, "IPv6: set the flow label of every probe (0-1048575, -1 = kernel default; implies -6)")
+	flags.StringVar(&cfg.IPv6Ext, "ipv6-ext", "", "IPv6: add padded options headers to every probe: hbh, dst or both, with an optional size in bytes (e.g. hbh:16,dst:64; implies -6)")
+
+	// Probe pacing flags
+	flags.Float64Var(&cfg.Rate, "rate", 0, "Maximum probes per second across all traces (0 = unlimited)")
+	flags.IntVar(&cfg.Burst, "burst", 1, "Probes sent back to back before --rate applies")
+	flags.IntVar(&cfg.MaxInFlight, "max-in-flight", 0, "Maximum probes awaiting a reply across all traces and shards (0 = unlimited)")
+	flags.StringVar(&cfg.TTLInterval, "ttl-interval", "", "Pause before probing each TTL after the first (e.g., 100ms)")
+}
+
+// pacingConfig returns the probe pacing set by the --rate, --burst,
+// --max-in-flight and --ttl-interval flags.
+func pacingConfig(cfg *Config) (trace.PacingConfig, error) {
+	var p trace.PacingConfig
+	if cfg.Rate < 0 {
+		return p, fmt.Errorf("--rate must be >= 0")
+	}
+	if cfg.Burst < 1 {
+		return p, fmt.Errorf("--burst must be >= 1")
+	}
+	if cfg.Burst > 1 && cfg.Rate == 0 {
+		return p, fmt.Errorf("--burst requires --rate")
+	}
+	if cfg.MaxInFlight < 0 {
+		return p, fmt.Errorf("--max-in-flight must be >= 0")
+	}
+	if cfg.TTLInterval != "" {
+		d, err := time.ParseDuration(cfg.TTLInterval)
+		if err != nil || d < 0 {
+			return p, fmt.Errorf("invalid --ttl-interval %q", cfg.TTLInterval)
+		}
+		p.TTLInterval = d
+	}
+
+	p.Rate = cfg.Rate
+	if cfg.Rate > 0 {
+		p.Burst = cfg.Burst
+	}
+	p.MaxInFlight = cfg.MaxInFlight
+	return p, nil
+}
+
+// defaultConfig returns a Config holding every flag's default value.
+func defaultConfig() Config {
+	var cfg Config
+	registerRootFlags(pflag.NewFlagSet("defaults", pflag.ContinueOnError), &cfg)
+	return cfg
+}
+
+// prepareConfig validates cfg and fills in derived settings: the merged
+// target list, implied flags, and the privilege check for local traces.
+func prepareConfig(cfg *Config, args []string) error {
+	// Merge positional targets with --targets-file entries
+	targets, err := collectTargets(args, cfg.TargetsFile)
+	if err != nil {
+		return err
+	}
+
+	// Require at least one target for normal operation
+	if len(targets) == 0 {
+		return fmt.Errorf("requires a target argument")
+	}
+
+	// Validate max targets
+	if len(targets) > maxTargets {
+		return fmt.Errorf("too many targets: %d (maximum %d)", len(targets), maxTargets)
+	}
+	cfg.Targets = targets
+
+	// Validate protocol
+	if !validProtocols[cfg.Protocol] {
+		return fmt.Errorf("invalid protocol %q: must be icmp, udp, tcp, quic or auto", cfg.Protocol)
+	}
+
+	// --protocol auto runs several local traces and merges them
+	if cfg.Protocol == "auto" && (cfg.From != "" || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "") {
+		return fmt.Errorf("--protocol auto requires a local trace (not --from or a proxy)")
+	}
+
+	if cfg.LogFormat != logging.FormatText && cfg.LogFormat != logging.FormatJSON {
+		return fmt.Errorf("invalid log format %q: must be text or json", cfg.LogFormat)
+	}
+
+	// --compare requires --from
+	if cfg.Compare && cfg.From == "" {
+		return fmt.Errorf("--compare requires --from to specify remote location")
+	}
+
+	// Validate --from location count
+	if cfg.From != "" {
+		locations := globalping.ParseLocationStrings(cfg.From)
+		if len(locations) > globalping.MaxLocations {
+			return fmt.Errorf("too many --from locations: %d (maximum %d)", len(locations), globalping.MaxLocations)
+		}
+	}
+
+	// --no-local requires --from with >= 2 locations and implies --compare
+	if cfg.NoLocal {
+		if cfg.From == "" {
+			return fmt.Errorf("--no-local requires --from to specify remote locations")
+		}
+		locations := globalping.ParseLocationStrings(cfg.From)
+		if len(locations) < 2 && cfg.Limit < 2 {
+			return fmt.Errorf("--no-local requires --from with at least 2 locations or --limit of at least 2")
+		}
+		cfg.Compare = true
+	}
+
+	// --schedule runs the monitoring loop at the times of a cron expression
+	if cfg.Schedule != "" {
+		schedule, err := monitor.ParseSchedule(cfg.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid --schedule %q: %w", cfg.Schedule, err)
+		}
+		cfg.schedule = schedule
+		cfg.Monitor = true
+	}
+
+	// --limit fans out to several probes per location, compared side by side
+	if cfg.Limit < 0 {
+		return fmt.Errorf("--limit must be >= 0")
+	}
+	if cfg.Limit > 0 {
+		if cfg.From == "" {
+			return fmt.Errorf("--limit requires --from to specify remote locations")
+		}
+		if cfg.Monitor || cfg.Reverse {
+			return fmt.Errorf("--limit cannot be combined with --monitor or --reverse")
+		}
+		if !cfg.Compare {
+			cfg.Compare, cfg.NoLocal = true, true
+		}
+	}
+
+	// --align only makes sense when local and remote traces are compared
+	if cfg.Align && (!cfg.Compare || cfg.NoLocal) {
+		return fmt.Errorf("--align requires --compare with a local trace")
+	}
+
+	// --align-by asn changes the side-by-side tables of compare, reverse and dual-stack runs
+	switch cfg.AlignBy {
+	case "", "ttl":
+	case "asn":
+		if !cfg.Compare && !cfg.Reverse && !cfg.DualStack && !cfg.AllIPs {
+			return fmt.Errorf("--align-by asn requires --compare, --reverse, --dual-stack or --all-ips")
+		}
+	default:
+		return fmt.Errorf("invalid --align-by %q: must be ttl or asn", cfg.AlignBy)
+	}
+
+	// --json only applies to monitor summaries
+	if cfg.JSON && !cfg.Monitor {
+		return fmt.Errorf("--json requires --monitor")
+	}
+
+	// --history-file stores the traces of monitor cycles
+	if cfg.HistoryFile != "" && !cfg.Monitor {
+		return fmt.Errorf("--history-file requires --monitor")
+	}
+
+	// --confirm-anomalies re-traces within monitor cycles
+	if cfg.ConfirmAnomalies && !cfg.Monitor {
+		return fmt.Errorf("--confirm-anomalies requires --monitor")
+	}
+
+	// Stop conditions bound unattended monitoring runs
+	if cfg.Duration != "" {
+		d, err := time.ParseDuration(cfg.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid --duration %q: must be a positive duration (e.g. 10m)", cfg.Duration)
+		}
+		cfg.duration = d
+	}
+	var until []failCondition
+	if cfg.UntilLoss != "" {
+		loss, err := parseLossThreshold(cfg.UntilLoss)
+		if err != nil || loss < 0 || loss >= 100 {
+			return fmt.Errorf("invalid --until-loss %q: must be a percentage below 100", cfg.UntilLoss)
+		}
+		until = append(until, failCondition{kind: "loss", loss: loss})
+	}
+	if cfg.UntilLatency != "" {
+		latency, err := parseLatencyThreshold(cfg.UntilLatency)
+		if err != nil || latency <= 0 {
+			return fmt.Errorf("invalid --until-latency %q: must be a positive duration (e.g. 100ms)", cfg.UntilLatency)
+		}
+		until = append(until, failCondition{kind: "latency", latency: latency})
+	}
+	if (cfg.duration > 0 || len(until) > 0) && !cfg.Monitor {
+		return fmt.Errorf("--duration, --until-loss and --until-latency require --monitor")
+	}
+	if len(until) > 0 {
+		cfg.until = newFailTracker(until)
+		cfg.until.flag = "stopped"
+	}
+
+	// Alert rules, from the config file and --alert, are evaluated by the monitoring loop
+	if len(cfg.Alerts) > 0 && !cfg.Monitor {
+		return fmt.Errorf("--alert requires --monitor")
+	}
+	if cfg.Monitor {
+		rules, err := loadAlertRules(cfg.ConfigFile)
+		if err != nil {
+			return err
+		}
+		thresholds, err := thresholdAlerts(cfg.AlertLatency, cfg.AlertLoss)
+		if err != nil {
+			return err
+		}
+		flagRules, err := parseAlertRules(append(thresholds, cfg.Alerts...))
+		if err != nil {
+			return err
+		}
+		cfg.alertRules = append(rules, flagRules...)
+
+		sinks, err := loadMetricSinks(cfg.ConfigFile)
+		if err != nil {
+			return err
+		}
+		cfg.metricSinks = sinks
+	}
+
+	// --reverse runs its own local + remote pair
+	if cfg.Reverse {
+		if cfg.Compare || cfg.NoLocal {
+			return fmt.Errorf("--reverse cannot be combined with --compare or --no-local")
+		}
+		if cfg.DualStack {
+			return fmt.Errorf("--reverse cannot be combined with --dual-stack")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--reverse accepts a single target")
+		}
+	}
+
+	// -4 and -6 are mutually exclusive
+	if cfg.IPv4Only && cfg.IPv6Only {
+		return fmt.Errorf("-4/--ipv4 and -6/--ipv6 are mutually exclusive")
+	}
+
+	// --dual-stack traces both families locally
+	if cfg.DualStack {
+		if cfg.IPv4Only || cfg.IPv6Only {
+			return fmt.Errorf("--dual-stack cannot be combined with -4/--ipv4 or -6/--ipv6")
+		}
+		if cfg.From != "" {
+			return fmt.Errorf("--dual-stack cannot be combined with --from")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--dual-stack accepts a single target")
+		}
+	}
+
+	// --all-ips traces every address of one hostname locally
+	if cfg.AllIPs {
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.Reverse || cfg.Compare || cfg.Queue || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--all-ips requires a plain local trace (not --from, --monitor, --dual-stack, --reverse, --compare, --queue or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--all-ips accepts a single target")
+		}
+		if net.ParseIP(targets[0]) != nil {
+			return fmt.Errorf("--all-ips requires a hostname, not an IP address")
+		}
+	}
+
+	// --flow-label and --ipv6-ext shape the IPv6 probes of local traces
+	if cfg.FlowLabel != -1 || cfg.IPv6Ext != "" {
+		if cfg.FlowLabel < -1 || cfg.FlowLabel > trace.MaxFlowLabel {
+			return fmt.Errorf("invalid --flow-label %d: must be 0 to %d (0x%X)", cfg.FlowLabel, trace.MaxFlowLabel, trace.MaxFlowLabel)
+		}
+		opts := &trace.IPv6Options{FlowLabel: cfg.FlowLabel}
+		if cfg.IPv6Ext != "" {
+			headers, err := trace.ParseIPv6ExtHeaders(cfg.IPv6Ext)
+			if err != nil {
+				return fmt.Errorf("invalid --ipv6-ext %q: %w", cfg.IPv6Ext, err)
+			}
+			opts.ExtHeaders = headers
+		}
+		if cfg.IPv4Only || cfg.DualStack || cfg.AllIPs {
+			return fmt.Errorf("--flow-label and --ipv6-ext apply to IPv6 probes and cannot be combined with -4/--ipv4, --dual-stack or --all-ips")
+		}
+		if cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--flow-label and --ipv6-ext cannot be combined with a proxy")
+		}
+		for _, t := range targets {
+			if ip := net.ParseIP(t); ip != nil && ip.To4() != nil {
+				return fmt.Errorf("--flow-label and --ipv6-ext require IPv6 targets, not %s", t)
+			}
+		}
+		// Hostnames must resolve to an address the options apply to
+		cfg.IPv6Only = true
+		cfg.ipv6Opts = opts
+	}
+
+	if cfg.Cycles < 0 {
+		return fmt.Errorf("--cycles must be >= 0")
+	}
+	if cfg.ResolveEvery < 0 {
+		return fmt.Errorf("--resolve-every must be >= 0")
+	}
+
+	// --fail-on needs the final results, which the TUI and side-by-side modes don't produce
+	if len(cfg.FailOn) > 0 {
+		conds, err := parseFailOn(cfg.FailOn)
+		if err != nil {
+			return err
+		}
+		if cfg.Compare || cfg.Reverse || cfg.DualStack || cfg.AllIPs || cfg.Queue || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--fail-on cannot be combined with --compare, --reverse, --dual-stack, --all-ips, --queue or a proxy")
+		}
+		if cfg.From == "" && !cfg.Simple && cfg.Output == "" && !cfg.Monitor {
+			return fmt.Errorf("--fail-on requires --simple, --output, --from or --monitor (the interactive TUI has no exit status)")
+		}
+		cfg.failOn = newFailTracker(conds)
+		if cfg.failOn.hasCondition("alert") && !cfg.Monitor {
+			return fmt.Errorf("--fail-on alert requires --monitor")
+		}
+	}
+
+	if cfg.DBAutoUpdate < 0 {
+		return fmt.Errorf("--db-auto-update must be >= 0")
+	}
+	if cfg.History < 1 {
+		return fmt.Errorf("--history must be >= 1")
+	}
+	if cfg.Warmup < 0 {
+		return fmt.Errorf("--warmup must be >= 0")
+	}
+
+	theme, err := loadTheme(cfg.Theme, cfg.ConfigFile)
+	if err != nil {
+		return err
+	}
+	cfg.theme = theme
+
+	// Validate diagnostic flags
+	if cfg.ECMPFlows < 0 {
+		return fmt.Errorf("--ecmp-flows must be >= 0")
+	}
+	if cfg.ProbeSize < 1 {
+		return fmt.Errorf("--probe-size must be >= 1")
+	}
+	if cfg.Shards < 1 {
+		return fmt.Errorf("--shards must be >= 1")
+	}
+	if cfg.Shards > 1 && cfg.Protocol == "icmp" {
+		return fmt.Errorf("--shards requires --protocol udp or tcp")
+	}
+	if cfg.EndToEnd && cfg.Protocol != "tcp" {
+		return fmt.Errorf("--end-to-end requires --protocol tcp")
+	}
+
+	// One scheduler paces every trace of the run, so the limits are global
+	pacing, err := pacingConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if !pacing.IsZero() {
+		pacer, err := trace.NewPacer(pacing)
+		if err != nil {
+			return err
+		}
+		cfg.scheduler = pacer
+	}
+
+	// --geo-validate checks a single enriched local trace as it is printed
+	if cfg.GeoValidate {
+		if cfg.Offline {
+			return fmt.Errorf("--geo-validate needs hop geolocation and cannot be combined with --offline")
+		}
+		if cfg.From != "" || cfg.Monitor || cfg.Reverse || cfg.DualStack || cfg.AllIPs || cfg.Queue || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--geo-validate requires a plain local trace (not --from, --monitor, --reverse, --dual-stack, --all-ips, --queue or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--geo-validate accepts a single target")
+		}
+		cfg.Simple = true
+	}
+
+	// --host-stats samples the outgoing interface while a single local trace is printed
+	if cfg.HostStats {
+		if cfg.From != "" || cfg.Monitor || cfg.Reverse || cfg.DualStack || cfg.AllIPs || cfg.Queue || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--host-stats requires a plain local trace (not --from, --monitor, --reverse, --dual-stack, --all-ips, --queue or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--host-stats accepts a single target")
+		}
+		cfg.Simple = true
+	}
+
+	// --dns replaces the system resolver for the target and rDNS lookups
+	if cfg.DNS != "" {
+		if cfg.Offline {
+			return fmt.Errorf("--dns queries a remote resolver and cannot be combined with --offline")
+		}
+		server, err := dnsdiag.ParseServer(cfg.DNS)
+		if err != nil {
+			return fmt.Errorf("invalid --dns %q: %w", cfg.DNS, err)
+		}
+		cfg.dnsServer = server
+		cfg.resolver = server.Resolver()
+	}
+
+	// --resolve-debug queries the resolvers directly and prints before the trace
+	if cfg.ResolveDebug {
+		if cfg.Offline {
+			return fmt.Errorf("--resolve-debug queries DNS resolvers and cannot be combined with --offline")
+		}
+		if cfg.JSON {
+			return fmt.Errorf("--resolve-debug cannot be combined with --json")
+		}
+	}
+
+	// --otlp sends finished traces to a collector, so it needs their results
+	if cfg.OTLP != "" {
+		u, err := url.Parse(cfg.OTLP)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --otlp %q: must be an http(s) URL", cfg.OTLP)
+		}
+		if cfg.Compare || cfg.Reverse || cfg.DualStack || cfg.Queue || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--otlp cannot be combined with --compare, --reverse, --dual-stack, --queue or a proxy")
+		}
+		if !cfg.Monitor && !cfg.AllIPs {
+			cfg.Simple = true
+		}
+	}
+
+	// --queue replaces the trace with rounds of small and large probe traces
+	if cfg.Queue {
+		if cfg.Protocol != "icmp" && cfg.Protocol != "udp" {
+			return fmt.Errorf("--queue requires --protocol icmp or udp (tcp probes carry no payload, quic probes have a fixed minimum size)")
+		}
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.Reverse || cfg.Compare || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--queue requires a plain local trace (not --from, --monitor, --dual-stack, --reverse, --compare or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--queue accepts a single target")
+		}
+		if cfg.QueueRounds < 1 {
+			return fmt.Errorf("--queue-rounds must be >= 1")
+		}
+		if cfg.QueueSize <= cfg.ProbeSize {
+			return fmt.Errorf("--queue-size must be larger than --probe-size (%d)", cfg.ProbeSize)
+		}
+	}
+
+	// --quic-compare replaces the trace with rounds of QUIC and plain UDP traces
+	if cfg.QUICCompare {
+		if cfg.Protocol != "icmp" && cfg.Protocol != "quic" {
+			return fmt.Errorf("--quic-compare sends quic probes and cannot be combined with --protocol %s", cfg.Protocol)
+		}
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.AllIPs || cfg.Reverse || cfg.Compare || cfg.Queue || cfg.GeoValidate || cfg.OTLP != "" || len(cfg.FailOn) > 0 || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--quic-compare requires a plain local trace (not --from, --monitor, --dual-stack, --all-ips, --reverse, --compare, --queue, --geo-validate, --otlp, --fail-on or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--quic-compare accepts a single target")
+		}
+		if cfg.QUICRounds < 1 {
+			return fmt.Errorf("--quic-rounds must be >= 1")
+		}
+		cfg.Protocol = "quic"
+	}
+
+	// --service-matrix replaces the trace with one trace per protocol and port
+	if cfg.ServiceMatrix {
+		if cfg.Protocol != "icmp" {
+			return fmt.Errorf("--service-matrix takes its protocols from --services and cannot be combined with --protocol %s", cfg.Protocol)
+		}
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.AllIPs || cfg.Reverse || cfg.Compare || cfg.Queue || cfg.QUICCompare || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--service-matrix requires a plain local trace (not --from, --monitor, --dual-stack, --all-ips, --reverse, --compare, --queue, --quic-compare or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--service-matrix accepts a single target")
+		}
+		if len(cfg.Services) < 2 {
+			return fmt.Errorf("--services needs at least two services to compare")
+		}
+		cfg.services = nil
+		for _, s := range cfg.Services {
+			svc, err := trace.ParseService(s)
+			if err != nil {
+				return err
+			}
+			cfg.services = append(cfg.services, svc)
+		}
+	}
+
+	// --diagnose follows a tcp/udp trace that does not reach the target with an ICMP trace
+	if cfg.Diagnose {
+		if cfg.Protocol != "tcp" && cfg.Protocol != "udp" {
+			return fmt.Errorf("--diagnose requires --protocol tcp or udp (it compares them with an icmp trace)")
+		}
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.AllIPs || cfg.Reverse || cfg.Compare || cfg.Queue || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--diagnose requires a plain local trace (not --from, --monitor, --dual-stack, --all-ips, --reverse, --compare, --queue or a proxy)")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--diagnose accepts a single target")
+		}
+		if cfg.Output == "" {
+			cfg.Simple = true
+		}
+	}
+
+	// --record logs the events shown by the interactive MTR view of one target
+	if cfg.Record != "" {
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.AllIPs || cfg.Reverse || cfg.Compare || cfg.Queue || cfg.QUICCompare || cfg.ServiceMatrix || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--record requires a plain local MTR session (not --from, --monitor, --dual-stack, --all-ips, --reverse, --compare, --queue, --quic-compare, --service-matrix or a proxy)")
+		}
+		if cfg.Simple || cfg.Output != "" {
+			return fmt.Errorf("--record records the interactive MTR view and cannot be combined with --simple, --output or options implying them")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--record accepts a single target")
+		}
+	}
+
+	// --via runs the interactive MTR session of one target on a remote host
+	if cfg.ViaSudo && cfg.Via == "" {
+		return fmt.Errorf("--via-sudo requires --via")
+	}
+	if cfg.Via != "" {
+		dest, err := agent.ParseDest(cfg.Via)
+		if err != nil {
+			return fmt.Errorf("invalid --via %q: %w", cfg.Via, err)
+		}
+		cfg.via = &dest
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.AllIPs || cfg.Reverse || cfg.Compare || cfg.Queue || cfg.QUICCompare || cfg.ServiceMatrix || cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+			return fmt.Errorf("--via requires a plain MTR session (not --from, --monitor, --dual-stack, --all-ips, --reverse, --compare, --queue, --quic-compare, --service-matrix or a proxy)")
+		}
+		if cfg.Simple || cfg.Output != "" {
+			return fmt.Errorf("--via streams to the interactive MTR view and cannot be combined with --simple, --output or options implying them")
+		}
+		if cfg.Protocol == "auto" || cfg.PCAP != "" || cfg.NetNS != "" || cfg.IPv6Ext != "" || cfg.FlowLabel >= 0 || cfg.EndToEnd {
+			return fmt.Errorf("--via cannot be combined with --protocol auto, --pcap, --netns, --ipv6-ext, --flow-label or --end-to-end")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--via accepts a single target")
+		}
+	}
+
+	// QUIC probes go to the HTTP/3 port unless --port is given
+	if cfg.Protocol == "quic" && cfg.Port == trace.DefaultConfig().Port {
+		cfg.Port = trace.QUICPort
+	}
+
+	// --via-socks5/--via-ssh replace the trace with proxied connect probes
+	proxied := cfg.ViaSOCKS5 != "" || cfg.ViaSSH != ""
+	if proxied {
+		if cfg.ViaSOCKS5 != "" && cfg.ViaSSH != "" {
+			return fmt.Errorf("--via-socks5 and --via-ssh are mutually exclusive")
+		}
+		if cfg.Protocol != "tcp" {
+			return fmt.Errorf("--via-socks5/--via-ssh require --protocol tcp")
+		}
+		if cfg.From != "" || cfg.Monitor || cfg.DualStack || cfg.Reverse {
+			return fmt.Errorf("--via-socks5/--via-ssh cannot be combined with --from, --monitor, --dual-stack or --reverse")
+		}
+		if len(targets) > 1 {
+			return fmt.Errorf("--via-socks5/--via-ssh accept a single target")
+		}
+		if cfg.ViaSSH != "" {
+			if err := trace.CheckSSHDest(cfg.ViaSSH); err != nil {
+				return fmt.Errorf("invalid --via-ssh: %w", err)
+			}
+		}
+	}
+
+	geo, err := newGeoProvider(cfg.GeoProvider, cfg.GeoDB)
+	if err != nil {
+		return err
+	}
+	cfg.geoProvider = geo
+
+	// Offline mode guarantees no network use besides the trace itself
+	if cfg.Offline && (cfg.BGP || cfg.LookingGlass != "") {
+		return fmt.Errorf("--bgp and --looking-glass cannot be combined with --offline")
+	}
+	if cfg.Offline && (cfg.From != "" || cfg.Reverse) {
+		return fmt.Errorf("--from and --reverse use the GlobalPing API and cannot be combined with --offline")
+	}
+	if cfg.Offline && cfg.DBAutoUpdate > 0 {
+		return fmt.Errorf("--db-auto-update downloads databases and cannot be combined with --offline")
+	}
+	bgp, err := newBGPLookup(cfg.BGP, cfg.LookingGlass)
+	if err != nil {
+		return err
+	}
+	cfg.bgpLookup = bgp
+
+	// Check privileges early for local traces
+	// Skip for: --from only (GlobalPing API), --dry-run, --compare (checked at runtime),
+	// proxied connect probes (plain sockets), --via (probes sent by the remote agent)
+	needsLocalTrace := (cfg.From == "" || cfg.Compare || cfg.Reverse) && !cfg.NoLocal && !proxied && cfg.via == nil
+
+	// --pcap only records packets sent by local tracers
+	if cfg.PCAP != "" && !needsLocalTrace {
+		return fmt.Errorf("--pcap requires a local trace (not available with --from only or --no-local)")
+	}
+	if needsLocalTrace && !cfg.DryRun {
+		if err := trace.CheckPrivileges(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	// Export flags
-	cmd.Flags().StringVarP(&cfg.Output, "output", "o", "", "Export to file (json/csv/txt)")
-	cmd.Flags().StringVar(&cfg.Format, "format", "", "Explicit export format")
+// executeConfig runs the trace described by a prepared cfg.
+func executeConfig(cmd *cobra.Command, cfg *Config) error {
+	cfg.Target = cfg.Targets[0]
 
-	// Other flags
-	cmd.Flags().StringVar(&cfg.APIKey, "api-key", "", "GlobalPing API key")
-	cmd.Flags().BoolVar(&cfg.Offline, "offline", false, "Use only local enrichment DBs")
-	cmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Verbose output")
-	cmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "Validate args without running trace")
+	if cfg.DryRun {
+		// Just validate args and return
+		return nil
+	}
 
-	// Database management flags
-	cmd.Flags().BoolVar(&cfg.DownloadDB, "download-db", false, "Show instructions to download GeoIP databases")
-	cmd.Flags().BoolVar(&cfg.DBStatus, "db-status", false, "Show GeoIP database status")
+	logger, err := logging.New(cmd.ErrOrStderr(), cfg.LogFormat, cfg.Verbose)
+	if err != nil {
+		return err
+	}
+	cfg.logger = logger
 
-	// IP version flags
-	cmd.Flags().BoolVarP(&cfg.IPv4Only, "ipv4", "4", false, "Use IPv4 only")
-	cmd.Flags().BoolVarP(&cfg.IPv6Only, "ipv6", "6", false, "Use IPv6 only")
+	if cfg.DBAutoUpdate > 0 {
+		autoUpdateGeoDatabases(cmd.ErrOrStderr(), cfg)
+	}
 
-	// Advanced diagnostics flags
-	cmd.Flags().BoolVar(&cfg.DetectNAT, "detect-nat", false, "Enable NAT detection via TTL analysis")
-	cmd.Flags().IntVar(&cfg.ECMPFlows, "ecmp-flows", 0, "ECMP flow variations per hop (0=disabled, 8=recommended)")
-	cmd.Flags().BoolVar(&cfg.DiscoverMTU, "discover-mtu", false, "Enable Path MTU Discovery")
-	cmd.Flags().IntVar(&cfg.ProbeSize, "probe-size", 64, "Probe packet size in bytes")
-	cmd.Flags().BoolVarP(&cfg.Decode, "decode", "D", false, "Decode transport headers from ICMP error bodies")
+	if cfg.Offline {
+		reportOfflineGaps(cmd.ErrOrStderr(), cfg.geoProvider)
+	} else {
+		cfg.diskCache = openDiskCache(cmd.ErrOrStderr(), cfg.CacheDir)
+	}
+	cfg.reputation = loadReputation(cmd.Context(), cmd.ErrOrStderr(), cfg)
 
-	return cmd
+	err = runTrace(cmd, cfg)
+	saveDiskCache(cmd.ErrOrStderr(), cfg.diskCache)
+	printUpdateNotification(cmd.ErrOrStderr(), cfg.updateResult)
+	if isExitError(err) {
+		// The trace was printed; only the failed conditions are left to report
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+	}
+	return err
 }
 
-// newGlobalPingClient creates a GlobalPing client with retry notification.
-func newGlobalPingClient(w io.Writer, apiKey string) *globalping.Client {
+// newGlobalPingClient creates a GlobalPing client that logs retries, and
+// once when the remaining quota runs low (info level, shown with --verbose).
+func newGlobalPingClient(log *slog.Logger, apiKey string) *globalping.Client {
 	client := globalping.NewClient(apiKey)
 	client.SetRetryCallback(func(attempt int, delay time.Duration) {
-		fmt.Fprintf(w, "Rate limited by GlobalPing API. Retrying in %v (attempt %d/3)...\n", delay, attempt)
+		log.Warn("rate limited by GlobalPing API, retrying", "attempt", attempt, "max_attempts", 3, "retry_in", delay)
+	})
+	var warnOnce sync.Once
+	client.SetRateLimitCallback(func(info globalping.RateLimitInfo) {
+		if info.IsLow() {
+			warnOnce.Do(func() {
+				log.Info("GlobalPing quota running low, run 'gtrace limits' for details",
+					"remaining", info.Remaining, "limit", info.Limit, "resets_in", info.Reset)
+			})
+		}
 	})
 	return client
 }
@@ -300,14 +1163,77 @@ func runTrace(cmd *cobra.Command, cfg *Config) error {
 		cancel()
 	}()
 
+	// Tag every log message of this run with one ID
+	ctx = logging.NewContext(ctx, cfg.logger.With(logging.TraceIDKey, logging.NewID()))
+
+	// Pick a rendering profile the terminal can display, in the configured colors
+	applyRenderProfile(cmd.ErrOrStderr(), cfg)
+	display.ApplyTheme(cfg.theme)
+	trace.SetResolver(cfg.resolver)
+
+	if cfg.ResolveDebug {
+		writeResolveDebug(ctx, cmd.OutOrStdout(), cfg)
+	}
+
+	// Open packet capture before any tracer is created
+	if cfg.PCAP != "" {
+		pw, err := trace.NewPcapWriter(cfg.PCAP)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			pw.Close()
+			fmt.Fprintf(cmd.ErrOrStderr(), "Packets captured to %s\n", cfg.PCAP)
+		}()
+		cfg.capture = pw
+	}
+
 	// Use monitoring mode if --monitor is set
 	if cfg.Monitor {
 		err := runMonitor(ctx, cmd, cfg)
 		if err != nil && ctx.Err() != nil {
 			fmt.Fprintln(cmd.OutOrStdout(), "\nMonitoring stopped")
-			return nil
+			err = nil
 		}
-		return err
+		if err != nil {
+			return err
+		}
+		return cfg.failOn.check()
+	}
+
+	// Dual-stack mode: run IPv4 and IPv6 traces concurrently
+	if cfg.DualStack {
+		return runDualStackMode(ctx, cmd, cfg)
+	}
+
+	// All-addresses mode: trace every A/AAAA record of the target and compare
+	if cfg.AllIPs {
+		return runAllIPsMode(ctx, cmd, cfg)
+	}
+
+	// Queueing mode: small and large probe traces compared hop by hop
+	if cfg.Queue {
+		return runQueueMode(ctx, cmd, cfg)
+	}
+
+	// QUIC comparison mode: QUIC and plain UDP traces compared hop by hop
+	if cfg.QUICCompare {
+		return runQUICCompareMode(ctx, cmd, cfg)
+	}
+
+	// Service matrix mode: one trace per protocol and port, compared hop by hop
+	if cfg.ServiceMatrix {
+		return runServiceMatrixMode(ctx, cmd, cfg)
+	}
+
+	// Proxied mode: TCP connect probes through a SOCKS5 proxy or SSH bastion
+	if cfg.ViaSOCKS5 != "" || cfg.ViaSSH != "" {
+		return runProxyMode(ctx, cmd, cfg)
+	}
+
+	// Reverse mode: forward local trace vs remote trace back to us
+	if cfg.Reverse {
+		return runReverseMode(ctx, cmd, cfg)
 	}
 
 	// Compare mode: run local and remote traces concurrently
@@ -318,9 +1244,24 @@ func runTrace(cmd *cobra.Command, cfg *Config) error {
 	var result *hop.TraceResult
 	var err error
 
-	// Use GlobalPing if --from is specified
+	// Multiple targets without the TUI: trace concurrently, export combined
+	if len(cfg.Targets) > 1 && cfg.From == "" && (cfg.Simple || cfg.Output != "") {
+		err := runMultiTargetSimple(ctx, cmd, cfg)
+		if err != nil && ctx.Err() != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), "\nTrace interrupted")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return cfg.failOn.check()
+	}
+
+	// Use GlobalPing if --from is specified, the remote agent if --via is
 	if cfg.From != "" {
 		result, err = runGlobalPingTrace(ctx, cmd, cfg)
+	} else if cfg.via != nil {
+		result, err = runAgentMTR(ctx, cmd, cfg)
 	} else {
 		result, err = runLocalTrace(ctx, cmd, cfg)
 	}
@@ -341,10 +1282,131 @@ func runTrace(cmd *cobra.Command, cfg *Config) error {
 		}
 		fmt.Fprintf(cmd.OutOrStdout(), "Results exported to %s\n", cfg.Output)
 	}
+	sendOTLP(ctx, cfg, result)
+
+	if cfg.Diagnose && !result.ReachedTarget {
+		if err := runFilterDiagnosis(ctx, cmd, cfg, result); err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), "\nDiagnosis interrupted")
+				return nil
+			}
+			return err
+		}
+	}
+
+	cfg.failOn.observe(result)
+	return cfg.failOn.check()
+}
+
+// sendOTLP sends results to the --otlp collector, if any. A failed export is
+// logged rather than failing the trace.
+func sendOTLP(ctx context.Context, cfg *Config, results ...*hop.TraceResult) {
+	if cfg.OTLP == "" {
+		return
+	}
+	if err := export.SendOTLP(ctx, cfg.OTLP, results); err != nil {
+		logging.FromContext(ctx).Warn("OTLP export failed", "endpoint", cfg.OTLP, "err", err)
+	}
+}
+
+// writeResolveDebug prints the DNS resolution breakdown of every hostname
+// target, from the --dns server or the system resolvers. Failures are
+// reported and the trace goes on: the system resolver may still answer
+// through its own configuration.
+func writeResolveDebug(ctx context.Context, w io.Writer, cfg *Config) {
+	servers := dnsdiag.SystemResolvers(dnsdiag.ResolvConfPath)
+	if cfg.dnsServer != nil {
+		servers = []*dnsdiag.Server{cfg.dnsServer}
+	}
+	for _, target := range cfg.Targets {
+		if net.ParseIP(target) != nil {
+			continue
+		}
+		report, err := dnsdiag.Diagnose(ctx, target, servers)
+		if report != nil {
+			report.WriteReport(w)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "  %v\n", err)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// downloadGeoDatabases downloads the default GeoIP databases, or only dbs
+// when given, and reports each result to w.
+func downloadGeoDatabases(w io.Writer, licenseKey string, dbs []string) error {
+	dlCfg := enrich.DefaultDownloadConfig()
+	dlCfg.LicenseKey = licenseKey
+	if dbs != nil {
+		dlCfg.Databases = dbs
+	}
+
+	fmt.Fprintf(w, "Downloading GeoIP databases to %s...\n", dlCfg.DataDir)
+	results, err := enrich.DownloadDatabases(dlCfg)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Success {
+			fmt.Fprintf(w, "  %s: installed (%d bytes)\n", r.Database, r.Size)
+		} else {
+			fmt.Fprintf(w, "  %s: failed: %v\n", r.Database, r.Error)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d database downloads failed", failed, len(results))
+	}
+	return nil
+}
 
+// updateIXPrefixes downloads the PeeringDB IXP prefix list to the data directory.
+func updateIXPrefixes(ctx context.Context, w io.Writer) error {
+	path := enrich.DefaultPeeringDBPath()
+	if path == "" {
+		return fmt.Errorf("failed to locate the data directory")
+	}
+	fmt.Fprintf(w, "Downloading IXP prefixes from PeeringDB to %s...\n", path)
+	n, err := enrich.UpdatePeeringDB(ctx, path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "  %d IX prefixes installed\n", n)
 	return nil
 }
 
+// autoUpdateGeoDatabases refreshes installed GeoIP databases and PeeringDB
+// IX prefixes older than cfg.DBAutoUpdate days. Failures are reported but do
+// not stop the trace.
+func autoUpdateGeoDatabases(w io.Writer, cfg *Config) {
+	dlCfg := enrich.DefaultDownloadConfig()
+	maxAge := time.Duration(cfg.DBAutoUpdate) * 24 * time.Hour
+
+	// PeeringDB needs no account, so it is refreshed even without a license key
+	if len(enrich.StaleDatabases(dlCfg.DataDir, []string{enrich.PeeringDBFile}, maxAge)) > 0 {
+		if err := updateIXPrefixes(context.Background(), w); err != nil {
+			cfg.logger.Warn("IX prefix update failed", "err", err)
+		}
+	}
+
+	stale := enrich.StaleDatabases(dlCfg.DataDir, dlCfg.Databases, maxAge)
+	if len(stale) == 0 {
+		return
+	}
+
+	licenseKey := enrich.ResolveLicenseKey(cfg.LicenseKey)
+	if licenseKey == "" {
+		cfg.logger.Warn("GeoIP databases are outdated but no MaxMind license key is set", "max_age_days", cfg.DBAutoUpdate)
+		return
+	}
+	if err := downloadGeoDatabases(w, licenseKey, stale); err != nil {
+		cfg.logger.Warn("GeoIP database update failed", "err", err)
+	}
+}
+
 // runLocalTrace runs a local traceroute.
 func runLocalTrace(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.TraceResult, error) {
 	// Parse timeout
@@ -359,8 +1421,15 @@ func runLocalTrace(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.T
 		return nil, fmt.Errorf("failed to resolve target: %w", err)
 	}
 
-	// Create enricher (unless offline mode)
-	enricher := newEnricher(cfg.Offline)
+	// A listed target is worth knowing before reading the hops
+	if !enrich.IsPrivateIP(targetIP) {
+		for _, l := range cfg.reputation.Lookup(targetIP) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: target %s is on %s (%s)\n", targetIP, l.List, l.Category)
+		}
+	}
+
+	// Create enricher (local databases only in offline mode)
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
 
 	// Use single-shot mode for --simple or when exporting
 	if cfg.Simple || cfg.Output != "" {
@@ -376,6 +1445,14 @@ func runLocalTrace(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.T
 			DiscoverMTU:   cfg.DiscoverMTU,
 			ProbeSize:     cfg.ProbeSize,
 			Decode:        cfg.Decode,
+			Capture:       cfg.capture,
+			Shards:        cfg.Shards,
+			Scheduler:     cfg.scheduler,
+			NetNS:         cfg.NetNS,
+			IPv6:          cfg.ipv6Opts,
+			Parallel:      !cfg.Sequential,
+			EndToEnd:      cfg.EndToEnd,
+			ServerName:    cfg.Target,
 		}
 
 		// Create tracer
@@ -396,6 +1473,15 @@ func runLocalTrace(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.T
 	return runLocalTraceMTR(ctx, cmd, cfg, enricher, targetIP, timeout)
 }
 
+// mtrPacketsPerHop returns the probes per hop and cycle of MTR mode: one,
+// unless --packets is given.
+func mtrPacketsPerHop(cfg *Config) int {
+	if !cfg.packetsSet {
+		return 1
+	}
+	return cfg.Packets
+}
+
 // runLocalTraceMTR runs a continuous MTR-style trace with the TUI.
 func runLocalTraceMTR(ctx context.Context, cmd *cobra.Command, cfg *Config, enricher enrich.EnricherInterface, targetIP net.IP, timeout time.Duration) (*hop.TraceResult, error) {
 	// Parse interval
@@ -404,11 +1490,12 @@ func runLocalTraceMTR(ctx context.Context, cmd *cobra.Command, cfg *Config, enri
 		return nil, fmt.Errorf("invalid interval: %w", err)
 	}
 
-	// Create trace config for MTR mode (1 packet per hop for faster cycles)
+	// Create trace config for MTR mode: each trace probes every hop once,
+	// --packets runs several traces per cycle
 	traceCfg := &trace.Config{
 		Protocol:      trace.Protocol(cfg.Protocol),
 		MaxHops:       cfg.MaxHops,
-		PacketsPerHop: 1, // MTR-style: 1 probe per hop per cycle
+		PacketsPerHop: 1,
 		Timeout:       timeout,
 		Port:          cfg.Port,
 		DetectNAT:     cfg.DetectNAT,
@@ -416,98 +1503,244 @@ func runLocalTraceMTR(ctx context.Context, cmd *cobra.Command, cfg *Config, enri
 		DiscoverMTU:   cfg.DiscoverMTU,
 		ProbeSize:     cfg.ProbeSize,
 		Decode:        cfg.Decode,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
+		EndToEnd:      cfg.EndToEnd,
+		ServerName:    cfg.Target,
+	}
+
+	// --record logs what the TUI is sent for gtrace replay
+	var rec *session.Recorder
+	if cfg.Record != "" {
+		rec, err = session.Create(cfg.Record, session.Header{
+			Target:   cfg.Target,
+			TargetIP: targetIP.String(),
+			Protocol: cfg.Protocol,
+			Interval: interval,
+			History:  cfg.History,
+			Warmup:   cfg.Warmup,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := rec.Close(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+				return
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Session recorded to %s (replay with: gtrace replay %s)\n", cfg.Record, cfg.Record)
+		}()
 	}
 
-	// Create tracer
-	tracer, err := trace.NewLocalTracer(traceCfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create tracer: %w", err)
-	}
-
-	// Create continuous tracer
-	ct := trace.NewContinuousTracer(traceCfg, tracer, interval)
-
 	// Create channels for TUI communication
 	resultChan := make(chan display.ProbeResultMsg, 100)
 	cycleChan := make(chan display.CycleCompleteMsg, 10)
 	doneChan := make(chan struct{})
 	resetChan := make(chan struct{}, 1)
 
+	// Hostnames of slow PTR zones are filled in once resolved instead of
+	// holding up the probes
+	enrichChan := make(chan display.EnrichmentMsg, 100)
+	if e, ok := enricher.(*enrich.Enricher); ok {
+		e.SetHostnameCallback(func(ip net.IP, enrichment hop.Enrichment) {
+			msg := display.EnrichmentMsg{IP: ip, Enrichment: enrichment}
+			rec.Enrichment(msg)
+			select {
+			case enrichChan <- msg:
+			case <-ctx.Done():
+			}
+		})
+	}
+
 	// Track enriched IPs to avoid re-enriching
 	enrichedIPs := make(map[string]bool)
 	var enrichMu sync.Mutex
 
-	// Run continuous tracer in background
-	go func() {
-		defer close(resultChan)
-		defer close(cycleChan)
+	// Each target is traced under its own context, so that the 't' key of
+	// the TUI can stop tracing it and start tracing another one
+	var (
+		runMu   sync.Mutex
+		ct      *trace.ContinuousTracer
+		stopRun context.CancelFunc
+		runDone chan struct{}
+		paused  bool
+	)
+	probeSize := traceCfg.ProbeSize
+	// startRun starts tracing ip. Must be called with runMu held.
+	startRun := func(runCfg *trace.Config, tracer trace.Tracer, ip net.IP) {
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		run := trace.NewContinuousTracer(runCfg, tracer, interval)
+		run.SetPacketsPerHop(mtrPacketsPerHop(cfg))
+		run.SetAdaptiveMaxHops(true)
+		run.SetPaused(paused)
+		ct, stopRun, runDone = run, cancel, done
+
+		// Run continuous tracer in background
+		go func() {
+			defer close(done)
+
+			probeCallback := func(pr trace.ProbeResult) {
+				// Drain reset signal if present
+				select {
+				case <-resetChan:
+					enrichMu.Lock()
+					enrichedIPs = make(map[string]bool)
+					enrichMu.Unlock()
+				default:
+				}
 
-		probeCallback := func(pr trace.ProbeResult) {
-			// Drain reset signal if present
-			select {
-			case <-resetChan:
-				enrichMu.Lock()
-				enrichedIPs = make(map[string]bool)
-				enrichMu.Unlock()
-			default:
-			}
+				msg := display.ProbeResultMsg{
+					TTL:            pr.TTL,
+					IP:             pr.IP,
+					RTT:            pr.RTT,
+					Timeout:        pr.Timeout,
+					MPLS:           pr.MPLS,
+					ICMPType:       pr.ICMPType,
+					ICMPCode:       pr.ICMPCode,
+					OriginalTTL:    pr.OriginalTTL,
+					FlowID:         pr.FlowID,
+					TransportInfo:  pr.TransportInfo,
+					NAT:            pr.NAT,
+					MTU:            pr.MTU,
+					SegmentRouting: pr.SegmentRouting,
+				}
+
+				// Enrich first occurrence of each IP
+				if pr.IP != nil && enricher != nil {
+					ipStr := pr.IP.String()
+					enrichMu.Lock()
+					needsEnrich := !enrichedIPs[ipStr]
+					if needsEnrich {
+						enrichedIPs[ipStr] = true
+					}
+					enrichMu.Unlock()
+
+					if needsEnrich {
+						// Create a temporary hop to get enrichment
+						h := hop.NewHop(pr.TTL)
+						h.AddProbe(pr.IP, pr.RTT)
+						enricher.EnrichHop(runCtx, h)
+						msg.Enrichment = h.Enrichment
+					}
+				}
 
-			msg := display.ProbeResultMsg{
-				TTL:           pr.TTL,
-				IP:            pr.IP,
-				RTT:           pr.RTT,
-				Timeout:       pr.Timeout,
-				MPLS:          pr.MPLS,
-				ICMPType:      pr.ICMPType,
-				ICMPCode:      pr.ICMPCode,
-				OriginalTTL:   pr.OriginalTTL,
-				FlowID:        pr.FlowID,
-				TransportInfo: pr.TransportInfo,
+				rec.Probe(msg)
+				select {
+				case resultChan <- msg:
+				case <-runCtx.Done():
+				}
 			}
 
-			// Enrich first occurrence of each IP
-			if pr.IP != nil && enricher != nil {
-				ipStr := pr.IP.String()
-				enrichMu.Lock()
-				needsEnrich := !enrichedIPs[ipStr]
-				if needsEnrich {
-					enrichedIPs[ipStr] = true
+			cycleCallback := func(cycle int, reached bool) {
+				msg := display.CycleCompleteMsg{Cycle: cycle, Reached: reached}
+				rec.Cycle(msg)
+				select {
+				case cycleChan <- msg:
+				case <-runCtx.Done():
 				}
-				enrichMu.Unlock()
 
-				if needsEnrich {
-					// Create a temporary hop to get enrichment
-					h := hop.NewHop(pr.TTL)
-					h.AddProbe(pr.IP, pr.RTT)
-					enricher.EnrichHop(ctx, h)
-					msg.Enrichment = h.Enrichment
+				// Check if we've reached the cycle limit
+				if cfg.Cycles > 0 && cycle >= cfg.Cycles+cfg.Warmup {
+					// Signal done via context cancellation
+					return
 				}
 			}
 
-			select {
-			case resultChan <- msg:
-			case <-ctx.Done():
-			}
+			// Discard cycles spanning a laptop sleep or a network switch
+			// instead of recording them as 100% loss
+			run.SetNetworkWatcher(netwatch.New(ip), func(cycle int, ev netwatch.Event) {
+				msg := display.CycleCompleteMsg{Cycle: cycle, Invalid: true, Event: ev.String()}
+				rec.Cycle(msg)
+				select {
+				case cycleChan <- msg:
+				case <-runCtx.Done():
+				}
+			})
+
+			run.Run(runCtx, ip, probeCallback, cycleCallback)
+		}()
+	}
+
+	// newTracer creates the tracer of target. Must be called with runMu held.
+	newTracer := func(target string) (*trace.Config, trace.Tracer, error) {
+		runCfg := *traceCfg
+		runCfg.ServerName = target
+		runCfg.ProbeSize = probeSize
+		tracer, err := trace.NewLocalTracer(&runCfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create tracer: %w", err)
 		}
+		return &runCfg, tracer, nil
+	}
+	runMu.Lock()
+	runCfg, tracer, err := newTracer(cfg.Target)
+	if err == nil {
+		startRun(runCfg, tracer, targetIP)
+	}
+	runMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
 
-		cycleCallback := func(cycle int, reached bool) {
-			select {
-			case cycleChan <- display.CycleCompleteMsg{Cycle: cycle, Reached: reached}:
-			case <-ctx.Done():
-			}
+	// The settings changed from the TUI carry over to the next target
+	controls := display.MTRControls{
+		Pause: func(p bool) {
+			runMu.Lock()
+			defer runMu.Unlock()
+			paused = p
+			ct.SetPaused(p)
+		},
+		SetInterval: func(d time.Duration) {
+			runMu.Lock()
+			defer runMu.Unlock()
+			interval = d
+			ct.SetInterval(d)
+		},
+		Interval: interval,
+		SetProbeSize: func(size int) {
+			runMu.Lock()
+			defer runMu.Unlock()
+			probeSize = size
+			ct.SetProbeSize(size)
+		},
+		ProbeSize: probeSize,
+	}
 
-			// Check if we've reached the cycle limit
-			if cfg.Cycles > 0 && cycle >= cfg.Cycles {
-				// Signal done via context cancellation
-				return
-			}
+	// Retarget stops tracing the current target and starts tracing target.
+	// The current target keeps being traced when target does not resolve.
+	controls.Retarget = func(target string) (string, error) {
+		ip, err := trace.ResolveTarget(target, getAddressFamily(cfg))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve target: %w", err)
+		}
+		runMu.Lock()
+		defer runMu.Unlock()
+		runCfg, tracer, err := newTracer(target)
+		if err != nil {
+			return "", err
 		}
+		stopRun()
+		<-runDone
 
-		ct.Run(ctx, targetIP, probeCallback, cycleCallback)
-	}()
+		// The TUI reads the channels in the goroutine calling retarget, so
+		// what the old target left in them can be dropped without a race
+		for len(resultChan) > 0 {
+			<-resultChan
+		}
+		for len(cycleChan) > 0 {
+			<-cycleChan
+		}
+		rec.Target(target, ip.String())
+		startRun(runCfg, tracer, ip)
+		return ip.String(), nil
+	}
 
 	// Run MTR TUI (blocks until user quits)
-	if err := display.RunMTR(cfg.Target, targetIP.String(), resultChan, cycleChan, doneChan, resetChan); err != nil {
+	if err := display.RunMTR(cmd.OutOrStdout(), cfg.Target, targetIP.String(), cfg.History, cfg.Warmup, resultChan, cycleChan, enrichChan, doneChan, resetChan, controls); err != nil {
 		return nil, fmt.Errorf("TUI error: %w", err)
 	}
 
@@ -548,6 +1781,11 @@ func runLocalTraceMultiMTR(ctx context.Context, cmd *cobra.Command, cfg *Config,
 		DiscoverMTU:   cfg.DiscoverMTU,
 		ProbeSize:     cfg.ProbeSize,
 		Decode:        cfg.Decode,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
 	}
 
 	tracers := make([]trace.Tracer, len(targets))
@@ -581,6 +1819,8 @@ func runLocalTraceMultiMTR(ctx context.Context, cmd *cobra.Command, cfg *Config,
 
 	// Create multi-tracer
 	mct := trace.NewMultiContinuousTracer(traceCfg, tracers, targets, interval)
+	mct.SetPacketsPerHop(mtrPacketsPerHop(cfg))
+	mct.SetAdaptiveMaxHops(true)
 
 	// Run in background
 	go func() {
@@ -595,16 +1835,19 @@ func runLocalTraceMultiMTR(ctx context.Context, cmd *cobra.Command, cfg *Config,
 			msg := display.MultiProbeResultMsg{
 				TargetIndex: targetIndex,
 				Probe: display.ProbeResultMsg{
-					TTL:           pr.TTL,
-					IP:            pr.IP,
-					RTT:           pr.RTT,
-					Timeout:       pr.Timeout,
-					MPLS:          pr.MPLS,
-					ICMPType:      pr.ICMPType,
-					ICMPCode:      pr.ICMPCode,
-					OriginalTTL:   pr.OriginalTTL,
-					FlowID:        pr.FlowID,
-					TransportInfo: pr.TransportInfo,
+					TTL:            pr.TTL,
+					IP:             pr.IP,
+					RTT:            pr.RTT,
+					Timeout:        pr.Timeout,
+					MPLS:           pr.MPLS,
+					ICMPType:       pr.ICMPType,
+					ICMPCode:       pr.ICMPCode,
+					OriginalTTL:    pr.OriginalTTL,
+					FlowID:         pr.FlowID,
+					TransportInfo:  pr.TransportInfo,
+					NAT:            pr.NAT,
+					MTU:            pr.MTU,
+					SegmentRouting: pr.SegmentRouting,
 				},
 			}
 
@@ -647,13 +1890,99 @@ func runLocalTraceMultiMTR(ctx context.Context, cmd *cobra.Command, cfg *Config,
 	}()
 
 	// Run split-pane TUI
-	if err := display.RunSplitMTR(targetNames, targetIPStrs, resultChans, cycleChans, doneChan); err != nil {
+	if err := display.RunSplitMTR(cmd.OutOrStdout(), targetNames, targetIPStrs, cfg.History, cfg.Warmup, resultChans, cycleChans, doneChan, display.MTRControls{
+		Pause:        mct.SetPaused,
+		SetInterval:  mct.SetInterval,
+		Interval:     interval,
+		SetProbeSize: mct.SetProbeSize,
+		ProbeSize:    traceCfg.ProbeSize,
+	}); err != nil {
 		return nil, fmt.Errorf("TUI error: %w", err)
 	}
 
 	return nil, nil
 }
 
+// applyRenderProfile selects ASCII/basic-color rendering when forced with --ascii
+// or when the terminal lacks UTF-8 or 256-color support.
+func applyRenderProfile(w io.Writer, cfg *Config) {
+	if cfg.ASCII {
+		display.ApplyRenderProfile(display.RenderProfile{ASCII: true, BasicColor: true})
+		return
+	}
+
+	profile := display.DetectRenderProfile(os.Getenv)
+	if profile.ASCII && term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintln(w, "Terminal does not advertise UTF-8 support, using ASCII rendering (set LANG to a UTF-8 locale to change)")
+	}
+	display.ApplyRenderProfile(profile)
+}
+
+// runMultiTargetSimple traces every target concurrently, prints each result
+// with the simple renderer and exports all of them to a single file.
+func runMultiTargetSimple(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	w := cmd.OutOrStdout()
+	results := make([]*hop.TraceResult, len(cfg.Targets))
+	errs := make([]error, len(cfg.Targets))
+
+	fmt.Fprintf(w, "Tracing %d targets concurrently...\n", len(cfg.Targets))
+
+	var wg sync.WaitGroup
+	for i, target := range cfg.Targets {
+		wg.Add(1)
+		go func(idx int, target string) {
+			defer wg.Done()
+			targetIP, err := trace.ResolveTarget(target, getAddressFamily(cfg))
+			if err != nil {
+				errs[idx] = fmt.Errorf("failed to resolve target: %w", err)
+				return
+			}
+			results[idx], errs[idx] = runLocalTraceToIP(ctx, cfg, targetIP)
+		}(i, target)
+	}
+	wg.Wait()
+
+	renderer := display.NewSimpleRenderer()
+	renderer.ShowDecode = cfg.Decode
+	renderer.ShowHints = cfg.Verbose
+
+	var completed []*hop.TraceResult
+	for i, target := range cfg.Targets {
+		fmt.Fprintln(w)
+		if errs[i] != nil {
+			fmt.Fprintf(w, "%s: %v\n", target, errs[i])
+			continue
+		}
+		result := results[i]
+		result.Target = target
+		completed = append(completed, result)
+		cfg.failOn.observe(result)
+
+		fmt.Fprintf(w, "traceroute to %s (%s), %d hops max, %s protocol\n",
+			target, result.TargetIP, cfg.MaxHops, cfg.Protocol)
+		for _, h := range result.Hops {
+			fmt.Fprintln(w, renderer.RenderHop(h))
+		}
+		fmt.Fprint(w, formatGatewayDiagnosis(trace.DiagnoseGateway(result)))
+		display.RenderASLatency(w, result)
+	}
+
+	if len(completed) == 0 {
+		return fmt.Errorf("all %d traces failed", len(cfg.Targets))
+	}
+
+	if cfg.Output != "" {
+		format := export.Format(cfg.Format)
+		if err := export.ExportAllToFile(cfg.Output, format, completed); err != nil {
+			return fmt.Errorf("failed to export: %w", err)
+		}
+		fmt.Fprintf(w, "\nResults exported to %s\n", cfg.Output)
+	}
+	sendOTLP(ctx, cfg, completed...)
+
+	return nil
+}
+
 // runLocalTraceWithTUI runs a trace with the interactive TUI display (legacy single-shot).
 func runLocalTraceWithTUI(ctx context.Context, cmd *cobra.Command, cfg *Config, tracer trace.Tracer, enricher enrich.EnricherInterface, targetIP net.IP) (*hop.TraceResult, error) {
 	hopChan := make(chan *hop.Hop, 100)
@@ -701,10 +2030,21 @@ func runLocalTraceSimple(ctx context.Context, cmd *cobra.Command, cfg *Config, t
 	// Create renderer
 	renderer := display.NewSimpleRenderer()
 	renderer.ShowDecode = cfg.Decode
+	renderer.ShowHints = cfg.Verbose
+
+	var geo *geocheck.Checker
+	if cfg.GeoValidate {
+		geo = geocheck.NewChecker()
+	}
+	var host *hoststats.Sampler
+	if cfg.HostStats {
+		host = hoststats.NewSampler(netwatch.CurrentState(targetIP).Interface)
+	}
 
 	// Print header
 	fmt.Fprintf(cmd.OutOrStdout(), "traceroute to %s (%s), %d hops max, %s protocol\n",
 		cfg.Target, targetIP, cfg.MaxHops, cfg.Protocol)
+	route := showRoute(cmd.OutOrStdout(), cmd.ErrOrStderr(), cfg, targetIP)
 
 	// Run trace with real-time output
 	callback := func(h *hop.Hop) {
@@ -712,6 +2052,12 @@ func runLocalTraceSimple(ctx context.Context, cmd *cobra.Command, cfg *Config, t
 		if enricher != nil {
 			enricher.EnrichHop(ctx, h)
 		}
+		if geo != nil {
+			geo.Check(h)
+		}
+		if host != nil {
+			host.Observe(h)
+		}
 		fmt.Fprintln(cmd.OutOrStdout(), renderer.RenderHop(h))
 	}
 
@@ -719,22 +2065,47 @@ func runLocalTraceSimple(ctx context.Context, cmd *cobra.Command, cfg *Config, t
 	if err != nil {
 		return nil, fmt.Errorf("trace failed: %w", err)
 	}
+	result.Route = route
+	result.Overlay = trace.DetectOverlay(result)
 
 	// Print summary
 	if result.ReachedTarget {
 		fmt.Fprintf(cmd.OutOrStdout(), "\nTrace complete: reached %s in %d hops\n",
 			cfg.Target, result.TotalHops())
+		if n := len(result.Hops); n > 0 {
+			if hs, ok := result.Hops[n-1].AvgHandshake(); ok {
+				fmt.Fprintln(cmd.OutOrStdout(), formatHandshake(hs))
+			}
+		}
+		if fp := result.TargetFingerprint(); fp != nil {
+			fmt.Fprintln(cmd.OutOrStdout(), formatFingerprint(fp, targetIP.To4() == nil))
+		}
 	} else {
 		fmt.Fprintf(cmd.OutOrStdout(), "\nTrace complete: %d hops (target not reached)\n",
 			result.TotalHops())
 	}
+	fmt.Fprint(cmd.OutOrStdout(), formatGatewayDiagnosis(trace.DiagnoseGateway(result)))
+	fmt.Fprint(cmd.OutOrStdout(), formatOverlay(result.Overlay))
+	display.RenderASLatency(cmd.OutOrStdout(), result)
+
+	if geo != nil {
+		geo.WriteReport(cmd.OutOrStdout())
+	}
+	if host != nil {
+		host.WriteReport(cmd.OutOrStdout())
+	}
 
 	return result, nil
 }
 
 // runGlobalPingTrace runs a traceroute via GlobalPing API.
-// Uses MTR when not in simple mode for richer statistics.
+// Uses MTR when not in simple mode for richer statistics, live in the TUI
+// when measuring from a single probe.
 func runGlobalPingTrace(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.TraceResult, error) {
+	if liveRemoteMTR(cfg, isTerminal(cmd.OutOrStdout())) {
+		return runGlobalPingLiveMTR(ctx, cmd, cfg)
+	}
+
 	// Use MTR for richer output when not in simple mode
 	if !cfg.Simple {
 		return runGlobalPingMTR(ctx, cmd, cfg)
@@ -746,10 +2117,10 @@ func runGlobalPingTrace(ctx context.Context, cmd *cobra.Command, cfg *Config) (*
 // runGlobalPingTraceroute runs a simple traceroute via GlobalPing API.
 func runGlobalPingTraceroute(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.TraceResult, error) {
 	// Create client with retry notification
-	client := newGlobalPingClient(cmd.OutOrStdout(), cfg.APIKey)
+	client := newGlobalPingClient(logging.FromContext(ctx), cfg.APIKey)
 
 	// Parse locations
-	locations := globalping.ParseLocationStrings(cfg.From)
+	locations := fromLocations(cfg)
 
 	// Create measurement request
 	req := &globalping.MeasurementRequest{
@@ -776,46 +2147,87 @@ func runGlobalPingTraceroute(ctx context.Context, cmd *cobra.Command, cfg *Confi
 	fmt.Fprintf(cmd.OutOrStdout(), "Measurement ID: %s (%d probes)\n", resp.ID, resp.ProbesCount)
 	fmt.Fprintln(cmd.OutOrStdout(), "Waiting for results...")
 
-	// Wait for completion
-	measurement, err := client.WaitForMeasurement(ctx, resp.ID)
+	// Create renderer
+	renderer := display.NewSimpleRenderer()
+	renderer.ShowDecode = cfg.Decode
+	renderer.ShowHints = cfg.Verbose
+
+	// Stream hops as partial results arrive, then flush what remains
+	streamer := &hopStreamer{w: cmd.OutOrStdout(), target: cfg.Target, renderer: renderer}
+	measurement, err := client.WaitForMeasurementProgress(ctx, resp.ID, func(m *globalping.MeasurementResult) {
+		streamer.update(m.Results, false)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get results: %w", err)
 	}
+	streamer.update(measurement.Results, true)
 
-	// Create renderer
-	renderer := display.NewSimpleRenderer()
-	renderer.ShowDecode = cfg.Decode
+	return streamer.last, nil
+}
 
-	// Display results from each probe
-	var lastResult *hop.TraceResult
-	for _, pr := range measurement.Results {
-		result := pr.ToTraceResult(cfg.Target)
-		lastResult = result
+// hopStreamer prints GlobalPing traceroute hops as partial results arrive.
+// Probes are streamed one at a time in result order so their output does not
+// interleave. A hop is printed once a later hop exists or its probe finished,
+// so its timings are complete.
+type hopStreamer struct {
+	w        io.Writer
+	target   string
+	renderer *display.SimpleRenderer
+
+	probe   int              // Index of the probe being streamed
+	printed int              // Hops already printed for that probe
+	started bool             // Whether that probe's header was printed
+	last    *hop.TraceResult // Last fully printed probe result
+}
 
-		fmt.Fprintf(cmd.OutOrStdout(), "\n=== From %s ===\n", result.Source)
-		fmt.Fprintf(cmd.OutOrStdout(), "Target: %s (%s)\n\n", cfg.Target, result.TargetIP)
+// update prints newly completed hops. When final is true every probe is
+// treated as finished.
+func (s *hopStreamer) update(results []globalping.ProbeResult, final bool) {
+	for s.probe < len(results) {
+		pr := &results[s.probe]
+		result := pr.ToTraceResult(s.target)
+		finished := final || (pr.Status() != "" && pr.Status() != "in-progress")
+
+		ready := len(result.Hops)
+		if !finished {
+			ready--
+		}
+		if ready > s.printed || finished {
+			if !s.started {
+				fmt.Fprintf(s.w, "\n=== From %s ===\n", result.Source)
+				fmt.Fprintf(s.w, "Target: %s (%s)\n\n", s.target, result.TargetIP)
+				s.started = true
+			}
+			for _, h := range result.Hops[s.printed:max(ready, s.printed)] {
+				fmt.Fprintln(s.w, s.renderer.RenderHop(h))
+			}
+			s.printed = max(ready, s.printed)
+		}
 
-		for _, h := range result.Hops {
-			fmt.Fprintln(cmd.OutOrStdout(), renderer.RenderHop(h))
+		if !finished {
+			return
 		}
 
 		if result.ReachedTarget {
-			fmt.Fprintf(cmd.OutOrStdout(), "\nTarget reached in %d hops\n", result.TotalHops())
+			fmt.Fprintf(s.w, "\nTarget reached in %d hops\n", result.TotalHops())
 		} else {
-			fmt.Fprintf(cmd.OutOrStdout(), "\nTarget not reached (%d hops)\n", result.TotalHops())
+			fmt.Fprintf(s.w, "\nTarget not reached (%d hops)\n", result.TotalHops())
 		}
+		display.RenderASLatency(s.w, result)
+		s.last = result
+		s.probe++
+		s.printed = 0
+		s.started = false
 	}
-
-	return lastResult, nil
 }
 
 // runGlobalPingMTR runs an MTR measurement via GlobalPing API.
 func runGlobalPingMTR(ctx context.Context, cmd *cobra.Command, cfg *Config) (*hop.TraceResult, error) {
 	// Create client with retry notification
-	client := newGlobalPingClient(cmd.OutOrStdout(), cfg.APIKey)
+	client := newGlobalPingClient(logging.FromContext(ctx), cfg.APIKey)
 
 	// Parse locations
-	locations := globalping.ParseLocationStrings(cfg.From)
+	locations := fromLocations(cfg)
 
 	// Create MTR measurement request
 	req := &globalping.MeasurementRequest{
@@ -843,7 +2255,7 @@ func runGlobalPingMTR(ctx context.Context, cmd *cobra.Command, cfg *Config) (*ho
 	fmt.Fprintln(cmd.OutOrStdout(), "Waiting for results (MTR takes longer)...")
 
 	// Wait for MTR completion
-	measurement, err := client.WaitForMTRMeasurement(ctx, resp.ID)
+	measurement, err := client.WaitForMeasurement(ctx, resp.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get results: %w", err)
 	}
@@ -862,7 +2274,7 @@ func runGlobalPingMTR(ctx context.Context, cmd *cobra.Command, cfg *Config) (*ho
 			"Hop", "Host", "Loss%", "Sent", "Recv", "Best", "Avg", "Worst")
 
 		// Display each hop with MTR stats
-		for i, mh := range pr.Result.Hops {
+		for i, mh := range pr.MTR.Hops {
 			displayMTRHop(cmd.OutOrStdout(), i+1, &mh)
 		}
 
@@ -956,6 +2368,9 @@ func runCompareMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error
 	} else {
 		fmt.Fprintf(cmd.OutOrStdout(), "Comparing traces to %s (local vs %s)\n", cfg.Target, cfg.From)
 	}
+	if liveCompare(cfg, isTerminal(cmd.OutOrStdout())) {
+		return runCompareTUI(ctx, cmd, cfg)
+	}
 	fmt.Fprintln(cmd.OutOrStdout(), "Running traces concurrently...")
 
 	var localResult *hop.TraceResult
@@ -968,21 +2383,32 @@ func runCompareMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			remoteResults, remoteErr = runGlobalPingTraceForCompare(ctx, cmd.OutOrStdout(), cfg)
+			remoteResults, remoteErr = runGlobalPingTraceForCompare(ctx, cmd.OutOrStdout(), cfg, nil, nil)
 		}()
 	} else {
-		// Run both local and remote traces concurrently
+		// Run both local and remote traces concurrently. With --align the local
+		// trace waits until the remote measurement exists (or failed to start).
+		remoteStarted := make(chan struct{})
+		markStarted := sync.OnceFunc(func() { close(remoteStarted) })
+
 		wg.Add(2)
 		go func() {
 			defer wg.Done()
+			if cfg.Align {
+				select {
+				case <-remoteStarted:
+				case <-ctx.Done():
+				}
+			}
 			localCfg := *cfg
 			localCfg.Simple = true
 			localCfg.From = ""
-			localResult, localErr = runLocalTraceForCompare(ctx, &localCfg)
+			localResult, localErr = runLocalTraceForCompare(ctx, &localCfg, nil)
 		}()
 		go func() {
 			defer wg.Done()
-			remoteResults, remoteErr = runGlobalPingTraceForCompare(ctx, cmd.OutOrStdout(), cfg)
+			defer markStarted()
+			remoteResults, remoteErr = runGlobalPingTraceForCompare(ctx, cmd.OutOrStdout(), cfg, markStarted, nil)
 		}()
 	}
 
@@ -1024,23 +2450,408 @@ func runCompareMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error
 	fmt.Fprintln(cmd.OutOrStdout())
 
 	renderer := display.NewCompareRenderer(cmd.OutOrStdout(), cfg.NoColor)
+	renderer.AlignByASN = cfg.AlignBy == "asn"
+	if err := renderer.RenderAll(sources); err != nil {
+		return err
+	}
+
+	warnCompareSkew(cmd.OutOrStdout(), cfg, sources)
+
+	if cfg.Output != "" {
+		if err := export.ExportAllToFile(cfg.Output, export.Format(cfg.Format), sources); err != nil {
+			return fmt.Errorf("failed to export: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Results exported to %s\n", cfg.Output)
+	}
+
+	return nil
+}
+
+// warnCompareSkew warns when the compared sources started far apart: traces
+// taken minutes apart can disagree because of transient events.
+func warnCompareSkew(w io.Writer, cfg *Config, sources []*hop.TraceResult) {
+	skew := hop.MaxStartSkew(sources)
+	if skew <= compareSkewWarning {
+		return
+	}
+	fmt.Fprintf(w, "\nWarning: sources started %v apart", skew.Round(time.Second))
+	if !hop.WindowsOverlap(sources) {
+		fmt.Fprint(w, " and did not overlap in time")
+	}
+	if !cfg.Align && !cfg.NoLocal {
+		fmt.Fprint(w, " (use --align to run them in the same time window)")
+	}
+	fmt.Fprintln(w)
+}
+
+// compareSkewWarning is the start time spread above which compare mode warns
+// that sources may not have observed the same network conditions.
+const compareSkewWarning = 10 * time.Second
+
+// runDualStackMode traces the IPv4 and IPv6 addresses of the target concurrently
+// and displays them side by side.
+func runDualStackMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	v4, v6, err := trace.ResolveDualStack(cfg.Target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve target for dual-stack: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Dual-stack trace to %s (%s / %s)\n", cfg.Target, v4, v6)
+	if liveCompare(cfg, isTerminal(cmd.OutOrStdout())) {
+		return runCompareTUI(ctx, cmd, cfg)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Running traces concurrently...")
+
+	ips := []net.IP{v4, v6}
+	labels := []string{"IPv4", "IPv6"}
+	results := make([]*hop.TraceResult, len(ips))
+	errs := make([]error, len(ips))
+
+	var wg sync.WaitGroup
+	for i := range ips {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			results[idx], errs[idx] = runLocalTraceToIP(ctx, cfg, ips[idx])
+		}(i)
+	}
+	wg.Wait()
+
+	if errs[0] != nil && errs[1] != nil {
+		return fmt.Errorf("both traces failed: ipv4=%v, ipv6=%v", errs[0], errs[1])
+	}
+
+	for i := range results {
+		if results[i] == nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "\n%s trace failed: %v\n", labels[i], errs[i])
+			results[i] = hop.NewTraceResult(cfg.Target, ips[i].String())
+		}
+		results[i].Source = labels[i]
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout())
+
+	renderer := display.NewCompareRenderer(cmd.OutOrStdout(), cfg.NoColor)
+	renderer.AlignByASN = cfg.AlignBy == "asn"
+	return renderer.RenderAll(results)
+}
+
+// runAllIPsMode traces every address the target resolves to, concurrently or
+// one after another with --sequential, then renders the paths side by side:
+// anycast and multi-CDN names can reach very different networks per address.
+func runAllIPsMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	w := cmd.OutOrStdout()
+
+	ips, err := trace.ResolveAllAddresses(cfg.Target, getAddressFamily(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	fmt.Fprintf(w, "Tracing all %d addresses of %s (%s)\n", len(ips), cfg.Target, strings.Join(addrs, ", "))
+
+	results := make([]*hop.TraceResult, len(ips))
+	errs := make([]error, len(ips))
+	if cfg.Sequential {
+		fmt.Fprintln(w, "Running traces one at a time...")
+		for i, ip := range ips {
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				continue
+			}
+			results[i], errs[i] = runLocalTraceToIP(ctx, cfg, ip)
+		}
+	} else {
+		fmt.Fprintln(w, "Running traces concurrently...")
+		var wg sync.WaitGroup
+		for i := range ips {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				results[idx], errs[idx] = runLocalTraceToIP(ctx, cfg, ips[idx])
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	failed := 0
+	for i := range results {
+		if results[i] == nil {
+			failed++
+			fmt.Fprintf(w, "\n%s trace failed: %v\n", addrs[i], errs[i])
+			results[i] = hop.NewTraceResult(cfg.Target, addrs[i])
+		}
+		results[i].Source = addrs[i]
+	}
+	if failed == len(results) {
+		return fmt.Errorf("all %d traces failed: %w", len(results), errs[0])
+	}
+
+	fmt.Fprintln(w)
+
+	renderer := display.NewCompareRenderer(w, cfg.NoColor)
+	renderer.AlignByASN = cfg.AlignBy == "asn"
+	if err := renderer.RenderAll(results); err != nil {
+		return err
+	}
+
+	sendOTLP(ctx, cfg, results...)
+
+	if cfg.Output != "" {
+		if err := export.ExportAllToFile(cfg.Output, export.Format(cfg.Format), results); err != nil {
+			return fmt.Errorf("failed to export: %w", err)
+		}
+		fmt.Fprintf(w, "Results exported to %s\n", cfg.Output)
+	}
+
+	return nil
+}
+
+// runReverseMode traces the forward path locally and the reverse path from a
+// GlobalPing probe near the target back to our public IP, then renders both
+// side by side to expose path asymmetry.
+func runReverseMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	w := cmd.OutOrStdout()
+
+	targetIP, err := trace.ResolveTarget(cfg.Target, getAddressFamily(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+
+	version := 4
+	if targetIP.To4() == nil {
+		version = 6
+	}
+	publicIP, err := enrich.NewPublicIPLookup().Lookup(ctx, version)
+	if err != nil {
+		return fmt.Errorf("failed to discover public IP: %w", err)
+	}
+
+	candidates := []string{cfg.From}
+	if cfg.From == "" {
+		candidates = nil
+		if asn, err := enrich.NewASNLookup().Lookup(ctx, targetIP); err == nil {
+			candidates = reverseProbeLocations(asn.ASN, asn.Country)
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("cannot locate a probe near %s, specify one with --from", targetIP)
+		}
+	}
+
+	fmt.Fprintf(w, "Bidirectional trace: %s (%s) <-> %s\n", cfg.Target, targetIP, publicIP)
+	fmt.Fprintln(w, "Running traces concurrently...")
+
+	var forward *hop.TraceResult
+	var reverse []*hop.TraceResult
+	var forwardErr, reverseErr error
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		forward, forwardErr = runLocalTraceToIP(ctx, cfg, targetIP)
+	}()
+	go func() {
+		defer wg.Done()
+		reverseCfg := *cfg
+		reverseCfg.Target = publicIP.String()
+		reverseCfg.IPv4Only, reverseCfg.IPv6Only = false, false
+		// Probes may not exist in the target's network; widen to its country
+		for _, from := range candidates {
+			reverseCfg.From = from
+			reverse, reverseErr = runGlobalPingTraceForCompare(ctx, w, &reverseCfg, nil, nil)
+			if reverseErr == nil {
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if forwardErr != nil && reverseErr != nil {
+		return fmt.Errorf("both traces failed: forward=%v, reverse=%v", forwardErr, reverseErr)
+	}
+
+	if forward == nil {
+		fmt.Fprintf(w, "\nForward trace failed: %v\n", forwardErr)
+		forward = hop.NewTraceResult(cfg.Target, targetIP.String())
+	}
+	forward.Source = "Forward: you -> " + cfg.Target
+	sources := []*hop.TraceResult{forward}
+
+	if len(reverse) == 0 {
+		fmt.Fprintf(w, "\nReverse trace failed: %v\n", reverseErr)
+		placeholder := hop.NewTraceResult(publicIP.String(), publicIP.String())
+		placeholder.Source = "Reverse: " + strings.Join(candidates, " / ")
+		sources = append(sources, placeholder)
+	}
+	for _, r := range reverse {
+		r.Source = "Reverse: " + r.Source + " -> you"
+		sources = append(sources, r)
+	}
+
+	fmt.Fprintln(w)
+
+	renderer := display.NewCompareRenderer(w, cfg.NoColor)
+	renderer.AlignByASN = cfg.AlignBy == "asn"
 	return renderer.RenderAll(sources)
 }
 
-// runLocalTraceForCompare runs a local trace for compare mode (simple output, no TUI).
-func runLocalTraceForCompare(ctx context.Context, cfg *Config) (*hop.TraceResult, error) {
-	// Parse timeout
+// reverseProbeLocations returns GlobalPing locations near a target, most
+// specific first: the target's network, then its country.
+func reverseProbeLocations(asn uint32, country string) []string {
+	var locs []string
+	if asn > 0 {
+		locs = append(locs, fmt.Sprintf("asn:%d", asn))
+	}
+	if country != "" {
+		locs = append(locs, "country:"+country)
+	}
+	return locs
+}
+
+// runProxyMode measures TCP connect latency to the target through a SOCKS5
+// proxy or an SSH bastion, the path the real application traffic takes.
+func runProxyMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	w := cmd.OutOrStdout()
+
 	timeout, err := time.ParseDuration(cfg.Timeout)
 	if err != nil {
-		return nil, fmt.Errorf("invalid timeout: %w", err)
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
 	}
 
+	proxyAddr, via := cfg.ViaSOCKS5, "SOCKS5 proxy "+cfg.ViaSOCKS5
+	if cfg.ViaSSH != "" {
+		fmt.Fprintf(w, "Opening SSH tunnel to %s...\n", cfg.ViaSSH)
+		tunnel, err := trace.StartSSHTunnel(ctx, cfg.ViaSSH, 30*time.Second)
+		if err != nil {
+			return err
+		}
+		defer tunnel.Close()
+		proxyAddr, via = tunnel.Addr, "SSH bastion "+cfg.ViaSSH
+	}
+
+	target := net.JoinHostPort(cfg.Target, strconv.Itoa(cfg.Port))
+	fmt.Fprintf(w, "TCP connect to %s via %s (%d probes)\n", target, via, cfg.Packets)
+
+	prober := trace.NewProxyProber(proxyAddr, timeout)
+	probes := make([]trace.ConnectProbe, 0, cfg.Packets)
+	for seq := 1; seq <= cfg.Packets; seq++ {
+		if seq > 1 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+		}
+		p := prober.Probe(ctx, seq, target)
+		probes = append(probes, p)
+		fmt.Fprintln(w, formatConnectProbe(p))
+	}
+
+	fmt.Fprintln(w, summarizeConnectProbes(probes))
+	return nil
+}
+
+// formatConnectProbe formats one proxied connect probe.
+func formatConnectProbe(p trace.ConnectProbe) string {
+	if p.Err != nil {
+		return fmt.Sprintf("%3d  failed: %v", p.Seq, p.Err)
+	}
+	return fmt.Sprintf("%3d  proxy %s  end-to-end %s  (+%s beyond proxy)",
+		p.Seq, formatMs(p.ProxyRTT), formatMs(p.ConnectRTT), formatMs(p.BeyondProxy()))
+}
+
+// summarizeConnectProbes returns loss and latency statistics for proxied probes.
+func summarizeConnectProbes(probes []trace.ConnectProbe) string {
+	var ok []trace.ConnectProbe
+	for _, p := range probes {
+		if p.Err == nil {
+			ok = append(ok, p)
+		}
+	}
+
+	loss := 0.0
+	if len(probes) > 0 {
+		loss = float64(len(probes)-len(ok)) / float64(len(probes)) * 100
+	}
+	line := fmt.Sprintf("--- %d probes, %d connected, %.1f%% loss", len(probes), len(ok), loss)
+	if len(ok) == 0 {
+		return line
+	}
+
+	var proxySum, sum time.Duration
+	lo, hi := ok[0].ConnectRTT, ok[0].ConnectRTT
+	for _, p := range ok {
+		proxySum += p.ProxyRTT
+		sum += p.ConnectRTT
+		lo = min(lo, p.ConnectRTT)
+		hi = max(hi, p.ConnectRTT)
+	}
+	n := time.Duration(len(ok))
+	return line + fmt.Sprintf("\nproxy avg %s, end-to-end min/avg/max %s/%s/%s",
+		formatMs(proxySum/n), formatMs(lo), formatMs(sum/n), formatMs(hi))
+}
+
+// formatHandshake describes the TCP handshake latency breakdown at the target.
+func formatHandshake(hs hop.Handshake) string {
+	line := fmt.Sprintf("TCP handshake: SYN/SYN-ACK %s, connect %s", formatMs(hs.SYNRTT), formatMs(hs.ConnectRTT))
+	if hs.TLSRTT > 0 {
+		line += fmt.Sprintf(", TLS %s (server +%s)", formatMs(hs.TLSRTT), formatMs(hs.ServerDelay()))
+	}
+	return line
+}
+
+// formatFingerprint describes the target's SYN-ACK: what it reveals of the
+// target's TCP stack and whether its MSS was clamped.
+func formatFingerprint(fp *hop.TCPFingerprint, ipv6 bool) string {
+	line := "Target fingerprint: " + fp.String()
+	if os := fp.OS(); os != "" {
+		line += ", likely " + os
+	}
+	if fp.MSSClamped(ipv6) {
+		line += fmt.Sprintf("\nMSS %d is below a 1500 byte MTU: clamped on the path (PPPoE, tunnel) or a smaller MTU at the target", fp.MSS)
+	}
+	return line
+}
+
+// formatMs formats a duration as milliseconds with one decimal.
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+// runLocalTraceForCompare runs a local trace for compare mode (simple output, no TUI).
+// If onHop is non-nil it is called with each hop once enriched.
+func runLocalTraceForCompare(ctx context.Context, cfg *Config, onHop func(*hop.Hop)) (*hop.TraceResult, error) {
 	// Resolve target
 	targetIP, err := trace.ResolveTarget(cfg.Target, getAddressFamily(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve target: %w", err)
 	}
 
+	return runLocalTraceStreaming(ctx, cfg, targetIP, onHop)
+}
+
+// runLocalTraceToIP runs a silent single-shot local trace to an already resolved IP.
+func runLocalTraceToIP(ctx context.Context, cfg *Config, targetIP net.IP) (*hop.TraceResult, error) {
+	return runLocalTraceStreaming(ctx, cfg, targetIP, nil)
+}
+
+// runLocalTraceStreaming is runLocalTraceToIP, calling onHop (if non-nil)
+// with each hop once enriched.
+func runLocalTraceStreaming(ctx context.Context, cfg *Config, targetIP net.IP, onHop func(*hop.Hop)) (*hop.TraceResult, error) {
+	// Parse timeout
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+
 	// Create trace config
 	traceCfg := &trace.Config{
 		Protocol:      trace.Protocol(cfg.Protocol),
@@ -1053,6 +2864,14 @@ func runLocalTraceForCompare(ctx context.Context, cfg *Config) (*hop.TraceResult
 		DiscoverMTU:   cfg.DiscoverMTU,
 		ProbeSize:     cfg.ProbeSize,
 		Decode:        cfg.Decode,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
+		Parallel:      !cfg.Sequential,
+		EndToEnd:      cfg.EndToEnd,
+		ServerName:    cfg.Target,
 	}
 
 	// Create tracer
@@ -1061,14 +2880,17 @@ func runLocalTraceForCompare(ctx context.Context, cfg *Config) (*hop.TraceResult
 		return nil, fmt.Errorf("failed to create tracer: %w", err)
 	}
 
-	// Create enricher (unless offline mode)
-	enricher := newEnricher(cfg.Offline)
+	// Create enricher (local databases only in offline mode)
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
 
 	// Run trace silently (no output during trace)
 	result, err := tracer.Trace(ctx, targetIP, func(h *hop.Hop) {
 		if enricher != nil {
 			enricher.EnrichHop(ctx, h)
 		}
+		if onHop != nil {
+			onHop(h)
+		}
 	})
 	if err != nil {
 		return nil, fmt.Errorf("trace failed: %w", err)
@@ -1080,12 +2902,14 @@ func runLocalTraceForCompare(ctx context.Context, cfg *Config) (*hop.TraceResult
 
 // runGlobalPingTraceForCompare runs a GlobalPing trace for compare mode (returns all results).
 // Uses MTR instead of traceroute to get ASN data for richer output.
-func runGlobalPingTraceForCompare(ctx context.Context, w io.Writer, cfg *Config) ([]*hop.TraceResult, error) {
+// If onCreated is non-nil it is called once the measurement has been created,
+// and if onUpdate is non-nil it is called with each partial set of results.
+func runGlobalPingTraceForCompare(ctx context.Context, w io.Writer, cfg *Config, onCreated func(), onUpdate func([]*hop.TraceResult)) ([]*hop.TraceResult, error) {
 	// Create client with retry notification
-	client := newGlobalPingClient(w, cfg.APIKey)
+	client := newGlobalPingClient(logging.FromContext(ctx), cfg.APIKey)
 
 	// Parse locations
-	locations := globalping.ParseLocationStrings(cfg.From)
+	locations := fromLocations(cfg)
 
 	// Use MTR to get ASN data (traceroute doesn't include ASN)
 	req := &globalping.MeasurementRequest{
@@ -1104,9 +2928,18 @@ func runGlobalPingTraceForCompare(ctx context.Context, w io.Writer, cfg *Config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create measurement: %w", err)
 	}
+	if onCreated != nil {
+		onCreated()
+	}
 
 	// Wait for MTR completion (takes longer than traceroute)
-	measurement, err := client.WaitForMTRMeasurement(ctx, resp.ID)
+	var progress func(*globalping.MeasurementResult)
+	if onUpdate != nil {
+		progress = func(m *globalping.MeasurementResult) {
+			onUpdate(compareResults(m, cfg.Target))
+		}
+	}
+	measurement, err := client.WaitForMeasurementProgress(ctx, resp.ID, progress)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get results: %w", err)
 	}
@@ -1114,13 +2947,83 @@ func runGlobalPingTraceForCompare(ctx context.Context, w io.Writer, cfg *Config)
 	if len(measurement.Results) == 0 {
 		return nil, fmt.Errorf("no probe results")
 	}
+	return compareResults(measurement, cfg.Target), nil
+}
 
-	// Convert all probe results
+// compareResults converts all probe results of a measurement, stamped with
+// the measurement's time window.
+func compareResults(measurement *globalping.MeasurementResult, target string) []*hop.TraceResult {
 	results := make([]*hop.TraceResult, len(measurement.Results))
 	for i, pr := range measurement.Results {
-		results[i] = pr.ToTraceResult(cfg.Target)
+		results[i] = pr.ToTraceResult(target)
+		results[i].StartTime = measurement.CreatedAt
+		results[i].EndTime = measurement.UpdatedAt
+	}
+	numberDuplicateSources(results)
+	return results
+}
+
+// fromLocations parses --from, asking for --limit probes from each location
+// that does not set its own limit (country:US@3).
+func fromLocations(cfg *Config) []globalping.Location {
+	locations := globalping.ParseLocationStrings(cfg.From)
+	for i := range locations {
+		if locations[i].Limit == 0 {
+			locations[i].Limit = cfg.Limit
+		}
+	}
+	return locations
+}
+
+// numberDuplicateSources numbers the results of probes sharing a location
+// ("Tokyo, JP, IIJ #2"), so every column of a comparison can be told apart.
+func numberDuplicateSources(results []*hop.TraceResult) {
+	count := make(map[string]int)
+	for _, r := range results {
+		count[r.Source]++
+	}
+	seen := make(map[string]int)
+	for _, r := range results {
+		if count[r.Source] > 1 {
+			seen[r.Source]++
+			r.Source = fmt.Sprintf("%s #%d", r.Source, seen[r.Source])
+		}
+	}
+}
+
+// thresholdAlerts returns the alert rules equivalent to the deprecated
+// --alert-latency and --alert-loss thresholds. Empty or zero thresholds are
+// disabled.
+func thresholdAlerts(latency, loss string) ([]string, error) {
+	var alerts []string
+	d, err := parseLatencyThreshold(latency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latency threshold: %w", err)
+	}
+	if d > 0 {
+		alerts = append(alerts, fmt.Sprintf("any(hop).rtt > %s", d))
+	}
+	pct, err := parseLossThreshold(loss)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loss threshold: %w", err)
+	}
+	if pct > 0 {
+		alerts = append(alerts, fmt.Sprintf("any(hop).loss > %g%%", pct))
+	}
+	return alerts, nil
+}
+
+// parseAlertRules parses --alert rule expressions.
+func parseAlertRules(alerts []string) ([]*monitor.Rule, error) {
+	rules := make([]*monitor.Rule, 0, len(alerts))
+	for _, a := range alerts {
+		rule, err := monitor.ParseRule(a)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
 	}
-	return results, nil
+	return rules, nil
 }
 
 // parseLatencyThreshold parses a latency threshold string (e.g., "100ms", "1s").
@@ -1143,15 +3046,9 @@ func parseLossThreshold(s string) (float64, error) {
 
 // runMonitor runs continuous monitoring mode.
 func runMonitor(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
-	// Parse thresholds
-	latencyThreshold, err := parseLatencyThreshold(cfg.AlertLatency)
-	if err != nil {
-		return fmt.Errorf("invalid latency threshold: %w", err)
-	}
-
-	lossThreshold, err := parseLossThreshold(cfg.AlertLoss)
-	if err != nil {
-		return fmt.Errorf("invalid loss threshold: %w", err)
+	flapThreshold, err := parseLossThreshold(cfg.AlertFlap)
+	if err != nil || flapThreshold < 0 || flapThreshold > 100 {
+		return fmt.Errorf("invalid --alert-flap %q: must be a percentage between 0 and 100", cfg.AlertFlap)
 	}
 
 	// Parse trace timeout
@@ -1178,6 +3075,13 @@ func runMonitor(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
 		DiscoverMTU:   cfg.DiscoverMTU,
 		ProbeSize:     cfg.ProbeSize,
 		Decode:        cfg.Decode,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
+		EndToEnd:      cfg.EndToEnd,
+		ServerName:    cfg.Target,
 	}
 
 	// Create tracer
@@ -1186,58 +3090,168 @@ func runMonitor(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
 		return fmt.Errorf("failed to create tracer: %w", err)
 	}
 
-	// Create enricher (unless offline mode)
-	enricher := newEnricher(cfg.Offline)
+	// Create enricher (local databases only in offline mode)
+	enricher := newEnricher(cfg.Offline, cfg.geoProvider, cfg.diskCache, cfg.bgpLookup, cfg.reputation, cfg.resolver)
+
+	// Store every cycle's trace, so scheduled runs keep their results
+	var history *monitor.History
+	if cfg.HistoryFile != "" {
+		history, err = monitor.OpenHistory(cfg.HistoryFile)
+		if err != nil {
+			return err
+		}
+		defer history.Close()
+	}
 
 	// Create monitor config
 	monCfg := monitor.DefaultConfig()
-	monCfg.LatencyThreshold = latencyThreshold
-	monCfg.LossThreshold = lossThreshold
+	monCfg.FlapThreshold = flapThreshold
+	monCfg.Cycles = cfg.Cycles
+	monCfg.Rules = cfg.alertRules
+	monCfg.Schedule = cfg.schedule
+	monCfg.ConfirmAnomalies = cfg.ConfirmAnomalies
 
 	// Create monitor
 	mon := monitor.NewMonitor(monCfg)
 
+	// Follow CDN and GSLB flips of a hostname target
+	resolveEvery := cfg.ResolveEvery
+	if net.ParseIP(cfg.Target) != nil {
+		resolveEvery = 0
+	}
+	mon.SetTargetResolver(targetIP, resolveEvery, func(ctx context.Context) ([]net.IP, error) {
+		return trace.ResolveTargetAll(cfg.Target, getAddressFamily(cfg))
+	})
+
 	// Set up change callback
 	mon.SetCallback(func(changes []monitor.Change) {
 		for _, c := range changes {
 			fmt.Fprintf(cmd.OutOrStdout(), "ALERT: %s\n", c.String())
+			if c.Rule != nil {
+				if err := c.Rule.Notify(ctx, cfg.Target, c); err != nil {
+					logging.FromContext(ctx).Warn("alert notification failed", "rule", c.Rule.String(), "err", err)
+				}
+			}
 		}
+		cfg.failOn.alert(changes)
 	})
 
-	fmt.Fprintf(cmd.OutOrStdout(), "Monitoring %s (%s), interval %v\n",
-		cfg.Target, targetIP, monCfg.Interval)
-	if latencyThreshold > 0 {
-		fmt.Fprintf(cmd.OutOrStdout(), "  Latency alert threshold: %v\n", latencyThreshold)
+	if cfg.schedule != nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "Monitoring %s (%s), schedule %q, next trace at %s\n",
+			cfg.Target, targetIP, cfg.schedule, cfg.schedule.Next(time.Now()).Format("2006-01-02 15:04"))
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "Monitoring %s (%s), interval %v\n",
+			cfg.Target, targetIP, monCfg.Interval)
+	}
+	if flapThreshold > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Flap alert threshold: %.0f%% of %d cycles\n", flapThreshold, monitor.FlapWindow)
+	}
+	if resolveEvery > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Re-resolving %s every %d cycles\n", cfg.Target, resolveEvery)
+	}
+	for _, r := range cfg.alertRules {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Alert rule: %s\n", r)
+	}
+	if history != nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Appending cycle traces to %s\n", cfg.HistoryFile)
 	}
-	if lossThreshold > 0 {
-		fmt.Fprintf(cmd.OutOrStdout(), "  Loss alert threshold: %.1f%%\n", lossThreshold)
+	if len(cfg.metricSinks) > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Exporting cycle metrics to %d metric sink(s)\n", len(cfg.metricSinks))
+	}
+	if cfg.ConfirmAnomalies {
+		fmt.Fprintln(cmd.OutOrStdout(), "  Confirming latency and loss anomalies with a re-trace")
+	}
+	if cfg.duration > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Stopping after %v\n", cfg.duration)
+	}
+	if cfg.UntilLoss != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Stopping once the loss to the target exceeds %s\n", cfg.UntilLoss)
+	}
+	if cfg.UntilLatency != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "  Stopping once the average RTT to the target exceeds %s\n", cfg.UntilLatency)
 	}
 	fmt.Fprintln(cmd.OutOrStdout(), "Press Ctrl+C to stop")
 	fmt.Fprintln(cmd.OutOrStdout())
 
+	// Stop conditions cancel runCtx with the reason as its cause
+	start := time.Now()
+	runCtx, stopRun := context.WithCancelCause(ctx)
+	defer stopRun(nil)
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeoutCause(runCtx, cfg.duration, errDurationElapsed)
+		defer cancel()
+	}
+	cycles := 0
+
+	// Print structured cycle summaries
+	mon.SetCycleCallback(func(result *hop.TraceResult, invalid bool) {
+		cycles++
+		summary := monitor.Summarize(result, time.Now())
+		summary.Invalid = invalid
+		summary.Stability = mon.Stability().Score()
+		if !invalid {
+			cfg.failOn.observe(result)
+			if err := monitor.PushMetrics(ctx, cfg.metricSinks, summary, result); err != nil {
+				logging.FromContext(ctx).Warn("metric export failed", "err", err)
+			}
+			sendOTLP(ctx, cfg, result)
+			if history != nil {
+				if err := history.Append(result); err != nil {
+					logging.FromContext(ctx).Warn("history write failed", "err", err)
+				}
+			}
+		}
+		if cfg.JSON {
+			fmt.Fprintln(cmd.OutOrStdout(), summary.JSON())
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), summary.String())
+		}
+		if !invalid {
+			cfg.until.observe(result)
+			if err := cfg.until.check(); err != nil {
+				stopRun(err)
+			}
+		}
+	})
+
+	// Anomalies a re-trace did not reproduce are noted, not alerted
+	mon.SetConfirmCallback(func(changes []monitor.Change) {
+		for _, c := range changes {
+			fmt.Fprintf(cmd.OutOrStdout(), "UNCONFIRMED: %s (not reproduced by a re-trace)\n", c.String())
+		}
+	})
+
+	// Don't alert on loss caused by a laptop sleep or a network switch
+	mon.SetNetworkWatcher(netwatch.New(targetIP), func(ev netwatch.Event) {
+		fmt.Fprintf(cmd.OutOrStdout(), "EVENT: %s, baseline restarted\n", ev.String())
+	})
+
 	// Create trace function for monitor
 	traceFn := func(ctx context.Context) (*hop.TraceResult, error) {
-		result, err := tracer.Trace(ctx, targetIP, func(h *hop.Hop) {
+		return tracer.Trace(ctx, mon.TargetIP(), func(h *hop.Hop) {
 			// Enrich each hop
 			if enricher != nil {
 				enricher.EnrichHop(ctx, h)
 			}
 		})
-		if err != nil {
-			return nil, err
-		}
-
-		// Print current trace summary
-		fmt.Fprintf(cmd.OutOrStdout(), "[%s] Trace: %d hops, reached=%v\n",
-			time.Now().Format("15:04:05"), result.TotalHops(), result.ReachedTarget)
-
-		return result, nil
 	}
 
 	// Run monitoring loop
-	return mon.Run(ctx, traceFn)
+	err = mon.Run(runCtx, traceFn)
+	if ctx.Err() != nil || runCtx.Err() == nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "\nStopped after %d cycles in %v\n", cycles, time.Since(start).Round(time.Second))
+	if cause := context.Cause(runCtx); !errors.Is(cause, errDurationElapsed) {
+		return cause
+	}
+	return nil
 }
 
+// errDurationElapsed ends a monitoring run once --duration elapsed.
+var errDurationElapsed = errors.New("--duration elapsed")
+
 func startUpdateCheck(version string) <-chan *update.CheckResult {
 	ch := make(chan *update.CheckResult, 1)
 	go func() {
@@ -2,10 +2,19 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/display"
 	"github.com/hervehildenbrand/gtrace/internal/globalping"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
 func TestRootCommand_RequiresTarget(t *testing.T) {
@@ -270,6 +279,36 @@ func TestRootCommand_ParsesAlertLossFlag(t *testing.T) {
 	}
 }
 
+func TestThresholdAlerts(t *testing.T) {
+	tests := []struct {
+		latency, loss string
+		want          []string
+	}{
+		{"", "", nil},
+		{"100ms", "", []string{"any(hop).rtt > 100ms"}},
+		{"", "5%", []string{"any(hop).loss > 5%"}},
+		{"1.5s", "2.5", []string{"any(hop).rtt > 1.5s", "any(hop).loss > 2.5%"}},
+		{"0", "0%", nil},
+	}
+	for _, tt := range tests {
+		got, err := thresholdAlerts(tt.latency, tt.loss)
+		if err != nil {
+			t.Errorf("thresholdAlerts(%q, %q): %v", tt.latency, tt.loss, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("thresholdAlerts(%q, %q) = %q, want %q", tt.latency, tt.loss, got, tt.want)
+		}
+		if _, err := parseAlertRules(got); err != nil {
+			t.Errorf("thresholdAlerts(%q, %q) rules do not parse: %v", tt.latency, tt.loss, err)
+		}
+	}
+
+	if _, err := thresholdAlerts("fast", ""); err == nil {
+		t.Error("expected an invalid latency threshold to be rejected")
+	}
+}
+
 func TestRootCommand_SimpleDefaultsFalse(t *testing.T) {
 	cmd := NewRootCmd("dev")
 
@@ -347,6 +386,8 @@ func TestRootCommand_DBStatus(t *testing.T) {
 }
 
 func TestRootCommand_DownloadDB(t *testing.T) {
+	t.Setenv("MAXMIND_LICENSE_KEY", "")
+	t.Setenv("HOME", t.TempDir())
 	cmd := NewRootCmd("dev")
 	buf := new(bytes.Buffer)
 	cmd.SetOut(buf)
@@ -363,6 +404,22 @@ func TestRootCommand_DownloadDB(t *testing.T) {
 	if !strings.Contains(output, "MaxMind") {
 		t.Errorf("expected output to contain 'MaxMind', got: %s", output)
 	}
+	if !strings.Contains(output, "--license-key") {
+		t.Errorf("expected output to mention --license-key, got: %s", output)
+	}
+}
+
+func TestRootCommand_DBAutoUpdateRejectsNegative(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"example.com", "--db-auto-update", "-1", "--dry-run"})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--db-auto-update") {
+		t.Errorf("expected --db-auto-update error, got %v", err)
+	}
 }
 
 func TestRootCommand_MTRModeDefaultValues(t *testing.T) {
@@ -974,3 +1031,1709 @@ func TestSetupCmd_UpgradeRegisteredForReleaseBuild(t *testing.T) {
 		t.Error("upgrade --help should show the upgrade description")
 	}
 }
+
+func TestRootCommand_ParsesPcapFlag(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--pcap", "out.pcap", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	pcap, _ := cmd.Flags().GetString("pcap")
+	if pcap != "out.pcap" {
+		t.Errorf("expected pcap 'out.pcap', got %q", pcap)
+	}
+}
+
+func TestRootCommand_PcapRequiresLocalTrace(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--from", "Paris", "--pcap", "out.pcap", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err == nil {
+		t.Error("expected error for --pcap with --from only")
+	}
+}
+
+func TestRootCommand_ParsesDualStackFlag(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--dual-stack", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	dualStack, _ := cmd.Flags().GetBool("dual-stack")
+	if !dualStack {
+		t.Error("expected dual-stack to be true")
+	}
+}
+
+func TestRootCommand_DualStackRejectsConflictingFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"with -4", []string{"google.com", "--dual-stack", "-4", "--dry-run"}},
+		{"with -6", []string{"google.com", "--dual-stack", "-6", "--dry-run"}},
+		{"with --from", []string{"google.com", "--dual-stack", "--from", "Paris", "--dry-run"}},
+		{"with multiple targets", []string{"google.com", "cloudflare.com", "--dual-stack", "--dry-run"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			if err := cmd.Execute(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestParseFlags_Shards(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--protocol", "udp", "--shards", "4", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	shards, _ := cmd.Flags().GetInt("shards")
+	if shards != 4 {
+		t.Errorf("expected shards 4, got %d", shards)
+	}
+}
+
+func TestParseFlags_ShardsRejectsICMP(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--shards", "4", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err == nil {
+		t.Error("expected error for --shards with icmp")
+	}
+}
+
+func TestParseFlags_ShardsInvalid(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--protocol", "tcp", "--shards", "0", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err == nil {
+		t.Error("expected error for --shards 0")
+	}
+}
+
+func TestParseFlags_Sequential(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--sequential", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	sequential, _ := cmd.Flags().GetBool("sequential")
+	if !sequential {
+		t.Error("expected sequential to be true")
+	}
+}
+
+func TestParseFlags_NetNS(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--netns", "blue", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	netns, _ := cmd.Flags().GetString("netns")
+	if netns != "blue" {
+		t.Errorf("expected netns 'blue', got %q", netns)
+	}
+}
+
+func TestParseFlags_Reputation(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--reputation", "--blocklist", "deny.txt", "--blocklist", "https://example.com/drop.txt", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	reputation, _ := cmd.Flags().GetBool("reputation")
+	if !reputation {
+		t.Error("expected reputation to be true")
+	}
+	blocklists, _ := cmd.Flags().GetStringArray("blocklist")
+	if len(blocklists) != 2 || blocklists[1] != "https://example.com/drop.txt" {
+		t.Errorf("expected 2 blocklists, got %v", blocklists)
+	}
+}
+
+func TestRootCommand_TargetsFileWithoutArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	content := "# edge routers\ngoogle.com\n\ncloudflare.com\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--targets-file", path, "--dry-run"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("unexpected error with --targets-file: %v", err)
+	}
+}
+
+func TestRootCommand_TargetsFileCountsTowardMax(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	content := "c.com\nd.com\ne.com\nf.com\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"a.com", "b.com", "--targets-file", path, "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err == nil {
+		t.Fatal("expected error for 6 combined targets")
+	}
+	if !strings.Contains(err.Error(), "too many targets") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRootCommand_TargetsFileMissing(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"--targets-file", filepath.Join(t.TempDir(), "nope.txt"), "--dry-run"})
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for missing targets file")
+	}
+}
+
+func TestCollectTargets_SkipsCommentsAndBlanks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("  # comment\n\n 1.1.1.1 \nexample.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := collectTargets([]string{"google.com"}, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"google.com", "1.1.1.1", "example.com"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRootCommand_JSONRequiresMonitor(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--json", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err == nil {
+		t.Fatal("expected error for --json without --monitor")
+	}
+	if !strings.Contains(err.Error(), "--monitor") {
+		t.Errorf("error should mention --monitor, got: %v", err)
+	}
+}
+
+func TestRootCommand_JSONWithMonitor(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--monitor", "--json", "--dry-run"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRootCommand_HasASCIIFlag(t *testing.T) {
+	cmd := NewRootCmd("dev")
+
+	flag := cmd.Flags().Lookup("ascii")
+	if flag == nil {
+		t.Fatal("expected --ascii flag to exist")
+	}
+	if flag.DefValue != "false" {
+		t.Errorf("expected --ascii default false, got %s", flag.DefValue)
+	}
+}
+
+func TestRootCommand_ReverseFlagConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"compare", []string{"google.com", "--reverse", "--compare", "--from", "Paris"}},
+		{"dual-stack", []string{"google.com", "--reverse", "--dual-stack"}},
+		{"multiple targets", []string{"google.com", "cloudflare.com", "--reverse"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append(tt.args, "--dry-run"))
+
+			err := cmd.Execute()
+
+			if err == nil || !strings.Contains(err.Error(), "--reverse") {
+				t.Errorf("expected --reverse conflict error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_ReverseWithFromAccepted(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--reverse", "--from", "Paris", "--dry-run"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReverseProbeLocations_PrefersNetworkThenCountry(t *testing.T) {
+	got := reverseProbeLocations(13335, "US")
+	if len(got) != 2 || got[0] != "asn:13335" || got[1] != "country:US" {
+		t.Errorf("unexpected locations: %v", got)
+	}
+	if got := reverseProbeLocations(0, ""); len(got) != 0 {
+		t.Errorf("expected no locations, got %v", got)
+	}
+}
+
+func TestRootCommand_AlignRequiresCompare(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"google.com", "--align", "--dry-run"})
+
+	err := cmd.Execute()
+
+	if err == nil || !strings.Contains(err.Error(), "--align") {
+		t.Errorf("expected --align error, got: %v", err)
+	}
+}
+
+func TestHopStreamer_PrintsCompletedHopsIncrementally(t *testing.T) {
+	hopAt := func(ip string) globalping.TracerouteHop {
+		return globalping.TracerouteHop{ResolvedAddress: ip, Timings: []globalping.HopTiming{{RTT: 1.5}}}
+	}
+	probe := globalping.ProbeResult{
+		Probe:      globalping.ProbeInfo{City: "Paris", Country: "FR"},
+		Traceroute: &globalping.TracerouteResult{Status: "in-progress", ResolvedAddress: "8.8.8.8"},
+	}
+
+	var buf bytes.Buffer
+	s := &hopStreamer{w: &buf, target: "google.com", renderer: display.NewSimpleRenderer()}
+
+	// The newest hop may still be collecting timings, so it is held back
+	probe.Traceroute.Hops = []globalping.TracerouteHop{hopAt("10.0.0.1"), hopAt("10.0.0.2")}
+	s.update([]globalping.ProbeResult{probe}, false)
+	if !strings.Contains(buf.String(), "10.0.0.1") || strings.Contains(buf.String(), "10.0.0.2") {
+		t.Fatalf("expected only the first hop to be printed:\n%s", buf.String())
+	}
+
+	probe.Traceroute.Hops = append(probe.Traceroute.Hops, hopAt("8.8.8.8"))
+	probe.Traceroute.Status = "finished"
+	s.update([]globalping.ProbeResult{probe}, true)
+
+	out := buf.String()
+	if strings.Count(out, "10.0.0.1") != 1 || strings.Count(out, "=== From") != 1 {
+		t.Errorf("hops and header must be printed exactly once:\n%s", out)
+	}
+	if !strings.Contains(out, "Target reached in 3 hops") {
+		t.Errorf("expected final summary:\n%s", out)
+	}
+	if s.last == nil || s.last.TotalHops() != 3 {
+		t.Error("expected last result to be recorded")
+	}
+}
+
+func TestRootCommand_ViaProxyRequiresTCP(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"example.com", "--via-socks5", "127.0.0.1:1080", "--dry-run"}, "--protocol tcp"},
+		{[]string{"example.com", "--protocol", "tcp", "--via-socks5", "127.0.0.1:1080", "--via-ssh", "me@bastion", "--dry-run"}, "mutually exclusive"},
+		{[]string{"example.com", "--protocol", "tcp", "--via-ssh", "me@bastion", "--from", "Paris", "--dry-run"}, "cannot be combined"},
+		{[]string{"example.com", "--protocol", "tcp", "--via-ssh", "-oProxyCommand=id", "--dry-run"}, "must not start with '-'"},
+	}
+	for _, tt := range tests {
+		cmd := NewRootCmd("dev")
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		cmd.SetArgs(tt.args)
+
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.want, err)
+		}
+	}
+}
+
+func TestSummarizeConnectProbes(t *testing.T) {
+	probes := []trace.ConnectProbe{
+		{Seq: 1, ProxyRTT: 2 * time.Millisecond, ConnectRTT: 30 * time.Millisecond},
+		{Seq: 2, Err: fmt.Errorf("connection refused")},
+		{Seq: 3, ProxyRTT: 4 * time.Millisecond, ConnectRTT: 40 * time.Millisecond},
+	}
+
+	summary := summarizeConnectProbes(probes)
+
+	if !strings.Contains(summary, "3 probes, 2 connected, 33.3% loss") {
+		t.Errorf("unexpected loss line: %s", summary)
+	}
+	if !strings.Contains(summary, "proxy avg 3.0ms") || !strings.Contains(summary, "30.0ms/35.0ms/40.0ms") {
+		t.Errorf("unexpected latency line: %s", summary)
+	}
+	if !strings.Contains(formatConnectProbe(probes[1]), "failed: connection refused") {
+		t.Error("failed probe should show its error")
+	}
+}
+
+func TestRootCommand_EndToEndRequiresTCP(t *testing.T) {
+	cmd := NewRootCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"example.com", "--end-to-end", "--dry-run"})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--protocol tcp") {
+		t.Errorf("expected --protocol tcp error, got %v", err)
+	}
+}
+
+func TestFormatHandshake(t *testing.T) {
+	hs := hop.Handshake{SYNRTT: 10 * time.Millisecond, ConnectRTT: 11 * time.Millisecond}
+	if got := formatHandshake(hs); got != "TCP handshake: SYN/SYN-ACK 10.0ms, connect 11.0ms" {
+		t.Errorf("unexpected line without TLS: %s", got)
+	}
+
+	hs.TLSRTT = 35 * time.Millisecond
+	if got := formatHandshake(hs); !strings.HasSuffix(got, ", TLS 35.0ms (server +25.0ms)") {
+		t.Errorf("unexpected line with TLS: %s", got)
+	}
+}
+
+func TestFormatFingerprint(t *testing.T) {
+	fp := &hop.TCPFingerprint{TTL: 113, Window: 64240, MSS: 1380, WindowScale: 8, SACK: true, Options: "M,N,W,N,N,S"}
+	got := formatFingerprint(fp, false)
+	want := "Target fingerprint: TTL 113 (initial 128, 15 hops), window 64240, MSS 1380, WS 8, SACK, options M,N,W,N,N,S, likely Windows\n" +
+		"MSS 1380 is below a 1500 byte MTU: clamped on the path (PPPoE, tunnel) or a smaller MTU at the target"
+	if got != want {
+		t.Errorf("formatFingerprint() = %q, want %q", got, want)
+	}
+}
+
+func TestRootCommand_BGPValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"bgp accepted", []string{"--bgp"}, ""},
+		{"looking glass accepted", []string{"--looking-glass", "https://lg.example.net"}, ""},
+		{"invalid looking glass", []string{"--looking-glass", "lg.example.net"}, "invalid --looking-glass"},
+		{"offline", []string{"--bgp", "--offline"}, "cannot be combined with --offline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_LimitValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"fan out", []string{"--from", "London,Tokyo", "--limit", "3"}, ""},
+		{"compare with local", []string{"--from", "London", "--limit", "2", "--compare"}, ""},
+		{"no-local with one location", []string{"--from", "London", "--limit", "2", "--no-local"}, ""},
+		{"no-local with one probe", []string{"--from", "London", "--no-local"}, "at least 2 locations"},
+		{"negative", []string{"--from", "London", "--limit", "-1"}, "--limit must be >= 0"},
+		{"without from", []string{"--limit", "2"}, "--limit requires --from"},
+		{"monitor", []string{"--from", "London", "--limit", "2", "--monitor"}, "cannot be combined with --monitor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_AlignByValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"ttl", []string{"--align-by", "ttl"}, ""},
+		{"asn compare", []string{"--from", "London", "--compare", "--align-by", "asn"}, ""},
+		{"asn dual-stack", []string{"--dual-stack", "--align-by", "asn"}, ""},
+		{"asn single trace", []string{"--align-by", "asn"}, "requires --compare, --reverse, --dual-stack or --all-ips"},
+		{"unknown", []string{"--from", "London", "--compare", "--align-by", "hop"}, "must be ttl or asn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_QueueValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"icmp", []string{"--queue"}, ""},
+		{"udp custom size", []string{"--queue", "--protocol", "udp", "--queue-size", "1200", "--queue-rounds", "3"}, ""},
+		{"tcp", []string{"--queue", "--protocol", "tcp"}, "requires --protocol icmp or udp"},
+		{"remote", []string{"--queue", "--from", "London"}, "requires a plain local trace"},
+		{"zero rounds", []string{"--queue", "--queue-rounds", "0"}, "--queue-rounds must be >= 1"},
+		{"size not larger", []string{"--queue", "--probe-size", "1500"}, "--queue-size must be larger than --probe-size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_QUICValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"quic protocol", []string{"--protocol", "quic"}, ""},
+		{"compare", []string{"--quic-compare"}, ""},
+		{"compare explicit protocol", []string{"--quic-compare", "--protocol", "quic", "--quic-rounds", "5"}, ""},
+		{"compare tcp", []string{"--quic-compare", "--protocol", "tcp"}, "cannot be combined with --protocol tcp"},
+		{"compare remote", []string{"--quic-compare", "--from", "London"}, "requires a plain local trace"},
+		{"compare queue", []string{"--quic-compare", "--queue"}, "requires a plain local trace"},
+		{"zero rounds", []string{"--quic-compare", "--quic-rounds", "0"}, "--quic-rounds must be >= 1"},
+		{"queue quic", []string{"--queue", "--protocol", "quic"}, "requires --protocol icmp or udp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_AutoProtocolValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"auto", []string{"--protocol", "auto"}, ""},
+		{"auto sharded", []string{"--protocol", "auto", "--shards", "4"}, ""},
+		{"remote", []string{"--protocol", "auto", "--from", "London"}, "--protocol auto requires a local trace"},
+		{"proxy", []string{"--protocol", "auto", "--via-socks5", "127.0.0.1:1080"}, "--protocol auto requires a local trace"},
+		{"queue", []string{"--queue", "--protocol", "auto"}, "requires --protocol icmp or udp"},
+		{"end to end", []string{"--protocol", "auto", "--end-to-end"}, "--end-to-end requires --protocol tcp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_DiagnoseValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"tcp", []string{"--diagnose", "--protocol", "tcp", "--port", "443"}, ""},
+		{"udp", []string{"--diagnose", "--protocol", "udp"}, ""},
+		{"icmp", []string{"--diagnose"}, "requires --protocol tcp or udp"},
+		{"quic compare", []string{"--diagnose", "--quic-compare"}, "requires --protocol tcp or udp"},
+		{"remote", []string{"--diagnose", "--protocol", "tcp", "--from", "London"}, "requires a plain local trace"},
+		{"monitor", []string{"--diagnose", "--protocol", "tcp", "--monitor"}, "requires a plain local trace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareConfig_DiagnoseImpliesSimple(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Diagnose = true
+	cfg.Protocol = "tcp"
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Fatalf("prepareConfig() error = %v", err)
+	}
+	if !cfg.Simple {
+		t.Error("expected --diagnose to print the trace in simple mode")
+	}
+}
+
+func TestMTRPacketsPerHop(t *testing.T) {
+	cfg := defaultConfig()
+	if got := mtrPacketsPerHop(&cfg); got != 1 {
+		t.Errorf("mtrPacketsPerHop() = %d without --packets, want 1", got)
+	}
+
+	cfg.Packets = 5
+	cfg.packetsSet = true
+	if got := mtrPacketsPerHop(&cfg); got != 5 {
+		t.Errorf("mtrPacketsPerHop() = %d with --packets 5, want 5", got)
+	}
+}
+
+func TestRootCommand_ScheduleValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"every five minutes", []string{"--schedule", "*/5 * * * *"}, ""},
+		{"descriptor", []string{"--schedule", "@hourly"}, ""},
+		{"too few fields", []string{"--schedule", "*/5 * *"}, "invalid --schedule"},
+		{"out of range", []string{"--schedule", "0 25 * * *"}, "invalid hour"},
+		{"json summaries without --monitor", []string{"--schedule", "@daily", "--json"}, ""},
+		{"history file", []string{"--schedule", "@daily", "--history-file", "history.jsonl"}, ""},
+		{"history file without monitoring", []string{"--history-file", "history.jsonl"}, "--history-file requires --monitor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareConfig_ScheduleImpliesMonitor(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Schedule = "0 * * * *"
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Fatalf("prepareConfig() error = %v", err)
+	}
+	if !cfg.Monitor || cfg.schedule == nil {
+		t.Errorf("Monitor = %v, schedule = %v, want monitoring on a schedule", cfg.Monitor, cfg.schedule)
+	}
+}
+
+func TestPrepareConfig_QUICDefaultsToPort443(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Protocol = "quic"
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Fatalf("prepareConfig() error = %v", err)
+	}
+	if cfg.Port != trace.QUICPort {
+		t.Errorf("Port = %d, want %d", cfg.Port, trace.QUICPort)
+	}
+
+	cfg = defaultConfig()
+	cfg.QUICCompare = true
+	cfg.Port = 8443
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Fatalf("prepareConfig() error = %v", err)
+	}
+	if cfg.Protocol != "quic" || cfg.Port != 8443 {
+		t.Errorf("Protocol = %q, Port = %d, want quic to 8443", cfg.Protocol, cfg.Port)
+	}
+}
+
+func TestRootCommand_OTLPValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"collector", []string{"--otlp", "http://localhost:4318"}, ""},
+		{"monitor", []string{"--otlp", "https://otel.example.com/v1/traces", "--monitor"}, ""},
+		{"not a url", []string{"--otlp", "localhost:4318"}, "invalid --otlp"},
+		{"compare", []string{"--otlp", "http://localhost:4318", "--from", "London", "--compare"}, "--otlp cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareConfig_ResolveEvery(t *testing.T) {
+	cfg := defaultConfig()
+	if cfg.ResolveEvery != 10 {
+		t.Errorf("default ResolveEvery = %d, want 10", cfg.ResolveEvery)
+	}
+	cfg.Monitor = true
+	cfg.ResolveEvery = -1
+	if err := prepareConfig(&cfg, []string{"example.com"}); err == nil || !strings.Contains(err.Error(), "--resolve-every must be >= 0") {
+		t.Errorf("expected --resolve-every error, got %v", err)
+	}
+}
+
+func TestPrepareConfig_LimitImpliesRemoteComparison(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.From = "London;AWS+us-east-1"
+	cfg.Limit = 5
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Compare || !cfg.NoLocal {
+		t.Errorf("Compare = %v, NoLocal = %v; want both true", cfg.Compare, cfg.NoLocal)
+	}
+}
+
+func TestFromLocations_AppliesLimit(t *testing.T) {
+	cfg := &Config{From: "London;country:US@2", Limit: 5}
+	locations := fromLocations(cfg)
+	if len(locations) != 2 {
+		t.Fatalf("expected 2 locations, got %d", len(locations))
+	}
+	if locations[0].Magic != "London" || locations[0].Limit != 5 {
+		t.Errorf("expected London with limit 5, got %+v", locations[0])
+	}
+	if locations[1].Country != "US" || locations[1].Limit != 2 {
+		t.Errorf("expected explicit limit 2 kept, got %+v", locations[1])
+	}
+}
+
+func TestNumberDuplicateSources(t *testing.T) {
+	var results []*hop.TraceResult
+	for _, source := range []string{"Tokyo, JP", "London, GB", "Tokyo, JP"} {
+		r := hop.NewTraceResult("example.com", "")
+		r.Source = source
+		results = append(results, r)
+	}
+
+	numberDuplicateSources(results)
+
+	want := []string{"Tokyo, JP #1", "London, GB", "Tokyo, JP #2"}
+	for i, r := range results {
+		if r.Source != want[i] {
+			t.Errorf("results[%d].Source = %q, want %q", i, r.Source, want[i])
+		}
+	}
+}
+
+func TestRootCommand_OfflineValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"local trace", []string{"--offline"}, ""},
+		{"remote", []string{"--offline", "--from", "Paris"}, "cannot be combined with --offline"},
+		{"reverse", []string{"--offline", "--reverse"}, "cannot be combined with --offline"},
+		{"db auto update", []string{"--offline", "--db-auto-update", "7"}, "cannot be combined with --offline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_GeoValidateValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"local trace", []string{"--geo-validate"}, ""},
+		{"offline", []string{"--geo-validate", "--offline"}, "cannot be combined with --offline"},
+		{"remote", []string{"--geo-validate", "--from", "Paris"}, "requires a plain local trace"},
+		{"several targets", []string{"--geo-validate", "example.com"}, "accepts a single target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_HostStatsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"local trace", []string{"--host-stats"}, ""},
+		{"monitor", []string{"--host-stats", "--monitor"}, "requires a plain local trace"},
+		{"remote", []string{"--host-stats", "--from", "Paris"}, "requires a plain local trace"},
+		{"several targets", []string{"--host-stats", "example.com"}, "accepts a single target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_RecordValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"mtr session", []string{"--record", "s.gtr"}, ""},
+		{"simple", []string{"--record", "s.gtr", "--simple"}, "interactive MTR view"},
+		{"implied simple", []string{"--record", "s.gtr", "--host-stats"}, "interactive MTR view"},
+		{"monitor", []string{"--record", "s.gtr", "--monitor"}, "requires a plain local MTR session"},
+		{"remote", []string{"--record", "s.gtr", "--from", "Paris"}, "requires a plain local MTR session"},
+		{"several targets", []string{"--record", "s.gtr", "example.com"}, "accepts a single target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_ViaValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"mtr session", []string{"--via", "ssh://ops@jump.example.net"}, ""},
+		{"with sudo and record", []string{"--via", "ssh://jump.example.net:2222", "--via-sudo", "--record", "s.gtr"}, ""},
+		{"not ssh", []string{"--via", "jump.example.net"}, "invalid --via"},
+		{"sudo alone", []string{"--via-sudo"}, "--via-sudo requires --via"},
+		{"simple", []string{"--via", "ssh://jump", "--simple"}, "interactive MTR view"},
+		{"remote", []string{"--via", "ssh://jump", "--from", "Paris"}, "requires a plain MTR session"},
+		{"pcap", []string{"--via", "ssh://jump", "--pcap", "out.pcap"}, "cannot be combined with"},
+		{"several targets", []string{"--via", "ssh://jump", "example.com"}, "accepts a single target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_ServiceMatrixValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"default services", []string{"--service-matrix"}, ""},
+		{"custom services", []string{"--service-matrix", "--services", "icmp,tcp:22,quic:443"}, ""},
+		{"protocol", []string{"--service-matrix", "--protocol", "tcp"}, "cannot be combined with --protocol tcp"},
+		{"remote", []string{"--service-matrix", "--from", "Paris"}, "requires a plain local trace"},
+		{"several targets", []string{"--service-matrix", "example.com"}, "accepts a single target"},
+		{"one service", []string{"--service-matrix", "--services", "tcp:443"}, "at least two services"},
+		{"invalid service", []string{"--service-matrix", "--services", "icmp,tcp"}, `invalid service "tcp"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_HistoryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"default", nil, ""},
+		{"long history", []string{"--history", "300"}, ""},
+		{"zero", []string{"--history", "0"}, "--history must be >= 1"},
+		{"warmup", []string{"--warmup", "3"}, ""},
+		{"negative warmup", []string{"--warmup", "-1"}, "--warmup must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_LogFormatValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"default", nil, ""},
+		{"json", []string{"--log-format", "json", "--verbose"}, ""},
+		{"invalid", []string{"--log-format", "xml"}, "invalid log format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_PacingValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"default", nil, ""},
+		{"rate with burst", []string{"--rate", "20", "--burst", "5", "--max-in-flight", "4", "--ttl-interval", "100ms"}, ""},
+		{"negative rate", []string{"--rate", "-1"}, "--rate must be >= 0"},
+		{"burst without rate", []string{"--burst", "5"}, "--burst requires --rate"},
+		{"zero burst", []string{"--rate", "10", "--burst", "0"}, "--burst must be >= 1"},
+		{"negative in flight", []string{"--max-in-flight", "-1"}, "--max-in-flight must be >= 0"},
+		{"bad TTL interval", []string{"--ttl-interval", "soon"}, "invalid --ttl-interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_GeoProviderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"ipinfo accepted", []string{"--geo-provider", "ipinfo"}, ""},
+		{"dbip accepted", []string{"--geo-provider", "dbip"}, ""},
+		{"unknown provider", []string{"--geo-provider", "geoip3"}, "unknown GeoIP provider"},
+		{"missing database", []string{"--geo-db", filepath.Join(t.TempDir(), "missing.mmdb")}, "--geo-db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_ThemeValidation(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(good, []byte(`{"theme": "mine", "themes": {"mine": {"base": "light", "rtt": "#00ff00"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	badColor := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badColor, []byte(`{"themes": {"mine": {"rtt": "green"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	typo := filepath.Join(dir, "typo.json")
+	if err := os.WriteFile(typo, []byte(`{"theems": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"built-in", []string{"--theme", "colorblind", "--config", good}, ""},
+		{"from config file", []string{"--config", good}, ""},
+		{"missing config file", []string{"--config", filepath.Join(dir, "none.json")}, ""},
+		{"unknown theme", []string{"--theme", "neon", "--config", good}, `unknown theme "neon"`},
+		{"invalid color", []string{"--theme", "mine", "--config", badColor}, `invalid rtt color "green"`},
+		{"unknown field", []string{"--config", typo}, "invalid config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_FailOnValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"simple", []string{"--fail-on", "unreached,loss>5%", "--simple"}, ""},
+		{"repeated", []string{"--fail-on", "unreached", "--fail-on", "latency>100ms", "--simple"}, ""},
+		{"remote", []string{"--fail-on", "unreached", "--from", "Paris"}, ""},
+		{"monitor alert", []string{"--fail-on", "alert", "--monitor", "--cycles", "10"}, ""},
+		{"invalid expression", []string{"--fail-on", "jitter>5ms", "--simple"}, "invalid --fail-on"},
+		{"tui", []string{"--fail-on", "unreached"}, "requires --simple"},
+		{"compare", []string{"--fail-on", "unreached", "--compare", "--from", "Paris"}, "cannot be combined"},
+		{"alert without monitor", []string{"--fail-on", "alert", "--simple"}, "requires --monitor"},
+		{"negative cycles", []string{"--cycles", "-1"}, "--cycles must be >= 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareConfig_ConfirmAnomalies(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.ConfirmAnomalies = true
+	if err := prepareConfig(&cfg, []string{"example.com"}); err == nil || !strings.Contains(err.Error(), "--confirm-anomalies requires --monitor") {
+		t.Errorf("expected --confirm-anomalies to require --monitor, got %v", err)
+	}
+
+	cfg = defaultConfig()
+	cfg.ConfirmAnomalies = true
+	cfg.Schedule = "@hourly"
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Errorf("expected --schedule to allow --confirm-anomalies, got %v", err)
+	}
+}
+
+func TestRootCommand_AlertRuleValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"rules", []string{"--monitor", "--alert", "hop(last).loss > 5% for 3 cycles", "--alert", "any(hop).rtt_p95 > 150ms"}, ""},
+		{"action", []string{"--monitor", "--alert", "hop(last).rtt > 100ms then exec echo alert, target down"}, ""},
+		{"without monitor", []string{"--alert", "hop(last).loss > 5%"}, "--alert requires --monitor"},
+		{"invalid rule", []string{"--monitor", "--alert", "hop(last).loss >"}, "invalid alert rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_AlertSinkConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.json", `{
+		"alertSinks": {
+			"ops": {"type": "syslog", "network": "udp", "address": "127.0.0.1:514", "facility": "local0"},
+			"oncall": {"type": "email", "server": "smtp.example.com:587", "from": "gtrace@example.com", "to": ["oncall@example.com"]}
+		},
+		"alertRules": [{"rule": "hop(last).loss > 5% for 3 cycles", "sinks": ["ops", "oncall"]}]
+	}`)
+	unknownSink := write("unknown.json", `{"alertRules": [{"rule": "hop(last).loss > 5%", "sinks": ["pager"]}]}`)
+	badSink := write("badsink.json", `{"alertSinks": {"ops": {"type": "syslog", "network": "udp"}}}`)
+	badRule := write("badrule.json", `{"alertRules": [{"rule": "loss > 5%"}]}`)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"rules with sinks", []string{"--monitor", "--config", good}, ""},
+		{"ignored without monitor", []string{"--config", unknownSink}, ""},
+		{"unknown sink", []string{"--monitor", "--config", unknownSink}, `unknown sink "pager"`},
+		{"invalid sink", []string{"--monitor", "--config", badSink}, "requires an address"},
+		{"invalid rule", []string{"--monitor", "--config", badRule}, "invalid alert rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_MetricSinkConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.json", `{
+		"metricSinks": {
+			"influx": {"type": "influxdb", "url": "http://127.0.0.1:8086/api/v2/write?org=ops&bucket=gtrace", "token": "secret"},
+			"graphite": {"type": "graphite", "address": "127.0.0.1:2003", "prefix": "net.gtrace"}
+		}
+	}`)
+	badURL := write("badurl.json", `{"metricSinks": {"influx": {"type": "influxdb", "url": "influx:8086"}}}`)
+	badType := write("badtype.json", `{"metricSinks": {"tsdb": {"type": "prometheus"}}}`)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"influxdb and graphite", []string{"--monitor", "--config", good}, ""},
+		{"ignored without monitor", []string{"--config", badType}, ""},
+		{"invalid url", []string{"--monitor", "--config", badURL}, `metric sink "influx"`},
+		{"unknown type", []string{"--monitor", "--config", badType}, "unknown metric sink type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRootCommand_StopConditionValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"duration", []string{"--monitor", "--duration", "10m"}, ""},
+		{"until loss and latency", []string{"--monitor", "--until-loss", "5%", "--until-latency", "100ms"}, ""},
+		{"invalid duration", []string{"--monitor", "--duration", "soon"}, "invalid --duration"},
+		{"negative duration", []string{"--monitor", "--duration", "-1m"}, "invalid --duration"},
+		{"loss of 100%", []string{"--monitor", "--until-loss", "100%"}, "invalid --until-loss"},
+		{"invalid latency", []string{"--monitor", "--until-latency", "fast"}, "invalid --until-latency"},
+		{"without monitor", []string{"--until-loss", "5%"}, "require --monitor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"google.com", "--dry-run"}, tt.args...))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPrepareConfig_UntilLossStopsWithLossExitCode(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Monitor = true
+	cfg.UntilLoss = "5%"
+	cfg.Duration = "1h"
+	if err := prepareConfig(&cfg, []string{"example.com"}); err != nil {
+		t.Fatalf("prepareConfig() error = %v", err)
+	}
+	if cfg.duration != time.Hour || cfg.until == nil {
+		t.Fatalf("duration = %v, until = %v, want a one hour run with a loss stop condition", cfg.duration, cfg.until)
+	}
+
+	cfg.until.observe(hop.NewTraceResult("example.com", "93.184.216.34"))
+	var exit *exitError
+	if err := cfg.until.check(); !errors.As(err, &exit) || exit.code != exitLoss || !strings.HasPrefix(exit.Error(), "stopped: loss 100.0% > 5%") {
+		t.Errorf("expected the unreached target to stop the run with the loss exit code, got %v", err)
+	}
+}
+
+func TestRootCommand_AllIPsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"plain", []string{"google.com", "--all-ips", "--dry-run"}, ""},
+		{"sequential ipv6", []string{"google.com", "--all-ips", "-6", "--sequential", "--dry-run"}, ""},
+		{"align by asn", []string{"google.com", "--all-ips", "--align-by", "asn", "--dry-run"}, ""},
+		{"ip literal", []string{"8.8.8.8", "--all-ips", "--dry-run"}, "requires a hostname"},
+		{"multiple targets", []string{"google.com", "cloudflare.com", "--all-ips", "--dry-run"}, "single target"},
+		{"with --from", []string{"google.com", "--all-ips", "--from", "Paris", "--dry-run"}, "plain local trace"},
+		{"with --dual-stack", []string{"google.com", "--all-ips", "--dual-stack", "--dry-run"}, "plain local trace"},
+		{"with --monitor", []string{"google.com", "--all-ips", "--monitor", "--dry-run"}, "plain local trace"},
+		{"with --fail-on", []string{"google.com", "--all-ips", "--simple", "--fail-on", "unreached", "--dry-run"}, "--all-ips"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRootCommand_ResolveDebugValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"plain", []string{"google.com", "--resolve-debug", "--dry-run"}, ""},
+		{"with --offline", []string{"google.com", "--resolve-debug", "--offline", "--dry-run"}, "--offline"},
+		{"with --json", []string{"google.com", "--resolve-debug", "--monitor", "--json", "--dry-run"}, "--json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRootCommand_DNSValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"plain", []string{"google.com", "--dns", "9.9.9.9", "--dry-run"}, ""},
+		{"dns over tls", []string{"google.com", "--dns", "tls://dns.quad9.net", "--dry-run"}, ""},
+		{"dns over https", []string{"google.com", "--dns", "https://dns.google/dns-query", "--dry-run"}, ""},
+		{"bad scheme", []string{"google.com", "--dns", "quic://dns.adguard.com", "--dry-run"}, "invalid --dns"},
+		{"bad port", []string{"google.com", "--dns", "9.9.9.9:0", "--dry-run"}, "invalid --dns"},
+		{"with --offline", []string{"google.com", "--dns", "9.9.9.9", "--offline", "--dry-run"}, "--offline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRootCommand_IPv6OptionsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"flow label", []string{"google.com", "--flow-label", "0x12345", "--dry-run"}, ""},
+		{"flow label zero", []string{"2001:db8::1", "--flow-label", "0", "--dry-run"}, ""},
+		{"extension headers", []string{"google.com", "--ipv6-ext", "hbh:16,dst", "--dry-run"}, ""},
+		{"flow label too large", []string{"google.com", "--flow-label", "1048576", "--dry-run"}, "invalid --flow-label"},
+		{"bad header", []string{"google.com", "--ipv6-ext", "rthdr", "--dry-run"}, "invalid --ipv6-ext"},
+		{"bad header size", []string{"google.com", "--ipv6-ext", "dst:12", "--dry-run"}, "invalid --ipv6-ext"},
+		{"with -4", []string{"google.com", "--flow-label", "1", "-4", "--dry-run"}, "-4/--ipv4"},
+		{"with --dual-stack", []string{"google.com", "--ipv6-ext", "hbh", "--dual-stack", "--dry-run"}, "--dual-stack"},
+		{"IPv4 target", []string{"192.0.2.1", "--flow-label", "1", "--dry-run"}, "require IPv6 targets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewRootCmd("dev")
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
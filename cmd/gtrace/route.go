@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// showRoute looks up the kernel route to targetIP, prints it to out and
+// warns on errOut when probes would not take the path the user expects.
+// Returns nil when the route can't be looked up: from inside another
+// network namespace, the host's routes don't apply.
+func showRoute(out, errOut io.Writer, cfg *Config, targetIP net.IP) *hop.RouteInfo {
+	if cfg.NetNS != "" {
+		return nil
+	}
+	route, err := trace.LookupRoute(targetIP)
+	if err != nil {
+		fmt.Fprintf(errOut, "Warning: %v\n", err)
+		return nil
+	}
+	fmt.Fprintf(out, "route: %s\n", route)
+
+	def, _ := trace.DefaultRoute(targetIP)
+	for _, w := range trace.RouteWarnings(route, def) {
+		fmt.Fprintf(errOut, "Warning: %s\n", w)
+	}
+	return route
+}
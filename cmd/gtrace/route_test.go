@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestShowRoute_PrintsKernelRoute(t *testing.T) {
+	cfg := defaultConfig()
+	var out, errOut bytes.Buffer
+	route := showRoute(&out, &errOut, &cfg, net.ParseIP("127.0.0.1"))
+	if route == nil {
+		t.Skipf("route lookup unavailable: %s", errOut.String())
+	}
+	if !strings.HasPrefix(out.String(), "route: ") || !strings.Contains(out.String(), "dev "+route.Interface) {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+func TestShowRoute_SkipsNetNS(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.NetNS = "blue"
+
+	var out, errOut bytes.Buffer
+	if route := showRoute(&out, &errOut, &cfg, net.ParseIP("127.0.0.1")); route != nil || out.Len() > 0 {
+		t.Errorf("expected no route from another namespace, got %+v %q", route, out.String())
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Job describes a gtrace invocation as JSON, for programs that drive gtrace
+// without building a command line. Omitted fields take the CLI defaults.
+type Job struct {
+	Targets      []string `json:"targets,omitempty"`
+	Target       string   `json:"target,omitempty"` // Shorthand for a single target
+	Mode         string   `json:"mode,omitempty"`   // trace (default), mtr, compare, reverse, monitor
+	From         string   `json:"from,omitempty"`
+	Limit        int      `json:"limit,omitempty"` // GlobalPing probes per location
+	Protocol     string   `json:"protocol,omitempty"`
+	Port         int      `json:"port,omitempty"`
+	MaxHops      int      `json:"maxHops,omitempty"`
+	Packets      int      `json:"packets,omitempty"`
+	Timeout      string   `json:"timeout,omitempty"`
+	Interval     string   `json:"interval,omitempty"`
+	Cycles       int      `json:"cycles,omitempty"`
+	History      int      `json:"history,omitempty"`
+	IPVersion    int      `json:"ipVersion,omitempty"` // 4 or 6 (0 = auto)
+	Output       string   `json:"output,omitempty"`
+	Format       string   `json:"format,omitempty"`
+	OTLP         string   `json:"otlp,omitempty"`
+	APIKey       string   `json:"apiKey,omitempty"`
+	Offline      bool     `json:"offline,omitempty"`
+	GeoProvider  string   `json:"geoProvider,omitempty"` // maxmind, ipinfo or dbip
+	GeoDB        string   `json:"geoDb,omitempty"`
+	CacheDir     string   `json:"cacheDir,omitempty"`
+	BGP          bool     `json:"bgp,omitempty"`
+	LookingGlass string   `json:"lookingGlass,omitempty"`
+	Reputation   bool     `json:"reputation,omitempty"`
+	Blocklists   []string `json:"blocklists,omitempty"`
+	GeoValidate  bool     `json:"geoValidate,omitempty"`
+	HostStats    bool     `json:"hostStats,omitempty"`
+	ResolveDebug bool     `json:"resolveDebug,omitempty"`
+	DNS          string   `json:"dns,omitempty"`
+	AlertFlap    string   `json:"alertFlap,omitempty"`
+	Alerts       []string `json:"alerts,omitempty"`       // Monitor mode: alert rules
+	Schedule     string   `json:"schedule,omitempty"`     // Monitor mode: cron expression
+	ResolveEvery int      `json:"resolveEvery,omitempty"` // Monitor mode: cycles between target re-resolutions
+	JSON         bool     `json:"json,omitempty"`         // Monitor mode: JSON lines output
+	HistoryFile  string   `json:"historyFile,omitempty"`  // Monitor mode: trace history file
+	FailOn       []string `json:"failOn,omitempty"`
+	DryRun       bool     `json:"dryRun,omitempty"`
+}
+
+// readJob decodes a job from the file at path, or from stdin when path is "-".
+// Unknown fields are rejected so typos don't silently fall back to defaults.
+func readJob(stdin io.Reader, path string) (*Job, error) {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open job file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var job Job
+	if err := dec.Decode(&job); err != nil {
+		return nil, fmt.Errorf("invalid job: %w", err)
+	}
+	return &job, nil
+}
+
+// Config returns the CLI configuration equivalent to the job, starting from
+// the flag defaults. Targets are left to prepareConfig (see TargetList).
+func (j *Job) Config() (Config, error) {
+	cfg := defaultConfig()
+
+	switch j.Mode {
+	case "", "trace":
+		cfg.Simple = true
+	case "mtr":
+	case "compare":
+		cfg.Compare = true
+	case "reverse":
+		cfg.Reverse = true
+	case "monitor":
+		cfg.Monitor = true
+	default:
+		return Config{}, fmt.Errorf("invalid mode %q: must be trace, mtr, compare, reverse, or monitor", j.Mode)
+	}
+
+	switch j.IPVersion {
+	case 0:
+	case 4:
+		cfg.IPv4Only = true
+	case 6:
+		cfg.IPv6Only = true
+	default:
+		return Config{}, fmt.Errorf("invalid ipVersion %d: must be 4 or 6", j.IPVersion)
+	}
+
+	if j.From != "" {
+		cfg.From = j.From
+	}
+	cfg.Limit = j.Limit
+	if j.Protocol != "" {
+		cfg.Protocol = j.Protocol
+	}
+	if j.Port != 0 {
+		cfg.Port = j.Port
+	}
+	if j.MaxHops != 0 {
+		cfg.MaxHops = j.MaxHops
+	}
+	if j.Packets != 0 {
+		cfg.Packets = j.Packets
+		cfg.packetsSet = true
+	}
+	if j.Timeout != "" {
+		cfg.Timeout = j.Timeout
+	}
+	if j.Interval != "" {
+		cfg.Interval = j.Interval
+	}
+	if j.Cycles != 0 {
+		cfg.Cycles = j.Cycles
+	}
+	if j.History != 0 {
+		cfg.History = j.History
+	}
+	cfg.Output = j.Output
+	cfg.Format = j.Format
+	cfg.OTLP = j.OTLP
+	cfg.APIKey = j.APIKey
+	cfg.Offline = j.Offline
+	if j.GeoProvider != "" {
+		cfg.GeoProvider = j.GeoProvider
+	}
+	cfg.GeoDB = j.GeoDB
+	cfg.CacheDir = j.CacheDir
+	cfg.BGP = j.BGP
+	cfg.LookingGlass = j.LookingGlass
+	cfg.Reputation = j.Reputation
+	cfg.Blocklists = j.Blocklists
+	cfg.GeoValidate = j.GeoValidate
+	cfg.HostStats = j.HostStats
+	cfg.ResolveDebug = j.ResolveDebug
+	cfg.DNS = j.DNS
+	if j.AlertFlap != "" {
+		cfg.AlertFlap = j.AlertFlap
+	}
+	cfg.Alerts = j.Alerts
+	cfg.Schedule = j.Schedule
+	if j.ResolveEvery != 0 {
+		cfg.ResolveEvery = j.ResolveEvery
+	}
+	cfg.JSON = j.JSON
+	cfg.HistoryFile = j.HistoryFile
+	cfg.FailOn = j.FailOn
+	cfg.DryRun = j.DryRun
+
+	return cfg, nil
+}
+
+// TargetList returns the job's targets, including the single-target shorthand.
+func (j *Job) TargetList() []string {
+	targets := append([]string{}, j.Targets...)
+	if j.Target != "" {
+		targets = append([]string{j.Target}, targets...)
+	}
+	return targets
+}
+
+// detachFromStdin adapts cfg to a job read from stdin. Stdin is consumed by
+// then, so a TUI would get no keys: modes with a plain output use it, and the
+// MTR TUI, which has none, is rejected.
+func detachFromStdin(cfg *Config, mode string) error {
+	if mode == "mtr" {
+		return fmt.Errorf(`mode "mtr" runs the interactive TUI, which cannot read keys once the job is read from stdin: pass the job as a file`)
+	}
+	cfg.Simple = true
+	return nil
+}
+
+// NewRunCmd creates the run subcommand, which executes a JSON job description.
+func NewRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <job.json|->",
+		Short: "Run a trace described by a JSON job",
+		Long: `Run a trace from a JSON job description read from a file, or from stdin
+when the argument is "-". Jobs go through the same validation as the
+command line flags; omitted fields take the flag defaults. A job read from
+stdin never starts a TUI: compare and remote traces print their plain output,
+and mode mtr must be given as a file.
+
+Fields: targets, target, mode (trace|mtr|compare|reverse|monitor), from,
+protocol, port, maxHops, packets, timeout, interval, cycles, history,
+ipVersion, output, format, apiKey, offline, geoProvider, geoDb, cacheDir,
+bgp, lookingGlass, geoValidate, alerts, json, historyFile, failOn, dryRun.
+
+Examples:
+  echo '{"target":"google.com","protocol":"tcp","port":443}' | sudo gtrace run -
+  echo '{"targets":["1.1.1.1"],"mode":"compare","from":"Paris"}' | sudo gtrace run -
+  sudo gtrace run job.json`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			job, err := readJob(cmd.InOrStdin(), args[0])
+			if err != nil {
+				return err
+			}
+			cfg, err := job.Config()
+			if err != nil {
+				return err
+			}
+			if args[0] == "-" {
+				if err := detachFromStdin(&cfg, job.Mode); err != nil {
+					return err
+				}
+			}
+			if err := prepareConfig(&cfg, job.TargetList()); err != nil {
+				return err
+			}
+			return executeConfig(cmd, &cfg)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runJob(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := SetupCmd("dev")
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetArgs(append([]string{"run"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestRunCommand_AcceptsJobFromStdin(t *testing.T) {
+	_, err := runJob(t, `{"target":"google.com","protocol":"tcp","port":443,"dryRun":true}`, "-")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunCommand_AcceptsJobFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.json")
+	os.WriteFile(path, []byte(`{"targets":["1.1.1.1","8.8.8.8"],"dryRun":true}`), 0644)
+
+	if _, err := runJob(t, "", path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunCommand_ValidatesLikeFlags(t *testing.T) {
+	_, err := runJob(t, `{"target":"google.com","mode":"compare","dryRun":true}`, "-")
+	if err == nil || !strings.Contains(err.Error(), "--compare requires --from") {
+		t.Errorf("expected compare validation error, got %v", err)
+	}
+}
+
+func TestRunCommand_RejectsMTRFromStdin(t *testing.T) {
+	_, err := runJob(t, `{"target":"google.com","mode":"mtr","dryRun":true}`, "-")
+	if err == nil || !strings.Contains(err.Error(), "pass the job as a file") {
+		t.Errorf("expected the TUI to be rejected for a job on stdin, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "job.json")
+	os.WriteFile(path, []byte(`{"target":"google.com","mode":"mtr","dryRun":true}`), 0644)
+	if _, err := runJob(t, "", path); err != nil {
+		t.Errorf("unexpected error for a job file: %v", err)
+	}
+}
+
+func TestDetachFromStdin_UsesPlainOutput(t *testing.T) {
+	for _, mode := range []string{"compare", "monitor", "reverse"} {
+		cfg, err := (&Job{Mode: mode}).Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := detachFromStdin(&cfg, mode); err != nil || !cfg.Simple {
+			t.Errorf("mode %s: Simple = %v, err = %v, want plain output", mode, cfg.Simple, err)
+		}
+	}
+}
+
+func TestRunCommand_RequiresTarget(t *testing.T) {
+	if _, err := runJob(t, `{"dryRun":true}`, "-"); err == nil {
+		t.Error("expected error for job without targets")
+	}
+}
+
+func TestReadJob_RejectsUnknownFields(t *testing.T) {
+	_, err := readJob(strings.NewReader(`{"target":"google.com","protcol":"tcp"}`), "-")
+	if err == nil || !strings.Contains(err.Error(), "protcol") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestJob_Config_AppliesDefaults(t *testing.T) {
+	job := &Job{Target: "google.com", Protocol: "udp", IPVersion: 6}
+	cfg, err := job.Config()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Simple {
+		t.Error("expected trace mode to use simple output")
+	}
+	if cfg.Protocol != "udp" || !cfg.IPv6Only {
+		t.Errorf("expected udp over IPv6, got %s (ipv6=%v)", cfg.Protocol, cfg.IPv6Only)
+	}
+	if cfg.MaxHops != 30 || cfg.Packets != 3 || cfg.Timeout != "500ms" || cfg.Port != 33434 {
+		t.Errorf("expected flag defaults, got maxHops=%d packets=%d timeout=%s port=%d",
+			cfg.MaxHops, cfg.Packets, cfg.Timeout, cfg.Port)
+	}
+}
+
+func TestJob_Config_Modes(t *testing.T) {
+	if cfg, _ := (&Job{Mode: "mtr"}).Config(); cfg.Simple {
+		t.Error("expected mtr mode to use the TUI")
+	}
+	if cfg, _ := (&Job{Mode: "monitor"}).Config(); !cfg.Monitor {
+		t.Error("expected monitor mode to set Monitor")
+	}
+	if _, err := (&Job{Mode: "bogus"}).Config(); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	if _, err := (&Job{IPVersion: 5}).Config(); err == nil {
+		t.Error("expected error for invalid ipVersion")
+	}
+}
+
+func TestJob_TargetList(t *testing.T) {
+	job := &Job{Target: "a.example", Targets: []string{"b.example"}}
+	got := job.TargetList()
+	if len(got) != 2 || got[0] != "a.example" || got[1] != "b.example" {
+		t.Errorf("TargetList() = %v", got)
+	}
+}
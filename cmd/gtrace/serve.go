@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/enrich"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/internal/web"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"github.com/spf13/cobra"
+)
+
+// NewServeCmd creates the serve subcommand, which traces targets
+// continuously and shows them on a web dashboard.
+func NewServeCmd() *cobra.Command {
+	var (
+		listen      string
+		targetsFile string
+		interval    string
+		history     int
+		protocol    string
+		port        int
+		maxHops     int
+		packets     int
+		timeout     string
+		offline     bool
+		cacheDir    string
+		ipv4        bool
+		ipv6        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve [target...]",
+		Short: "Trace targets continuously and show them on a web dashboard",
+		Long: `Trace each target every interval, like MTR mode, and serve the live
+statistics on a web dashboard: one MTR table per target with an RTT sparkline
+per hop. Click a hop to show its RTT chart.
+
+Share the dashboard with teammates without terminal access by listening on a
+reachable address. The dashboard has no authentication: keep the default
+loopback address unless the network is trusted.
+
+The statistics are also available as JSON at /api/targets.
+
+Requires root privileges (raw sockets), like local traces.
+
+Examples:
+  sudo gtrace serve google.com
+  sudo gtrace serve google.com 1.1.1.1 --listen :8080
+  sudo gtrace serve --targets-file targets.txt --interval 5s`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !validProtocols[protocol] {
+				return fmt.Errorf("invalid protocol %q: must be icmp, udp, or tcp", protocol)
+			}
+			if ipv4 && ipv6 {
+				return fmt.Errorf("-4/--ipv4 and -6/--ipv6 are mutually exclusive")
+			}
+			if history < 1 {
+				return fmt.Errorf("--history must be >= 1")
+			}
+			if _, _, err := net.SplitHostPort(listen); err != nil {
+				return fmt.Errorf("invalid --listen %q: must be host:port", listen)
+			}
+
+			every, err := time.ParseDuration(interval)
+			if err != nil || every <= 0 {
+				return fmt.Errorf("invalid interval %q", interval)
+			}
+			perHop, err := time.ParseDuration(timeout)
+			if err != nil {
+				return fmt.Errorf("invalid timeout: %w", err)
+			}
+
+			targets, err := collectTargets(args, targetsFile)
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				return fmt.Errorf("no targets: pass them as arguments or with --targets-file")
+			}
+			targets = dedupeTargets(targets)
+
+			traceCfg := &trace.Config{
+				Protocol:      trace.Protocol(protocol),
+				MaxHops:       maxHops,
+				PacketsPerHop: packets,
+				Timeout:       perHop,
+				Port:          port,
+				ProbeSize:     64,
+			}
+			if err := traceCfg.Validate(); err != nil {
+				return fmt.Errorf("invalid config: %w", err)
+			}
+
+			if err := trace.CheckPrivileges(); err != nil {
+				return err
+			}
+
+			// Bind before tracing so a busy port fails fast
+			ln, err := net.Listen("tcp", listen)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+
+			var cache *enrich.DiskCache
+			if !offline {
+				cache = openDiskCache(cmd.ErrOrStderr(), cacheDir)
+				defer saveDiskCache(cmd.ErrOrStderr(), cache)
+			}
+			enricher := newEnricher(offline, nil, cache, nil, nil, nil)
+			family := getAddressFamily(&Config{IPv4Only: ipv4, IPv6Only: ipv6})
+
+			// Resolve on every cycle so DNS changes are picked up
+			traceFn := func(ctx context.Context, target string) (*hop.TraceResult, error) {
+				targetIP, err := trace.ResolveTarget(target, family)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve target: %w", err)
+				}
+				tracer, err := trace.NewLocalTracer(traceCfg)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create tracer: %w", err)
+				}
+				result, err := tracer.Trace(ctx, targetIP, func(h *hop.Hop) {
+					if enricher != nil {
+						enricher.EnrichHop(ctx, h)
+					}
+				})
+				if err != nil {
+					return nil, fmt.Errorf("trace failed: %w", err)
+				}
+				result.TargetIP = targetIP.String()
+				return result, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigCh
+				cancel()
+			}()
+
+			rec := web.NewRecorder(targets, history)
+			var wg sync.WaitGroup
+			for _, target := range targets {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					serveTarget(ctx, rec, target, every, traceFn)
+				}()
+			}
+
+			srv := &http.Server{Handler: web.Handler(rec.Snapshot)}
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+				defer done()
+				srv.Shutdown(shutdownCtx)
+			}()
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Tracing %d target(s) every %v\n", len(targets), every)
+			fmt.Fprintf(cmd.OutOrStdout(), "Dashboard at http://%s/\n", ln.Addr())
+			fmt.Fprintln(cmd.OutOrStdout(), "Press Ctrl+C to stop")
+
+			err = srv.Serve(ln)
+			cancel()
+			wg.Wait()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("server error: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:8080", "Address of the dashboard")
+	cmd.Flags().StringVar(&targetsFile, "targets-file", "", "File with additional targets (one per line, # comments)")
+	cmd.Flags().StringVar(&interval, "interval", "1s", "Time between traces of each target")
+	cmd.Flags().IntVar(&history, "history", 100, "RTT samples kept per hop for the charts")
+	cmd.Flags().StringVar(&protocol, "protocol", "icmp", "Protocol: icmp|udp|tcp")
+	cmd.Flags().IntVar(&port, "port", 33434, "Port for TCP/UDP")
+	cmd.Flags().IntVar(&maxHops, "max-hops", 30, "Maximum hops")
+	cmd.Flags().IntVar(&packets, "packets", 1, "Packets per hop per cycle")
+	cmd.Flags().StringVar(&timeout, "timeout", "500ms", "Per-hop timeout")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Use only local enrichment DBs")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Enrichment cache directory (default: ~/.gtr/cache)")
+	cmd.Flags().BoolVarP(&ipv4, "ipv4", "4", false, "Use IPv4 only")
+	cmd.Flags().BoolVarP(&ipv6, "ipv6", "6", false, "Use IPv6 only")
+
+	return cmd
+}
+
+// serveTarget traces target every interval into rec until ctx is done.
+func serveTarget(ctx context.Context, rec *web.Recorder, target string, every time.Duration, traceFn func(context.Context, string) (*hop.TraceResult, error)) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		result, err := traceFn(ctx, target)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			rec.RecordError(target, err)
+		} else {
+			rec.Record(target, result)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dedupeTargets removes repeated targets, keeping the first occurrence.
+func dedupeTargets(targets []string) []string {
+	var out []string
+	for _, t := range targets {
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestServeCommand_ValidatesFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no targets", nil, "no targets"},
+		{"protocol", []string{"example.com", "--protocol", "sctp"}, "invalid protocol"},
+		{"interval", []string{"example.com", "--interval", "soon"}, "invalid interval"},
+		{"history", []string{"example.com", "--history", "0"}, "--history"},
+		{"listen", []string{"example.com", "--listen", "8080"}, "invalid --listen"},
+		{"ip family", []string{"example.com", "-4", "-6"}, "mutually exclusive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewServeCmd()
+			buf := new(bytes.Buffer)
+			cmd.SetOut(buf)
+			cmd.SetErr(buf)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDedupeTargets(t *testing.T) {
+	got := dedupeTargets([]string{"a", "b", "a", "c", "b"})
+	if strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("expected a,b,c, got %v", got)
+	}
+}
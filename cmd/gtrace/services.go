@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+// runServiceMatrixMode traces the target over every --services protocol and
+// port at once and shows, hop by hop, which address answered each of them,
+// followed by which services reach the target and where their paths part.
+func runServiceMatrixMode(ctx context.Context, cmd *cobra.Command, cfg *Config) error {
+	targetIP, err := trace.ResolveTarget(cfg.Target, getAddressFamily(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	names := make([]string, len(cfg.services))
+	for i, svc := range cfg.services {
+		names[i] = svc.String()
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Service matrix to %s (%s): %s\n\n", cfg.Target, targetIP, strings.Join(names, ", "))
+
+	traces := trace.TraceServices(ctx, &trace.Config{
+		MaxHops:       cfg.MaxHops,
+		PacketsPerHop: cfg.Packets,
+		Timeout:       timeout,
+		ProbeSize:     cfg.ProbeSize,
+		Capture:       cfg.capture,
+		Shards:        cfg.Shards,
+		Scheduler:     cfg.scheduler,
+		NetNS:         cfg.NetNS,
+		IPv6:          cfg.ipv6Opts,
+		Parallel:      !cfg.Sequential,
+		ServerName:    cfg.Target,
+	}, targetIP, cfg.services)
+	if ctx.Err() != nil {
+		fmt.Fprintln(cmd.OutOrStdout(), "Trace interrupted")
+		return nil
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), formatServiceMatrix(traces))
+	return nil
+}
+
+// formatServiceMatrix formats the hop by service table of answering
+// addresses, then the reachability of each service and the diagnosis. An
+// address that differs from the first one answered at its hop is marked "!".
+func formatServiceMatrix(traces []trace.ServiceTrace) string {
+	width := 15
+	maxTTL := 0
+	for _, st := range traces {
+		width = max(width, len(st.Service.String()))
+		if st.Result == nil {
+			continue
+		}
+		for _, h := range st.Result.Hops {
+			maxTTL = max(maxTTL, h.TTL)
+			if ip := h.PrimaryIP(); ip != nil {
+				width = max(width, len(ip.String())+2)
+			}
+		}
+	}
+
+	var b strings.Builder
+	row := []string{"Hop"}
+	for _, st := range traces {
+		row = append(row, fmt.Sprintf("%-*s", width, st.Service))
+	}
+	writeMatrixRow(&b, row)
+
+	for ttl := 1; ttl <= maxTTL; ttl++ {
+		row := []string{fmt.Sprintf("%3d", ttl)}
+		var first string
+		for _, st := range traces {
+			cell := ""
+			if st.Result != nil {
+				if h := st.Result.GetHop(ttl); h != nil {
+					cell = "*"
+					if ip := h.PrimaryIP(); ip != nil {
+						cell = ip.String()
+						if first == "" {
+							first = cell
+						} else if cell != first {
+							cell += " !"
+						}
+					}
+				}
+			}
+			row = append(row, fmt.Sprintf("%-*s", width, cell))
+		}
+		writeMatrixRow(&b, row)
+	}
+
+	b.WriteString("\n")
+	var reached, unreached []trace.ServiceTrace
+	for _, st := range traces {
+		switch {
+		case st.Err != nil:
+			fmt.Fprintf(&b, "%-10s failed: %v\n", st.Service, st.Err)
+			continue
+		case st.Result.ReachedTarget && len(st.Result.Hops) > 0:
+			last := st.Result.Hops[len(st.Result.Hops)-1]
+			fmt.Fprintf(&b, "%-10s reached in %d hop%s, %.1f ms\n", st.Service, last.TTL, pluralS(last.TTL),
+				float64(last.AvgRTT().Microseconds())/1000)
+			reached = append(reached, st)
+			continue
+		}
+		unreached = append(unreached, st)
+		if last := lastAnswered(st); last != "" {
+			fmt.Fprintf(&b, "%-10s not reached, last answer %s\n", st.Service, last)
+		} else {
+			fmt.Fprintf(&b, "%-10s not reached, no answer\n", st.Service)
+		}
+	}
+
+	if ttl, addrs := trace.PathDivergence(traces); ttl > 0 {
+		var parts []string
+		for _, st := range traces {
+			if ip, ok := addrs[st.Service]; ok {
+				parts = append(parts, fmt.Sprintf("%s %s", st.Service, ip))
+			}
+		}
+		fmt.Fprintf(&b, "Paths diverge at hop %d: %s\n", ttl, strings.Join(parts, ", "))
+	}
+
+	switch {
+	case len(unreached) == 0 && len(reached) > 0:
+		b.WriteString("\nAll services reach the target\n")
+	case len(reached) == 0 && len(unreached) > 0:
+		b.WriteString("\nNo service reaches the target: the block is not specific to a protocol or port\n")
+	case len(reached) > 0:
+		for _, st := range unreached {
+			fmt.Fprintf(&b, "\n%s is filtered past its last answering hop while %s reaches the target\n",
+				st.Service, reached[0].Service)
+		}
+	}
+	return b.String()
+}
+
+// writeMatrixRow writes the cells of a matrix row two spaces apart, without
+// the padding of the last one.
+func writeMatrixRow(b *strings.Builder, cells []string) {
+	b.WriteString(strings.TrimRight(strings.Join(cells, "  "), " "))
+	b.WriteString("\n")
+}
+
+// lastAnswered returns "at hop N (address)" for the deepest hop of st that
+// answered, or "" when none did.
+func lastAnswered(st trace.ServiceTrace) string {
+	for i := len(st.Result.Hops) - 1; i >= 0; i-- {
+		h := st.Result.Hops[i]
+		if ip := h.PrimaryIP(); ip != nil {
+			return fmt.Sprintf("at hop %d (%s)", h.TTL, ip)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// matrixTestTrace returns a trace of svc answered by ips, one hop each; ""
+// is a silent hop.
+func matrixTestTrace(svc string, reached bool, ips ...string) trace.ServiceTrace {
+	s, _ := trace.ParseService(svc)
+	tr := hop.NewTraceResult("example.com", "192.0.2.1")
+	for i, ip := range ips {
+		h := hop.NewHop(i + 1)
+		if ip == "" {
+			h.AddTimeout()
+		} else {
+			h.AddProbe(net.ParseIP(ip), 12*time.Millisecond)
+		}
+		tr.AddHop(h)
+	}
+	tr.ReachedTarget = reached
+	return trace.ServiceTrace{Service: s, Result: tr}
+}
+
+func TestFormatServiceMatrix_FilteredService(t *testing.T) {
+	out := formatServiceMatrix([]trace.ServiceTrace{
+		matrixTestTrace("icmp", true, "10.0.0.1", "10.0.1.1", "192.0.2.1"),
+		matrixTestTrace("udp:53", false, "10.0.0.1", "", ""),
+		matrixTestTrace("tcp:443", true, "10.0.0.1", "10.0.1.9", "192.0.2.1"),
+	})
+
+	for _, want := range []string{
+		"Hop  icmp",
+		"  2  10.0.1.1         *                10.0.1.9 !",
+		"icmp       reached in 3 hops, 12.0 ms",
+		"udp:53     not reached, last answer at hop 1 (10.0.0.1)",
+		"Paths diverge at hop 2: icmp 10.0.1.1, tcp:443 10.0.1.9",
+		"udp:53 is filtered past its last answering hop while icmp reaches the target",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatServiceMatrix_AllReached(t *testing.T) {
+	out := formatServiceMatrix([]trace.ServiceTrace{
+		matrixTestTrace("icmp", true, "10.0.0.1", "192.0.2.1"),
+		matrixTestTrace("tcp:80", true, "10.0.0.1", "192.0.2.1"),
+		{Service: trace.Service{Protocol: trace.ProtocolUDP, Port: 53}, Err: errors.New("permission denied")},
+	})
+
+	for _, want := range []string{"udp:53     failed: permission denied", "All services reach the target"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Paths diverge") {
+		t.Errorf("unexpected divergence in:\n%s", out)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
+)
+
+// userConfig is the user configuration file (~/.gtr/config.json).
+type userConfig struct {
+	Theme      string                        `json:"theme,omitempty"`      // Theme used when --theme is not set
+	Themes     map[string]display.Theme      `json:"themes,omitempty"`     // User-defined themes by name
+	AlertSinks map[string]monitor.SinkConfig `json:"alertSinks,omitempty"` // Alert destinations by name
+	AlertRules []alertRuleConfig             `json:"alertRules,omitempty"` // Rules evaluated in every --monitor run
+
+	MetricSinks map[string]monitor.MetricSinkConfig `json:"metricSinks,omitempty"` // Time-series databases fed every --monitor cycle
+}
+
+// alertRuleConfig is an alert rule of the config file with the names of the
+// sinks its alerts are sent to.
+type alertRuleConfig struct {
+	Rule  string   `json:"rule"`
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+// defaultUserConfigPath returns the default config file path (~/.gtr/config.json).
+func defaultUserConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gtr", "config.json")
+}
+
+// loadUserConfig reads the config file at path. A missing file is an empty
+// configuration; unknown fields are rejected so typos are reported.
+func loadUserConfig(path string) (*userConfig, error) {
+	var uc userConfig
+	if path == "" {
+		return &uc, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &uc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&uc); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &uc, nil
+}
+
+// loadTheme resolves the color theme: name (from --theme) when set,
+// otherwise the config file's theme, otherwise the default theme.
+func loadTheme(name, configPath string) (display.Theme, error) {
+	uc, err := loadUserConfig(configPath)
+	if err != nil {
+		return display.Theme{}, err
+	}
+	if name == "" {
+		name = uc.Theme
+	}
+	return display.ResolveTheme(name, uc.Themes)
+}
+
+// loadAlertRules returns the alert rules of the config file at path, with
+// their sinks.
+func loadAlertRules(path string) ([]*monitor.Rule, error) {
+	uc, err := loadUserConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sinks := make(map[string]monitor.Sink, len(uc.AlertSinks))
+	for name, sc := range uc.AlertSinks {
+		sink, err := monitor.NewSink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("alert sink %q in %s: %w", name, path, err)
+		}
+		sinks[name] = sink
+	}
+
+	var rules []*monitor.Rule
+	for _, rc := range uc.AlertRules {
+		rule, err := monitor.ParseRule(rc.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, name := range rc.Sinks {
+			sink, ok := sinks[name]
+			if !ok {
+				return nil, fmt.Errorf("alert rule %q in %s: unknown sink %q", rc.Rule, path, name)
+			}
+			rule.Sinks = append(rule.Sinks, sink)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// loadMetricSinks returns the metric sinks of the config file at path.
+func loadMetricSinks(path string) ([]monitor.MetricSink, error) {
+	uc, err := loadUserConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinks []monitor.MetricSink
+	for name, sc := range uc.MetricSinks {
+		sink, err := monitor.NewMetricSink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("metric sink %q in %s: %w", name, path, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
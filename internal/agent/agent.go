@@ -0,0 +1,197 @@
+// Package agent runs gtrace probes on another host over SSH and streams
+// their results back.
+//
+// The local gtrace runs "gtrace agent" on the remote host through ssh and
+// writes a Request, then any number of Controls, one JSON object per line,
+// to its standard input. The agent answers on its standard output with a
+// session stream (see package session): a header with the address the
+// remote host resolved the target to, then every probe result and
+// completed cycle as it happens. The agent stops when its standard input is
+// closed or the requested cycles are done.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/netwatch"
+	"github.com/hervehildenbrand/gtrace/internal/session"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+)
+
+// Version is the agent protocol version. Both ends must speak the same one.
+const Version = 1
+
+// WriteHandshake writes what "gtrace agent --protocol" prints: the agent
+// protocol version and the gtrace release, so that the local gtrace can
+// tell whether an installed gtrace can serve it.
+func WriteHandshake(w io.Writer, release string) {
+	fmt.Fprintf(w, "protocol=%d\nversion=%s\n", Version, release)
+}
+
+// Request describes the MTR session the agent runs.
+type Request struct {
+	Version     int           `json:"version"`
+	Target      string        `json:"target"`
+	IPVersion   int           `json:"ipVersion,omitempty"` // 4, 6 or 0 for either
+	Protocol    string        `json:"protocol"`
+	Port        int           `json:"port,omitempty"`
+	MaxHops     int           `json:"maxHops"`
+	Timeout     time.Duration `json:"timeout"`
+	Packets     int           `json:"packets"` // Probes per hop and cycle
+	ProbeSize   int           `json:"probeSize,omitempty"`
+	Interval    time.Duration `json:"interval"`
+	Cycles      int           `json:"cycles,omitempty"` // 0 = until the input is closed
+	DetectNAT   bool          `json:"detectNAT,omitempty"`
+	ECMPFlows   int           `json:"ecmpFlows,omitempty"`
+	DiscoverMTU bool          `json:"discoverMTU,omitempty"`
+	Decode      bool          `json:"decode,omitempty"`
+}
+
+// Control changes a running session, like the keys of the local TUI.
+type Control struct {
+	Pause     *bool         `json:"pause,omitempty"`     // Stop or resume probing
+	Interval  time.Duration `json:"interval,omitempty"`  // New time between cycles
+	ProbeSize int           `json:"probeSize,omitempty"` // New probe size in bytes
+}
+
+// traceConfig returns the trace configuration of r.
+func (r *Request) traceConfig() *trace.Config {
+	return &trace.Config{
+		Protocol:      trace.Protocol(r.Protocol),
+		MaxHops:       r.MaxHops,
+		PacketsPerHop: 1,
+		Timeout:       r.Timeout,
+		Port:          r.Port,
+		DetectNAT:     r.DetectNAT,
+		ECMPFlows:     r.ECMPFlows,
+		DiscoverMTU:   r.DiscoverMTU,
+		ProbeSize:     r.ProbeSize,
+		Decode:        r.Decode,
+		ServerName:    r.Target,
+	}
+}
+
+// family returns the address family the target is resolved in.
+func (r *Request) family() trace.AddressFamily {
+	switch r.IPVersion {
+	case 4:
+		return trace.AddressFamilyIPv4
+	case 6:
+		return trace.AddressFamilyIPv6
+	}
+	return trace.AddressFamilyAuto
+}
+
+// Serve runs the agent: it reads a Request from in, streams the results of
+// the session to out and applies the Controls read from in until in is
+// closed or the requested cycles are done.
+func Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	return serve(ctx, in, out, trace.NewLocalTracer, trace.ResolveTarget)
+}
+
+func serve(ctx context.Context, in io.Reader, out io.Writer, newTracer func(*trace.Config) (trace.Tracer, error), resolve func(string, trace.AddressFamily) (net.IP, error)) error {
+	dec := json.NewDecoder(in)
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	if req.Version != Version {
+		return fmt.Errorf("agent protocol version %d is not supported (this gtrace speaks version %d), run the same gtrace release on both hosts", req.Version, Version)
+	}
+	if req.Interval <= 0 {
+		return fmt.Errorf("invalid interval %v", req.Interval)
+	}
+	packets := max(req.Packets, 1)
+
+	cfg := req.traceConfig()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	ip, err := resolve(req.Target, req.family())
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+	tracer, err := newTracer(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create tracer: %w", err)
+	}
+
+	rec, err := session.NewStreamRecorder(out, session.Header{
+		Target:   req.Target,
+		TargetIP: ip.String(),
+		Protocol: req.Protocol,
+		Interval: req.Interval,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ct := trace.NewContinuousTracer(cfg, tracer, req.Interval)
+	ct.SetTracerFactory(newTracer)
+	ct.SetPacketsPerHop(packets)
+	ct.SetAdaptiveMaxHops(true)
+
+	// The session ends when the local gtrace goes away
+	go func() {
+		defer cancel()
+		for {
+			var c Control
+			if err := dec.Decode(&c); err != nil {
+				return
+			}
+			if c.Pause != nil {
+				ct.SetPaused(*c.Pause)
+			}
+			if c.Interval > 0 {
+				ct.SetInterval(c.Interval)
+			}
+			if c.ProbeSize > 0 {
+				ct.SetProbeSize(c.ProbeSize)
+			}
+		}
+	}()
+
+	// Cycles spanning a sleep or network change of the remote host are
+	// discarded there like locally
+	ct.SetNetworkWatcher(netwatch.New(ip), func(cycle int, ev netwatch.Event) {
+		rec.Cycle(display.CycleCompleteMsg{Cycle: cycle, Invalid: true, Event: ev.String()})
+	})
+
+	ct.Run(ctx, ip, func(pr trace.ProbeResult) {
+		rec.Probe(probeMsg(pr))
+	}, func(cycle int, reached bool) {
+		rec.Cycle(display.CycleCompleteMsg{Cycle: cycle, Reached: reached})
+		if req.Cycles > 0 && cycle >= req.Cycles {
+			cancel()
+		}
+	})
+	return rec.Close()
+}
+
+// probeMsg converts a probe result into the message shown by the TUI.
+func probeMsg(pr trace.ProbeResult) display.ProbeResultMsg {
+	return display.ProbeResultMsg{
+		TTL:            pr.TTL,
+		IP:             pr.IP,
+		RTT:            pr.RTT,
+		Timeout:        pr.Timeout,
+		MPLS:           pr.MPLS,
+		ICMPType:       pr.ICMPType,
+		ICMPCode:       pr.ICMPCode,
+		OriginalTTL:    pr.OriginalTTL,
+		FlowID:         pr.FlowID,
+		TransportInfo:  pr.TransportInfo,
+		NAT:            pr.NAT,
+		MTU:            pr.MTU,
+		SegmentRouting: pr.SegmentRouting,
+	}
+}
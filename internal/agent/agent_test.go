@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/internal/session"
+	"github.com/hervehildenbrand/gtrace/internal/trace"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// fakeTracer answers at TTL 1 from a router and at TTL 2 from the target.
+type fakeTracer struct{}
+
+func (fakeTracer) Trace(ctx context.Context, target net.IP, callback trace.HopCallback) (*hop.TraceResult, error) {
+	result := hop.NewTraceResult(target.String(), target.String())
+	for ttl, ip := range []net.IP{net.ParseIP("192.168.1.1"), target} {
+		h := hop.NewHop(ttl + 1)
+		h.AddProbe(ip, time.Duration(ttl+1)*time.Millisecond)
+		result.AddHop(h)
+		if callback != nil {
+			callback(h)
+		}
+	}
+	result.ReachedTarget = true
+	return result, nil
+}
+
+func fakeResolve(target string, af trace.AddressFamily) (net.IP, error) {
+	if target != "example.com" {
+		return nil, errors.New("no such host")
+	}
+	return net.ParseIP("93.184.216.34"), nil
+}
+
+func newFakeTracer(*trace.Config) (trace.Tracer, error) {
+	return fakeTracer{}, nil
+}
+
+func request() Request {
+	return Request{
+		Version:  Version,
+		Target:   "example.com",
+		Protocol: "icmp",
+		MaxHops:  30,
+		Timeout:  time.Second,
+		Packets:  1,
+		Interval: 10 * time.Millisecond,
+		Cycles:   2,
+	}
+}
+
+func TestServe_StreamsSession(t *testing.T) {
+	// The input stays open: the agent stops after the requested cycles
+	in, inw := io.Pipe()
+	defer inw.Close()
+	go json.NewEncoder(inw).Encode(request())
+
+	var out bytes.Buffer
+	if err := serve(context.Background(), in, &out, newFakeTracer, fakeResolve); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	s, err := session.Read(&out)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if s.Header.Target != "example.com" || s.Header.TargetIP != "93.184.216.34" || s.Header.Protocol != "icmp" {
+		t.Errorf("unexpected header: %+v", s.Header)
+	}
+	if s.Cycles() != 2 {
+		t.Errorf("expected 2 cycles, got %d", s.Cycles())
+	}
+	probe, ok := s.Events[0].Msg().(display.ProbeResultMsg)
+	if !ok || probe.TTL != 1 || !probe.IP.Equal(net.ParseIP("192.168.1.1")) {
+		t.Errorf("unexpected first event: %+v", s.Events[0])
+	}
+	last, ok := s.Events[len(s.Events)-1].Msg().(display.CycleCompleteMsg)
+	if !ok || last.Cycle != 2 || !last.Reached {
+		t.Errorf("unexpected last event: %+v", last)
+	}
+}
+
+func TestServe_StopsWhenInputCloses(t *testing.T) {
+	req := request()
+	req.Cycles = 0
+	data, _ := json.Marshal(req)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(context.Background(), bytes.NewReader(append(data, '\n')), io.Discard, newFakeTracer, fakeResolve)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent kept running after its input was closed")
+	}
+}
+
+func TestServe_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Request)
+		wantErr string
+	}{
+		{"version", func(r *Request) { r.Version = 99 }, "protocol version 99 is not supported"},
+		{"interval", func(r *Request) { r.Interval = 0 }, "invalid interval"},
+		{"protocol", func(r *Request) { r.Protocol = "sctp" }, "invalid request"},
+		{"unknown target", func(r *Request) { r.Target = "nowhere.invalid" }, "failed to resolve target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request()
+			tt.modify(&req)
+			data, _ := json.Marshal(req)
+			var out bytes.Buffer
+			err := serve(context.Background(), bytes.NewReader(data), &out, newFakeTracer, fakeResolve)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("expected no session header on error, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestParseDest(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Dest
+		wantErr bool
+	}{
+		{"ssh://jump.example.net", Dest{Host: "jump.example.net"}, false},
+		{"ssh://ops@jump.example.net", Dest{User: "ops", Host: "jump.example.net"}, false},
+		{"ssh://ops@jump.example.net:2222", Dest{User: "ops", Host: "jump.example.net", Port: 2222}, false},
+		{"ssh://[2001:db8::1]:22", Dest{Host: "2001:db8::1", Port: 22}, false},
+		{"jump.example.net", Dest{}, true},
+		{"https://jump.example.net", Dest{}, true},
+		{"ssh://jump.example.net/tmp", Dest{}, true},
+		{"ssh://jump.example.net:99999", Dest{}, true},
+		{"ssh://-oProxyCommand=id", Dest{}, true},
+		{"ssh://-oProxyCommand=id@jump.example.net", Dest{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDest(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDest(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDest(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDest_SSHArgs(t *testing.T) {
+	d := Dest{User: "ops", Host: "jump", Port: 2222}
+	got := strings.Join(d.sshArgs("uname"), " ")
+	if got != "-T -o ServerAliveInterval=15 -p 2222 -- ops@jump uname" {
+		t.Errorf("unexpected ssh arguments: %s", got)
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		kernel, machine string
+		goos, goarch    string
+	}{
+		{"Linux", "x86_64", "linux", "amd64"},
+		{"Linux", "aarch64", "linux", "arm64"},
+		{"Darwin", "arm64", "darwin", "arm64"},
+		{"Linux", "armv7l", "linux", "arm"},
+		{"FreeBSD", "amd64", "freebsd", "amd64"},
+	}
+	for _, tt := range tests {
+		goos, goarch := platform(tt.kernel, tt.machine)
+		if goos != tt.goos || goarch != tt.goarch {
+			t.Errorf("platform(%q, %q) = %s/%s, want %s/%s", tt.kernel, tt.machine, goos, goarch, tt.goos, tt.goarch)
+		}
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	kv := parseKeyValues([]byte("os=Linux\narch=x86_64\ngtrace=\ncached=yes\n"))
+	if kv["os"] != "Linux" || kv["arch"] != "x86_64" || kv["gtrace"] != "" || kv["cached"] != "yes" {
+		t.Errorf("unexpected values: %v", kv)
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	var buf bytes.Buffer
+	WriteHandshake(&buf, "v1.4.0")
+	if !compatible(parseKeyValues(buf.Bytes()), "v1.4.0") {
+		t.Errorf("expected the handshake of the same release to match: %q", buf.String())
+	}
+
+	tests := []struct {
+		name string
+		out  string
+	}{
+		{"other release", "protocol=1\nversion=v1.3.0\n"},
+		{"other protocol", "protocol=2\nversion=v1.4.0\n"},
+		{"no agent", "os=Linux\ngtrace=/usr/bin/gtrace\n"},
+	}
+	for _, tt := range tests {
+		if compatible(parseKeyValues([]byte(tt.out)), "v1.4.0") {
+			t.Errorf("%s: expected no match", tt.name)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("unexpected quoting: %s", got)
+	}
+}
@@ -0,0 +1,372 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/session"
+)
+
+// Dest is a host to run the agent on.
+type Dest struct {
+	User string // Empty for the ssh default
+	Host string
+	Port int // 0 for the ssh default
+}
+
+// ParseDest parses a destination given as ssh://[user@]host[:port].
+func ParseDest(s string) (Dest, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return Dest{}, fmt.Errorf("must be ssh://[user@]host[:port]")
+	}
+	d := Dest{Host: u.Hostname(), User: u.User.Username()}
+	// ssh would take them for options
+	if strings.HasPrefix(d.Host, "-") || strings.HasPrefix(d.User, "-") {
+		return Dest{}, fmt.Errorf("user and host must not start with '-'")
+	}
+	if p := u.Port(); p != "" {
+		d.Port, err = strconv.Atoi(p)
+		if err != nil || d.Port < 1 || d.Port > 65535 {
+			return Dest{}, fmt.Errorf("invalid port %q", p)
+		}
+	}
+	return d, nil
+}
+
+// String returns the destination as given to ssh: [user@]host.
+func (d Dest) String() string {
+	if d.User != "" {
+		return d.User + "@" + d.Host
+	}
+	return d.Host
+}
+
+// sshArgs returns the ssh arguments running command on d.
+func (d Dest) sshArgs(command string) []string {
+	args := []string{"-T", "-o", "ServerAliveInterval=15"}
+	if d.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(d.Port))
+	}
+	return append(args, "--", d.String(), command)
+}
+
+// Options controls how the agent is started.
+type Options struct {
+	Sudo    bool   // Run the agent with sudo -n (raw sockets need root on most hosts)
+	SSH     string // ssh client to run (default "ssh")
+	Release string // Release of this gtrace, which an installed gtrace must match
+}
+
+// agentDir is where uploaded agents are kept on the remote host, relative
+// to the home directory.
+const agentDir = ".cache/gtrace"
+
+// Client is a running agent.
+type Client struct {
+	// Header describes the session: the target address as resolved by
+	// the remote host.
+	Header session.Header
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	dec    *session.Decoder
+	stderr bytes.Buffer
+
+	mu  sync.Mutex
+	enc *json.Encoder
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+// Start runs the agent on dest and sends it req. It returns once the agent
+// resolved the target and started probing. ssh may prompt for a password
+// on the terminal meanwhile.
+func Start(ctx context.Context, dest Dest, req Request, opts Options) (*Client, error) {
+	if opts.SSH == "" {
+		opts.SSH = "ssh"
+	}
+	req.Version = Version
+
+	path, err := locateAgent(ctx, dest, opts)
+	if err != nil {
+		return nil, err
+	}
+	command := shellQuote(path) + " agent"
+	if opts.Sudo {
+		command = "sudo -n " + command
+	}
+
+	c := &Client{}
+	c.cmd = exec.CommandContext(ctx, opts.SSH, dest.sshArgs(command)...)
+	c.cmd.Stderr = &limitedWriter{w: &c.stderr, n: 4096}
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if c.stdin, err = c.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	c.enc = json.NewEncoder(c.stdin)
+	if err := c.enc.Encode(req); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to send request to %s: %w", dest, err)
+	}
+
+	c.dec, err = session.NewDecoder(stdout)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("agent on %s failed: %s", dest, c.failure(err))
+	}
+	c.Header = c.dec.Header
+	return c, nil
+}
+
+// Next returns the next event of the session. It returns io.EOF once the
+// requested cycles are done.
+func (c *Client) Next() (session.Event, error) {
+	ev, err := c.dec.Next()
+	if err == io.EOF {
+		if werr := c.wait(); werr != nil {
+			return ev, fmt.Errorf("agent failed: %s", c.failure(werr))
+		}
+	}
+	return ev, err
+}
+
+// SetPaused stops or resumes probing.
+func (c *Client) SetPaused(paused bool) {
+	c.send(Control{Pause: &paused})
+}
+
+// SetInterval changes the time between cycles.
+func (c *Client) SetInterval(d time.Duration) {
+	c.send(Control{Interval: d})
+}
+
+// SetProbeSize changes the size of probes.
+func (c *Client) SetProbeSize(size int) {
+	c.send(Control{ProbeSize: size})
+}
+
+func (c *Client) send(ctl Control) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A lost agent shows as the end of the session
+	_ = c.enc.Encode(ctl)
+}
+
+// closeTimeout is how long Close waits for the agent to exit before
+// killing ssh, e.g. when the remote host became unreachable.
+const closeTimeout = 5 * time.Second
+
+// Close stops the agent.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.stdin.Close()
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(closeTimeout):
+		c.cmd.Process.Kill()
+		<-done
+	}
+	return nil
+}
+
+// wait waits for ssh to exit and returns its error.
+func (c *Client) wait() error {
+	c.waitOnce.Do(func() { c.waitErr = c.cmd.Wait() })
+	return c.waitErr
+}
+
+// failure describes why the agent stopped, from what it wrote on stderr.
+func (c *Client) failure(err error) string {
+	c.wait()
+	msg := strings.TrimSpace(c.stderr.String())
+	msg = strings.TrimPrefix(msg, "Error: ")
+	if msg == "" {
+		return err.Error()
+	}
+	return msg
+}
+
+// locateAgent returns the path of the gtrace binary to run on dest: one
+// uploaded by an earlier session, else the installed gtrace when it is the
+// same release, else this binary uploaded now when the remote host can run
+// it.
+func locateAgent(ctx context.Context, dest Dest, opts Options) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate gtrace binary: %w", err)
+	}
+	sum, err := fileHash(self)
+	if err != nil {
+		return "", err
+	}
+	cached := agentDir + "/gtrace-" + runtime.GOOS + "-" + runtime.GOARCH + "-" + sum
+
+	// An installed gtrace reports its agent protocol and release, older
+	// ones without an agent fail silently
+	script := fmt.Sprintf(`echo "os=$(uname -s)"; echo "arch=$(uname -m)"; g=$(command -v gtrace); echo "gtrace=$g"; [ -n "$g" ] && "$g" agent --protocol </dev/null 2>/dev/null; [ -x %s ] && echo cached=yes; true`, shellQuote(cached))
+	out, err := exec.CommandContext(ctx, opts.SSH, dest.sshArgs(script)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("ssh to %s failed: %s", dest, exitMessage(err))
+	}
+	info := parseKeyValues(out)
+
+	switch {
+	case info["cached"] == "yes":
+		return cached, nil
+	case info["gtrace"] != "" && compatible(info, opts.Release):
+		return info["gtrace"], nil
+	}
+
+	goos, goarch := platform(info["os"], info["arch"])
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		if info["gtrace"] != "" {
+			return "", fmt.Errorf("gtrace %s on %s does not match this gtrace (%s), and %s runs %s/%s: install gtrace %s there (this binary is for %s/%s)",
+				installedRelease(info), dest, opts.Release, dest, info["os"], info["arch"], opts.Release, runtime.GOOS, runtime.GOARCH)
+		}
+		return "", fmt.Errorf("gtrace is not installed on %s, which runs %s/%s: install gtrace there (this binary is for %s/%s)",
+			dest, info["os"], info["arch"], runtime.GOOS, runtime.GOARCH)
+	}
+	if err := upload(ctx, dest, opts, self, cached); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// compatible reports whether the installed gtrace described by info, the
+// output of the probe script of locateAgent, speaks this agent protocol
+// and is the given release.
+func compatible(info map[string]string, release string) bool {
+	return info["protocol"] == strconv.Itoa(Version) && info["version"] == release
+}
+
+// installedRelease describes the release of the installed gtrace in info.
+func installedRelease(info map[string]string) string {
+	if info["version"] == "" {
+		return "(without agent support)"
+	}
+	return info["version"]
+}
+
+// upload copies the file at local to remote on dest.
+func upload(ctx context.Context, dest Dest, opts Options, local, remote string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return fmt.Errorf("failed to open gtrace binary: %w", err)
+	}
+	defer f.Close()
+
+	tmp := shellQuote(remote + ".tmp")
+	script := fmt.Sprintf("mkdir -p %s && cat > %s && chmod 755 %s && mv %s %s", agentDir, tmp, tmp, tmp, shellQuote(remote))
+	cmd := exec.CommandContext(ctx, opts.SSH, dest.sshArgs(script)...)
+	cmd.Stdin = f
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("failed to copy gtrace to %s: %s", dest, exitMessage(err))
+	}
+	return nil
+}
+
+// fileHash returns a short hash of the file at path, so that an uploaded
+// agent is replaced when the local binary changes.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read gtrace binary: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read gtrace binary: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// platform maps the output of uname -s and uname -m to Go's GOOS and GOARCH.
+func platform(kernel, machine string) (goos, goarch string) {
+	goos = strings.ToLower(kernel)
+	switch machine {
+	case "x86_64", "amd64":
+		goarch = "amd64"
+	case "aarch64", "arm64":
+		goarch = "arm64"
+	case "i386", "i686":
+		goarch = "386"
+	default:
+		if strings.HasPrefix(machine, "armv") {
+			goarch = "arm"
+		} else {
+			goarch = machine
+		}
+	}
+	return goos, goarch
+}
+
+// parseKeyValues parses key=value lines.
+func parseKeyValues(out []byte) map[string]string {
+	kv := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "="); ok {
+			kv[k] = v
+		}
+	}
+	return kv
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// exitMessage describes a failed command from its standard error.
+func exitMessage(err error) string {
+	if ee, ok := err.(*exec.ExitError); ok {
+		if msg := strings.TrimSpace(string(ee.Stderr)); msg != "" {
+			return msg
+		}
+	}
+	return err.Error()
+}
+
+// limitedWriter keeps the first n bytes written to it.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		k := min(len(p), l.n)
+		l.w.Write(p[:k])
+		l.n -= k
+	}
+	return len(p), nil
+}
@@ -0,0 +1,257 @@
+// Package atlas converts RIPE Atlas measurement results to gtrace types.
+package atlas
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// TracerouteResult is one probe's result of a RIPE Atlas traceroute
+// measurement, as returned by the results API and in result downloads.
+type TracerouteResult struct {
+	Type      string      `json:"type"`
+	MsmID     int         `json:"msm_id"`
+	PrbID     int         `json:"prb_id"`
+	From      string      `json:"from"`     // Public address of the probe
+	SrcAddr   string      `json:"src_addr"` // Source address of the probe packets
+	DstName   string      `json:"dst_name"`
+	DstAddr   string      `json:"dst_addr"`
+	Proto     string      `json:"proto"` // ICMP, UDP or TCP
+	AF        int         `json:"af"`
+	Size      int         `json:"size"`
+	ParisID   int         `json:"paris_id"`
+	Timestamp int64       `json:"timestamp"`
+	EndTime   int64       `json:"endtime"`
+	Result    []HopResult `json:"result"`
+}
+
+// HopResult holds the replies to the probes sent with one TTL.
+type HopResult struct {
+	Hop    int     `json:"hop"`
+	Error  string  `json:"error,omitempty"` // Set instead of Result when probing failed
+	Result []Reply `json:"result"`
+}
+
+// Reply is the reply to a single probe, or a timeout when X is "*".
+type Reply struct {
+	X       string          `json:"x,omitempty"`
+	From    string          `json:"from,omitempty"`
+	RTT     float64         `json:"rtt,omitempty"`     // Milliseconds
+	TTL     int             `json:"ttl,omitempty"`     // TTL of the reply packet
+	ITTL    int             `json:"ittl,omitempty"`    // TTL of the probe quoted in the ICMP error, when not 1
+	Err     json.RawMessage `json:"err,omitempty"`     // Unreachable code: letter or number
+	Late    int             `json:"late,omitempty"`    // Reply arrived after later probes were sent
+	Dup     bool            `json:"dup,omitempty"`     // Duplicate reply
+	ICMPExt *ICMPExt        `json:"icmpext,omitempty"` // RFC 4884 extensions
+}
+
+// ICMPExt holds the ICMP extension objects of a reply.
+type ICMPExt struct {
+	Obj []ICMPExtObj `json:"obj"`
+}
+
+// ICMPExtObj is an ICMP extension object; class 1 type 1 is an MPLS stack.
+type ICMPExtObj struct {
+	Class int         `json:"class"`
+	Type  int         `json:"type"`
+	MPLS  []MPLSEntry `json:"mpls,omitempty"`
+}
+
+// MPLSEntry is an MPLS label stack entry.
+type MPLSEntry struct {
+	Label uint32 `json:"label"`
+	Exp   uint8  `json:"exp"`
+	S     int    `json:"s"`
+	TTL   uint8  `json:"ttl"`
+}
+
+// Parse decodes RIPE Atlas traceroute results: a JSON array (API and
+// downloads), a single result object, or one result per line. Results of
+// other measurement types are rejected.
+func Parse(data []byte) ([]TracerouteResult, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no results")
+	}
+
+	var results []TracerouteResult
+	switch data[0] {
+	case '[':
+		if err := json.Unmarshal(data, &results); err != nil {
+			return nil, fmt.Errorf("invalid RIPE Atlas results: %w", err)
+		}
+	default:
+		var single TracerouteResult
+		if json.Unmarshal(data, &single) == nil {
+			results = append(results, single)
+			break
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			var r TracerouteResult
+			if err := json.Unmarshal(text, &r); err != nil {
+				return nil, fmt.Errorf("invalid RIPE Atlas result on line %d: %w", line, err)
+			}
+			results = append(results, r)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read results: %w", err)
+		}
+	}
+
+	for i, r := range results {
+		if r.Type != "" && r.Type != "traceroute" {
+			return nil, fmt.Errorf("result %d is a %s measurement, only traceroute is supported", i+1, r.Type)
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results")
+	}
+	return results, nil
+}
+
+// ToTraceResult converts a RIPE Atlas result to our internal TraceResult type.
+func (r *TracerouteResult) ToTraceResult() *hop.TraceResult {
+	target := r.DstName
+	if target == "" {
+		target = r.DstAddr
+	}
+	result := hop.NewTraceResult(target, r.DstAddr)
+	result.Protocol = strings.ToLower(r.Proto)
+	result.Source = r.SourceLabel()
+	if r.Timestamp > 0 {
+		result.StartTime = time.Unix(r.Timestamp, 0)
+	}
+	if r.EndTime > 0 {
+		result.EndTime = time.Unix(r.EndTime, 0)
+	}
+
+	for _, hr := range r.Result {
+		if hr.Error != "" {
+			continue
+		}
+		h := hr.ToHop(r.AF)
+		result.AddHop(h)
+		if ip := h.PrimaryIP(); ip != nil && ip.String() == r.DstAddr {
+			result.ReachedTarget = true
+		}
+	}
+
+	return result
+}
+
+// SourceLabel identifies the probe the result comes from.
+func (r *TracerouteResult) SourceLabel() string {
+	label := fmt.Sprintf("Atlas probe %d", r.PrbID)
+	if r.From != "" {
+		label += " (" + r.From + ")"
+	}
+	return label
+}
+
+// ToHop converts the replies at one TTL to our internal Hop type. Late and
+// duplicate replies are dropped, as they answer probes already counted.
+func (hr *HopResult) ToHop(af int) *hop.Hop {
+	h := hop.NewHop(hr.Hop)
+	for _, reply := range hr.Result {
+		if reply.Dup || reply.Late > 0 {
+			continue
+		}
+		ip := net.ParseIP(reply.From)
+		if reply.X == "*" || ip == nil {
+			h.AddTimeout()
+			continue
+		}
+
+		p := hop.Probe{
+			IP:          ip,
+			RTT:         time.Duration(reply.RTT * float64(time.Millisecond)),
+			ResponseTTL: reply.TTL,
+			OriginalTTL: reply.ITTL,
+		}
+		if len(reply.Err) > 0 {
+			p.ICMPType, p.ICMPCode = unreachable(reply.Err, af)
+		}
+		h.Probes = append(h.Probes, p)
+
+		if reply.ICMPExt != nil && len(h.MPLS) == 0 {
+			h.SetMPLS(reply.ICMPExt.mplsLabels())
+		}
+	}
+	return h
+}
+
+// mplsLabels returns the MPLS label stack carried in the extensions.
+func (e *ICMPExt) mplsLabels() []hop.MPLSLabel {
+	var labels []hop.MPLSLabel
+	for _, obj := range e.Obj {
+		if obj.Class != 1 || obj.Type != 1 {
+			continue
+		}
+		for _, m := range obj.MPLS {
+			labels = append(labels, hop.MPLSLabel{Label: m.Label, Exp: m.Exp, S: m.S == 1, TTL: m.TTL})
+		}
+	}
+	return labels
+}
+
+// unreachable maps an Atlas "err" value to an ICMP Destination Unreachable
+// type and code. Atlas uses letters for the common codes (N network,
+// H host, A administratively prohibited, P protocol, p port, h beyond
+// scope) and the numeric code otherwise.
+func unreachable(raw json.RawMessage, af int) (int, int) {
+	icmpType := 3
+	if af == 6 {
+		icmpType = 1
+	}
+
+	var code int
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return icmpType, code
+	}
+	var letter string
+	if err := json.Unmarshal(raw, &letter); err != nil {
+		return 0, 0
+	}
+
+	if af == 6 {
+		switch letter {
+		case "N":
+			return icmpType, 0
+		case "A":
+			return icmpType, 1
+		case "h":
+			return icmpType, 2
+		case "H":
+			return icmpType, 3
+		case "p":
+			return icmpType, 4
+		}
+		return 0, 0
+	}
+	switch letter {
+	case "N":
+		return icmpType, 0
+	case "H":
+		return icmpType, 1
+	case "P":
+		return icmpType, 2
+	case "p":
+		return icmpType, 3
+	case "A":
+		return icmpType, 13
+	}
+	return 0, 0
+}
@@ -0,0 +1,134 @@
+package atlas
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleResult = `{"fw":5080,"af":4,"dst_addr":"93.184.216.34","dst_name":"example.com","endtime":1700000012,
+"from":"198.51.100.7","msm_id":5051,"paris_id":3,"prb_id":6012,"proto":"ICMP","size":48,
+"src_addr":"192.168.1.20","timestamp":1700000000,"type":"traceroute","result":[
+{"hop":1,"result":[{"from":"192.168.1.1","rtt":1.25,"size":76,"ttl":64},{"from":"192.168.1.1","rtt":1.75,"size":76,"ttl":64},{"x":"*"}]},
+{"hop":2,"result":[{"from":"10.0.0.1","rtt":8.5,"size":140,"ttl":254,"icmpext":{"version":2,"rfc4884":0,"obj":[{"class":1,"type":1,"mpls":[{"exp":0,"label":24005,"s":1,"ttl":1}]}]}},
+  {"from":"10.0.0.1","rtt":9.1,"size":140,"ttl":254,"dup":true},{"from":"10.0.0.2","rtt":30,"late":1}]},
+{"hop":3,"result":[{"from":"93.184.216.34","rtt":12.5,"size":48,"ttl":56},{"from":"93.184.216.34","rtt":12.75,"size":48,"ttl":56},{"from":"93.184.216.34","rtt":13,"size":48,"ttl":56}]}]}`
+
+func TestParse_Formats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"array", "[" + sampleResult + "," + sampleResult + "]", 2},
+		{"single object", sampleResult, 1},
+		{"one per line", compact(sampleResult) + "\n" + compact(sampleResult) + "\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("expected %d results, got %d", tt.want, len(results))
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", "  "},
+		{"not json", "traceroute to example.com"},
+		{"ping results", `[{"type":"ping","prb_id":1}]`},
+		{"empty array", "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.input)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestTracerouteResult_ToTraceResult(t *testing.T) {
+	results, err := Parse([]byte(sampleResult))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := results[0].ToTraceResult()
+
+	if tr.Target != "example.com" || tr.TargetIP != "93.184.216.34" || tr.Protocol != "icmp" {
+		t.Errorf("unexpected trace header: %+v", tr)
+	}
+	if tr.Source != "Atlas probe 6012 (198.51.100.7)" {
+		t.Errorf("unexpected source %q", tr.Source)
+	}
+	if tr.StartTime.Unix() != 1700000000 || tr.EndTime.Unix() != 1700000012 {
+		t.Errorf("unexpected times: %v - %v", tr.StartTime, tr.EndTime)
+	}
+	if !tr.ReachedTarget || len(tr.Hops) != 3 {
+		t.Fatalf("expected 3 hops reaching the target, got %d (reached=%v)", len(tr.Hops), tr.ReachedTarget)
+	}
+
+	h1 := tr.Hops[0]
+	if len(h1.Probes) != 3 || !h1.Probes[2].Timeout || h1.Probes[1].RTT.Microseconds() != 1750 {
+		t.Errorf("unexpected hop 1 probes: %+v", h1.Probes)
+	}
+	if h1.Probes[0].ResponseTTL != 64 {
+		t.Errorf("expected reply TTL 64, got %d", h1.Probes[0].ResponseTTL)
+	}
+
+	// Duplicate and late replies are dropped
+	h2 := tr.Hops[1]
+	if len(h2.Probes) != 1 || h2.HasMultipleIPs() {
+		t.Errorf("expected a single reply at hop 2, got %+v", h2.Probes)
+	}
+	if len(h2.MPLS) != 1 || h2.MPLS[0].Label != 24005 || !h2.MPLS[0].S {
+		t.Errorf("expected MPLS label 24005, got %+v", h2.MPLS)
+	}
+}
+
+func TestHopResult_ToHop_Unreachable(t *testing.T) {
+	tests := []struct {
+		err      string
+		af       int
+		wantType int
+		wantCode int
+	}{
+		{`"N"`, 4, 3, 0},
+		{`"H"`, 4, 3, 1},
+		{`"A"`, 4, 3, 13},
+		{`"p"`, 4, 3, 3},
+		{`9`, 4, 3, 9},
+		{`"A"`, 6, 1, 1},
+		{`"p"`, 6, 1, 4},
+	}
+	for _, tt := range tests {
+		hr := HopResult{Hop: 5, Result: []Reply{{From: "203.0.113.1", RTT: 4, Err: []byte(tt.err)}}}
+		p := hr.ToHop(tt.af).Probes[0]
+		if p.ICMPType != tt.wantType || p.ICMPCode != tt.wantCode {
+			t.Errorf("err %s (af %d): expected type %d code %d, got %d/%d", tt.err, tt.af, tt.wantType, tt.wantCode, p.ICMPType, p.ICMPCode)
+		}
+	}
+}
+
+func TestTracerouteResult_SkipsHopErrors(t *testing.T) {
+	r := TracerouteResult{DstAddr: "192.0.2.1", PrbID: 1, Result: []HopResult{
+		{Hop: 1, Result: []Reply{{From: "10.0.0.1", RTT: 1}}},
+		{Hop: 255, Error: "sendto failed: Network is unreachable"},
+	}}
+	tr := r.ToTraceResult()
+	if len(tr.Hops) != 1 || tr.Target != "192.0.2.1" || tr.Source != "Atlas probe 1" {
+		t.Errorf("unexpected trace: %+v", tr)
+	}
+}
+
+// compact puts a JSON document on one line.
+func compact(s string) string {
+	return strings.ReplaceAll(s, "\n", "")
+}
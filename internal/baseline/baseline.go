@@ -0,0 +1,353 @@
+// Package baseline records the reference path to a target and checks later
+// traces against it.
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/diff"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Version is the baseline file format version.
+const Version = 1
+
+// Baseline is the canonical path to a target and the latency envelope of
+// each hop, built from one or more traces.
+type Baseline struct {
+	Version       int       `json:"version"`
+	Target        string    `json:"target"`
+	TargetIP      string    `json:"targetIP"`
+	Protocol      string    `json:"protocol"`
+	Port          int       `json:"port,omitempty"`
+	MaxHops       int       `json:"maxHops,omitempty"`
+	Packets       int       `json:"packets,omitempty"`
+	Created       time.Time `json:"created"`
+	Samples       int       `json:"samples"` // Number of traces the baseline was built from
+	ReachedTarget bool      `json:"reachedTarget"`
+	Hops          []Hop     `json:"hops"`
+}
+
+// Hop is one TTL of the canonical path.
+type Hop struct {
+	TTL         int       `json:"ttl"`
+	IPs         []string  `json:"ips,omitempty"` // Every address seen, most frequent first
+	Hostname    string    `json:"hostname,omitempty"`
+	ASN         uint32    `json:"asn,omitempty"`
+	RTT         *Envelope `json:"rtt,omitempty"` // Nil when the hop never answered
+	LossPercent float64   `json:"lossPercent"`
+}
+
+// Envelope summarizes the RTTs seen at a hop, in milliseconds.
+type Envelope struct {
+	Min    float64 `json:"min"`
+	Avg    float64 `json:"avg"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+}
+
+// Upper is the highest RTT considered normal: the worst RTT recorded, or
+// three standard deviations above the average when that is higher, plus the
+// tolerance.
+func (e *Envelope) Upper(tolerance time.Duration) float64 {
+	return round(max(e.Max, e.Avg+3*e.StdDev) + hop.Milliseconds(tolerance))
+}
+
+// Build computes a baseline from traces to the same target. At each TTL the
+// addresses are ordered by how often they answered, so the first one forms
+// the canonical path and the others are known ECMP alternatives.
+func Build(traces []*hop.TraceResult) (*Baseline, error) {
+	if len(traces) == 0 {
+		return nil, fmt.Errorf("no traces to build a baseline from")
+	}
+
+	first := traces[0]
+	b := &Baseline{
+		Version:  Version,
+		Target:   first.Target,
+		TargetIP: first.TargetIP,
+		Protocol: first.Protocol,
+		Created:  first.StartTime,
+		Samples:  len(traces),
+	}
+	if b.Created.IsZero() {
+		b.Created = time.Now()
+	}
+
+	maxTTL := 0
+	reached := 0
+	for _, tr := range traces {
+		if tr.ReachedTarget {
+			reached++
+		}
+		for _, h := range tr.Hops {
+			maxTTL = max(maxTTL, h.TTL)
+		}
+	}
+	// Most traces must reach the target for it to be part of the baseline
+	b.ReachedTarget = reached*2 > len(traces)
+
+	for ttl := 1; ttl <= maxTTL; ttl++ {
+		var hops []*hop.Hop
+		for _, tr := range traces {
+			if h := tr.GetHop(ttl); h != nil {
+				hops = append(hops, h)
+			}
+		}
+		if len(hops) > 0 {
+			b.Hops = append(b.Hops, summarize(ttl, hops))
+		}
+	}
+	return b, nil
+}
+
+// summarize merges the probes of several traces at one TTL.
+func summarize(ttl int, hops []*hop.Hop) Hop {
+	s := Hop{TTL: ttl}
+	counts := make(map[string]int)
+	var rtts []float64
+	total, lost := 0, 0
+
+	for _, h := range hops {
+		for _, p := range h.Probes {
+			total++
+			if p.Timeout || p.IP == nil {
+				lost++
+				continue
+			}
+			ip := p.IP.String()
+			if counts[ip] == 0 {
+				s.IPs = append(s.IPs, ip)
+			}
+			counts[ip]++
+			rtts = append(rtts, hop.Milliseconds(p.RTT))
+		}
+		if s.Hostname == "" {
+			s.Hostname = h.Enrichment.Hostname
+		}
+		if s.ASN == 0 {
+			s.ASN = h.Enrichment.ASN
+		}
+	}
+
+	sort.SliceStable(s.IPs, func(i, j int) bool {
+		return counts[s.IPs[i]] > counts[s.IPs[j]]
+	})
+	if total > 0 {
+		s.LossPercent = round(float64(lost) / float64(total) * 100)
+	}
+	if len(rtts) > 0 {
+		s.RTT = envelope(rtts)
+	}
+	return s
+}
+
+// envelope computes the RTT envelope of a set of samples.
+func envelope(rtts []float64) *Envelope {
+	e := &Envelope{Min: rtts[0], Max: rtts[0]}
+	sum := 0.0
+	for _, r := range rtts {
+		e.Min = min(e.Min, r)
+		e.Max = max(e.Max, r)
+		sum += r
+	}
+	avg := sum / float64(len(rtts))
+	variance := 0.0
+	for _, r := range rtts {
+		variance += (r - avg) * (r - avg)
+	}
+	e.Avg = round(avg)
+	e.StdDev = round(math.Sqrt(variance / float64(len(rtts))))
+	return e
+}
+
+// TraceResult returns the canonical path as a trace: one probe per known
+// address at each hop, so ECMP alternatives still match when diffing.
+func (b *Baseline) TraceResult() *hop.TraceResult {
+	tr := hop.NewTraceResult(b.Target, b.TargetIP)
+	tr.Protocol = b.Protocol
+	tr.Source = "baseline"
+	tr.StartTime = b.Created
+	tr.ReachedTarget = b.ReachedTarget
+
+	for _, bh := range b.Hops {
+		h := hop.NewHop(bh.TTL)
+		if len(bh.IPs) == 0 {
+			h.AddTimeout()
+		}
+		for _, ip := range bh.IPs {
+			var rtt time.Duration
+			if bh.RTT != nil {
+				rtt = time.Duration(bh.RTT.Avg * float64(time.Millisecond))
+			}
+			h.AddProbe(net.ParseIP(ip), rtt)
+		}
+		h.SetEnrichment(hop.Enrichment{ASN: bh.ASN, Hostname: bh.Hostname})
+		tr.AddHop(h)
+	}
+	return tr
+}
+
+// targetHop returns the hop at which the target answered, if any.
+func (b *Baseline) targetHop() *Hop {
+	for i := len(b.Hops) - 1; i >= 0; i-- {
+		for _, ip := range b.Hops[i].IPs {
+			if ip == b.TargetIP {
+				return &b.Hops[i]
+			}
+		}
+	}
+	return nil
+}
+
+// hopByTTL returns the hop at ttl, if any.
+func (b *Baseline) hopByTTL(ttl int) *Hop {
+	for i := range b.Hops {
+		if b.Hops[i].TTL == ttl {
+			return &b.Hops[i]
+		}
+	}
+	return nil
+}
+
+// Load reads a baseline file.
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no baseline at %s (record one with gtrace baseline save)", path)
+		}
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid baseline file %s: %w", path, err)
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("baseline file %s has unsupported version %d", path, b.Version)
+	}
+	return &b, nil
+}
+
+// Save writes the baseline to path, creating its directory.
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create baseline directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// DefaultPath returns where the baseline of a target is stored by default:
+// ~/.gtr/baselines/<target>.json.
+func DefaultPath(target string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".gtr", "baselines", unsafeFileChars.ReplaceAllString(target, "_")+".json"), nil
+}
+
+// Status is the outcome of a baseline check, from best to worst.
+type Status string
+
+const (
+	StatusOK      Status = "ok"      // Same path, latency within the envelope
+	StatusLatency Status = "latency" // Same path, target RTT above the envelope
+	StatusPath    Status = "path"    // Path changed or target no longer reached
+)
+
+// Deviation is a hop whose RTT left the baseline envelope.
+type Deviation struct {
+	TTL   int     `json:"ttl"`
+	IP    string  `json:"ip"`
+	RTT   float64 `json:"rtt"`   // Current average RTT in ms
+	Upper float64 `json:"upper"` // Upper bound of the baseline envelope in ms
+}
+
+// Report is the result of checking traces against a baseline.
+type Report struct {
+	Target        string        `json:"target"`
+	TargetIP      string        `json:"targetIP"`
+	BaselineTime  time.Time     `json:"baselineTime"`
+	Status        Status        `json:"status"`
+	ReachedTarget bool          `json:"reachedTarget"`
+	TargetRTT     float64       `json:"targetRtt,omitempty"`   // Current RTT to the target in ms
+	TargetUpper   float64       `json:"targetUpper,omitempty"` // Envelope upper bound at the target in ms
+	PathChanges   []diff.Change `json:"pathChanges,omitempty"`
+	Deviations    []Deviation   `json:"deviations,omitempty"`
+}
+
+// Check compares traces against the baseline. The path is compared with
+// hops aligned by address and AS; only the RTT to the target decides a
+// latency regression, since intermediate routers answer traceroute probes
+// at low priority. Hops out of their envelope are listed to locate where
+// the latency was added.
+func (b *Baseline) Check(traces []*hop.TraceResult, tolerance time.Duration) (*Report, error) {
+	current, err := Build(traces)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		Target:        b.Target,
+		TargetIP:      b.TargetIP,
+		BaselineTime:  b.Created,
+		Status:        StatusOK,
+		ReachedTarget: current.ReachedTarget,
+	}
+
+	d := diff.Compare(b.TraceResult(), current.TraceResult(), diff.Options{})
+	for _, c := range d.Changes {
+		switch c.Kind {
+		case diff.KindInserted, diff.KindRemoved, diff.KindReplaced, diff.KindASN:
+			r.PathChanges = append(r.PathChanges, c)
+		}
+	}
+
+	for _, step := range d.Alignment {
+		if step.Old == nil || step.New == nil {
+			continue
+		}
+		old, cur := b.hopByTTL(step.Old.TTL), current.hopByTTL(step.New.TTL)
+		if old == nil || cur == nil || old.RTT == nil || cur.RTT == nil {
+			continue
+		}
+		if upper := old.RTT.Upper(tolerance); cur.RTT.Avg > upper {
+			r.Deviations = append(r.Deviations, Deviation{TTL: cur.TTL, IP: step.New.IP, RTT: cur.RTT.Avg, Upper: upper})
+		}
+	}
+
+	if old, cur := b.targetHop(), current.targetHop(); old != nil && cur != nil && old.RTT != nil && cur.RTT != nil {
+		r.TargetRTT = cur.RTT.Avg
+		r.TargetUpper = old.RTT.Upper(tolerance)
+		if r.TargetRTT > r.TargetUpper {
+			r.Status = StatusLatency
+		}
+	}
+	if len(r.PathChanges) > 0 || (b.ReachedTarget && !current.ReachedTarget) {
+		r.Status = StatusPath
+	}
+	return r, nil
+}
+
+// round rounds milliseconds to the microsecond.
+func round(ms float64) float64 {
+	return math.Round(ms*1000) / 1000
+}
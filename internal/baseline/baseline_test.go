@@ -0,0 +1,149 @@
+package baseline
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/diff"
+	"github.com/hervehildenbrand/gtrace/internal/tracetest"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestBuild_CanonicalPathAndEnvelope(t *testing.T) {
+	b, err := Build([]*hop.TraceResult{
+		tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.1"),
+		tracetest.Path(12*time.Millisecond, "192.168.1.1", "10.0.0.2"),
+		tracetest.Path(14*time.Millisecond, "192.168.1.1", "10.0.0.2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Samples != 3 || !b.ReachedTarget || len(b.Hops) != 3 {
+		t.Fatalf("unexpected baseline: %+v", b)
+	}
+	if got := b.Hops[1].IPs; len(got) != 2 || got[0] != "10.0.0.2" || got[1] != "10.0.0.1" {
+		t.Errorf("expected most frequent address first, got %v", got)
+	}
+	env := b.Hops[2].RTT
+	if env.Min != 30 || env.Max != 42 || env.Avg != 36 {
+		t.Errorf("expected target envelope 30/36/42ms, got %+v", env)
+	}
+}
+
+func TestBuild_NoTraces(t *testing.T) {
+	if _, err := Build(nil); err == nil {
+		t.Error("expected error without traces")
+	}
+}
+
+func TestEnvelope_Upper(t *testing.T) {
+	// Max dominates
+	e := &Envelope{Avg: 10, Max: 20, StdDev: 1}
+	if got := e.Upper(5 * time.Millisecond); got != 25 {
+		t.Errorf("expected 25ms, got %v", got)
+	}
+	// Three standard deviations dominate
+	e = &Envelope{Avg: 10, Max: 12, StdDev: 4}
+	if got := e.Upper(0); got != 22 {
+		t.Errorf("expected 22ms, got %v", got)
+	}
+}
+
+func TestCheck_MatchingPath(t *testing.T) {
+	b, _ := Build([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.1")})
+
+	r, err := b.Check([]*hop.TraceResult{tracetest.Path(11*time.Millisecond, "192.168.1.1", "10.0.0.1")}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != StatusOK {
+		t.Errorf("expected ok, got %s: %+v", r.Status, r)
+	}
+	if r.TargetRTT != 33 || r.TargetUpper != 40 {
+		t.Errorf("expected target RTT 33ms under 40ms, got %v under %v", r.TargetRTT, r.TargetUpper)
+	}
+}
+
+func TestCheck_ECMPAlternativeIsNotAChange(t *testing.T) {
+	b, _ := Build([]*hop.TraceResult{
+		tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.1"),
+		tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.2"),
+	})
+
+	r, _ := b.Check([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.2")}, 0)
+	if r.Status != StatusOK {
+		t.Errorf("expected ok for a known ECMP address, got %s: %v", r.Status, r.PathChanges)
+	}
+}
+
+func TestCheck_LatencyRegression(t *testing.T) {
+	b, _ := Build([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.1")})
+
+	r, _ := b.Check([]*hop.TraceResult{tracetest.Path(20*time.Millisecond, "192.168.1.1", "10.0.0.1")}, 5*time.Millisecond)
+	if r.Status != StatusLatency {
+		t.Fatalf("expected latency status, got %s", r.Status)
+	}
+	if len(r.Deviations) != 3 || r.Deviations[2].RTT != 60 || r.Deviations[2].Upper != 35 {
+		t.Errorf("expected every hop above its envelope, got %+v", r.Deviations)
+	}
+}
+
+func TestCheck_PathChange(t *testing.T) {
+	b, _ := Build([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.0.0.1")})
+
+	r, _ := b.Check([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1", "10.9.9.9")}, 0)
+	if r.Status != StatusPath {
+		t.Fatalf("expected path status, got %s", r.Status)
+	}
+	if len(r.PathChanges) != 1 || r.PathChanges[0].Kind != diff.KindReplaced {
+		t.Errorf("expected a replaced hop, got %v", r.PathChanges)
+	}
+}
+
+func TestCheck_TargetNoLongerReached(t *testing.T) {
+	b, _ := Build([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1")})
+
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1", RTT: 10 * time.Millisecond}, tracetest.Hop{})
+
+	r, _ := b.Check([]*hop.TraceResult{curr}, 0)
+	if r.Status != StatusPath || r.ReachedTarget {
+		t.Errorf("expected path status for an unreached target, got %s", r.Status)
+	}
+}
+
+func TestSaveLoad_RoundTrip(t *testing.T) {
+	b, _ := Build([]*hop.TraceResult{tracetest.Path(10*time.Millisecond, "192.168.1.1")})
+	b.Port = 443
+	path := filepath.Join(t.TempDir(), "nested", "example.json")
+
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Target != "example.com" || loaded.Port != 443 || len(loaded.Hops) != 2 || loaded.Hops[1].RTT.Avg != 20 {
+		t.Errorf("baseline not restored: %+v", loaded)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil || !strings.Contains(err.Error(), "gtrace baseline save") {
+		t.Errorf("expected hint to save a baseline, got %v", err)
+	}
+}
+
+func TestDefaultPath_SanitizesTarget(t *testing.T) {
+	path, err := DefaultPath("2001:db8::1")
+	if err != nil {
+		t.Skip(err)
+	}
+	if filepath.Base(path) != "2001_db8__1.json" || filepath.Base(filepath.Dir(path)) != "baselines" {
+		t.Errorf("unexpected path %s", path)
+	}
+}
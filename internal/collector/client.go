@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pusher pushes reports to a collector over one Push call, opened by the
+// first report and reopened by the next one after a failure.
+type Pusher struct {
+	addr   string
+	client *http.Client
+
+	mu     sync.Mutex
+	stream *stream
+}
+
+// stream is an open Push call.
+type stream struct {
+	body   *io.PipeWriter
+	cancel context.CancelFunc
+	resp   chan result
+}
+
+type result struct {
+	resp *http.Response
+	err  error
+}
+
+// NewPusher creates a pusher to the collector at addr (host:port) with the
+// TLS configuration tlsCfg (see ClientTLSConfig).
+func NewPusher(addr string, tlsCfg *tls.Config) (*Pusher, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid collector address %q: must be host:port", addr)
+	}
+	return &Pusher{
+		addr: addr,
+		client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   tlsCfg,
+			ForceAttemptHTTP2: true,
+		}},
+	}, nil
+}
+
+// Push sends r to the collector. A failed push closes the call, which the
+// next push reopens, so that a restarted collector is caught up with.
+func (p *Pusher) Push(ctx context.Context, r Report) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stream == nil {
+		p.stream = p.open()
+	}
+	s := p.stream
+
+	// Unblock a write the collector is not reading
+	stop := context.AfterFunc(ctx, func() { s.body.CloseWithError(ctx.Err()) })
+	err := writeMessage(s.body, r)
+	stop()
+	if err != nil {
+		p.stream = nil
+		if ctx.Err() != nil {
+			s.cancel()
+		}
+		if cerr := s.close(); cerr != nil {
+			return fmt.Errorf("failed to push to collector %s: %w", p.addr, cerr)
+		}
+		return fmt.Errorf("failed to push to collector %s: %w", p.addr, err)
+	}
+	return nil
+}
+
+// Close ends the call and returns its status.
+func (p *Pusher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream == nil {
+		return nil
+	}
+	err := p.stream.close()
+	p.stream = nil
+	return err
+}
+
+// open starts a Push call, whose request body is written by Push.
+func (p *Pusher) open() *stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	s := &stream{body: pw, cancel: cancel, resp: make(chan result, 1)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+p.addr+pushPath, pr)
+	if err != nil {
+		pw.CloseWithError(err)
+		s.resp <- result{err: err}
+		return s
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Te", "trailers")
+	go func() {
+		resp, err := p.client.Do(req)
+		if err != nil {
+			// Fail the writes of Push
+			pw.CloseWithError(err)
+		}
+		s.resp <- result{resp, err}
+	}()
+	return s
+}
+
+// closeTimeout bounds the wait for the status of a call being closed.
+const closeTimeout = 10 * time.Second
+
+// close ends the request body and returns the status of the call.
+func (s *stream) close() error {
+	defer s.cancel()
+	s.body.Close()
+	t := time.AfterFunc(closeTimeout, s.cancel)
+	defer t.Stop()
+
+	res := <-s.resp
+	if res.err != nil {
+		return res.err
+	}
+	defer res.resp.Body.Close()
+	if res.resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector answered HTTP %s", res.resp.Status)
+	}
+
+	// The reply is followed by the trailers, or there are only headers
+	var reply PushReply
+	if err := readMessage(res.resp.Body, &reply); err != nil && err != io.EOF {
+		var se *statusError
+		if !errors.As(err, &se) {
+			return err
+		}
+	}
+	io.Copy(io.Discard, res.resp.Body)
+	code, msg := res.resp.Header.Get("Grpc-Status"), res.resp.Header.Get("Grpc-Message")
+	if code == "" {
+		code, msg = res.resp.Trailer.Get("Grpc-Status"), res.resp.Trailer.Get("Grpc-Message")
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return fmt.Errorf("collector answered without a gRPC status")
+	}
+	if n != codeOK {
+		return &statusError{n, msg}
+	}
+	return nil
+}
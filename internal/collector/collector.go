@@ -0,0 +1,218 @@
+// Package collector streams the trace results of gtrace agents to a central
+// collector, which keeps the latest path from each agent to each target for
+// a fleet comparison.
+//
+// Agents push results over a long-lived client-streaming gRPC call (see
+// grpc.go) on HTTP/2 with mutual TLS. Both ends are identified by SPIFFE
+// IDs in their certificates: the collector accepts any agent of its trust
+// domain and names it by its ID, an agent only talks to the collector ID it
+// was configured with.
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Report is one trace result pushed by an agent.
+type Report struct {
+	Target string           `json:"target"`
+	Time   time.Time        `json:"time"`
+	Result *hop.TraceResult `json:"result"`
+}
+
+// PushReply ends a Push call.
+type PushReply struct {
+	Received int `json:"received"`
+}
+
+// Agent is an agent that pushed results to the collector.
+type Agent struct {
+	ID       ID
+	LastSeen time.Time
+	Reports  int
+}
+
+// Collector keeps the latest result of each agent for each target.
+type Collector struct {
+	mu      sync.Mutex
+	agents  map[ID]*Agent
+	latest  map[string]map[ID]*hop.TraceResult // By target, then agent
+	version int                                // Incremented by every report
+	now     func() time.Time
+}
+
+// New creates an empty collector.
+func New() *Collector {
+	return &Collector{
+		agents: make(map[ID]*Agent),
+		latest: make(map[string]map[ID]*hop.TraceResult),
+		now:    time.Now,
+	}
+}
+
+// record stores a report of agent.
+func (c *Collector) record(agent ID, r Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a := c.agents[agent]
+	if a == nil {
+		a = &Agent{ID: agent}
+		c.agents[agent] = a
+	}
+	a.LastSeen = c.now()
+	a.Reports++
+
+	if c.latest[r.Target] == nil {
+		c.latest[r.Target] = make(map[ID]*hop.TraceResult)
+	}
+	c.latest[r.Target][agent] = r.Result
+	c.version++
+}
+
+// Version changes whenever a report is received, so that a renderer can
+// skip unchanged views.
+func (c *Collector) Version() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// Agents returns the agents seen so far, by ID.
+func (c *Collector) Agents() []Agent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	agents := make([]Agent, 0, len(c.agents))
+	for _, a := range c.agents {
+		agents = append(agents, *a)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID.String() < agents[j].ID.String() })
+	return agents
+}
+
+// Targets returns the traced targets, sorted.
+func (c *Collector) Targets() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	targets := make([]string, 0, len(c.latest))
+	for t := range c.latest {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Results returns the latest result of each agent for target, ordered by
+// agent ID, with each result's Source set to the agent's name.
+func (c *Collector) Results(target string) []*hop.TraceResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]ID, 0, len(c.latest[target]))
+	for id := range c.latest[target] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	results := make([]*hop.TraceResult, 0, len(ids))
+	for _, id := range ids {
+		tr := *c.latest[target][id]
+		tr.Source = id.Name()
+		results = append(results, &tr)
+	}
+	return results
+}
+
+// ServeHTTP serves the Push method to agents authenticated by mutual TLS.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != pushPath {
+		writeStatus(w, &statusError{codeUnimplemented, "unknown method " + r.URL.Path})
+		return
+	}
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 || r.Header.Get("Content-Type") != contentType {
+		writeStatus(w, &statusError{codeInvalidArgument, "expected a gRPC call with the " + contentType + " codec over HTTP/2"})
+		return
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		writeStatus(w, &statusError{codeUnauthenticated, "client certificate required"})
+		return
+	}
+	// The certificate was verified during the handshake
+	agent, err := PeerID(r.TLS.PeerCertificates[0])
+	if err != nil {
+		writeStatus(w, &statusError{codeUnauthenticated, err.Error()})
+		return
+	}
+
+	n := 0
+	for {
+		var rep Report
+		err := readMessage(r.Body, &rep)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var se *statusError
+			if !errors.As(err, &se) {
+				se = &statusError{codeInternal, err.Error()}
+			}
+			writeStatus(w, se)
+			return
+		}
+		if rep.Target == "" || rep.Result == nil {
+			writeStatus(w, &statusError{codeInvalidArgument, "report without target or result"})
+			return
+		}
+		c.record(agent, rep)
+		n++
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	writeMessage(w, PushReply{Received: n})
+	w.Header().Set("Grpc-Status", strconv.Itoa(codeOK))
+}
+
+// writeStatus ends a call with an error status, in a trailers-only
+// response.
+func writeStatus(w http.ResponseWriter, se *statusError) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Grpc-Status", strconv.Itoa(se.code))
+	w.Header().Set("Grpc-Message", se.msg)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Serve accepts agents on ln with the TLS configuration tlsCfg (see
+// ServerTLSConfig) until ctx is done.
+func Serve(ctx context.Context, ln net.Listener, tlsCfg *tls.Config, c *Collector) error {
+	srv := &http.Server{
+		Handler:           c,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// Agent streams never end on their own
+		if srv.Shutdown(shutdownCtx) != nil {
+			srv.Close()
+		}
+	}()
+	if err := srv.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("collector error: %w", err)
+	}
+	return nil
+}
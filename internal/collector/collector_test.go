@@ -0,0 +1,288 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// testCA issues SVIDs for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string // PEM bundle
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue returns the files of an SVID for id.
+func (ca *testCA) issue(t *testing.T, id string) TLSFiles {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(id)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{u},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	f := TLSFiles{Cert: filepath.Join(dir, "svid.pem"), Key: filepath.Join(dir, "key.pem"), CA: ca.file}
+	writePEM(t, f.Cert, "CERTIFICATE", der)
+	writePEM(t, f.Key, "EC PRIVATE KEY", keyDER)
+	return f
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startCollector serves a collector of trust domain example.org with the
+// ID spiffe://example.org/collector.
+func startCollector(t *testing.T, ca *testCA) (*Collector, string) {
+	t.Helper()
+	tlsCfg, err := ServerTLSConfig(ca.issue(t, "spiffe://example.org/collector"), "example.org")
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Serve(ctx, ln, tlsCfg, c)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return c, ln.Addr().String()
+}
+
+func newTestPusher(t *testing.T, files TLSFiles, addr, server string) *Pusher {
+	t.Helper()
+	id, err := ParseID(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsCfg, err := ClientTLSConfig(files, id)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig: %v", err)
+	}
+	p, err := NewPusher(addr, tlsCfg)
+	if err != nil {
+		t.Fatalf("NewPusher: %v", err)
+	}
+	return p
+}
+
+func report(target, lastHop string) Report {
+	tr := hop.NewTraceResult(target, lastHop)
+	h := hop.NewHop(1)
+	h.AddProbe(net.ParseIP(lastHop), 5*time.Millisecond)
+	tr.AddHop(h)
+	tr.ReachedTarget = true
+	return Report{Target: target, Time: time.Now(), Result: tr}
+}
+
+func TestPush_CollectsResultsByAgent(t *testing.T) {
+	ca := newTestCA(t)
+	c, addr := startCollector(t, ca)
+
+	paris := newTestPusher(t, ca.issue(t, "spiffe://example.org/gtrace/paris"), addr, "spiffe://example.org/collector")
+	tokyo := newTestPusher(t, ca.issue(t, "spiffe://example.org/gtrace/tokyo"), addr, "spiffe://example.org/collector")
+	ctx := context.Background()
+	for _, push := range []struct {
+		p *Pusher
+		r Report
+	}{
+		{tokyo, report("example.com", "93.184.216.34")},
+		{paris, report("example.com", "93.184.216.34")},
+		{paris, report("example.org", "93.184.215.14")},
+	} {
+		if err := push.p.Push(ctx, push.r); err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+	if err := paris.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := tokyo.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	agents := c.Agents()
+	if len(agents) != 2 || agents[0].ID.Name() != "gtrace/paris" || agents[0].Reports != 2 || agents[1].Reports != 1 {
+		t.Errorf("unexpected agents: %+v", agents)
+	}
+	if targets := c.Targets(); len(targets) != 2 || targets[0] != "example.com" {
+		t.Errorf("unexpected targets: %v", targets)
+	}
+	results := c.Results("example.com")
+	if len(results) != 2 || results[0].Source != "gtrace/paris" || results[1].Source != "gtrace/tokyo" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if !results[0].ReachedTarget || len(results[0].Hops) != 1 {
+		t.Errorf("result not received intact: %+v", results[0])
+	}
+	if c.Version() != 3 {
+		t.Errorf("expected version 3, got %d", c.Version())
+	}
+}
+
+func TestPush_RejectsAgentOfOtherTrustDomain(t *testing.T) {
+	ca := newTestCA(t)
+	c, addr := startCollector(t, ca)
+
+	p := newTestPusher(t, ca.issue(t, "spiffe://other.org/gtrace/paris"), addr, "spiffe://example.org/collector")
+	err := p.Push(context.Background(), report("example.com", "93.184.216.34"))
+	if err == nil {
+		err = p.Close()
+	}
+	if err == nil {
+		t.Fatal("expected the agent to be rejected")
+	}
+	if len(c.Agents()) != 0 {
+		t.Errorf("expected no agent, got %+v", c.Agents())
+	}
+}
+
+func TestPush_RejectsUnexpectedCollector(t *testing.T) {
+	ca := newTestCA(t)
+	_, addr := startCollector(t, ca)
+
+	p := newTestPusher(t, ca.issue(t, "spiffe://example.org/gtrace/paris"), addr, "spiffe://example.org/other-collector")
+	err := p.Push(context.Background(), report("example.com", "93.184.216.34"))
+	if err == nil {
+		err = p.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "expected spiffe://example.org/other-collector") {
+		t.Errorf("expected a collector ID mismatch, got %v", err)
+	}
+}
+
+func TestPush_RejectsCollectorOfOtherCA(t *testing.T) {
+	_, addr := startCollector(t, newTestCA(t))
+
+	p := newTestPusher(t, newTestCA(t).issue(t, "spiffe://example.org/gtrace/paris"), addr, "spiffe://example.org/collector")
+	err := p.Push(context.Background(), report("example.com", "93.184.216.34"))
+	if err == nil {
+		err = p.Close()
+	}
+	if err == nil {
+		t.Error("expected the handshake to fail")
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ID
+		wantErr bool
+	}{
+		{"spiffe://example.org/gtrace/paris", ID{"example.org", "/gtrace/paris"}, false},
+		{"spiffe://example.org", ID{"example.org", ""}, false},
+		{"https://example.org/gtrace", ID{}, true},
+		{"spiffe://Example.org/gtrace", ID{}, true},
+		{"spiffe://example.org:8443/gtrace", ID{}, true},
+		{"spiffe://example.org/gtrace/", ID{}, true},
+		{"spiffe://example.org/gtrace?x=1", ID{}, true},
+		{"spiffe:///gtrace", ID{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseID(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseID(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseID(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if !tt.wantErr && got.String() != tt.in {
+			t.Errorf("ID(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, PushReply{Received: 3}); err != nil {
+		t.Fatal(err)
+	}
+	var reply PushReply
+	if err := readMessage(&buf, &reply); err != nil || reply.Received != 3 {
+		t.Errorf("round trip: %+v, %v", reply, err)
+	}
+	if err := readMessage(&buf, &reply); err != io.EOF {
+		t.Errorf("expected io.EOF after the last message, got %v", err)
+	}
+
+	var big [5]byte
+	binary.BigEndian.PutUint32(big[1:], maxMessageSize+1)
+	if err := readMessage(bytes.NewReader(big[:]), &reply); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected an oversized message error, got %v", err)
+	}
+	if err := readMessage(bytes.NewReader([]byte{1, 0, 0, 0, 2, '{', '}'}), &reply); err == nil || !strings.Contains(err.Error(), "compressed") {
+		t.Errorf("expected a compression error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// The Push RPC of the Collector service, a client-streaming gRPC method:
+//
+//	service Collector {
+//	  rpc Push(stream Report) returns (PushReply);
+//	}
+//
+// Messages use the gRPC JSON codec, so that neither end needs generated
+// protobuf code.
+const (
+	pushPath    = "/gtrace.collector.v1.Collector/Push"
+	contentType = "application/grpc+json"
+)
+
+// maxMessageSize bounds a message, a trace result with its enrichment
+// being a few kilobytes.
+const maxMessageSize = 4 << 20
+
+// gRPC status codes.
+const (
+	codeOK                = 0
+	codeInvalidArgument   = 3
+	codeResourceExhausted = 8
+	codeUnimplemented     = 12
+	codeInternal          = 13
+	codeUnauthenticated   = 16
+)
+
+// statusError is a gRPC status other than OK.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("collector error (code %d): %s", e.code, e.msg)
+}
+
+// writeMessage writes v as a length-prefixed gRPC message.
+func writeMessage(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	_, err = w.Write(append(frame, data...))
+	return err
+}
+
+// readMessage reads a length-prefixed gRPC message into v. It returns
+// io.EOF when the stream ends between messages.
+func readMessage(r io.Reader, v any) error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return &statusError{codeInvalidArgument, "truncated message"}
+		}
+		return err
+	}
+	if prefix[0] != 0 {
+		return &statusError{codeUnimplemented, "compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxMessageSize {
+		return &statusError{codeResourceExhausted, fmt.Sprintf("message of %d bytes exceeds %d", n, maxMessageSize)}
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return &statusError{codeInvalidArgument, "truncated message"}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &statusError{codeInvalidArgument, err.Error()}
+	}
+	return nil
+}
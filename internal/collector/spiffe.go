@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ID is a SPIFFE ID, spiffe://trust-domain/path, naming an agent or the
+// collector in the URI SAN of its X.509 certificate (its SVID).
+type ID struct {
+	TrustDomain string
+	Path        string // Starts with "/", or empty
+}
+
+// ParseID parses a SPIFFE ID.
+func ParseID(s string) (ID, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "spiffe" {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: must be spiffe://trust-domain/path", s)
+	}
+	if u.Host == "" || u.Port() != "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" || u.Host != strings.ToLower(u.Host) {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: the trust domain must be a lowercase name without port or user", s)
+	}
+	if strings.HasSuffix(u.Path, "/") || strings.Contains(u.Path, "//") {
+		return ID{}, fmt.Errorf("invalid SPIFFE ID %q: empty path segment", s)
+	}
+	return ID{TrustDomain: u.Host, Path: u.Path}, nil
+}
+
+// String returns the ID as a spiffe:// URI.
+func (id ID) String() string {
+	return "spiffe://" + id.TrustDomain + id.Path
+}
+
+// Name returns the path of the ID without its leading slash, which names an
+// agent within the trust domain, or the trust domain for an empty path.
+func (id ID) Name() string {
+	if id.Path == "" {
+		return id.TrustDomain
+	}
+	return strings.TrimPrefix(id.Path, "/")
+}
+
+// PeerID returns the SPIFFE ID of cert, the single spiffe:// URI SAN an SVID
+// carries.
+func PeerID(cert *x509.Certificate) (ID, error) {
+	var ids []ID
+	for _, u := range cert.URIs {
+		if u.Scheme != "spiffe" {
+			continue
+		}
+		id, err := ParseID(u.String())
+		if err != nil {
+			return ID{}, err
+		}
+		ids = append(ids, id)
+	}
+	switch len(ids) {
+	case 0:
+		return ID{}, errors.New("certificate has no SPIFFE ID")
+	case 1:
+		return ids[0], nil
+	}
+	return ID{}, errors.New("certificate has several SPIFFE IDs")
+}
+
+// TLSFiles are the PEM files of one end of the connection.
+type TLSFiles struct {
+	Cert string // Certificate chain, the SVID first
+	Key  string // Private key of the SVID
+	CA   string // Trust bundle the peer's SVID is verified against
+}
+
+// load reads the certificate and trust bundle of f.
+func (f TLSFiles) load() (tls.Certificate, *x509.CertPool, error) {
+	if f.Cert == "" || f.Key == "" || f.CA == "" {
+		return tls.Certificate{}, nil, errors.New("a certificate, its key and a CA bundle are required for mutual TLS")
+	}
+	cert, err := tls.LoadX509KeyPair(f.Cert, f.Key)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	pem, err := os.ReadFile(f.CA)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return tls.Certificate{}, nil, fmt.Errorf("CA bundle %s holds no PEM certificate", f.CA)
+	}
+	return cert, pool, nil
+}
+
+// ServerTLSConfig returns the TLS configuration of a collector: clients must
+// present an SVID issued by the CA of f with an ID in trustDomain.
+func ServerTLSConfig(f TLSFiles, trustDomain string) (*tls.Config, error) {
+	if trustDomain == "" {
+		return nil, errors.New("a trust domain is required")
+	}
+	cert, pool, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			id, err := PeerID(cs.PeerCertificates[0])
+			if err != nil {
+				return err
+			}
+			if id.TrustDomain != trustDomain {
+				return fmt.Errorf("agent %s is not in trust domain %s", id, trustDomain)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// ClientTLSConfig returns the TLS configuration of an agent: the collector
+// must present an SVID issued by the CA of f with the ID server.
+func ClientTLSConfig(f TLSFiles, server ID) (*tls.Config, error) {
+	cert, pool, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+		// SVIDs name their holder by SPIFFE ID rather than host name:
+		// the chain and the ID are verified below instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("collector presented no certificate")
+			}
+			opts := x509.VerifyOptions{
+				Roots:         pool,
+				Intermediates: x509.NewCertPool(),
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}
+			for _, c := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(c)
+			}
+			if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+				return fmt.Errorf("collector certificate: %w", err)
+			}
+			id, err := PeerID(cs.PeerCertificates[0])
+			if err != nil {
+				return fmt.Errorf("collector certificate: %w", err)
+			}
+			if id != server {
+				return fmt.Errorf("collector is %s, expected %s", id, server)
+			}
+			return nil
+		},
+	}, nil
+}
@@ -0,0 +1,377 @@
+// Package diff aligns two traces to the same target and classifies how the
+// path changed between them.
+package diff
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Kind classifies a change between two traces.
+type Kind string
+
+const (
+	KindInserted Kind = "inserted"           // Hop only on the new path
+	KindRemoved  Kind = "removed"            // Hop only on the old path
+	KindReplaced Kind = "replaced"           // Different router at the same place in the path
+	KindASN      Kind = "asn"                // Aligned hops in different ASes
+	KindLatency  Kind = "latency_regression" // RTT at an aligned hop went up
+	KindLoss     Kind = "loss_regression"    // Loss at an aligned hop went up
+	KindMPLS     Kind = "mpls"               // MPLS label stack changed
+)
+
+// Options controls which latency and loss changes are reported.
+type Options struct {
+	// LatencyIncrease is the minimum RTT increase reported as a latency
+	// regression.
+	LatencyIncrease time.Duration
+	// LatencyThreshold only reports latency regressions ending above it.
+	LatencyThreshold time.Duration
+	// LossIncrease is the minimum loss increase, in percentage points,
+	// reported as a loss regression.
+	LossIncrease float64
+	// LossThreshold only reports loss regressions ending above it.
+	LossThreshold float64
+}
+
+// DefaultOptions reports RTT increases of 20ms or more. Loss is not compared
+// by default: routers rate-limiting ICMP make single-trace loss noisy.
+func DefaultOptions() Options {
+	return Options{LatencyIncrease: 20 * time.Millisecond}
+}
+
+// latencyEnabled reports whether latency regressions are checked at all.
+func (o Options) latencyEnabled() bool {
+	return o.LatencyIncrease > 0 || o.LatencyThreshold > 0
+}
+
+// lossEnabled reports whether loss regressions are checked at all.
+func (o Options) lossEnabled() bool {
+	return o.LossIncrease > 0 || o.LossThreshold > 0
+}
+
+// HopRef describes one side of an aligned hop.
+type HopRef struct {
+	TTL         int     `json:"ttl"`
+	IP          string  `json:"ip,omitempty"` // Empty when the hop did not answer
+	Hostname    string  `json:"hostname,omitempty"`
+	ASN         uint32  `json:"asn,omitempty"`
+	AvgRTT      float64 `json:"avgRtt"` // in ms
+	LossPercent float64 `json:"lossPercent"`
+
+	hop *hop.Hop
+}
+
+// String formats the hop as its address and AS.
+func (r *HopRef) String() string {
+	if r == nil {
+		return "-"
+	}
+	s := r.IP
+	if s == "" {
+		s = "*"
+	}
+	if r.ASN > 0 {
+		s += fmt.Sprintf(" (AS%d)", r.ASN)
+	}
+	return s
+}
+
+// Step pairs a hop of the old trace with the hop of the new trace it was
+// aligned to. Old is nil for inserted hops, New for removed ones.
+type Step struct {
+	Old *HopRef `json:"old,omitempty"`
+	New *HopRef `json:"new,omitempty"`
+}
+
+// Change is a classified difference between the two traces.
+type Change struct {
+	Kind    Kind    `json:"kind"`
+	Old     *HopRef `json:"old,omitempty"`
+	New     *HopRef `json:"new,omitempty"`
+	Message string  `json:"message"`
+}
+
+// TTL is the position of the change: the new hop's TTL, or the old one's for
+// removed hops.
+func (c Change) TTL() int {
+	if c.New != nil {
+		return c.New.TTL
+	}
+	if c.Old != nil {
+		return c.Old.TTL
+	}
+	return 0
+}
+
+// String formats the change for display.
+func (c Change) String() string {
+	return fmt.Sprintf("[%s] TTL %d: %s", c.Kind, c.TTL(), c.Message)
+}
+
+// TraceInfo identifies one of the compared traces.
+type TraceInfo struct {
+	Target        string    `json:"target"`
+	TargetIP      string    `json:"targetIP"`
+	Source        string    `json:"source,omitempty"`
+	StartTime     time.Time `json:"startTime,omitempty"`
+	Hops          int       `json:"hops"`
+	ReachedTarget bool      `json:"reachedTarget"`
+	ASPath        []uint32  `json:"asPath,omitempty"`
+}
+
+// Result is the machine-readable difference between two traces.
+type Result struct {
+	Old        TraceInfo  `json:"old"`
+	New        TraceInfo  `json:"new"`
+	Alignment  []Step     `json:"alignment"`
+	Changes    []Change   `json:"changes"`
+	Divergence Divergence `json:"divergence"`
+}
+
+// Count returns the number of changes of the given kind.
+func (r *Result) Count(kind Kind) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// PathChanged reports whether the routers or ASes along the path differ.
+func (r *Result) PathChanged() bool {
+	for _, c := range r.Changes {
+		switch c.Kind {
+		case KindInserted, KindRemoved, KindReplaced, KindASN:
+			return true
+		}
+	}
+	return false
+}
+
+// Compare aligns the hops of two traces and classifies their differences.
+func Compare(prev, curr *hop.TraceResult, opts Options) *Result {
+	oldRefs := hopRefs(prev)
+	newRefs := hopRefs(curr)
+
+	res := &Result{
+		Old:       traceInfo(prev, oldRefs),
+		New:       traceInfo(curr, newRefs),
+		Alignment: align(oldRefs, newRefs),
+	}
+	for _, step := range res.Alignment {
+		res.Changes = append(res.Changes, classify(step, opts)...)
+	}
+	res.Divergence = FindDivergence(res.Alignment)
+	return res
+}
+
+// hopRefs describes the hops of a trace in TTL order.
+func hopRefs(tr *hop.TraceResult) []*HopRef {
+	refs := make([]*HopRef, 0, len(tr.Hops))
+	for _, h := range tr.Hops {
+		ref := &HopRef{
+			TTL:         h.TTL,
+			Hostname:    h.Enrichment.Hostname,
+			ASN:         h.Enrichment.ASN,
+			AvgRTT:      math.Round(hop.Milliseconds(h.AvgRTT())*1000) / 1000, // To the microsecond
+			LossPercent: h.LossPercent(),
+			hop:         h,
+		}
+		if ip := h.PrimaryIP(); ip != nil {
+			ref.IP = ip.String()
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// traceInfo summarizes a trace, including the ASes its hops cross in order.
+func traceInfo(tr *hop.TraceResult, refs []*HopRef) TraceInfo {
+	info := TraceInfo{
+		Target:        tr.Target,
+		TargetIP:      tr.TargetIP,
+		Source:        tr.Source,
+		StartTime:     tr.StartTime,
+		Hops:          len(tr.Hops),
+		ReachedTarget: tr.ReachedTarget,
+	}
+	for _, ref := range refs {
+		if ref.ASN > 0 && (len(info.ASPath) == 0 || info.ASPath[len(info.ASPath)-1] != ref.ASN) {
+			info.ASPath = append(info.ASPath, ref.ASN)
+		}
+	}
+	return info
+}
+
+// Alignment scores: a shared address anchors two hops much more firmly than
+// a shared AS, which only says the routers belong to the same network.
+const (
+	scoreIP  = 3
+	scoreASN = 1
+)
+
+// matchScore rates how likely two hops are the same point in the path.
+func matchScore(a, b *HopRef) int {
+	if a.IP == "" || b.IP == "" {
+		return 0
+	}
+	if sharesAddress(a.hop, b.hop) {
+		return scoreIP
+	}
+	if a.ASN > 0 && a.ASN == b.ASN {
+		return scoreASN
+	}
+	return 0
+}
+
+// sharesAddress reports whether two hops have a responding address in
+// common, so ECMP hops answering from several routers still line up.
+func sharesAddress(a, b *hop.Hop) bool {
+	for _, pa := range a.Probes {
+		if pa.Timeout || pa.IP == nil {
+			continue
+		}
+		for _, pb := range b.Probes {
+			if !pb.Timeout && pb.IP != nil && pa.IP.Equal(pb.IP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// align pairs up the hops of both traces. Hops matching by address or AS
+// are anchored with a weighted longest common subsequence, so an inserted or
+// removed hop shifts the TTLs without making every later hop look changed.
+// Unmatched hops between two anchors are paired in order; the surplus on
+// either side is an insertion or a removal.
+func align(prev, curr []*HopRef) []Step {
+	n, m := len(prev), len(curr)
+
+	// best[i][j] is the best score aligning prev[i:] with curr[j:]
+	best := make([][]int, n+1)
+	for i := range best {
+		best[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			best[i][j] = max(best[i+1][j], best[i][j+1])
+			if s := matchScore(prev[i], curr[j]); s > 0 {
+				best[i][j] = max(best[i][j], best[i+1][j+1]+s)
+			}
+		}
+	}
+
+	var steps []Step
+	gapOld, gapNew := 0, 0 // Start of the current unmatched runs
+	flush := func(endOld, endNew int) {
+		for gapOld < endOld && gapNew < endNew {
+			steps = append(steps, Step{Old: prev[gapOld], New: curr[gapNew]})
+			gapOld++
+			gapNew++
+		}
+		for ; gapOld < endOld; gapOld++ {
+			steps = append(steps, Step{Old: prev[gapOld]})
+		}
+		for ; gapNew < endNew; gapNew++ {
+			steps = append(steps, Step{New: curr[gapNew]})
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		if s := matchScore(prev[i], curr[j]); s > 0 && best[i][j] == best[i+1][j+1]+s {
+			flush(i, j)
+			steps = append(steps, Step{Old: prev[i], New: curr[j]})
+			i++
+			j++
+			gapOld, gapNew = i, j
+		} else if best[i+1][j] >= best[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	flush(n, m)
+	return steps
+}
+
+// classify returns the changes at one aligned step.
+func classify(step Step, opts Options) []Change {
+	o, n := step.Old, step.New
+	switch {
+	case o == nil:
+		return []Change{{Kind: KindInserted, New: n, Message: "hop inserted: " + n.String()}}
+	case n == nil:
+		return []Change{{Kind: KindRemoved, Old: o, Message: "hop removed: " + o.String()}}
+	}
+
+	var changes []Change
+	add := func(kind Kind, format string, args ...any) {
+		changes = append(changes, Change{Kind: kind, Old: o, New: n, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// A silent hop on either side hides whether the router changed
+	if o.IP != "" && n.IP != "" {
+		if !sharesAddress(o.hop, n.hop) {
+			add(KindReplaced, "%s replaced by %s", o, n)
+		}
+		if o.ASN > 0 && n.ASN > 0 && o.ASN != n.ASN {
+			add(KindASN, "AS%d changed to AS%d", o.ASN, n.ASN)
+		}
+	}
+
+	if opts.latencyEnabled() && o.AvgRTT > 0 && n.AvgRTT > 0 {
+		increase := n.AvgRTT - o.AvgRTT
+		if increase > 0 && increase >= hop.Milliseconds(opts.LatencyIncrease) && n.AvgRTT > hop.Milliseconds(opts.LatencyThreshold) {
+			add(KindLatency, "latency up from %.1fms to %.1fms (+%.1fms)", o.AvgRTT, n.AvgRTT, increase)
+		}
+	}
+
+	if opts.lossEnabled() {
+		increase := n.LossPercent - o.LossPercent
+		if increase > 0 && increase >= opts.LossIncrease && n.LossPercent > opts.LossThreshold {
+			add(KindLoss, "loss up from %.0f%% to %.0f%%", o.LossPercent, n.LossPercent)
+		}
+	}
+
+	if !mplsEqual(o.hop.MPLS, n.hop.MPLS) {
+		add(KindMPLS, "MPLS label stack changed from %s to %s", formatMPLS(o.hop.MPLS), formatMPLS(n.hop.MPLS))
+	}
+
+	return changes
+}
+
+// mplsEqual compares label stacks by label and TTL; the TTL tells where in
+// the tunnel the hop sits.
+func mplsEqual(a, b []hop.MPLSLabel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Label != b[i].Label || a[i].TTL != b[i].TTL {
+			return false
+		}
+	}
+	return true
+}
+
+// formatMPLS lists the labels of a stack, or "none".
+func formatMPLS(labels []hop.MPLSLabel) string {
+	if len(labels) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = strconv.FormatUint(uint64(l.Label), 10)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,178 @@
+package diff
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/tracetest"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func kinds(res *Result) []Kind {
+	var ks []Kind
+	for _, c := range res.Changes {
+		ks = append(ks, c.Kind)
+	}
+	return ks
+}
+
+func TestCompare_IdenticalTraces(t *testing.T) {
+	tr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1", ASN: 3356}, tracetest.Hop{IP: "93.184.216.34", ASN: 15133})
+
+	res := Compare(tr, tr, DefaultOptions())
+	if len(res.Changes) != 0 {
+		t.Errorf("expected no changes, got %v", res.Changes)
+	}
+	if len(res.Alignment) != 3 {
+		t.Errorf("expected 3 aligned hops, got %d", len(res.Alignment))
+	}
+	if res.PathChanged() {
+		t.Error("expected path unchanged")
+	}
+}
+
+func TestCompare_InsertedHopShiftsTTLs(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.5"}, tracetest.Hop{IP: "10.0.0.1", RTT: 2 * time.Millisecond}, tracetest.Hop{IP: "93.184.216.34", RTT: 3 * time.Millisecond})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if got := kinds(res); !slices.Equal(got, []Kind{KindInserted}) {
+		t.Fatalf("expected a single insertion, got %v", res.Changes)
+	}
+	c := res.Changes[0]
+	if c.New.IP != "10.0.0.5" || c.TTL() != 2 {
+		t.Errorf("expected 10.0.0.5 inserted at TTL 2, got %s at TTL %d", c.New.IP, c.TTL())
+	}
+}
+
+func TestCompare_RemovedHop(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1"}, tracetest.Hop{IP: "10.0.0.2"}, tracetest.Hop{IP: "93.184.216.34", RTT: 3 * time.Millisecond})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.2", RTT: 3 * time.Millisecond}, tracetest.Hop{IP: "93.184.216.34"})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if got := kinds(res); !slices.Equal(got, []Kind{KindRemoved}) {
+		t.Fatalf("expected a single removal, got %v", res.Changes)
+	}
+	if res.Changes[0].Old.IP != "10.0.0.1" || res.Changes[0].TTL() != 2 {
+		t.Errorf("expected 10.0.0.1 removed at TTL 2, got %v", res.Changes[0])
+	}
+}
+
+func TestCompare_ReplacedHopWithASNChange(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "4.69.1.1", ASN: 3356}, tracetest.Hop{IP: "93.184.216.34", ASN: 15133})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "154.54.1.1", ASN: 174}, tracetest.Hop{IP: "93.184.216.34", ASN: 15133})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if got := kinds(res); !slices.Equal(got, []Kind{KindReplaced, KindASN}) {
+		t.Fatalf("expected replacement and AS change, got %v", res.Changes)
+	}
+	if !strings.Contains(res.Changes[0].Message, "4.69.1.1 (AS3356) replaced by 154.54.1.1 (AS174)") {
+		t.Errorf("unexpected message %q", res.Changes[0].Message)
+	}
+	if !res.PathChanged() {
+		t.Error("expected path changed")
+	}
+}
+
+func TestCompare_SameASAlignsDifferentRouters(t *testing.T) {
+	// The second path has an extra router in AS3356: the router sharing the
+	// AS should line up with the old one rather than the insertion
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "4.69.1.1", ASN: 3356}, tracetest.Hop{IP: "93.184.216.34", ASN: 15133})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.9.9.9", ASN: 64500}, tracetest.Hop{IP: "4.69.2.2", ASN: 3356}, tracetest.Hop{IP: "93.184.216.34", ASN: 15133, RTT: 3 * time.Millisecond})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if got := kinds(res); !slices.Equal(got, []Kind{KindInserted, KindReplaced}) {
+		t.Fatalf("expected insertion then replacement, got %v", res.Changes)
+	}
+	if res.Changes[1].Old.IP != "4.69.1.1" || res.Changes[1].New.IP != "4.69.2.2" {
+		t.Errorf("expected 4.69.1.1 replaced by 4.69.2.2, got %v", res.Changes[1])
+	}
+}
+
+func TestCompare_SilentHopIsNotAReplacement(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{}, tracetest.Hop{IP: "93.184.216.34"})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if len(res.Changes) != 0 {
+		t.Errorf("expected no changes for a hop that stopped answering, got %v", res.Changes)
+	}
+}
+
+func TestCompare_LatencyRegression(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1", RTT: time.Millisecond}, tracetest.Hop{IP: "93.184.216.34", RTT: 10 * time.Millisecond})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1", RTT: 5 * time.Millisecond}, tracetest.Hop{IP: "93.184.216.34", RTT: 45 * time.Millisecond})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if got := kinds(res); !slices.Equal(got, []Kind{KindLatency}) {
+		t.Fatalf("expected one latency regression, got %v", res.Changes)
+	}
+	if res.Changes[0].Message != "latency up from 10.0ms to 45.0ms (+35.0ms)" {
+		t.Errorf("unexpected message %q", res.Changes[0].Message)
+	}
+
+	// Below the absolute threshold
+	opts := DefaultOptions()
+	opts.LatencyThreshold = 50 * time.Millisecond
+	if res := Compare(prev, curr, opts); len(res.Changes) != 0 {
+		t.Errorf("expected no regression under the threshold, got %v", res.Changes)
+	}
+}
+
+func TestCompare_LossRegressionNeedsThreshold(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr.Hops[1].AddTimeout()
+
+	if res := Compare(prev, curr, DefaultOptions()); len(res.Changes) != 0 {
+		t.Errorf("expected loss ignored by default, got %v", res.Changes)
+	}
+
+	opts := DefaultOptions()
+	opts.LossThreshold = 5
+	res := Compare(prev, curr, opts)
+	if got := kinds(res); !slices.Equal(got, []Kind{KindLoss}) {
+		t.Errorf("expected a loss regression, got %v", res.Changes)
+	}
+}
+
+func TestCompare_MPLSChange(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr.Hops[0].SetMPLS([]hop.MPLSLabel{{Label: 24001, S: true, TTL: 1}})
+
+	res := Compare(prev, curr, DefaultOptions())
+	if got := kinds(res); !slices.Equal(got, []Kind{KindMPLS}) {
+		t.Fatalf("expected an MPLS change, got %v", res.Changes)
+	}
+	if res.Changes[0].Message != "MPLS label stack changed from none to 24001" {
+		t.Errorf("unexpected message %q", res.Changes[0].Message)
+	}
+}
+
+func TestCompare_ASPath(t *testing.T) {
+	tr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "4.69.1.1", ASN: 3356}, tracetest.Hop{IP: "4.69.1.2", ASN: 3356}, tracetest.Hop{IP: "93.184.216.34", ASN: 15133})
+
+	res := Compare(tr, tr, DefaultOptions())
+	if got := res.New.ASPath; len(got) != 2 || got[0] != 3356 || got[1] != 15133 {
+		t.Errorf("expected AS path [3356 15133], got %v", got)
+	}
+}
+
+func TestResult_MarshalsJSON(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.9"})
+
+	data, err := json.Marshal(Compare(prev, curr, DefaultOptions()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"kind":"replaced"`, `"old":{"ttl":2,"ip":"10.0.0.1"`, `"alignment":[`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+}
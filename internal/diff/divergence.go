@@ -0,0 +1,105 @@
+package diff
+
+import "fmt"
+
+// Divergence locates where two aligned paths split and where they meet
+// again. Steps where either hop did not answer are skipped: a silent router
+// says nothing about which path the packets took.
+type Divergence struct {
+	// FirstIP is the first step whose hops are different routers, or where
+	// one path has a hop the other lacks. Nil when the routers never differ.
+	FirstIP *Step `json:"firstIp,omitempty"`
+	// FirstASN is the first step whose hops are in different ASes.
+	FirstASN *Step `json:"firstAsn,omitempty"`
+	// LastCommon is the last router both paths cross before FirstIP. Nil
+	// when the paths differ from their first answering hop.
+	LastCommon *Step `json:"lastCommon,omitempty"`
+	// Convergences are the routers where the paths meet again after
+	// differing.
+	Convergences []Convergence `json:"convergences,omitempty"`
+}
+
+// Convergence is a router where two diverged paths meet again.
+type Convergence struct {
+	Step
+	RTTDelta float64 `json:"rttDelta"` // New minus old average RTT, in ms
+}
+
+// FindDivergence walks an alignment and locates the divergence point, the
+// last common hop and the points where the paths converge again.
+func FindDivergence(alignment []Step) Divergence {
+	var d Divergence
+	diverged := false
+	for i := range alignment {
+		step := &alignment[i]
+		o, n := step.Old, step.New
+		if (o != nil && o.IP == "") || (n != nil && n.IP == "") {
+			continue
+		}
+
+		if o == nil || n == nil || !sharesAddress(o.hop, n.hop) {
+			if d.FirstIP == nil {
+				d.FirstIP = step
+			}
+			if d.FirstASN == nil && o != nil && n != nil && o.ASN > 0 && n.ASN > 0 && o.ASN != n.ASN {
+				d.FirstASN = step
+			}
+			diverged = true
+			continue
+		}
+
+		if d.FirstIP == nil {
+			d.LastCommon = step
+		}
+		if diverged {
+			c := Convergence{Step: *step}
+			if o.AvgRTT > 0 && n.AvgRTT > 0 {
+				c.RTTDelta = n.AvgRTT - o.AvgRTT
+			}
+			d.Convergences = append(d.Convergences, c)
+			diverged = false
+		}
+	}
+	return d
+}
+
+// Summary describes the divergence in a few lines for display.
+func (d Divergence) Summary() []string {
+	if d.FirstIP == nil {
+		return []string{"Same routers along the whole path"}
+	}
+
+	lines := []string{fmt.Sprintf("Diverges at %s: %s vs %s", stepTTLs(*d.FirstIP), d.FirstIP.Old, d.FirstIP.New)}
+	if d.FirstASN != nil {
+		lines = append(lines, fmt.Sprintf("AS diverges at %s: AS%d vs AS%d", stepTTLs(*d.FirstASN), d.FirstASN.Old.ASN, d.FirstASN.New.ASN))
+	} else {
+		lines = append(lines, "Same AS at every aligned hop")
+	}
+	if d.LastCommon != nil {
+		lines = append(lines, fmt.Sprintf("Last common hop: %s %s", stepTTLs(*d.LastCommon), d.LastCommon.New))
+	} else {
+		lines = append(lines, "Last common hop: none (paths differ from the first hop)")
+	}
+	for _, c := range d.Convergences {
+		line := fmt.Sprintf("Converges at %s: %s", stepTTLs(c.Step), c.New)
+		if c.Old.AvgRTT > 0 && c.New.AvgRTT > 0 {
+			line += fmt.Sprintf(", RTT %+.1fms", c.RTTDelta)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// stepTTLs formats the TTL of a step, or both when the sides differ
+// ("TTL 4/5").
+func stepTTLs(s Step) string {
+	switch {
+	case s.Old == nil:
+		return fmt.Sprintf("TTL -/%d", s.New.TTL)
+	case s.New == nil:
+		return fmt.Sprintf("TTL %d/-", s.Old.TTL)
+	case s.Old.TTL != s.New.TTL:
+		return fmt.Sprintf("TTL %d/%d", s.Old.TTL, s.New.TTL)
+	}
+	return fmt.Sprintf("TTL %d", s.New.TTL)
+}
@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/tracetest"
+)
+
+func TestFindDivergence_IdenticalPaths(t *testing.T) {
+	tr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1", ASN: 3356})
+
+	d := Compare(tr, tr, DefaultOptions()).Divergence
+	if d.FirstIP != nil || d.FirstASN != nil || len(d.Convergences) != 0 {
+		t.Errorf("expected no divergence, got %+v", d)
+	}
+	if d.LastCommon == nil || d.LastCommon.New.IP != "10.0.0.1" {
+		t.Errorf("expected last common hop 10.0.0.1, got %+v", d.LastCommon)
+	}
+	if got := d.Summary(); !slices.Equal(got, []string{"Same routers along the whole path"}) {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
+
+func TestFindDivergence_SplitAndRejoin(t *testing.T) {
+	prev := tracetest.Trace(
+		tracetest.Hop{IP: "192.168.1.1"},
+		tracetest.Hop{IP: "4.69.1.1", ASN: 3356},
+		tracetest.Hop{IP: "4.69.1.2", ASN: 3356},
+		tracetest.Hop{IP: "93.184.216.34", ASN: 15133, RTT: 20 * time.Millisecond},
+	)
+	curr := tracetest.Trace(
+		tracetest.Hop{IP: "192.168.1.1"},
+		tracetest.Hop{IP: "4.69.9.9", ASN: 3356},
+		tracetest.Hop{IP: "154.54.1.1", ASN: 174},
+		tracetest.Hop{IP: "93.184.216.34", ASN: 15133, RTT: 25 * time.Millisecond},
+	)
+
+	d := Compare(prev, curr, DefaultOptions()).Divergence
+	if d.LastCommon == nil || d.LastCommon.New.IP != "192.168.1.1" {
+		t.Errorf("expected last common hop 192.168.1.1, got %+v", d.LastCommon)
+	}
+	if d.FirstIP == nil || d.FirstIP.New.IP != "4.69.9.9" {
+		t.Errorf("expected routers to diverge at 4.69.9.9, got %+v", d.FirstIP)
+	}
+	if d.FirstASN == nil || d.FirstASN.New.ASN != 174 {
+		t.Errorf("expected ASes to diverge at AS174, got %+v", d.FirstASN)
+	}
+	if len(d.Convergences) != 1 || d.Convergences[0].New.IP != "93.184.216.34" || d.Convergences[0].RTTDelta != 5 {
+		t.Fatalf("expected convergence at the target 5ms slower, got %+v", d.Convergences)
+	}
+
+	want := []string{
+		"Diverges at TTL 2: 4.69.1.1 (AS3356) vs 4.69.9.9 (AS3356)",
+		"AS diverges at TTL 3: AS3356 vs AS174",
+		"Last common hop: TTL 1 192.168.1.1",
+		"Converges at TTL 4: 93.184.216.34 (AS15133), RTT +5.0ms",
+	}
+	if got := d.Summary(); !slices.Equal(got, want) {
+		t.Errorf("unexpected summary:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFindDivergence_DifferentFromFirstHop(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "10.0.0.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "10.9.0.1"}, tracetest.Hop{IP: "10.9.0.2"}, tracetest.Hop{IP: "93.184.216.34"})
+
+	d := Compare(prev, curr, DefaultOptions()).Divergence
+	if d.LastCommon != nil {
+		t.Errorf("expected no common hop before the divergence, got %+v", d.LastCommon)
+	}
+	if len(d.Convergences) != 1 {
+		t.Fatalf("expected one convergence, got %+v", d.Convergences)
+	}
+	if got := d.Summary(); got[2] != "Last common hop: none (paths differ from the first hop)" || got[3] != "Converges at TTL 2/3: 93.184.216.34, RTT +1.0ms" {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
+
+func TestFindDivergence_SilentHopsIgnored(t *testing.T) {
+	prev := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{IP: "10.0.0.1"}, tracetest.Hop{IP: "93.184.216.34"})
+	curr := tracetest.Trace(tracetest.Hop{IP: "192.168.1.1"}, tracetest.Hop{}, tracetest.Hop{IP: "93.184.216.34"})
+
+	d := Compare(prev, curr, DefaultOptions()).Divergence
+	if d.FirstIP != nil || len(d.Convergences) != 0 {
+		t.Errorf("expected a silent hop not to count as divergence, got %+v", d)
+	}
+}
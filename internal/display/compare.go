@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/hervehildenbrand/gtrace/internal/diff"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 	"golang.org/x/term"
 )
@@ -17,20 +18,17 @@ const (
 	colWidthMax = 45
 )
 
-// Source colors for up to 5 sources.
-var sourceColors = []lipgloss.Color{
-	lipgloss.Color("39"),  // Cyan
-	lipgloss.Color("208"), // Orange
-	lipgloss.Color("141"), // Purple
-	lipgloss.Color("82"),  // Green
-	lipgloss.Color("205"), // Pink
-}
+// Source colors, cycled when there are more sources, set from the active
+// theme by ApplyTheme.
+var sourceColors []lipgloss.Color
 
 // CompareRenderer renders trace results from multiple sources.
 type CompareRenderer struct {
 	writer    io.Writer
 	noColor   bool
 	termWidth int
+
+	AlignByASN bool // Align rows by AS sequence rather than TTL
 }
 
 // NewCompareRenderer creates a new CompareRenderer.
@@ -75,10 +73,48 @@ func (r *CompareRenderer) RenderAll(sources []*hop.TraceResult) error {
 
 	fmt.Fprintf(r.writer, "Comparing traces to %s\n\n", target)
 
-	if len(sources) <= 3 {
-		return r.renderUnified(sources)
+	var err error
+	switch {
+	case r.AlignByASN:
+		err = r.renderASNAligned(sources)
+	case len(sources) <= 3:
+		err = r.renderUnified(sources)
+	default:
+		err = r.renderStacked(sources)
+	}
+	if err != nil {
+		return err
+	}
+	r.renderDivergence(sources)
+	return nil
+}
+
+// renderDivergence summarizes, for every other source, where its path splits
+// from and rejoins the first source's path. Sources without hops (failed
+// traces) are skipped.
+func (r *CompareRenderer) renderDivergence(sources []*hop.TraceResult) {
+	if len(sources) < 2 || len(sources[0].Hops) == 0 {
+		return
+	}
+
+	ref := sources[0].Source
+	if ref == "" {
+		ref = "Source 1"
+	}
+	fmt.Fprintf(r.writer, "\nPath divergence from %s\n", r.colorize(ref, 0))
+	for i, src := range sources[1:] {
+		if len(src.Hops) == 0 {
+			continue
+		}
+		name := src.Source
+		if name == "" {
+			name = fmt.Sprintf("Source %d", i+2)
+		}
+		fmt.Fprintf(r.writer, "  %s\n", r.colorize(name, i+1))
+		for _, line := range diff.Compare(sources[0], src, diff.Options{}).Divergence.Summary() {
+			fmt.Fprintf(r.writer, "    %s\n", line)
+		}
 	}
-	return r.renderStacked(sources)
 }
 
 // calcColumnWidth computes the width for each data column in unified layout.
@@ -123,14 +159,14 @@ func (r *CompareRenderer) renderUnified(sources []*hop.TraceResult) error {
 		}
 		headerParts[i] = r.colorize(fmt.Sprintf("%-*s", colWidth, name), i)
 	}
-	fmt.Fprintf(r.writer, "Hop │ %s\n", strings.Join(headerParts, " │ "))
+	fmt.Fprintf(r.writer, "Hop %s %s\n", glyphs.VLine, strings.Join(headerParts, " "+glyphs.VLine+" "))
 
 	// Separator row
 	sepParts := make([]string, numCols)
 	for i := range sepParts {
-		sepParts[i] = strings.Repeat("─", colWidth)
+		sepParts[i] = strings.Repeat(glyphs.HLine, colWidth)
 	}
-	fmt.Fprintf(r.writer, "────┼─%s\n", strings.Join(sepParts, "─┼─"))
+	fmt.Fprintf(r.writer, "%s\n", ruleRow(sepParts))
 
 	// Data rows by TTL
 	for ttl := 1; ttl <= maxTTL; ttl++ {
@@ -150,15 +186,15 @@ func (r *CompareRenderer) renderUnified(sources []*hop.TraceResult) error {
 			cell := r.formatHopCell(h, colWidth, maxRTT, common, ttl)
 			cols[i] = r.colorize(cell, i)
 		}
-		fmt.Fprintf(r.writer, "%3d │ %s\n", ttl, strings.Join(cols, " │ "))
+		fmt.Fprintf(r.writer, "%3d %s %s\n", ttl, glyphs.VLine, strings.Join(cols, " "+glyphs.VLine+" "))
 	}
 
 	// Summary separator
 	sumSepParts := make([]string, numCols)
 	for i := range sumSepParts {
-		sumSepParts[i] = strings.Repeat("─", colWidth)
+		sumSepParts[i] = strings.Repeat(glyphs.HLine, colWidth)
 	}
-	fmt.Fprintf(r.writer, "────┼─%s\n", strings.Join(sumSepParts, "─┼─"))
+	fmt.Fprintf(r.writer, "%s\n", ruleRow(sumSepParts))
 
 	// Summary row
 	sumParts := make([]string, numCols)
@@ -169,7 +205,7 @@ func (r *CompareRenderer) renderUnified(sources []*hop.TraceResult) error {
 		}
 		sumParts[i] = fmt.Sprintf("%-*s", colWidth, summary)
 	}
-	fmt.Fprintf(r.writer, "    │ %s\n", strings.Join(sumParts, " │ "))
+	fmt.Fprintf(r.writer, "    %s %s\n", glyphs.VLine, strings.Join(sumParts, " "+glyphs.VLine+" "))
 
 	return nil
 }
@@ -204,33 +240,33 @@ func (r *CompareRenderer) renderStacked(sources []*hop.TraceResult) error {
 		contentWidth := boxWidth - 4 // padding inside box
 
 		// Top border with title: ╭─ Name ─────────╮
-		title := fmt.Sprintf("─ %s ", name)
+		title := fmt.Sprintf("%s %s ", glyphs.HLine, name)
 		titleRuneLen := runeDisplayWidth(title)
 		fillLen := boxWidth - titleRuneLen - 1 // -1 for ╭
 		if fillLen < 1 {
 			fillLen = 1
 		}
-		topBorder := "╭" + title + strings.Repeat("─", fillLen) + "╮"
+		topBorder := glyphs.TopLeft + title + strings.Repeat(glyphs.HLine, fillLen) + glyphs.TopRight
 		fmt.Fprintln(r.writer, r.colorize(topBorder, i))
 
 		// Content rows
 		for _, h := range src.Hops {
 			cell := r.formatHopCell(h, contentWidth, maxRTT, common, h.TTL)
-			line := fmt.Sprintf("│  %s  │", padToWidth(cell, contentWidth))
+			line := glyphs.VLine + "  " + padToWidth(cell, contentWidth) + "  " + glyphs.VLine
 			fmt.Fprintln(r.writer, r.colorize(line, i))
 		}
 
 		// Blank line before summary
-		blankLine := fmt.Sprintf("│  %s  │", strings.Repeat(" ", contentWidth))
+		blankLine := glyphs.VLine + "  " + strings.Repeat(" ", contentWidth) + "  " + glyphs.VLine
 		fmt.Fprintln(r.writer, r.colorize(blankLine, i))
 
 		// Summary line
 		summary := r.formatSummary(src)
-		summaryLine := fmt.Sprintf("│  %s  │", padToWidth(summary, contentWidth))
+		summaryLine := glyphs.VLine + "  " + padToWidth(summary, contentWidth) + "  " + glyphs.VLine
 		fmt.Fprintln(r.writer, r.colorize(summaryLine, i))
 
 		// Bottom border: ╰──────────────────╯
-		bottomBorder := "╰" + strings.Repeat("─", boxWidth) + "╯"
+		bottomBorder := glyphs.BottomLeft + strings.Repeat(glyphs.HLine, boxWidth) + glyphs.BottomRight
 		fmt.Fprintln(r.writer, r.colorize(bottomBorder, i))
 
 		if i < len(sources)-1 {
@@ -250,6 +286,19 @@ func padToWidth(s string, width int) string {
 	return s + strings.Repeat(" ", width-displayLen)
 }
 
+// ruleRow builds a horizontal separator line matching the "Hop | ..." column layout.
+func ruleRow(parts []string) string {
+	return labelRule(3, parts)
+}
+
+// labelRule builds a horizontal separator line for a table whose first
+// column is labelWidth wide.
+func labelRule(labelWidth int, parts []string) string {
+	h := glyphs.HLine
+	joint := h + glyphs.Cross + h
+	return strings.Repeat(h, labelWidth+1) + joint[len(h):] + strings.Join(parts, joint)
+}
+
 // formatHopCell formats a single hop within a column of given width.
 func (r *CompareRenderer) formatHopCell(h *hop.Hop, colWidth int, maxRTT time.Duration, common map[int]map[string]int, ttl int) string {
 	if h == nil {
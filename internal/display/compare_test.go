@@ -715,3 +715,42 @@ func createTestTraceResult(targetIP string, reached bool, hops []testHop) *hop.T
 
 	return result
 }
+
+func TestRenderAll_DivergenceSummary(t *testing.T) {
+	local := createTestTraceResult("8.8.8.8", true, []testHop{
+		{ttl: 1, ip: "192.168.1.1", rtt: 500 * time.Microsecond},
+		{ttl: 2, ip: "80.10.255.25", rtt: 1500 * time.Microsecond},
+		{ttl: 3, ip: "8.8.8.8", rtt: 2000 * time.Microsecond},
+	})
+	local.Source = "Local"
+
+	remote := createTestTraceResult("8.8.8.8", true, []testHop{
+		{ttl: 1, ip: "51.89.217.252", rtt: 400 * time.Microsecond},
+		{ttl: 2, ip: "8.8.8.8", rtt: 3000 * time.Microsecond},
+	})
+	remote.Source = "London, GB"
+
+	failed := hop.NewTraceResult("8.8.8.8", "")
+	failed.Source = "Tokyo, JP"
+
+	var buf bytes.Buffer
+	if err := NewCompareRenderer(&buf, true).RenderAll([]*hop.TraceResult{local, remote, failed}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		"Path divergence from Local",
+		"  London, GB\n",
+		"Diverges at TTL 1: 192.168.1.1 vs 51.89.217.252",
+		"Last common hop: none (paths differ from the first hop)",
+		"Converges at TTL 3/2: 8.8.8.8, RTT +1.0ms",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "  Tokyo, JP\n") {
+		t.Errorf("expected the failed source left out of the summary:\n%s", output)
+	}
+}
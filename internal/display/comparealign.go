@@ -0,0 +1,196 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// asnLabelWidth is the width of the AS column of the ASN-aligned table,
+// wide enough for a 32-bit ASN and the divergence marker.
+const asnLabelWidth = 14
+
+// asnSegment is a run of consecutive hops of one trace in the same AS.
+type asnSegment struct {
+	asn  uint32 // 0 when unknown (private space, no reply)
+	hops []*hop.Hop
+}
+
+// asnRow is one AS of the merged path, with the segment of each source that
+// crosses it (nil for sources that do not).
+type asnRow struct {
+	asn  uint32
+	segs []*asnSegment
+}
+
+// asnSegments splits a trace into runs of hops by AS. Hops of unknown AS
+// join the run before them, so a silent router inside a provider does not
+// split it; leading ones form their own run.
+func asnSegments(tr *hop.TraceResult) []*asnSegment {
+	var segs []*asnSegment
+	for _, h := range tr.Hops {
+		asn := h.Enrichment.ASN
+		if len(segs) > 0 {
+			last := segs[len(segs)-1]
+			if asn == 0 || asn == last.asn {
+				last.hops = append(last.hops, h)
+				continue
+			}
+		}
+		segs = append(segs, &asnSegment{asn: asn, hops: []*hop.Hop{h}})
+	}
+	return segs
+}
+
+// alignASNs merges the AS sequences of all sources into one, aligning each
+// source with the sources before it by longest common subsequence: ASes the
+// paths share line up, the others get rows of their own in path order.
+func alignASNs(sources []*hop.TraceResult) []asnRow {
+	var rows []asnRow
+	for s, src := range sources {
+		segs := asnSegments(src)
+		n, m := len(rows), len(segs)
+
+		// best[i][j] is the longest common subsequence of rows[i:] and segs[j:]
+		best := make([][]int, n+1)
+		for i := range best {
+			best[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if rows[i].asn == segs[j].asn {
+					best[i][j] = best[i+1][j+1] + 1
+				} else {
+					best[i][j] = max(best[i+1][j], best[i][j+1])
+				}
+			}
+		}
+
+		merged := make([]asnRow, 0, n+m)
+		newRow := func(seg *asnSegment) asnRow {
+			row := asnRow{asn: seg.asn, segs: make([]*asnSegment, len(sources))}
+			row.segs[s] = seg
+			return row
+		}
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case rows[i].asn == segs[j].asn:
+				rows[i].segs[s] = segs[j]
+				merged = append(merged, rows[i])
+				i++
+				j++
+			case best[i+1][j] >= best[i][j+1]:
+				merged = append(merged, rows[i])
+				i++
+			default:
+				merged = append(merged, newRow(segs[j]))
+				j++
+			}
+		}
+		merged = append(merged, rows[i:]...)
+		for ; j < m; j++ {
+			merged = append(merged, newRow(segs[j]))
+		}
+		rows = merged
+	}
+	return rows
+}
+
+// renderASNAligned renders the sources side by side with rows aligned by AS
+// rather than TTL. Each cell starts with the hop's TTL; ASes that not every
+// source with hops crosses are marked "!".
+func (r *CompareRenderer) renderASNAligned(sources []*hop.TraceResult) error {
+	numCols := len(sources)
+	colWidth := calcColumnWidth(r.termWidth-(asnLabelWidth-3), numCols)
+	cellWidth := colWidth - 4 // "%3d " TTL prefix
+	common := computeCommonHops(sources)
+	rows := alignASNs(sources)
+
+	traced := 0
+	for _, src := range sources {
+		if len(src.Hops) > 0 {
+			traced++
+		}
+	}
+
+	headerParts := make([]string, numCols)
+	for i, src := range sources {
+		name := src.Source
+		if name == "" {
+			name = fmt.Sprintf("Source %d", i+1)
+		}
+		if len(name) > colWidth {
+			name = name[:colWidth-3] + "..."
+		}
+		headerParts[i] = r.colorize(fmt.Sprintf("%-*s", colWidth, name), i)
+	}
+	fmt.Fprintf(r.writer, "%-*s %s %s\n", asnLabelWidth, "AS", glyphs.VLine, strings.Join(headerParts, " "+glyphs.VLine+" "))
+
+	sepParts := make([]string, numCols)
+	for i := range sepParts {
+		sepParts[i] = strings.Repeat(glyphs.HLine, colWidth)
+	}
+	fmt.Fprintf(r.writer, "%s\n", labelRule(asnLabelWidth, sepParts))
+
+	for _, row := range rows {
+		label := "?"
+		if row.asn > 0 {
+			label = fmt.Sprintf("AS%d", row.asn)
+		}
+		height, crossing := 0, 0
+		for _, seg := range row.segs {
+			if seg != nil {
+				height = max(height, len(seg.hops))
+				crossing++
+			}
+		}
+		if crossing < traced {
+			label += " !"
+		}
+
+		// Sparks are scaled across the AS rather than the TTL, which differs
+		// between the columns
+		var maxRTT time.Duration
+		for _, seg := range row.segs {
+			if seg != nil {
+				for _, h := range seg.hops {
+					maxRTT = max(maxRTT, h.AvgRTT())
+				}
+			}
+		}
+
+		for line := range height {
+			cols := make([]string, numCols)
+			for i, seg := range row.segs {
+				if seg == nil || line >= len(seg.hops) {
+					cols[i] = strings.Repeat(" ", colWidth)
+					continue
+				}
+				h := seg.hops[line]
+				cell := fmt.Sprintf("%3d ", h.TTL) + r.formatHopCell(h, cellWidth, maxRTT, common, h.TTL)
+				cols[i] = r.colorize(cell, i)
+			}
+			if line > 0 {
+				label = ""
+			}
+			fmt.Fprintf(r.writer, "%-*s %s %s\n", asnLabelWidth, label, glyphs.VLine, strings.Join(cols, " "+glyphs.VLine+" "))
+		}
+	}
+
+	fmt.Fprintf(r.writer, "%s\n", labelRule(asnLabelWidth, sepParts))
+
+	sumParts := make([]string, numCols)
+	for i, src := range sources {
+		summary := r.formatSummary(src)
+		if len(summary) > colWidth {
+			summary = summary[:colWidth]
+		}
+		sumParts[i] = fmt.Sprintf("%-*s", colWidth, summary)
+	}
+	fmt.Fprintf(r.writer, "%-*s %s %s\n", asnLabelWidth, "", glyphs.VLine, strings.Join(sumParts, " "+glyphs.VLine+" "))
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package display
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// asnTrace builds a trace whose hops are in the given ASes (0 for a
+// timeout), answering from 10.<i>.<ttl>.1.
+func asnTrace(source string, id int, asns ...uint32) *hop.TraceResult {
+	tr := hop.NewTraceResult("example.com", "93.184.216.34")
+	tr.Source = source
+	for i, asn := range asns {
+		h := hop.NewHop(i + 1)
+		if asn == 0 {
+			h.AddTimeout()
+		} else {
+			h.AddProbe(net.IPv4(10, byte(id), byte(i+1), 1), time.Duration(i+1)*time.Millisecond)
+			h.Enrichment.ASN = asn
+		}
+		tr.AddHop(h)
+	}
+	return tr
+}
+
+func TestASNSegments_GroupsRunsAndSilentHops(t *testing.T) {
+	tr := asnTrace("a", 1, 0, 3356, 0, 3356, 174)
+
+	segs := asnSegments(tr)
+
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(segs))
+	}
+	want := []struct {
+		asn  uint32
+		hops int
+	}{{0, 1}, {3356, 3}, {174, 1}}
+	for i, w := range want {
+		if segs[i].asn != w.asn || len(segs[i].hops) != w.hops {
+			t.Errorf("segment %d: expected AS%d with %d hops, got AS%d with %d", i, w.asn, w.hops, segs[i].asn, len(segs[i].hops))
+		}
+	}
+}
+
+func TestAlignASNs_LinesUpSharedProviders(t *testing.T) {
+	a := asnTrace("a", 1, 64500, 3356, 3356, 15169)
+	b := asnTrace("b", 2, 64501, 3356, 174, 15169)
+
+	rows := alignASNs([]*hop.TraceResult{a, b})
+
+	var got []uint32
+	for _, row := range rows {
+		got = append(got, row.asn)
+	}
+	// Both first hops are unshared, AS3356 and AS15169 line up
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 rows, got %v", got)
+	}
+	for _, asn := range []uint32{3356, 15169} {
+		for _, row := range rows {
+			if row.asn == asn && (row.segs[0] == nil || row.segs[1] == nil) {
+				t.Errorf("expected AS%d shared by both sources, got %v", asn, got)
+			}
+		}
+	}
+	if rows[len(rows)-1].asn != 15169 {
+		t.Errorf("expected AS15169 last, got %v", got)
+	}
+}
+
+func TestRenderAll_AlignByASN(t *testing.T) {
+	a := asnTrace("Local", 1, 64500, 3356, 3356, 3356, 15169)
+	b := asnTrace("London", 2, 64500, 174, 15169)
+
+	var buf bytes.Buffer
+	r := NewCompareRenderer(&buf, true)
+	r.AlignByASN = true
+	if err := r.RenderAll([]*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{"AS64500 ", "AS3356 !", "AS174 !", "AS15169 "} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	// The target's AS is one row holding TTL 5 of one source and TTL 3 of the other
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "AS15169") {
+			if !strings.Contains(line, "  5 10.1.5.1") || !strings.Contains(line, "  3 10.2.3.1") {
+				t.Errorf("expected both sources' target hops on the AS15169 row, got %q", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// CompareHopMsg is sent when a compare source receives a new hop
+type CompareHopMsg struct {
+	Source int
+	Hop    *hop.Hop
+}
+
+// CompareResultMsg replaces a compare source's hops with a newer partial
+// or final result, as GlobalPing progress updates deliver them
+type CompareResultMsg struct {
+	Source int
+	Result *hop.TraceResult
+}
+
+// CompareDoneMsg is sent when a compare source finishes or fails
+type CompareDoneMsg struct {
+	Source int
+	Err    error
+}
+
+// compareSource is the live state of one column of the compare TUI.
+type compareSource struct {
+	result *hop.TraceResult
+	done   bool
+	err    error
+}
+
+// CompareTUIModel is the Bubbletea model for the live compare TUI. It shows
+// every source side by side as hops arrive and marks the hops where the
+// sources disagree.
+type CompareTUIModel struct {
+	mu        sync.RWMutex
+	target    string
+	sources   []*compareSource
+	renderer  *CompareRenderer
+	spinner   spinner.Model
+	width     int
+	startTime time.Time
+}
+
+// NewCompareTUIModel creates a compare TUI model with one column per name.
+// Sources reported beyond the named ones get a column when they first appear.
+func NewCompareTUIModel(target string, names []string, noColor bool) *CompareTUIModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = spinnerStyle()
+
+	m := &CompareTUIModel{
+		target:    target,
+		renderer:  &CompareRenderer{writer: io.Discard, noColor: noColor, termWidth: 80},
+		spinner:   s,
+		startTime: time.Now(),
+	}
+	for i, name := range names {
+		m.source(i).result.Source = name
+	}
+	return m
+}
+
+// source returns source i, adding columns up to it. Must be called with lock held.
+func (m *CompareTUIModel) source(i int) *compareSource {
+	for len(m.sources) <= i {
+		m.sources = append(m.sources, &compareSource{result: hop.NewTraceResult(m.target, "")})
+	}
+	return m.sources[i]
+}
+
+// AddHop adds or replaces a hop of source i
+func (m *CompareTUIModel) AddHop(i int, h *hop.Hop) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := m.source(i).result
+	for j, existing := range result.Hops {
+		if existing.TTL == h.TTL {
+			result.Hops[j] = h
+			return
+		}
+	}
+	result.AddHop(h)
+}
+
+// SetResult replaces the hops of source i, keeping its name when the new
+// result has none
+func (m *CompareTUIModel) SetResult(i int, tr *hop.TraceResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	src := m.source(i)
+	if tr.Source == "" {
+		tr.Source = src.result.Source
+	}
+	src.result = tr
+}
+
+// SetDone marks source i as finished, or failed when err is non-nil
+func (m *CompareTUIModel) SetDone(i int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	src := m.source(i)
+	src.done = true
+	src.err = err
+}
+
+// Results returns the current result of every source
+func (m *CompareTUIModel) Results() []*hop.TraceResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make([]*hop.TraceResult, len(m.sources))
+	for i, src := range m.sources {
+		results[i] = src.result
+	}
+	return results
+}
+
+// allDone reports whether every source finished. Must be called with lock held.
+func (m *CompareTUIModel) allDone() bool {
+	for _, src := range m.sources {
+		if !src.done {
+			return false
+		}
+	}
+	return len(m.sources) > 0
+}
+
+// Init implements tea.Model
+func (m *CompareTUIModel) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+// Update implements tea.Model
+func (m *CompareTUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.mu.Lock()
+		m.width = msg.Width
+		m.renderer.termWidth = msg.Width
+		m.mu.Unlock()
+
+	case CompareHopMsg:
+		m.AddHop(msg.Source, msg.Hop)
+
+	case CompareResultMsg:
+		m.SetResult(msg.Source, msg.Result)
+
+	case CompareDoneMsg:
+		m.SetDone(msg.Source, msg.Err)
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+// View implements tea.Model
+func (m *CompareTUIModel) View() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var b strings.Builder
+
+	title := fmt.Sprintf("gtr %s %s (compare)", glyphs.Arrow, m.target)
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	results := make([]*hop.TraceResult, len(m.sources))
+	for i, src := range m.sources {
+		results[i] = src.result
+	}
+	numCols := max(len(results), 1)
+	colWidth := calcColumnWidth(m.renderer.termWidth, numCols)
+	common := computeCommonHops(results)
+	diverged := divergentTTLs(results)
+
+	// Header row, the TTL column leaves room for the divergence marker
+	headerParts := make([]string, len(results))
+	for i, tr := range results {
+		name := tr.Source
+		if name == "" {
+			name = fmt.Sprintf("Source %d", i+1)
+		}
+		if len(name) > colWidth {
+			name = name[:colWidth-3] + "..."
+		}
+		headerParts[i] = m.renderer.colorize(fmt.Sprintf("%-*s", colWidth, name), i)
+	}
+	b.WriteString(fmt.Sprintf("Hop %s %s\n", glyphs.VLine, strings.Join(headerParts, " "+glyphs.VLine+" ")))
+
+	sepParts := make([]string, len(results))
+	for i := range sepParts {
+		sepParts[i] = strings.Repeat(glyphs.HLine, colWidth)
+	}
+	b.WriteString(ruleRow(sepParts))
+	b.WriteString("\n")
+
+	maxTTL := 0
+	for _, tr := range results {
+		for _, h := range tr.Hops {
+			maxTTL = max(maxTTL, h.TTL)
+		}
+	}
+
+	for ttl := 1; ttl <= maxTTL; ttl++ {
+		var maxRTT time.Duration
+		for _, tr := range results {
+			if h := tr.GetHop(ttl); h != nil {
+				maxRTT = max(maxRTT, h.AvgRTT())
+			}
+		}
+
+		cols := make([]string, len(results))
+		for i, tr := range results {
+			cell := m.renderer.formatHopCell(tr.GetHop(ttl), colWidth, maxRTT, common, ttl)
+			cols[i] = m.renderer.colorize(cell, i)
+		}
+
+		label := fmt.Sprintf("%3d", ttl)
+		if diverged[ttl] {
+			label = timeoutStyle.Render(fmt.Sprintf("%2d!", ttl))
+		}
+		b.WriteString(fmt.Sprintf("%s %s %s\n", label, glyphs.VLine, strings.Join(cols, " "+glyphs.VLine+" ")))
+	}
+
+	// Status bar
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat(glyphs.HLine, 70))
+	b.WriteString("\n")
+	b.WriteString(m.renderStatusBar(len(diverged)))
+
+	// Help
+	b.WriteString("\n")
+	if m.allDone() {
+		b.WriteString(completeStyle.Render(glyphs.Check + " All traces complete"))
+		b.WriteString(" | Press 'q' to quit")
+	} else {
+		b.WriteString(m.spinner.View())
+		b.WriteString(" Tracing... Press 'q' to cancel")
+	}
+
+	return b.String()
+}
+
+// renderStatusBar renders the state of every source, the number of
+// diverging hops and the elapsed time. Must be called with lock held.
+func (m *CompareTUIModel) renderStatusBar(diverged int) string {
+	var parts []string
+	for i, src := range m.sources {
+		name := src.result.Source
+		if name == "" {
+			name = fmt.Sprintf("Source %d", i+1)
+		}
+		switch {
+		case src.err != nil:
+			parts = append(parts, timeoutStyle.Render(fmt.Sprintf("%s %s failed", name, glyphs.Fail)))
+		case src.done && src.result.ReachedTarget:
+			parts = append(parts, fmt.Sprintf("%s %s %d hops", name, glyphs.Check, src.result.TotalHops()))
+		case src.done:
+			parts = append(parts, fmt.Sprintf("%s %s %d hops", name, glyphs.Fail, src.result.TotalHops()))
+		default:
+			parts = append(parts, fmt.Sprintf("%s %d hops...", name, src.result.TotalHops()))
+		}
+	}
+
+	if diverged > 0 {
+		parts = append(parts, timeoutStyle.Render(fmt.Sprintf("Diverging: %d", diverged)))
+	}
+
+	elapsed := time.Since(m.startTime).Round(time.Millisecond)
+	parts = append(parts, fmt.Sprintf("Time: %v", elapsed))
+
+	return statusStyle.Render(strings.Join(parts, " "+glyphs.VLine+" "))
+}
+
+// divergentTTLs returns the TTLs at which the sources that answered disagree
+// on the network the hop is in: its ASN, or its address when either side's
+// ASN is unknown. Timeouts and missing hops never count as divergence.
+func divergentTTLs(sources []*hop.TraceResult) map[int]bool {
+	diverged := make(map[int]bool)
+	type answer struct {
+		ip  string
+		asn uint32
+	}
+	seen := make(map[int][]answer)
+	for _, src := range sources {
+		for _, h := range src.Hops {
+			ip := h.PrimaryIP()
+			if ip == nil {
+				continue
+			}
+			a := answer{ip: ip.String(), asn: h.Enrichment.ASN}
+			for _, other := range seen[h.TTL] {
+				same := a.ip == other.ip || (a.asn != 0 && a.asn == other.asn)
+				if !same {
+					diverged[h.TTL] = true
+				}
+			}
+			seen[h.TTL] = append(seen[h.TTL], a)
+		}
+	}
+	return diverged
+}
+
+// RunCompareTUI runs the live compare TUI until the user quits. Hop, result
+// and done messages for each source are read from msgChan, which the caller
+// closes once every source finished. It returns the last result of every
+// source.
+func RunCompareTUI(target string, names []string, noColor bool, msgChan <-chan tea.Msg) ([]*hop.TraceResult, error) {
+	model := NewCompareTUIModel(target, names, noColor)
+
+	p := tea.NewProgram(model)
+
+	go func() {
+		for msg := range msgChan {
+			p.Send(msg)
+		}
+	}()
+
+	if _, err := p.Run(); err != nil {
+		return nil, err
+	}
+	return model.Results(), nil
+}
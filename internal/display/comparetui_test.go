@@ -0,0 +1,112 @@
+package display
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func compareTestHop(ttl int, ip string, asn uint32) *hop.Hop {
+	h := hop.NewHop(ttl)
+	h.AddProbe(net.ParseIP(ip), 5*time.Millisecond)
+	h.Enrichment.ASN = asn
+	return h
+}
+
+func TestCompareTUIModel_AddHop_GrowsAndReplaces(t *testing.T) {
+	model := NewCompareTUIModel("example.com", []string{"Local"}, true)
+
+	model.AddHop(0, compareTestHop(1, "10.0.0.1", 0))
+	model.AddHop(0, compareTestHop(1, "10.0.0.2", 0))
+	model.AddHop(2, compareTestHop(1, "10.1.0.1", 0))
+
+	results := model.Results()
+	if len(results) != 3 {
+		t.Fatalf("expected 3 sources, got %d", len(results))
+	}
+	if len(results[0].Hops) != 1 || results[0].Hops[0].PrimaryIP().String() != "10.0.0.2" {
+		t.Errorf("expected TTL 1 replaced by the later hop, got %+v", results[0].Hops)
+	}
+	if results[0].Source != "Local" {
+		t.Errorf("expected source name Local, got %q", results[0].Source)
+	}
+}
+
+func TestCompareTUIModel_SetResult_KeepsName(t *testing.T) {
+	model := NewCompareTUIModel("example.com", []string{"Local", "London"}, true)
+
+	tr := hop.NewTraceResult("example.com", "93.184.216.34")
+	tr.AddHop(compareTestHop(1, "10.0.0.1", 0))
+	model.SetResult(1, tr)
+
+	named := hop.NewTraceResult("example.com", "93.184.216.34")
+	named.Source = "London, GB, ASN 1234"
+	model.SetResult(1, named)
+
+	results := model.Results()
+	if results[1] != named || results[1].Source != "London, GB, ASN 1234" {
+		t.Errorf("expected result with probe location, got %q", results[1].Source)
+	}
+	if tr.Source != "London" {
+		t.Errorf("expected unnamed result to keep column name, got %q", tr.Source)
+	}
+}
+
+func TestCompareTUIModel_View_ShowsSourcesAndDivergence(t *testing.T) {
+	model := NewCompareTUIModel("example.com", []string{"Local", "London"}, true)
+	model.AddHop(0, compareTestHop(1, "10.0.0.1", 0))
+	model.AddHop(1, compareTestHop(1, "10.9.0.1", 0))
+	model.AddHop(0, compareTestHop(2, "192.0.2.1", 64500))
+	model.AddHop(1, compareTestHop(2, "192.0.2.1", 64500))
+
+	view := model.View()
+
+	for _, want := range []string{"Local", "London", " 1!", "Diverging: 1", "Tracing..."} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected view to contain %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, " 2!") {
+		t.Errorf("expected shared hop 2 not marked as diverging:\n%s", view)
+	}
+}
+
+func TestCompareTUIModel_View_StatusAfterDone(t *testing.T) {
+	model := NewCompareTUIModel("example.com", []string{"Local", "London"}, true)
+	model.SetDone(0, nil)
+	model.SetDone(1, errors.New("no probes"))
+
+	view := model.View()
+
+	if !strings.Contains(view, "London "+glyphs.Fail+" failed") {
+		t.Errorf("expected failed source in status bar:\n%s", view)
+	}
+	if !strings.Contains(view, "All traces complete") {
+		t.Errorf("expected completion once every source is done:\n%s", view)
+	}
+}
+
+func TestDivergentTTLs(t *testing.T) {
+	a := hop.NewTraceResult("t", "")
+	b := hop.NewTraceResult("t", "")
+	a.AddHop(compareTestHop(1, "10.0.0.1", 0))
+	b.AddHop(compareTestHop(1, "10.0.0.1", 0)) // Same address
+	a.AddHop(compareTestHop(2, "192.0.2.1", 64500))
+	b.AddHop(compareTestHop(2, "192.0.2.9", 64500)) // Same ASN
+	a.AddHop(compareTestHop(3, "192.0.2.2", 64500))
+	b.AddHop(compareTestHop(3, "198.51.100.1", 64501)) // Different ASN
+	a.AddHop(compareTestHop(4, "192.0.2.3", 64500))
+	timeout := hop.NewHop(4)
+	timeout.AddTimeout()
+	b.AddHop(timeout) // Timeouts never diverge
+
+	got := divergentTTLs([]*hop.TraceResult{a, b})
+
+	if len(got) != 1 || !got[3] {
+		t.Errorf("expected only TTL 3 diverging, got %v", got)
+	}
+}
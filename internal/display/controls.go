@@ -0,0 +1,68 @@
+package display
+
+import (
+	"time"
+)
+
+// MTRControls connects the MTR keys that act on probing rather than on the
+// display to the tracer. A nil function disables its key.
+type MTRControls struct {
+	// Pause stops ('p') or resumes probing.
+	Pause func(paused bool)
+
+	// Retarget switches tracing to a target typed at the 't' prompt and
+	// returns its address. It is called from the goroutine feeding the TUI
+	// and must drop the probes and cycles of the old target still queued in
+	// the result and cycle channels before probing the new one.
+	Retarget func(target string) (targetIP string, err error)
+
+	// SetInterval changes the time between cycles ('+' and '-'), starting
+	// from Interval.
+	SetInterval func(d time.Duration)
+	Interval    time.Duration
+
+	// SetProbeSize changes the size of probes ('z'), starting from
+	// ProbeSize bytes.
+	SetProbeSize func(size int)
+	ProbeSize    int
+}
+
+// intervalSteps are the intervals '+' and '-' step through.
+var intervalSteps = []time.Duration{
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// probeSizeSteps are the probe sizes in bytes 'z' cycles through, from a
+// minimal probe up to a full Ethernet frame.
+var probeSizeSteps = []int{64, 128, 256, 512, 1024, 1400, 1500}
+
+// stepInterval returns the step after d, the next longer one when longer is
+// set, or d when it is already the longest or shortest step.
+func stepInterval(d time.Duration, longer bool) time.Duration {
+	if longer {
+		for _, s := range intervalSteps {
+			if s > d {
+				return s
+			}
+		}
+		return d
+	}
+	for i := len(intervalSteps) - 1; i >= 0; i-- {
+		if intervalSteps[i] < d {
+			return intervalSteps[i]
+		}
+	}
+	return d
+}
+
+// nextProbeSize returns the probe size after size, wrapping around to the
+// smallest one after the largest.
+func nextProbeSize(size int) int {
+	for _, s := range probeSizeSteps {
+		if s > size {
+			return s
+		}
+	}
+	return probeSizeSteps[0]
+}
@@ -0,0 +1,43 @@
+package display
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStepInterval(t *testing.T) {
+	tests := []struct {
+		d      time.Duration
+		longer bool
+		want   time.Duration
+	}{
+		{time.Second, true, 2 * time.Second},
+		{time.Second, false, 500 * time.Millisecond},
+		{1500 * time.Millisecond, true, 2 * time.Second},
+		{1500 * time.Millisecond, false, time.Second},
+		{time.Minute, true, time.Minute},
+		{100 * time.Millisecond, false, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := stepInterval(tt.d, tt.longer); got != tt.want {
+			t.Errorf("stepInterval(%v, %v) = %v, want %v", tt.d, tt.longer, got, tt.want)
+		}
+	}
+}
+
+func TestNextProbeSize(t *testing.T) {
+	tests := []struct {
+		size, want int
+	}{
+		{64, 128},
+		{100, 128},
+		{1400, 1500},
+		{1500, 64},
+		{9000, 64},
+	}
+	for _, tt := range tests {
+		if got := nextProbeSize(tt.size); got != tt.want {
+			t.Errorf("nextProbeSize(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
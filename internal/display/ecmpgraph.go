@@ -0,0 +1,243 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// ecmpNode is an address that answered at one TTL.
+type ecmpNode struct {
+	ttl int
+	ip  string
+}
+
+// ecmpEdge links a node to a node further along the path, with the flows
+// seen taking it (nil when the probes carry no flow IDs).
+type ecmpEdge struct {
+	to    ecmpNode
+	flows []int
+}
+
+// ecmpGraph is the DAG of paths discovered by a trace: one node per
+// responding address and TTL, one edge per observed next hop.
+type ecmpGraph struct {
+	ttls    []int            // TTLs in path order
+	nodes   map[int][]string // Addresses per TTL, in order first seen
+	edges   map[ecmpNode][]ecmpEdge
+	in      map[ecmpNode]int // Number of distinct predecessors
+	flows   int              // Distinct flow IDs seen
+	perFlow bool             // Edges come from per-flow data
+}
+
+// buildECMPGraph builds the path graph from the hops of a trace. With flow
+// IDs (--ecmp-flows), each flow links the addresses it reached at
+// consecutive responding TTLs. Without them, which branch leads where is
+// unknown and every address is linked to every address of the next TTL.
+func buildECMPGraph(hops []*hop.Hop) *ecmpGraph {
+	g := &ecmpGraph{
+		nodes: make(map[int][]string),
+		edges: make(map[ecmpNode][]ecmpEdge),
+		in:    make(map[ecmpNode]int),
+	}
+
+	sorted := make([]*hop.Hop, len(hops))
+	copy(sorted, hops)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].TTL < sorted[j].TTL })
+
+	// flowHits[flow][ttl] lists the addresses a flow reached at a TTL
+	flowHits := make(map[int]map[int][]string)
+	for _, h := range sorted {
+		if len(g.ttls) == 0 || g.ttls[len(g.ttls)-1] != h.TTL {
+			g.ttls = append(g.ttls, h.TTL)
+		}
+		for _, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				continue
+			}
+			ip := p.IP.String()
+			g.nodes[h.TTL] = appendUnique(g.nodes[h.TTL], ip)
+			if p.FlowID > 0 {
+				if flowHits[p.FlowID] == nil {
+					flowHits[p.FlowID] = make(map[int][]string)
+				}
+				flowHits[p.FlowID][h.TTL] = appendUnique(flowHits[p.FlowID][h.TTL], ip)
+			}
+		}
+	}
+
+	g.flows = len(flowHits)
+	g.perFlow = g.flows > 0
+	if g.perFlow {
+		flows := make([]int, 0, len(flowHits))
+		for flow := range flowHits {
+			flows = append(flows, flow)
+		}
+		sort.Ints(flows)
+		for _, flow := range flows {
+			var prev []string
+			prevTTL := 0
+			for _, ttl := range g.ttls {
+				ips := flowHits[flow][ttl]
+				if len(ips) == 0 {
+					continue
+				}
+				for _, from := range prev {
+					for _, to := range ips {
+						g.addEdge(ecmpNode{prevTTL, from}, ecmpNode{ttl, to}, flow)
+					}
+				}
+				prev, prevTTL = ips, ttl
+			}
+		}
+	} else {
+		prevTTL := 0
+		for _, ttl := range g.ttls {
+			if len(g.nodes[ttl]) == 0 {
+				continue
+			}
+			for _, from := range g.nodes[prevTTL] {
+				for _, to := range g.nodes[ttl] {
+					g.addEdge(ecmpNode{prevTTL, from}, ecmpNode{ttl, to}, 0)
+				}
+			}
+			prevTTL = ttl
+		}
+	}
+
+	return g
+}
+
+// addEdge records that flow (0 = unknown) went from one node to another.
+func (g *ecmpGraph) addEdge(from, to ecmpNode, flow int) {
+	edges := g.edges[from]
+	for i := range edges {
+		if edges[i].to == to {
+			if flow > 0 {
+				edges[i].flows = append(edges[i].flows, flow)
+			}
+			return
+		}
+	}
+	e := ecmpEdge{to: to}
+	if flow > 0 {
+		e.flows = []int{flow}
+	}
+	g.edges[from] = append(edges, e)
+	g.in[to]++
+}
+
+// branches returns the number of nodes with more than one next hop.
+func (g *ecmpGraph) branches() int {
+	n := 0
+	for _, edges := range g.edges {
+		if len(edges) > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// merges returns the number of nodes reached from more than one node.
+func (g *ecmpGraph) merges() int {
+	n := 0
+	for _, count := range g.in {
+		if count > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// render draws the graph one TTL at a time: each address, then its next
+// hops as a tree, with branch and merge points annotated.
+func (g *ecmpGraph) render() []string {
+	summary := fmt.Sprintf("%d branch points, %d merge points", g.branches(), g.merges())
+	if g.perFlow {
+		summary = fmt.Sprintf("%d flows, %s", g.flows, summary)
+	} else {
+		summary += " (no per-flow data: links are inferred, use --ecmp-flows)"
+	}
+	lines := []string{headerStyle.Render(summary)}
+
+	for _, ttl := range g.ttls {
+		ips := g.nodes[ttl]
+		if len(ips) == 0 {
+			lines = append(lines, hopStyle.Render(fmt.Sprintf("%3d", ttl))+"  "+timeoutStyle.Render("*"))
+			continue
+		}
+		for i, ip := range ips {
+			node := ecmpNode{ttl, ip}
+			prefix := "     "
+			if i == 0 {
+				prefix = hopStyle.Render(fmt.Sprintf("%3d", ttl)) + "  "
+			}
+			line := prefix + ipStyle.Render(fmt.Sprintf("%-39s", ip))
+			var notes []string
+			if n := len(g.edges[node]); n > 1 {
+				notes = append(notes, fmt.Sprintf("branches %d ways", n))
+			}
+			if n := g.in[node]; n > 1 {
+				notes = append(notes, fmt.Sprintf("merges %d paths", n))
+			}
+			if len(notes) > 0 {
+				line += " " + asnStyle.Render(strings.Join(notes, ", "))
+			}
+			lines = append(lines, strings.TrimRight(line, " "))
+
+			edges := g.edges[node]
+			for j, e := range edges {
+				branch := glyphs.Branch
+				if j == len(edges)-1 {
+					branch = glyphs.LastBranch
+				}
+				edge := "     " + branch + glyphs.HLine + glyphs.Arrow + " " + fmt.Sprintf("%-36s", e.to.ip)
+				var notes []string
+				if e.to.ttl != ttl+1 {
+					notes = append(notes, fmt.Sprintf("at TTL %d", e.to.ttl))
+				}
+				if len(e.flows) > 0 {
+					notes = append(notes, "flows "+formatFlows(e.flows))
+				}
+				if len(notes) > 0 {
+					edge += " " + hostnameStyle.Render(strings.Join(notes, ", "))
+				}
+				lines = append(lines, strings.TrimRight(edge, " "))
+			}
+		}
+	}
+	return lines
+}
+
+// formatFlows lists flow IDs, collapsing runs: "1-3,5".
+func formatFlows(flows []int) string {
+	sorted := append([]int(nil), flows...)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, strconv.Itoa(sorted[i])+"-"+strconv.Itoa(sorted[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// appendUnique appends s to list unless it is already there.
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,117 @@
+package display
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// flowHop builds a hop whose probes reached ips[i] with flow ID i+1
+// ("" = timeout).
+func flowHop(ttl int, ips ...string) *hop.Hop {
+	h := hop.NewHop(ttl)
+	for i, ip := range ips {
+		if ip == "" {
+			h.Probes = append(h.Probes, hop.Probe{Timeout: true, FlowID: i + 1})
+			continue
+		}
+		h.Probes = append(h.Probes, hop.Probe{IP: net.ParseIP(ip), RTT: time.Millisecond, FlowID: i + 1})
+	}
+	return h
+}
+
+func TestBuildECMPGraph_BranchAndMerge(t *testing.T) {
+	g := buildECMPGraph([]*hop.Hop{
+		flowHop(1, "10.0.0.1", "10.0.0.1", "10.0.0.1", "10.0.0.1"),
+		flowHop(2, "10.1.0.1", "10.1.0.2", "10.1.0.1", "10.1.0.2"),
+		flowHop(3, "10.2.0.1", "10.2.0.1", "10.2.0.1", "10.2.0.1"),
+	})
+
+	if !g.perFlow || g.flows != 4 {
+		t.Fatalf("expected per-flow graph with 4 flows, got perFlow=%v flows=%d", g.perFlow, g.flows)
+	}
+	edges := g.edges[ecmpNode{1, "10.0.0.1"}]
+	if len(edges) != 2 {
+		t.Fatalf("expected 2 branches at TTL 1, got %d", len(edges))
+	}
+	if got := formatFlows(edges[0].flows); got != "1,3" {
+		t.Errorf("expected flows 1,3 on first branch, got %s", got)
+	}
+	if g.in[ecmpNode{3, "10.2.0.1"}] != 2 {
+		t.Errorf("expected merge of 2 paths at TTL 3, got %d", g.in[ecmpNode{3, "10.2.0.1"}])
+	}
+	if g.branches() != 1 || g.merges() != 1 {
+		t.Errorf("expected 1 branch and 1 merge, got %d and %d", g.branches(), g.merges())
+	}
+}
+
+func TestBuildECMPGraph_FlowSkipsSilentTTL(t *testing.T) {
+	g := buildECMPGraph([]*hop.Hop{
+		flowHop(1, "10.0.0.1", "10.0.0.1"),
+		flowHop(2, "", "10.1.0.2"),
+		flowHop(3, "10.2.0.1", "10.2.0.1"),
+	})
+
+	var skip *ecmpEdge
+	for _, e := range g.edges[ecmpNode{1, "10.0.0.1"}] {
+		if e.to.ttl == 3 {
+			skip = &e
+		}
+	}
+	if skip == nil || formatFlows(skip.flows) != "1" {
+		t.Fatalf("expected flow 1 to link TTL 1 to TTL 3, got %+v", g.edges[ecmpNode{1, "10.0.0.1"}])
+	}
+}
+
+func TestBuildECMPGraph_WithoutFlowIDs(t *testing.T) {
+	h1 := hop.NewHop(1)
+	h1.AddProbe(net.ParseIP("10.0.0.1"), time.Millisecond)
+	h2 := hop.NewHop(2)
+	h2.AddProbe(net.ParseIP("10.1.0.1"), time.Millisecond)
+	h2.AddProbe(net.ParseIP("10.1.0.2"), time.Millisecond)
+
+	g := buildECMPGraph([]*hop.Hop{h1, h2})
+
+	if g.perFlow {
+		t.Error("expected inferred graph without flow IDs")
+	}
+	if len(g.edges[ecmpNode{1, "10.0.0.1"}]) != 2 {
+		t.Errorf("expected links to both addresses, got %+v", g.edges)
+	}
+	if out := strings.Join(g.render(), "\n"); !strings.Contains(out, "--ecmp-flows") {
+		t.Errorf("expected hint about --ecmp-flows, got:\n%s", out)
+	}
+}
+
+func TestECMPGraph_Render(t *testing.T) {
+	g := buildECMPGraph([]*hop.Hop{
+		flowHop(1, "10.0.0.1", "10.0.0.1"),
+		flowHop(2, "10.1.0.1", "10.1.0.2"),
+		flowHop(3, "", ""),
+		flowHop(4, "10.2.0.1", "10.2.0.1"),
+	})
+	out := strings.Join(g.render(), "\n")
+
+	for _, want := range []string{
+		"2 flows, 1 branch points, 1 merge points",
+		"branches 2 ways",
+		glyphs.Branch + glyphs.HLine + glyphs.Arrow + " 10.1.0.1",
+		glyphs.LastBranch + glyphs.HLine + glyphs.Arrow + " 10.1.0.2",
+		"at TTL 4",
+		"  3  *",
+		"merges 2 paths",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in graph:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatFlows(t *testing.T) {
+	if got := formatFlows([]int{5, 1, 2, 3, 8, 9}); got != "1-3,5,8-9" {
+		t.Errorf("expected 1-3,5,8-9, got %s", got)
+	}
+}
@@ -0,0 +1,227 @@
+package display
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/export"
+)
+
+// exportFormats are the formats the export overlay offers, in the order tab
+// cycles through them.
+var exportFormats = []export.Format{export.FormatJSON, export.FormatCSV, export.FormatText}
+
+// exportExtensions maps each export format to its file extension.
+var exportExtensions = map[export.Format]string{
+	export.FormatJSON: ".json",
+	export.FormatCSV:  ".csv",
+	export.FormatText: ".txt",
+}
+
+// exportPrompt is the overlay that asks for the path and format of an
+// export of the current TUI state.
+type exportPrompt struct {
+	active bool
+	path   string
+	format int // Index into exportFormats
+}
+
+// open shows the overlay with a timestamped default path for target.
+func (p *exportPrompt) open(kind, target string, t time.Time) {
+	p.active = true
+	p.format = 0
+	p.path = fmt.Sprintf("gtrace-%s-%s-%s%s", kind, fileSafe(target), t.Format("20060102-150405"),
+		exportExtensions[exportFormats[0]])
+}
+
+// selected returns the selected export format.
+func (p *exportPrompt) selected() export.Format {
+	return exportFormats[p.format]
+}
+
+// handleKey edits the path or the format. It returns true when enter
+// confirms the export; esc closes the overlay.
+func (p *exportPrompt) handleKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyEnter:
+		p.active = false
+		return p.path != ""
+	case tea.KeyEsc, tea.KeyCtrlC:
+		p.active = false
+	case tea.KeyTab:
+		p.cycleFormat(1)
+	case tea.KeyShiftTab:
+		p.cycleFormat(-1)
+	default:
+		p.path = editLine(p.path, msg)
+	}
+	return false
+}
+
+// editLine applies msg, a key typed into a one-line input, to s: text is
+// appended, backspace deletes the last character and Ctrl+U clears it.
+func editLine(s string, msg tea.KeyMsg) string {
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if r := []rune(s); len(r) > 0 {
+			return string(r[:len(r)-1])
+		}
+	case tea.KeyCtrlU:
+		return ""
+	case tea.KeyRunes, tea.KeySpace:
+		return s + string(msg.Runes)
+	}
+	return s
+}
+
+// cycleFormat selects the next (delta 1) or previous (delta -1) format and
+// swaps the extension of the path when it is the old format's.
+func (p *exportPrompt) cycleFormat(delta int) {
+	old := exportExtensions[p.selected()]
+	p.format = (p.format + delta + len(exportFormats)) % len(exportFormats)
+	if strings.HasSuffix(p.path, old) {
+		p.path = strings.TrimSuffix(p.path, old) + exportExtensions[p.selected()]
+	}
+}
+
+// view renders the overlay line.
+func (p *exportPrompt) view() string {
+	formats := make([]string, len(exportFormats))
+	for i, f := range exportFormats {
+		formats[i] = string(f)
+		if i == p.format {
+			formats[i] = headerStyle.Render("[" + string(f) + "]")
+		}
+	}
+	return fmt.Sprintf("Export to: %s_  Format: %s  (tab format, enter save, esc cancel)",
+		p.path, strings.Join(formats, " "))
+}
+
+// writeExportFile creates path and writes an export into it with write.
+func writeExportFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	return f.Close()
+}
+
+// exportNotice returns the status bar message for an export to path.
+func exportNotice(path string, format export.Format, err error) string {
+	if err != nil {
+		return "Export failed: " + err.Error()
+	}
+	return fmt.Sprintf("Exported %s to %s", format, path)
+}
+
+// fileSafe replaces the characters of s that are unsafe in file names.
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// mtrExport is the JSON export of the MTR statistics table.
+type mtrExport struct {
+	Target   string         `json:"target"`
+	TargetIP string         `json:"targetIP"`
+	Cycles   int            `json:"cycles"`
+	Time     time.Time      `json:"time"`
+	Hops     []mtrExportHop `json:"hops"`
+}
+
+// mtrExportHop is one row of the MTR statistics table. RTTs are in
+// milliseconds; Best, Avg and StdDev leave out RTT outliers.
+type mtrExportHop struct {
+	TTL      int     `json:"ttl"`
+	IP       string  `json:"ip,omitempty"`
+	Hostname string  `json:"hostname,omitempty"`
+	ASN      uint32  `json:"asn,omitempty"`
+	Loss     float64 `json:"loss"`
+	Sent     int     `json:"sent"`
+	Recv     int     `json:"recv"`
+	Best     float64 `json:"best"`
+	Avg      float64 `json:"avg"`
+	Worst    float64 `json:"worst"`
+	Last     float64 `json:"last"`
+	StdDev   float64 `json:"stdDev"`
+}
+
+// exportHopsLocked returns the rows of the statistics table. Must be
+// called with lock held.
+func (m *MTRModel) exportHopsLocked() []mtrExportHop {
+	var hops []mtrExportHop
+	for _, stats := range m.getOrderedStatsLocked() {
+		h := mtrExportHop{
+			TTL:    stats.TTL,
+			Loss:   stats.LossPercent(),
+			Sent:   stats.Sent,
+			Recv:   stats.Recv,
+			Best:   msFloat(stats.FilteredBest),
+			Avg:    msFloat(stats.FilteredAvgRTT()),
+			Worst:  msFloat(stats.WorstRTT),
+			Last:   msFloat(stats.LastRTT),
+			StdDev: msFloat(stats.FilteredStdDev()),
+		}
+		if ip := stats.PrimaryIP(); ip != nil {
+			e := stats.PrimaryEnrichment()
+			h.IP, h.Hostname, h.ASN = ip.String(), e.Hostname, e.ASN
+		}
+		hops = append(hops, h)
+	}
+	return hops
+}
+
+// writeExport writes the statistics table to w in format.
+func (m *MTRModel) writeExport(w io.Writer, format export.Format) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	switch format {
+	case export.FormatText:
+		_, err := io.WriteString(w, m.plainTextLocked())
+		return err
+	case export.FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"ttl", "ip", "hostname", "asn", "loss", "sent", "recv", "best_ms", "avg_ms", "worst_ms", "last_ms", "stddev_ms"})
+		for _, h := range m.exportHopsLocked() {
+			asn := ""
+			if h.ASN > 0 {
+				asn = strconv.FormatUint(uint64(h.ASN), 10)
+			}
+			cw.Write([]string{
+				strconv.Itoa(h.TTL), h.IP, h.Hostname, asn,
+				strconv.FormatFloat(h.Loss, 'f', 1, 64), strconv.Itoa(h.Sent), strconv.Itoa(h.Recv),
+				strconv.FormatFloat(h.Best, 'f', 3, 64), strconv.FormatFloat(h.Avg, 'f', 3, 64),
+				strconv.FormatFloat(h.Worst, 'f', 3, 64), strconv.FormatFloat(h.Last, 'f', 3, 64),
+				strconv.FormatFloat(h.StdDev, 'f', 3, 64),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(mtrExport{
+			Target:   m.target,
+			TargetIP: m.targetIP,
+			Cycles:   m.cycles,
+			Time:     time.Now(),
+			Hops:     m.exportHopsLocked(),
+		})
+	}
+}
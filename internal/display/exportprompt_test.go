@@ -0,0 +1,46 @@
+package display
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/export"
+)
+
+func TestExportPrompt_TabCyclesFormatAndExtension(t *testing.T) {
+	var p exportPrompt
+	p.open("trace", "2001:db8::1", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	if p.path != "gtrace-trace-2001_db8__1-20260102-030405.json" {
+		t.Errorf("unexpected default path %q", p.path)
+	}
+
+	p.handleKey(tea.KeyMsg{Type: tea.KeyTab})
+	if p.selected() != export.FormatCSV || !strings.HasSuffix(p.path, ".csv") {
+		t.Errorf("expected csv, got %s and %q", p.selected(), p.path)
+	}
+	p.handleKey(tea.KeyMsg{Type: tea.KeyShiftTab})
+	p.handleKey(tea.KeyMsg{Type: tea.KeyShiftTab})
+	if p.selected() != export.FormatText || !strings.HasSuffix(p.path, ".txt") {
+		t.Errorf("expected text, got %s and %q", p.selected(), p.path)
+	}
+
+	// A path typed with its own extension keeps it
+	p.handleKey(tea.KeyMsg{Type: tea.KeyCtrlU})
+	p.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("out.log")})
+	p.handleKey(tea.KeyMsg{Type: tea.KeyTab})
+	if p.path != "out.log" {
+		t.Errorf("expected the typed path to be kept, got %q", p.path)
+	}
+}
+
+func TestExportPrompt_EscCancels(t *testing.T) {
+	var p exportPrompt
+	p.open("mtr", "example.com", time.Now())
+
+	if p.handleKey(tea.KeyMsg{Type: tea.KeyEsc}) || p.active {
+		t.Error("expected esc to close the overlay without exporting")
+	}
+}
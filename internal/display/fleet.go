@@ -0,0 +1,192 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
+)
+
+// fleetRefresh is how often the fleet dashboard re-reads the fleet status.
+const fleetRefresh = time.Second
+
+// fleetTickMsg triggers a dashboard refresh.
+type fleetTickMsg struct{}
+
+// FleetModel is a Bubbletea model that renders a fleet monitoring dashboard.
+type FleetModel struct {
+	snapshot func() []monitor.TargetStatus
+	interval time.Duration
+	statuses []monitor.TargetStatus
+	start    time.Time
+}
+
+// NewFleetModel creates a dashboard that polls snapshot for target status.
+func NewFleetModel(snapshot func() []monitor.TargetStatus, interval time.Duration) *FleetModel {
+	return &FleetModel{
+		snapshot: snapshot,
+		interval: interval,
+		statuses: snapshot(),
+		start:    time.Now(),
+	}
+}
+
+// Init implements tea.Model.
+func (m *FleetModel) Init() tea.Cmd {
+	return fleetTick()
+}
+
+func fleetTick() tea.Cmd {
+	return tea.Tick(fleetRefresh, func(time.Time) tea.Msg { return fleetTickMsg{} })
+}
+
+// Update implements tea.Model.
+func (m *FleetModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		}
+	case fleetTickMsg:
+		m.statuses = m.snapshot()
+		return m, fleetTick()
+	}
+	return m, nil
+}
+
+// View implements tea.Model.
+func (m *FleetModel) View() string {
+	var b strings.Builder
+
+	title := fmt.Sprintf("gtr fleet %s %d targets, every %v", glyphs.Arrow, len(m.statuses), m.interval)
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	b.WriteString(renderFleetTable(m.statuses, true))
+
+	b.WriteString("\n")
+	b.WriteString(statusStyle.Render(fmt.Sprintf("Running: %v %s %s", time.Since(m.start).Round(time.Second), glyphs.VLine, fleetCounts(m.statuses))))
+	b.WriteString("\n")
+	b.WriteString("Press 'q' to quit")
+
+	return b.String()
+}
+
+// RenderFleet writes the fleet status table without styling, for --simple output.
+func RenderFleet(w io.Writer, statuses []monitor.TargetStatus) {
+	fmt.Fprintf(w, "[%s] %s\n", time.Now().Format("15:04:05"), fleetCounts(statuses))
+	fmt.Fprint(w, renderFleetTable(statuses, false))
+}
+
+// renderFleetTable renders one row per target followed by its recent alerts.
+func renderFleetTable(statuses []monitor.TargetStatus, styled bool) string {
+	var b strings.Builder
+
+	const (
+		colTarget = 28
+		colIP     = 16
+		colState  = 8
+		colHops   = 4
+		colWorst  = 16
+		colCycles = 6
+	)
+
+	header := fmt.Sprintf("%-*s %-*s %-*s %*s %-*s %*s %s",
+		colTarget, "Target", colIP, "IP", colState, "State", colHops, "Hops",
+		colWorst, "Worst hop", colCycles, "Runs", "Last")
+	if styled {
+		header = headerStyle.Render(header)
+	}
+	b.WriteString(header)
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat(glyphs.HLine, colTarget+colIP+colState+colHops+colWorst+colCycles+14))
+	b.WriteString("\n")
+
+	for _, s := range statuses {
+		target := s.Target
+		if len(target) > colTarget {
+			target = target[:colTarget-3] + "..."
+		}
+
+		ip := s.TargetIP
+		if ip == "" {
+			ip = "-"
+		}
+
+		state, stateStyle := "pending", hopStyle
+		switch {
+		case s.LastError != nil:
+			state, stateStyle = "error", timeoutStyle
+		case s.Cycles == 0:
+		case s.Reached:
+			state, stateStyle = "up", completeStyle
+		default:
+			state, stateStyle = "down", timeoutStyle
+		}
+		stateCell := fmt.Sprintf("%-*s", colState, state)
+		if styled {
+			stateCell = stateStyle.Render(stateCell)
+		}
+
+		worst := "-"
+		if s.WorstHop > 0 {
+			worst = fmt.Sprintf("#%d %.1fms", s.WorstHop, float64(s.WorstRTT)/float64(time.Millisecond))
+		}
+
+		last := "-"
+		if !s.LastRun.IsZero() {
+			last = s.LastRun.Format("15:04:05")
+		}
+
+		fmt.Fprintf(&b, "%-*s %-*s %s %*d %-*s %*d %s\n",
+			colTarget, target, colIP, ip, stateCell, colHops, s.Hops,
+			colWorst, worst, colCycles, s.Cycles, last)
+
+		if s.LastError != nil {
+			line := "    " + s.LastError.Error()
+			if styled {
+				line = timeoutStyle.Render(line)
+			}
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		for _, c := range s.Alerts {
+			line := fmt.Sprintf("    %s %s", c.Timestamp.Format("15:04:05"), c.String())
+			if styled {
+				line = asnStyle.Render(line)
+			}
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// fleetCounts summarizes how many targets are up, down or erroring.
+func fleetCounts(statuses []monitor.TargetStatus) string {
+	var up, down, failed int
+	for _, s := range statuses {
+		switch {
+		case s.LastError != nil:
+			failed++
+		case s.Cycles == 0:
+		case s.Reached:
+			up++
+		default:
+			down++
+		}
+	}
+	return fmt.Sprintf("Up: %d  Down: %d  Errors: %d", up, down, failed)
+}
+
+// RunFleet runs the fleet dashboard TUI until the user quits.
+func RunFleet(snapshot func() []monitor.TargetStatus, interval time.Duration) error {
+	p := tea.NewProgram(NewFleetModel(snapshot, interval))
+	_, err := p.Run()
+	return err
+}
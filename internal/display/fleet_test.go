@@ -0,0 +1,65 @@
+package display
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
+)
+
+func testFleetStatuses() []monitor.TargetStatus {
+	return []monitor.TargetStatus{
+		{Target: "up.example", TargetIP: "10.0.0.1", Cycles: 2, Reached: true, Hops: 7, WorstHop: 5, WorstRTT: 42 * time.Millisecond, LastRun: time.Now(),
+			Alerts: []monitor.Change{{Type: monitor.ChangeTypeRoute, Hop: 3, Message: "route changed", Timestamp: time.Now()}}},
+		{Target: "down.example", TargetIP: "10.0.0.2", Cycles: 1, Hops: 30, LastRun: time.Now()},
+		{Target: "broken.example", Cycles: 1, LastError: errors.New("no route to host"), LastRun: time.Now()},
+		{Target: "new.example"},
+	}
+}
+
+func TestRenderFleet_ShowsStateWorstHopAndAlerts(t *testing.T) {
+	var buf bytes.Buffer
+	RenderFleet(&buf, testFleetStatuses())
+	out := buf.String()
+
+	for _, want := range []string{"Up: 1  Down: 1  Errors: 1", "up.example", "#5 42.0ms", "route changed", "no route to host", "pending"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestFleetModel_RefreshesOnTick(t *testing.T) {
+	calls := 0
+	snapshot := func() []monitor.TargetStatus {
+		calls++
+		return testFleetStatuses()
+	}
+	m := NewFleetModel(snapshot, 30*time.Second)
+
+	m.Update(fleetTickMsg{})
+
+	if calls != 2 {
+		t.Errorf("expected snapshot on creation and tick, got %d calls", calls)
+	}
+	if !strings.Contains(m.View(), "broken.example") {
+		t.Error("view should list every target")
+	}
+}
+
+func TestFleetModel_QuitOnQ(t *testing.T) {
+	m := NewFleetModel(testFleetStatuses, time.Second)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+
+	if cmd == nil {
+		t.Fatal("expected quit command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("expected tea.QuitMsg")
+	}
+}
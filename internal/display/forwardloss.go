@@ -0,0 +1,61 @@
+package display
+
+// ControlPlaneLossNote annotates a hop whose loss does not reach the final
+// hop: the router deprioritizes its own ICMP replies but forwards traffic.
+const ControlPlaneLossNote = "control-plane rate limiting (likely benign)"
+
+// minLossCycles is the number of cycles with loss a hop needs before its
+// loss is correlated with the final hop's.
+const minLossCycles = 3
+
+// CorrelateCycleLoss closes a cycle: for every hop before the final one it
+// records whether the hop lost a probe, whether the final hop did, and
+// whether both did. The final hop is the last one that ever answered. Hops
+// not probed this cycle are left out, and every hop starts the next cycle
+// afresh.
+func CorrelateCycleLoss(stats map[int]*HopStats) {
+	var final *HopStats
+	for _, s := range stats {
+		if s.Recv > 0 && (final == nil || s.TTL > final.TTL) {
+			final = s
+		}
+	}
+
+	if final != nil && final.cycleSent > 0 {
+		finalLost := final.cycleLost > 0
+		for _, s := range stats {
+			if s.TTL >= final.TTL || s.cycleSent == 0 {
+				continue
+			}
+			lost := s.cycleLost > 0
+			s.CorrelatedCycles++
+			if lost {
+				s.LossCycles++
+			}
+			if finalLost {
+				s.FinalLossCycles++
+			}
+			if lost && finalLost {
+				s.SharedLossCycles++
+			}
+		}
+	}
+
+	for _, s := range stats {
+		s.cycleSent, s.cycleLost = 0, 0
+	}
+}
+
+// ControlPlaneLoss reports whether the hop's loss does not propagate
+// downstream: it lost probes in at least minLossCycles cycles, the final hop
+// lost in clearly fewer of the same cycles, and mostly not at the same time.
+// Forwarding loss at a hop would show at the final hop too.
+func (s *HopStats) ControlPlaneLoss() bool {
+	if s.LossCycles < minLossCycles || s.CorrelatedCycles == 0 {
+		return false
+	}
+	hopRate := float64(s.LossCycles) / float64(s.CorrelatedCycles)
+	finalRate := float64(s.FinalLossCycles) / float64(s.CorrelatedCycles)
+	shared := float64(s.SharedLossCycles) / float64(s.LossCycles)
+	return hopRate-finalRate >= 0.1 && shared < 0.5
+}
@@ -0,0 +1,87 @@
+package display
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runCycles feeds one probe per hop per cycle: patterns[ttl-1][cycle] is 'x'
+// for a timeout, anything else for a reply. Each cycle is then correlated.
+func runCycles(stats map[int]*HopStats, patterns ...string) {
+	for cycle := range len(patterns[0]) {
+		for i, p := range patterns {
+			s, ok := stats[i+1]
+			if !ok {
+				s = NewHopStats(i + 1)
+				stats[i+1] = s
+			}
+			if p[cycle] == 'x' {
+				s.AddTimeout()
+			} else {
+				s.AddProbe(net.IPv4(10, 0, 0, byte(i+1)), time.Millisecond)
+			}
+		}
+		CorrelateCycleLoss(stats)
+	}
+}
+
+func TestCorrelateCycleLoss_ControlPlane(t *testing.T) {
+	stats := make(map[int]*HopStats)
+	runCycles(stats,
+		"..........",
+		".x..x.x..x", // Loss the final hop does not share
+		"..........",
+	)
+
+	s := stats[2]
+	if s.CorrelatedCycles != 10 || s.LossCycles != 4 || s.SharedLossCycles != 0 {
+		t.Errorf("cycles = %d correlated, %d lost, %d shared; want 10, 4, 0",
+			s.CorrelatedCycles, s.LossCycles, s.SharedLossCycles)
+	}
+	if !s.ControlPlaneLoss() {
+		t.Error("loss that does not reach the final hop should be control-plane loss")
+	}
+	if stats[3].CorrelatedCycles != 0 {
+		t.Error("the final hop should not be correlated with itself")
+	}
+}
+
+func TestCorrelateCycleLoss_ForwardingLoss(t *testing.T) {
+	stats := make(map[int]*HopStats)
+	runCycles(stats,
+		"..........",
+		".x..x.x..x",
+		".x..x.x..x", // Same cycles lost at the final hop
+	)
+
+	if stats[2].ControlPlaneLoss() {
+		t.Error("loss shared with the final hop is forwarding loss")
+	}
+}
+
+func TestCorrelateCycleLoss_TooFewLossCycles(t *testing.T) {
+	stats := make(map[int]*HopStats)
+	runCycles(stats,
+		".x..x.....",
+		"..........",
+	)
+
+	if stats[1].ControlPlaneLoss() {
+		t.Error("two loss cycles are too few to judge")
+	}
+}
+
+func TestMTRModel_PlainText_ControlPlaneLoss(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	for cycle, c := range ".x..x.x..x" {
+		model.handleProbeResult(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Millisecond, Timeout: c == 'x'})
+		model.handleProbeResult(ProbeResultMsg{TTL: 2, IP: net.ParseIP("8.8.8.8"), RTT: 10 * time.Millisecond})
+		model.Update(CycleCompleteMsg{Cycle: cycle + 1, Reached: true})
+	}
+
+	if out := model.PlainText(); !strings.Contains(out, ControlPlaneLossNote) {
+		t.Errorf("report missing control-plane note:\n%s", out)
+	}
+}
@@ -0,0 +1,206 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// heatmapMode is what the cells of the MTR heatmap encode.
+type heatmapMode int
+
+const (
+	heatmapOff  heatmapMode = iota // Hop table
+	heatmapRTT                     // RTT above the hop's best
+	heatmapLoss                    // Share of probes lost
+)
+
+// Heatmap layout
+const (
+	heatmapLabelWidth   = 4  // Hop number and a space
+	heatmapHostWidth    = 16 // Address after the cells
+	heatmapDefaultWidth = 60 // Cells when the terminal width is unknown
+	heatmapMinWidth     = 10
+)
+
+// heatCell sums up the probes of one hop in one time bucket.
+type heatCell struct {
+	sent int
+	lost int
+	sum  time.Duration // RTTs of the answered probes
+}
+
+// avg returns the average RTT of the answered probes, 0 when none.
+func (c heatCell) avg() time.Duration {
+	if recv := c.sent - c.lost; recv > 0 {
+		return c.sum / time.Duration(recv)
+	}
+	return 0
+}
+
+// buildHeatmap spreads the recorded samples of each hop over cols time
+// buckets ending at now, oldest on the left. A bucket spans the time since
+// the oldest sample divided by cols, rounded up to the second.
+func buildHeatmap(hops []*HopStats, cols int, now time.Time) ([][]heatCell, time.Duration) {
+	oldest := now
+	for _, s := range hops {
+		for _, sample := range s.Samples {
+			if !sample.At.IsZero() && sample.At.Before(oldest) {
+				oldest = sample.At
+			}
+		}
+	}
+	bucket := max((now.Sub(oldest)/time.Duration(cols) + time.Second - 1).Truncate(time.Second), time.Second)
+
+	cells := make([][]heatCell, len(hops))
+	for i, s := range hops {
+		cells[i] = make([]heatCell, cols)
+		for _, sample := range s.Samples {
+			if sample.At.IsZero() {
+				continue
+			}
+			col := cols - 1 - int(now.Sub(sample.At)/bucket)
+			if col < 0 || col >= cols {
+				continue
+			}
+			cells[i][col].sent++
+			if sample.Lost {
+				cells[i][col].lost++
+			} else {
+				cells[i][col].sum += sample.RTT
+			}
+		}
+	}
+	return cells, bucket
+}
+
+// heatLevel returns the heat of a cell from 0 to len(glyphs.Heat)-1, or -1
+// for an empty cell and len(glyphs.Heat) for an RTT cell whose probes were
+// all lost. RTT heat grows with the excess over best, in steps of a quarter
+// of best (at least 1ms, so LAN jitter stays cool); loss heat in steps of 25%.
+func heatLevel(c heatCell, mode heatmapMode, best time.Duration) int {
+	top := len(glyphs.Heat) - 1
+	if c.sent == 0 {
+		return -1
+	}
+	if mode == heatmapLoss {
+		switch pct := c.lost * 100 / c.sent; {
+		case c.lost == 0:
+			return 0
+		case pct <= 25:
+			return 1
+		case pct <= 50:
+			return 2
+		}
+		return top
+	}
+	if c.lost == c.sent {
+		return top + 1
+	}
+	step := max(best/4, time.Millisecond)
+	excess := c.avg() - best
+	switch {
+	case excess < step:
+		return 0
+	case excess < 2*step:
+		return 1
+	case excess < 4*step:
+		return 2
+	}
+	return top
+}
+
+// heatCellString renders a cell at a heat level.
+func heatCellString(level int) string {
+	top := len(glyphs.Heat) - 1
+	switch {
+	case level < 0:
+		return " "
+	case level > top:
+		return timeoutStyle.Render(glyphs.Fail)
+	case level == top:
+		return timeoutStyle.Render(string(glyphs.Heat[level]))
+	case level == top-1:
+		return asnStyle.Render(string(glyphs.Heat[level]))
+	}
+	return rttStyle.Render(string(glyphs.Heat[level]))
+}
+
+// heatmapWidthLocked returns the number of time buckets that fit the
+// terminal. Must be called with lock held.
+func (m *MTRModel) heatmapWidthLocked() int {
+	if m.width <= 0 {
+		return heatmapDefaultWidth
+	}
+	return max(m.width-heatmapLabelWidth-1-heatmapHostWidth, heatmapMinWidth)
+}
+
+// formatHeatmap renders the heatmap view: one row per hop, one column per
+// time bucket, so intermittent latency or loss shows up as a vertical
+// streak across the hops it affects. Must be called with lock held.
+func (m *MTRModel) formatHeatmap(now time.Time) string {
+	var b strings.Builder
+
+	title := fmt.Sprintf("gtr %s %s (%s)", glyphs.Arrow, m.target, m.targetIP)
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	hops := m.getOrderedStatsLocked()
+	cols := m.heatmapWidthLocked()
+	cells, bucket := buildHeatmap(hops, cols, now)
+
+	header := "Latency heatmap: RTT above each hop's best"
+	if m.heatmap == heatmapLoss {
+		header = "Loss heatmap: share of probes lost"
+	}
+	b.WriteString(headerStyle.Render(fmt.Sprintf("%s, %s per column", header, bucket)))
+	b.WriteString("\n\n")
+
+	for i, s := range hops {
+		fmt.Fprintf(&b, "%3d ", s.TTL)
+		for _, c := range cells[i] {
+			b.WriteString(heatCellString(heatLevel(c, m.heatmap, s.FilteredBest)))
+		}
+		host := "???"
+		if ip := s.PrimaryIP(); ip != nil {
+			host = ip.String()
+		}
+		b.WriteString(" ")
+		b.WriteString(ipStyle.Render(truncateMiddle(host, heatmapHostWidth)))
+		b.WriteString("\n")
+	}
+
+	// Time axis: the span covered on the left, now on the right
+	span := fmt.Sprintf("-%s", (bucket * time.Duration(cols)).String())
+	b.WriteString(strings.Repeat(" ", heatmapLabelWidth))
+	b.WriteString(hopStyle.Render(span + strings.Repeat(" ", max(cols-len(span)-3, 1)) + "now"))
+	b.WriteString("\n\n")
+
+	// Legend
+	var legend []string
+	labels := []string{"< 1/4", "< 1/2", "< 1x", ">= 1x best"}
+	if m.heatmap == heatmapLoss {
+		labels = []string{"0%", "<= 25%", "<= 50%", "> 50% lost"}
+	}
+	for level, label := range labels {
+		legend = append(legend, heatCellString(level)+" "+label)
+	}
+	if m.heatmap == heatmapRTT {
+		legend = append(legend, heatCellString(len(glyphs.Heat))+" all lost")
+	}
+	b.WriteString(strings.Join(legend, "  "))
+	b.WriteString("\n")
+	history := m.historySize
+	if history < 1 {
+		history = RTTHistorySize
+	}
+	b.WriteString(statusStyle.Render(fmt.Sprintf("History %d samples per hop", history)))
+	b.WriteString("\n")
+	next := "loss heatmap"
+	if m.heatmap == heatmapLoss {
+		next = "hop table"
+	}
+	fmt.Fprintf(&b, "Press 'h' %s, Esc back, 'q' quit", next)
+
+	return b.String()
+}
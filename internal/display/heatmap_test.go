@@ -0,0 +1,107 @@
+package display
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestBuildHeatmap_BucketsSamplesByTime(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewHopStatsWithHistory(1, 100)
+	s.Samples = []RTTSample{
+		{RTT: 10 * time.Millisecond, At: now.Add(-60 * time.Second)},
+		{Lost: true, At: now.Add(-25 * time.Second)},
+		{RTT: 12 * time.Millisecond, At: now.Add(-21 * time.Second)},
+		{RTT: 14 * time.Millisecond, At: now},
+	}
+
+	cells, bucket := buildHeatmap([]*HopStats{s}, 6, now)
+	if bucket != 10*time.Second {
+		t.Fatalf("expected 10s buckets over 60s and 6 columns, got %s", bucket)
+	}
+	row := cells[0]
+	// The oldest sample falls one bucket short of the window and is dropped
+	if row[0].sent != 0 {
+		t.Errorf("expected the sample 60s ago out of the window, got %+v", row[0])
+	}
+	if c := row[3]; c.sent != 2 || c.lost != 1 || c.avg() != 12*time.Millisecond {
+		t.Errorf("expected a lost and a 12ms probe 20-30s ago, got %+v", c)
+	}
+	if c := row[5]; c.sent != 1 || c.avg() != 14*time.Millisecond {
+		t.Errorf("expected the newest probe in the last column, got %+v", c)
+	}
+}
+
+func TestHeatLevel(t *testing.T) {
+	best := 20 * time.Millisecond
+	top := len(glyphs.Heat) - 1
+	tests := []struct {
+		name string
+		cell heatCell
+		mode heatmapMode
+		want int
+	}{
+		{"empty", heatCell{}, heatmapRTT, -1},
+		{"at best", heatCell{sent: 1, sum: 22 * time.Millisecond}, heatmapRTT, 0},
+		{"quarter above", heatCell{sent: 1, sum: 27 * time.Millisecond}, heatmapRTT, 1},
+		{"half above", heatCell{sent: 1, sum: 35 * time.Millisecond}, heatmapRTT, 2},
+		{"double", heatCell{sent: 2, sum: 90 * time.Millisecond}, heatmapRTT, top},
+		{"all lost", heatCell{sent: 2, lost: 2}, heatmapRTT, top + 1},
+		{"no loss", heatCell{sent: 4, sum: time.Second}, heatmapLoss, 0},
+		{"quarter lost", heatCell{sent: 4, lost: 1}, heatmapLoss, 1},
+		{"half lost", heatCell{sent: 4, lost: 2}, heatmapLoss, 2},
+		{"mostly lost", heatCell{sent: 4, lost: 3}, heatmapLoss, top},
+	}
+
+	for _, tt := range tests {
+		if got := heatLevel(tt.cell, tt.mode, best); got != tt.want {
+			t.Errorf("%s: heatLevel = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// LAN hops keep a 1ms floor so sub-millisecond jitter stays cool
+	if got := heatLevel(heatCell{sent: 1, sum: 900 * time.Microsecond}, heatmapRTT, 300*time.Microsecond); got != 0 {
+		t.Errorf("expected LAN jitter below 1ms to stay cool, got level %d", got)
+	}
+}
+
+func TestMTRModel_HeatmapKeyCycles(t *testing.T) {
+	model := NewMTRModel("example.com", "93.184.216.34")
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	for ttl := 1; ttl <= 3; ttl++ {
+		model.Update(ProbeResultMsg{TTL: ttl, IP: net.ParseIP("10.0.0." + string(rune('0'+ttl))), RTT: 5 * time.Millisecond})
+	}
+	model.Update(ProbeResultMsg{TTL: 2, Timeout: true})
+
+	h := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}}
+	model.Update(h)
+	view := model.View()
+	if !strings.Contains(view, "Latency heatmap") || strings.Contains(view, "Loss%") {
+		t.Fatalf("expected the latency heatmap instead of the table, got:\n%s", view)
+	}
+	for _, want := range []string{"  1 ", "  3 ", "10.0.0.3", "now"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in the heatmap, got:\n%s", want, view)
+		}
+	}
+
+	model.Update(h)
+	if view := model.View(); !strings.Contains(view, "Loss heatmap") {
+		t.Errorf("expected 'h' to switch to the loss heatmap, got:\n%s", view)
+	}
+
+	model.Update(h)
+	if view := model.View(); !strings.Contains(view, "Loss%") {
+		t.Errorf("expected 'h' to return to the hop table, got:\n%s", view)
+	}
+
+	model.Update(h)
+	model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if view := model.View(); !strings.Contains(view, "Loss%") {
+		t.Errorf("expected Esc to return to the hop table, got:\n%s", view)
+	}
+}
@@ -0,0 +1,161 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// helpSection is one titled block of the '?' help overlay.
+type helpSection struct {
+	title string
+	items [][2]string // Key, mode or badge, then what it does or means
+}
+
+// traceHelp is the help of the interactive trace view.
+var traceHelp = []helpSection{
+	{"Keys", [][2]string{
+		{"e", "Export the hops received so far (json, csv or text)"},
+		{"g", "ECMP graph instead of the hop table (once ECMP is detected)"},
+		{"j / k", "Scroll the ECMP graph"},
+		{"Esc", "Back to the hop table"},
+		{"?", "This help"},
+		{"q", "Quit (cancels a trace in progress)"},
+	}},
+	{"Status bar", [][2]string{
+		{"MPLS", "A hop quoted MPLS labels"},
+		{"ECMP", "A hop answered from several addresses (load balancing)"},
+		{"NAT", "A hop rewrote the probes' addresses"},
+	}},
+}
+
+// mtrHelp is the help of the MTR view.
+var mtrHelp = []helpSection{
+	{"Keys", [][2]string{
+		{"Enter / Space / d", "Hop details: every ECMP address, enrichment and last probes"},
+		{"Up / Down (k / j)", "Select a hop"},
+		{"g", "RTT chart of the selected hop"},
+		{"h", "Heatmap of every hop over time: RTT, then loss, then back to the table"},
+		{"Left / Right (l)", "Scroll hidden columns on narrow terminals"},
+		{"e", "Expand ECMP addresses into sub-rows"},
+		{"n", "Cycle the host display mode"},
+		{"c", "Copy mode: the table as plain text"},
+		{"s", "Snapshot the statistics to a timestamped file"},
+		{"E", "Export the statistics (json, csv or text)"},
+		{"t", "Trace another target, restarting the statistics (local traces)"},
+		{"p", "Pause or resume probing"},
+		{"+ / -", "Lengthen or shorten the interval between cycles"},
+		{"z", "Cycle probe sizes, from 64 to 1500 bytes"},
+		{"r", "Reset statistics"},
+		{"?", "This help"},
+		{"q", "Quit and print the report"},
+	}},
+	{"Display modes (n)", [][2]string{
+		{"[DNS]", "Hostname, or the address when it has none"},
+		{"[IP]", "Address"},
+		{"[Both]", "Address and hostname"},
+	}},
+	{"Status bar", [][2]string{
+		{"PAUSED", "Probing is paused"},
+		{"Warm-up", "Cycle excluded from the statistics (--warmup)"},
+		{"Stability", "Share of cycles in which the path did not change"},
+		{"Every / probes", "Interval between cycles and probe size"},
+		{"MPLS / ECMP", "A hop quoted MPLS labels / answered from several addresses"},
+		{"Sorted by", "Rows sorted by a clicked column"},
+		{"stats restarted", "Sleep or network change, statistics restarted"},
+	}},
+	{"Hop flags", [][2]string{
+		{"[NAT] [MTU:n]", "Address rewrite / path MTU drop at this hop"},
+		{"[^TTL]", "The hop resets or raises the TTL of probes"},
+		{"[!N] [!H] [!P] [!F] [!X]", "Network, host, protocol unreachable, needs fragmentation, prohibited"},
+		{"[!]", "Route flap: the address at this TTL keeps changing"},
+		{"[RL?]", "Loss the hops behind do not share: likely ICMP rate limiting"},
+		{"[MPLS] [SR] [SRv6]", "MPLS labels / segment routing domain"},
+		{"[DSCP:n] [DF] [TCP:flags]", "Header fields of the quoted probe (--decode)"},
+	}},
+}
+
+// splitHelp is the help of the multi-target MTR view.
+var splitHelp = []helpSection{
+	{"Keys", [][2]string{
+		{"1-9", "Show one target full-screen, with every MTR key"},
+		{"0", "Back to the split view"},
+		{"e", "Expand ECMP addresses into sub-rows"},
+		{"n", "Cycle the host display mode"},
+		{"s", "Snapshot every target to its own file"},
+		{"p", "Pause or resume probing of every target"},
+		{"+ / -", "Lengthen or shorten the interval of every target"},
+		{"z", "Cycle probe sizes of every target"},
+		{"r", "Reset statistics of every target"},
+		{"?", "This help"},
+		{"q", "Quit and print the reports"},
+	}},
+}
+
+// renderHelp renders sections as a bordered modal.
+func renderHelp(sections []helpSection) string {
+	keyWidth := 0
+	for _, s := range sections {
+		for _, item := range s.items {
+			keyWidth = max(keyWidth, lipgloss.Width(item[0]))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("gtrace help"))
+	for _, s := range sections {
+		b.WriteString("\n\n")
+		b.WriteString(headerStyle.Render(s.title))
+		for _, item := range s.items {
+			fmt.Fprintf(&b, "\n  %-*s  %s", keyWidth, item[0], item[1])
+		}
+	}
+	b.WriteString("\n\n")
+	b.WriteString(statusStyle.Render("Press any key to close"))
+
+	border := lipgloss.Border{
+		Top:         glyphs.HLine,
+		Bottom:      glyphs.HLine,
+		Left:        glyphs.VLine,
+		Right:       glyphs.VLine,
+		TopLeft:     glyphs.TopLeft,
+		TopRight:    glyphs.TopRight,
+		BottomLeft:  glyphs.BottomLeft,
+		BottomRight: glyphs.BottomRight,
+	}
+	return lipgloss.NewStyle().Border(border).Padding(0, 1).Render(b.String())
+}
+
+// overlayCenter draws modal over the middle of base, a width by height
+// screen (0 = the size of base), which stays visible around it.
+func overlayCenter(base, modal string, width, height int) string {
+	lines := strings.Split(base, "\n")
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	if width <= 0 {
+		width = lipgloss.Width(base)
+	}
+
+	modalLines := strings.Split(modal, "\n")
+	modalWidth := lipgloss.Width(modal)
+	top := max((len(lines)-len(modalLines))/2, 0)
+	left := max((width-modalWidth)/2, 0)
+
+	for i, ml := range modalLines {
+		row := top + i
+		if row >= len(lines) {
+			lines = append(lines, "")
+		}
+		before := ansi.Truncate(lines[row], left, "")
+		if strings.Contains(before, "\x1b") {
+			before += ansi.ResetStyle
+		}
+		before += strings.Repeat(" ", left-ansi.StringWidth(before))
+		after := ansi.TruncateLeft(lines[row], left+modalWidth, "")
+		lines[row] = before + ml + after
+	}
+	return strings.Join(lines, "\n")
+}
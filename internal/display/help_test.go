@@ -0,0 +1,41 @@
+package display
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOverlayCenter_KeepsBaseAroundModal(t *testing.T) {
+	base := strings.Repeat(strings.Repeat("x", 20)+"\n", 4) + strings.Repeat("x", 20)
+	modal := "+--+\n|ab|\n+--+"
+
+	lines := strings.Split(overlayCenter(base, modal, 20, 5), "\n")
+
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d", len(lines))
+	}
+	if lines[0] != strings.Repeat("x", 20) || lines[4] != strings.Repeat("x", 20) {
+		t.Errorf("expected rows outside the modal untouched, got %q and %q", lines[0], lines[4])
+	}
+	if want := "xxxxxxxx|ab|xxxxxxxx"; lines[2] != want {
+		t.Errorf("expected %q, got %q", want, lines[2])
+	}
+}
+
+func TestOverlayCenter_PadsShortLines(t *testing.T) {
+	got := overlayCenter("ab", "[]", 10, 1)
+
+	if got != "ab  []" {
+		t.Errorf("expected the modal centered past a short line, got %q", got)
+	}
+}
+
+func TestRenderHelp_ListsKeysInABox(t *testing.T) {
+	help := renderHelp(mtrHelp)
+
+	for _, want := range []string{"gtrace help", "Pause or resume probing", "[RL?]", "Press any key to close", glyphs.TopLeft} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected %q in the help", want)
+		}
+	}
+}
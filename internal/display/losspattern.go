@@ -0,0 +1,85 @@
+package display
+
+import "math"
+
+// LossPattern classifies how a hop's timeouts are spread over time.
+type LossPattern string
+
+const (
+	// LossNone means the hop lost too few probes to classify.
+	LossNone LossPattern = ""
+	// LossRandom means isolated timeouts with no structure, as congestion
+	// drops in a busy queue produce.
+	LossRandom LossPattern = "random"
+	// LossBursty means timeouts cluster in runs longer than random loss at
+	// the same rate would give: an outage, a flapping link or a full buffer.
+	LossBursty LossPattern = "bursty"
+	// LossPeriodic means timeouts recur at a steady interval, the signature
+	// of a router's ICMP rate limiter rather than of forwarding loss.
+	LossPeriodic LossPattern = "periodic"
+)
+
+// lossGapHistorySize is the number of intervals between loss runs kept for
+// periodicity detection.
+const lossGapHistorySize = 20
+
+// Minimum evidence for a classification: probes sent, probes lost and, for
+// periodicity, intervals between loss runs.
+const (
+	minLossPatternSent = 10
+	minLossPatternLost = 2
+	minPeriodicGaps    = 3
+)
+
+// maxPeriodicVariation is the highest coefficient of variation of the
+// intervals between loss runs still counted as periodic.
+const maxPeriodicVariation = 0.2
+
+// LossPattern classifies the hop's timeouts as random, bursty or periodic,
+// or returns LossNone when there is too little loss to tell. A hop that never
+// answered is silent rather than lossy and has no pattern either.
+func (s *HopStats) LossPattern() LossPattern {
+	lost := s.Sent - s.Recv
+	if s.Sent < minLossPatternSent || s.Recv == 0 || lost < minLossPatternLost || s.LossRuns == 0 {
+		return LossNone
+	}
+
+	if len(s.lossGaps) >= minPeriodicGaps {
+		var sum float64
+		for _, g := range s.lossGaps {
+			sum += float64(g)
+		}
+		mean := sum / float64(len(s.lossGaps))
+		var variance float64
+		for _, g := range s.lossGaps {
+			d := float64(g) - mean
+			variance += d * d
+		}
+		variance /= float64(len(s.lossGaps))
+		if math.Sqrt(variance)/mean <= maxPeriodicVariation {
+			return LossPeriodic
+		}
+	}
+
+	// Random loss at rate p gives runs of 1/(1-p) timeouts on average
+	meanRun := float64(lost) / float64(s.LossRuns)
+	expected := 1 / (1 - s.LossPercent()/100)
+	if s.MaxLossRun >= 3 && meanRun >= 1.5*expected {
+		return LossBursty
+	}
+	return LossRandom
+}
+
+// LossPatternTag returns the report tag for the hop's loss pattern, or ""
+// when it has none. Periodic loss and loss that downstream hops do not share
+// are ICMP deprioritized by the router, not traffic dropped in forwarding.
+func (s *HopStats) LossPatternTag() string {
+	pattern := s.LossPattern()
+	if pattern == LossNone {
+		return ""
+	}
+	if pattern == LossPeriodic || s.RateLimited {
+		return "[loss:" + string(pattern) + ", ICMP limit]"
+	}
+	return "[loss:" + string(pattern) + "]"
+}
@@ -2,7 +2,11 @@ package display
 
 import (
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -11,30 +15,55 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/hervehildenbrand/gtrace/internal/monitor"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
 // ProbeResultMsg is sent when a probe result is received.
 type ProbeResultMsg struct {
-	TTL           int
-	IP            net.IP
-	RTT           time.Duration
-	Timeout       bool
-	MPLS          []hop.MPLSLabel
-	Enrichment    hop.Enrichment
-	ICMPType      int
-	ICMPCode      int
-	OriginalTTL   int                // -1 = not set
-	FlowID        int                // ECMP flow identifier (0 = not tracked)
-	TransportInfo *hop.TransportInfo // Decoded transport header info (nil if --decode not used)
+	TTL            int
+	IP             net.IP
+	RTT            time.Duration
+	Timeout        bool
+	MPLS           []hop.MPLSLabel
+	Enrichment     hop.Enrichment
+	ICMPType       int
+	ICMPCode       int
+	OriginalTTL    int                 // -1 = not set
+	FlowID         int                 // ECMP flow identifier (0 = not tracked)
+	TransportInfo  *hop.TransportInfo  // Decoded transport header info (nil if --decode not used)
+	NAT            bool                // NAT detected at this hop
+	MTU            int                 // Discovered MTU at this hop (0 = unknown)
+	SegmentRouting *hop.SegmentRouting // Segment routing domain of this hop (nil if none seen)
 }
 
 // CycleCompleteMsg is sent when a trace cycle completes.
 type CycleCompleteMsg struct {
 	Cycle   int
 	Reached bool
+	Invalid bool   // The system slept or the network changed during the cycle
+	Event   string // Invalid: what happened, e.g. "network changed (wlan0 → eth0)"
 }
 
+// TargetChangedMsg reports the outcome of switching the traced target with
+// the 't' key.
+type TargetChangedMsg struct {
+	Target   string
+	TargetIP string
+	Err      error // The target could not be switched to; the old one is still traced
+}
+
+// EnrichmentMsg updates the enrichment of an address already shown, e.g.
+// with a reverse DNS name resolved in the background.
+type EnrichmentMsg struct {
+	IP         net.IP
+	Enrichment hop.Enrichment
+}
+
+// NoticeMsg shows a note in the status bar, e.g. the progress of a replayed
+// session.
+type NoticeMsg string
+
 // TickMsg is sent periodically to refresh the display.
 type TickMsg struct{}
 
@@ -58,6 +87,9 @@ type MTRModel struct {
 	stats       map[int]*HopStats // Keyed by TTL
 	maxTTL      int               // Highest TTL seen
 	cycles      int
+	cycleBase   int // Tracer cycle at the last reset, so cycles counts from there
+	warmup      int // Initial cycles shown but left out of the statistics (--warmup)
+	warmupLeft  int // Warm-up cycles still to complete
 	running     bool
 	paused      bool
 	interval    time.Duration
@@ -69,13 +101,35 @@ type MTRModel struct {
 	showECMP    bool        // Toggle ECMP sub-row expansion
 	isIPv6      bool        // Track if target is IPv6 for column sizing
 	resetChan   chan<- struct{}
+	controls    MTRControls   // Keys acting on probing (nil functions = display only)
+	probeSize   int           // Probe size in bytes, when 'z' can change it
+	notice      string        // Outcome of the last snapshot or export, shown in the status bar
+	export      exportPrompt  // Path and format overlay of the 'E' export
+	showHelp    bool          // Help overlay over the current view
+	retargetCh  chan<- string // Targets typed at the 't' prompt (nil = switching unsupported)
+	editTarget  bool          // The 't' prompt is open
+	targetInput string        // Target typed at the 't' prompt
+	copyView    string        // Frozen plain-text table shown in copy mode ("" = live view)
+	lastEvent   string        // Most recent sleep/network change, shown in the status bar
+	lastEventAt time.Time
+	showDetail  bool        // Toggle the detail panel for the selected hop
+	selectedTTL int         // Hop shown in the detail panel (0 = first hop)
+	showChart   bool        // Show the full-width RTT chart of the selected hop
+	heatmap     heatmapMode // Heatmap shown instead of the table ('h')
+	chartScroll int         // Samples the RTT chart is scrolled back from the newest
+	historySize int         // RTT samples kept per hop (0 = RTTHistorySize)
+	colOffset   int         // Statistics columns scrolled out on the left (narrow terminals)
+	rowOffset   int         // Table rows scrolled out at the top (long paths)
+	sortColumn  string      // Column title the hops are sorted by ("" = hop order)
+	stability   *monitor.Stability
+	cycleAddrs  map[int][]string // Addresses that answered each TTL in the current cycle
 }
 
 // NewMTRModel creates a new MTR model.
 func NewMTRModel(target, targetIP string) *MTRModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	s.Style = spinnerStyle()
 
 	// Check if target is IPv6 (contains colon)
 	isIPv6 := strings.Contains(targetIP, ":")
@@ -91,9 +145,41 @@ func NewMTRModel(target, targetIP string) *MTRModel {
 		spinner:     s,
 		displayMode: DisplayModeHostname, // Default: show hostname first
 		isIPv6:      isIPv6,
+		stability:   monitor.NewStability(),
+		cycleAddrs:  make(map[int][]string),
 	}
 }
 
+// SetHistorySize sets the number of RTT samples kept per hop. It applies to
+// hops seen after the call, so set it before the first probe arrives.
+func (m *MTRModel) SetHistorySize(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.historySize = n
+}
+
+// SetControls connects the keys acting on probing to the tracer.
+func (m *MTRModel) SetControls(c MTRControls) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.controls = c
+	if c.Interval > 0 {
+		m.interval = c.Interval
+	}
+	m.probeSize = c.ProbeSize
+}
+
+// SetWarmup makes the first n cycles, and the first n after a network
+// change, warm-up cycles: their probes are shown, then cleared from the
+// statistics once the last one completes, so first-packet ARP/ND and route
+// cache misses do not skew Avg and Wrst.
+func (m *MTRModel) SetWarmup(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.warmup = n
+	m.warmupLeft = n
+}
+
 // Init implements tea.Model.
 func (m *MTRModel) Init() tea.Cmd {
 	return m.spinner.Tick
@@ -103,20 +189,67 @@ func (m *MTRModel) Init() tea.Cmd {
 func (m *MTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// The export overlay takes every key while it is open
+		if m.exporting() {
+			m.handleExportKey(msg)
+			return m, nil
+		}
+		// Any key but Ctrl+C closes the help overlay
+		if m.helpShown() && msg.String() != "ctrl+c" {
+			m.mu.Lock()
+			m.showHelp = false
+			m.mu.Unlock()
+			return m, nil
+		}
+		if m.editingTarget() {
+			m.handleTargetKey(msg)
+			return m, nil
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			m.running = false
 			return m, tea.Quit
+		case "t":
+			m.mu.Lock()
+			if m.retargetCh != nil {
+				m.editTarget = true
+				m.targetInput = ""
+			}
+			m.mu.Unlock()
+		case "?":
+			m.mu.Lock()
+			m.showHelp = true
+			m.mu.Unlock()
+		case "E":
+			m.mu.Lock()
+			m.copyView = ""
+			m.showChart = false
+			m.export.open("mtr", m.target, time.Now())
+			m.mu.Unlock()
 		case "p":
 			m.mu.Lock()
 			m.paused = !m.paused
+			paused, pauseFunc := m.paused, m.controls.Pause
+			m.mu.Unlock()
+			if pauseFunc != nil {
+				pauseFunc(paused)
+			}
+		case "+", "=", "-":
+			m.stepInterval(msg.String() != "-")
+		case "z":
+			m.cycleProbeSize()
+		case "s":
+			name, err := m.WriteSnapshot(".")
+			m.mu.Lock()
+			if err != nil {
+				m.notice = "Snapshot failed: " + err.Error()
+			} else {
+				m.notice = "Snapshot saved to " + name
+			}
 			m.mu.Unlock()
 		case "r":
 			m.mu.Lock()
-			m.stats = make(map[int]*HopStats)
-			m.maxTTL = 0
-			m.cycles = 0
-			m.startTime = time.Now()
+			m.resetStatsLocked()
 			resetChan := m.resetChan
 			m.mu.Unlock()
 			if resetChan != nil {
@@ -134,20 +267,89 @@ func (m *MTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.mu.Lock()
 			m.showECMP = !m.showECMP
 			m.mu.Unlock()
+		case "enter", " ", "d":
+			m.mu.Lock()
+			m.showDetail = !m.showDetail
+			m.mu.Unlock()
+		case "up", "k":
+			m.moveSelection(-1)
+		case "down", "j":
+			m.moveSelection(1)
+		case "g":
+			m.mu.Lock()
+			m.showChart = !m.showChart
+			m.chartScroll = 0
+			m.mu.Unlock()
+		case "h":
+			m.mu.Lock()
+			if m.showChart {
+				m.scrollChartLocked(1)
+			} else {
+				m.heatmap = (m.heatmap + 1) % 3
+			}
+			m.mu.Unlock()
+		case "left":
+			m.scrollHorizontal(-1)
+		case "right", "l":
+			m.scrollHorizontal(1)
+		case "c":
+			m.ToggleCopyMode()
+		case "esc":
+			m.mu.Lock()
+			m.copyView = ""
+			m.showChart = false
+			m.heatmap = heatmapOff
+			m.showDetail = false
+			m.mu.Unlock()
 		}
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 
+	case tea.MouseMsg:
+		m.handleMouse(msg)
+
 	case ProbeResultMsg:
 		m.handleProbeResult(msg)
 
+	case TargetChangedMsg:
+		m.mu.Lock()
+		if msg.Err != nil {
+			m.notice = fmt.Sprintf("Cannot switch to %s: %v", msg.Target, msg.Err)
+		} else {
+			// The tracer of the new target counts its cycles from 1
+			m.target, m.targetIP = msg.Target, msg.TargetIP
+			m.isIPv6 = strings.Contains(msg.TargetIP, ":")
+			m.resetStatsLocked()
+			m.cycleBase = 0
+			m.warmupLeft = m.warmup
+			m.lastEvent = ""
+			m.selectedTTL = 0
+			m.notice = "Switched to " + msg.Target
+		}
+		m.mu.Unlock()
+
+	case EnrichmentMsg:
+		m.handleEnrichment(msg)
+
+	case NoticeMsg:
+		m.mu.Lock()
+		m.notice = string(msg)
+		m.mu.Unlock()
+
 	case CycleCompleteMsg:
 		m.mu.Lock()
-		m.cycles = msg.Cycle
-		m.updateRateLimitFlags()
-		m.updateECMPClassification()
+		if msg.Invalid {
+			// Discard the cycle and restart the baseline on the new network
+			m.resetStatsLocked()
+			m.cycleBase = msg.Cycle
+			m.warmupLeft = m.warmup
+			m.lastEvent = msg.Event
+			m.lastEventAt = time.Now()
+		} else {
+			m.completeCycleLocked(msg.Cycle)
+		}
 		m.mu.Unlock()
 
 	case TickMsg:
@@ -162,6 +364,69 @@ func (m *MTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleEnrichment applies late enrichment to every hop the address
+// answered at.
+func (m *MTRModel) handleEnrichment(msg EnrichmentMsg) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stats := range m.stats {
+		if _, seen := stats.IPCounts[msg.IP.String()]; seen {
+			stats.SetIPEnrichment(msg.IP, msg.Enrichment)
+		}
+	}
+}
+
+// resetStatsLocked clears all statistics. Caller must hold m.mu.
+func (m *MTRModel) resetStatsLocked() {
+	m.stats = make(map[int]*HopStats)
+	m.maxTTL = 0
+	m.cycleBase += m.cycles
+	m.cycles = 0
+	m.startTime = time.Now()
+	m.rowOffset = 0
+	m.stability.Reset()
+	clear(m.cycleAddrs)
+}
+
+// completeCycleLocked records the end of valid tracer cycle cycle. The
+// last warm-up cycle clears the statistics gathered so far instead of
+// updating the per-cycle findings. Must be called with lock held.
+func (m *MTRModel) completeCycleLocked(cycle int) {
+	m.cycles = cycle - m.cycleBase
+	if m.warmupLeft == 0 {
+		m.endCycleLocked()
+		return
+	}
+	m.warmupLeft--
+	if m.warmupLeft > 0 {
+		return
+	}
+	for _, stats := range m.stats {
+		stats.ClearCounters()
+	}
+	m.cycleBase = cycle
+	m.cycles = 0
+	m.startTime = time.Now()
+	m.stability.Reset()
+	clear(m.cycleAddrs)
+}
+
+// endCycleLocked updates the findings that are computed once per cycle:
+// route stability, loss correlation, rate limiting and ECMP classification.
+// Must be called with lock held.
+func (m *MTRModel) endCycleLocked() {
+	now := time.Now()
+	for _, ttl := range m.stability.Observe(m.cycleAddrs) {
+		if stats, ok := m.stats[ttl]; ok {
+			stats.LastChange = now
+		}
+	}
+	clear(m.cycleAddrs)
+	CorrelateCycleLoss(m.stats)
+	m.updateRateLimitFlags()
+	m.updateECMPClassification()
+}
+
 // handleProbeResult processes a probe result message.
 func (m *MTRModel) handleProbeResult(msg ProbeResultMsg) {
 	m.mu.Lock()
@@ -170,7 +435,7 @@ func (m *MTRModel) handleProbeResult(msg ProbeResultMsg) {
 	// Get or create stats for this TTL
 	stats, ok := m.stats[msg.TTL]
 	if !ok {
-		stats = NewHopStats(msg.TTL)
+		stats = NewHopStatsWithHistory(msg.TTL, m.historySize)
 		m.stats[msg.TTL] = stats
 	}
 
@@ -179,11 +444,22 @@ func (m *MTRModel) handleProbeResult(msg ProbeResultMsg) {
 		m.maxTTL = msg.TTL
 	}
 
+	// NAT and MTU are per-hop findings; MTU hops may only time out
+	if msg.NAT {
+		stats.NAT = true
+	}
+	if msg.MTU > 0 {
+		stats.MTU = msg.MTU
+	}
+
 	// Record the probe result
 	if msg.Timeout {
 		stats.AddTimeout()
 	} else {
 		stats.AddProbe(msg.IP, msg.RTT)
+		if msg.IP != nil && !slices.Contains(m.cycleAddrs[msg.TTL], msg.IP.String()) {
+			m.cycleAddrs[msg.TTL] = append(m.cycleAddrs[msg.TTL], msg.IP.String())
+		}
 
 		// Track ICMP type/code for code reporting
 		if msg.ICMPType != 0 {
@@ -206,7 +482,7 @@ func (m *MTRModel) handleProbeResult(msg ProbeResultMsg) {
 		}
 
 		// Update enrichment if provided (only on first response per IP)
-		if msg.Enrichment.ASN != 0 || msg.Enrichment.Hostname != "" {
+		if msg.Enrichment.ASN != 0 || msg.Enrichment.Hostname != "" || msg.Enrichment.Special != "" || len(msg.Enrichment.Reputation) > 0 {
 			stats.SetIPEnrichment(msg.IP, msg.Enrichment)
 		}
 
@@ -215,6 +491,10 @@ func (m *MTRModel) handleProbeResult(msg ProbeResultMsg) {
 			stats.SetMPLS(msg.MPLS)
 		}
 
+		if msg.SegmentRouting != nil {
+			stats.SegmentRouting = msg.SegmentRouting
+		}
+
 		// Track transport info for decode display
 		if msg.TransportInfo != nil {
 			stats.LastTransportInfo = msg.TransportInfo
@@ -237,6 +517,10 @@ const (
 	colStdDev   = 8
 )
 
+// minHostWidth is the narrowest the host column gets before statistics
+// columns are hidden.
+const minHostWidth = 20
+
 // getHostColumnWidth returns the appropriate host column width.
 func (m *MTRModel) getHostColumnWidth() int {
 	if m.isIPv6 {
@@ -245,56 +529,405 @@ func (m *MTRModel) getHostColumnWidth() int {
 	return colHostIPv4
 }
 
+// mtrColumn is a statistics column shown after Hop, Host and Loss%.
+type mtrColumn struct {
+	title string
+	width int
+	left  bool                                      // Left-aligned, unpadded title (last column)
+	cell  func(m *MTRModel, stats *HopStats) string // Styled cell padded to width
+	value func(stats *HopStats) float64             // Sort key (nil = not sortable)
+}
+
+// statColumns are the statistics columns in display order. Narrow
+// terminals hide them from the right; Left/Right scroll through them.
+var statColumns = []mtrColumn{
+	{title: "Snt", width: colSnt, cell: func(m *MTRModel, s *HopStats) string {
+		return fmt.Sprintf("%*d", colSnt, s.Sent)
+	}, value: func(s *HopStats) float64 { return float64(s.Sent) }},
+	{title: "Recv", width: colRecv, cell: func(m *MTRModel, s *HopStats) string {
+		return fmt.Sprintf("%*d", colRecv, s.Recv)
+	}, value: func(s *HopStats) float64 { return float64(s.Recv) }},
+	{title: "Best", width: colBest, cell: func(m *MTRModel, s *HopStats) string {
+		return rttCell(s.FilteredBest, colBest)
+	}, value: func(s *HopStats) float64 { return float64(s.FilteredBest) }},
+	{title: "Avg", width: colAvg, cell: func(m *MTRModel, s *HopStats) string {
+		return rttCell(s.FilteredAvgRTT(), colAvg)
+	}, value: func(s *HopStats) float64 { return float64(s.FilteredAvgRTT()) }},
+	{title: "Wrst", width: colWrst, cell: func(m *MTRModel, s *HopStats) string {
+		return rttCell(s.WorstRTT, colWrst)
+	}, value: func(s *HopStats) float64 { return float64(s.WorstRTT) }},
+	{title: "Last", width: colLast, cell: func(m *MTRModel, s *HopStats) string {
+		return rttCell(s.LastRTT, colLast)
+	}, value: func(s *HopStats) float64 { return float64(s.LastRTT) }},
+	{title: "StDev", width: colStdDev, cell: func(m *MTRModel, s *HopStats) string {
+		return rttCell(s.FilteredStdDev(), colStdDev)
+	}, value: func(s *HopStats) float64 { return float64(s.FilteredStdDev()) }},
+	{title: "Graph", width: RTTHistorySize, left: true, cell: func(m *MTRModel, s *HopStats) string {
+		// Sparkline of the most recent samples
+		rtts := s.RTTHistory
+		if len(rtts) > RTTHistorySize {
+			rtts = rtts[len(rtts)-RTTHistorySize:]
+		}
+		return m.renderSparkline(rtts)
+	}},
+}
+
+// rttCell formats a duration column in milliseconds, or "-" when unset.
+func rttCell(d time.Duration, width int) string {
+	if d <= 0 {
+		return timeoutStyle.Render(fmt.Sprintf("%*s", width, "-"))
+	}
+	return rttStyle.Render(fmt.Sprintf("%*.1f", width, msFloat(d)))
+}
+
+// tableLayout is the set of columns that fit the terminal width.
+type tableLayout struct {
+	hostWidth   int
+	columns     []mtrColumn // Visible statistics columns
+	offset      int         // Index of the first visible statistics column
+	hiddenLeft  int
+	hiddenRight int
+}
+
+// lineWidth returns the width of a table row without indicators.
+func (l tableLayout) lineWidth() int {
+	width := colHop + 1 + l.hostWidth + 1 + colLoss
+	for _, col := range l.columns {
+		width += 1 + col.width
+	}
+	return width
+}
+
+// layoutLocked fits the table to the terminal width: the host column
+// shrinks first, down to minHostWidth, then statistics columns are hidden
+// from the right. Hop, Host and Loss% are always shown. Without a known
+// width every column is shown. Must be called with lock held.
+func (m *MTRModel) layoutLocked() tableLayout {
+	layout := tableLayout{hostWidth: m.getHostColumnWidth(), columns: statColumns}
+	if m.width <= 0 || layout.lineWidth() <= m.width {
+		return layout
+	}
+
+	layout.hostWidth = max(layout.hostWidth-(layout.lineWidth()-m.width), minHostWidth)
+	avail := m.width - (colHop + 1 + layout.hostWidth + 1 + colLoss)
+
+	// Scroll no further than needed to bring the last column into view
+	maxOffset := len(statColumns)
+	for used := 0; maxOffset > 0 && used+1+statColumns[maxOffset-1].width <= avail; maxOffset-- {
+		used += 1 + statColumns[maxOffset-1].width
+	}
+	layout.offset = min(m.colOffset, maxOffset)
+
+	end := layout.offset
+	for used := 0; end < len(statColumns) && used+1+statColumns[end].width <= avail; end++ {
+		used += 1 + statColumns[end].width
+	}
+	layout.columns = statColumns[layout.offset:end]
+	layout.hiddenLeft = layout.offset
+	layout.hiddenRight = len(statColumns) - end
+	return layout
+}
+
+// scrollHorizontal scrolls the RTT chart when it is shown, otherwise the
+// statistics columns hidden on narrow terminals. delta > 0 scrolls right.
+func (m *MTRModel) scrollHorizontal(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.showChart {
+		m.scrollChartLocked(-delta)
+		return
+	}
+	layout := m.layoutLocked()
+	switch {
+	case delta < 0:
+		m.colOffset = max(layout.offset-1, 0)
+	case layout.hiddenRight > 0:
+		m.colOffset = layout.offset + 1
+	}
+}
+
+// Screen lines of the table in the live view
+const (
+	headerLine   = 2 // Column titles, below the title and a blank line
+	firstRowLine = 4 // First hop row, below the header rule
+)
+
+// tableRow is a rendered line of the hop table: a hop row or one of its
+// ECMP sub-rows.
+type tableRow struct {
+	ttl  int
+	text string
+}
+
+// displayStatsLocked returns the hops in display order: by TTL, or by the
+// clicked column with the largest values first. Must be called with lock held.
+func (m *MTRModel) displayStatsLocked() []*HopStats {
+	ordered := m.getOrderedStatsLocked()
+	value := sortValue(m.sortColumn)
+	if value == nil {
+		return ordered
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return value(ordered[i]) > value(ordered[j])
+	})
+	return ordered
+}
+
+// sortValue returns the sort key of a column, or nil if it can't be sorted.
+func sortValue(title string) func(*HopStats) float64 {
+	if title == "Loss%" {
+		return (*HopStats).LossPercent
+	}
+	for _, col := range statColumns {
+		if col.title == title {
+			return col.value
+		}
+	}
+	return nil
+}
+
+// columnTitle returns a header title, marked when the table is sorted by it.
+func (m *MTRModel) columnTitle(title string) string {
+	if title == m.sortColumn {
+		return title + glyphs.SortDesc
+	}
+	return title
+}
+
+// columnAt returns the title of the header column at screen column x.
+// The space before a column counts as part of it.
+func (l tableLayout) columnAt(x int) string {
+	edge := colHop
+	if x < edge {
+		return "Hop"
+	}
+	if edge += 1 + l.hostWidth; x < edge {
+		return "Host"
+	}
+	if edge += 1 + colLoss; x < edge {
+		return "Loss%"
+	}
+	for _, col := range l.columns {
+		if edge += 1 + col.width; x < edge {
+			return col.title
+		}
+	}
+	return ""
+}
+
+// rowWindowLocked renders the hop table rows and the detail panel, and
+// returns the range of rows that fits the terminal height below the header
+// and above the detail panel and status bar. Must be called with lock held.
+func (m *MTRModel) rowWindowLocked(layout tableLayout) (rows []tableRow, start, end int, detail string) {
+	for _, stats := range m.displayStatsLocked() {
+		rows = append(rows, tableRow{stats.TTL, m.clipLine(m.formatStatsRow(stats, layout))})
+		if m.showECMP && stats.HasECMP() {
+			sub := strings.TrimSuffix(m.clipLine(m.formatECMPSubRows(stats)), "\n")
+			for _, line := range strings.Split(sub, "\n") {
+				rows = append(rows, tableRow{stats.TTL, line})
+			}
+		}
+	}
+
+	if m.showDetail {
+		if stats, ok := m.stats[m.selectedTTLLocked()]; ok {
+			detail = "\n" + m.formatHopDetail(stats)
+		}
+	}
+
+	visible := len(rows)
+	if m.height > 0 {
+		// Title, blank, header and rule above; blank, rule, status and help below
+		visible = min(max(m.height-8-strings.Count(detail, "\n"), 3), len(rows))
+	}
+	start = min(m.rowOffset, len(rows)-visible)
+	return rows, start, start + visible, detail
+}
+
+// scrollToSelectedLocked scrolls the table so the selected hop is visible.
+// Must be called with lock held.
+func (m *MTRModel) scrollToSelectedLocked() {
+	rows, start, end, _ := m.rowWindowLocked(m.layoutLocked())
+	selected := m.selectedTTLLocked()
+	for i, row := range rows {
+		if row.ttl != selected {
+			continue
+		}
+		if i < start {
+			m.rowOffset = i
+		} else if i >= end {
+			m.rowOffset = i - (end - start) + 1
+		}
+		return
+	}
+}
+
+// handleMouse selects the clicked hop and opens its detail panel, sorts by
+// the clicked column header (a second click restores hop order), and
+// scrolls the hop list with the wheel.
+func (m *MTRModel) handleMouse(msg tea.MouseMsg) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.copyView != "" || m.showChart || m.heatmap != heatmapOff {
+		return
+	}
+	layout := m.layoutLocked()
+	rows, start, end, _ := m.rowWindowLocked(layout)
+
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.rowOffset = max(start-1, 0)
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.rowOffset = min(start+1, len(rows)-(end-start))
+	case msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionPress:
+	case msg.Y == headerLine:
+		title := layout.columnAt(msg.X)
+		switch {
+		case title == m.sortColumn || title == "Hop":
+			m.sortColumn = ""
+		case sortValue(title) != nil:
+			m.sortColumn = title
+		}
+	case msg.Y >= firstRowLine && start+msg.Y-firstRowLine < end:
+		m.selectedTTL = rows[start+msg.Y-firstRowLine].ttl
+		m.showDetail = true
+	}
+}
+
+// clipLine cuts styled lines at the terminal width so indicators past the
+// last column don't wrap. Must be called with lock held.
+func (m *MTRModel) clipLine(line string) string {
+	if m.width <= 0 {
+		return line
+	}
+	clip := lipgloss.NewStyle().MaxWidth(m.width)
+	lines := strings.Split(line, "\n")
+	for i, l := range lines {
+		if lipgloss.Width(l) > m.width {
+			lines[i] = clip.Render(l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fitHostParts joins host parts into at most width columns. Trailing
+// annotations are dropped first; a name that still doesn't fit is shortened
+// in the middle so both its start and its domain stay visible.
+func fitHostParts(plainParts, styledParts []string, width int) (plain, styled string) {
+	for n := len(plainParts); n > 0; n-- {
+		plain = strings.Join(plainParts[:n], " ")
+		if len(plain) <= width {
+			return plain, strings.Join(styledParts[:n], " ")
+		}
+	}
+	if len(plainParts) == 0 {
+		return "", ""
+	}
+	plain = truncateMiddle(plainParts[0], width)
+	return plain, hopStyle.Render(plain)
+}
+
+// truncateMiddle shortens s to width by replacing its middle with "...".
+func truncateMiddle(s string, width int) string {
+	if len(s) <= width {
+		return s
+	}
+	if width <= 3 {
+		return s[:width]
+	}
+	head := (width - 2) / 2
+	tail := width - 3 - head
+	return s[:head] + "..." + s[len(s)-tail:]
+}
+
 // View implements tea.Model.
 func (m *MTRModel) View() string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if m.showHelp {
+		return overlayCenter(m.viewLocked(), renderHelp(mtrHelp), m.width, m.height)
+	}
+	return m.viewLocked()
+}
+
+// helpShown reports whether the help overlay is open.
+func (m *MTRModel) helpShown() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.showHelp
+}
+
+// viewLocked renders the MTR view. Must be called with lock held.
+func (m *MTRModel) viewLocked() string {
+	// Copy mode: show the frozen plain-text table until toggled off
+	if m.copyView != "" {
+		return m.copyView + "\nCOPY MODE - select the table above to copy it (hold Shift to select with the mouse). Press 'c' or Esc to return to the live view"
+	}
+
+	// RTT chart of the selected hop replaces the table
+	if m.showChart {
+		if stats, ok := m.stats[m.selectedTTLLocked()]; ok {
+			return m.formatRTTChart(stats)
+		}
+	}
+
+	// Heatmap of every hop over time replaces the table
+	if m.heatmap != heatmapOff {
+		return m.formatHeatmap(time.Now())
+	}
+
 	var b strings.Builder
 
 	// Title
-	title := fmt.Sprintf("gtr → %s (%s)", m.target, m.targetIP)
+	title := fmt.Sprintf("gtr %s %s (%s)", glyphs.Arrow, m.target, m.targetIP)
 	b.WriteString(titleStyle.Render(title))
 	b.WriteString("\n\n")
 
-	// Header (mtr-style columns)
-	colHost := m.getHostColumnWidth()
-	header := fmt.Sprintf("%-*s %-*s %*s %*s %*s %*s %*s %*s %*s %*s %s",
-		colHop, "Hop",
-		colHost, "Host",
-		colLoss, "Loss%",
-		colSnt, "Snt",
-		colRecv, "Recv",
-		colBest, "Best",
-		colAvg, "Avg",
-		colWrst, "Wrst",
-		colLast, "Last",
-		colStdDev, "StDev",
-		"Graph")
+	// Header (mtr-style columns, fitted to the terminal width)
+	layout := m.layoutLocked()
+	header := fmt.Sprintf("%-*s %-*s %*s", colHop, "Hop", layout.hostWidth, "Host", colLoss, m.columnTitle("Loss%"))
+	for _, col := range layout.columns {
+		if col.left {
+			header += " " + m.columnTitle(col.title)
+		} else {
+			header += fmt.Sprintf(" %*s", col.width, m.columnTitle(col.title))
+		}
+	}
 	b.WriteString(headerStyle.Render(header))
 	b.WriteString("\n")
-	lineWidth := colHop + 1 + colHost + 1 + colLoss + 1 + colSnt + 1 + colRecv + 1 + colBest + 1 + colAvg + 1 + colWrst + 1 + colLast + 1 + colStdDev + 10
-	b.WriteString(strings.Repeat("─", lineWidth))
+	lineWidth := layout.lineWidth()
+	b.WriteString(strings.Repeat(glyphs.HLine, lineWidth))
 	b.WriteString("\n")
 
-	// Hops (ordered by TTL)
-	orderedStats := m.getOrderedStatsLocked()
-	for _, stats := range orderedStats {
-		b.WriteString(m.formatStatsRow(stats))
+	// Hops, scrolled to fit the terminal height
+	rows, start, end, detail := m.rowWindowLocked(layout)
+	for _, row := range rows[start:end] {
+		b.WriteString(row.text)
 		b.WriteString("\n")
-		if m.showECMP && stats.HasECMP() {
-			b.WriteString(m.formatECMPSubRows(stats))
-		}
 	}
 
+	// Detail panel for the selected hop
+	b.WriteString(detail)
+
 	// Status bar
 	b.WriteString("\n")
-	b.WriteString(strings.Repeat("─", lineWidth))
+	b.WriteString(strings.Repeat(glyphs.HLine, lineWidth))
 	b.WriteString("\n")
-	b.WriteString(m.renderStatusBar())
+	b.WriteString(m.renderStatusBar(layout, len(rows), start, end))
 
-	// Help
+	// Help, or the export or target prompt in its place
 	b.WriteString("\n")
+	if m.export.active {
+		b.WriteString(m.export.view())
+		return b.String()
+	}
+	if m.editTarget {
+		b.WriteString(fmt.Sprintf("New target: %s_  (enter trace it, esc cancel)", m.targetInput))
+		return b.String()
+	}
 	if m.paused {
 		b.WriteString(timeoutStyle.Render("PAUSED"))
 		b.WriteString(" | ")
@@ -313,22 +946,219 @@ func (m *MTRModel) View() string {
 	case DisplayModeBoth:
 		modeStr = "[Both]"
 	}
-	b.WriteString(fmt.Sprintf("%s Press 'e' expand ECMP, 'n' DNS/IP, 'p' pause, 'r' reset, 'q' quit", modeStr))
+	b.WriteString(fmt.Sprintf("%s Press 'enter' hop details, 'g' RTT chart, 'h' heatmap, 'e' expand ECMP, 'n' DNS/IP, 'c' copy, 's' snapshot, 'E' export, 'p' pause, 'r' reset, '?' help, 'q' quit", modeStr))
+
+	return b.String()
+}
+
+// ToggleCopyMode freezes the current table as plain text, or returns to the live view.
+func (m *MTRModel) ToggleCopyMode() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.copyView != "" {
+		m.copyView = ""
+		return
+	}
+	m.copyView = m.plainTextLocked()
+}
+
+// PlainText renders the current table as fixed-width ASCII without colors,
+// spinner or graphs, so it can be pasted into tickets with columns intact.
+func (m *MTRModel) PlainText() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.plainTextLocked()
+}
+
+// WriteSnapshot writes the current statistics, as the plain-text report
+// printed on quit, to a timestamped file in dir and returns its path.
+func (m *MTRModel) WriteSnapshot(dir string) (string, error) {
+	m.mu.RLock()
+	report := m.plainTextLocked()
+	name := filepath.Join(dir, snapshotFilename(m.target, time.Now()))
+	m.mu.RUnlock()
+
+	if err := os.WriteFile(name, []byte(report), 0o644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// stepInterval lengthens or shortens the time between cycles by one step.
+func (m *MTRModel) stepInterval(longer bool) {
+	m.mu.Lock()
+	set := m.controls.SetInterval
+	if set == nil {
+		m.mu.Unlock()
+		return
+	}
+	m.interval = stepInterval(m.interval, longer)
+	interval := m.interval
+	m.mu.Unlock()
+	set(interval)
+}
+
+// cycleProbeSize switches probes to the next probe size.
+func (m *MTRModel) cycleProbeSize() {
+	m.mu.Lock()
+	set := m.controls.SetProbeSize
+	if set == nil {
+		m.mu.Unlock()
+		return
+	}
+	m.probeSize = nextProbeSize(m.probeSize)
+	size := m.probeSize
+	m.mu.Unlock()
+	set(size)
+}
+
+// editingTarget reports whether the 't' prompt is open.
+func (m *MTRModel) editingTarget() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.editTarget
+}
+
+// handleTargetKey passes msg to the 't' prompt and, once a target is
+// entered, asks for tracing to switch to it.
+func (m *MTRModel) handleTargetKey(msg tea.KeyMsg) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.editTarget = false
+	case tea.KeyEnter:
+		m.editTarget = false
+		target := strings.TrimSpace(m.targetInput)
+		if target == "" {
+			return
+		}
+		select {
+		case m.retargetCh <- target:
+			m.notice = "Switching to " + target + "..."
+		default:
+			m.notice = "Already switching target"
+		}
+	default:
+		m.targetInput = editLine(m.targetInput, msg)
+	}
+}
+
+// exporting reports whether the export overlay is open.
+func (m *MTRModel) exporting() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.export.active
+}
+
+// handleExportKey passes msg to the export overlay and, once the export is
+// confirmed, writes the statistics table to the chosen path.
+func (m *MTRModel) handleExportKey(msg tea.KeyMsg) {
+	m.mu.Lock()
+	confirmed := m.export.handleKey(msg)
+	path, format := m.export.path, m.export.selected()
+	m.mu.Unlock()
+	if !confirmed {
+		return
+	}
+
+	err := writeExportFile(path, func(w io.Writer) error {
+		return m.writeExport(w, format)
+	})
+	m.mu.Lock()
+	m.notice = exportNotice(path, format, err)
+	m.mu.Unlock()
+}
+
+// snapshotFilename returns the file name of a snapshot of target taken at
+// t, with characters that are unsafe in file names replaced.
+func snapshotFilename(target string, t time.Time) string {
+	return fmt.Sprintf("gtrace-mtr-%s-%s.txt", fileSafe(target), t.Format("20060102-150405"))
+}
+
+// plainTextLocked renders the plain-text table. Must be called with lock held.
+func (m *MTRModel) plainTextLocked() string {
+	var b strings.Builder
+	colHost := m.getHostColumnWidth()
+
+	fmt.Fprintf(&b, "gtrace to %s (%s), %d cycles, %s\n\n",
+		m.target, m.targetIP, m.cycles, time.Now().Format("2006-01-02 15:04:05"))
+
+	header := fmt.Sprintf("%-*s %-*s %*s %*s %*s %*s %*s %*s %*s %*s",
+		colHop, "Hop",
+		colHost, "Host",
+		colLoss, "Loss%",
+		colSnt, "Snt",
+		colRecv, "Recv",
+		colBest, "Best",
+		colAvg, "Avg",
+		colWrst, "Wrst",
+		colLast, "Last",
+		colStdDev, "StDev")
+	b.WriteString(header)
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat("-", len(header)))
+	b.WriteString("\n")
+
+	for _, stats := range m.getOrderedStatsLocked() {
+		host := "*"
+		if stats.PrimaryIP() != nil {
+			plainParts, _ := m.hostParts(stats)
+			host, _ = fitHostParts(plainParts, plainParts, colHost)
+		}
+
+		line := fmt.Sprintf("%-*d %-*s %*.1f%% %*d %*d %s %s %s %s %s",
+			colHop, stats.TTL,
+			colHost, host,
+			colLoss-1, stats.LossPercent(),
+			colSnt, stats.Sent,
+			colRecv, stats.Recv,
+			plainMs(stats.FilteredBest, colBest),
+			plainMs(stats.FilteredAvgRTT(), colAvg),
+			plainMs(stats.WorstRTT, colWrst),
+			plainMs(stats.LastRTT, colLast),
+			plainMs(stats.FilteredStdDev(), colStdDev))
+		for _, f := range rowFlags(stats) {
+			line += " " + f.text
+		}
+		if tag := stats.LossPatternTag(); tag != "" {
+			line += " " + tag
+		}
+		if stats.ControlPlaneLoss() {
+			line += " " + ControlPlaneLossNote
+		}
+		b.WriteString(strings.TrimRight(line, " "))
+		b.WriteString("\n")
+	}
 
 	return b.String()
 }
 
-// formatStatsRow formats a single stats row.
-func (m *MTRModel) formatStatsRow(stats *HopStats) string {
+// plainMs formats a duration in milliseconds right-aligned to width, or "-" if zero.
+func plainMs(d time.Duration, width int) string {
+	if d <= 0 {
+		return fmt.Sprintf("%*s", width, "-")
+	}
+	return fmt.Sprintf("%*.1f", width, float64(d)/float64(time.Millisecond))
+}
+
+// formatStatsRow formats a single stats row with the columns of layout.
+func (m *MTRModel) formatStatsRow(stats *HopStats, layout tableLayout) string {
 	var b strings.Builder
 
 	// TTL - pad then style
 	ttlStr := fmt.Sprintf("%-*d", colHop, stats.TTL)
-	b.WriteString(hopStyle.Render(ttlStr))
+	if m.showDetail && stats.TTL == m.selectedTTLLocked() {
+		ttlStr = fmt.Sprintf("%-*s", colHop, fmt.Sprintf("%d%s", stats.TTL, glyphs.Arrow))
+		b.WriteString(titleStyle.Render(ttlStr))
+	} else {
+		b.WriteString(hopStyle.Render(ttlStr))
+	}
 	b.WriteString(" ")
 
 	// Host info - build styled string with proper padding
-	b.WriteString(m.formatHostColumn(stats))
+	b.WriteString(m.formatHostColumn(stats, layout.hostWidth))
 	b.WriteString(" ")
 
 	// Loss% - pad then style
@@ -339,139 +1169,122 @@ func (m *MTRModel) formatStatsRow(stats *HopStats) string {
 	} else {
 		b.WriteString(hopStyle.Render(lossStr))
 	}
-	b.WriteString(" ")
-
-	// Sent - no styling needed
-	b.WriteString(fmt.Sprintf("%*d", colSnt, stats.Sent))
-	b.WriteString(" ")
 
-	// Recv - no styling needed
-	b.WriteString(fmt.Sprintf("%*d", colRecv, stats.Recv))
-	b.WriteString(" ")
-
-	// Best RTT - pad then style
-	if stats.BestRTT > 0 {
-		bestStr := fmt.Sprintf("%*.1f", colBest, float64(stats.BestRTT)/float64(time.Millisecond))
-		b.WriteString(rttStyle.Render(bestStr))
-	} else {
-		b.WriteString(timeoutStyle.Render(fmt.Sprintf("%*s", colBest, "-")))
+	// Statistics columns that fit
+	for _, col := range layout.columns {
+		b.WriteString(" ")
+		b.WriteString(col.cell(m, stats))
 	}
-	b.WriteString(" ")
 
-	// Avg RTT - pad then style
-	avg := stats.AvgRTT()
-	if avg > 0 {
-		avgStr := fmt.Sprintf("%*.1f", colAvg, float64(avg)/float64(time.Millisecond))
-		b.WriteString(rttStyle.Render(avgStr))
-	} else {
-		b.WriteString(timeoutStyle.Render(fmt.Sprintf("%*s", colAvg, "-")))
+	// Indicators
+	for _, f := range rowFlags(stats) {
+		b.WriteString(" ")
+		b.WriteString(f.style.Render(f.text))
 	}
-	b.WriteString(" ")
 
-	// Worst RTT - pad then style
-	if stats.WorstRTT > 0 {
-		wrstStr := fmt.Sprintf("%*.1f", colWrst, float64(stats.WorstRTT)/float64(time.Millisecond))
-		b.WriteString(rttStyle.Render(wrstStr))
-	} else {
-		b.WriteString(timeoutStyle.Render(fmt.Sprintf("%*s", colWrst, "-")))
-	}
-	b.WriteString(" ")
+	return b.String()
+}
 
-	// Last RTT - pad then style
-	if stats.LastRTT > 0 {
-		lastStr := fmt.Sprintf("%*.1f", colLast, float64(stats.LastRTT)/float64(time.Millisecond))
-		b.WriteString(rttStyle.Render(lastStr))
-	} else {
-		b.WriteString(timeoutStyle.Render(fmt.Sprintf("%*s", colLast, "-")))
-	}
-	b.WriteString(" ")
+// rowFlag is a bracketed indicator shown after a hop row.
+type rowFlag struct {
+	text  string
+	style lipgloss.Style
+}
 
-	// StdDev - pad then style
-	stdDev := stats.StdDev()
-	if stdDev > 0 {
-		b.WriteString(rttStyle.Render(fmt.Sprintf("%*.1f", colStdDev, float64(stdDev)/float64(time.Millisecond))))
-	} else {
-		b.WriteString(timeoutStyle.Render(fmt.Sprintf("%*s", colStdDev, "-")))
+// rowFlags returns the indicators for a hop row, in display order.
+func rowFlags(stats *HopStats) []rowFlag {
+	var flags []rowFlag
+
+	// NAT indicator
+	if stats.NAT {
+		flags = append(flags, rowFlag{"[NAT]", timeoutStyle})
 	}
-	b.WriteString(" ")
 
-	// Sparkline
-	if len(stats.RTTHistory) > 0 {
-		b.WriteString(m.renderSparkline(stats.RTTHistory))
+	// MTU indicator
+	if stats.MTU > 0 {
+		flags = append(flags, rowFlag{fmt.Sprintf("[MTU:%d]", stats.MTU), timeoutStyle})
 	}
 
 	// TTL manipulation indicator
 	if stats.TTLManipulated {
-		b.WriteString(" ")
-		b.WriteString(timeoutStyle.Render("[^TTL]"))
+		flags = append(flags, rowFlag{"[^TTL]", timeoutStyle})
 	}
 
 	// ICMP code indicator (for Dest Unreachable codes)
 	if stats.LastICMPType == 3 {
-		indicator := icmpCodeIndicator(stats.LastICMPCode)
-		if indicator != "" {
-			b.WriteString(" ")
-			b.WriteString(timeoutStyle.Render(indicator))
+		if indicator := icmpCodeIndicator(stats.LastICMPCode); indicator != "" {
+			flags = append(flags, rowFlag{indicator, timeoutStyle})
 		}
 	}
 
 	// Route flap indicator
 	if stats.HasRouteFlap() {
-		b.WriteString(" ")
-		b.WriteString(timeoutStyle.Render("[!]"))
+		flags = append(flags, rowFlag{"[!]", timeoutStyle})
 	}
 
 	// Rate-limit indicator
 	if stats.RateLimited {
-		b.WriteString(" ")
-		b.WriteString(timeoutStyle.Render("[RL?]"))
+		flags = append(flags, rowFlag{"[RL?]", timeoutStyle})
 	}
 
 	// MPLS indicator
 	if len(stats.MPLS) > 0 {
-		b.WriteString(" ")
-		b.WriteString(mplsStyle.Render("[MPLS]"))
+		flags = append(flags, rowFlag{"[MPLS]", mplsStyle})
+	}
+
+	// Segment routing indicator
+	if sr := stats.SegmentRouting; sr != nil {
+		tag := "[SR]"
+		if sr.Dataplane == hop.SRv6 {
+			tag = "[SRv6]"
+		}
+		flags = append(flags, rowFlag{tag, mplsStyle})
 	}
 
 	// Decode indicators (transport header info)
-	if stats.LastTransportInfo != nil {
-		ti := stats.LastTransportInfo
+	if ti := stats.LastTransportInfo; ti != nil {
 		if ti.DSCP != 0 {
-			b.WriteString(" ")
-			b.WriteString(asnStyle.Render(fmt.Sprintf("[DSCP:%d]", ti.DSCP)))
+			flags = append(flags, rowFlag{fmt.Sprintf("[DSCP:%d]", ti.DSCP), asnStyle})
 		}
 		if ti.DF {
-			b.WriteString(" ")
-			b.WriteString(asnStyle.Render("[DF]"))
+			flags = append(flags, rowFlag{"[DF]", asnStyle})
 		}
 		if ti.TCPFlagsStr != "" {
-			b.WriteString(" ")
-			b.WriteString(asnStyle.Render(fmt.Sprintf("[TCP:%s]", ti.TCPFlagsStr)))
+			flags = append(flags, rowFlag{fmt.Sprintf("[TCP:%s]", ti.TCPFlagsStr), asnStyle})
 		}
 	}
 
-	return b.String()
+	return flags
 }
 
-// formatHostColumn formats the host column with proper padding and styling.
+// formatHostColumn formats the host column padded or shortened to width.
 // This handles ANSI codes correctly by padding plain text first.
 // Display modes:
 //   - DisplayModeHostname: hostname [ASN] (IP) - like real mtr default
 //   - DisplayModeIP: IP [ASN] (hostname)
 //   - DisplayModeBoth: IP [ASN] (hostname) - legacy behavior
-func (m *MTRModel) formatHostColumn(stats *HopStats) string {
-	colWidth := m.getHostColumnWidth()
-
-	displayIP := stats.PrimaryIP()
-	if displayIP == nil {
+func (m *MTRModel) formatHostColumn(stats *HopStats, width int) string {
+	if stats.PrimaryIP() == nil {
 		// Timeout - pad asterisk to full width
-		padded := fmt.Sprintf("%-*s", colWidth, "*")
+		padded := fmt.Sprintf("%-*s", width, "*")
 		return timeoutStyle.Render(padded)
 	}
 
-	// Build plain text first to calculate length
-	var plainParts []string
-	var styledParts []string
+	plainParts, styledParts := m.hostParts(stats)
+	plainText, styled := fitHostParts(plainParts, styledParts, width)
+
+	// Pad to the column width
+	if padding := width - len(plainText); padding > 0 {
+		styled += strings.Repeat(" ", padding)
+	}
+
+	return styled
+}
+
+// hostParts returns the host column pieces for the current display mode,
+// both as plain text and styled. Must be called with a responding hop.
+func (m *MTRModel) hostParts(stats *HopStats) (plainParts, styledParts []string) {
+	displayIP := stats.PrimaryIP()
 
 	enrichment := stats.PrimaryEnrichment()
 	ipStr := displayIP.String()
@@ -541,6 +1354,26 @@ func (m *MTRModel) formatHostColumn(stats *HopStats) string {
 		}
 	}
 
+	// Internet Exchange peering LAN
+	if enrichment.IX != "" {
+		ixStr := "[IX:" + enrichment.IX + "]"
+		plainParts = append(plainParts, ixStr)
+		styledParts = append(styledParts, asnStyle.Render(ixStr))
+	}
+
+	// IANA special-purpose range
+	if enrichment.Special != "" {
+		specialStr := "[SPECIAL:" + enrichment.Special + "]"
+		plainParts = append(plainParts, specialStr)
+		styledParts = append(styledParts, timeoutStyle.Render(specialStr))
+	}
+
+	// Blocklist listings (--reputation)
+	for _, l := range enrichment.Reputation {
+		plainParts = append(plainParts, l.Tag())
+		styledParts = append(styledParts, timeoutStyle.Render(l.Tag()))
+	}
+
 	// ECMP indicator with classification
 	if stats.HasECMP() {
 		var ecmpStr string
@@ -556,25 +1389,306 @@ func (m *MTRModel) formatHostColumn(stats *HopStats) string {
 		styledParts = append(styledParts, asnStyle.Render(ecmpStr))
 	}
 
-	// Calculate plain text length (with spaces between parts)
-	plainText := strings.Join(plainParts, " ")
-	plainLen := len(plainText)
+	return plainParts, styledParts
+}
 
-	// Truncate if too long
-	if plainLen > colWidth {
-		// Rebuild with truncation
-		truncated := plainText[:colWidth-3] + "..."
-		return hopStyle.Render(truncated)
+// selectedTTLLocked returns the TTL of the hop shown in the detail panel,
+// falling back to the first hop when none is selected or the selected hop
+// was reset. Must be called with lock held.
+func (m *MTRModel) selectedTTLLocked() int {
+	if _, ok := m.stats[m.selectedTTL]; ok {
+		return m.selectedTTL
 	}
+	first := 0
+	for ttl := range m.stats {
+		if first == 0 || ttl < first {
+			first = ttl
+		}
+	}
+	return first
+}
 
-	// Build styled output with padding
-	styled := strings.Join(styledParts, " ")
-	padding := colWidth - plainLen
-	if padding > 0 {
-		styled += strings.Repeat(" ", padding)
+// moveSelection selects the previous (delta < 0) or next hop for the detail panel.
+func (m *MTRModel) moveSelection(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ordered := m.displayStatsLocked()
+	current := m.selectedTTLLocked()
+	for i, stats := range ordered {
+		if stats.TTL != current {
+			continue
+		}
+		if j := i + delta; j >= 0 && j < len(ordered) {
+			m.selectedTTL = ordered[j].TTL
+		}
+		m.scrollToSelectedLocked()
+		return
 	}
+}
 
-	return styled
+// formatHopDetail renders the detail panel of a hop: its flags, when its
+// address last changed, every address seen with its probe stats and
+// enrichment, the BGP prefix and origin AS path, the MPLS label stack, and
+// the most recent probe results.
+func (m *MTRModel) formatHopDetail(stats *HopStats) string {
+	var b strings.Builder
+	indent := strings.Repeat(" ", colHop+1)
+
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Hop %d details", stats.TTL)))
+	b.WriteString("\n")
+
+	if flags := rowFlags(stats); len(flags) > 0 {
+		b.WriteString(indent + "Flags:")
+		for _, f := range flags {
+			b.WriteString(" " + f.style.Render(f.text))
+		}
+		b.WriteString("\n")
+	}
+
+	if changed, compared := m.stability.HopChanges(stats.TTL); compared > 0 {
+		b.WriteString(indent + fmt.Sprintf("Stability: %.0f%% (address changed in %d of %d cycles)\n",
+			m.stability.HopScore(stats.TTL), changed, compared))
+	}
+	if !stats.LastChange.IsZero() {
+		b.WriteString(indent + fmt.Sprintf("Address last changed: %s (%v ago)\n",
+			stats.LastChange.Format("15:04:05"), time.Since(stats.LastChange).Round(time.Second)))
+	}
+	if stats.Outliers > 0 {
+		b.WriteString(indent + fmt.Sprintf("RTT outliers: %d filtered from Best/Avg/StDev (raw: Best %.1f  Avg %.1f  StDev %.1f ms)\n",
+			stats.Outliers, msFloat(stats.BestRTT), msFloat(stats.AvgRTT()), msFloat(stats.StdDev())))
+	}
+	if stats.ControlPlaneLoss() {
+		b.WriteString(indent + fmt.Sprintf("Loss: %s, final hop lost in %d of its %d loss cycles\n",
+			ControlPlaneLossNote, stats.SharedLossCycles, stats.LossCycles))
+	}
+
+	ips := stats.SortedIPs()
+	if len(ips) == 0 {
+		b.WriteString(indent + timeoutStyle.Render("No response"))
+		b.WriteString("\n")
+	}
+
+	for _, info := range ips {
+		e := info.Enrichment
+		line := ipStyle.Render(info.IP.String())
+		if e.Hostname != "" {
+			line += " " + hostnameStyle.Render("("+e.Hostname+")")
+		}
+		line += fmt.Sprintf(" %d probes", info.Count)
+		if stats.Recv > 0 && len(ips) > 1 {
+			line += fmt.Sprintf(" (%.0f%%)", float64(info.Count)/float64(stats.Recv)*100)
+		}
+		b.WriteString(indent + line + "\n")
+		b.WriteString(indent + "  " + rttStyle.Render(fmt.Sprintf("Best %.1f  Avg %.1f  Wrst %.1f  Last %.1f ms",
+			msFloat(info.RTT.Best), msFloat(info.RTT.Avg(info.Count)), msFloat(info.RTT.Worst), msFloat(info.RTT.Last))) + "\n")
+
+		var facts []string
+		if e.ASN > 0 {
+			facts = append(facts, strings.TrimSpace(fmt.Sprintf("AS%d %s", e.ASN, e.ASOrg)))
+		}
+		if loc := strings.Trim(e.City+", "+e.Country, ", "); loc != "" {
+			facts = append(facts, loc)
+		}
+		if e.IX != "" {
+			facts = append(facts, "IX: "+e.IX)
+		}
+		if e.Special != "" {
+			facts = append(facts, "Special: "+e.Special)
+		}
+		for _, l := range e.Reputation {
+			facts = append(facts, "Listed: "+l.List)
+		}
+		if len(facts) > 0 {
+			b.WriteString(indent + "  " + asnStyle.Render(strings.Join(facts, " "+glyphs.VLine+" ")) + "\n")
+		}
+		if e.InterfaceHint != "" {
+			b.WriteString(indent + "  Interface (from PTR): " + e.InterfaceHint + "\n")
+		}
+		if e.LocationHint != "" {
+			b.WriteString(indent + "  Location (from PTR): " + e.LocationHint + "\n")
+		}
+		if e.BGP != nil {
+			b.WriteString(indent + "  Prefix: " + bgpPrefixSummary(e.BGP) + "\n")
+			if len(e.BGP.ASPath) > 0 {
+				b.WriteString(indent + "  " + asnStyle.Render("AS path: "+e.BGP.PathString()) + "\n")
+			}
+		}
+	}
+
+	for _, label := range stats.MPLS {
+		b.WriteString(indent + mplsStyle.Render("MPLS: "+label.String()) + "\n")
+	}
+
+	if len(stats.Recent) > 0 {
+		b.WriteString(m.formatRecentProbes(stats, indent))
+	}
+
+	return b.String()
+}
+
+// recentProbesPerLine is the number of probe results per detail panel line.
+const recentProbesPerLine = 10
+
+// formatRecentProbes renders the last probe results of a hop, oldest first,
+// marking lost probes and replies from an address other than the primary one.
+func (m *MTRModel) formatRecentProbes(stats *HopStats, indent string) string {
+	var b strings.Builder
+	primary := stats.PrimaryIP()
+
+	b.WriteString(fmt.Sprintf("%sLast %d probes (ms, %s lost, * other address):\n", indent, len(stats.Recent), glyphs.Fail))
+	for i, sample := range stats.Recent {
+		if i%recentProbesPerLine == 0 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(indent + " ")
+		}
+		switch {
+		case sample.Lost:
+			b.WriteString(timeoutStyle.Render(fmt.Sprintf("%7s", glyphs.Fail)))
+		case primary != nil && !sample.IP.Equal(primary):
+			b.WriteString(asnStyle.Render(fmt.Sprintf("%6.1f*", msFloat(sample.RTT))))
+		default:
+			b.WriteString(rttStyle.Render(fmt.Sprintf("%6.1f ", msFloat(sample.RTT))))
+		}
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// msFloat converts a duration to fractional milliseconds.
+func msFloat(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// RTT chart layout
+const (
+	chartRows       = 10 // Height of the RTT chart in lines
+	chartLabelWidth = 10 // Width of the y-axis labels, e.g. " 123.4ms "
+	chartScrollStep = 10 // Samples scrolled per key press
+)
+
+// chartPlotWidth returns the number of samples the RTT chart shows at once.
+// Must be called with lock held.
+func (m *MTRModel) chartPlotWidth() int {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+	return max(width-chartLabelWidth-1, 10)
+}
+
+// scrollChartLocked scrolls the RTT chart back (delta > 0) or forward in
+// time. Must be called with lock held.
+func (m *MTRModel) scrollChartLocked(delta int) {
+	stats, ok := m.stats[m.selectedTTLLocked()]
+	if !ok {
+		return
+	}
+	maxScroll := max(len(stats.Samples)-m.chartPlotWidth(), 0)
+	m.chartScroll = min(max(m.chartScroll+delta*chartScrollStep, 0), maxScroll)
+}
+
+// formatRTTChart renders the full-width RTT chart of a hop: one column per
+// probe, oldest on the left, with lost probes marked on the baseline.
+// Must be called with lock held.
+func (m *MTRModel) formatRTTChart(stats *HopStats) string {
+	var b strings.Builder
+
+	title := fmt.Sprintf("gtr %s %s (%s)", glyphs.Arrow, m.target, m.targetIP)
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	header := fmt.Sprintf("Hop %d RTT history", stats.TTL)
+	if ip := stats.PrimaryIP(); ip != nil {
+		header += ": " + ip.String()
+		if e := stats.PrimaryEnrichment(); e.Hostname != "" {
+			header += " (" + e.Hostname + ")"
+		}
+	}
+	b.WriteString(headerStyle.Render(header))
+	b.WriteString("\n\n")
+
+	// Visible window, scrolled back from the newest sample
+	plotWidth := m.chartPlotWidth()
+	end := len(stats.Samples) - min(m.chartScroll, max(len(stats.Samples)-plotWidth, 0))
+	start := max(end-plotWidth, 0)
+	window := stats.Samples[start:end]
+
+	var maxRTT, sumRTT time.Duration
+	lost := 0
+	for _, sample := range window {
+		if sample.Lost {
+			lost++
+			continue
+		}
+		sumRTT += sample.RTT
+		maxRTT = max(maxRTT, sample.RTT)
+	}
+
+	// Bar heights in eighths of a line
+	levels := make([]int, len(window))
+	for i, sample := range window {
+		if sample.Lost || maxRTT == 0 {
+			continue
+		}
+		levels[i] = max(int(float64(sample.RTT)/float64(maxRTT)*chartRows*8+0.5), 1)
+	}
+
+	labelPad := strings.Repeat(" ", chartLabelWidth)
+	for row := 0; row < chartRows; row++ {
+		switch row {
+		case 0:
+			b.WriteString(fmt.Sprintf("%*.1fms ", chartLabelWidth-3, float64(maxRTT)/float64(time.Millisecond)))
+		case chartRows - 1:
+			b.WriteString(fmt.Sprintf("%*.1fms ", chartLabelWidth-3, 0.0))
+		default:
+			b.WriteString(labelPad)
+		}
+		b.WriteString(hopStyle.Render(glyphs.VLine))
+
+		floor := (chartRows - 1 - row) * 8
+		var bars strings.Builder
+		for i, sample := range window {
+			switch cell := levels[i] - floor; {
+			case sample.Lost && row == chartRows-1:
+				b.WriteString(rttStyle.Render(bars.String()))
+				bars.Reset()
+				b.WriteString(timeoutStyle.Render(glyphs.Fail))
+			case cell >= 8:
+				bars.WriteRune(sparkChars[len(sparkChars)-1])
+			case cell > 0:
+				bars.WriteRune(sparkChars[cell-1])
+			default:
+				bars.WriteString(" ")
+			}
+		}
+		b.WriteString(rttStyle.Render(bars.String()))
+		b.WriteString("\n")
+	}
+	b.WriteString(labelPad)
+	b.WriteString(hopStyle.Render(glyphs.BottomLeft + strings.Repeat(glyphs.HLine, plotWidth)))
+	b.WriteString("\n\n")
+
+	// Summary of the visible window
+	parts := []string{fmt.Sprintf("Samples %d-%d of %d", start+1, end, len(stats.Samples))}
+	if len(window) == 0 {
+		parts[0] = "No samples yet"
+	} else {
+		parts = append(parts, fmt.Sprintf("Loss %.1f%%", float64(lost)/float64(len(window))*100))
+		if recv := len(window) - lost; recv > 0 {
+			parts = append(parts, fmt.Sprintf("Avg %.1fms", float64(sumRTT/time.Duration(recv))/float64(time.Millisecond)))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("History %d", stats.HistorySize()))
+	b.WriteString(statusStyle.Render(strings.Join(parts, " "+glyphs.VLine+" ")))
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("%s lost probe. Press 'h'/'l' scroll, 'k'/'j' previous/next hop, 'g' or Esc back, 'q' quit",
+		timeoutStyle.Render(glyphs.Fail)))
+
+	return b.String()
 }
 
 // formatECMPSubRows renders sub-rows for non-primary IPs at an ECMP hop.
@@ -594,9 +1708,9 @@ func (m *MTRModel) formatECMPSubRows(stats *HopStats) string {
 		b.WriteString(indent)
 
 		// Tree connector
-		connector := "├─ "
+		connector := glyphs.Branch + glyphs.HLine + " "
 		if i == len(secondary)-1 {
-			connector = "└─ "
+			connector = glyphs.LastBranch + glyphs.HLine + " "
 		}
 		b.WriteString(hopStyle.Render(connector))
 
@@ -617,7 +1731,7 @@ func (m *MTRModel) formatECMPSubRows(stats *HopStats) string {
 
 		// Probe count
 		b.WriteString(" ")
-		b.WriteString(hopStyle.Render(fmt.Sprintf("×%d", info.Count)))
+		b.WriteString(hopStyle.Render(fmt.Sprintf("%s%d", glyphs.Times, info.Count)))
 
 		b.WriteString("\n")
 	}
@@ -662,11 +1776,31 @@ func (m *MTRModel) renderSparkline(rtts []time.Duration) string {
 }
 
 // renderStatusBar renders the status bar.
-func (m *MTRModel) renderStatusBar() string {
+func (m *MTRModel) renderStatusBar(layout tableLayout, rows, start, end int) string {
 	parts := []string{
 		fmt.Sprintf("Cycles: %d", m.cycles),
 		fmt.Sprintf("Hops: %d", len(m.stats)),
 	}
+	if m.controls.SetInterval != nil {
+		parts = append(parts, fmt.Sprintf("Every %v", m.interval))
+	}
+	if m.controls.SetProbeSize != nil {
+		parts = append(parts, fmt.Sprintf("%dB probes", m.probeSize))
+	}
+	if m.warmupLeft > 0 {
+		parts[0] = timeoutStyle.Render(fmt.Sprintf("Warm-up: cycle %d of %d, not counted", m.warmup-m.warmupLeft+1, m.warmup))
+	}
+
+	if end-start < rows {
+		parts = append(parts, fmt.Sprintf("Rows %d-%d of %d", start+1, end, rows))
+	}
+	if m.sortColumn != "" {
+		parts = append(parts, "Sorted by "+m.sortColumn)
+	}
+
+	if hidden := layout.hiddenLeft + layout.hiddenRight; hidden > 0 {
+		parts = append(parts, fmt.Sprintf("%d columns hidden, Left/Right scroll", hidden))
+	}
 
 	// Check for MPLS and ECMP
 	hasMPLS := false
@@ -682,17 +1816,27 @@ func (m *MTRModel) renderStatusBar() string {
 			break
 		}
 	}
+	if m.cycles > 1 {
+		parts = append(parts, fmt.Sprintf("Stability: %.0f%%", m.stability.Score()))
+	}
 	if hasMPLS {
 		parts = append(parts, mplsStyle.Render("MPLS"))
 	}
 	if hasECMP {
 		parts = append(parts, asnStyle.Render("ECMP"))
 	}
+	if m.notice != "" {
+		parts = append(parts, m.notice)
+	}
+	if m.lastEvent != "" {
+		parts = append(parts, timeoutStyle.Render(fmt.Sprintf("%s at %s, stats restarted",
+			m.lastEvent, m.lastEventAt.Format("15:04:05"))))
+	}
 
 	elapsed := time.Since(m.startTime).Round(time.Millisecond)
 	parts = append(parts, fmt.Sprintf("Time: %v", elapsed))
 
-	return statusStyle.Render(strings.Join(parts, " │ "))
+	return statusStyle.Render(strings.Join(parts, " "+glyphs.VLine+" "))
 }
 
 // updateRateLimitFlags recalculates rate-limit detection for all hops. Must be called with lock held.
@@ -776,12 +1920,23 @@ func (m *MTRModel) IsPaused() bool {
 	return m.paused
 }
 
-// RunMTR runs the MTR TUI program.
-func RunMTR(target, targetIP string, resultChan <-chan ProbeResultMsg, cycleChan <-chan CycleCompleteMsg, doneChan <-chan struct{}, resetChan chan<- struct{}) error {
+// RunMTR runs the MTR TUI program. When the program exits, the accumulated
+// statistics are written to w as a plain-text report so they survive in the
+// terminal scrollback. enrichChan, which may be nil, delivers enrichment
+// completed after the first probe of an address.
+func RunMTR(w io.Writer, target, targetIP string, historySize, warmup int, resultChan <-chan ProbeResultMsg, cycleChan <-chan CycleCompleteMsg, enrichChan <-chan EnrichmentMsg, doneChan <-chan struct{}, resetChan chan<- struct{}, controls MTRControls) error {
 	model := NewMTRModel(target, targetIP)
 	model.resetChan = resetChan
+	model.SetControls(controls)
+	var retargetCh chan string
+	if controls.Retarget != nil {
+		retargetCh = make(chan string, 1)
+		model.retargetCh = retargetCh
+	}
+	model.historySize = historySize
+	model.SetWarmup(warmup)
 
-	p := tea.NewProgram(model)
+	p := tea.NewProgram(model, tea.WithMouseCellMotion())
 
 	// Goroutine to receive results
 	go func() {
@@ -796,15 +1951,74 @@ func RunMTR(target, targetIP string, resultChan <-chan ProbeResultMsg, cycleChan
 				if !ok {
 					return
 				}
+				// Deliver the cycle's probes first: an invalid cycle resets
+				// the statistics and must not be followed by its own probes
+				for drained := false; !drained; {
+					select {
+					case result, ok := <-resultChan:
+						if !ok {
+							return
+						}
+						p.Send(result)
+					default:
+						drained = true
+					}
+				}
 				p.Send(cycle)
+			case e := <-enrichChan:
+				p.Send(e)
+			case target := <-retargetCh:
+				ip, err := controls.Retarget(target)
+				p.Send(TargetChangedMsg{Target: target, TargetIP: ip, Err: err})
 			case <-doneChan:
 				return
 			}
 		}
 	}()
 
-	_, err := p.Run()
-	return err
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	if model.HopCount() > 0 {
+		fmt.Fprint(w, model.PlainText())
+	}
+	return nil
+}
+
+// ReplayMTR runs the MTR TUI on the messages of a recorded session, or of
+// one streamed by a remote agent, received in order on feed: ProbeResultMsg, CycleCompleteMsg, EnrichmentMsg,
+// TargetChangedMsg and NoticeMsg. The TUI stays open once feed is closed,
+// until the user quits. Switching targets is not available.
+func ReplayMTR(w io.Writer, target, targetIP string, historySize, warmup int, feed <-chan tea.Msg, controls MTRControls) error {
+	model := NewMTRModel(target, targetIP)
+	controls.Retarget = nil
+	model.SetControls(controls)
+	model.historySize = historySize
+	model.SetWarmup(warmup)
+
+	p := tea.NewProgram(model, tea.WithMouseCellMotion())
+	go func() {
+		for msg := range feed {
+			p.Send(msg)
+		}
+	}()
+
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	if model.HopCount() > 0 {
+		fmt.Fprint(w, model.PlainText())
+	}
+	return nil
+}
+
+// HopCount returns the number of hops with recorded statistics.
+func (m *MTRModel) HopCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.stats)
 }
 
 // classifyECMP determines whether ECMP load balancing is per-flow or per-packet.
@@ -1,11 +1,16 @@
 package display
 
 import (
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -131,6 +136,22 @@ func TestMTRModel_ProbeResultMsg_WithEnrichment(t *testing.T) {
 	}
 }
 
+func TestMTRModel_EnrichmentMsg_BackfillsHostname(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	ip := net.ParseIP("192.0.2.1")
+
+	model.Update(ProbeResultMsg{TTL: 3, IP: ip, RTT: 10 * time.Millisecond, Enrichment: hop.Enrichment{ASN: 64500}})
+	model.Update(ProbeResultMsg{TTL: 4, IP: net.ParseIP("192.0.2.2"), RTT: 12 * time.Millisecond})
+	model.Update(EnrichmentMsg{IP: ip, Enrichment: hop.Enrichment{ASN: 64500, Hostname: "core1.example.net"}})
+
+	if got := model.stats[3].PrimaryEnrichment().Hostname; got != "core1.example.net" {
+		t.Errorf("hop 3 hostname = %q, want core1.example.net", got)
+	}
+	if got := model.stats[4].PrimaryEnrichment().Hostname; got != "" {
+		t.Errorf("hop 4 hostname = %q, want none", got)
+	}
+}
+
 func TestMTRModel_CycleCompleteMsg(t *testing.T) {
 	model := NewMTRModel("google.com", "8.8.8.8")
 
@@ -143,6 +164,232 @@ func TestMTRModel_CycleCompleteMsg(t *testing.T) {
 	}
 }
 
+func TestMTRModel_InvalidCycleRestartsStats(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	for cycle := 1; cycle <= 3; cycle++ {
+		model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 5 * time.Millisecond})
+		model.Update(CycleCompleteMsg{Cycle: cycle, Reached: true})
+	}
+
+	// Cycle 4 ran while the laptop switched networks: all timeouts
+	model.Update(ProbeResultMsg{TTL: 1, Timeout: true})
+	model.Update(CycleCompleteMsg{Cycle: 4, Invalid: true, Event: "network changed (wlan0 → eth0)"})
+
+	if len(model.stats) != 0 || model.cycles != 0 {
+		t.Errorf("expected stats to restart, got %d hops, %d cycles", len(model.stats), model.cycles)
+	}
+
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.1"), RTT: 2 * time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 5, Reached: true})
+
+	if model.cycles != 1 {
+		t.Errorf("expected cycles to count from the restart, got %d", model.cycles)
+	}
+	if loss := model.stats[1].LossPercent(); loss != 0 {
+		t.Errorf("expected no loss after restart, got %.1f%%", loss)
+	}
+	if view := model.View(); !strings.Contains(view, "network changed (wlan0 → eth0)") {
+		t.Error("expected the network change in the status bar")
+	}
+}
+
+func TestMTRModel_WarmupCyclesExcludedFromStats(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.SetWarmup(2)
+	ip := net.ParseIP("192.168.1.1")
+
+	// First-packet ARP resolution: slow replies and a loss
+	model.Update(ProbeResultMsg{TTL: 1, IP: ip, RTT: 900 * time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 1, Reached: true})
+	if view := model.View(); !strings.Contains(view, "Warm-up: cycle 2 of 2") {
+		t.Error("expected the warm-up in the status bar")
+	}
+	model.Update(ProbeResultMsg{TTL: 1, Timeout: true})
+	model.Update(CycleCompleteMsg{Cycle: 2, Reached: true})
+
+	stats := model.stats[1]
+	if stats.Sent != 0 || model.cycles != 0 {
+		t.Fatalf("expected warm-up to be cleared, got %d sent, %d cycles", stats.Sent, model.cycles)
+	}
+	if !stats.LastIP.Equal(ip) {
+		t.Error("expected the hop address to survive the warm-up")
+	}
+
+	model.Update(ProbeResultMsg{TTL: 1, IP: ip, RTT: 5 * time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 3, Reached: true})
+
+	if model.cycles != 1 {
+		t.Errorf("expected cycles to count from the end of warm-up, got %d", model.cycles)
+	}
+	if stats.WorstRTT != 5*time.Millisecond || stats.LossPercent() != 0 {
+		t.Errorf("expected only post-warm-up probes, got worst %v, loss %.1f%%", stats.WorstRTT, stats.LossPercent())
+	}
+}
+
+func TestMTRModel_PauseKeyStopsProbing(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	var calls []bool
+	model.SetControls(MTRControls{Pause: func(paused bool) { calls = append(calls, paused) }})
+
+	pause := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}}
+	model.Update(pause)
+	model.Update(pause)
+
+	if len(calls) != 2 || !calls[0] || calls[1] {
+		t.Errorf("expected the tracer paused then resumed, got %v", calls)
+	}
+}
+
+func TestMTRModel_IntervalAndProbeSizeKeys(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	var intervals []time.Duration
+	var sizes []int
+	model.SetControls(MTRControls{
+		SetInterval:  func(d time.Duration) { intervals = append(intervals, d) },
+		Interval:     time.Second,
+		SetProbeSize: func(size int) { sizes = append(sizes, size) },
+		ProbeSize:    64,
+	})
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'-'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+
+	if len(intervals) != 3 || intervals[0] != 2*time.Second || intervals[1] != 5*time.Second || intervals[2] != 2*time.Second {
+		t.Errorf("unexpected intervals %v", intervals)
+	}
+	if len(sizes) != 1 || sizes[0] != 128 {
+		t.Errorf("unexpected probe sizes %v", sizes)
+	}
+	view := model.View()
+	if !strings.Contains(view, "Every 2s") || !strings.Contains(view, "128B probes") {
+		t.Errorf("expected the interval and probe size in the status bar, got:\n%s", view)
+	}
+}
+
+func TestMTRModel_WriteSnapshot(t *testing.T) {
+	model := NewMTRModel("2001:db8::1 from Paris", "2001:db8::1")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 5 * time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 1, Reached: true})
+
+	dir := t.TempDir()
+	name, err := model.WriteSnapshot(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	base := filepath.Base(name)
+	if filepath.Dir(name) != dir || !strings.HasPrefix(base, "gtrace-mtr-2001_db8__1_from_Paris-") || !strings.HasSuffix(base, ".txt") {
+		t.Errorf("unexpected snapshot name %q", name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), "192.168.1.1") {
+		t.Errorf("expected the hop in the snapshot, got:\n%s", data)
+	}
+	if !model.IsRunning() {
+		t.Error("expected a snapshot not to stop the session")
+	}
+}
+
+func TestMTRModel_ExportKeyWritesCSV(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 5 * time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 1, Reached: true})
+
+	path := filepath.Join(t.TempDir(), "mtr.csv")
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'E'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyTab})
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlU})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(path)})
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the export file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ttl,ip,") || !strings.HasPrefix(lines[1], "1,192.168.1.1,") {
+		t.Errorf("unexpected CSV export:\n%s", data)
+	}
+	if !model.IsRunning() || !strings.Contains(model.View(), "Exported csv to "+path) {
+		t.Error("expected the export confirmed without quitting")
+	}
+}
+
+func TestMTRModel_HelpOverlay(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(tea.WindowSizeMsg{Width: 120, Height: 50})
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	if view := model.View(); !strings.Contains(view, "Display modes (n)") {
+		t.Fatal("expected the MTR help")
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if model.IsPaused() || strings.Contains(model.View(), "Display modes (n)") {
+		t.Error("expected the key to only close the help")
+	}
+}
+
+func TestMTRModel_TargetKeySwitchesTarget(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	retarget := make(chan string, 1)
+	model.retargetCh = retarget
+	for cycle := 1; cycle <= 3; cycle++ {
+		model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 5 * time.Millisecond})
+		model.Update(CycleCompleteMsg{Cycle: cycle, Reached: true})
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("quad9.net")})
+	if !strings.Contains(model.View(), "New target: quad9.net_") {
+		t.Fatal("expected the target prompt")
+	}
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := <-retarget; got != "quad9.net" {
+		t.Fatalf("expected a switch to quad9.net, got %q", got)
+	}
+
+	model.Update(TargetChangedMsg{Target: "quad9.net", TargetIP: "2620:fe::fe"})
+	if model.target != "quad9.net" || !model.isIPv6 || len(model.stats) != 0 || model.cycles != 0 {
+		t.Fatalf("expected stats restarted for the new target, got %s, %d hops, %d cycles", model.target, len(model.stats), model.cycles)
+	}
+
+	// The new tracer counts its cycles from 1
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.1"), RTT: 2 * time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 1, Reached: true})
+	if model.cycles != 1 {
+		t.Errorf("expected 1 cycle, got %d", model.cycles)
+	}
+}
+
+func TestMTRModel_TargetChangeFailureKeepsTarget(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 5 * time.Millisecond})
+
+	model.Update(TargetChangedMsg{Target: "nope.invalid", Err: fmt.Errorf("no such host")})
+
+	if model.target != "google.com" || len(model.stats) != 1 {
+		t.Error("expected the current target and stats to be kept")
+	}
+	if !strings.Contains(model.View(), "Cannot switch to nope.invalid: no such host") {
+		t.Error("expected the failure in the status bar")
+	}
+}
+
+func TestMTRModel_TargetKeyIgnoredWithoutSwitching(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+
+	if model.editingTarget() {
+		t.Error("expected no prompt when the tracer cannot switch targets")
+	}
+}
+
 func TestMTRModel_KeyMsg_Quit(t *testing.T) {
 	model := NewMTRModel("google.com", "8.8.8.8")
 
@@ -571,3 +818,388 @@ func TestMTRModel_ECMP_WithEnrichment(t *testing.T) {
 		t.Errorf("expected primary enrichment ASN 100, got %d", pe.ASN)
 	}
 }
+
+func TestMTRModel_KeyMsg_CopyModeFreezesPlainTable(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.handleProbeResult(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 2 * time.Millisecond})
+	model.handleProbeResult(ProbeResultMsg{TTL: 2, Timeout: true})
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	view := model.View()
+
+	if !strings.Contains(view, "COPY MODE") {
+		t.Fatal("expected copy mode banner")
+	}
+	for _, c := range view {
+		if c > 127 || c == '\x1b' {
+			t.Fatalf("copy view must be plain ASCII, found %q", c)
+		}
+	}
+	if !strings.Contains(view, "192.168.1.1") || !strings.Contains(view, "StDev") {
+		t.Errorf("expected table contents in copy view:\n%s", view)
+	}
+
+	// New data does not change the frozen snapshot
+	model.handleProbeResult(ProbeResultMsg{TTL: 3, IP: net.ParseIP("10.9.9.9"), RTT: 5 * time.Millisecond})
+	if strings.Contains(model.View(), "10.9.9.9") {
+		t.Error("copy view should stay frozen while probing continues")
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if strings.Contains(model.View(), "COPY MODE") {
+		t.Error("Esc should return to the live view")
+	}
+}
+
+func TestMTRModel_PlainText_ColumnsAligned(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.handleProbeResult(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 2 * time.Millisecond})
+	model.handleProbeResult(ProbeResultMsg{TTL: 2, IP: net.ParseIP("10.0.0.1"), RTT: 12 * time.Millisecond})
+
+	lines := strings.Split(model.PlainText(), "\n")
+	// Title, blank, header, rule, rows
+	header := lines[2]
+	lossCol := strings.Index(header, "Loss%") + len("Loss%")
+	for _, row := range lines[4:6] {
+		if len(row) < lossCol || row[lossCol-1] != '%' {
+			t.Errorf("loss column misaligned in %q", row)
+		}
+	}
+}
+
+func TestMTRModel_HopDetail_ShowsASPath(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Millisecond})
+	model.Update(ProbeResultMsg{
+		TTL: 2,
+		IP:  net.ParseIP("8.8.8.8"),
+		RTT: 10 * time.Millisecond,
+		Enrichment: hop.Enrichment{
+			ASN:   15169,
+			ASOrg: "GOOGLE",
+			BGP:   &hop.BGPInfo{Prefix: "8.8.8.0/24", OriginASN: 15169, ASPath: []uint32{3356, 15169}, Visibility: 97},
+		},
+	})
+
+	if strings.Contains(model.View(), "AS path") {
+		t.Error("expected detail panel hidden by default")
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	view := model.View()
+	if !strings.Contains(view, "Hop 1 details") {
+		t.Errorf("expected first hop selected initially, got:\n%s", view)
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	view = model.View()
+	if !strings.Contains(view, "Hop 2 details") {
+		t.Fatalf("expected hop 2 selected after down, got:\n%s", view)
+	}
+	if !strings.Contains(view, "AS path: 3356 15169") || !strings.Contains(view, "8.8.8.0/24, 97% visible") {
+		t.Errorf("expected AS path and prefix in detail panel, got:\n%s", view)
+	}
+
+	// Moving past the last hop keeps the selection
+	model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if !strings.Contains(model.View(), "Hop 2 details") {
+		t.Error("expected selection to stay on the last hop")
+	}
+}
+
+func TestMTRModel_HopDetail_LastAddressChange(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	for cycle, ip := range []string{"10.0.0.1", "10.0.0.1"} {
+		model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP(ip), RTT: time.Millisecond})
+		model.Update(CycleCompleteMsg{Cycle: cycle + 1})
+	}
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if strings.Contains(model.View(), "Address last changed") {
+		t.Error("expected no address change before a new address answers")
+	}
+
+	before := time.Now()
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.2"), RTT: time.Millisecond})
+	model.Update(CycleCompleteMsg{Cycle: 3})
+
+	if changed := model.stats[1].LastChange; changed.Before(before) {
+		t.Errorf("expected the change recorded at the end of cycle 3, got %v", changed)
+	}
+	if view := model.View(); !strings.Contains(view, "Address last changed: ") || !strings.Contains(view, "(0s ago)") {
+		t.Errorf("expected the time of the address change in the detail panel, got:\n%s", view)
+	}
+}
+
+func TestMTRModel_RTTChart(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.SetHistorySize(300)
+	model.Update(tea.WindowSizeMsg{Width: 60, Height: 30})
+	for i := 1; i <= 200; i++ {
+		if i == 200 {
+			model.Update(ProbeResultMsg{TTL: 1, Timeout: true})
+			continue
+		}
+		model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Duration(i) * time.Millisecond})
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	view := model.View()
+	if !strings.Contains(view, "Hop 1 RTT history: 192.168.1.1") {
+		t.Fatalf("expected RTT chart for hop 1, got:\n%s", view)
+	}
+	// 60 columns minus the axis leaves 49 samples: 152..200
+	if !strings.Contains(view, "Samples 152-200 of 200") || !strings.Contains(view, glyphs.Fail) {
+		t.Errorf("expected newest samples with a loss marker, got:\n%s", view)
+	}
+	if strings.Contains(view, "Loss%") {
+		t.Error("expected the chart to replace the hop table")
+	}
+
+	// Scroll back, then past the oldest sample
+	model.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if view := model.View(); !strings.Contains(view, "Samples 142-190 of 200") {
+		t.Errorf("expected chart scrolled back, got:\n%s", view)
+	}
+	for i := 0; i < 30; i++ {
+		model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}})
+	}
+	if view := model.View(); !strings.Contains(view, "Samples 1-49 of 200") {
+		t.Errorf("expected scrolling to stop at the oldest sample, got:\n%s", view)
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if !strings.Contains(model.View(), "Loss%") {
+		t.Error("expected Esc to return to the hop table")
+	}
+}
+
+func TestMTRModel_Sparkline_ShowsRecentSamples(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.SetHistorySize(100)
+	for i := 1; i <= 50; i++ {
+		model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Duration(i) * time.Millisecond})
+	}
+
+	row := model.formatStatsRow(model.stats[1], model.layoutLocked())
+	if !strings.ContainsRune(row, sparkChars[0]) {
+		t.Fatalf("expected a sparkline in %q", row)
+	}
+	graph := row[strings.IndexRune(row, sparkChars[0]):]
+	if got := len([]rune(graph)); got != RTTHistorySize {
+		t.Errorf("expected %d sparkline columns, got %d in %q", RTTHistorySize, got, graph)
+	}
+	if got := len(model.stats[1].RTTHistory); got != 50 {
+		t.Errorf("expected 50 samples kept, got %d", got)
+	}
+}
+
+func TestMTRModel_HopDetail_DrillDown(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.1"), RTT: 10 * time.Millisecond, NAT: true, MTU: 1400})
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.1"), RTT: 20 * time.Millisecond})
+	model.Update(ProbeResultMsg{TTL: 1, Timeout: true})
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.2"), RTT: 30 * time.Millisecond})
+	model.Update(ProbeResultMsg{
+		TTL:  1,
+		IP:   net.ParseIP("10.0.0.1"),
+		RTT:  12 * time.Millisecond,
+		MPLS: []hop.MPLSLabel{{Label: 24001, Exp: 0, S: true, TTL: 1}},
+	})
+
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	view := model.View()
+	for _, want := range []string{
+		"Hop 1 details",
+		"Flags: [NAT] [MTU:1400] [MPLS]",
+		"10.0.0.1 3 probes (75%)",
+		"Best 10.0  Avg 14.0  Wrst 20.0  Last 12.0 ms",
+		"10.0.0.2 1 probes (25%)",
+		"MPLS: ",
+		"Last 5 probes",
+		"30.0*",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected %q in detail panel, got:\n%s", want, view)
+		}
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if strings.Contains(model.View(), "Hop 1 details") {
+		t.Error("expected space to close the detail panel")
+	}
+}
+
+func TestMTRModel_Layout_NarrowTerminal(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Millisecond})
+
+	wide := model.layoutLocked()
+	if len(wide.columns) != len(statColumns) || wide.hostWidth != colHostIPv4 {
+		t.Fatalf("expected every column without a known width, got %d columns", len(wide.columns))
+	}
+
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	layout := model.layoutLocked()
+	if layout.hostWidth != minHostWidth {
+		t.Errorf("expected host column shrunk to %d, got %d", minHostWidth, layout.hostWidth)
+	}
+	if layout.lineWidth() > 80 {
+		t.Errorf("expected rows to fit 80 columns, got %d", layout.lineWidth())
+	}
+	if layout.hiddenLeft != 0 || layout.hiddenRight == 0 {
+		t.Fatalf("expected columns hidden on the right, got left %d right %d", layout.hiddenLeft, layout.hiddenRight)
+	}
+	view := model.View()
+	if strings.Contains(view, "Graph") || !strings.Contains(view, "columns hidden") {
+		t.Errorf("expected the graph hidden with a status hint, got:\n%s", view)
+	}
+
+	// Scroll right until the last column shows, then no further
+	for i := 0; i < len(statColumns)+2; i++ {
+		model.Update(tea.KeyMsg{Type: tea.KeyRight})
+	}
+	layout = model.layoutLocked()
+	if layout.hiddenRight != 0 || layout.hiddenLeft == 0 {
+		t.Fatalf("expected scrolled to the last column, got left %d right %d", layout.hiddenLeft, layout.hiddenRight)
+	}
+	if !strings.Contains(model.View(), "Graph") {
+		t.Error("expected the graph column after scrolling right")
+	}
+	model.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if got := model.layoutLocked().offset; got != layout.offset {
+		t.Errorf("expected scrolling to stop at offset %d, got %d", layout.offset, got)
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if got := model.layoutLocked().offset; got != layout.offset-1 {
+		t.Errorf("expected Left to scroll left to offset %d, got %d", layout.offset-1, got)
+	}
+
+	for _, line := range strings.Split(model.View(), "\n")[:6] {
+		if w := lipgloss.Width(line); w > 80 {
+			t.Errorf("expected line within 80 columns, got %d: %q", w, line)
+		}
+	}
+}
+
+func TestFitHostParts(t *testing.T) {
+	parts := []string{"ae-1-3509.edge3.frankfurt1.level3.net", "[AS3356]", "[ECMP:2]"}
+
+	if plain, _ := fitHostParts(parts, parts, 60); plain != "ae-1-3509.edge3.frankfurt1.level3.net [AS3356] [ECMP:2]" {
+		t.Errorf("expected all parts when they fit, got %q", plain)
+	}
+	if plain, _ := fitHostParts(parts, parts, 46); plain != "ae-1-3509.edge3.frankfurt1.level3.net [AS3356]" {
+		t.Errorf("expected trailing annotation dropped, got %q", plain)
+	}
+	if plain, _ := fitHostParts(parts, parts, 20); plain != "ae-1-3509...vel3.net" {
+		t.Errorf("expected name shortened in the middle, got %q", plain)
+	}
+}
+
+func TestMTRModel_Mouse_ClickSelectsHop(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	for ttl := 1; ttl <= 3; ttl++ {
+		model.Update(ProbeResultMsg{TTL: ttl, IP: net.ParseIP(fmt.Sprintf("10.0.0.%d", ttl)), RTT: time.Duration(ttl) * time.Millisecond})
+	}
+
+	model.Update(tea.MouseMsg{X: 10, Y: firstRowLine + 1, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress})
+	if !strings.Contains(model.View(), "Hop 2 details") {
+		t.Errorf("expected click on the second row to open hop 2 details, got:\n%s", model.View())
+	}
+
+	// Clicks below the last row are ignored
+	model.Update(tea.MouseMsg{X: 10, Y: firstRowLine + 5, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress})
+	if !strings.Contains(model.View(), "Hop 2 details") {
+		t.Error("expected selection unchanged after clicking below the table")
+	}
+}
+
+func TestMTRModel_Mouse_HeaderSorts(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(ProbeResultMsg{TTL: 1, IP: net.ParseIP("10.0.0.1"), RTT: 5 * time.Millisecond})
+	model.Update(ProbeResultMsg{TTL: 2, IP: net.ParseIP("10.0.0.2"), RTT: 40 * time.Millisecond})
+	model.Update(ProbeResultMsg{TTL: 3, IP: net.ParseIP("10.0.0.3"), RTT: 20 * time.Millisecond})
+
+	layout := model.layoutLocked()
+	avgX := strings.Index(strings.Split(model.View(), "\n")[headerLine], " Avg") + 2
+	if got := layout.columnAt(avgX); got != "Avg" {
+		t.Fatalf("expected Avg header at column %d, got %q", avgX, got)
+	}
+
+	click := tea.MouseMsg{X: avgX, Y: headerLine, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}
+	model.Update(click)
+	var ttls []int
+	for _, stats := range model.displayStatsLocked() {
+		ttls = append(ttls, stats.TTL)
+	}
+	if fmt.Sprint(ttls) != "[2 3 1]" {
+		t.Errorf("expected hops sorted by Avg descending, got %v", ttls)
+	}
+	if view := model.View(); !strings.Contains(view, "Avg"+glyphs.SortDesc) || !strings.Contains(view, "Sorted by Avg") {
+		t.Errorf("expected sorted header marker, got:\n%s", view)
+	}
+
+	model.Update(click)
+	if model.sortColumn != "" {
+		t.Errorf("expected a second click to restore hop order, got %q", model.sortColumn)
+	}
+}
+
+func TestMTRModel_Mouse_WheelScrollsLongPaths(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	model.Update(tea.WindowSizeMsg{Width: 200, Height: 15})
+	for ttl := 1; ttl <= 20; ttl++ {
+		model.Update(ProbeResultMsg{TTL: ttl, IP: net.ParseIP(fmt.Sprintf("10.0.0.%d", ttl)), RTT: time.Millisecond})
+	}
+
+	// 15 lines leave 7 for hop rows
+	if view := model.View(); !strings.Contains(view, "Rows 1-7 of 20") {
+		t.Fatalf("expected the first 7 rows, got:\n%s", view)
+	}
+
+	for i := 0; i < 3; i++ {
+		model.Update(tea.MouseMsg{Button: tea.MouseButtonWheelDown, Action: tea.MouseActionPress})
+	}
+	view := model.View()
+	if !strings.Contains(view, "Rows 4-10 of 20") {
+		t.Errorf("expected the wheel to scroll 3 rows, got:\n%s", view)
+	}
+	if got := strings.Split(view, "\n")[firstRowLine]; !strings.HasPrefix(got, "4 ") {
+		t.Errorf("expected hop 4 on the first row, got %q", got)
+	}
+
+	// Clicking a scrolled row selects the hop shown there
+	model.Update(tea.MouseMsg{X: 10, Y: firstRowLine, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress})
+	if model.selectedTTL != 4 {
+		t.Errorf("expected hop 4 selected, got %d", model.selectedTTL)
+	}
+
+	// Keyboard selection keeps the selected hop in view
+	model.Update(tea.MouseMsg{Button: tea.MouseButtonWheelUp, Action: tea.MouseActionPress})
+	for i := 0; i < 15; i++ {
+		model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	if view := model.View(); !strings.Contains(view, "Hop 19 details") || !strings.Contains(view, "\n19"+glyphs.Arrow) {
+		t.Errorf("expected hop 19 selected and visible, got:\n%s", view)
+	}
+}
+
+func TestMTRModel_Stability(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	addrs := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	for cycle := range 4 {
+		model.handleProbeResult(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Millisecond})
+		model.handleProbeResult(ProbeResultMsg{TTL: 2, IP: net.ParseIP(addrs[cycle]), RTT: 5 * time.Millisecond})
+		model.Update(CycleCompleteMsg{Cycle: cycle + 1, Reached: true})
+	}
+
+	if view := model.View(); !strings.Contains(view, "Stability: 0%") {
+		t.Errorf("expected path stability in status bar, got:\n%s", view)
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if view := model.View(); !strings.Contains(view, "Stability: 0% (address changed in 3 of 3 cycles)") {
+		t.Errorf("expected hop stability in detail panel, got:\n%s", view)
+	}
+}
@@ -2,6 +2,7 @@ package display
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -22,9 +23,14 @@ type MultiCycleCompleteMsg struct {
 
 // SplitMTRModel is a Bubbletea model that renders multiple MTR targets side-by-side.
 type SplitMTRModel struct {
-	models []*MTRModel
-	width  int
-	height int
+	models  []*MTRModel
+	focused int // Index of the target shown full-screen (-1 = split view)
+	width   int
+	height  int
+
+	controls MTRControls // Keys acting on the probing of every target (nil functions = display only)
+	notice   string      // Outcome of the last snapshot, shown in the help bar
+	showHelp bool        // Help overlay over the split view
 }
 
 // NewSplitMTRModel creates a split-pane model with one sub-model per target.
@@ -34,7 +40,15 @@ func NewSplitMTRModel(targets, targetIPs []string) *SplitMTRModel {
 		models[i] = NewMTRModel(targets[i], targetIPs[i])
 	}
 	return &SplitMTRModel{
-		models: models,
+		models:  models,
+		focused: -1,
+	}
+}
+
+// SetHistorySize sets the number of RTT samples kept per hop for every target.
+func (m *SplitMTRModel) SetHistorySize(n int) {
+	for _, model := range m.models {
+		model.SetHistorySize(n)
 	}
 }
 
@@ -50,6 +64,16 @@ func (m *SplitMTRModel) Init() tea.Cmd {
 func (m *SplitMTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// The focused target's export and help overlays take every key while open
+		if m.focused >= 0 && m.focused < len(m.models) && (m.models[m.focused].exporting() || m.models[m.focused].helpShown()) {
+			m.models[m.focused].Update(msg)
+			return m, nil
+		}
+		// Any key but Ctrl+C closes the help overlay
+		if m.showHelp && msg.String() != "ctrl+c" {
+			m.showHelp = false
+			return m, nil
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			for _, model := range m.models {
@@ -59,11 +83,23 @@ func (m *SplitMTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 		case "p":
+			paused := false
 			for _, model := range m.models {
 				model.mu.Lock()
 				model.paused = !model.paused
+				paused = model.paused
 				model.mu.Unlock()
 			}
+			if m.controls.Pause != nil {
+				m.controls.Pause(paused)
+			}
+		case "+", "=", "-":
+			longer := msg.String() != "-"
+			m.adjustProbing(func(model *MTRModel) { model.stepInterval(longer) })
+		case "z":
+			m.adjustProbing((*MTRModel).cycleProbeSize)
+		case "s":
+			m.writeSnapshots(".")
 		case "r":
 			for _, model := range m.models {
 				model.mu.Lock()
@@ -84,11 +120,53 @@ func (m *SplitMTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				model.showECMP = !model.showECMP
 				model.mu.Unlock()
 			}
+		case "c", "esc":
+			// Copy mode applies to the focused target's full view
+			if m.focused >= 0 && m.focused < len(m.models) {
+				model := m.models[m.focused]
+				if msg.String() == "c" {
+					model.ToggleCopyMode()
+				} else {
+					model.mu.Lock()
+					model.copyView = ""
+					model.showChart = false
+					model.mu.Unlock()
+				}
+			}
+		case "?":
+			if m.focused >= 0 && m.focused < len(m.models) {
+				m.models[m.focused].Update(msg)
+			} else {
+				m.showHelp = true
+			}
+		case "d", "enter", " ", "up", "k", "down", "j", "g", "left", "h", "right", "l", "E":
+			// Hop details, the RTT chart and export apply to the focused target's full view
+			if m.focused >= 0 && m.focused < len(m.models) {
+				m.models[m.focused].Update(msg)
+			}
+		case "0":
+			m.focused = -1
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			// Number keys focus a single target; out-of-range keys are ignored
+			idx := int(msg.String()[0] - '1')
+			if idx < len(m.models) {
+				m.focused = idx
+			}
 		}
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		// The focused target's full view fits itself to the terminal
+		for _, model := range m.models {
+			model.Update(msg)
+		}
+
+	case tea.MouseMsg:
+		// Clicks and the wheel apply to the focused target's full view
+		if m.focused >= 0 && m.focused < len(m.models) {
+			m.models[m.focused].Update(msg)
+		}
 
 	case MultiProbeResultMsg:
 		if msg.TargetIndex >= 0 && msg.TargetIndex < len(m.models) {
@@ -99,9 +177,7 @@ func (m *SplitMTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.TargetIndex >= 0 && msg.TargetIndex < len(m.models) {
 			model := m.models[msg.TargetIndex]
 			model.mu.Lock()
-			model.cycles = msg.Cycle
-			model.updateRateLimitFlags()
-			model.updateECMPClassification()
+			model.completeCycleLocked(msg.Cycle)
 			model.mu.Unlock()
 		}
 
@@ -126,6 +202,14 @@ func (m *SplitMTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // View implements tea.Model.
 func (m *SplitMTRModel) View() string {
+	if m.showHelp {
+		return overlayCenter(m.splitView(), renderHelp(splitHelp), m.width, m.height)
+	}
+	return m.splitView()
+}
+
+// splitView renders the split panes, or the focused target's full view.
+func (m *SplitMTRModel) splitView() string {
 	if len(m.models) == 0 {
 		return ""
 	}
@@ -135,6 +219,11 @@ func (m *SplitMTRModel) View() string {
 		return m.models[0].View()
 	}
 
+	// Focused target: full MTR view with a target switcher line
+	if m.focused >= 0 && m.focused < len(m.models) {
+		return m.models[m.focused].View() + "\n" + m.renderTargetSwitcher()
+	}
+
 	// Multi-target: render side-by-side
 	paneWidth := m.width / len(m.models)
 	if paneWidth < 40 {
@@ -160,7 +249,7 @@ func (m *SplitMTRModel) View() string {
 
 	// Merge lines side-by-side
 	var b strings.Builder
-	sep := " │ "
+	sep := " " + glyphs.VLine + " "
 	for line := 0; line < maxLines; line++ {
 		for i, pane := range panes {
 			if i > 0 {
@@ -173,11 +262,68 @@ func (m *SplitMTRModel) View() string {
 
 	// Shared help bar
 	b.WriteString("\n")
-	b.WriteString("Press 'e' expand ECMP, 'n' DNS/IP, 'p' pause all, 'r' reset all, 'q' quit")
+	if m.notice != "" {
+		b.WriteString(m.notice)
+		b.WriteString("\n")
+	}
+	b.WriteString(fmt.Sprintf("Press 1-%d focus target, 'e' expand ECMP, 'n' DNS/IP, 's' snapshot, 'p' pause all, 'r' reset all, '?' help, 'q' quit", len(m.models)))
 
 	return b.String()
 }
 
+// adjustProbing applies adjust, which changes the interval or probe size
+// of every target through the controls, to the first target and shows the
+// result on the others.
+func (m *SplitMTRModel) adjustProbing(adjust func(*MTRModel)) {
+	if len(m.models) == 0 {
+		return
+	}
+	first := m.models[0]
+	adjust(first)
+	first.mu.RLock()
+	interval, size := first.interval, first.probeSize
+	first.mu.RUnlock()
+	for _, model := range m.models[1:] {
+		model.mu.Lock()
+		model.interval, model.probeSize = interval, size
+		model.mu.Unlock()
+	}
+}
+
+// writeSnapshots writes a snapshot of every target to dir, each to its own
+// file, and records the outcome for the help bar and the focused view.
+func (m *SplitMTRModel) writeSnapshots(dir string) {
+	var names []string
+	for _, model := range m.models {
+		name, err := model.WriteSnapshot(dir)
+		if err != nil {
+			m.notice = "Snapshot failed: " + err.Error()
+			break
+		}
+		names = append(names, name)
+		m.notice = "Snapshot saved to " + strings.Join(names, ", ")
+	}
+	for _, model := range m.models {
+		model.mu.Lock()
+		model.notice = m.notice
+		model.mu.Unlock()
+	}
+}
+
+// renderTargetSwitcher renders the list of targets with the focused one highlighted.
+func (m *SplitMTRModel) renderTargetSwitcher() string {
+	parts := make([]string, len(m.models))
+	for i, model := range m.models {
+		label := fmt.Sprintf("%d:%s", i+1, model.target)
+		if i == m.focused {
+			parts[i] = titleStyle.Render("[" + label + "]")
+		} else {
+			parts[i] = label
+		}
+	}
+	return strings.Join(parts, "  ") + "  " + glyphs.VLine + " Press 0 for split view"
+}
+
 // renderPane renders a single target's MTR view as lines, truncated/padded to paneWidth.
 func (m *SplitMTRModel) renderPane(model *MTRModel, paneWidth int) []string {
 	model.mu.RLock()
@@ -186,17 +332,17 @@ func (m *SplitMTRModel) renderPane(model *MTRModel, paneWidth int) []string {
 	var lines []string
 
 	// Title
-	title := fmt.Sprintf("gtr → %s (%s)", model.target, model.targetIP)
+	title := fmt.Sprintf("gtr %s %s (%s)", glyphs.Arrow, model.target, model.targetIP)
 	if len(title) > paneWidth {
 		title = title[:paneWidth-3] + "..."
 	}
 	lines = append(lines, padOrTruncate(title, paneWidth))
-	lines = append(lines, strings.Repeat("─", paneWidth))
+	lines = append(lines, strings.Repeat(glyphs.HLine, paneWidth))
 
 	// Compact header
 	header := fmt.Sprintf("%-3s %-15s %5s %4s %7s %7s", "Hop", "Host", "Loss%", "Snt", "Avg", "Last")
 	lines = append(lines, padOrTruncate(header, paneWidth))
-	lines = append(lines, strings.Repeat("─", paneWidth))
+	lines = append(lines, strings.Repeat(glyphs.HLine, paneWidth))
 
 	// Hops
 	orderedStats := model.getOrderedStatsLocked()
@@ -206,7 +352,7 @@ func (m *SplitMTRModel) renderPane(model *MTRModel, paneWidth int) []string {
 	}
 
 	// Status
-	lines = append(lines, strings.Repeat("─", paneWidth))
+	lines = append(lines, strings.Repeat(glyphs.HLine, paneWidth))
 	status := fmt.Sprintf("Cycles: %d | Hops: %d", model.cycles, len(model.stats))
 	lines = append(lines, padOrTruncate(status, paneWidth))
 
@@ -231,7 +377,7 @@ func (m *SplitMTRModel) formatCompactRow(stats *HopStats, paneWidth int) string
 		host = host[:maxHost-3] + "..."
 	}
 
-	avg := float64(stats.AvgRTT()) / float64(1e6) // nanoseconds to ms
+	avg := float64(stats.FilteredAvgRTT()) / float64(1e6) // nanoseconds to ms
 	last := float64(stats.LastRTT) / float64(1e6)
 
 	return fmt.Sprintf("%3d %-15s %4.1f%% %4d %6.1fms %6.1fms",
@@ -251,11 +397,24 @@ func padOrTruncate(s string, width int) string {
 	return s
 }
 
-// RunSplitMTR runs the split-pane MTR TUI program.
-func RunSplitMTR(targets, targetIPs []string, resultChans []<-chan MultiProbeResultMsg, cycleChans []<-chan MultiCycleCompleteMsg, doneChan <-chan struct{}) error {
+// RunSplitMTR runs the split-pane MTR TUI program. When the program exits,
+// a plain-text report for every target is written to w. The controls act on
+// every target; target switching is not supported.
+func RunSplitMTR(w io.Writer, targets, targetIPs []string, historySize, warmup int, resultChans []<-chan MultiProbeResultMsg, cycleChans []<-chan MultiCycleCompleteMsg, doneChan <-chan struct{}, controls MTRControls) error {
 	model := NewSplitMTRModel(targets, targetIPs)
+	model.controls = controls
+	model.SetHistorySize(historySize)
+	for _, m := range model.models {
+		m.SetWarmup(warmup)
+		m.SetControls(MTRControls{
+			SetInterval:  controls.SetInterval,
+			Interval:     controls.Interval,
+			SetProbeSize: controls.SetProbeSize,
+			ProbeSize:    controls.ProbeSize,
+		})
+	}
 
-	p := tea.NewProgram(model)
+	p := tea.NewProgram(model, tea.WithMouseCellMotion())
 
 	// Goroutine to receive results from all targets
 	go func() {
@@ -286,6 +445,22 @@ func RunSplitMTR(targets, targetIPs []string, resultChans []<-chan MultiProbeRes
 		<-doneChan
 	}()
 
-	_, err := p.Run()
-	return err
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	fmt.Fprint(w, model.PlainText())
+	return nil
+}
+
+// PlainText renders the plain-text report of every target that has
+// recorded hops, separated by blank lines.
+func (m *SplitMTRModel) PlainText() string {
+	var reports []string
+	for _, model := range m.models {
+		if model.HopCount() > 0 {
+			reports = append(reports, model.PlainText())
+		}
+	}
+	return strings.Join(reports, "\n")
 }
@@ -136,9 +136,9 @@ func TestSplitMTRModel_ResetAll(t *testing.T) {
 
 	// Add some data
 	model.models[0].handleProbeResult(ProbeResultMsg{
-		TTL: 1,
-		IP:  net.ParseIP("192.168.1.1"),
-		RTT: 1 * time.Millisecond,
+		TTL:  1,
+		IP:   net.ParseIP("192.168.1.1"),
+		RTT:  1 * time.Millisecond,
 		MPLS: []hop.MPLSLabel{},
 	})
 
@@ -154,3 +154,58 @@ func TestSplitMTRModel_ResetAll(t *testing.T) {
 		t.Error("expected stats cleared after reset")
 	}
 }
+
+func TestSplitMTRModel_NumberKeyFocusesTarget(t *testing.T) {
+	model := NewSplitMTRModel([]string{"alpha.com", "beta.com"}, []string{"10.0.0.1", "10.0.0.2"})
+	model.width = 120
+
+	updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'2'}})
+	model = updated.(*SplitMTRModel)
+
+	if model.focused != 1 {
+		t.Fatalf("expected focused=1, got %d", model.focused)
+	}
+
+	view := model.View()
+	if !strings.Contains(view, "gtr → beta.com") {
+		t.Error("focused view should show the second target's full MTR view")
+	}
+	if strings.Contains(view, "gtr → alpha.com") {
+		t.Error("focused view should not render other target panes")
+	}
+
+	// '0' returns to split view
+	updated, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'0'}})
+	model = updated.(*SplitMTRModel)
+	if model.focused != -1 {
+		t.Errorf("expected split view after '0', got focused=%d", model.focused)
+	}
+}
+
+func TestSplitMTRModel_NumberKeyOutOfRangeIgnored(t *testing.T) {
+	model := NewSplitMTRModel([]string{"a", "b"}, []string{"1.1.1.1", "2.2.2.2"})
+
+	updated, _ := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'5'}})
+	model = updated.(*SplitMTRModel)
+
+	if model.focused != -1 {
+		t.Errorf("expected out-of-range key to be ignored, got focused=%d", model.focused)
+	}
+}
+
+func TestSplitMTRModel_PlainText_ReportsTargetsWithData(t *testing.T) {
+	model := NewSplitMTRModel([]string{"alpha.com", "beta.com"}, []string{"10.0.0.1", "10.0.0.2"})
+	model.Update(MultiProbeResultMsg{
+		TargetIndex: 0,
+		Probe:       ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 2 * time.Millisecond},
+	})
+
+	report := model.PlainText()
+
+	if !strings.Contains(report, "alpha.com") || !strings.Contains(report, "192.168.1.1") {
+		t.Errorf("expected report for alpha.com, got:\n%s", report)
+	}
+	if strings.Contains(report, "beta.com") {
+		t.Error("targets without hops should be omitted from the report")
+	}
+}
@@ -0,0 +1,146 @@
+package display
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// RenderProfile describes the terminal capabilities the renderers may rely on.
+type RenderProfile struct {
+	ASCII      bool // No UTF-8: use ASCII box drawing and graphs
+	BasicColor bool // No 256-color support: restrict to the 16 ANSI colors
+}
+
+// Degraded reports whether the profile falls back from full rendering.
+func (p RenderProfile) Degraded() bool {
+	return p.ASCII || p.BasicColor
+}
+
+// glyphSet holds the characters used for rules, trees and graphs.
+// Every glyph is exactly one display column wide in both sets so
+// switching profiles never changes the layout.
+type glyphSet struct {
+	HLine       string
+	VLine       string
+	Cross       string
+	TopLeft     string
+	TopRight    string
+	BottomLeft  string
+	BottomRight string
+	Branch      string
+	LastBranch  string
+	Arrow       string
+	Times       string
+	Check       string
+	Fail        string
+	SortDesc    string
+	Spark       []rune
+	Heat        []rune // Heatmap cells, from cool to hot
+}
+
+var unicodeGlyphs = glyphSet{
+	HLine:       "─",
+	VLine:       "│",
+	Cross:       "┼",
+	TopLeft:     "╭",
+	TopRight:    "╮",
+	BottomLeft:  "╰",
+	BottomRight: "╯",
+	Branch:      "├",
+	LastBranch:  "└",
+	Arrow:       "→",
+	Times:       "×",
+	Check:       "✓",
+	Fail:        "✗",
+	SortDesc:    "▼",
+	Spark:       []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'},
+	Heat:        []rune{'░', '▒', '▓', '█'},
+}
+
+var asciiGlyphs = glyphSet{
+	HLine:       "-",
+	VLine:       "|",
+	Cross:       "+",
+	TopLeft:     "+",
+	TopRight:    "+",
+	BottomLeft:  "+",
+	BottomRight: "+",
+	Branch:      "|",
+	LastBranch:  "`",
+	Arrow:       ">",
+	Times:       "x",
+	Check:       "+",
+	Fail:        "x",
+	SortDesc:    "v",
+	Spark:       []rune{'_', '.', ',', '-', '=', '+', '*', '#'},
+	Heat:        []rune{'.', ':', '*', '#'},
+}
+
+// glyphs is the active glyph set, switched by ApplyRenderProfile.
+var glyphs = unicodeGlyphs
+
+// sparkChars are the active sparkline characters (from low to high).
+var sparkChars = glyphs.Spark
+
+// ApplyRenderProfile switches all renderers to the given profile.
+// It must be called before any TUI is started.
+func ApplyRenderProfile(p RenderProfile) {
+	if p.ASCII {
+		glyphs = asciiGlyphs
+	} else {
+		glyphs = unicodeGlyphs
+	}
+	sparkChars = glyphs.Spark
+
+	if p.BasicColor {
+		lipgloss.SetColorProfile(termenv.ANSI)
+	}
+}
+
+// DetectRenderProfile inspects the locale and terminal environment variables
+// and returns the profile the current terminal can display.
+func DetectRenderProfile(getenv func(string) string) RenderProfile {
+	return detectRenderProfile(runtime.GOOS, getenv)
+}
+
+func detectRenderProfile(goos string, getenv func(string) string) RenderProfile {
+	term := strings.ToLower(getenv("TERM"))
+
+	// Windows consoles rarely set TERM or a locale; Windows Terminal
+	// supports both UTF-8 and 256 colors.
+	if goos == "windows" && term == "" {
+		return RenderProfile{}
+	}
+
+	var p RenderProfile
+	switch term {
+	case "dumb", "linux", "vt100", "vt102", "vt220", "ansi", "cons25":
+		return RenderProfile{ASCII: true, BasicColor: true}
+	}
+
+	if !localeIsUTF8(getenv) {
+		p.ASCII = true
+	}
+
+	colorTerm := strings.ToLower(getenv("COLORTERM"))
+	if colorTerm == "" && !strings.Contains(term, "256color") &&
+		!strings.Contains(term, "direct") && !strings.Contains(term, "kitty") {
+		p.BasicColor = true
+	}
+
+	return p
+}
+
+// localeIsUTF8 applies POSIX locale precedence: LC_ALL, then LC_CTYPE, then LANG.
+func localeIsUTF8(getenv func(string) string) bool {
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := getenv(key); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
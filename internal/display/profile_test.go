@@ -0,0 +1,115 @@
+package display
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func envMap(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func TestDetectRenderProfile_ModernTerminal(t *testing.T) {
+	p := detectRenderProfile("linux", envMap(map[string]string{
+		"TERM": "xterm-256color",
+		"LANG": "en_US.UTF-8",
+	}))
+
+	if p.Degraded() {
+		t.Errorf("expected full profile, got %+v", p)
+	}
+}
+
+func TestDetectRenderProfile_NonUTF8Locale(t *testing.T) {
+	p := detectRenderProfile("linux", envMap(map[string]string{
+		"TERM":   "xterm-256color",
+		"LANG":   "en_US.UTF-8",
+		"LC_ALL": "C",
+	}))
+
+	if !p.ASCII {
+		t.Error("expected ASCII when LC_ALL overrides to a non-UTF-8 locale")
+	}
+	if p.BasicColor {
+		t.Error("256-color terminal should keep full colors")
+	}
+}
+
+func TestDetectRenderProfile_BasicColorTerminal(t *testing.T) {
+	p := detectRenderProfile("linux", envMap(map[string]string{
+		"TERM": "xterm",
+		"LANG": "en_US.UTF-8",
+	}))
+
+	if p.ASCII {
+		t.Error("UTF-8 locale should keep Unicode glyphs")
+	}
+	if !p.BasicColor {
+		t.Error("expected basic colors for TERM=xterm without COLORTERM")
+	}
+}
+
+func TestDetectRenderProfile_ColortermEnablesFullColor(t *testing.T) {
+	p := detectRenderProfile("linux", envMap(map[string]string{
+		"TERM":      "xterm",
+		"COLORTERM": "truecolor",
+		"LANG":      "en_US.UTF-8",
+	}))
+
+	if p.BasicColor {
+		t.Error("COLORTERM should enable full colors")
+	}
+}
+
+func TestDetectRenderProfile_LinuxConsole(t *testing.T) {
+	p := detectRenderProfile("linux", envMap(map[string]string{
+		"TERM": "linux",
+		"LANG": "en_US.UTF-8",
+	}))
+
+	if !p.ASCII || !p.BasicColor {
+		t.Errorf("expected fully degraded profile on the Linux console, got %+v", p)
+	}
+}
+
+func TestDetectRenderProfile_WindowsWithoutTerm(t *testing.T) {
+	p := detectRenderProfile("windows", envMap(nil))
+
+	if p.Degraded() {
+		t.Errorf("expected full profile on Windows consoles, got %+v", p)
+	}
+}
+
+func TestApplyRenderProfile_ASCIIRendersOnlyASCII(t *testing.T) {
+	ApplyRenderProfile(RenderProfile{ASCII: true})
+	defer ApplyRenderProfile(RenderProfile{})
+
+	a := hop.NewTraceResult("example.com", "10.0.0.2")
+	a.Source = "Local"
+	h := hop.NewHop(1)
+	h.AddProbe(net.ParseIP("10.0.0.1"), 5*time.Millisecond)
+	a.AddHop(h)
+	b := hop.NewTraceResult("example.com", "10.0.0.2")
+	b.Source = "Remote"
+	b.AddHop(h)
+
+	var buf bytes.Buffer
+	r := NewCompareRenderer(&buf, true)
+	if err := r.RenderAll([]*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, c := range buf.String() {
+		if c > 127 {
+			t.Fatalf("found non-ASCII rune %q in output:\n%s", c, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "|") {
+		t.Error("expected ASCII column separators")
+	}
+}
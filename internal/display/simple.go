@@ -15,6 +15,7 @@ type SimpleRenderer struct {
 	ShowASN      bool
 	ShowHostname bool
 	ShowDecode   bool
+	ShowHints    bool // Show interface/location hints parsed from hostnames
 }
 
 // NewSimpleRenderer creates a new SimpleRenderer with default settings.
@@ -66,6 +67,21 @@ func (r *SimpleRenderer) RenderHop(h *hop.Hop) string {
 			parts = append(parts, fmt.Sprintf("[AS%d]", h.Enrichment.ASN))
 		}
 
+		// Internet Exchange peering LAN
+		if h.Enrichment.IX != "" {
+			parts = append(parts, fmt.Sprintf("[IX:%s]", h.Enrichment.IX))
+		}
+
+		// IANA special-purpose range
+		if h.Enrichment.Special != "" {
+			parts = append(parts, fmt.Sprintf("[SPECIAL:%s]", h.Enrichment.Special))
+		}
+
+		// Blocklist listings (--reputation)
+		for _, l := range h.Enrichment.Reputation {
+			parts = append(parts, l.Tag())
+		}
+
 		// RTTs
 		rtts := r.formatProbeRTTs(h)
 		parts = append(parts, rtts)
@@ -77,16 +93,35 @@ func (r *SimpleRenderer) RenderHop(h *hop.Hop) string {
 			}
 		}
 
+		// Segment routing domain, segment list on a detail line
+		if sr := h.SegmentRouting; sr != nil {
+			if sr.Dataplane == hop.SRv6 {
+				parts = append(parts, "[SRv6]")
+			} else {
+				parts = append(parts, "[SR]")
+			}
+		}
+
 		// ICMP code indicator (Dest Unreachable codes)
 		if indicator := r.icmpCodeIndicator(h); indicator != "" {
 			parts = append(parts, indicator)
 		}
 
+		// Protocol that got the answer (--protocol auto)
+		if h.Protocol != "" {
+			parts = append(parts, fmt.Sprintf("[%s]", h.Protocol))
+		}
+
 		// NAT indicator
 		if h.NAT {
 			parts = append(parts, "[NAT]")
 		}
 
+		// Geolocation inconsistent with the RTTs (--geo-validate)
+		if h.GeoMismatch {
+			parts = append(parts, "[GEO!]")
+		}
+
 		// Decode indicator (transport header info)
 		if indicator := r.decodeIndicator(h); indicator != "" {
 			parts = append(parts, indicator)
@@ -98,7 +133,41 @@ func (r *SimpleRenderer) RenderHop(h *hop.Hop) string {
 		parts = append(parts, fmt.Sprintf("[MTU:%d]", h.MTU))
 	}
 
-	return strings.Join(parts, "  ")
+	line := strings.Join(parts, "  ")
+
+	if sr := h.SegmentRouting; sr != nil && len(sr.Segments) > 0 {
+		line += "\n    Segments: " + sr.String()
+	}
+
+	// Origin AS path on a detail line, aligned under the address
+	if bgp := h.Enrichment.BGP; r.ShowASN && bgp != nil && len(bgp.ASPath) > 0 {
+		line += fmt.Sprintf("\n    AS path: %s (%s)", bgp.PathString(), bgpPrefixSummary(bgp))
+	}
+
+	// Hostname hints on a detail line (verbose)
+	if r.ShowHints {
+		var hints []string
+		if h.Enrichment.InterfaceHint != "" {
+			hints = append(hints, "interface "+h.Enrichment.InterfaceHint)
+		}
+		if h.Enrichment.LocationHint != "" {
+			hints = append(hints, "location "+h.Enrichment.LocationHint)
+		}
+		if len(hints) > 0 {
+			line += "\n    PTR hints: " + strings.Join(hints, ", ")
+		}
+	}
+
+	return line
+}
+
+// bgpPrefixSummary formats the announcing prefix and its visibility,
+// e.g. "8.8.8.0/24, 98% visible".
+func bgpPrefixSummary(bgp *hop.BGPInfo) string {
+	if bgp.Visibility <= 0 {
+		return bgp.Prefix
+	}
+	return fmt.Sprintf("%s, %.0f%% visible", bgp.Prefix, bgp.Visibility)
 }
 
 // collectUniqueIPs returns unique IP strings from probes.
@@ -173,9 +242,9 @@ func (r *SimpleRenderer) decodeIndicator(h *hop.Hop) string {
 			parts = append(parts, "[DF]")
 		}
 		if ti.TCPSrcPort != 0 {
-			parts = append(parts, fmt.Sprintf("[%d→%d]", ti.TCPSrcPort, ti.TCPDstPort))
+			parts = append(parts, fmt.Sprintf("[%d%s%d]", ti.TCPSrcPort, glyphs.Arrow, ti.TCPDstPort))
 		} else if ti.UDPSrcPort != 0 {
-			parts = append(parts, fmt.Sprintf("[%d→%d]", ti.UDPSrcPort, ti.UDPDstPort))
+			parts = append(parts, fmt.Sprintf("[%d%s%d]", ti.UDPSrcPort, glyphs.Arrow, ti.UDPDstPort))
 		}
 		if ti.TCPFlagsStr != "" {
 			parts = append(parts, fmt.Sprintf("[TCP:%s]", ti.TCPFlagsStr))
@@ -187,6 +256,19 @@ func (r *SimpleRenderer) decodeIndicator(h *hop.Hop) string {
 	return ""
 }
 
+// RenderASLatency writes where the trace's latency accumulates, one line per
+// AS. Nothing is written when no hop has a known AS.
+func RenderASLatency(w io.Writer, tr *hop.TraceResult) {
+	segs := tr.LatencyByAS()
+	if len(segs) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nLatency by AS:")
+	for _, seg := range segs {
+		fmt.Fprintf(w, "  %s\n", seg)
+	}
+}
+
 // RenderTrace renders a complete trace result to the writer.
 func (r *SimpleRenderer) RenderTrace(w io.Writer, tr *hop.TraceResult) {
 	// Header
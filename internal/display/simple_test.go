@@ -170,6 +170,19 @@ func TestSimpleRenderer_RenderHop_ShowsNAT(t *testing.T) {
 	}
 }
 
+func TestSimpleRenderer_RenderHop_ShowsProtocol(t *testing.T) {
+	r := NewSimpleRenderer()
+	h := hop.NewHop(3)
+	h.AddProbe(net.ParseIP("10.0.0.3"), 5*time.Millisecond)
+	h.Protocol = "udp"
+
+	result := r.RenderHop(h)
+
+	if !strings.Contains(result, "[udp]") {
+		t.Errorf("expected [udp] in output, got %q", result)
+	}
+}
+
 func TestSimpleRenderer_RenderHop_ShowsMTU(t *testing.T) {
 	r := NewSimpleRenderer()
 	h := hop.NewHop(1)
@@ -253,3 +266,67 @@ func TestSimpleRenderer_FormatRTT_FormatsMilliseconds(t *testing.T) {
 		t.Errorf("expected '0.50ms', got %q", result)
 	}
 }
+
+func TestSimpleRenderer_RenderHop_ShowsASPath(t *testing.T) {
+	r := NewSimpleRenderer()
+	h := hop.NewHop(5)
+	h.AddProbe(net.ParseIP("8.8.8.8"), 10*time.Millisecond)
+	h.SetEnrichment(hop.Enrichment{
+		ASN: 15169,
+		BGP: &hop.BGPInfo{Prefix: "8.8.8.0/24", OriginASN: 15169, ASPath: []uint32{3356, 15169}, Visibility: 98.2},
+	})
+
+	result := r.RenderHop(h)
+	if !strings.Contains(result, "\n    AS path: 3356 15169 (8.8.8.0/24, 98% visible)") {
+		t.Errorf("expected AS path detail line, got %q", result)
+	}
+
+	r.ShowASN = false
+	if strings.Contains(r.RenderHop(h), "AS path") {
+		t.Error("expected no AS path when ASN display is disabled")
+	}
+}
+
+func TestSimpleRenderer_RenderHop_ShowsIX(t *testing.T) {
+	r := NewSimpleRenderer()
+	h := hop.NewHop(4)
+	h.AddProbe(net.ParseIP("80.81.192.1"), 10*time.Millisecond)
+	h.SetEnrichment(hop.Enrichment{ASN: 6695, IX: "DE-CIX Frankfurt"})
+
+	result := r.RenderHop(h)
+	if !strings.Contains(result, "[AS6695]  [IX:DE-CIX Frankfurt]") {
+		t.Errorf("expected IX tag after ASN, got %q", result)
+	}
+}
+
+func TestSimpleRenderer_RenderHop_ShowsPTRHintsWhenVerbose(t *testing.T) {
+	r := NewSimpleRenderer()
+	h := hop.NewHop(6)
+	h.AddProbe(net.ParseIP("154.54.56.1"), 10*time.Millisecond)
+	h.SetEnrichment(hop.Enrichment{
+		Hostname:      "be2101.ccr41.fra03.atlas.cogentco.com",
+		InterfaceHint: "be2101 (LAG, core)",
+		LocationHint:  "Frankfurt, DE (fra)",
+	})
+
+	if strings.Contains(r.RenderHop(h), "PTR hints") {
+		t.Error("expected no PTR hints without ShowHints")
+	}
+
+	r.ShowHints = true
+	result := r.RenderHop(h)
+	if !strings.Contains(result, "\n    PTR hints: interface be2101 (LAG, core), location Frankfurt, DE (fra)") {
+		t.Errorf("expected PTR hints detail line, got %q", result)
+	}
+}
+
+func TestSimpleRenderer_RenderHop_ShowsGeoMismatch(t *testing.T) {
+	r := NewSimpleRenderer()
+	h := hop.NewHop(5)
+	h.AddProbe(net.ParseIP("192.0.2.1"), 12*time.Millisecond)
+	h.GeoMismatch = true
+
+	if result := r.RenderHop(h); !strings.Contains(result, "[GEO!]") {
+		t.Errorf("expected [GEO!] badge, got %q", result)
+	}
+}
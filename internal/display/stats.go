@@ -3,6 +3,7 @@ package display
 import (
 	"math"
 	"net"
+	"slices"
 	"sort"
 	"time"
 
@@ -14,49 +15,140 @@ type IPInfo struct {
 	IP         net.IP
 	Count      int
 	Enrichment hop.Enrichment
+	RTT        IPRTTStats
 }
 
-// RTTHistorySize is the number of RTT samples to keep for sparkline display.
+// IPRTTStats aggregates the RTTs of the probes answered by one IP at a hop.
+type IPRTTStats struct {
+	Best  time.Duration
+	Worst time.Duration
+	Sum   time.Duration
+	Last  time.Duration
+}
+
+// Avg returns the average RTT over count probes.
+func (r IPRTTStats) Avg(count int) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return r.Sum / time.Duration(count)
+}
+
+// RTTHistorySize is the default number of RTT samples kept per hop, and the
+// width of the sparkline column.
 const RTTHistorySize = 10
 
+// RecentProbesSize is the number of probe results kept for the hop detail pane.
+const RecentProbesSize = 50
+
+// RTTSample is a single probe outcome in a hop's history.
+type RTTSample struct {
+	RTT  time.Duration
+	Lost bool
+	IP   net.IP    // Responding address (nil when lost)
+	At   time.Time // When the result was recorded
+}
+
 // HopStats aggregates statistics for a single TTL across multiple trace cycles.
 // This is used by the MTR-style continuous tracing mode.
 type HopStats struct {
-	TTL           int
-	Sent          int
-	Recv          int
-	LastIP        net.IP
-	BestRTT       time.Duration
-	WorstRTT      time.Duration
-	SumRTT        time.Duration // For calculating avg
-	LastRTT       time.Duration
-	RTTHistory    []time.Duration // Ring buffer for sparkline
-	Enrichment    hop.Enrichment
-	MPLS          []hop.MPLSLabel
-	IPCounts        map[string]int           // IP string -> probe count
-	IPEnrichments   map[string]hop.Enrichment // IP string -> enrichment
-	RateLimited     bool                     // Hop is likely rate-limiting ICMP
-	IPHistory       []string                 // Bounded ring buffer of IP strings (cap 100)
-	TransitionCount int                      // Number of IP transitions observed
-	LastICMPType    int                      // Last ICMP type seen (for code reporting)
-	LastICMPCode    int                      // Last ICMP code seen (for code reporting)
-	TTLManipulated  bool                     // Original datagram TTL mismatch detected
-	FlowPaths         map[int]map[string]int   // flowID → IP string → hit count
-	ECMPClassified    string                   // "per_flow", "per_packet", "unknown", or ""
-	LastTransportInfo *hop.TransportInfo       // Last decoded transport header info
+	TTL               int
+	Sent              int
+	Recv              int
+	LastIP            net.IP
+	BestRTT           time.Duration
+	WorstRTT          time.Duration
+	SumRTT            time.Duration // For calculating avg
+	LastRTT           time.Duration
+	Outliers          int             // Answered probes the RTT outlier filter set aside
+	FilteredBest      time.Duration   // BestRTT without outliers
+	FilteredSum       time.Duration   // SumRTT without outliers
+	RTTHistory        []time.Duration // Ring buffer for sparkline and StdDev
+	Samples           []RTTSample     // Ring buffer of probes including timeouts, for the RTT chart
+	Enrichment        hop.Enrichment
+	MPLS              []hop.MPLSLabel
+	SegmentRouting    *hop.SegmentRouting       // Segment routing domain (nil if none seen)
+	IPCounts          map[string]int            // IP string -> probe count
+	IPEnrichments     map[string]hop.Enrichment // IP string -> enrichment
+	IPRTTs            map[string]IPRTTStats     // IP string -> RTT stats
+	Recent            []RTTSample               // Ring buffer of the last RecentProbesSize probes
+	NAT               bool                      // NAT detected at this hop
+	MTU               int                       // Discovered MTU at this hop (0 = unknown)
+	RateLimited       bool                      // Hop is likely rate-limiting ICMP
+	IPHistory         []string                  // Bounded ring buffer of IP strings (cap 100)
+	TransitionCount   int                       // Number of IP transitions observed
+	LastChange        time.Time                 // When an address new to this TTL last answered (zero = never)
+	LastICMPType      int                       // Last ICMP type seen (for code reporting)
+	LastICMPCode      int                       // Last ICMP code seen (for code reporting)
+	TTLManipulated    bool                      // Original datagram TTL mismatch detected
+	FlowPaths         map[int]map[string]int    // flowID → IP string → hit count
+	ECMPClassified    string                    // "per_flow", "per_packet", "unknown", or ""
+	LastTransportInfo *hop.TransportInfo        // Last decoded transport header info
+	LossRuns          int                       // Number of runs of consecutive timeouts
+	MaxLossRun        int                       // Longest run of consecutive timeouts
+	CorrelatedCycles  int                       // Cycles in which this hop and the final hop were both probed
+	LossCycles        int                       // Correlated cycles in which this hop lost a probe
+	FinalLossCycles   int                       // Correlated cycles in which the final hop lost a probe
+	SharedLossCycles  int                       // Correlated cycles in which both lost a probe
+	historySize       int                       // Capacity of RTTHistory and Samples
+	lossRun           int                       // Length of the current run of timeouts
+	lastLossStart     int                       // Probe number the last loss run started at
+	lossGaps          []int                     // Ring buffer of probes between loss run starts
+	cycleSent         int                       // Probes sent in the current cycle
+	cycleLost         int                       // Probes lost in the current cycle
 }
 
 // NewHopStats creates a new HopStats for the given TTL.
 func NewHopStats(ttl int) *HopStats {
+	return NewHopStatsWithHistory(ttl, RTTHistorySize)
+}
+
+// NewHopStatsWithHistory creates a new HopStats that keeps the last size
+// samples. Sizes below 1 fall back to RTTHistorySize.
+func NewHopStatsWithHistory(ttl, size int) *HopStats {
+	if size < 1 {
+		size = RTTHistorySize
+	}
 	return &HopStats{
 		TTL:           ttl,
-		RTTHistory:    make([]time.Duration, 0, RTTHistorySize),
+		RTTHistory:    make([]time.Duration, 0, size),
 		IPCounts:      make(map[string]int),
 		IPEnrichments: make(map[string]hop.Enrichment),
+		IPRTTs:        make(map[string]IPRTTStats),
 		FlowPaths:     make(map[int]map[string]int),
+		historySize:   size,
+	}
+}
+
+// HistorySize returns the number of samples kept for this hop.
+func (s *HopStats) HistorySize() int {
+	if s.historySize < 1 {
+		return RTTHistorySize
 	}
+	return s.historySize
 }
 
+// pushRing appends v to buf, dropping the oldest entry once size is reached.
+func pushRing[T any](buf []T, v T, size int) []T {
+	if len(buf) >= size {
+		copy(buf, buf[len(buf)-size+1:])
+		buf = buf[:size]
+		buf[size-1] = v
+		return buf
+	}
+	return append(buf, v)
+}
+
+// RTT outlier filter: a probe whose RTT is further from the median of the
+// recent samples than outlierMADs median absolute deviations (and than
+// outlierFloor) is set aside from the filtered statistics. The measuring
+// host being descheduled between send and receive produces such spikes.
+const (
+	outlierMADs       = 5.2 // Modified z-score above 3.5 (Iglewicz and Hoaglin)
+	outlierFloor      = time.Millisecond
+	outlierMinSamples = 5 // Recent samples needed before filtering
+)
+
 // IPHistorySize is the maximum number of IP entries to keep for route flap detection.
 const IPHistorySize = 100
 
@@ -64,6 +156,8 @@ const IPHistorySize = 100
 func (s *HopStats) AddProbe(ip net.IP, rtt time.Duration) {
 	s.Sent++
 	s.Recv++
+	s.cycleSent++
+	s.lossRun = 0
 	s.LastIP = ip
 	s.LastRTT = rtt
 	s.SumRTT += rtt
@@ -82,6 +176,29 @@ func (s *HopStats) AddProbe(ip net.IP, rtt time.Duration) {
 		} else {
 			s.IPHistory = append(s.IPHistory, ipStr)
 		}
+
+		// Per-IP RTT stats for the detail pane
+		if s.IPRTTs == nil {
+			s.IPRTTs = make(map[string]IPRTTStats)
+		}
+		r := s.IPRTTs[ipStr]
+		if r.Best == 0 || rtt < r.Best {
+			r.Best = rtt
+		}
+		r.Worst = max(r.Worst, rtt)
+		r.Sum += rtt
+		r.Last = rtt
+		s.IPRTTs[ipStr] = r
+	}
+
+	// Filtered stats skip RTTs far off the recent ones
+	if isRTTOutlier(s.RTTHistory, rtt) {
+		s.Outliers++
+	} else {
+		s.FilteredSum += rtt
+		if s.FilteredBest == 0 || rtt < s.FilteredBest {
+			s.FilteredBest = rtt
+		}
 	}
 
 	// Update best/worst
@@ -92,19 +209,30 @@ func (s *HopStats) AddProbe(ip net.IP, rtt time.Duration) {
 		s.WorstRTT = rtt
 	}
 
-	// Add to history (ring buffer)
-	if len(s.RTTHistory) >= RTTHistorySize {
-		// Shift left, drop oldest
-		copy(s.RTTHistory, s.RTTHistory[1:])
-		s.RTTHistory[RTTHistorySize-1] = rtt
-	} else {
-		s.RTTHistory = append(s.RTTHistory, rtt)
-	}
+	// Add to history (ring buffers)
+	s.RTTHistory = pushRing(s.RTTHistory, rtt, s.HistorySize())
+	sample := RTTSample{RTT: rtt, IP: ip, At: time.Now()}
+	s.Samples = pushRing(s.Samples, sample, s.HistorySize())
+	s.Recent = pushRing(s.Recent, sample, RecentProbesSize)
 }
 
 // AddTimeout records a probe that timed out.
 func (s *HopStats) AddTimeout() {
 	s.Sent++
+	s.cycleSent++
+	s.cycleLost++
+	if s.lossRun == 0 {
+		if s.LossRuns > 0 {
+			s.lossGaps = pushRing(s.lossGaps, s.Sent-s.lastLossStart, lossGapHistorySize)
+		}
+		s.lastLossStart = s.Sent
+		s.LossRuns++
+	}
+	s.lossRun++
+	s.MaxLossRun = max(s.MaxLossRun, s.lossRun)
+	sample := RTTSample{Lost: true, At: time.Now()}
+	s.Samples = pushRing(s.Samples, sample, s.HistorySize())
+	s.Recent = pushRing(s.Recent, sample, RecentProbesSize)
 }
 
 // LossPercent calculates the packet loss percentage.
@@ -123,38 +251,119 @@ func (s *HopStats) AvgRTT() time.Duration {
 	return s.SumRTT / time.Duration(s.Recv)
 }
 
+// FilteredAvgRTT calculates the average RTT without outliers.
+func (s *HopStats) FilteredAvgRTT() time.Duration {
+	if n := s.Recv - s.Outliers; n > 0 {
+		return s.FilteredSum / time.Duration(n)
+	}
+	return 0
+}
+
 // StdDev calculates the standard deviation of RTT values.
 func (s *HopStats) StdDev() time.Duration {
-	if len(s.RTTHistory) < 2 {
+	return stdDev(s.RTTHistory)
+}
+
+// FilteredStdDev calculates the standard deviation of the RTT values that
+// are not outliers among the recent samples.
+func (s *HopStats) FilteredStdDev() time.Duration {
+	if len(s.RTTHistory) < outlierMinSamples {
+		return stdDev(s.RTTHistory)
+	}
+	kept := make([]time.Duration, 0, len(s.RTTHistory))
+	for _, rtt := range s.RTTHistory {
+		if !isRTTOutlier(s.RTTHistory, rtt) {
+			kept = append(kept, rtt)
+		}
+	}
+	return stdDev(kept)
+}
+
+// stdDev returns the standard deviation of rtts.
+func stdDev(rtts []time.Duration) time.Duration {
+	if len(rtts) < 2 {
 		return 0
 	}
 	var sum float64
-	for _, rtt := range s.RTTHistory {
+	for _, rtt := range rtts {
 		sum += float64(rtt)
 	}
-	mean := sum / float64(len(s.RTTHistory))
+	mean := sum / float64(len(rtts))
 	var variance float64
-	for _, rtt := range s.RTTHistory {
+	for _, rtt := range rtts {
 		d := float64(rtt) - mean
 		variance += d * d
 	}
-	variance /= float64(len(s.RTTHistory))
+	variance /= float64(len(rtts))
 	return time.Duration(math.Sqrt(variance))
 }
 
-// Reset clears all statistics while preserving the TTL.
+// isRTTOutlier reports whether rtt is an outlier among the samples of
+// window: further from their median than outlierMADs median absolute
+// deviations and than outlierFloor. Windows of fewer than outlierMinSamples
+// samples have no outliers.
+func isRTTOutlier(window []time.Duration, rtt time.Duration) bool {
+	if len(window) < outlierMinSamples {
+		return false
+	}
+	median := medianDuration(window)
+	deviations := make([]time.Duration, len(window))
+	for i, w := range window {
+		deviations[i] = absDuration(w - median)
+	}
+	mad := medianDuration(deviations)
+	d := absDuration(rtt - median)
+	return d > outlierFloor && float64(d) > outlierMADs*float64(mad)
+}
+
+// medianDuration returns the median of durations, which must not be empty.
+func medianDuration(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// absDuration returns the absolute value of d.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Reset clears all statistics while preserving the TTL and history size.
 func (s *HopStats) Reset() {
-	ttl := s.TTL
+	ttl, size := s.TTL, s.HistorySize()
 	*s = HopStats{
 		TTL:           ttl,
-		RTTHistory:    make([]time.Duration, 0, RTTHistorySize),
+		RTTHistory:    make([]time.Duration, 0, size),
 		IPCounts:      make(map[string]int),
 		IPEnrichments: make(map[string]hop.Enrichment),
+		IPRTTs:        make(map[string]IPRTTStats),
 		IPHistory:     make([]string, 0, IPHistorySize),
 		FlowPaths:     make(map[int]map[string]int),
+		historySize:   size,
 	}
 }
 
+// ClearCounters clears the probe statistics but keeps what identifies the
+// hop: its last address, enrichment, MPLS labels and per-hop findings.
+func (s *HopStats) ClearCounters() {
+	kept := *s
+	s.Reset()
+	s.LastIP = kept.LastIP
+	s.Enrichment = kept.Enrichment
+	s.IPEnrichments = kept.IPEnrichments
+	s.MPLS = kept.MPLS
+	s.SegmentRouting = kept.SegmentRouting
+	s.NAT = kept.NAT
+	s.MTU = kept.MTU
+}
+
 // SetEnrichment sets the enrichment data for this hop.
 func (s *HopStats) SetEnrichment(e hop.Enrichment) {
 	s.Enrichment = e
@@ -236,6 +445,7 @@ func (s *HopStats) SortedIPs() []IPInfo {
 		info := IPInfo{
 			IP:    net.ParseIP(ipStr),
 			Count: count,
+			RTT:   s.IPRTTs[ipStr],
 		}
 		if e, ok := s.IPEnrichments[ipStr]; ok {
 			info.Enrichment = e
@@ -0,0 +1,90 @@
+package display
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// feedLoss records one probe per character of pattern: 'x' is a timeout,
+// anything else a reply.
+func feedLoss(s *HopStats, pattern string) {
+	for _, c := range pattern {
+		if c == 'x' {
+			s.AddTimeout()
+		} else {
+			s.AddProbe(net.ParseIP("10.0.0.1"), time.Millisecond)
+		}
+	}
+}
+
+func TestHopStats_LossPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    LossPattern
+	}{
+		{"no loss", strings.Repeat(".", 20), LossNone},
+		{"too few probes", "..x.x..", LossNone},
+		{"silent hop", strings.Repeat("x", 20), LossNone},
+		{"periodic", strings.Repeat(".........x", 5), LossPeriodic},
+		{"bursty", "....................xxxx.........xxx......", LossBursty},
+		{"random", ".x...x.x.....x..x........x..", LossRandom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewHopStats(1)
+			feedLoss(s, tt.pattern)
+			if got := s.LossPattern(); got != tt.want {
+				t.Errorf("LossPattern() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHopStats_LossRuns(t *testing.T) {
+	s := NewHopStats(1)
+	feedLoss(s, "..xx...xxx.x")
+	if s.LossRuns != 3 {
+		t.Errorf("LossRuns = %d, want 3", s.LossRuns)
+	}
+	if s.MaxLossRun != 3 {
+		t.Errorf("MaxLossRun = %d, want 3", s.MaxLossRun)
+	}
+
+	s.Reset()
+	if s.LossRuns != 0 || s.MaxLossRun != 0 || len(s.lossGaps) != 0 {
+		t.Errorf("Reset() kept loss runs: %d runs, max %d, %d gaps", s.LossRuns, s.MaxLossRun, len(s.lossGaps))
+	}
+}
+
+func TestHopStats_LossPatternTag(t *testing.T) {
+	s := NewHopStats(1)
+	feedLoss(s, strings.Repeat(".........x", 5))
+	if got := s.LossPatternTag(); got != "[loss:periodic, ICMP limit]" {
+		t.Errorf("periodic tag = %q", got)
+	}
+
+	s = NewHopStats(1)
+	feedLoss(s, ".x...x.x.....x..x........x..")
+	if got := s.LossPatternTag(); got != "[loss:random]" {
+		t.Errorf("random tag = %q", got)
+	}
+	// Loss the downstream hops do not share is the router's ICMP, not forwarding
+	s.RateLimited = true
+	if got := s.LossPatternTag(); got != "[loss:random, ICMP limit]" {
+		t.Errorf("rate-limited tag = %q", got)
+	}
+}
+
+func TestMTRModel_PlainText_LossPatternTag(t *testing.T) {
+	model := NewMTRModel("google.com", "8.8.8.8")
+	for _, c := range strings.Repeat(".........x", 5) {
+		model.handleProbeResult(ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: time.Millisecond, Timeout: c == 'x'})
+	}
+
+	if out := model.PlainText(); !strings.Contains(out, "[loss:periodic, ICMP limit]") {
+		t.Errorf("report missing loss pattern tag:\n%s", out)
+	}
+}
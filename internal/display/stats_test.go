@@ -204,6 +204,46 @@ func TestHopStats_RTTHistory_RingBuffer(t *testing.T) {
 	}
 }
 
+func TestHopStats_HistorySize_Configurable(t *testing.T) {
+	stats := NewHopStatsWithHistory(1, 300)
+
+	for i := 1; i <= 350; i++ {
+		if i%50 == 0 {
+			stats.AddTimeout()
+			continue
+		}
+		stats.AddProbe(net.ParseIP("10.0.0.1"), time.Duration(i)*time.Millisecond)
+	}
+
+	if len(stats.RTTHistory) != 300 {
+		t.Errorf("expected RTTHistory length 300, got %d", len(stats.RTTHistory))
+	}
+	if len(stats.Samples) != 300 {
+		t.Fatalf("expected 300 samples, got %d", len(stats.Samples))
+	}
+	// Samples 51..350, with timeouts at 100, 150, ... 350
+	if stats.Samples[0].RTT != 51*time.Millisecond {
+		t.Errorf("expected oldest sample 51ms, got %v", stats.Samples[0].RTT)
+	}
+	if !stats.Samples[49].Lost || !stats.Samples[299].Lost {
+		t.Error("expected timeouts recorded as lost samples")
+	}
+
+	stats.Reset()
+	if stats.HistorySize() != 300 {
+		t.Errorf("expected history size kept across reset, got %d", stats.HistorySize())
+	}
+	if len(stats.Samples) != 0 {
+		t.Errorf("expected no samples after reset, got %d", len(stats.Samples))
+	}
+}
+
+func TestNewHopStatsWithHistory_DefaultsInvalidSize(t *testing.T) {
+	if got := NewHopStatsWithHistory(1, 0).HistorySize(); got != RTTHistorySize {
+		t.Errorf("expected default history size %d, got %d", RTTHistorySize, got)
+	}
+}
+
 func TestHopStats_StdDev(t *testing.T) {
 	tests := []struct {
 		name string
@@ -238,6 +278,55 @@ func TestHopStats_StdDev(t *testing.T) {
 	}
 }
 
+func TestHopStats_OutlierFilter(t *testing.T) {
+	s := NewHopStats(1)
+	ip := net.ParseIP("1.1.1.1")
+	for _, ms := range []int{10, 11, 10, 12, 11, 250, 10, 11} {
+		s.AddProbe(ip, time.Duration(ms)*time.Millisecond)
+	}
+
+	if s.Outliers != 1 {
+		t.Fatalf("Outliers = %d, want the 250ms spike only", s.Outliers)
+	}
+	if got, want := s.FilteredAvgRTT(), 75*time.Millisecond/7; got != want {
+		t.Errorf("FilteredAvgRTT() = %v, want %v", got, want)
+	}
+	if s.AvgRTT() < 40*time.Millisecond {
+		t.Errorf("AvgRTT() = %v, want the raw average to include the spike", s.AvgRTT())
+	}
+	if s.FilteredBest != 10*time.Millisecond || s.WorstRTT != 250*time.Millisecond {
+		t.Errorf("FilteredBest = %v, WorstRTT = %v", s.FilteredBest, s.WorstRTT)
+	}
+	if got := s.FilteredStdDev(); got > time.Millisecond {
+		t.Errorf("FilteredStdDev() = %v, want the spike left out", got)
+	}
+	if s.StdDev() < 50*time.Millisecond {
+		t.Errorf("StdDev() = %v, want the raw deviation to include the spike", s.StdDev())
+	}
+}
+
+func TestHopStats_OutlierFilter_NeedsSamples(t *testing.T) {
+	s := NewHopStats(1)
+	ip := net.ParseIP("1.1.1.1")
+	for _, ms := range []int{10, 10, 10, 250} {
+		s.AddProbe(ip, time.Duration(ms)*time.Millisecond)
+	}
+	if s.Outliers != 0 || s.FilteredAvgRTT() != s.AvgRTT() {
+		t.Errorf("expected no filtering before %d samples, got %d outliers", outlierMinSamples, s.Outliers)
+	}
+}
+
+func TestHopStats_OutlierFilter_IgnoresSubMillisecondJitter(t *testing.T) {
+	s := NewHopStats(1)
+	ip := net.ParseIP("1.1.1.1")
+	for _, us := range []int{1000, 1000, 1000, 1000, 1000, 1800} {
+		s.AddProbe(ip, time.Duration(us)*time.Microsecond)
+	}
+	if s.Outliers != 0 {
+		t.Errorf("expected jitter below %v kept, got %d outliers", outlierFloor, s.Outliers)
+	}
+}
+
 func TestHopStats_SetEnrichment(t *testing.T) {
 	stats := NewHopStats(1)
 	enrichment := hop.Enrichment{
@@ -471,3 +560,21 @@ func TestHopStats_Reset_ClearsECMP(t *testing.T) {
 		t.Errorf("expected TTL 1 preserved after reset, got %d", stats.TTL)
 	}
 }
+
+func TestHopStats_Recent_KeepsLastProbes(t *testing.T) {
+	stats := NewHopStats(1)
+	for i := 1; i <= RecentProbesSize+5; i++ {
+		stats.AddProbe(net.ParseIP("10.0.0.1"), time.Duration(i)*time.Millisecond)
+	}
+	stats.AddTimeout()
+
+	if len(stats.Recent) != RecentProbesSize {
+		t.Fatalf("expected %d recent probes, got %d", RecentProbesSize, len(stats.Recent))
+	}
+	if !stats.Recent[RecentProbesSize-1].Lost {
+		t.Error("expected the newest probe to be the timeout")
+	}
+	if got := stats.Recent[0].RTT; got != 7*time.Millisecond {
+		t.Errorf("expected oldest recent probe 7ms, got %v", got)
+	}
+}
@@ -0,0 +1,191 @@
+package display
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is the color palette of the TUI and the compare view. Colors are
+// ANSI 256-color numbers ("205") or hex RGB ("#ff5f87"); terminals with
+// fewer colors get the nearest match.
+type Theme struct {
+	Base     string   `json:"base,omitempty"`   // Theme whose colors fill unset fields (user themes)
+	Title    string   `json:"title,omitempty"`  // Title and spinner
+	Header   string   `json:"header,omitempty"` // Column headers
+	Hop      string   `json:"hop,omitempty"`    // Hop numbers and plain text
+	IP       string   `json:"ip,omitempty"`
+	Hostname string   `json:"hostname,omitempty"`
+	RTT      string   `json:"rtt,omitempty"`     // Latencies and graphs
+	Timeout  string   `json:"timeout,omitempty"` // Timeouts, loss and warnings
+	ASN      string   `json:"asn,omitempty"`     // ASN, ECMP and other annotations
+	MPLS     string   `json:"mpls,omitempty"`
+	Status   string   `json:"status,omitempty"`   // Status bar background
+	Complete string   `json:"complete,omitempty"` // Trace complete message
+	Sources  []string `json:"sources,omitempty"`  // Compare mode colors, one per source
+}
+
+// DefaultTheme is the theme used when none is configured.
+const DefaultTheme = "dark"
+
+// builtinThemes are the themes shipped with gtrace.
+var builtinThemes = map[string]Theme{
+	"dark": {
+		Title: "205", Header: "240", Hop: "252", IP: "39", Hostname: "243",
+		RTT: "82", Timeout: "196", ASN: "208", MPLS: "141", Status: "235", Complete: "82",
+		Sources: []string{"39", "208", "141", "82", "205"},
+	},
+	"light": {
+		Title: "125", Header: "244", Hop: "235", IP: "25", Hostname: "242",
+		RTT: "28", Timeout: "160", ASN: "130", MPLS: "91", Status: "254", Complete: "28",
+		Sources: []string{"25", "130", "91", "28", "125"},
+	},
+	"high-contrast": {
+		Title: "231", Header: "231", Hop: "231", IP: "51", Hostname: "231",
+		RTT: "46", Timeout: "196", ASN: "226", MPLS: "201", Status: "16", Complete: "46",
+		Sources: []string{"51", "226", "201", "46", "231"},
+	},
+	// Okabe-Ito palette: distinguishable with the common color vision deficiencies
+	"colorblind": {
+		Title: "#CC79A7", Header: "#999999", Hop: "252", IP: "#56B4E9", Hostname: "#999999",
+		RTT: "#009E73", Timeout: "#D55E00", ASN: "#E69F00", MPLS: "#0072B2", Status: "235", Complete: "#009E73",
+		Sources: []string{"#56B4E9", "#E69F00", "#0072B2", "#009E73", "#CC79A7"},
+	},
+}
+
+// activeTheme is the theme applied by ApplyTheme.
+var activeTheme = builtinThemes[DefaultTheme]
+
+// ThemeNames returns the names of the built-in themes, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveTheme returns the named theme, looking in custom (user-defined
+// themes) before the built-in ones. Unset colors of a custom theme are
+// taken from its base theme, or from DefaultTheme.
+func ResolveTheme(name string, custom map[string]Theme) (Theme, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	return resolveTheme(name, custom, map[string]bool{})
+}
+
+func resolveTheme(name string, custom map[string]Theme, seen map[string]bool) (Theme, error) {
+	t, ok := custom[name]
+	if !ok {
+		builtin, ok := builtinThemes[name]
+		if !ok {
+			return Theme{}, fmt.Errorf("unknown theme %q (built-in themes: %s)", name, strings.Join(ThemeNames(), ", "))
+		}
+		return builtin, nil
+	}
+	if seen[name] {
+		return Theme{}, fmt.Errorf("theme %q inherits from itself", name)
+	}
+	seen[name] = true
+
+	base := t.Base
+	if base == "" {
+		base = DefaultTheme
+	}
+	var parent Theme
+	if base == name {
+		// A user theme named after a built-in one customizes it
+		builtin, ok := builtinThemes[name]
+		if !ok {
+			return Theme{}, fmt.Errorf("theme %q inherits from itself", name)
+		}
+		parent = builtin
+	} else {
+		var err error
+		if parent, err = resolveTheme(base, custom, seen); err != nil {
+			return Theme{}, fmt.Errorf("theme %q: %w", name, err)
+		}
+	}
+	t.fill(parent)
+	t.Base = ""
+
+	if err := t.validate(); err != nil {
+		return Theme{}, fmt.Errorf("theme %q: %w", name, err)
+	}
+	return t, nil
+}
+
+// fill copies the colors of base into the unset fields of t.
+func (t *Theme) fill(base Theme) {
+	for _, f := range []struct{ dst, src *string }{
+		{&t.Title, &base.Title}, {&t.Header, &base.Header}, {&t.Hop, &base.Hop},
+		{&t.IP, &base.IP}, {&t.Hostname, &base.Hostname}, {&t.RTT, &base.RTT},
+		{&t.Timeout, &base.Timeout}, {&t.ASN, &base.ASN}, {&t.MPLS, &base.MPLS},
+		{&t.Status, &base.Status}, {&t.Complete, &base.Complete},
+	} {
+		if *f.dst == "" {
+			*f.dst = *f.src
+		}
+	}
+	if len(t.Sources) == 0 {
+		t.Sources = base.Sources
+	}
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validate checks that every color is an ANSI 256-color number or hex RGB.
+func (t Theme) validate() error {
+	colors := map[string]string{
+		"title": t.Title, "header": t.Header, "hop": t.Hop, "ip": t.IP, "hostname": t.Hostname,
+		"rtt": t.RTT, "timeout": t.Timeout, "asn": t.ASN, "mpls": t.MPLS, "status": t.Status,
+		"complete": t.Complete,
+	}
+	for i, c := range t.Sources {
+		colors[fmt.Sprintf("sources[%d]", i)] = c
+	}
+	for field, c := range colors {
+		if n, err := strconv.Atoi(c); err == nil && n >= 0 && n <= 255 {
+			continue
+		}
+		if hexColor.MatchString(c) {
+			continue
+		}
+		return fmt.Errorf("invalid %s color %q: use 0-255 or #rrggbb", field, c)
+	}
+	return nil
+}
+
+// ApplyTheme switches all renderers to the theme's colors. Like
+// ApplyRenderProfile, it must be called before any TUI is started.
+func ApplyTheme(t Theme) {
+	activeTheme = t
+
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(t.Title))
+	headerStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(t.Header))
+	hopStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Hop))
+	ipStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.IP))
+	hostnameStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Hostname))
+	rttStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.RTT))
+	timeoutStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Timeout))
+	asnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.ASN))
+	mplsStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.MPLS))
+	statusStyle = lipgloss.NewStyle().Background(lipgloss.Color(t.Status)).Padding(0, 1)
+	completeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Complete)).Bold(true)
+
+	sourceColors = make([]lipgloss.Color, len(t.Sources))
+	for i, c := range t.Sources {
+		sourceColors[i] = lipgloss.Color(c)
+	}
+}
+
+// spinnerStyle returns the style of the TUI spinners.
+func spinnerStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(activeTheme.Title))
+}
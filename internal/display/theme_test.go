@@ -0,0 +1,93 @@
+package display
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveTheme_Builtin(t *testing.T) {
+	for _, name := range ThemeNames() {
+		theme, err := ResolveTheme(name, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := theme.validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	theme, err := ResolveTheme("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Title != builtinThemes[DefaultTheme].Title {
+		t.Errorf("empty name should resolve to %s", DefaultTheme)
+	}
+}
+
+func TestResolveTheme_CustomInheritsBase(t *testing.T) {
+	custom := map[string]Theme{
+		"mine":  {Base: "light", RTT: "#00ff00"},
+		"other": {Base: "mine", IP: "33"},
+		"dark":  {Timeout: "9"},
+	}
+
+	theme, err := ResolveTheme("other", custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	light := builtinThemes["light"]
+	if theme.IP != "33" || theme.RTT != "#00ff00" || theme.Header != light.Header {
+		t.Errorf("unexpected inheritance: %+v", theme)
+	}
+	if len(theme.Sources) != len(light.Sources) {
+		t.Errorf("expected sources from the light theme, got %v", theme.Sources)
+	}
+
+	// A custom theme named after a built-in one customizes it
+	theme, err = ResolveTheme("dark", custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Timeout != "9" || theme.RTT != builtinThemes["dark"].RTT {
+		t.Errorf("expected customized dark theme, got %+v", theme)
+	}
+}
+
+func TestResolveTheme_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		theme   string
+		custom  map[string]Theme
+		wantErr string
+	}{
+		{"unknown", "neon", nil, `unknown theme "neon"`},
+		{"unknown base", "mine", map[string]Theme{"mine": {Base: "neon"}}, `unknown theme "neon"`},
+		{"cycle", "a", map[string]Theme{"a": {Base: "b"}, "b": {Base: "a"}}, "inherits from itself"},
+		{"color name", "mine", map[string]Theme{"mine": {RTT: "green"}}, `invalid rtt color "green"`},
+		{"out of range", "mine", map[string]Theme{"mine": {IP: "300"}}, `invalid ip color "300"`},
+		{"bad source", "mine", map[string]Theme{"mine": {Sources: []string{"#12"}}}, "invalid sources[0] color"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ResolveTheme(tt.theme, tt.custom)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestApplyTheme_SourceColors(t *testing.T) {
+	defer ApplyTheme(builtinThemes[DefaultTheme])
+
+	theme, err := ResolveTheme("colorblind", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ApplyTheme(theme)
+	if len(sourceColors) != len(theme.Sources) || string(sourceColors[0]) != theme.Sources[0] {
+		t.Errorf("source colors not applied: %v", sourceColors)
+	}
+}
@@ -2,6 +2,7 @@ package display
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"time"
@@ -9,51 +10,28 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/hervehildenbrand/gtrace/internal/export"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
-// Styles for the TUI
+// Styles for the TUI, set from the active theme by ApplyTheme
 var (
-	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("205"))
-
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("240"))
-
-	hopStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("252"))
-
-	ipStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("39"))
-
-	hostnameStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("243"))
-
-	rttStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("82"))
-
-	timeoutStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("196"))
-
-	asnStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("208"))
-
-	mplsStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("141"))
-
-	statusStyle = lipgloss.NewStyle().
-			Background(lipgloss.Color("235")).
-			Padding(0, 1)
-
-	completeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("82")).
-			Bold(true)
+	titleStyle    lipgloss.Style
+	headerStyle   lipgloss.Style
+	hopStyle      lipgloss.Style
+	ipStyle       lipgloss.Style
+	hostnameStyle lipgloss.Style
+	rttStyle      lipgloss.Style
+	timeoutStyle  lipgloss.Style
+	asnStyle      lipgloss.Style
+	mplsStyle     lipgloss.Style
+	statusStyle   lipgloss.Style
+	completeStyle lipgloss.Style
 )
 
-// Sparkline characters (from low to high)
-var sparkChars = []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+func init() {
+	ApplyTheme(builtinThemes[DefaultTheme])
+}
 
 // StatusInfo contains status bar information
 type StatusInfo struct {
@@ -87,13 +65,20 @@ type TUIModel struct {
 	width     int
 	height    int
 	startTime time.Time
+
+	showGraph   bool // ECMP topology graph instead of the hop table
+	graphScroll int  // First graph line shown
+
+	export   exportPrompt // Path and format overlay of the 'e' export
+	notice   string       // Outcome of the last export, shown in the status bar
+	showHelp bool         // Help overlay over the current view
 }
 
 // NewTUIModel creates a new TUI model
 func NewTUIModel(target, targetIP string) *TUIModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	s.Style = spinnerStyle()
 
 	return &TUIModel{
 		target:    target,
@@ -128,13 +113,44 @@ func (m *TUIModel) Init() tea.Cmd {
 func (m *TUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// The export overlay takes every key while it is open
+		if m.exporting() {
+			m.handleExportKey(msg)
+			return m, nil
+		}
+		// Any key but Ctrl+C closes the help overlay
+		if m.helpShown() && msg.String() != "ctrl+c" {
+			m.mu.Lock()
+			m.showHelp = false
+			m.mu.Unlock()
+			return m, nil
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "g":
+			m.mu.Lock()
+			if m.showGraph || m.getStatusInfo().HasECMP {
+				m.showGraph = !m.showGraph
+				m.graphScroll = 0
+			}
+			m.mu.Unlock()
+		case "esc":
+			m.mu.Lock()
+			m.showGraph = false
+			m.mu.Unlock()
+		case "up", "k":
+			m.scrollGraph(-1)
+		case "down", "j":
+			m.scrollGraph(1)
 		case "e":
-			// TODO: Export
+			m.mu.Lock()
+			m.export.open("trace", m.target, time.Now())
+			m.mu.Unlock()
 		case "?":
-			// TODO: Help
+			m.mu.Lock()
+			m.showHelp = true
+			m.mu.Unlock()
 		}
 
 	case tea.WindowSizeMsg:
@@ -161,50 +177,167 @@ func (m *TUIModel) View() string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if m.showHelp {
+		return overlayCenter(m.viewLocked(), renderHelp(traceHelp), m.width, m.height)
+	}
+	return m.viewLocked()
+}
+
+// helpShown reports whether the help overlay is open.
+func (m *TUIModel) helpShown() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.showHelp
+}
+
+// viewLocked renders the trace view. Must be called with lock held.
+func (m *TUIModel) viewLocked() string {
 	var b strings.Builder
 
 	// Title
-	title := fmt.Sprintf("gtr → %s (%s)", m.target, m.targetIP)
+	title := fmt.Sprintf("gtr %s %s (%s)", glyphs.Arrow, m.target, m.targetIP)
 	b.WriteString(titleStyle.Render(title))
 	b.WriteString("\n\n")
 
-	// Header
-	header := fmt.Sprintf("%-4s %-16s %-20s %-8s %-6s %-8s",
-		"Hop", "IP Address", "Hostname/ASN", "Loss", "Avg", "Graph")
-	b.WriteString(headerStyle.Render(header))
-	b.WriteString("\n")
-	b.WriteString(strings.Repeat("─", 70))
-	b.WriteString("\n")
-
-	// Hops
-	for _, h := range m.hops {
-		b.WriteString(m.formatHopRow(h))
+	if m.showGraph {
+		for _, line := range m.graphWindow() {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	} else {
+		// Header
+		header := fmt.Sprintf("%-4s %-16s %-20s %-8s %-6s %-8s",
+			"Hop", "IP Address", "Hostname/ASN", "Loss", "Avg", "Graph")
+		b.WriteString(headerStyle.Render(header))
 		b.WriteString("\n")
+		b.WriteString(strings.Repeat(glyphs.HLine, 70))
+		b.WriteString("\n")
+
+		// Hops
+		for _, h := range m.hops {
+			b.WriteString(m.formatHopRow(h))
+			b.WriteString("\n")
+		}
 	}
 
 	// Status bar
 	b.WriteString("\n")
-	b.WriteString(strings.Repeat("─", 70))
+	b.WriteString(strings.Repeat(glyphs.HLine, 70))
 	b.WriteString("\n")
 	b.WriteString(m.renderStatusBar())
 
-	// Help
+	// Help, or the export overlay in its place
 	b.WriteString("\n")
+	if m.export.active {
+		b.WriteString(m.export.view())
+		return b.String()
+	}
 	if m.complete {
 		if m.reached {
-			b.WriteString(completeStyle.Render("✓ Target reached"))
+			b.WriteString(completeStyle.Render(glyphs.Check + " Target reached"))
 		} else {
-			b.WriteString(timeoutStyle.Render("✗ Target not reached"))
+			b.WriteString(timeoutStyle.Render(glyphs.Fail + " Target not reached"))
 		}
-		b.WriteString(" | Press 'q' to quit")
+		b.WriteString(" | " + m.graphHelp() + "Press 'e' to export, '?' help, 'q' to quit")
 	} else {
 		b.WriteString(m.spinner.View())
-		b.WriteString(" Tracing... Press 'q' to cancel")
+		b.WriteString(" Tracing... " + m.graphHelp() + "Press 'e' to export, '?' help, 'q' to cancel")
 	}
 
 	return b.String()
 }
 
+// exporting reports whether the export overlay is open.
+func (m *TUIModel) exporting() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.export.active
+}
+
+// handleExportKey passes msg to the export overlay and, once the export is
+// confirmed, writes the hops received so far to the chosen path.
+func (m *TUIModel) handleExportKey(msg tea.KeyMsg) {
+	m.mu.Lock()
+	confirmed := m.export.handleKey(msg)
+	path, format := m.export.path, m.export.selected()
+	m.mu.Unlock()
+	if !confirmed {
+		return
+	}
+
+	err := writeExportFile(path, func(w io.Writer) error {
+		exporter, err := export.NewExporter(format)
+		if err != nil {
+			return err
+		}
+		return exporter.Export(w, m.traceResult())
+	})
+	m.mu.Lock()
+	m.notice = exportNotice(path, format, err)
+	m.mu.Unlock()
+}
+
+// traceResult returns the hops received so far as a trace result.
+func (m *TUIModel) traceResult() *hop.TraceResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tr := hop.NewTraceResult(m.target, m.targetIP)
+	for _, h := range m.hops {
+		tr.AddHop(h)
+	}
+	tr.ReachedTarget = m.reached
+	tr.StartTime = m.startTime
+	return tr
+}
+
+// graphHelp returns the help for the ECMP graph key, shown once ECMP is
+// detected. Must be called with lock held.
+func (m *TUIModel) graphHelp() string {
+	switch {
+	case m.showGraph:
+		return "'g' hop table, 'j'/'k' scroll | "
+	case m.getStatusInfo().HasECMP:
+		return "'g' ECMP graph | "
+	}
+	return ""
+}
+
+// graphRows returns how many graph lines fit on screen (all of them when
+// the terminal size is unknown). Must be called with lock held.
+func (m *TUIModel) graphRows() int {
+	if m.height <= 0 {
+		return 0
+	}
+	return max(m.height-7, 3)
+}
+
+// graphWindow returns the visible part of the ECMP graph. Must be called
+// with lock held.
+func (m *TUIModel) graphWindow() []string {
+	lines := buildECMPGraph(m.hops).render()
+	rows := m.graphRows()
+	if rows == 0 || len(lines) <= rows {
+		return lines
+	}
+	start := min(m.graphScroll, len(lines)-rows)
+	return lines[start : start+rows]
+}
+
+// scrollGraph moves the ECMP graph by delta lines.
+func (m *TUIModel) scrollGraph(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.showGraph {
+		return
+	}
+	total := len(buildECMPGraph(m.hops).render())
+	maxScroll := 0
+	if rows := m.graphRows(); rows > 0 && total > rows {
+		maxScroll = total - rows
+	}
+	m.graphScroll = min(max(m.graphScroll+delta, 0), maxScroll)
+}
+
 // formatHopRow formats a single hop row
 func (m *TUIModel) formatHopRow(h *hop.Hop) string {
 	var b strings.Builder
@@ -347,8 +480,11 @@ func (m *TUIModel) renderStatusBar() string {
 
 	elapsed := time.Since(m.startTime).Round(time.Millisecond)
 	parts = append(parts, fmt.Sprintf("Time: %v", elapsed))
+	if m.notice != "" {
+		parts = append(parts, m.notice)
+	}
 
-	return statusStyle.Render(strings.Join(parts, " │ "))
+	return statusStyle.Render(strings.Join(parts, " "+glyphs.VLine+" "))
 }
 
 // getStatusInfo collects status information
@@ -1,10 +1,15 @@
 package display
 
 import (
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -105,3 +110,98 @@ func TestTUIModel_GetStatusInfo_ReturnsInfo(t *testing.T) {
 		t.Error("expected HasMPLS to be true")
 	}
 }
+
+func TestTUIModel_ECMPGraphKey(t *testing.T) {
+	model := NewTUIModel("google.com", "8.8.8.8")
+	model.AddHop(flowHop(1, "10.0.0.1", "10.0.0.1"))
+
+	// No ECMP yet: 'g' does nothing
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	if model.showGraph {
+		t.Fatal("expected no graph without ECMP")
+	}
+
+	model.AddHop(flowHop(2, "10.1.0.1", "10.1.0.2"))
+	if !strings.Contains(model.View(), "'g' ECMP graph") {
+		t.Error("expected graph key in help once ECMP is detected")
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	view := model.View()
+	if !model.showGraph || !strings.Contains(view, "branches 2 ways") {
+		t.Errorf("expected ECMP graph view, got:\n%s", view)
+	}
+	if strings.Contains(view, "IP Address") {
+		t.Error("expected graph to replace the hop table")
+	}
+
+	model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.showGraph {
+		t.Error("expected esc to close the graph")
+	}
+}
+
+func TestTUIModel_ECMPGraphScroll(t *testing.T) {
+	model := NewTUIModel("google.com", "8.8.8.8")
+	for ttl := 1; ttl <= 20; ttl++ {
+		model.AddHop(flowHop(ttl, fmt.Sprintf("10.%d.0.1", ttl), fmt.Sprintf("10.%d.0.2", ttl)))
+	}
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 17})
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+
+	if got := len(model.graphWindow()); got != 10 {
+		t.Fatalf("expected 10 visible graph lines, got %d", got)
+	}
+	for i := 0; i < 500; i++ {
+		model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	lines := model.graphWindow()
+	if !strings.Contains(lines[len(lines)-1], "10.20.0.2") {
+		t.Errorf("expected last graph line at the bottom, got %q", lines[len(lines)-1])
+	}
+}
+
+func TestTUIModel_ExportKeyWritesCurrentHops(t *testing.T) {
+	model := NewTUIModel("google.com", "8.8.8.8")
+	h := hop.NewHop(1)
+	h.AddProbe(net.ParseIP("192.168.1.1"), 5*time.Millisecond)
+	model.Update(HopMsg{Hop: h})
+
+	path := filepath.Join(t.TempDir(), "quit.json")
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if !strings.Contains(model.View(), "Export to: gtrace-trace-google.com-") {
+		t.Fatal("expected the export overlay")
+	}
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlU})
+	// 'q' is part of the path while the overlay is open, not quit
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(path)})
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the export file: %v", err)
+	}
+	if !strings.Contains(string(data), "192.168.1.1") {
+		t.Errorf("expected the hop in the export, got:\n%s", data)
+	}
+	if !strings.Contains(model.View(), "Exported json to "+path) {
+		t.Error("expected the export confirmed in the status bar")
+	}
+}
+
+func TestTUIModel_HelpOverlayClosesOnAnyKey(t *testing.T) {
+	model := NewTUIModel("google.com", "8.8.8.8")
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+
+	model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	view := model.View()
+	if !strings.Contains(view, "gtrace help") || !strings.Contains(view, "google.com") {
+		t.Fatal("expected the help over the trace view")
+	}
+
+	// 'q' closes the help instead of quitting
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+	if cmd != nil || strings.Contains(model.View(), "gtrace help") {
+		t.Error("expected the key to only close the help")
+	}
+}
@@ -0,0 +1,433 @@
+// Package dnsdiag breaks down how a hostname resolves before it is traced:
+// which resolver answered, the CNAME chain, record TTLs and every address,
+// and whether the answers depend on EDNS Client Subnet. "Wrong POP" problems
+// often start with a resolver steering the client to a distant edge.
+package dnsdiag
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ResolvConfPath is the resolver configuration read for the system resolvers.
+const ResolvConfPath = "/etc/resolv.conf"
+
+// QueryTimeout bounds each query to a resolver.
+const QueryTimeout = 3 * time.Second
+
+// ednsUDPSize is the EDNS buffer size advertised, the DNS Flag Day 2020 value.
+const ednsUDPSize = 1232
+
+// ednsClientSubnet is the EDNS option code of Client Subnet (RFC 7871).
+const ednsClientSubnet = 8
+
+// Record is one resource record of an answer.
+type Record struct {
+	Name string
+	Type string // A, AAAA or CNAME
+	TTL  uint32
+	Data string // Address or canonical name
+}
+
+// Query is the answer to one question.
+type Query struct {
+	Type    string // A or AAAA
+	Rcode   string
+	RTT     time.Duration
+	Records []Record
+	Err     error
+}
+
+// Addresses returns the addresses of the answer.
+func (q Query) Addresses() []string {
+	var addrs []string
+	for _, r := range q.Records {
+		if r.Type == "A" || r.Type == "AAAA" {
+			addrs = append(addrs, r.Data)
+		}
+	}
+	return addrs
+}
+
+// ECS is the Client Subnet behavior of the resolver for the target: the
+// address answer is asked again with a /0 subnet, which tells authoritative
+// servers not to tailor the answer to the client's network.
+type ECS struct {
+	Type      string   // Record type asked again
+	Echoed    bool     // The resolver returned a Client Subnet option
+	Scope     int      // Scope prefix of the returned option
+	Addresses []string // Addresses of the /0 answer
+	Differs   bool     // The /0 answer differs from the plain one
+	Err       error
+}
+
+// Report is the resolution breakdown of one hostname.
+type Report struct {
+	Target   string
+	Resolver string   // Resolver that answered
+	Failed   []string // Resolvers that did not answer
+	Queries  []Query  // A then AAAA
+	ECS      *ECS
+}
+
+// CNAMEs returns the CNAME chain of the answers, in order.
+func (r *Report) CNAMEs() []Record {
+	var chain []Record
+	for _, q := range r.Queries {
+		for _, rec := range q.Records {
+			if rec.Type == "CNAME" && !slices.ContainsFunc(chain, func(c Record) bool { return c.Name == rec.Name }) {
+				chain = append(chain, rec)
+			}
+		}
+	}
+	return chain
+}
+
+// Addresses returns every address of the answers, IPv4 first.
+func (r *Report) Addresses() []string {
+	var addrs []string
+	for _, q := range r.Queries {
+		addrs = append(addrs, q.Addresses()...)
+	}
+	return addrs
+}
+
+// SystemResolvers returns the nameservers of the resolver configuration at
+// path, or the loopback resolver when none is listed, as the Go resolver
+// does.
+func SystemResolvers(path string) []*Server {
+	var addrs []string
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+				addrs = append(addrs, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+		f.Close()
+	}
+	if len(addrs) == 0 {
+		addrs = []string{"127.0.0.1:53", "[::1]:53"}
+	}
+	servers := make([]*Server, len(addrs))
+	for i, addr := range addrs {
+		servers[i] = &Server{Transport: TransportUDP, Addr: addr}
+	}
+	return servers
+}
+
+// Diagnose resolves target with the first of servers that answers, and
+// checks its Client Subnet behavior.
+func Diagnose(ctx context.Context, target string, servers []*Server) (*Report, error) {
+	report := &Report{Target: target}
+	name := target
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hostname %q: %w", target, err)
+	}
+
+	var resolver *Server
+	var lastErr error
+	for _, server := range servers {
+		q, err := query(ctx, server, qname, dnsmessage.TypeA, false)
+		if err != nil {
+			report.Failed = append(report.Failed, server.String())
+			lastErr = err
+			continue
+		}
+		resolver = server
+		report.Resolver = server.String()
+		report.Queries = append(report.Queries, q.Query)
+		break
+	}
+	if resolver == nil {
+		return report, fmt.Errorf("no resolver answered: %w", lastErr)
+	}
+
+	aaaa, err := query(ctx, resolver, qname, dnsmessage.TypeAAAA, false)
+	if err != nil {
+		aaaa.Query = Query{Type: "AAAA", Err: err}
+	}
+	report.Queries = append(report.Queries, aaaa.Query)
+
+	// Ask the first family with addresses again, with a /0 client subnet
+	for _, q := range report.Queries {
+		if len(q.Addresses()) == 0 {
+			continue
+		}
+		typ := dnsmessage.TypeA
+		if q.Type == "AAAA" {
+			typ = dnsmessage.TypeAAAA
+		}
+		report.ECS = checkECS(ctx, resolver, qname, typ, q.Addresses())
+		break
+	}
+	return report, nil
+}
+
+// checkECS asks the question again with a /0 Client Subnet and compares the
+// answer with plain.
+func checkECS(ctx context.Context, server *Server, qname dnsmessage.Name, typ dnsmessage.Type, plain []string) *ECS {
+	ecs := &ECS{Type: typeName(typ)}
+	q, err := query(ctx, server, qname, typ, true)
+	if err != nil {
+		ecs.Err = err
+		return ecs
+	}
+	ecs.Echoed = q.ecsScope >= 0
+	ecs.Scope = max(q.ecsScope, 0)
+	ecs.Addresses = q.Addresses()
+	ecs.Differs = !sameSet(plain, ecs.Addresses)
+	return ecs
+}
+
+// answer is a parsed response with the scope of its Client Subnet option,
+// -1 without one.
+type answer struct {
+	Query
+	ecsScope int
+}
+
+// query sends one question to server.
+func query(ctx context.Context, server *Server, qname dnsmessage.Name, typ dnsmessage.Type, withECS bool) (answer, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	id := randomID()
+	msg, err := buildQuery(id, qname, typ, withECS)
+	if err != nil {
+		return answer{}, err
+	}
+
+	start := time.Now()
+	resp, err := server.Exchange(ctx, msg)
+	if err != nil {
+		return answer{}, err
+	}
+	rtt := time.Since(start)
+
+	a, err := parseAnswer(resp, id, typ)
+	if err != nil {
+		return answer{}, err
+	}
+	a.RTT = rtt
+	return a, nil
+}
+
+// buildQuery returns a recursive query with an EDNS OPT record, carrying a
+// /0 Client Subnet when withECS is set.
+func buildQuery(id uint16, qname dnsmessage.Name, typ dnsmessage.Type, withECS bool) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: typ, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(ednsUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	var options []dnsmessage.Option
+	if withECS {
+		// Family, source prefix length 0, scope prefix length 0, no address bytes
+		family := byte(1)
+		if typ == dnsmessage.TypeAAAA {
+			family = 2
+		}
+		options = append(options, dnsmessage.Option{Code: ednsClientSubnet, Data: []byte{0, family, 0, 0}})
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{Options: options}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// parseAnswer parses the response to query id.
+func parseAnswer(resp []byte, id uint16, typ dnsmessage.Type) (answer, error) {
+	a := answer{Query: Query{Type: typeName(typ)}, ecsScope: -1}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return a, fmt.Errorf("malformed response: %w", err)
+	}
+	if h.ID != id || !h.Response {
+		return a, errors.New("response does not match the query")
+	}
+	a.Rcode = rcodeName(h.RCode)
+	if err := p.SkipAllQuestions(); err != nil {
+		return a, fmt.Errorf("malformed response: %w", err)
+	}
+
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return a, fmt.Errorf("malformed response: %w", err)
+		}
+		rec := Record{Name: strings.TrimSuffix(rh.Name.String(), "."), TTL: rh.TTL}
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return a, fmt.Errorf("malformed response: %w", err)
+			}
+			rec.Type, rec.Data = "A", net.IP(r.A[:]).String()
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return a, fmt.Errorf("malformed response: %w", err)
+			}
+			rec.Type, rec.Data = "AAAA", net.IP(r.AAAA[:]).String()
+		case dnsmessage.TypeCNAME:
+			r, err := p.CNAMEResource()
+			if err != nil {
+				return a, fmt.Errorf("malformed response: %w", err)
+			}
+			rec.Type, rec.Data = "CNAME", strings.TrimSuffix(r.CNAME.String(), ".")
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return a, fmt.Errorf("malformed response: %w", err)
+			}
+			continue
+		}
+		a.Records = append(a.Records, rec)
+	}
+
+	if err := p.SkipAllAuthorities(); err != nil {
+		return a, nil
+	}
+	for {
+		rh, err := p.AdditionalHeader()
+		if err != nil {
+			break
+		}
+		if rh.Type != dnsmessage.TypeOPT {
+			if p.SkipAdditional() != nil {
+				break
+			}
+			continue
+		}
+		opt, err := p.OPTResource()
+		if err != nil {
+			break
+		}
+		for _, o := range opt.Options {
+			if o.Code == ednsClientSubnet && len(o.Data) >= 4 {
+				a.ecsScope = int(o.Data[3])
+			}
+		}
+	}
+	return a, nil
+}
+
+// WriteReport writes the --resolve-debug section of the report.
+func (r *Report) WriteReport(w io.Writer) {
+	fmt.Fprintf(w, "DNS resolution of %s:\n", r.Target)
+	for _, server := range r.Failed {
+		fmt.Fprintf(w, "  Resolver %s did not answer\n", server)
+	}
+	if r.Resolver == "" {
+		return
+	}
+	fmt.Fprintf(w, "  Resolver:  %s\n", r.Resolver)
+
+	for _, c := range r.CNAMEs() {
+		fmt.Fprintf(w, "  CNAME:     %s -> %s (TTL %ds)\n", c.Name, c.Data, c.TTL)
+	}
+	for _, q := range r.Queries {
+		if q.Err != nil {
+			fmt.Fprintf(w, "  %-5s      query failed: %v\n", q.Type+":", q.Err)
+			continue
+		}
+		var addrs []string
+		for _, rec := range q.Records {
+			if rec.Type == q.Type {
+				addrs = append(addrs, fmt.Sprintf("%s (TTL %ds)", rec.Data, rec.TTL))
+			}
+		}
+		if len(addrs) == 0 {
+			addrs = []string{"no records, " + q.Rcode}
+		}
+		fmt.Fprintf(w, "  %-5s      %s in %.1fms\n", q.Type+":", strings.Join(addrs, ", "), float64(q.RTT)/float64(time.Millisecond))
+	}
+
+	switch e := r.ECS; {
+	case e == nil:
+	case e.Err != nil:
+		fmt.Fprintf(w, "  ECS:       %s query with a /0 client subnet failed: %v\n", e.Type, e.Err)
+	default:
+		echo := "not echoed, the resolver ignores or strips client subnets"
+		if e.Echoed {
+			echo = fmt.Sprintf("echoed with scope /%d", e.Scope)
+		}
+		answers := "same answer without a client subnet"
+		if e.Differs {
+			answers = "different answer without a client subnet (" + strings.Join(e.Addresses, ", ") + "): the edge depends on the client's network"
+		}
+		fmt.Fprintf(w, "  ECS:       %s; %s\n", echo, answers)
+	}
+}
+
+// sameSet reports whether a and b hold the same addresses in any order.
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func typeName(t dnsmessage.Type) string {
+	return strings.TrimPrefix(t.String(), "Type")
+}
+
+// rcodeName returns the mnemonic dig prints for c.
+func rcodeName(c dnsmessage.RCode) string {
+	switch c {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", c)
+}
+
+func randomID() uint16 {
+	var b [2]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
@@ -0,0 +1,174 @@
+package dnsdiag
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeResolver answers www.example.com with a CNAME to edge.example.net and
+// an A record; queries carrying a Client Subnet get another address and the
+// option echoed with scope /24.
+func fakeResolver(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := fakeAnswer(buf[:n]); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func fakeAnswer(req []byte) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(req)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	p.SkipAllQuestions()
+	p.SkipAllAnswers()
+	p.SkipAllAuthorities()
+	withECS := false
+	for {
+		rh, err := p.AdditionalHeader()
+		if err != nil {
+			break
+		}
+		if rh.Type != dnsmessage.TypeOPT {
+			p.SkipAdditional()
+			continue
+		}
+		opt, _ := p.OPTResource()
+		for _, o := range opt.Options {
+			withECS = withECS || o.Code == ednsClientSubnet
+		}
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true})
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	edge := dnsmessage.MustNewName("edge.example.net.")
+	b.CNAMEResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 300}, dnsmessage.CNAMEResource{CNAME: edge})
+	if q.Type == dnsmessage.TypeA {
+		addr := [4]byte{192, 0, 2, 1}
+		if withECS {
+			addr = [4]byte{192, 0, 2, 9}
+		}
+		b.AResource(dnsmessage.ResourceHeader{Name: edge, Class: dnsmessage.ClassINET, TTL: 20}, dnsmessage.AResource{A: addr})
+	}
+	b.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	opt.SetEDNS0(ednsUDPSize, dnsmessage.RCodeSuccess, false)
+	var options []dnsmessage.Option
+	if withECS {
+		options = append(options, dnsmessage.Option{Code: ednsClientSubnet, Data: []byte{0, 1, 0, 24}})
+	}
+	b.OPTResource(opt, dnsmessage.OPTResource{Options: options})
+	resp, _ := b.Finish()
+	return resp
+}
+
+func TestDiagnose_BreaksDownResolution(t *testing.T) {
+	server := fakeResolver(t)
+
+	report, err := Diagnose(context.Background(), "www.example.com", []*Server{{Transport: TransportUDP, Addr: server}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Resolver != server {
+		t.Errorf("Resolver = %q, want %q", report.Resolver, server)
+	}
+
+	chain := report.CNAMEs()
+	if len(chain) != 1 || chain[0].Name != "www.example.com" || chain[0].Data != "edge.example.net" || chain[0].TTL != 300 {
+		t.Errorf("CNAMEs = %+v, want www.example.com -> edge.example.net TTL 300", chain)
+	}
+	if got := report.Addresses(); !slices.Equal(got, []string{"192.0.2.1"}) {
+		t.Errorf("Addresses = %v, want [192.0.2.1]", got)
+	}
+	if len(report.Queries) != 2 || report.Queries[1].Type != "AAAA" || report.Queries[1].Rcode != "NOERROR" {
+		t.Errorf("Queries = %+v, want an empty AAAA answer", report.Queries)
+	}
+
+	ecs := report.ECS
+	if ecs == nil || !ecs.Echoed || ecs.Scope != 24 || !ecs.Differs {
+		t.Fatalf("ECS = %+v, want echoed scope /24 with a different answer", ecs)
+	}
+
+	var buf bytes.Buffer
+	report.WriteReport(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"Resolver:  " + server,
+		"www.example.com -> edge.example.net (TTL 300s)",
+		"192.0.2.1 (TTL 20s)",
+		"no records, NOERROR",
+		"echoed with scope /24",
+		"192.0.2.9",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDiagnose_FallsBackToNextResolver(t *testing.T) {
+	server := fakeResolver(t)
+
+	// Nothing listens on the discard port, so the query is refused or times out
+	dead := "127.0.0.1:9"
+	servers := []*Server{{Transport: TransportUDP, Addr: dead}, {Transport: TransportUDP, Addr: server}}
+	report, err := Diagnose(context.Background(), "www.example.com", servers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Resolver != server || !slices.Equal(report.Failed, []string{dead}) {
+		t.Errorf("Resolver = %q, Failed = %v, want %q after %q", report.Resolver, report.Failed, server, dead)
+	}
+}
+
+func TestSystemResolvers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "# comment\nsearch example.com\nnameserver 192.0.2.53\nnameserver 2001:db8::53\nnameserver bogus\n"
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range SystemResolvers(path) {
+		got = append(got, s.String())
+	}
+	want := []string{"192.0.2.53:53", "[2001:db8::53]:53"}
+	if !slices.Equal(got, want) {
+		t.Errorf("SystemResolvers = %v, want %v", got, want)
+	}
+
+	if got := SystemResolvers(filepath.Join(t.TempDir(), "missing")); len(got) == 0 {
+		t.Error("expected the loopback resolver without a configuration")
+	}
+}
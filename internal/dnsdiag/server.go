@@ -0,0 +1,306 @@
+package dnsdiag
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Transports a Server is queried over.
+const (
+	TransportUDP   = "udp"   // Plain DNS, over TCP when the answer is truncated
+	TransportTLS   = "tls"   // DNS over TLS (RFC 7858)
+	TransportHTTPS = "https" // DNS over HTTPS (RFC 8484)
+)
+
+// dohMaxResponse bounds the DNS over HTTPS response body read.
+const dohMaxResponse = 65535
+
+// Server is a DNS resolver queried directly: a system nameserver or the
+// --dns server.
+type Server struct {
+	Transport  string
+	Addr       string // host:port, plain DNS and DNS over TLS
+	ServerName string // Name verified in the TLS certificate
+	URL        string // DNS over HTTPS endpoint
+}
+
+// ParseServer parses a --dns value: an address for plain DNS (9.9.9.9,
+// 9.9.9.9:5353, [2620:fe::fe]:53), tls://host[:853] for DNS over TLS or an
+// https:// URL for DNS over HTTPS.
+func ParseServer(spec string) (*Server, error) {
+	switch {
+	case strings.HasPrefix(spec, "https://"):
+		u, err := url.Parse(spec)
+		if err != nil || u.Host == "" {
+			return nil, errors.New("DNS over HTTPS needs an https://host/path URL")
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/dns-query"
+		}
+		return &Server{Transport: TransportHTTPS, URL: u.String()}, nil
+	case strings.HasPrefix(spec, "tls://"):
+		host, addr, err := hostPort(strings.TrimPrefix(spec, "tls://"), "853")
+		if err != nil {
+			return nil, err
+		}
+		return &Server{Transport: TransportTLS, Addr: addr, ServerName: host}, nil
+	case strings.Contains(spec, "://"):
+		return nil, errors.New("must be an address, tls://host or an https:// URL")
+	}
+	_, addr, err := hostPort(spec, "53")
+	if err != nil {
+		return nil, err
+	}
+	return &Server{Transport: TransportUDP, Addr: addr}, nil
+}
+
+// hostPort splits host[:port] and adds the default port.
+func hostPort(s, defaultPort string) (host, addr string, err error) {
+	if s == "" {
+		return "", "", errors.New("missing server address")
+	}
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		return h, s, validPort(p)
+	}
+	host = strings.Trim(s, "[]")
+	if strings.ContainsAny(host, "/[]") {
+		return "", "", fmt.Errorf("invalid server address %q", s)
+	}
+	return host, net.JoinHostPort(host, defaultPort), nil
+}
+
+func validPort(p string) error {
+	if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", p)
+	}
+	return nil
+}
+
+// String returns the server as given to --dns.
+func (s *Server) String() string {
+	switch s.Transport {
+	case TransportHTTPS:
+		return s.URL
+	case TransportTLS:
+		return "tls://" + s.Addr
+	}
+	return s.Addr
+}
+
+// Resolver returns a resolver that sends every query to s, for target
+// resolution and reverse DNS.
+func (s *Server) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			switch s.Transport {
+			case TransportHTTPS:
+				// Not a PacketConn, so the resolver frames messages as over TCP
+				return &dohConn{server: s, ctx: ctx}, nil
+			case TransportTLS:
+				return s.dialTLS(ctx)
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, s.Addr)
+		},
+	}
+}
+
+// Exchange sends one query message to s and returns the response.
+func (s *Server) Exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	switch s.Transport {
+	case TransportHTTPS:
+		return s.exchangeHTTPS(ctx, msg)
+	case TransportTLS:
+		conn, err := s.dialTLS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return streamExchange(ctx, conn, msg)
+	}
+
+	resp, err := s.exchangeUDP(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	var p dnsmessage.Parser
+	if h, err := p.Start(resp); err == nil && h.Truncated {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", s.Addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return streamExchange(ctx, conn, msg)
+	}
+	return resp, nil
+}
+
+func (s *Server) exchangeUDP(ctx context.Context, msg []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, 65535)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp[:n], nil
+}
+
+func (s *Server) dialTLS(ctx context.Context) (net.Conn, error) {
+	d := tls.Dialer{Config: &tls.Config{ServerName: s.ServerName}}
+	return d.DialContext(ctx, "tcp", s.Addr)
+}
+
+// exchangeHTTPS posts msg to the DNS over HTTPS endpoint.
+func (s *Server) exchangeHTTPS(ctx context.Context, msg []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, dohMaxResponse))
+}
+
+// streamExchange sends msg with the length prefix DNS uses over TCP and TLS,
+// and reads the response.
+func streamExchange(ctx context.Context, conn net.Conn, msg []byte) ([]byte, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(framed, msg...)); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err := io.ReadFull(conn, resp)
+	return resp, err
+}
+
+// dohConn lets the Go resolver query a DNS over HTTPS server: it reads the
+// length-prefixed queries the resolver writes to stream connections, posts
+// each one and serves the length-prefixed responses back.
+type dohConn struct {
+	server *Server
+	ctx    context.Context
+
+	mu       sync.Mutex
+	out      []byte // Query bytes written, not yet complete
+	in       bytes.Buffer
+	deadline time.Time
+	closed   bool
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	c.out = append(c.out, b...)
+	for len(c.out) >= 2 {
+		n := int(binary.BigEndian.Uint16(c.out))
+		if len(c.out) < 2+n {
+			break
+		}
+		msg := c.out[2 : 2+n]
+		c.out = c.out[2+n:]
+		if err := c.post(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// post sends one query and queues its framed response for Read.
+func (c *dohConn) post(msg []byte) error {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	resp, err := c.server.exchangeHTTPS(ctx, msg)
+	if err != nil {
+		return err
+	}
+	c.in.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+	c.in.Write(resp)
+	return nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if c.in.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.in.Read(b)
+}
+
+func (c *dohConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr{c.server.URL} }
+
+// dohAddr is the address of a DNS over HTTPS connection: its URL.
+type dohAddr struct{ url string }
+
+func (a dohAddr) Network() string { return TransportHTTPS }
+func (a dohAddr) String() string  { return a.url }
@@ -0,0 +1,92 @@
+package dnsdiag
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		spec      string
+		transport string
+		want      string
+		wantErr   bool
+	}{
+		{"9.9.9.9", TransportUDP, "9.9.9.9:53", false},
+		{"9.9.9.9:5353", TransportUDP, "9.9.9.9:5353", false},
+		{"2620:fe::fe", TransportUDP, "[2620:fe::fe]:53", false},
+		{"[2620:fe::fe]:53", TransportUDP, "[2620:fe::fe]:53", false},
+		{"tls://dns.quad9.net", TransportTLS, "tls://dns.quad9.net:853", false},
+		{"tls://1.1.1.1:8853", TransportTLS, "tls://1.1.1.1:8853", false},
+		{"https://dns.google/dns-query", TransportHTTPS, "https://dns.google/dns-query", false},
+		{"https://cloudflare-dns.com", TransportHTTPS, "https://cloudflare-dns.com/dns-query", false},
+		{"", "", "", true},
+		{"9.9.9.9:99999", "", "", true},
+		{"quic://dns.adguard.com", "", "", true},
+		{"tls://", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseServer(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Transport != tt.transport || s.String() != tt.want {
+				t.Errorf("ParseServer(%q) = %s %s, want %s %s", tt.spec, s.Transport, s, tt.transport, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerResolver_PlainDNS(t *testing.T) {
+	s := &Server{Transport: TransportUDP, Addr: fakeResolver(t)}
+
+	ips, err := s.Resolver().LookupIP(context.Background(), "ip4", "www.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+		t.Errorf("LookupIP = %v, want [192.0.2.1]", ips)
+	}
+}
+
+func TestServerResolver_DNSOverHTTPS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		req, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(fakeAnswer(req))
+	}))
+	defer srv.Close()
+
+	s := &Server{Transport: TransportHTTPS, URL: srv.URL + "/dns-query"}
+
+	ips, err := s.Resolver().LookupIP(context.Background(), "ip4", "www.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+		t.Errorf("LookupIP = %v, want [192.0.2.1]", ips)
+	}
+
+	report, err := Diagnose(context.Background(), "www.example.com", []*Server{s})
+	if err != nil {
+		t.Fatalf("Diagnose: %v", err)
+	}
+	if report.Resolver != s.URL {
+		t.Errorf("Resolver = %q, want %q", report.Resolver, s.URL)
+	}
+}
@@ -27,7 +27,9 @@ type ASNResult struct {
 // ASNLookup performs ASN lookups via Team Cymru DNS.
 type ASNLookup struct {
 	resolver    *net.Resolver
-	ripeBaseURL string // Base URL for RIPE REST DB (overridable for testing)
+	ripeBaseURL string         // Base URL for RIPE REST DB (overridable for testing)
+	local       []*asnDatabase // Local databases, the only source when offline
+	offline     bool           // Never query the network
 }
 
 const defaultRIPEBaseURL = "https://rest.db.ripe.net"
@@ -53,6 +55,10 @@ func (l *ASNLookup) Lookup(ctx context.Context, ip net.IP) (*ASNResult, error) {
 		return nil, errors.New("private IP address")
 	}
 
+	if l.offline {
+		return l.lookupLocal(ip)
+	}
+
 	// Try Team Cymru DNS first
 	result, err := l.lookupCymru(ctx, ip)
 	if err == nil && result.ASN > 0 {
@@ -106,8 +112,8 @@ func (l *ASNLookup) lookupCymru(ctx context.Context, ip net.IP) (*ASNResult, err
 // ipAPIResponse represents the response from ip-api.com
 type ipAPIResponse struct {
 	Status  string `json:"status"`
-	AS      string `json:"as"`      // e.g., "AS3215 Orange S.A."
-	ASName  string `json:"asname"`  // e.g., "Orange S.A."
+	AS      string `json:"as"`     // e.g., "AS3215 Orange S.A."
+	ASName  string `json:"asname"` // e.g., "Orange S.A."
 	ISP     string `json:"isp"`
 	Org     string `json:"org"`
 	Country string `json:"countryCode"`
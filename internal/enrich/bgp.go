@@ -0,0 +1,182 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// DefaultLookingGlassURL is the RIPEstat Data API, which serves RIS route
+// collector data.
+const DefaultLookingGlassURL = "https://stat.ripe.net"
+
+// BGPLookup queries a RIPEstat-compatible looking glass for the prefix
+// announcing an IP, its AS path and its visibility.
+type BGPLookup struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewBGPLookup creates a BGP lookup against RIPEstat.
+func NewBGPLookup() *BGPLookup {
+	return NewBGPLookupWithURL(DefaultLookingGlassURL)
+}
+
+// NewBGPLookupWithURL creates a BGP lookup against a looking glass that
+// serves the RIPEstat looking-glass and routing-status data calls.
+func NewBGPLookupWithURL(baseURL string) *BGPLookup {
+	return &BGPLookup{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// lookingGlassResponse is the RIPEstat looking-glass data call response.
+type lookingGlassResponse struct {
+	Data struct {
+		RRCs []struct {
+			Peers []struct {
+				Prefix    string `json:"prefix"`
+				ASPath    string `json:"as_path"`
+				ASNOrigin string `json:"asn_origin"`
+			} `json:"peers"`
+		} `json:"rrcs"`
+	} `json:"data"`
+}
+
+// routingStatusResponse is the RIPEstat routing-status data call response.
+type routingStatusResponse struct {
+	Data struct {
+		Visibility struct {
+			V4 routingVisibility `json:"v4"`
+			V6 routingVisibility `json:"v6"`
+		} `json:"visibility"`
+	} `json:"data"`
+}
+
+type routingVisibility struct {
+	Seeing int `json:"ris_peers_seeing"`
+	Total  int `json:"total_ris_peers"`
+}
+
+// Lookup returns the BGP routing data for ip, or nil if no collector peer
+// has a route covering it.
+func (l *BGPLookup) Lookup(ctx context.Context, ip net.IP) (*hop.BGPInfo, error) {
+	if ip == nil {
+		return nil, errors.New("nil IP")
+	}
+
+	var lg lookingGlassResponse
+	if err := l.get(ctx, "looking-glass", ip.String(), &lg); err != nil {
+		return nil, err
+	}
+
+	// Tally the paths seen by every collector peer for the most specific prefix
+	var prefix string
+	var prefixBits int
+	counts := make(map[string]int)
+	for _, rrc := range lg.Data.RRCs {
+		for _, peer := range rrc.Peers {
+			_, ipnet, err := net.ParseCIDR(peer.Prefix)
+			if err != nil {
+				continue
+			}
+			bits, _ := ipnet.Mask.Size()
+			if prefix == "" || bits > prefixBits {
+				prefix, prefixBits = ipnet.String(), bits
+				counts = make(map[string]int)
+			}
+			if ipnet.String() == prefix {
+				counts[peer.ASPath]++
+			}
+		}
+	}
+	if prefix == "" {
+		return nil, nil
+	}
+
+	info := &hop.BGPInfo{Prefix: prefix, ASPath: mostCommonPath(counts)}
+	if len(info.ASPath) > 0 {
+		info.OriginASN = info.ASPath[len(info.ASPath)-1]
+	}
+
+	var rs routingStatusResponse
+	if err := l.get(ctx, "routing-status", prefix, &rs); err == nil {
+		v := rs.Data.Visibility.V4
+		if ip.To4() == nil {
+			v = rs.Data.Visibility.V6
+		}
+		if v.Total > 0 {
+			info.Visibility = float64(v.Seeing) / float64(v.Total) * 100
+		}
+	}
+
+	return info, nil
+}
+
+// get fetches a RIPEstat data call for resource and decodes it into v.
+func (l *BGPLookup) get(ctx context.Context, call, resource string, v any) error {
+	u := fmt.Sprintf("%s/data/%s/data.json?resource=%s", l.baseURL, call, url.QueryEscape(resource))
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("looking glass query failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("looking glass query failed: HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("looking glass returned invalid data: %w", err)
+	}
+	return nil
+}
+
+// mostCommonPath picks the AS path seen by most peers, preferring the
+// shorter path on ties, and collapses prepended AS numbers.
+func mostCommonPath(counts map[string]int) []uint32 {
+	var best []uint32
+	bestCount := 0
+	for raw, n := range counts {
+		path := parseASPath(raw)
+		if len(path) == 0 {
+			continue
+		}
+		if n > bestCount || (n == bestCount && (len(path) < len(best) ||
+			len(path) == len(best) && fmt.Sprint(path) < fmt.Sprint(best))) {
+			best, bestCount = path, n
+		}
+	}
+	return best
+}
+
+// parseASPath parses a space-separated AS path, dropping prepends and
+// AS sets ("{64512,64513}").
+func parseASPath(raw string) []uint32 {
+	var path []uint32
+	for _, field := range strings.Fields(raw) {
+		asn, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			continue
+		}
+		if len(path) > 0 && path[len(path)-1] == uint32(asn) {
+			continue
+		}
+		path = append(path, uint32(asn))
+	}
+	return path
+}
@@ -0,0 +1,121 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newTestLookingGlass serves the RIPEstat looking-glass and routing-status
+// data calls for 8.8.8.8.
+func newTestLookingGlass(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data/looking-glass/data.json":
+			if r.URL.Query().Get("resource") != "8.8.8.8" {
+				fmt.Fprint(w, `{"data":{"rrcs":[]}}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"rrcs":[
+				{"rrc":"RRC00","peers":[
+					{"prefix":"8.8.8.0/24","as_path":"3356 15169","asn_origin":"15169"},
+					{"prefix":"8.8.0.0/16","as_path":"174 15169","asn_origin":"15169"},
+					{"prefix":"8.8.8.0/24","as_path":"6939 15169 15169 15169","asn_origin":"15169"}
+				]},
+				{"rrc":"RRC01","peers":[
+					{"prefix":"8.8.8.0/24","as_path":"3356 15169","asn_origin":"15169"}
+				]}
+			]}}`)
+		case "/data/routing-status/data.json":
+			if r.URL.Query().Get("resource") != "8.8.8.0/24" {
+				t.Errorf("routing-status queried for %q, want the announced prefix", r.URL.Query().Get("resource"))
+			}
+			fmt.Fprint(w, `{"data":{"visibility":{
+				"v4":{"ris_peers_seeing":300,"total_ris_peers":320},
+				"v6":{"ris_peers_seeing":0,"total_ris_peers":330}}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestBGPLookup_Lookup(t *testing.T) {
+	srv := newTestLookingGlass(t)
+	defer srv.Close()
+
+	info, err := NewBGPLookupWithURL(srv.URL+"/").Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info == nil {
+		t.Fatal("expected BGP info")
+	}
+	if info.Prefix != "8.8.8.0/24" {
+		t.Errorf("expected most specific prefix 8.8.8.0/24, got %s", info.Prefix)
+	}
+	if want := []uint32{3356, 15169}; !reflect.DeepEqual(info.ASPath, want) {
+		t.Errorf("expected most common path %v, got %v", want, info.ASPath)
+	}
+	if info.OriginASN != 15169 {
+		t.Errorf("expected origin AS15169, got %d", info.OriginASN)
+	}
+	if info.Visibility != 93.75 {
+		t.Errorf("expected visibility 93.75, got %v", info.Visibility)
+	}
+}
+
+func TestBGPLookup_Unannounced(t *testing.T) {
+	srv := newTestLookingGlass(t)
+	defer srv.Close()
+
+	info, err := NewBGPLookupWithURL(srv.URL).Lookup(context.Background(), net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info != nil {
+		t.Errorf("expected nil for an unannounced address, got %+v", info)
+	}
+}
+
+func TestBGPLookup_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := NewBGPLookupWithURL(srv.URL).Lookup(context.Background(), net.ParseIP("8.8.8.8")); err == nil {
+		t.Error("expected error for HTTP 503")
+	}
+}
+
+func TestParseASPath(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []uint32
+	}{
+		{"3356 15169", []uint32{3356, 15169}},
+		{"6939 15169 15169 15169", []uint32{6939, 15169}},
+		{"174 {64512,64513}", []uint32{174}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseASPath(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseASPath(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestMostCommonPath_PrefersShorterOnTie(t *testing.T) {
+	got := mostCommonPath(map[string]int{
+		"1299 3356 15169": 2,
+		"3356 15169":      2,
+	})
+	if want := []uint32{3356, 15169}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,157 @@
+package enrich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// DiskCacheFile is the name of the enrichment cache file in the cache directory.
+const DiskCacheFile = "enrichment.json"
+
+// DefaultDiskCacheTTL is how long cached enrichment results stay valid.
+const DefaultDiskCacheTTL = 7 * 24 * time.Hour
+
+// DefaultCacheDir returns the default cache directory path (~/.gtr/cache).
+func DefaultCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".gtr", "cache"), nil
+}
+
+// diskCacheEntry is a cached enrichment result with its expiry time.
+type diskCacheEntry struct {
+	Enrichment hop.Enrichment `json:"enrichment"`
+	Expires    time.Time      `json:"expires"`
+}
+
+// DiskCache persists enrichment results across runs in a JSON file, so
+// repeated traces over the same path don't re-query Team Cymru, ip-api.com
+// and DNS for every hop. Changes are written by Save.
+type DiskCache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]diskCacheEntry
+	dirty   bool
+}
+
+// OpenDiskCache loads the cache stored in dir. A missing or unreadable cache
+// file starts an empty cache; entries older than ttl are ignored.
+func OpenDiskCache(dir string, ttl time.Duration) *DiskCache {
+	c := &DiskCache{
+		path:    filepath.Join(dir, DiskCacheFile),
+		ttl:     ttl,
+		entries: make(map[string]diskCacheEntry),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		// Corrupt cache: start over, it is rewritten on the next save
+		c.entries = make(map[string]diskCacheEntry)
+	}
+	return c
+}
+
+// Path returns the cache file path.
+func (c *DiskCache) Path() string {
+	return c.path
+}
+
+// Get returns the cached enrichment for key if it has not expired.
+func (c *DiskCache) Get(key string) (*hop.Enrichment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.Expires) {
+		return nil, false
+	}
+	e := entry.Enrichment
+	return &e, true
+}
+
+// Set stores an enrichment result for key.
+func (c *DiskCache) Set(key string, e *hop.Enrichment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = diskCacheEntry{Enrichment: *e, Expires: time.Now().Add(c.ttl)}
+	c.dirty = true
+}
+
+// Len returns the number of entries, including expired ones not yet pruned.
+func (c *DiskCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Save writes the cache to disk if it changed, dropping expired entries.
+// The file is replaced atomically so concurrent runs never read a partial cache.
+func (c *DiskCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.Expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, DiskCacheFile+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// ClearDiskCache removes the cache stored in dir and returns the number of
+// entries it held.
+func ClearDiskCache(dir string) (int, error) {
+	c := OpenDiskCache(dir, DefaultDiskCacheTTL)
+	n := c.Len()
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestDiskCache_PersistsAcrossOpens(t *testing.T) {
+	dir := t.TempDir()
+
+	c := OpenDiskCache(dir, time.Hour)
+	c.Set("8.8.8.8", &hop.Enrichment{ASN: 15169, ASOrg: "GOOGLE", Hostname: "dns.google"})
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	got, ok := OpenDiskCache(dir, time.Hour).Get("8.8.8.8")
+	if !ok {
+		t.Fatal("expected cached entry after reopening")
+	}
+	if got.ASN != 15169 || got.Hostname != "dns.google" {
+		t.Errorf("Get() = %+v", got)
+	}
+}
+
+func TestDiskCache_ExpiredEntriesAreMissesAndPruned(t *testing.T) {
+	dir := t.TempDir()
+
+	c := OpenDiskCache(dir, -time.Minute) // Already expired
+	c.Set("1.1.1.1", &hop.Enrichment{ASN: 13335})
+	if _, ok := c.Get("1.1.1.1"); ok {
+		t.Error("expected expired entry to be a miss")
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if n := OpenDiskCache(dir, time.Hour).Len(); n != 0 {
+		t.Errorf("expected expired entries to be pruned on save, got %d", n)
+	}
+}
+
+func TestDiskCache_CorruptFileStartsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, DiskCacheFile), []byte("{not json"), 0644)
+
+	c := OpenDiskCache(dir, time.Hour)
+	if c.Len() != 0 {
+		t.Errorf("expected empty cache, got %d entries", c.Len())
+	}
+	c.Set("8.8.8.8", &hop.Enrichment{ASN: 15169})
+	if err := c.Save(); err != nil {
+		t.Errorf("expected corrupt cache to be rewritten, got %v", err)
+	}
+}
+
+func TestClearDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	c := OpenDiskCache(dir, time.Hour)
+	c.Set("8.8.8.8", &hop.Enrichment{ASN: 15169})
+	c.Set("1.1.1.1", &hop.Enrichment{ASN: 13335})
+	c.Save()
+
+	n, err := ClearDiskCache(dir)
+	if err != nil || n != 2 {
+		t.Errorf("ClearDiskCache() = %d, %v; want 2, nil", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DiskCacheFile)); !os.IsNotExist(err) {
+		t.Error("expected cache file to be removed")
+	}
+
+	// Clearing an empty cache is not an error
+	if n, err := ClearDiskCache(dir); err != nil || n != 0 {
+		t.Errorf("ClearDiskCache() on empty = %d, %v", n, err)
+	}
+}
+
+func TestEnricher_UsesDiskCache(t *testing.T) {
+	dc := OpenDiskCache(t.TempDir(), time.Hour)
+	dc.Set("8.8.8.8", &hop.Enrichment{ASN: 15169, ASOrg: "GOOGLE"})
+
+	e := NewEnricher()
+	e.SetDiskCache(dc)
+
+	// A cancelled context makes any network lookup fail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got, err := e.EnrichIP(ctx, net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("EnrichIP() error: %v", err)
+	}
+	if got.ASN != 15169 || got.ASOrg != "GOOGLE" {
+		t.Errorf("EnrichIP() = %+v, want cached enrichment", got)
+	}
+}
+
+func TestEnricher_DiskCacheSkipsPrivateAndEmptyResults(t *testing.T) {
+	dc := OpenDiskCache(t.TempDir(), time.Hour)
+	e := NewEnricher()
+	e.SetDiskCache(dc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	e.EnrichIP(ctx, net.ParseIP("192.168.1.1"))
+	e.EnrichIP(ctx, net.ParseIP("203.0.113.1")) // Lookups fail: empty result
+
+	if dc.Len() != 0 {
+		t.Errorf("expected nothing persisted, got %d entries", dc.Len())
+	}
+}
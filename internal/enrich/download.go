@@ -2,9 +2,19 @@
 package enrich
 
 import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -15,6 +25,12 @@ const (
 	GeoLite2ASNDB     = "GeoLite2-ASN.mmdb"
 )
 
+// MaxMindDownloadURL is the MaxMind database download endpoint.
+const MaxMindDownloadURL = "https://download.maxmind.com/app/geoip_download"
+
+// LicenseKeyEnv is the environment variable holding the MaxMind license key.
+const LicenseKeyEnv = "MAXMIND_LICENSE_KEY"
+
 // DBStatus represents the status of a downloaded database.
 type DBStatus struct {
 	Installed   bool      // Whether the database is installed
@@ -89,6 +105,12 @@ type DownloadConfig struct {
 
 	// Databases is the list of databases to download
 	Databases []string
+
+	// BaseURL is the download endpoint (default: MaxMindDownloadURL)
+	BaseURL string
+
+	// HTTPClient performs the downloads (default: 10 minute timeout)
+	HTTPClient *http.Client
 }
 
 // DefaultDownloadConfig returns sensible defaults.
@@ -99,9 +121,46 @@ func DefaultDownloadConfig() *DownloadConfig {
 		Databases: []string{
 			GeoLite2CityDB,
 		},
+		BaseURL:    MaxMindDownloadURL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Minute},
 	}
 }
 
+// ResolveLicenseKey returns the MaxMind license key from, in order, the given
+// flag value, the MAXMIND_LICENSE_KEY environment variable, or the LicenseKey
+// line of ~/.gtr/GeoIP.conf (geoipupdate's format). Returns "" if none is set.
+func ResolveLicenseKey(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if key := os.Getenv(LicenseKeyEnv); key != "" {
+		return key
+	}
+	dir, err := DataDir()
+	if err != nil {
+		return ""
+	}
+	return licenseKeyFromConf(filepath.Join(filepath.Dir(dir), "GeoIP.conf"))
+}
+
+// licenseKeyFromConf reads the LicenseKey setting from a GeoIP.conf file.
+func licenseKeyFromConf(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "LicenseKey" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
 // DownloadResult represents the result of a download operation.
 type DownloadResult struct {
 	Database string
@@ -114,6 +173,10 @@ type DownloadResult struct {
 // DownloadDatabases downloads the specified databases.
 // Note: MaxMind requires a license key since December 2019.
 // Users need to register at https://www.maxmind.com/en/geolite2/signup
+//
+// Each archive is checked against MaxMind's published SHA-256 before the
+// database is extracted, and the installed file is replaced atomically, so a
+// failed download never leaves a truncated database behind.
 func DownloadDatabases(cfg *DownloadConfig) ([]DownloadResult, error) {
 	if cfg.LicenseKey == "" {
 		return nil, fmt.Errorf("MaxMind license key required. Register at https://www.maxmind.com/en/geolite2/signup")
@@ -130,18 +193,164 @@ func DownloadDatabases(cfg *DownloadConfig) ([]DownloadResult, error) {
 			Database: db,
 		}
 
-		// Download URL format for MaxMind
-		// https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-City&license_key=YOUR_KEY&suffix=tar.gz
-		// Note: Actual download implementation would require HTTP client and tar extraction
-		// For now, we provide the infrastructure
-
-		result.Error = fmt.Errorf("download not implemented - please download manually from MaxMind")
+		path, size, err := downloadDatabase(cfg, db)
+		if err != nil {
+			result.Error = err
+		} else {
+			result.Success = true
+			result.Path = path
+			result.Size = size
+		}
 		results = append(results, result)
 	}
 
 	return results, nil
 }
 
+// downloadDatabase fetches, verifies and installs one database.
+func downloadDatabase(cfg *DownloadConfig, db string) (string, int64, error) {
+	edition := strings.TrimSuffix(db, ".mmdb")
+
+	checksum, err := fetchEdition(cfg, edition, "tar.gz.sha256")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to fetch checksum: %w", err)
+	}
+	sumLine, err := io.ReadAll(io.LimitReader(checksum, 1024))
+	checksum.Close()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read checksum: %w", err)
+	}
+	fields := strings.Fields(string(sumLine))
+	if len(fields) == 0 {
+		return "", 0, errors.New("empty checksum response")
+	}
+	want := strings.ToLower(fields[0])
+
+	archive, err := fetchEdition(cfg, edition, "tar.gz")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer archive.Close()
+
+	// Spool the archive to disk so the checksum is verified before extracting
+	tmp, err := os.CreateTemp(cfg.DataDir, edition+"-*.tar.gz.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), archive); err != nil {
+		return "", 0, fmt.Errorf("failed to download archive: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return "", 0, fmt.Errorf("checksum mismatch: expected %s, got %s", want, got)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	return extractDatabase(tmp, cfg.DataDir, db)
+}
+
+// fetchEdition requests one file of a MaxMind edition. The license key is
+// kept out of returned errors since it is part of the request URL.
+func fetchEdition(cfg *DownloadConfig, edition, suffix string) (io.ReadCloser, error) {
+	base := cfg.BaseURL
+	if base == "" {
+		base = MaxMindDownloadURL
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	q := url.Values{}
+	q.Set("edition_id", edition)
+	q.Set("license_key", cfg.LicenseKey)
+	q.Set("suffix", suffix)
+
+	resp, err := client.Get(base + "?" + q.Encode())
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, errors.New("invalid MaxMind license key")
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// extractDatabase finds db in a tar.gz archive and atomically installs it in dir.
+func extractDatabase(r io.Reader, dir, db string) (string, int64, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", 0, fmt.Errorf("%s not found in archive", db)
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || filepath.Base(hdr.Name) != db {
+			continue
+		}
+
+		tmp, err := os.CreateTemp(dir, db+"-*.tmp")
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		size, err := io.Copy(tmp, tr)
+		if err == nil {
+			err = tmp.Sync()
+		}
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return "", 0, fmt.Errorf("failed to write database: %w", err)
+		}
+
+		path := filepath.Join(dir, db)
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			os.Remove(tmp.Name())
+			return "", 0, fmt.Errorf("failed to install database: %w", err)
+		}
+		return path, size, nil
+	}
+}
+
+// StaleDatabases returns the installed databases in dir that were last
+// updated more than maxAge ago. Missing databases are not included.
+func StaleDatabases(dir string, dbs []string, maxAge time.Duration) []string {
+	var stale []string
+	for _, db := range dbs {
+		info, err := os.Stat(filepath.Join(dir, db))
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > maxAge {
+			stale = append(stale, db)
+		}
+	}
+	return stale
+}
+
 // PrintDBStatus prints a formatted database status report.
 func PrintDBStatus() string {
 	status := CheckDBStatus()
@@ -155,7 +364,7 @@ func PrintDBStatus() string {
 		report += fmt.Sprintf("  Size: %d bytes\n", status.Size)
 		report += fmt.Sprintf("  Modified: %s\n", status.ModTime.Format(time.RFC3339))
 		if status.NeedsUpdate {
-			report += "  Note: Database is older than 30 days, consider updating\n"
+			report += "  Note: Database is older than 30 days, consider updating (gtrace --download-db)\n"
 		}
 	} else {
 		report += "  Status: Not installed\n"
@@ -164,6 +373,16 @@ func PrintDBStatus() string {
 		report += "  1. Register at https://www.maxmind.com/en/geolite2/signup\n"
 		report += "  2. Download GeoLite2-City.mmdb\n"
 		report += fmt.Sprintf("  3. Place it at: %s\n", status.Path)
+		report += "  Or run: gtrace --download-db --license-key YOUR_KEY\n"
+	}
+
+	report += "\nIX Prefixes (PeeringDB):\n"
+	ixPath := DefaultPeeringDBPath()
+	report += fmt.Sprintf("  Path: %s\n", ixPath)
+	if prefixes, updated, err := LoadPeeringDB(ixPath); err == nil {
+		report += fmt.Sprintf("  Status: %d prefixes, updated %s\n", len(prefixes), updated.Format(time.RFC3339))
+	} else {
+		report += "  Status: Not downloaded, using built-in prefixes (run: gtrace --update-ix)\n"
 	}
 
 	return report
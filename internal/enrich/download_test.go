@@ -1,9 +1,18 @@
 package enrich
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDataDir(t *testing.T) {
@@ -77,3 +86,166 @@ func TestCheckDBStatus(t *testing.T) {
 		t.Error("CheckDBStatus() returned empty path")
 	}
 }
+
+// maxMindServer serves a GeoLite2 archive containing db with the given
+// content, and a checksum for it (or badSum when set).
+func maxMindServer(t *testing.T, db, content, badSum string) *httptest.Server {
+	t.Helper()
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	name := "GeoLite2-City_20240102/" + db
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+
+	sum := sha256.Sum256(archive.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+	if badSum != "" {
+		checksum = badSum
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("license_key") != "good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("suffix") {
+		case "tar.gz.sha256":
+			w.Write([]byte(checksum + "  GeoLite2-City_20240102.tar.gz\n"))
+		case "tar.gz":
+			w.Write(archive.Bytes())
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestDownloadDatabases_InstallsVerifiedDatabase(t *testing.T) {
+	srv := maxMindServer(t, GeoLite2CityDB, "mmdb-data", "")
+	defer srv.Close()
+
+	dir := t.TempDir()
+	results, err := DownloadDatabases(&DownloadConfig{
+		LicenseKey: "good-key",
+		DataDir:    dir,
+		Databases:  []string{GeoLite2CityDB},
+		BaseURL:    srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("DownloadDatabases() error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("expected one successful result, got %+v", results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, GeoLite2CityDB))
+	if err != nil || string(data) != "mmdb-data" {
+		t.Errorf("expected installed database, got %q (%v)", data, err)
+	}
+	if results[0].Size != int64(len("mmdb-data")) {
+		t.Errorf("expected size %d, got %d", len("mmdb-data"), results[0].Size)
+	}
+
+	// Temp files must not be left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the database in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestDownloadDatabases_ChecksumMismatchKeepsExistingDatabase(t *testing.T) {
+	srv := maxMindServer(t, GeoLite2CityDB, "new-data", strings.Repeat("0", 64))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, GeoLite2CityDB)
+	os.WriteFile(path, []byte("old-data"), 0644)
+
+	results, err := DownloadDatabases(&DownloadConfig{
+		LicenseKey: "good-key",
+		DataDir:    dir,
+		Databases:  []string{GeoLite2CityDB},
+		BaseURL:    srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("DownloadDatabases() error: %v", err)
+	}
+	if results[0].Success || results[0].Error == nil || !strings.Contains(results[0].Error.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %+v", results[0])
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "old-data" {
+		t.Errorf("expected existing database to be kept, got %q", data)
+	}
+}
+
+func TestDownloadDatabases_InvalidLicenseKey(t *testing.T) {
+	srv := maxMindServer(t, GeoLite2CityDB, "mmdb-data", "")
+	defer srv.Close()
+
+	results, err := DownloadDatabases(&DownloadConfig{
+		LicenseKey: "bad-key",
+		DataDir:    t.TempDir(),
+		Databases:  []string{GeoLite2CityDB},
+		BaseURL:    srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("DownloadDatabases() error: %v", err)
+	}
+	if results[0].Error == nil || !strings.Contains(results[0].Error.Error(), "invalid MaxMind license key") {
+		t.Errorf("expected invalid key error, got %v", results[0].Error)
+	}
+	if strings.Contains(results[0].Error.Error(), "bad-key") {
+		t.Error("license key must not appear in errors")
+	}
+}
+
+func TestDownloadDatabases_RequiresLicenseKey(t *testing.T) {
+	if _, err := DownloadDatabases(&DownloadConfig{DataDir: t.TempDir()}); err == nil {
+		t.Error("expected error without license key")
+	}
+}
+
+func TestLicenseKeyFromConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoIP.conf")
+	os.WriteFile(path, []byte("# geoipupdate config\nAccountID 12345\nLicenseKey abc123\nEditionIDs GeoLite2-City\n"), 0600)
+
+	if got := licenseKeyFromConf(path); got != "abc123" {
+		t.Errorf("licenseKeyFromConf() = %q, want %q", got, "abc123")
+	}
+	if got := licenseKeyFromConf(filepath.Join(t.TempDir(), "missing.conf")); got != "" {
+		t.Errorf("expected empty key for missing file, got %q", got)
+	}
+}
+
+func TestResolveLicenseKey_FlagOverridesEnv(t *testing.T) {
+	t.Setenv(LicenseKeyEnv, "env-key")
+
+	if got := ResolveLicenseKey("flag-key"); got != "flag-key" {
+		t.Errorf("ResolveLicenseKey() = %q, want flag-key", got)
+	}
+	if got := ResolveLicenseKey(""); got != "env-key" {
+		t.Errorf("ResolveLicenseKey() = %q, want env-key", got)
+	}
+}
+
+func TestStaleDatabases(t *testing.T) {
+	dir := t.TempDir()
+	fresh := filepath.Join(dir, GeoLite2CityDB)
+	old := filepath.Join(dir, GeoLite2ASNDB)
+	os.WriteFile(fresh, []byte("x"), 0644)
+	os.WriteFile(old, []byte("x"), 0644)
+	past := time.Now().Add(-10 * 24 * time.Hour)
+	os.Chtimes(old, past, past)
+
+	stale := StaleDatabases(dir, []string{GeoLite2CityDB, GeoLite2ASNDB, GeoLite2CountryDB}, 7*24*time.Hour)
+	if len(stale) != 1 || stale[0] != GeoLite2ASNDB {
+		t.Errorf("StaleDatabases() = %v, want [%s]", stale, GeoLite2ASNDB)
+	}
+}
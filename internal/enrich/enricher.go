@@ -3,8 +3,10 @@ package enrich
 import (
 	"context"
 	"net"
+	"reflect"
 	"sync"
 
+	"github.com/hervehildenbrand/gtrace/internal/logging"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -28,6 +30,11 @@ type Enricher struct {
 	ix    *IXLookup
 	rdns  *RDNSLookup
 	cache *Cache
+	disk  *DiskCache        // Optional cache shared across runs
+	bgp   *BGPLookup        // Optional looking glass queries
+	rep   *ReputationLookup // Optional blocklist checks
+
+	onHostname func(ip net.IP, e hop.Enrichment) // Optional back-fill of pending rDNS names
 }
 
 // NewEnricher creates a new enricher with default settings.
@@ -41,6 +48,48 @@ func NewEnricher() *Enricher {
 	}
 }
 
+// NewEnricherWithProvider creates an enricher whose GeoIP lookups use the
+// given offline database provider before falling back to the API.
+func NewEnricherWithProvider(provider Provider) *Enricher {
+	e := NewEnricher()
+	e.geo = NewGeoLookupWithProvider(provider)
+	return e
+}
+
+// SetDiskCache makes the enricher read and record results in dc. Private
+// addresses are never persisted, since they mean different hosts on
+// different networks.
+func (e *Enricher) SetDiskCache(dc *DiskCache) {
+	e.disk = dc
+}
+
+// SetBGPLookup makes the enricher query a looking glass for the BGP prefix,
+// AS path and visibility of public addresses.
+func (e *Enricher) SetBGPLookup(l *BGPLookup) {
+	e.bgp = l
+}
+
+// SetReputation makes the enricher check public addresses against the
+// reputation feeds loaded in r. Listings are never cached on disk, so they
+// always reflect the feeds of the current run.
+func (e *Enricher) SetReputation(r *ReputationLookup) {
+	e.rep = r
+}
+
+// SetResolver makes reverse DNS lookups query r instead of the system
+// resolver.
+func (e *Enricher) SetResolver(r *net.Resolver) {
+	e.rdns = newRDNSLookup(r)
+}
+
+// SetHostnameCallback makes EnrichIP return without waiting for reverse DNS
+// names it does not know yet. They are resolved in the background, and fn
+// receives the completed enrichment of each address that has one, so a
+// live display can fill hostnames in instead of stalling on slow PTR zones.
+func (e *Enricher) SetHostnameCallback(fn func(ip net.IP, enrichment hop.Enrichment)) {
+	e.onHostname = fn
+}
+
 // EnrichIP performs all enrichment lookups for a single IP.
 func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) (*hop.Enrichment, error) {
 	if ip == nil {
@@ -53,7 +102,19 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) (*hop.Enrichment, er
 	if cached, ok := e.cache.Get(key); ok {
 		return cached, nil
 	}
+	persist := e.disk != nil && !IsPrivateIP(ip)
+	if persist {
+		// Entries saved without --bgp lack routing data; look them up again
+		if cached, ok := e.disk.Get(key); ok && (e.bgp == nil || cached.BGP != nil) {
+			applyPTRHints(cached)
+			applySpecialPurpose(ip, cached)
+			e.applyReputation(ip, cached)
+			e.cache.Set(key, cached)
+			return cached, nil
+		}
+	}
 
+	log := logging.FromContext(ctx).With("ip", key)
 	result := &hop.Enrichment{}
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -63,6 +124,9 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) (*hop.Enrichment, er
 	go func() {
 		defer wg.Done()
 		asnResult, err := e.asn.Lookup(ctx, ip)
+		if err != nil {
+			log.Debug("ASN lookup failed", "err", err)
+		}
 		if err == nil && asnResult != nil {
 			mu.Lock()
 			result.ASN = asnResult.ASN
@@ -79,10 +143,15 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) (*hop.Enrichment, er
 	go func() {
 		defer wg.Done()
 		geoResult, err := e.geo.Lookup(ctx, ip)
+		if err != nil {
+			log.Debug("GeoIP lookup failed", "err", err)
+		}
 		if err == nil && geoResult != nil && !geoResult.IsEmpty() {
 			mu.Lock()
 			if geoResult.City != "" {
 				result.City = geoResult.City
+				result.Latitude = geoResult.Latitude
+				result.Longitude = geoResult.Longitude
 			}
 			if geoResult.Country != "" && result.Country == "" {
 				result.Country = geoResult.Country
@@ -96,6 +165,9 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) (*hop.Enrichment, er
 	go func() {
 		defer wg.Done()
 		ixResult, err := e.ix.Lookup(ctx, ip)
+		if err != nil {
+			log.Debug("IX lookup failed", "err", err)
+		}
 		if err == nil && ixResult != nil && ixResult.IsIX() {
 			mu.Lock()
 			result.IX = ixResult.Name
@@ -103,26 +175,118 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) (*hop.Enrichment, er
 		}
 	}()
 
-	// Reverse DNS lookup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		hostname, err := e.rdns.Lookup(ctx, ip)
-		if err == nil && hostname != "" {
-			mu.Lock()
-			result.Hostname = hostname
-			mu.Unlock()
-		}
-	}()
+	// Reverse DNS lookup, left to the background when the name will be
+	// back-filled
+	pendingName := false
+	name, known := e.rdns.Cached(ip)
+	switch {
+	case known:
+		result.Hostname = name
+	case e.onHostname != nil:
+		pendingName = true
+	default:
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hostname, err := e.rdns.Lookup(ctx, ip)
+			if err != nil {
+				log.Debug("reverse DNS lookup failed", "err", err)
+			}
+			if err == nil && hostname != "" {
+				mu.Lock()
+				result.Hostname = hostname
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// BGP looking glass lookup (private addresses are never announced)
+	if e.bgp != nil && !IsPrivateIP(ip) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bgpResult, err := e.bgp.Lookup(ctx, ip)
+			if err != nil {
+				log.Debug("BGP lookup failed", "err", err)
+			}
+			if err == nil && bgpResult != nil {
+				mu.Lock()
+				result.BGP = bgpResult
+				mu.Unlock()
+			}
+		}()
+	}
 
 	wg.Wait()
+	applyPTRHints(result)
+	log.Debug("IP enriched", "asn", result.ASN, "hostname", result.Hostname, "country", result.Country)
 
-	// Cache the result
+	// Cache the result. Empty results are likely failed lookups (e.g. no
+	// network), so they are not kept across runs.
+	if persist && !reflect.DeepEqual(*result, hop.Enrichment{}) {
+		e.disk.Set(key, result)
+	}
+	applySpecialPurpose(ip, result)
+	e.applyReputation(ip, result)
 	e.cache.Set(key, result)
+	if pendingName {
+		go e.backfillHostname(ctx, ip, *result)
+	}
 
 	return result, nil
 }
 
+// backfillHostname resolves the name of ip in the background, records it
+// in the cached enrichment and hands the result to the hostname callback.
+func (e *Enricher) backfillHostname(ctx context.Context, ip net.IP, result hop.Enrichment) {
+	log := logging.FromContext(ctx).With("ip", ip.String())
+	hostname, err := e.rdns.Lookup(context.WithoutCancel(ctx), ip)
+	if err != nil {
+		log.Debug("reverse DNS lookup failed", "err", err)
+	}
+	if hostname == "" {
+		return
+	}
+
+	result.Hostname = hostname
+	applyPTRHints(&result)
+	if e.disk != nil && !IsPrivateIP(ip) {
+		// Labels and listings are computed on every run, not persisted
+		stored := result
+		stored.Special = ""
+		stored.Reputation = nil
+		e.disk.Set(ip.String(), &stored)
+	}
+	e.cache.Set(ip.String(), &result)
+	e.onHostname(ip, result)
+}
+
+// applyReputation records the reputation listings of ip in result. Private
+// addresses are expected on any path and are not checked.
+func (e *Enricher) applyReputation(ip net.IP, result *hop.Enrichment) {
+	if e.rep != nil && !IsPrivateIP(ip) {
+		result.Reputation = e.rep.Lookup(ip)
+	}
+}
+
+// applySpecialPurpose labels addresses in IANA special-purpose ranges. The
+// label is computed on every run, so it is not kept in the disk cache.
+func applySpecialPurpose(ip net.IP, result *hop.Enrichment) {
+	if r, ok := SpecialPurpose(ip); ok {
+		result.Special = r.Name
+	}
+}
+
+// applyPTRHints fills the interface and location hints from the hostname.
+func applyPTRHints(e *hop.Enrichment) {
+	if e.Hostname == "" {
+		return
+	}
+	hints := ParsePTR(e.Hostname)
+	e.InterfaceHint = hints.InterfaceString()
+	e.LocationHint = hints.LocationString()
+}
+
 // EnrichHop enriches a hop with ASN, hostname, etc.
 func (e *Enricher) EnrichHop(ctx context.Context, h *hop.Hop) {
 	ip := h.PrimaryIP()
@@ -3,6 +3,7 @@ package enrich
 import (
 	"context"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -168,3 +169,38 @@ func TestCache_Stats_TracksHitsMisses(t *testing.T) {
 		t.Errorf("expected 1 miss, got %d", stats.Misses)
 	}
 }
+
+func TestEnricher_HostnameCallbackBackfillsSlowNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var queries atomic.Int32
+	e := NewOfflineEnricher(nil)
+	e.rdns = newRDNSLookup(fakePTRResolver("ae1.core1.fra1.example.net.", 100*time.Millisecond, &queries))
+
+	got := make(chan hop.Enrichment, 1)
+	e.SetHostnameCallback(func(ip net.IP, enrichment hop.Enrichment) {
+		got <- enrichment
+	})
+
+	ip := net.ParseIP("192.0.2.1")
+	first, err := e.EnrichIP(context.Background(), ip)
+	if err != nil {
+		t.Fatalf("EnrichIP() error: %v", err)
+	}
+	if first.Hostname != "" {
+		t.Errorf("Hostname = %q, want none before the lookup completes", first.Hostname)
+	}
+
+	select {
+	case enrichment := <-got:
+		if enrichment.Hostname != "ae1.core1.fra1.example.net" || enrichment.Special == "" {
+			t.Errorf("back-filled enrichment = %+v, want the hostname and the existing fields", enrichment)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("hostname was never back-filled")
+	}
+
+	again, _ := e.EnrichIP(context.Background(), ip)
+	if again.Hostname != "ae1.core1.fra1.example.net" {
+		t.Errorf("cached Hostname = %q, want the back-filled name", again.Hostname)
+	}
+}
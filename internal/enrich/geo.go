@@ -15,13 +15,13 @@ import (
 
 // GeoResult contains the result of a GeoIP lookup.
 type GeoResult struct {
-	City       string  // City name
-	Country    string  // Country code (ISO 3166-1 alpha-2)
-	CountryName string // Full country name
-	Region     string  // Region/state
-	Latitude   float64 // Latitude
-	Longitude  float64 // Longitude
-	Timezone   string  // Timezone
+	City        string  // City name
+	Country     string  // Country code (ISO 3166-1 alpha-2)
+	CountryName string  // Full country name
+	Region      string  // Region/state
+	Latitude    float64 // Latitude
+	Longitude   float64 // Longitude
+	Timezone    string  // Timezone
 }
 
 // String returns a formatted location string.
@@ -47,22 +47,30 @@ const defaultGeoAPIBaseURL = "http://ip-api.com"
 
 // GeoLookup performs GeoIP lookups.
 type GeoLookup struct {
-	dbPath     string // Path to MaxMind database file (optional)
-	apiBaseURL string // Base URL for ip-api.com (overridable for testing)
+	provider   Provider // Offline database provider (optional)
+	apiBaseURL string   // Base URL for ip-api.com (overridable for testing)
+	offline    bool     // Never fall back to the API
 }
 
 // NewGeoLookup creates a new GeoIP lookup instance.
 func NewGeoLookup() *GeoLookup {
-	return &GeoLookup{
-		dbPath:     DefaultGeoDBPath(),
-		apiBaseURL: defaultGeoAPIBaseURL,
-	}
+	return NewGeoLookupWithDB(DefaultGeoDBPath())
 }
 
-// NewGeoLookupWithDB creates a GeoIP lookup with a specific database path.
+// NewGeoLookupWithDB creates a GeoIP lookup with a specific MaxMind database path.
 func NewGeoLookupWithDB(dbPath string) *GeoLookup {
+	var provider Provider
+	if dbPath != "" {
+		provider, _ = NewProvider(ProviderMaxMind, dbPath)
+	}
+	return NewGeoLookupWithProvider(provider)
+}
+
+// NewGeoLookupWithProvider creates a GeoIP lookup using the given database
+// provider, falling back to ip-api.com. A nil provider uses the API only.
+func NewGeoLookupWithProvider(provider Provider) *GeoLookup {
 	return &GeoLookup{
-		dbPath:     dbPath,
+		provider:   provider,
 		apiBaseURL: defaultGeoAPIBaseURL,
 	}
 }
@@ -79,14 +87,15 @@ func (l *GeoLookup) Lookup(ctx context.Context, ip net.IP) (*GeoResult, error) {
 	}
 
 	// Try database lookup first if available
-	if l.dbPath != "" {
-		if _, err := os.Stat(l.dbPath); err == nil {
-			result, err := l.lookupFromDB(ip)
-			if err == nil {
-				return result, nil
-			}
-			// Fall through to API on DB error
+	if l.HasDatabase() {
+		result, err := l.lookupFromDB(ip)
+		if err == nil && result != nil && !result.IsEmpty() {
+			return result, nil
 		}
+		// Fall through to API on DB error or miss
+	}
+	if l.offline {
+		return &GeoResult{}, nil
 	}
 
 	// Fallback to ip-api.com
@@ -146,19 +155,14 @@ func (l *GeoLookup) lookupAPI(ctx context.Context, ip net.IP) (*GeoResult, error
 	}, nil
 }
 
-// lookupFromDB looks up IP in MaxMind database.
+// lookupFromDB looks up IP in the provider's database.
 func (l *GeoLookup) lookupFromDB(ip net.IP) (*GeoResult, error) {
-	// Placeholder for MaxMind database lookup
-	return nil, errors.New("database lookup not implemented")
+	return l.provider.Lookup(ip)
 }
 
 // HasDatabase returns true if a GeoIP database is available.
 func (l *GeoLookup) HasDatabase() bool {
-	if l.dbPath == "" {
-		return false
-	}
-	_, err := os.Stat(l.dbPath)
-	return err == nil
+	return providerInstalled(l.provider)
 }
 
 // DefaultGeoDBPath returns the default path for GeoIP database.
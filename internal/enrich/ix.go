@@ -41,15 +41,44 @@ type ixPrefixInfo struct {
 	prefix  *net.IPNet
 }
 
-// NewIXLookup creates a new IX lookup instance with known prefixes.
+// NewIXLookup creates a new IX lookup instance with known prefixes, plus
+// the PeeringDB prefix list when one was downloaded (gtrace --update-ix).
 func NewIXLookup() *IXLookup {
+	return NewIXLookupWithPeeringDB(DefaultPeeringDBPath())
+}
+
+// NewIXLookupWithPeeringDB creates an IX lookup with the known prefixes and
+// the PeeringDB prefix list stored at path. A missing file is ignored.
+func NewIXLookupWithPeeringDB(path string) *IXLookup {
 	l := &IXLookup{
 		prefixes: make(map[string]ixPrefixInfo),
 	}
 	l.loadKnownPrefixes()
+	if path != "" {
+		if prefixes, _, err := LoadPeeringDB(path); err == nil {
+			l.AddPrefixes(prefixes)
+		}
+	}
 	return l
 }
 
+// AddPrefixes adds IX peering LAN prefixes, replacing known entries for the
+// same prefix.
+func (l *IXLookup) AddPrefixes(prefixes []IXPrefix) {
+	for _, p := range prefixes {
+		_, ipNet, err := net.ParseCIDR(p.Prefix)
+		if err != nil {
+			continue
+		}
+		l.prefixes[ipNet.String()] = ixPrefixInfo{
+			name:    p.Name,
+			city:    p.City,
+			country: p.Country,
+			prefix:  ipNet,
+		}
+	}
+}
+
 // loadKnownPrefixes loads commonly known IX peering LAN prefixes.
 // These are well-known prefixes used by major Internet Exchanges.
 func (l *IXLookup) loadKnownPrefixes() {
@@ -110,7 +139,7 @@ func (l *IXLookup) loadKnownPrefixes() {
 		if err != nil {
 			continue
 		}
-		l.prefixes[ix.cidr] = ixPrefixInfo{
+		l.prefixes[ipNet.String()] = ixPrefixInfo{
 			name:    ix.name,
 			city:    ix.city,
 			country: ix.country,
@@ -130,17 +159,26 @@ func (l *IXLookup) Lookup(ctx context.Context, ip net.IP) (*IXResult, error) {
 		return &IXResult{}, nil
 	}
 
-	// Check against known prefixes
+	// Check against known prefixes, preferring the most specific match
+	var best *ixPrefixInfo
+	bestBits := -1
 	for _, info := range l.prefixes {
-		if info.prefix.Contains(ip) {
-			return &IXResult{
-				Matched: true,
-				Name:    info.name,
-				City:    info.city,
-				Country: info.country,
-			}, nil
+		if !info.prefix.Contains(ip) {
+			continue
+		}
+		if bits, _ := info.prefix.Mask.Size(); bits > bestBits {
+			info := info
+			best, bestBits = &info, bits
 		}
 	}
+	if best != nil {
+		return &IXResult{
+			Matched: true,
+			Name:    best.name,
+			City:    best.city,
+			Country: best.country,
+		}, nil
+	}
 
 	// Not found in known prefixes
 	return &IXResult{}, nil
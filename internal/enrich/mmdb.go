@@ -0,0 +1,326 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// mmdbMetadataMarker precedes the metadata map at the end of a MaxMind DB file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbDataSectionSeparator is the size of the zero padding between the search
+// tree and the data section.
+const mmdbDataSectionSeparator = 16
+
+// mmdbReader is a minimal reader for the MaxMind DB format
+// (https://maxmind.github.io/MaxMind-DB/), the format used by GeoLite2,
+// IPinfo and DB-IP databases. The whole file is held in memory.
+type mmdbReader struct {
+	buf          []byte
+	data         []byte // Data section
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	ipv4Start    uint // Node reached after the 96 zero bits of ::/96 (IPv6 trees)
+}
+
+// openMMDB reads and validates the MaxMind DB file at path.
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newMMDBReader(buf)
+}
+
+// newMMDBReader parses the metadata of an in-memory MaxMind DB.
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	idx := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if idx < 0 {
+		return nil, errors.New("not a MaxMind DB file: metadata not found")
+	}
+
+	meta := mmdbDecoder{buf: buf[idx+len(mmdbMetadataMarker):]}
+	v, _, err := meta.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %w", err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	r := &mmdbReader{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+	}
+	r.databaseType, _ = m["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %d", r.recordSize)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+mmdbDataSectionSeparator > uint(idx) {
+		return nil, errors.New("invalid MaxMind DB: search tree exceeds file size")
+	}
+	r.data = buf[treeSize+mmdbDataSectionSeparator : idx]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// lookup returns the decoded record for ip, or nil if ip is not in the database.
+func (r *mmdbReader) lookup(ip net.IP) (any, error) {
+	node := uint(0)
+	bits := ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, errors.New("IPv6 address in IPv4-only database")
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, errors.New("invalid MaxMind DB: search tree too deep")
+	}
+
+	offset := node - r.nodeCount - mmdbDataSectionSeparator
+	d := mmdbDecoder{buf: r.data}
+	v, _, err := d.decode(offset)
+	return v, err
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of a tree node.
+func (r *mmdbReader) readNode(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.buf[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.buf[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.buf[node*8+bit*4:]))
+	}
+}
+
+// MaxMind DB data section types.
+const (
+	mmdbExtended = 0
+	mmdbPointer  = 1
+	mmdbString   = 2
+	mmdbDouble   = 3
+	mmdbBytes    = 4
+	mmdbUint16   = 5
+	mmdbUint32   = 6
+	mmdbMap      = 7
+	mmdbInt32    = 8
+	mmdbUint64   = 9
+	mmdbUint128  = 10
+	mmdbArray    = 11
+	mmdbBool     = 14
+	mmdbFloat    = 15
+)
+
+// mmdbDecoder decodes values from a MaxMind DB data section.
+type mmdbDecoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset just past it.
+func (d *mmdbDecoder) decode(offset uint) (any, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errors.New("data offset out of range")
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == mmdbPointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+	if typ == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("truncated extended type")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, errors.New("value exceeds data section")
+	}
+	b := d.buf[offset:end]
+
+	switch typ {
+	case mmdbString:
+		return string(b), end, nil
+	case mmdbBytes, mmdbUint128:
+		return append([]byte{}, b...), end, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		var u uint64
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		return u, end, nil
+	case mmdbInt32:
+		var u uint32
+		for _, c := range b {
+			u = u<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(u)), end, nil
+		}
+		return int64(u), end, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+// pointer decodes a pointer whose control byte is ctrl.
+func (d *mmdbDecoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("truncated pointer")
+	}
+	b := d.buf[offset : offset+n]
+
+	var p uint
+	if n < 4 {
+		p = uint(ctrl & 0x7)
+	}
+	for _, c := range b {
+		p = p<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		p += 2048
+	case 3:
+		p += 526336
+	}
+	return p, offset + n, nil
+}
+
+// size decodes the payload size encoded in ctrl and the bytes that follow.
+func (d *mmdbDecoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("truncated size")
+	}
+	var v uint
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 1:
+		v += 29
+	case 2:
+		v += 285
+	case 3:
+		v += 65821
+	}
+	return v, offset + n, nil
+}
+
+// toUint converts a decoded unsigned value to uint64.
+func toUint(v any) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// mmdbNetwork is a network and its record for buildMMDB.
+type mmdbNetwork struct {
+	cidr   string
+	record map[string]any
+}
+
+// buildMMDB writes a MaxMind DB with an IPv6 search tree holding the given
+// networks (IPv4 networks are mapped into ::/96) and returns its bytes.
+func buildMMDB(t *testing.T, recordSize int, networks []mmdbNetwork) []byte {
+	t.Helper()
+
+	// nodes[i][bit] is a child node index, or -(dataOffset+1) for a record
+	nodes := [][2]int{{0, 0}}
+	var data bytes.Buffer
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, bits := ipnet.Mask.Size()
+		ip := ipnet.IP.To16()
+		if bits == 32 {
+			ip = append(make(net.IP, 12), ipnet.IP.To4()...)
+			ones += 96
+		}
+
+		offset := data.Len()
+		data.Write(encodeMMDB(t, n.record))
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = -(offset + 1)
+				break
+			}
+			if nodes[node][bit] <= 0 {
+				nodes = append(nodes, [2]int{})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		var rec [2]uint32
+		for bit, child := range n {
+			switch {
+			case child < 0:
+				rec[bit] = uint32(nodeCount + mmdbDataSectionSeparator - child - 1)
+			case child == 0:
+				rec[bit] = uint32(nodeCount)
+			default:
+				rec[bit] = uint32(child)
+			}
+		}
+		switch recordSize {
+		case 24:
+			buf.Write([]byte{byte(rec[0] >> 16), byte(rec[0] >> 8), byte(rec[0]), byte(rec[1] >> 16), byte(rec[1] >> 8), byte(rec[1])})
+		case 28:
+			buf.Write([]byte{byte(rec[0] >> 16), byte(rec[0] >> 8), byte(rec[0]),
+				byte(rec[0]>>20)&0xf0 | byte(rec[1]>>24)&0x0f,
+				byte(rec[1] >> 16), byte(rec[1] >> 8), byte(rec[1])})
+		case 32:
+			binary.Write(&buf, binary.BigEndian, rec)
+		}
+	}
+	buf.Write(make([]byte, mmdbDataSectionSeparator))
+	buf.Write(data.Bytes())
+	buf.Write(mmdbMetadataMarker)
+	buf.Write(encodeMMDB(t, map[string]any{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(recordSize),
+		"ip_version":    uint16(6),
+		"database_type": "Test-City",
+	}))
+	return buf.Bytes()
+}
+
+// encodeMMDB encodes v in the MaxMind DB data section format.
+func encodeMMDB(t *testing.T, v any) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	header := func(typ int, size int) {
+		var ctrl byte // Extended types have type 0 in the control byte
+		if typ <= 7 {
+			ctrl = byte(typ) << 5
+		}
+		if size >= 29+256 {
+			t.Fatalf("encodeMMDB: size %d not supported", size)
+		}
+		out.WriteByte(ctrl | byte(min(size, 29)))
+		if typ > 7 {
+			out.WriteByte(byte(typ - 7))
+		}
+		if size >= 29 {
+			out.WriteByte(byte(size - 29))
+		}
+	}
+
+	switch x := v.(type) {
+	case string:
+		header(mmdbString, len(x))
+		out.WriteString(x)
+	case float64:
+		header(mmdbDouble, 8)
+		binary.Write(&out, binary.BigEndian, math.Float64bits(x))
+	case uint16:
+		header(mmdbUint16, 2)
+		binary.Write(&out, binary.BigEndian, x)
+	case uint32:
+		header(mmdbUint32, 4)
+		binary.Write(&out, binary.BigEndian, x)
+	case bool:
+		size := 0
+		if x {
+			size = 1
+		}
+		header(mmdbBool, size)
+	case []any:
+		header(mmdbArray, len(x))
+		for _, e := range x {
+			out.Write(encodeMMDB(t, e))
+		}
+	case map[string]any:
+		header(mmdbMap, len(x))
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out.Write(encodeMMDB(t, k))
+			out.Write(encodeMMDB(t, x[k]))
+		}
+	default:
+		t.Fatalf("encodeMMDB: unsupported type %T", v)
+	}
+	return out.Bytes()
+}
+
+// writeMMDB writes buildMMDB's output to a file and returns its path.
+func writeMMDB(t *testing.T, name string, networks []mmdbNetwork) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buildMMDB(t, 24, networks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMMDBReader_RecordSizes(t *testing.T) {
+	networks := []mmdbNetwork{
+		{cidr: "8.8.8.0/24", record: map[string]any{"name": "v4"}},
+		{cidr: "2001:db8::/32", record: map[string]any{"name": "v6"}},
+	}
+
+	for _, size := range []int{24, 28, 32} {
+		r, err := newMMDBReader(buildMMDB(t, size, networks))
+		if err != nil {
+			t.Fatalf("record size %d: %v", size, err)
+		}
+		if r.databaseType != "Test-City" {
+			t.Errorf("record size %d: database type = %q", size, r.databaseType)
+		}
+
+		for ip, want := range map[string]string{"8.8.8.8": "v4", "2001:db8::1": "v6"} {
+			v, err := r.lookup(net.ParseIP(ip))
+			if err != nil {
+				t.Fatalf("record size %d: lookup(%s): %v", size, ip, err)
+			}
+			m, _ := v.(map[string]any)
+			if m["name"] != want {
+				t.Errorf("record size %d: lookup(%s) = %v, want name %q", size, ip, v, want)
+			}
+		}
+
+		if v, err := r.lookup(net.ParseIP("1.1.1.1")); err != nil || v != nil {
+			t.Errorf("record size %d: expected no record for 1.1.1.1, got %v (%v)", size, v, err)
+		}
+	}
+}
+
+func TestMMDBDecoder_Pointer(t *testing.T) {
+	// "abc" at offset 0, then a map {"k": pointer to offset 0}
+	buf := []byte{0x43, 'a', 'b', 'c', 0xe1, 0x41, 'k', 0x20, 0x00}
+	d := mmdbDecoder{buf: buf}
+
+	v, next, err := d.decode(4)
+	if err != nil {
+		t.Fatalf("decode() error: %v", err)
+	}
+	if m, _ := v.(map[string]any); m["k"] != "abc" {
+		t.Errorf("decode() = %v, want map[k:abc]", v)
+	}
+	if next != uint(len(buf)) {
+		t.Errorf("next offset = %d, want %d", next, len(buf))
+	}
+}
+
+func TestNewMMDBReader_RejectsNonMMDB(t *testing.T) {
+	if _, err := newMMDBReader([]byte("not a database")); err == nil {
+		t.Error("expected error for data without metadata")
+	}
+}
@@ -0,0 +1,196 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrOffline is returned instead of querying the network in offline mode.
+var ErrOffline = errors.New("network lookups are disabled in offline mode")
+
+// resolvConfPath is the resolver configuration read to find local resolvers.
+const resolvConfPath = "/etc/resolv.conf"
+
+// NewOfflineEnricher creates an enricher that never queries the network.
+// Locations come from provider (the default GeoLite2 City database if nil),
+// ASNs from local ASN databases (see LocalASNDatabases), IX names from the
+// built-in and downloaded PeeringDB prefixes, and hostnames from the hosts
+// file and caching resolvers on the loopback interface. Fields without a
+// local source are left empty; OfflineGaps lists them.
+func NewOfflineEnricher(provider Provider) *Enricher {
+	e := NewEnricher()
+	if provider != nil {
+		e.geo = NewGeoLookupWithProvider(provider)
+	}
+	e.geo.offline = true
+	e.asn = NewOfflineASNLookup(LocalASNDatabases())
+	e.rdns = newRDNSLookup(localResolver())
+	return e
+}
+
+// NewOfflineASNLookup creates an ASN lookup that only reads the given
+// GeoLite2-ASN or IPinfo Lite databases.
+func NewOfflineASNLookup(paths []string) *ASNLookup {
+	l := &ASNLookup{offline: true}
+	for _, path := range paths {
+		decode := asnFromMaxMind
+		if filepath.Base(path) == IPinfoLiteDB {
+			decode = asnFromIPinfo
+		}
+		l.local = append(l.local, &asnDatabase{path: path, decode: decode})
+	}
+	return l
+}
+
+// LocalASNDatabases returns the installed databases offline ASN lookups
+// read: GeoLite2-ASN and IPinfo Lite, in the data directory.
+func LocalASNDatabases() []string {
+	dir, err := DataDir()
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, name := range []string{GeoLite2ASNDB, IPinfoLiteDB} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// lookupLocal looks up ip in the local databases, in order.
+func (l *ASNLookup) lookupLocal(ip net.IP) (*ASNResult, error) {
+	for _, db := range l.local {
+		if result, err := db.lookup(ip); err == nil && result != nil && result.ASN > 0 {
+			return result, nil
+		}
+	}
+	return nil, ErrOffline
+}
+
+// asnDatabase is a local MaxMind DB format ASN database, opened on first use.
+type asnDatabase struct {
+	path   string
+	decode func(record map[string]any) *ASNResult
+
+	once   sync.Once
+	reader *mmdbReader
+	err    error
+}
+
+func (d *asnDatabase) lookup(ip net.IP) (*ASNResult, error) {
+	d.once.Do(func() {
+		d.reader, d.err = openMMDB(d.path)
+	})
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	v, err := d.reader.lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := v.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	return d.decode(record), nil
+}
+
+// asnFromMaxMind decodes a GeoLite2-ASN record.
+func asnFromMaxMind(record map[string]any) *ASNResult {
+	org, _ := record["autonomous_system_organization"].(string)
+	return &ASNResult{ASN: uint32(toUint(record["autonomous_system_number"])), Name: org}
+}
+
+// asnFromIPinfo decodes an IPinfo Lite record, whose ASN is a string such as
+// "AS15169".
+func asnFromIPinfo(record map[string]any) *ASNResult {
+	asn, _ := record["asn"].(string)
+	n, _ := strconv.ParseUint(strings.TrimPrefix(asn, "AS"), 10, 32)
+	name, _ := record["as_name"].(string)
+	country, _ := record["country_code"].(string)
+	return &ASNResult{ASN: uint32(n), Name: name, Country: country}
+}
+
+// localResolver returns a resolver that only reads the hosts file and asks
+// nameservers on the loopback interface, such as systemd-resolved or
+// dnsmasq caches, so no query is sent off the host by gtrace itself.
+func localResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+				return nil, ErrOffline
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// hasLocalNameserver reports whether the resolver configuration at path
+// lists a nameserver on the loopback interface.
+func hasLocalNameserver(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			if ip := net.ParseIP(fields[1]); ip != nil && ip.IsLoopback() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OfflineGap is an enrichment field an offline enricher cannot fill.
+type OfflineGap struct {
+	Fields string // Affected fields
+	Reason string // Missing local source
+}
+
+func (g OfflineGap) String() string {
+	return g.Fields + " unavailable: " + g.Reason
+}
+
+// OfflineGaps lists the fields NewOfflineEnricher(provider) leaves empty for
+// lack of local data.
+func OfflineGaps(provider Provider) []OfflineGap {
+	if provider == nil {
+		provider = NewGeoLookup().provider
+	}
+	dir, _ := DataDir()
+
+	var gaps []OfflineGap
+	if len(LocalASNDatabases()) == 0 {
+		gaps = append(gaps, OfflineGap{"ASN and AS name", fmt.Sprintf("no %s or %s in %s", GeoLite2ASNDB, IPinfoLiteDB, dir)})
+	}
+	if !providerInstalled(provider) {
+		reason := "no GeoIP database"
+		if provider != nil {
+			reason += " at " + provider.Path()
+		}
+		gaps = append(gaps, OfflineGap{"city and country", reason})
+	}
+	if !hasLocalNameserver(resolvConfPath) {
+		gaps = append(gaps, OfflineGap{"hostnames", "no caching resolver on the loopback interface, only /etc/hosts is read"})
+	}
+	return gaps
+}
@@ -0,0 +1,153 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOfflineASNLookup_ReadsLocalDatabases(t *testing.T) {
+	maxmind := writeMMDB(t, GeoLite2ASNDB, []mmdbNetwork{
+		{cidr: "8.8.8.0/24", record: map[string]any{"autonomous_system_number": uint32(15169), "autonomous_system_organization": "GOOGLE"}},
+	})
+	ipinfo := writeMMDB(t, IPinfoLiteDB, []mmdbNetwork{
+		{cidr: "9.9.9.0/24", record: map[string]any{"asn": "AS19281", "as_name": "Quad9", "country_code": "CH"}},
+	})
+	l := NewOfflineASNLookup([]string{maxmind, ipinfo})
+
+	got, err := l.Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup(8.8.8.8) error: %v", err)
+	}
+	if got.ASN != 15169 || got.Name != "GOOGLE" {
+		t.Errorf("Lookup(8.8.8.8) = %+v, want AS15169 GOOGLE", got)
+	}
+
+	got, err = l.Lookup(context.Background(), net.ParseIP("9.9.9.9"))
+	if err != nil {
+		t.Fatalf("Lookup(9.9.9.9) error: %v", err)
+	}
+	if got.ASN != 19281 || got.Name != "Quad9" || got.Country != "CH" {
+		t.Errorf("Lookup(9.9.9.9) = %+v, want AS19281 Quad9 CH", got)
+	}
+
+	if _, err := l.Lookup(context.Background(), net.ParseIP("1.1.1.1")); !errors.Is(err, ErrOffline) {
+		t.Errorf("Lookup(1.1.1.1) error = %v, want ErrOffline", err)
+	}
+}
+
+func TestGeoLookup_OfflineSkipsAPI(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"status":"success","countryCode":"US"}`))
+	}))
+	defer srv.Close()
+
+	lookup := NewGeoLookupWithProvider(nil)
+	lookup.apiBaseURL = srv.URL
+	lookup.offline = true
+
+	got, err := lookup.Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if !got.IsEmpty() {
+		t.Errorf("Lookup() = %+v, want empty", got)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("API queried %d times, want 0", hits.Load())
+	}
+}
+
+func TestLocalResolver_RefusesRemoteNameservers(t *testing.T) {
+	r := localResolver()
+	if _, err := r.Dial(context.Background(), "udp", "8.8.8.8:53"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Dial(8.8.8.8:53) error = %v, want ErrOffline", err)
+	}
+	if _, err := r.Dial(context.Background(), "udp", "[2001:4860:4860::8888]:53"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Dial(IPv6 nameserver) error = %v, want ErrOffline", err)
+	}
+}
+
+func TestHasLocalNameserver(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		want bool
+	}{
+		{"systemd-resolved", "nameserver 127.0.0.53\noptions edns0\n", true},
+		{"ipv6 loopback", "nameserver ::1\n", true},
+		{"remote only", "# upstream\nnameserver 8.8.8.8\nnameserver 1.1.1.1\n", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resolv.conf")
+			if err := os.WriteFile(path, []byte(tt.conf), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := hasLocalNameserver(path); got != tt.want {
+				t.Errorf("hasLocalNameserver() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if hasLocalNameserver(filepath.Join(t.TempDir(), "missing")) {
+		t.Error("expected false for a missing file")
+	}
+}
+
+func TestOfflineGaps_ReportsMissingDatabases(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	gaps := OfflineGaps(nil)
+	fields := map[string]bool{}
+	for _, g := range gaps {
+		fields[g.Fields] = true
+	}
+	if !fields["ASN and AS name"] || !fields["city and country"] {
+		t.Errorf("OfflineGaps() = %v, want ASN and location gaps", gaps)
+	}
+}
+
+func TestOfflineGaps_LocalDatabasesCloseGaps(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".gtr", "data")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ipinfo := writeMMDB(t, IPinfoLiteDB, []mmdbNetwork{
+		{cidr: "9.9.9.0/24", record: map[string]any{"asn": "AS19281", "country_code": "CH"}},
+	})
+	data, _ := os.ReadFile(ipinfo)
+	if err := os.WriteFile(filepath.Join(dir, IPinfoLiteDB), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := NewProvider(ProviderIPinfo, "")
+
+	for _, g := range OfflineGaps(p) {
+		if g.Fields != "hostnames" {
+			t.Errorf("unexpected gap %v", g)
+		}
+	}
+}
+
+func TestOfflineEnricher_EnrichIPStaysLocal(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	e := NewOfflineEnricher(nil)
+
+	got, err := e.EnrichIP(context.Background(), net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("EnrichIP() error: %v", err)
+	}
+	if got.ASN != 0 || got.Country != "" {
+		t.Errorf("EnrichIP() = %+v, want no ASN or location without local databases", got)
+	}
+}
@@ -0,0 +1,188 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PeeringDBFile is the name of the cached PeeringDB IXP prefix list in the data directory.
+const PeeringDBFile = "peeringdb-ix.json"
+
+// PeeringDBAPIURL is the PeeringDB REST API endpoint.
+const PeeringDBAPIURL = "https://www.peeringdb.com/api"
+
+// IXPrefix is an Internet Exchange peering LAN prefix.
+type IXPrefix struct {
+	Prefix  string `json:"prefix"`
+	Name    string `json:"name"`
+	City    string `json:"city,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// peeringDBCache is the on-disk format of the PeeringDB prefix list.
+type peeringDBCache struct {
+	Updated  time.Time  `json:"updated"`
+	Prefixes []IXPrefix `json:"prefixes"`
+}
+
+// DefaultPeeringDBPath returns the default path of the cached PeeringDB prefix list.
+func DefaultPeeringDBPath() string {
+	dir, err := DataDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, PeeringDBFile)
+}
+
+// FetchPeeringDB downloads the peering LAN prefixes of every exchange
+// listed in PeeringDB. baseURL defaults to PeeringDBAPIURL.
+func FetchPeeringDB(ctx context.Context, client *http.Client, baseURL string) ([]IXPrefix, error) {
+	if baseURL == "" {
+		baseURL = PeeringDBAPIURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+
+	// Prefixes belong to an exchange LAN, which belongs to an exchange
+	var exchanges struct {
+		Data []struct {
+			ID      int    `json:"id"`
+			Name    string `json:"name"`
+			City    string `json:"city"`
+			Country string `json:"country"`
+		} `json:"data"`
+	}
+	var lans struct {
+		Data []struct {
+			ID   int `json:"id"`
+			IXID int `json:"ix_id"`
+		} `json:"data"`
+	}
+	var prefixes struct {
+		Data []struct {
+			IXLanID int    `json:"ixlan_id"`
+			Prefix  string `json:"prefix"`
+		} `json:"data"`
+	}
+
+	if err := getPeeringDB(ctx, client, baseURL+"/ix?fields=id,name,city,country", &exchanges); err != nil {
+		return nil, err
+	}
+	if err := getPeeringDB(ctx, client, baseURL+"/ixlan?fields=id,ix_id", &lans); err != nil {
+		return nil, err
+	}
+	if err := getPeeringDB(ctx, client, baseURL+"/ixpfx?fields=ixlan_id,prefix", &prefixes); err != nil {
+		return nil, err
+	}
+
+	lanIX := make(map[int]int, len(lans.Data))
+	for _, lan := range lans.Data {
+		lanIX[lan.ID] = lan.IXID
+	}
+	byID := make(map[int]IXPrefix, len(exchanges.Data))
+	for _, ix := range exchanges.Data {
+		byID[ix.ID] = IXPrefix{Name: ix.Name, City: ix.City, Country: ix.Country}
+	}
+
+	var result []IXPrefix
+	for _, p := range prefixes.Data {
+		ix, ok := byID[lanIX[p.IXLanID]]
+		if !ok {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(p.Prefix); err != nil {
+			continue
+		}
+		ix.Prefix = p.Prefix
+		result = append(result, ix)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("PeeringDB returned no IX prefixes")
+	}
+	return result, nil
+}
+
+// getPeeringDB fetches a PeeringDB API URL and decodes it into v.
+func getPeeringDB(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("PeeringDB request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PeeringDB request failed: HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("PeeringDB returned invalid data: %w", err)
+	}
+	return nil
+}
+
+// SavePeeringDB atomically writes the prefix list to path.
+func SavePeeringDB(path string, prefixes []IXPrefix) error {
+	data, err := json.Marshal(peeringDBCache{Updated: time.Now(), Prefixes: prefixes})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write IX prefixes: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write IX prefixes: %w", err)
+	}
+	return nil
+}
+
+// LoadPeeringDB reads a prefix list written by SavePeeringDB.
+func LoadPeeringDB(path string) ([]IXPrefix, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var cache peeringDBCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid IX prefix file %s: %w", path, err)
+	}
+	return cache.Prefixes, cache.Updated, nil
+}
+
+// UpdatePeeringDB downloads the PeeringDB prefix list and stores it at path.
+// It returns the number of prefixes saved.
+func UpdatePeeringDB(ctx context.Context, path string) (int, error) {
+	prefixes, err := FetchPeeringDB(ctx, nil, PeeringDBAPIURL)
+	if err != nil {
+		return 0, err
+	}
+	if err := SavePeeringDB(path, prefixes); err != nil {
+		return 0, err
+	}
+	return len(prefixes), nil
+}
@@ -0,0 +1,121 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func newTestPeeringDB(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ix":
+			fmt.Fprint(w, `{"data":[
+				{"id":31,"name":"DE-CIX Frankfurt","city":"Frankfurt","country":"DE"},
+				{"id":26,"name":"AMS-IX","city":"Amsterdam","country":"NL"}]}`)
+		case "/ixlan":
+			fmt.Fprint(w, `{"data":[{"id":31,"ix_id":31},{"id":26,"ix_id":26},{"id":99,"ix_id":404}]}`)
+		case "/ixpfx":
+			fmt.Fprint(w, `{"data":[
+				{"ixlan_id":31,"prefix":"80.81.192.0/21"},
+				{"ixlan_id":31,"prefix":"2001:7f8::/64"},
+				{"ixlan_id":26,"prefix":"80.249.208.0/21"},
+				{"ixlan_id":99,"prefix":"192.0.2.0/24"},
+				{"ixlan_id":26,"prefix":"not-a-prefix"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFetchPeeringDB(t *testing.T) {
+	srv := newTestPeeringDB(t)
+	defer srv.Close()
+
+	prefixes, err := FetchPeeringDB(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prefixes) != 3 {
+		t.Fatalf("expected 3 prefixes (orphan LAN and invalid prefix skipped), got %d: %+v", len(prefixes), prefixes)
+	}
+	want := IXPrefix{Prefix: "80.81.192.0/21", Name: "DE-CIX Frankfurt", City: "Frankfurt", Country: "DE"}
+	if prefixes[0] != want {
+		t.Errorf("expected %+v, got %+v", want, prefixes[0])
+	}
+}
+
+func TestFetchPeeringDB_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	if _, err := FetchPeeringDB(context.Background(), srv.Client(), srv.URL); err == nil {
+		t.Error("expected error for HTTP 429")
+	}
+}
+
+func TestPeeringDB_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", PeeringDBFile)
+	prefixes := []IXPrefix{{Prefix: "80.81.192.0/21", Name: "DE-CIX Frankfurt", City: "Frankfurt", Country: "DE"}}
+
+	if err := SavePeeringDB(path, prefixes); err != nil {
+		t.Fatalf("SavePeeringDB() error: %v", err)
+	}
+	loaded, updated, err := LoadPeeringDB(path)
+	if err != nil {
+		t.Fatalf("LoadPeeringDB() error: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != prefixes[0] {
+		t.Errorf("expected %+v, got %+v", prefixes, loaded)
+	}
+	if updated.IsZero() {
+		t.Error("expected update time to be recorded")
+	}
+}
+
+func TestIXLookup_WithPeeringDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PeeringDBFile)
+	err := SavePeeringDB(path, []IXPrefix{
+		{Prefix: "80.81.192.0/21", Name: "DE-CIX Frankfurt", City: "Frankfurt", Country: "DE"},
+		{Prefix: "185.1.0.0/24", Name: "BCIX", City: "Berlin", Country: "DE"},
+		{Prefix: "37.49.237.0/24", Name: "France-IX Marseille", City: "Marseille", Country: "FR"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := NewIXLookupWithPeeringDB(path)
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"185.1.0.10", "BCIX"},                 // Only in PeeringDB
+		{"80.81.192.1", "DE-CIX Frankfurt"},    // PeeringDB replaces the built-in entry
+		{"37.49.237.5", "France-IX Marseille"}, // Most specific prefix wins
+		{"37.49.236.5", "France-IX"},           // Built-in prefix still used
+		{"8.8.8.8", ""},
+	}
+	for _, tt := range tests {
+		result, err := lookup.Lookup(context.Background(), net.ParseIP(tt.ip))
+		if err != nil {
+			t.Fatalf("Lookup(%s) error: %v", tt.ip, err)
+		}
+		if result.Name != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.ip, result.Name, tt.want)
+		}
+	}
+}
+
+func TestNewIXLookupWithPeeringDB_MissingFile(t *testing.T) {
+	lookup := NewIXLookupWithPeeringDB(filepath.Join(t.TempDir(), "missing.json"))
+	if lookup.KnownIXCount() != NewIXLookupWithPeeringDB("").KnownIXCount() {
+		t.Error("expected only built-in prefixes when the PeeringDB file is missing")
+	}
+}
@@ -0,0 +1,196 @@
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GeoIP provider names.
+const (
+	ProviderMaxMind = "maxmind"
+	ProviderIPinfo  = "ipinfo"
+	ProviderDBIP    = "dbip"
+)
+
+// Default database file names for the IPinfo and DB-IP providers.
+const (
+	IPinfoLiteDB   = "ipinfo_lite.mmdb"
+	DBIPCityLiteDB = "dbip-city-lite.mmdb"
+)
+
+// Providers lists the supported GeoIP provider names.
+var Providers = []string{ProviderMaxMind, ProviderIPinfo, ProviderDBIP}
+
+// Provider looks up locations in an offline GeoIP database.
+type Provider interface {
+	// Name returns the provider name (see Providers).
+	Name() string
+
+	// Path returns the database file the provider reads.
+	Path() string
+
+	// Lookup returns the location of ip, or nil if the database has no entry.
+	Lookup(ip net.IP) (*GeoResult, error)
+}
+
+// NewProvider creates the named provider reading the database at path, or at
+// the provider's default path in the data directory when path is empty.
+// The database is opened on first lookup.
+func NewProvider(name, path string) (Provider, error) {
+	if path == "" {
+		path = DefaultProviderPath(name)
+	}
+
+	switch name {
+	case "", ProviderMaxMind:
+		return &mmdbProvider{name: ProviderMaxMind, path: path, decode: geoFromMaxMind}, nil
+	case ProviderDBIP:
+		// DB-IP's mmdb databases use the GeoLite2 record layout
+		return &mmdbProvider{name: ProviderDBIP, path: path, decode: geoFromMaxMind}, nil
+	case ProviderIPinfo:
+		return &mmdbProvider{name: ProviderIPinfo, path: path, decode: geoFromIPinfo}, nil
+	default:
+		return nil, fmt.Errorf("unknown GeoIP provider %q: must be %s", name, strings.Join(Providers, ", "))
+	}
+}
+
+// DefaultProviderPath returns the default database path of the named provider.
+func DefaultProviderPath(name string) string {
+	switch name {
+	case ProviderIPinfo, ProviderDBIP:
+		dir, err := DataDir()
+		if err != nil {
+			return ""
+		}
+		if name == ProviderIPinfo {
+			return filepath.Join(dir, IPinfoLiteDB)
+		}
+		return filepath.Join(dir, DBIPCityLiteDB)
+	default:
+		return DefaultGeoDBPath()
+	}
+}
+
+// mmdbProvider is a Provider backed by a MaxMind DB format file.
+type mmdbProvider struct {
+	name   string
+	path   string
+	decode func(record map[string]any) *GeoResult
+
+	once   sync.Once
+	reader *mmdbReader
+	err    error
+}
+
+func (p *mmdbProvider) Name() string { return p.name }
+func (p *mmdbProvider) Path() string { return p.path }
+
+// Lookup opens the database on first use and decodes the record for ip.
+func (p *mmdbProvider) Lookup(ip net.IP) (*GeoResult, error) {
+	p.once.Do(func() {
+		p.reader, p.err = openMMDB(p.path)
+	})
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	v, err := p.reader.lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := v.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	return p.decode(record), nil
+}
+
+// geoFromMaxMind decodes a GeoLite2/GeoIP2 City or Country record.
+func geoFromMaxMind(record map[string]any) *GeoResult {
+	result := &GeoResult{
+		City:        englishName(record["city"]),
+		CountryName: englishName(record["country"]),
+	}
+	if country, ok := record["country"].(map[string]any); ok {
+		result.Country, _ = country["iso_code"].(string)
+	}
+	if subdivisions, ok := record["subdivisions"].([]any); ok && len(subdivisions) > 0 {
+		result.Region = englishName(subdivisions[0])
+	}
+	if location, ok := record["location"].(map[string]any); ok {
+		result.Latitude = toFloat(location["latitude"])
+		result.Longitude = toFloat(location["longitude"])
+		result.Timezone, _ = location["time_zone"].(string)
+	}
+	return result
+}
+
+// geoFromIPinfo decodes an IPinfo record. IPinfo databases are flat: the free
+// Lite database has country_code/country, while location databases add city,
+// region, lat/lng (as strings) and timezone. Older country databases store
+// the ISO code in country and the name in country_name.
+func geoFromIPinfo(record map[string]any) *GeoResult {
+	str := func(key string) string {
+		s, _ := record[key].(string)
+		return s
+	}
+
+	result := &GeoResult{
+		City:        str("city"),
+		Country:     str("country_code"),
+		CountryName: str("country"),
+		Region:      str("region"),
+		Latitude:    toFloat(record["lat"]),
+		Longitude:   toFloat(record["lng"]),
+		Timezone:    str("timezone"),
+	}
+	if result.Country == "" && len(result.CountryName) == 2 {
+		result.Country = result.CountryName
+		result.CountryName = str("country_name")
+	}
+	return result
+}
+
+// englishName returns names.en of a GeoLite2 record such as city or country.
+func englishName(v any) string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return ""
+	}
+	names, ok := m["names"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	name, _ := names["en"].(string)
+	return name
+}
+
+// toFloat converts a decoded number or numeric string to float64.
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case uint64:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
+
+// providerInstalled reports whether the provider's database file exists.
+func providerInstalled(p Provider) bool {
+	if p == nil || p.Path() == "" {
+		return false
+	}
+	_, err := os.Stat(p.Path())
+	return err == nil
+}
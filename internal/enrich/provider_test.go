@@ -0,0 +1,131 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestNewProvider_MaxMind(t *testing.T) {
+	path := writeMMDB(t, GeoLite2CityDB, []mmdbNetwork{{
+		cidr: "8.8.8.0/24",
+		record: map[string]any{
+			"city":         map[string]any{"names": map[string]any{"en": "Mountain View"}},
+			"country":      map[string]any{"iso_code": "US", "names": map[string]any{"en": "United States"}},
+			"subdivisions": []any{map[string]any{"names": map[string]any{"en": "California"}}},
+			"location":     map[string]any{"latitude": 37.386, "longitude": -122.0838, "time_zone": "America/Los_Angeles"},
+		},
+	}})
+
+	p, err := NewProvider(ProviderMaxMind, path)
+	if err != nil {
+		t.Fatalf("NewProvider() error: %v", err)
+	}
+	got, err := p.Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+
+	want := GeoResult{
+		City:        "Mountain View",
+		Country:     "US",
+		CountryName: "United States",
+		Region:      "California",
+		Latitude:    37.386,
+		Longitude:   -122.0838,
+		Timezone:    "America/Los_Angeles",
+	}
+	if got == nil || *got != want {
+		t.Errorf("Lookup() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewProvider_DBIPUsesGeoLite2Layout(t *testing.T) {
+	path := writeMMDB(t, DBIPCityLiteDB, []mmdbNetwork{{
+		cidr: "1.1.1.0/24",
+		record: map[string]any{
+			"city":    map[string]any{"names": map[string]any{"en": "Sydney"}},
+			"country": map[string]any{"iso_code": "AU"},
+		},
+	}})
+
+	p, _ := NewProvider(ProviderDBIP, path)
+	got, err := p.Lookup(net.ParseIP("1.1.1.1"))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if got == nil || got.String() != "Sydney, AU" {
+		t.Errorf("Lookup() = %+v, want Sydney, AU", got)
+	}
+	if p.Name() != ProviderDBIP {
+		t.Errorf("Name() = %q, want %q", p.Name(), ProviderDBIP)
+	}
+}
+
+func TestNewProvider_IPinfo(t *testing.T) {
+	path := writeMMDB(t, IPinfoLiteDB, []mmdbNetwork{
+		{cidr: "9.9.9.0/24", record: map[string]any{"country_code": "CH", "country": "Switzerland", "asn": "AS19281"}},
+		{cidr: "2001:db8::/32", record: map[string]any{"city": "Paris", "region": "Île-de-France", "country": "FR", "country_name": "France", "lat": "48.8534", "lng": "2.3488"}},
+	})
+
+	p, _ := NewProvider(ProviderIPinfo, path)
+
+	lite, err := p.Lookup(net.ParseIP("9.9.9.9"))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if lite == nil || lite.Country != "CH" || lite.CountryName != "Switzerland" {
+		t.Errorf("Lookup(9.9.9.9) = %+v, want CH/Switzerland", lite)
+	}
+
+	loc, err := p.Lookup(net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if loc == nil || loc.City != "Paris" || loc.Country != "FR" || loc.CountryName != "France" || loc.Latitude != 48.8534 {
+		t.Errorf("Lookup(2001:db8::1) = %+v, want Paris, FR", loc)
+	}
+}
+
+func TestNewProvider_UnknownName(t *testing.T) {
+	if _, err := NewProvider("geoip3", ""); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestNewProvider_DefaultPaths(t *testing.T) {
+	for name, file := range map[string]string{
+		ProviderMaxMind: GeoLite2CityDB,
+		ProviderIPinfo:  IPinfoLiteDB,
+		ProviderDBIP:    DBIPCityLiteDB,
+	} {
+		p, err := NewProvider(name, "")
+		if err != nil {
+			t.Fatalf("NewProvider(%q) error: %v", name, err)
+		}
+		if got := p.Path(); len(got) < len(file) || got[len(got)-len(file):] != file {
+			t.Errorf("NewProvider(%q).Path() = %q, want a %s path", name, got, file)
+		}
+	}
+}
+
+func TestGeoLookup_UsesProviderBeforeAPI(t *testing.T) {
+	path := writeMMDB(t, IPinfoLiteDB, []mmdbNetwork{
+		{cidr: "9.9.9.0/24", record: map[string]any{"country_code": "CH", "country": "Switzerland"}},
+	})
+	p, _ := NewProvider(ProviderIPinfo, path)
+
+	lookup := NewGeoLookupWithProvider(p)
+	lookup.apiBaseURL = "http://127.0.0.1:0" // Unreachable: the API must not be needed
+	if !lookup.HasDatabase() {
+		t.Fatal("HasDatabase() = false, want true")
+	}
+
+	got, err := lookup.Lookup(context.Background(), net.ParseIP("9.9.9.9"))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if got.Country != "CH" {
+		t.Errorf("Lookup() country = %q, want CH", got.Country)
+	}
+}
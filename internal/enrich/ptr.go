@@ -0,0 +1,159 @@
+package enrich
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PTRHints is structured data inferred from a router's PTR record using
+// common carrier naming conventions, e.g.
+// "ae-1-3509.edge3.frankfurt1.level3.net" or "be2101.ccr41.fra03.atlas.cogentco.com".
+// Naming is not standardized, so every field is a best-effort guess.
+type PTRHints struct {
+	Interface     string // Interface name as written in the PTR, e.g. "xe-0-0-0"
+	InterfaceType string // Interface family, e.g. "10GE" or "LAG"
+	Role          string // Router role: core, edge, border, aggregation or access
+	LocationCode  string // City or site code found in the name, e.g. "fra"
+	City          string // City the code stands for
+	Country       string // Country code (ISO 3166-1 alpha-2)
+}
+
+// InterfaceString formats the interface hint, e.g. "xe-0-0-0 (10GE, core)".
+func (h PTRHints) InterfaceString() string {
+	var details []string
+	if h.InterfaceType != "" {
+		details = append(details, h.InterfaceType)
+	}
+	if h.Role != "" {
+		details = append(details, h.Role)
+	}
+
+	switch {
+	case h.Interface == "" && len(details) == 0:
+		return ""
+	case h.Interface == "":
+		return strings.Join(details, ", ")
+	case len(details) == 0:
+		return h.Interface
+	}
+	return h.Interface + " (" + strings.Join(details, ", ") + ")"
+}
+
+// LocationString formats the location hint, e.g. "Frankfurt, DE (fra)".
+func (h PTRHints) LocationString() string {
+	if h.City == "" {
+		return ""
+	}
+	return h.City + ", " + h.Country + " (" + h.LocationCode + ")"
+}
+
+// interfacePattern matches an interface name at the start of a PTR label.
+// Longer Cisco names come first so they win over their abbreviations.
+var interfacePattern = regexp.MustCompile(`^(bundle-ether|port-channel|tengigabitethernet|tengige|hundredgige|fortygige|gigabitethernet|loopback|vlan|irb|ae|xe|ge|et|so|fe|be|te|hu|gi|fo|po|lo|vl|ve)-?\d+([-_/:]\d+)*`)
+
+// interfaceTypes maps interface name prefixes to their family.
+var interfaceTypes = map[string]string{
+	"ae": "LAG", "be": "LAG", "bundle-ether": "LAG", "po": "LAG", "port-channel": "LAG",
+	"ge": "1GE", "gi": "1GE", "gigabitethernet": "1GE",
+	"xe": "10GE", "te": "10GE", "tengige": "10GE", "tengigabitethernet": "10GE",
+	"fo": "40GE", "fortygige": "40GE",
+	"et": "100GE", "hu": "100GE", "hundredgige": "100GE",
+	"so": "SONET", "fe": "FE",
+	"lo": "loopback", "loopback": "loopback",
+	"vl": "VLAN", "vlan": "VLAN", "irb": "VLAN", "ve": "VLAN",
+}
+
+// routerRoles maps router name prefixes (digits stripped) to their role.
+var routerRoles = map[string]string{
+	"cr": "core", "ccr": "core", "xcr": "core", "core": "core", "bb": "core", "bbr": "core", "mcr": "core",
+	"er": "edge", "edge": "edge", "pe": "edge", "gw": "edge",
+	"br": "border", "bdr": "border", "ibr": "border", "border": "border", "peer": "border",
+	"ar": "aggregation", "agr": "aggregation", "agg": "aggregation", "dar": "aggregation",
+	"acc": "access", "access": "access",
+}
+
+type ptrLocation struct {
+	city    string
+	country string
+}
+
+// ptrLocations maps the city codes carriers use in router names to cities:
+// IATA airport and metro codes, common abbreviations, full names, and
+// CLLI-style codes (city + state/country, e.g. "frnkge").
+var ptrLocations = map[string]ptrLocation{
+	"ams": {"Amsterdam", "NL"}, "amsterdam": {"Amsterdam", "NL"}, "amstnl": {"Amsterdam", "NL"},
+	"arn": {"Stockholm", "SE"}, "sto": {"Stockholm", "SE"}, "stockholm": {"Stockholm", "SE"},
+	"ash": {"Ashburn", "US"}, "iad": {"Ashburn", "US"}, "ashburn": {"Ashburn", "US"}, "asbnva": {"Ashburn", "US"},
+	"atl": {"Atlanta", "US"}, "atlanta": {"Atlanta", "US"},
+	"bru": {"Brussels", "BE"}, "brussels": {"Brussels", "BE"},
+	"chi": {"Chicago", "US"}, "ord": {"Chicago", "US"}, "chicago": {"Chicago", "US"}, "chcgil": {"Chicago", "US"},
+	"cph": {"Copenhagen", "DK"}, "copenhagen": {"Copenhagen", "DK"},
+	"dal": {"Dallas", "US"}, "dfw": {"Dallas", "US"}, "dallas": {"Dallas", "US"}, "dllstx": {"Dallas", "US"},
+	"den": {"Denver", "US"}, "denver": {"Denver", "US"},
+	"dub": {"Dublin", "IE"}, "dublin": {"Dublin", "IE"},
+	"fra": {"Frankfurt", "DE"}, "frankfurt": {"Frankfurt", "DE"}, "frnkge": {"Frankfurt", "DE"},
+	"hel": {"Helsinki", "FI"}, "helsinki": {"Helsinki", "FI"},
+	"hkg": {"Hong Kong", "HK"}, "hongkong": {"Hong Kong", "HK"},
+	"lax": {"Los Angeles", "US"}, "losangeles": {"Los Angeles", "US"}, "lsanca": {"Los Angeles", "US"},
+	"lon": {"London", "GB"}, "ldn": {"London", "GB"}, "lhr": {"London", "GB"}, "london": {"London", "GB"}, "lndngb": {"London", "GB"},
+	"mad": {"Madrid", "ES"}, "madrid": {"Madrid", "ES"},
+	"mia": {"Miami", "US"}, "miami": {"Miami", "US"},
+	"mil": {"Milan", "IT"}, "mxp": {"Milan", "IT"}, "milan": {"Milan", "IT"},
+	"mrs": {"Marseille", "FR"}, "marseille": {"Marseille", "FR"},
+	"nyc": {"New York", "US"}, "jfk": {"New York", "US"}, "ewr": {"Newark", "US"}, "newyork": {"New York", "US"}, "nycmny": {"New York", "US"},
+	"osl": {"Oslo", "NO"}, "oslo": {"Oslo", "NO"},
+	"par": {"Paris", "FR"}, "cdg": {"Paris", "FR"}, "paris": {"Paris", "FR"}, "parsfr": {"Paris", "FR"},
+	"prg": {"Prague", "CZ"}, "prague": {"Prague", "CZ"},
+	"sea": {"Seattle", "US"}, "seattle": {"Seattle", "US"}, "sttlwa": {"Seattle", "US"},
+	"sfo": {"San Francisco", "US"}, "sanfrancisco": {"San Francisco", "US"},
+	"sin": {"Singapore", "SG"}, "sng": {"Singapore", "SG"}, "singapore": {"Singapore", "SG"},
+	"sjc": {"San Jose", "US"}, "sanjose": {"San Jose", "US"}, "snjsca": {"San Jose", "US"},
+	"syd": {"Sydney", "AU"}, "sydney": {"Sydney", "AU"},
+	"tyo": {"Tokyo", "JP"}, "nrt": {"Tokyo", "JP"}, "tokyo": {"Tokyo", "JP"},
+	"tor": {"Toronto", "CA"}, "yyz": {"Toronto", "CA"}, "toronto": {"Toronto", "CA"},
+	"vie": {"Vienna", "AT"}, "vienna": {"Vienna", "AT"},
+	"waw": {"Warsaw", "PL"}, "warsaw": {"Warsaw", "PL"},
+	"zrh": {"Zurich", "CH"}, "zurich": {"Zurich", "CH"},
+}
+
+// ParsePTR extracts interface, role and location hints from a router PTR
+// record. The registrable domain (the last two labels) is ignored.
+func ParsePTR(hostname string) PTRHints {
+	var hints PTRHints
+
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(hostname, ".")), ".")
+	if len(labels) < 3 {
+		return hints
+	}
+	labels = labels[:len(labels)-2]
+
+	for i, label := range labels {
+		rest := label
+		if i == 0 {
+			if m := interfacePattern.FindStringSubmatch(label); m != nil {
+				hints.Interface = m[0]
+				hints.InterfaceType = interfaceTypes[m[1]]
+				rest = strings.TrimLeft(label[len(m[0]):], "-_")
+			}
+		}
+
+		for _, word := range strings.FieldsFunc(rest, func(r rune) bool { return r == '-' || r == '_' }) {
+			word = strings.TrimRight(word, "0123456789")
+			if hints.Role == "" {
+				if role, ok := routerRoles[word]; ok {
+					hints.Role = role
+					continue
+				}
+			}
+			if hints.City == "" {
+				if loc, ok := ptrLocations[word]; ok {
+					hints.LocationCode = word
+					hints.City = loc.city
+					hints.Country = loc.country
+				}
+			}
+		}
+	}
+
+	return hints
+}
@@ -0,0 +1,68 @@
+package enrich
+
+import "testing"
+
+func TestParsePTR(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     PTRHints
+	}{
+		{
+			hostname: "ae-1-3509.edge3.frankfurt1.level3.net",
+			want:     PTRHints{Interface: "ae-1-3509", InterfaceType: "LAG", Role: "edge", LocationCode: "frankfurt", City: "Frankfurt", Country: "DE"},
+		},
+		{
+			hostname: "be2101.ccr41.fra03.atlas.cogentco.com",
+			want:     PTRHints{Interface: "be2101", InterfaceType: "LAG", Role: "core", LocationCode: "fra", City: "Frankfurt", Country: "DE"},
+		},
+		{
+			hostname: "xe-0-0-0-0.r01.frnkge03.de.bb.gin.ntt.net.",
+			want:     PTRHints{Interface: "xe-0-0-0-0", InterfaceType: "10GE", Role: "core", LocationCode: "frnkge", City: "Frankfurt", Country: "DE"},
+		},
+		{
+			hostname: "et-0-0-2.cr0-par1.ip4.gtt.net",
+			want:     PTRHints{Interface: "et-0-0-2", InterfaceType: "100GE", Role: "core", LocationCode: "par", City: "Paris", Country: "FR"},
+		},
+		{
+			hostname: "ae11-xcr1.lns.cw.net",
+			want:     PTRHints{Interface: "ae11", InterfaceType: "LAG", Role: "core"},
+		},
+		{
+			hostname: "HundredGigE0-1-0-0.br1.AMS.example.net",
+			want:     PTRHints{Interface: "hundredgige0-1-0-0", InterfaceType: "100GE", Role: "border", LocationCode: "ams", City: "Amsterdam", Country: "NL"},
+		},
+		{
+			hostname: "dns.google",
+			want:     PTRHints{},
+		},
+		{
+			hostname: "customer-42.dsl.example.com",
+			want:     PTRHints{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			if got := ParsePTR(tt.hostname); got != tt.want {
+				t.Errorf("ParsePTR(%q) = %+v, want %+v", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPTRHints_Strings(t *testing.T) {
+	h := PTRHints{Interface: "xe-0-0-0", InterfaceType: "10GE", Role: "core", LocationCode: "fra", City: "Frankfurt", Country: "DE"}
+	if got := h.InterfaceString(); got != "xe-0-0-0 (10GE, core)" {
+		t.Errorf("InterfaceString() = %q", got)
+	}
+	if got := h.LocationString(); got != "Frankfurt, DE (fra)" {
+		t.Errorf("LocationString() = %q", got)
+	}
+
+	if got := (PTRHints{Role: "edge"}).InterfaceString(); got != "edge" {
+		t.Errorf("InterfaceString() without interface = %q, want %q", got, "edge")
+	}
+	if got := (PTRHints{}).InterfaceString(); got != "" {
+		t.Errorf("InterfaceString() of empty hints = %q", got)
+	}
+}
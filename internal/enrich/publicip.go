@@ -0,0 +1,71 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPublicIPv4URL = "https://api.ipify.org"
+	defaultPublicIPv6URL = "https://api6.ipify.org"
+)
+
+// PublicIPLookup discovers the public address this host is seen from.
+type PublicIPLookup struct {
+	client *http.Client
+	v4URL  string // Plain-text IPv4 echo service (overridable for testing)
+	v6URL  string // Plain-text IPv6 echo service (overridable for testing)
+}
+
+// NewPublicIPLookup creates a new public IP lookup instance.
+func NewPublicIPLookup() *PublicIPLookup {
+	return &PublicIPLookup{
+		client: &http.Client{Timeout: 5 * time.Second},
+		v4URL:  defaultPublicIPv4URL,
+		v6URL:  defaultPublicIPv6URL,
+	}
+}
+
+// Lookup returns the public IP of this host for the given IP version (4 or 6).
+func (l *PublicIPLookup) Lookup(ctx context.Context, version int) (net.IP, error) {
+	url := l.v4URL
+	if version == 6 {
+		url = l.v6URL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("public IP lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("public IP lookup failed: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return nil, fmt.Errorf("public IP lookup failed: %w", err)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, errors.New("public IP lookup returned an invalid address")
+	}
+	if (version == 6) != (ip.To4() == nil) {
+		return nil, fmt.Errorf("public IP lookup returned %s, expected IPv%d", ip, version)
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,83 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestPublicIPLookup(v4, v6 string) *PublicIPLookup {
+	l := NewPublicIPLookup()
+	l.v4URL = v4
+	l.v6URL = v6
+	return l
+}
+
+func TestPublicIPLookup_IPv4(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "203.0.113.7")
+	}))
+	defer srv.Close()
+
+	ip, err := newTestPublicIPLookup(srv.URL, "").Lookup(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip.String() != "203.0.113.7" {
+		t.Errorf("expected 203.0.113.7, got %s", ip)
+	}
+}
+
+func TestPublicIPLookup_IPv6UsesV6Endpoint(t *testing.T) {
+	v4 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "203.0.113.7")
+	}))
+	defer v4.Close()
+	v6 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2001:db8::7")
+	}))
+	defer v6.Close()
+
+	ip, err := newTestPublicIPLookup(v4.URL, v6.URL).Lookup(context.Background(), 6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip.String() != "2001:db8::7" {
+		t.Errorf("expected 2001:db8::7, got %s", ip)
+	}
+}
+
+func TestPublicIPLookup_RejectsGarbage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>captive portal</html>")
+	}))
+	defer srv.Close()
+
+	if _, err := newTestPublicIPLookup(srv.URL, "").Lookup(context.Background(), 4); err == nil {
+		t.Error("expected error for non-IP response")
+	}
+}
+
+func TestPublicIPLookup_RejectsWrongFamily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "2001:db8::7")
+	}))
+	defer srv.Close()
+
+	if _, err := newTestPublicIPLookup(srv.URL, "").Lookup(context.Background(), 4); err == nil {
+		t.Error("expected error when IPv4 endpoint returns IPv6")
+	}
+}
+
+func TestPublicIPLookup_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestPublicIPLookup(srv.URL, "").Lookup(context.Background(), 4); err == nil {
+		t.Error("expected error for HTTP 503")
+	}
+}
@@ -6,37 +6,137 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"time"
 )
 
-// RDNSLookup performs reverse DNS lookups.
+// Reverse DNS limits. The PTR zones of many router addresses never answer,
+// so each query is bounded, only a few run at once, and failures are
+// remembered for a while instead of stalling every trace through them.
+const (
+	RDNSTimeout     = 2 * time.Second  // Per-address query timeout
+	RDNSConcurrency = 16               // Queries in flight at once
+	RDNSNegativeTTL = 10 * time.Minute // How long a missing name is remembered
+)
+
+// RDNSLookup performs reverse DNS lookups, caching names for the run and
+// failures for RDNSNegativeTTL. Concurrent lookups of one address share a
+// single query.
 type RDNSLookup struct {
 	resolver *net.Resolver
+	timeout  time.Duration
+	slots    chan struct{}
+	now      func() time.Time
+
+	mu       sync.Mutex
+	names    map[string]rdnsEntry
+	inflight map[string]*rdnsCall
+}
+
+// rdnsEntry is a cached lookup: a name, or "" until expires.
+type rdnsEntry struct {
+	name    string
+	expires time.Time
+}
+
+// rdnsCall is a query in flight; done is closed once name and err are set.
+type rdnsCall struct {
+	done chan struct{}
+	name string
+	err  error
 }
 
 // NewRDNSLookup creates a new reverse DNS lookup instance.
 func NewRDNSLookup() *RDNSLookup {
+	return newRDNSLookup(net.DefaultResolver)
+}
+
+// newRDNSLookup creates a reverse DNS lookup querying resolver.
+func newRDNSLookup(resolver *net.Resolver) *RDNSLookup {
 	return &RDNSLookup{
-		resolver: net.DefaultResolver,
+		resolver: resolver,
+		timeout:  RDNSTimeout,
+		slots:    make(chan struct{}, RDNSConcurrency),
+		now:      time.Now,
+		names:    make(map[string]rdnsEntry),
+		inflight: make(map[string]*rdnsCall),
 	}
 }
 
-// Lookup performs a reverse DNS lookup for the given IP.
+// Lookup performs a reverse DNS lookup for the given IP. An address whose
+// lookup failed recently returns no name without a new query. The query
+// itself runs in the background: when ctx ends first, its result is still
+// cached for the next Lookup.
 func (l *RDNSLookup) Lookup(ctx context.Context, ip net.IP) (string, error) {
 	if ip == nil {
 		return "", errors.New("nil IP address")
 	}
+	key := ip.String()
 
-	names, err := l.resolver.LookupAddr(ctx, ip.String())
-	if err != nil {
-		return "", fmt.Errorf("reverse DNS lookup failed: %w", err)
+	l.mu.Lock()
+	if name, ok := l.cachedLocked(key); ok {
+		l.mu.Unlock()
+		return name, nil
+	}
+	call, ok := l.inflight[key]
+	if !ok {
+		call = &rdnsCall{done: make(chan struct{})}
+		l.inflight[key] = call
+		go l.resolve(key, call)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.name, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Cached returns the known name of ip without querying: ok is false when
+// the address was never looked up or its failure has expired.
+func (l *RDNSLookup) Cached(ip net.IP) (name string, ok bool) {
+	if ip == nil {
+		return "", false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cachedLocked(ip.String())
+}
+
+func (l *RDNSLookup) cachedLocked(key string) (string, bool) {
+	e, ok := l.names[key]
+	if !ok || (e.name == "" && !l.now().Before(e.expires)) {
+		return "", false
 	}
+	return e.name, true
+}
 
-	if len(names) == 0 {
-		return "", nil
+// resolve queries the name of key once a slot is free, and records it.
+func (l *RDNSLookup) resolve(key string, call *rdnsCall) {
+	l.slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	names, err := l.resolver.LookupAddr(ctx, key)
+	cancel()
+	<-l.slots
+
+	if err != nil {
+		call.err = fmt.Errorf("reverse DNS lookup failed: %w", err)
+	} else if len(names) > 0 {
+		// Return the first hostname, cleaned up
+		call.name = l.cleanHostname(names[0])
 	}
 
-	// Return the first hostname, cleaned up
-	return l.cleanHostname(names[0]), nil
+	l.mu.Lock()
+	entry := rdnsEntry{name: call.name}
+	if entry.name == "" {
+		entry.expires = l.now().Add(RDNSNegativeTTL)
+	}
+	l.names[key] = entry
+	delete(l.inflight, key)
+	l.mu.Unlock()
+	close(call.done)
 }
 
 // formatPTRQuery creates the PTR query string (for testing/debugging).
@@ -2,9 +2,15 @@ package enrich
 
 import (
 	"context"
+	"encoding/binary"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 func TestRDNSLookup_FormatQuery_ReversesIPv4(t *testing.T) {
@@ -135,3 +141,136 @@ func TestRDNSLookup_Lookup_IPv6(t *testing.T) {
 		t.Logf("Got hostname for Google IPv6 DNS: %s", hostname)
 	}
 }
+
+// fakePTRResolver returns a resolver answering every PTR query with name
+// (NXDOMAIN when empty) after delay, counting the queries in queries.
+func fakePTRResolver(name string, delay time.Duration, queries *atomic.Int32) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				var size [2]byte
+				if _, err := io.ReadFull(server, size[:]); err != nil {
+					return
+				}
+				req := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(server, req); err != nil {
+					return
+				}
+				queries.Add(1)
+				time.Sleep(delay)
+
+				var p dnsmessage.Parser
+				h, err := p.Start(req)
+				if err != nil {
+					return
+				}
+				q, err := p.Question()
+				if err != nil {
+					return
+				}
+				rh := dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true, RCode: dnsmessage.RCodeNameError}
+				if name != "" {
+					rh.RCode = dnsmessage.RCodeSuccess
+				}
+				b := dnsmessage.NewBuilder(nil, rh)
+				b.StartQuestions()
+				b.Question(q)
+				if name != "" {
+					b.StartAnswers()
+					b.PTRResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60},
+						dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(name)})
+				}
+				resp, _ := b.Finish()
+				server.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp))))
+				server.Write(resp)
+			}()
+			return client, nil
+		},
+	}
+}
+
+func TestRDNSLookup_CachesNames(t *testing.T) {
+	var queries atomic.Int32
+	lookup := newRDNSLookup(fakePTRResolver("core1.fra.example.net.", 0, &queries))
+	ip := net.ParseIP("192.0.2.1")
+
+	for range 3 {
+		name, err := lookup.Lookup(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if name != "core1.fra.example.net" {
+			t.Errorf("Lookup = %q, want core1.fra.example.net", name)
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("%d queries, want 1", n)
+	}
+	if name, ok := lookup.Cached(ip); !ok || name != "core1.fra.example.net" {
+		t.Errorf("Cached = %q, %v, want the name", name, ok)
+	}
+}
+
+func TestRDNSLookup_NegativeCaching(t *testing.T) {
+	var queries atomic.Int32
+	lookup := newRDNSLookup(fakePTRResolver("", 0, &queries))
+	now := time.Now()
+	lookup.now = func() time.Time { return now }
+	ip := net.ParseIP("192.0.2.1")
+
+	if _, err := lookup.Lookup(context.Background(), ip); err == nil {
+		t.Error("expected the first lookup to fail")
+	}
+	name, err := lookup.Lookup(context.Background(), ip)
+	if err != nil || name != "" {
+		t.Errorf("cached failure = %q, %v, want no name and no error", name, err)
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("%d queries, want 1 while the failure is cached", n)
+	}
+
+	now = now.Add(RDNSNegativeTTL)
+	if _, ok := lookup.Cached(ip); ok {
+		t.Error("expected the failure to expire")
+	}
+	lookup.Lookup(context.Background(), ip)
+	if n := queries.Load(); n != 2 {
+		t.Errorf("%d queries, want 2 after the failure expired", n)
+	}
+}
+
+func TestRDNSLookup_TimesOutSlowZones(t *testing.T) {
+	var queries atomic.Int32
+	lookup := newRDNSLookup(fakePTRResolver("slow.example.net.", time.Second, &queries))
+	lookup.timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, err := lookup.Lookup(context.Background(), net.ParseIP("192.0.2.1")); err == nil {
+		t.Error("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("lookup took %v, want about the 50ms timeout", elapsed)
+	}
+}
+
+func TestRDNSLookup_SharesConcurrentQueries(t *testing.T) {
+	var queries atomic.Int32
+	lookup := newRDNSLookup(fakePTRResolver("core1.example.net.", 50*time.Millisecond, &queries))
+	ip := net.ParseIP("192.0.2.1")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lookup.Lookup(context.Background(), ip)
+		}()
+	}
+	wg.Wait()
+	if n := queries.Load(); n != 1 {
+		t.Errorf("%d queries, want 1 shared by concurrent lookups", n)
+	}
+}
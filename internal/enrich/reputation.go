@@ -0,0 +1,275 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// ReputationDir is the directory of downloaded reputation feeds in the data
+// directory.
+const ReputationDir = "reputation"
+
+// DefaultReputationMaxAge is how long a downloaded feed is used before it is
+// downloaded again. DROP and bogon lists change daily at most.
+const DefaultReputationMaxAge = 24 * time.Hour
+
+// ReputationFeed is a list of prefixes to check addresses against: a local
+// file or an http(s) URL with one prefix or address per line. Text after '#'
+// or ';' is ignored, and lines may also be Spamhaus JSON records ("cidr").
+type ReputationFeed struct {
+	Name     string // Shown in listings; also names the downloaded copy
+	Category string // hop.Reputation* category of the listed addresses
+	Source   string // File path or URL
+}
+
+// DefaultReputationFeeds are the feeds checked by --reputation.
+var DefaultReputationFeeds = []ReputationFeed{
+	{Name: "spamhaus-drop", Category: hop.ReputationHijack, Source: "https://www.spamhaus.org/drop/drop_v4.json"},
+	{Name: "spamhaus-dropv6", Category: hop.ReputationHijack, Source: "https://www.spamhaus.org/drop/drop_v6.json"},
+	{Name: "cymru-fullbogons", Category: hop.ReputationBogon, Source: "https://www.team-cymru.org/Services/Bogons/fullbogons-ipv4.txt"},
+	{Name: "cymru-fullbogons-v6", Category: hop.ReputationBogon, Source: "https://www.team-cymru.org/Services/Bogons/fullbogons-ipv6.txt"},
+}
+
+// CustomReputationFeed returns the feed for a custom blocklist file or URL,
+// named after its file name.
+func CustomReputationFeed(source string) ReputationFeed {
+	name := source
+	if u, err := url.Parse(source); err == nil && isURL(u) {
+		name = path.Base(u.Path)
+	} else {
+		name = filepath.Base(source)
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return ReputationFeed{Name: name, Category: hop.ReputationListed, Source: source}
+}
+
+// isURL reports whether u is an http(s) URL rather than a file path.
+func isURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ReputationOptions control how LoadReputation gets remote feeds.
+type ReputationOptions struct {
+	CacheDir string        // Where downloaded feeds are kept (default: data directory)
+	MaxAge   time.Duration // Age after which a feed is downloaded again (default: DefaultReputationMaxAge)
+	Offline  bool          // Never download: use downloaded copies, however old
+	Client   *http.Client  // HTTP client for downloads (default: 30s timeout)
+}
+
+// ReputationLookup checks addresses against reputation feeds. A lookup costs
+// one map access per prefix length in use in the address family.
+type ReputationLookup struct {
+	prefixes map[netip.Prefix][]hop.ReputationListing
+	lengths4 [33]bool  // IPv4 prefix lengths in use
+	lengths6 [129]bool // IPv6 prefix lengths in use
+}
+
+// LoadReputation loads feeds, downloading remote ones whose copy is missing
+// or older than opts.MaxAge. Feeds that cannot be loaded are left out and
+// their errors returned; a stale copy is used when a download fails.
+func LoadReputation(ctx context.Context, feeds []ReputationFeed, opts ReputationOptions) (*ReputationLookup, []error) {
+	if opts.CacheDir == "" {
+		if dir, err := DataDir(); err == nil {
+			opts.CacheDir = filepath.Join(dir, ReputationDir)
+		}
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultReputationMaxAge
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	r := &ReputationLookup{prefixes: make(map[netip.Prefix][]hop.ReputationListing)}
+	var errs []error
+	for _, feed := range feeds {
+		data, err := loadFeed(ctx, feed, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reputation feed %s: %w", feed.Name, err))
+			continue
+		}
+		r.add(feed, data)
+	}
+	return r, errs
+}
+
+// loadFeed returns the contents of feed.
+func loadFeed(ctx context.Context, feed ReputationFeed, opts ReputationOptions) ([]byte, error) {
+	u, err := url.Parse(feed.Source)
+	if err != nil || !isURL(u) {
+		return os.ReadFile(feed.Source)
+	}
+
+	if opts.CacheDir == "" {
+		return nil, fmt.Errorf("no data directory to keep %s", feed.Source)
+	}
+	cached := filepath.Join(opts.CacheDir, feed.Name+".txt")
+	info, statErr := os.Stat(cached)
+	if statErr == nil && (opts.Offline || time.Since(info.ModTime()) < opts.MaxAge) {
+		return os.ReadFile(cached)
+	}
+	if opts.Offline {
+		return nil, fmt.Errorf("not downloaded yet and offline")
+	}
+
+	data, err := downloadFeed(ctx, opts.Client, feed.Source)
+	if err != nil {
+		if statErr == nil {
+			return os.ReadFile(cached) // Stale, but better than nothing
+		}
+		return nil, err
+	}
+	if err := saveFeed(cached, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// downloadFeed fetches a feed over HTTP.
+func downloadFeed(ctx context.Context, client *http.Client, source string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// saveFeed atomically writes a downloaded feed to path.
+func saveFeed(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save feed: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save feed: %w", err)
+	}
+	return nil
+}
+
+// add indexes the prefixes listed in data under feed.
+func (r *ReputationLookup) add(feed ReputationFeed, data []byte) {
+	listing := hop.ReputationListing{List: feed.Name, Category: feed.Category}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		p, ok := parseFeedLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if !containsListing(r.prefixes[p], listing) {
+			r.prefixes[p] = append(r.prefixes[p], listing)
+		}
+		if p.Addr().Is4() {
+			r.lengths4[p.Bits()] = true
+		} else {
+			r.lengths6[p.Bits()] = true
+		}
+	}
+}
+
+// parseFeedLine returns the prefix on a feed line, if any.
+func parseFeedLine(line string) (netip.Prefix, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		var record struct {
+			CIDR string `json:"cidr"`
+		}
+		if json.Unmarshal([]byte(line), &record) != nil {
+			return netip.Prefix{}, false
+		}
+		line = record.CIDR
+	}
+	if i := strings.IndexAny(line, "#;"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return netip.Prefix{}, false
+	}
+	if p, err := netip.ParsePrefix(fields[0]); err == nil && !p.Addr().Is4In6() {
+		return p.Masked(), true
+	}
+	if a, err := netip.ParseAddr(fields[0]); err == nil {
+		a = a.Unmap()
+		return netip.PrefixFrom(a, a.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+func containsListing(listings []hop.ReputationListing, l hop.ReputationListing) bool {
+	for _, x := range listings {
+		if x == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the listings of every feed prefix covering ip, most
+// specific first.
+func (r *ReputationLookup) Lookup(ip net.IP) []hop.ReputationListing {
+	addr, ok := netip.AddrFromSlice(ip)
+	if r == nil || !ok {
+		return nil
+	}
+	addr = addr.Unmap()
+	lengths := r.lengths6[:]
+	if addr.Is4() {
+		lengths = r.lengths4[:]
+	}
+
+	var listings []hop.ReputationListing
+	for bits := len(lengths) - 1; bits >= 0; bits-- {
+		if !lengths[bits] {
+			continue
+		}
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		for _, l := range r.prefixes[p] {
+			if !containsListing(listings, l) {
+				listings = append(listings, l)
+			}
+		}
+	}
+	return listings
+}
+
+// Len returns the number of prefixes loaded.
+func (r *ReputationLookup) Len() int {
+	return len(r.prefixes)
+}
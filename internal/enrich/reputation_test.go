@@ -0,0 +1,222 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestParseFeedLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"1.10.16.0/20 ; SBL256894", "1.10.16.0/20"},
+		{`{"cidr":"2.56.192.0/22","sblid":"SBL459831","rir":"ripencc"}`, "2.56.192.0/22"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"192.0.2.77", "192.0.2.77/32"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"  203.0.113.0/24  # test net", "203.0.113.0/24"},
+		{"# fullbogons", ""},
+		{`{"type":"metadata","timestamp":1700000000}`, ""},
+		{"not an address", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		p, ok := parseFeedLine(tt.line)
+		got := ""
+		if ok {
+			got = p.String()
+		}
+		if got != tt.want {
+			t.Errorf("parseFeedLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func writeFeed(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "feed.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReputationLookup_Lookup(t *testing.T) {
+	bogons := ReputationFeed{Name: "bogons", Category: hop.ReputationBogon, Source: writeFeed(t, "0.0.0.0/8\n192.0.2.0/24\n2001:db8::/32\n::/8\n")}
+	drop := ReputationFeed{Name: "drop", Category: hop.ReputationHijack, Source: writeFeed(t, "192.0.2.128/25\n")}
+
+	r, errs := LoadReputation(context.Background(), []ReputationFeed{bogons, drop}, ReputationOptions{})
+	if len(errs) > 0 {
+		t.Fatalf("LoadReputation errors: %v", errs)
+	}
+	if r.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", r.Len())
+	}
+
+	got := r.Lookup(net.ParseIP("192.0.2.200"))
+	want := []hop.ReputationListing{{List: "drop", Category: hop.ReputationHijack}, {List: "bogons", Category: hop.ReputationBogon}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Lookup(192.0.2.200) = %v, want %v (most specific first)", got, want)
+	}
+	if got := r.Lookup(net.ParseIP("2001:db8::1")); len(got) != 1 || got[0].List != "bogons" {
+		t.Errorf("Lookup(2001:db8::1) = %v, want bogons", got)
+	}
+	// ::/8 covers the IPv4-mapped range, which must not list IPv4 addresses
+	if got := r.Lookup(net.ParseIP("8.8.8.8")); got != nil {
+		t.Errorf("Lookup(8.8.8.8) = %v, want nil", got)
+	}
+
+	var nilLookup *ReputationLookup
+	if got := nilLookup.Lookup(net.ParseIP("192.0.2.1")); got != nil {
+		t.Errorf("nil Lookup = %v, want nil", got)
+	}
+}
+
+func TestLoadReputation_MissingFileIsReported(t *testing.T) {
+	feed := CustomReputationFeed(filepath.Join(t.TempDir(), "missing.txt"))
+	r, errs := LoadReputation(context.Background(), []ReputationFeed{feed}, ReputationOptions{})
+	if len(errs) != 1 {
+		t.Fatalf("errs = %v, want one error", errs)
+	}
+	if r.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", r.Len())
+	}
+}
+
+func TestCustomReputationFeed(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"/etc/gtrace/corp-deny.txt", "corp-deny"},
+		{"https://example.com/lists/firehol_level1.netset", "firehol_level1"},
+	}
+	for _, tt := range tests {
+		feed := CustomReputationFeed(tt.source)
+		if feed.Name != tt.want || feed.Category != hop.ReputationListed || feed.Source != tt.source {
+			t.Errorf("CustomReputationFeed(%q) = %+v, want name %q", tt.source, feed, tt.want)
+		}
+	}
+}
+
+func TestLoadReputation_CachesDownloads(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("198.51.100.0/24\n"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	feed := ReputationFeed{Name: "remote", Category: hop.ReputationHijack, Source: srv.URL + "/drop.txt"}
+	opts := ReputationOptions{CacheDir: dir}
+
+	for i := 0; i < 2; i++ {
+		r, errs := LoadReputation(context.Background(), []ReputationFeed{feed}, opts)
+		if len(errs) > 0 {
+			t.Fatalf("LoadReputation errors: %v", errs)
+		}
+		if got := r.Lookup(net.ParseIP("198.51.100.9")); len(got) != 1 {
+			t.Errorf("Lookup = %v, want one listing", got)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("downloads = %d, want 1 (second load from cache)", n)
+	}
+
+	// An expired copy is downloaded again
+	old := time.Now().Add(-2 * DefaultReputationMaxAge)
+	if err := os.Chtimes(filepath.Join(dir, "remote.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	LoadReputation(context.Background(), []ReputationFeed{feed}, opts)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("downloads = %d, want 2 after expiry", n)
+	}
+}
+
+func TestLoadReputation_StaleCopyOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	cached := filepath.Join(dir, "remote.txt")
+	if err := os.WriteFile(cached, []byte("198.51.100.0/24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * DefaultReputationMaxAge)
+	if err := os.Chtimes(cached, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	feed := ReputationFeed{Name: "remote", Category: hop.ReputationHijack, Source: srv.URL + "/drop.txt"}
+	r, errs := LoadReputation(context.Background(), []ReputationFeed{feed}, ReputationOptions{CacheDir: dir})
+	if len(errs) > 0 {
+		t.Fatalf("LoadReputation errors: %v", errs)
+	}
+	if got := r.Lookup(net.ParseIP("198.51.100.9")); len(got) != 1 {
+		t.Errorf("Lookup = %v, want the stale copy's listing", got)
+	}
+}
+
+func TestLoadReputation_OfflineNeverDownloads(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	feed := ReputationFeed{Name: "remote", Category: hop.ReputationHijack, Source: srv.URL + "/drop.txt"}
+	_, errs := LoadReputation(context.Background(), []ReputationFeed{feed}, ReputationOptions{CacheDir: t.TempDir(), Offline: true})
+	if len(errs) != 1 {
+		t.Errorf("errs = %v, want one error for the missing copy", errs)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("downloads = %d, want 0 offline", n)
+	}
+}
+
+func TestEnricher_ReputationIsNotPersisted(t *testing.T) {
+	feed := ReputationFeed{Name: "drop", Category: hop.ReputationHijack, Source: writeFeed(t, "8.8.8.0/24\n203.0.113.0/24\n10.0.0.0/8\n")}
+	rep, errs := LoadReputation(context.Background(), []ReputationFeed{feed}, ReputationOptions{})
+	if len(errs) > 0 {
+		t.Fatalf("LoadReputation errors: %v", errs)
+	}
+
+	dc := OpenDiskCache(t.TempDir(), time.Hour)
+	dc.Set("8.8.8.8", &hop.Enrichment{ASN: 15169, ASOrg: "GOOGLE"})
+	e := NewEnricher()
+	e.SetDiskCache(dc)
+	e.SetReputation(rep)
+
+	// A cancelled context makes any network lookup fail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, ip := range []string{"8.8.8.8", "203.0.113.1"} {
+		got, _ := e.EnrichIP(ctx, net.ParseIP(ip))
+		if len(got.Reputation) != 1 || got.Reputation[0].List != "drop" {
+			t.Errorf("EnrichIP(%s).Reputation = %v, want drop", ip, got.Reputation)
+		}
+	}
+	if cached, _ := dc.Get("8.8.8.8"); cached.Reputation != nil {
+		t.Errorf("disk cache entry has reputation %v, want none", cached.Reputation)
+	}
+
+	// Private addresses are not checked
+	if got, _ := e.EnrichIP(ctx, net.ParseIP("10.1.1.1")); got.Reputation != nil {
+		t.Errorf("EnrichIP(10.1.1.1).Reputation = %v, want nil", got.Reputation)
+	}
+}
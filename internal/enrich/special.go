@@ -0,0 +1,84 @@
+package enrich
+
+import (
+	"net"
+	"net/netip"
+)
+
+// SpecialRange is an address block from the IANA IPv4 and IPv6
+// Special-Purpose Address Registries, or a block reserved alongside them.
+type SpecialRange struct {
+	Prefix netip.Prefix
+	Name   string // Short label, e.g. "Documentation"
+	RFC    string // Defining document, e.g. "RFC 5737"
+}
+
+// specialRanges lists the special-purpose blocks hops are annotated with.
+// Private-use, shared (CGNAT), unique local and loopback space is left out:
+// it is expected on most paths and IsPrivateIP already covers it.
+var specialRanges = []SpecialRange{
+	// IPv4 (iana-ipv4-special-registry)
+	{netip.MustParsePrefix("0.0.0.0/8"), "This network", "RFC 791"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "Link-local", "RFC 3927"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "IETF protocol assignments", "RFC 6890"},
+	{netip.MustParsePrefix("192.0.0.0/29"), "DS-Lite", "RFC 7335"},
+	{netip.MustParsePrefix("192.0.0.8/32"), "Dummy address", "RFC 7600"},
+	{netip.MustParsePrefix("192.0.0.9/32"), "PCP anycast", "RFC 7723"},
+	{netip.MustParsePrefix("192.0.0.10/32"), "TURN anycast", "RFC 8155"},
+	{netip.MustParsePrefix("192.0.0.170/31"), "NAT64 discovery", "RFC 7050"},
+	{netip.MustParsePrefix("192.0.2.0/24"), "Documentation", "RFC 5737"},
+	{netip.MustParsePrefix("192.31.196.0/24"), "AS112", "RFC 7535"},
+	{netip.MustParsePrefix("192.52.193.0/24"), "AMT", "RFC 7450"},
+	{netip.MustParsePrefix("192.88.99.0/24"), "6to4 relay anycast", "RFC 7526"},
+	{netip.MustParsePrefix("192.175.48.0/24"), "AS112", "RFC 7534"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "Benchmarking", "RFC 2544"},
+	{netip.MustParsePrefix("198.51.100.0/24"), "Documentation", "RFC 5737"},
+	{netip.MustParsePrefix("203.0.113.0/24"), "Documentation", "RFC 5737"},
+	{netip.MustParsePrefix("224.0.0.0/4"), "Multicast", "RFC 5771"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "Reserved", "RFC 1112"},
+	{netip.MustParsePrefix("255.255.255.255/32"), "Limited broadcast", "RFC 919"},
+
+	// IPv6 (iana-ipv6-special-registry)
+	{netip.MustParsePrefix("::/128"), "Unspecified", "RFC 4291"},
+	{netip.MustParsePrefix("::ffff:0:0/96"), "IPv4-mapped", "RFC 4291"},
+	{netip.MustParsePrefix("64:ff9b::/96"), "NAT64", "RFC 6052"},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), "Local NAT64", "RFC 8215"},
+	{netip.MustParsePrefix("100::/64"), "Discard-only", "RFC 6666"},
+	{netip.MustParsePrefix("2001::/23"), "IETF protocol assignments", "RFC 2928"},
+	{netip.MustParsePrefix("2001::/32"), "Teredo", "RFC 4380"},
+	{netip.MustParsePrefix("2001:1::1/128"), "PCP anycast", "RFC 7723"},
+	{netip.MustParsePrefix("2001:1::2/128"), "TURN anycast", "RFC 8155"},
+	{netip.MustParsePrefix("2001:1::3/128"), "DNS-SD SRP anycast", "RFC 9665"},
+	{netip.MustParsePrefix("2001:2::/48"), "Benchmarking", "RFC 5180"},
+	{netip.MustParsePrefix("2001:3::/32"), "AMT", "RFC 7450"},
+	{netip.MustParsePrefix("2001:4:112::/48"), "AS112", "RFC 7535"},
+	{netip.MustParsePrefix("2001:20::/28"), "ORCHIDv2", "RFC 7343"},
+	{netip.MustParsePrefix("2001:30::/28"), "DRIP", "RFC 9374"},
+	{netip.MustParsePrefix("2001:db8::/32"), "Documentation", "RFC 3849"},
+	{netip.MustParsePrefix("2002::/16"), "6to4", "RFC 3056"},
+	{netip.MustParsePrefix("2620:4f:8000::/48"), "AS112", "RFC 7534"},
+	{netip.MustParsePrefix("3fff::/20"), "Documentation", "RFC 9637"},
+	{netip.MustParsePrefix("5f00::/16"), "Segment routing SIDs", "RFC 9602"},
+	{netip.MustParsePrefix("fe80::/10"), "Link-local", "RFC 4291"},
+	{netip.MustParsePrefix("ff00::/8"), "Multicast", "RFC 4291"},
+}
+
+// SpecialPurpose returns the most specific special-purpose range covering
+// ip. It needs no network or database access.
+func SpecialPurpose(ip net.IP) (SpecialRange, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return SpecialRange{}, false
+	}
+	// IPv4 addresses are matched against the IPv4 registry, not ::ffff:0:0/96
+	addr = addr.Unmap()
+
+	var best SpecialRange
+	found := false
+	for _, r := range specialRanges {
+		if r.Prefix.Contains(addr) && (!found || r.Prefix.Bits() > best.Prefix.Bits()) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,56 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestSpecialPurpose(t *testing.T) {
+	tests := []struct {
+		ip       string
+		wantName string
+		wantRFC  string
+	}{
+		{"192.0.2.1", "Documentation", "RFC 5737"},
+		{"2001:db8::1", "Documentation", "RFC 3849"},
+		{"169.254.10.1", "Link-local", "RFC 3927"},
+		{"fe80::1", "Link-local", "RFC 4291"},
+		{"2002:c000:201::1", "6to4", "RFC 3056"},
+		{"2001:0:4136:e378::1", "Teredo", "RFC 4380"},
+		{"198.19.255.1", "Benchmarking", "RFC 2544"},
+		{"2001:2::1", "Benchmarking", "RFC 5180"},
+		{"192.0.0.9", "PCP anycast", "RFC 7723"}, // Most specific wins over 192.0.0.0/24
+		{"192.0.0.100", "IETF protocol assignments", "RFC 6890"},
+		{"240.1.2.3", "Reserved", "RFC 1112"},
+		{"::ffff:192.0.2.1", "Documentation", "RFC 5737"},
+		{"8.8.8.8", "", ""},
+		{"10.0.0.1", "", ""}, // Private-use is not annotated
+		{"2606:4700::1111", "", ""},
+	}
+	for _, tt := range tests {
+		r, ok := SpecialPurpose(net.ParseIP(tt.ip))
+		if ok != (tt.wantName != "") || r.Name != tt.wantName || r.RFC != tt.wantRFC {
+			t.Errorf("SpecialPurpose(%s) = %+v, %v; want %q (%s)", tt.ip, r, ok, tt.wantName, tt.wantRFC)
+		}
+	}
+}
+
+func TestSpecialPurpose_NilIP(t *testing.T) {
+	if r, ok := SpecialPurpose(nil); ok {
+		t.Errorf("SpecialPurpose(nil) = %+v, want no match", r)
+	}
+}
+
+func TestEnricher_AnnotatesSpecialPurposeOffline(t *testing.T) {
+	e := NewEnricher()
+
+	// A cancelled context makes any network lookup fail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got, _ := e.EnrichIP(ctx, net.ParseIP("198.51.100.7"))
+	if got.Special != "Documentation" {
+		t.Errorf("EnrichIP(198.51.100.7).Special = %q, want Documentation", got.Special)
+	}
+}
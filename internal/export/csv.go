@@ -42,6 +42,40 @@ func (e *CSVExporter) Export(w io.Writer, tr *hop.TraceResult) error {
 	return nil
 }
 
+// ExportAll writes several trace results as one CSV table.
+// Rows are prefixed with target and source columns so traces can be told apart.
+func (e *CSVExporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	header := []string{
+		"target", "source", "ttl", "ip", "hostname", "asn", "as_org",
+		"country", "city", "avg_rtt_ms", "loss_percent",
+		"start_time", "start_skew_ms",
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	earliest := hop.EarliestStart(results)
+	for _, tr := range results {
+		startTime := ""
+		if !tr.StartTime.IsZero() {
+			startTime = tr.StartTime.UTC().Format(time.RFC3339Nano)
+		}
+		skew := fmt.Sprintf("%.0f", float64(tr.StartSkew(earliest))/float64(time.Millisecond))
+		for _, h := range tr.Hops {
+			row := append([]string{tr.Target, tr.Source}, e.hopToRow(h)...)
+			row = append(row, startTime, skew)
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write row: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // hopToRow converts a hop to a CSV row.
 func (e *CSVExporter) hopToRow(h *hop.Hop) []string {
 	ip := ""
@@ -15,6 +15,12 @@ type Exporter interface {
 	Export(w io.Writer, tr *hop.TraceResult) error
 }
 
+// MultiExporter is implemented by exporters that can write several trace
+// results (e.g. one per target) into a single document.
+type MultiExporter interface {
+	ExportAll(w io.Writer, results []*hop.TraceResult) error
+}
+
 // Format represents an export format.
 type Format string
 
@@ -22,6 +28,11 @@ const (
 	FormatJSON Format = "json"
 	FormatCSV  Format = "csv"
 	FormatText Format = "text"
+	FormatDOT  Format = "dot"
+	FormatD2   Format = "d2"
+
+	FormatScamper Format = "scamper"
+	FormatOTLP    Format = "otlp"
 )
 
 // DetectFormat determines the export format from a filename.
@@ -34,6 +45,10 @@ func DetectFormat(filename string) Format {
 		return FormatCSV
 	case ".txt", ".text":
 		return FormatText
+	case ".dot", ".gv":
+		return FormatDOT
+	case ".d2":
+		return FormatD2
 	default:
 		return FormatJSON // Default to JSON
 	}
@@ -48,6 +63,14 @@ func NewExporter(format Format) (Exporter, error) {
 		return NewCSVExporter(), nil
 	case FormatText, "txt":
 		return NewTextExporter(), nil
+	case FormatDOT:
+		return NewDOTExporter(), nil
+	case FormatD2:
+		return NewD2Exporter(), nil
+	case FormatScamper:
+		return NewScamperExporter(), nil
+	case FormatOTLP:
+		return NewOTLPExporter(), nil
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", format)
 	}
@@ -76,3 +99,31 @@ func ExportToFile(filename string, format Format, tr *hop.TraceResult) error {
 
 	return nil
 }
+
+// ExportAllToFile exports several trace results to a single file.
+func ExportAllToFile(filename string, format Format, results []*hop.TraceResult) error {
+	if format == "" {
+		format = DetectFormat(filename)
+	}
+
+	exporter, err := NewExporter(format)
+	if err != nil {
+		return err
+	}
+	multi, ok := exporter.(MultiExporter)
+	if !ok {
+		return fmt.Errorf("format %s does not support multiple traces", format)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	if err := multi.ExportAll(f, results); err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+
+	return nil
+}
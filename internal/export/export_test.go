@@ -1,7 +1,14 @@
 package export
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
 func TestNewExporter_TxtAlias(t *testing.T) {
@@ -37,3 +44,158 @@ func TestDetectFormat_TxtExtension(t *testing.T) {
 		t.Errorf("expected FormatText for .txt extension, got %q", f)
 	}
 }
+
+func TestExportAll_JSONWritesArray(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.Target = "cloudflare.com"
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().ExportAll(&buf, []*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []ExportedTrace
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON array: %v", err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 traces, got %d", len(decoded))
+	}
+	if decoded[1].Target != "cloudflare.com" {
+		t.Errorf("expected second target cloudflare.com, got %q", decoded[1].Target)
+	}
+}
+
+func TestExportAll_CSVPrefixesTarget(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.Target = "cloudflare.com"
+
+	var buf bytes.Buffer
+	if err := NewCSVExporter().ExportAll(&buf, []*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if records[0][0] != "target" || records[0][1] != "source" {
+		t.Errorf("expected target/source leading columns, got %v", records[0][:2])
+	}
+	// Header + 2 hops per trace
+	if len(records) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(records))
+	}
+	if records[4][0] != "cloudflare.com" {
+		t.Errorf("expected last row for cloudflare.com, got %q", records[4][0])
+	}
+}
+
+func TestExportAll_TextContainsEveryTarget(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.Target = "cloudflare.com"
+
+	var buf bytes.Buffer
+	if err := NewTextExporter().ExportAll(&buf, []*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "Traceroute to google.com") || !strings.Contains(out, "Traceroute to cloudflare.com") {
+		t.Error("expected a report for each target")
+	}
+}
+
+func TestExportAll_JSONIncludesStartSkew(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.Source = "Paris"
+	b.StartTime = a.StartTime.Add(2500 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().ExportAll(&buf, []*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []ExportedTrace
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON array: %v", err)
+	}
+	if decoded[0].StartSkewMs != 0 || decoded[1].StartSkewMs != 2500 {
+		t.Errorf("expected skews 0 and 2500ms, got %v and %v", decoded[0].StartSkewMs, decoded[1].StartSkewMs)
+	}
+}
+
+func TestExportAll_TextNotesStartSkew(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.StartTime = a.StartTime.Add(3 * time.Minute)
+
+	var buf bytes.Buffer
+	if err := NewTextExporter().ExportAll(&buf, []*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Start time skew across traces: 3m0s") {
+		t.Errorf("expected skew note, got:\n%s", buf.String())
+	}
+}
+
+func TestTextExporter_NotesTimestampSource(t *testing.T) {
+	tr := createTestTrace()
+	tr.TimestampSource = hop.TimestampUserspace
+
+	var buf bytes.Buffer
+	if err := NewTextExporter().Export(&buf, tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Timestamps: userspace\n") {
+		t.Errorf("expected timestamp source in header, got:\n%s", buf.String())
+	}
+}
+
+func TestTextExporter_NotesRoute(t *testing.T) {
+	tr := createTestTrace()
+	tr.Route = &hop.RouteInfo{Destination: "default", Gateway: "192.168.1.1", Interface: "eth0"}
+
+	var buf bytes.Buffer
+	if err := NewTextExporter().Export(&buf, tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Route: default via 192.168.1.1 dev eth0\n") {
+		t.Errorf("expected route in header, got:\n%s", buf.String())
+	}
+}
+
+func TestTextExporter_ReportsTargetFingerprint(t *testing.T) {
+	tr := createTestTrace()
+	last := tr.Hops[len(tr.Hops)-1]
+	last.Probes[0].Fingerprint = &hop.TCPFingerprint{TTL: 50, Window: 65535, MSS: 1400, WindowScale: -1, Options: "M,N,W"}
+
+	var buf bytes.Buffer
+	if err := NewTextExporter().Export(&buf, tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"Target fingerprint: TTL 50 (initial 64, 14 hops), window 65535, MSS 1400, options M,N,W\n",
+		"  Likely OS: macOS or BSD\n",
+		"  MSS 1400 is below a 1500 byte MTU",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestTextExporter_SummarizesLatencyByAS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewTextExporter().Export(&buf, createTestTrace()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Latency by AS:\n  AS12345 Test ISP: 1 hop, 0.0ms\n") {
+		t.Errorf("expected latency by AS summary, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,461 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// topoNode is a node of the traced topology: a responding address, the
+// trace source, or a TTL where nothing answered.
+type topoNode struct {
+	id     string
+	lines  []string // Label lines
+	source bool
+	silent bool
+	target bool
+}
+
+// topoEdge links two consecutive nodes of a path.
+type topoEdge struct {
+	from, to string
+	deltaSum time.Duration // Sum of RTT deltas (to - from) over the traces using the edge
+	deltas   int           // Number of deltas summed (0 = unknown, e.g. next to a silent hop)
+	flows    []int         // ECMP flow IDs seen on the edge
+}
+
+// delta returns the average RTT delta along the edge.
+func (e *topoEdge) delta() (time.Duration, bool) {
+	if e.deltas == 0 {
+		return 0, false
+	}
+	return e.deltaSum / time.Duration(e.deltas), true
+}
+
+// topology is the graph of one or more traces. Addresses seen by several
+// traces are a single node, so shared path segments merge.
+type topology struct {
+	nodes []*topoNode
+	byID  map[string]*topoNode
+	edges []*topoEdge
+	byKey map[[2]string]*topoEdge
+	out   map[string]int // Out-degree, to spot ECMP branches
+
+	traceEdges map[[2]string]bool // Edges already used by the trace being added
+}
+
+// buildTopology builds the graph of the traces. With ECMP flow IDs
+// (--ecmp-flows), each flow links the addresses it reached; without them
+// every address of a TTL is linked to every address of the next one.
+func buildTopology(results []*hop.TraceResult) *topology {
+	t := &topology{
+		byID:  make(map[string]*topoNode),
+		byKey: make(map[[2]string]*topoEdge),
+		out:   make(map[string]int),
+	}
+	for i, tr := range results {
+		t.addTrace(i, len(results), tr)
+	}
+	return t
+}
+
+func (t *topology) addTrace(index, count int, tr *hop.TraceResult) {
+	srcID := "source"
+	if count > 1 {
+		srcID = fmt.Sprintf("source%d", index+1)
+	}
+	srcLabel := tr.Source
+	if srcLabel == "" {
+		srcLabel = "local"
+	}
+	t.node(srcID, []string{srcLabel}).source = true
+	t.traceEdges = make(map[[2]string]bool)
+
+	hops := make([]*hop.Hop, len(tr.Hops))
+	copy(hops, tr.Hops)
+	sort.SliceStable(hops, func(i, j int) bool { return hops[i].TTL < hops[j].TTL })
+
+	perFlow := false
+	for _, h := range hops {
+		for _, p := range h.Probes {
+			if p.FlowID > 0 {
+				perFlow = true
+			}
+		}
+	}
+
+	// rtts holds the average RTT of each node in this trace (source = 0);
+	// silent nodes have none.
+	rtts := map[string]time.Duration{srcID: 0}
+	prevLayer := []string{srcID}
+	lastByFlow := make(map[int]string) // Last node each flow reached
+
+	for _, h := range hops {
+		addrs := hopAddresses(h)
+		if len(addrs) == 0 {
+			id := fmt.Sprintf("%s_ttl%d", srcID, h.TTL)
+			t.node(id, []string{"*", fmt.Sprintf("TTL %d", h.TTL)}).silent = true
+			froms := prevLayer
+			if len(lastByFlow) > 0 {
+				froms = distinct(flowValues(lastByFlow))
+				for f := range lastByFlow {
+					lastByFlow[f] = id
+				}
+			}
+			for _, from := range froms {
+				t.link(from, id, rtts, 0)
+			}
+			prevLayer = []string{id}
+			continue
+		}
+
+		var layer []string
+		for _, a := range addrs {
+			n := t.node(a.ip, []string{a.ip})
+			if a.ip == tr.TargetIP {
+				n.target = true
+			}
+			if h.PrimaryIP() != nil && h.PrimaryIP().String() == a.ip {
+				n.lines = nodeLabel(a.ip, h.Enrichment)
+			}
+			rtts[a.ip] = a.rtt
+			layer = append(layer, a.ip)
+
+			if !perFlow || len(a.flows) == 0 {
+				for _, from := range prevLayer {
+					t.link(from, a.ip, rtts, 0)
+				}
+				continue
+			}
+			for _, f := range a.flows {
+				if from, ok := lastByFlow[f]; ok {
+					t.link(from, a.ip, rtts, f)
+					continue
+				}
+				// First answer of this flow: it came through the previous TTL
+				for _, from := range prevLayer {
+					t.link(from, a.ip, rtts, f)
+				}
+			}
+		}
+		if perFlow {
+			for _, a := range addrs {
+				for _, f := range a.flows {
+					lastByFlow[f] = a.ip
+				}
+			}
+		}
+		prevLayer = layer
+	}
+}
+
+// node returns the node with the given id, creating it with the label lines.
+func (t *topology) node(id string, lines []string) *topoNode {
+	if n, ok := t.byID[id]; ok {
+		return n
+	}
+	n := &topoNode{id: id, lines: lines}
+	t.byID[id] = n
+	t.nodes = append(t.nodes, n)
+	return n
+}
+
+// link records an edge, with the RTT delta when both ends have an RTT in
+// this trace and the flow ID (0 = unknown) that took it.
+func (t *topology) link(from, to string, rtts map[string]time.Duration, flow int) {
+	if from == to {
+		return
+	}
+	key := [2]string{from, to}
+	e, ok := t.byKey[key]
+	if !ok {
+		e = &topoEdge{from: from, to: to}
+		t.byKey[key] = e
+		t.edges = append(t.edges, e)
+		t.out[from]++
+	}
+	if !t.traceEdges[key] {
+		t.traceEdges[key] = true
+		fromRTT, fromOK := rtts[from]
+		toRTT, toOK := rtts[to]
+		if fromOK && toOK {
+			e.deltaSum += toRTT - fromRTT
+			e.deltas++
+		}
+	}
+	if flow > 0 {
+		for _, f := range e.flows {
+			if f == flow {
+				return
+			}
+		}
+		e.flows = append(e.flows, flow)
+	}
+}
+
+// maxDelta returns the largest positive RTT delta, used to scale edge widths.
+func (t *topology) maxDelta() time.Duration {
+	var maxD time.Duration
+	for _, e := range t.edges {
+		if d, ok := e.delta(); ok && d > maxD {
+			maxD = d
+		}
+	}
+	return maxD
+}
+
+// edgeLabel returns the RTT delta and, on ECMP branches, the flows.
+func (t *topology) edgeLabel(e *topoEdge) string {
+	var parts []string
+	if d, ok := e.delta(); ok {
+		parts = append(parts, fmt.Sprintf("%+.1f ms", float64(d)/float64(time.Millisecond)))
+	}
+	if t.out[e.from] > 1 && len(e.flows) > 0 {
+		parts = append(parts, "flows "+flowList(e.flows))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// edgeWidth scales the line width from 1 to 5 with the RTT delta.
+func edgeWidth(e *topoEdge, maxDelta time.Duration) float64 {
+	d, ok := e.delta()
+	if !ok || d <= 0 || maxDelta <= 0 {
+		return 1
+	}
+	return 1 + 4*float64(d)/float64(maxDelta)
+}
+
+// hopAddress is an address that answered at a hop.
+type hopAddress struct {
+	ip    string
+	rtt   time.Duration // Average RTT of its probes
+	flows []int
+}
+
+// hopAddresses groups the answered probes of a hop by address, in order
+// first seen.
+func hopAddresses(h *hop.Hop) []hopAddress {
+	var addrs []hopAddress
+	index := make(map[string]int)
+	counts := make(map[string]int)
+	for _, p := range h.Probes {
+		if p.Timeout || p.IP == nil {
+			continue
+		}
+		ip := p.IP.String()
+		i, ok := index[ip]
+		if !ok {
+			i = len(addrs)
+			index[ip] = i
+			addrs = append(addrs, hopAddress{ip: ip})
+		}
+		addrs[i].rtt += p.RTT
+		counts[ip]++
+		if p.FlowID > 0 {
+			addrs[i].flows = append(addrs[i].flows, p.FlowID)
+		}
+	}
+	for i := range addrs {
+		addrs[i].rtt /= time.Duration(counts[addrs[i].ip])
+	}
+	return addrs
+}
+
+// nodeLabel returns the label lines of an address: IP, hostname, ASN and
+// geolocation when known.
+func nodeLabel(ip string, e hop.Enrichment) []string {
+	lines := []string{ip}
+	if e.Hostname != "" {
+		lines = append(lines, e.Hostname)
+	}
+	if e.ASN > 0 {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf("AS%d %s", e.ASN, e.ASOrg)))
+	}
+	var geo []string
+	if e.City != "" {
+		geo = append(geo, e.City)
+	}
+	if e.Country != "" {
+		geo = append(geo, e.Country)
+	}
+	if len(geo) > 0 {
+		lines = append(lines, strings.Join(geo, ", "))
+	}
+	return lines
+}
+
+// flowList formats flow IDs sorted: "1,3,5".
+func flowList(flows []int) string {
+	sorted := append([]int(nil), flows...)
+	sort.Ints(sorted)
+	parts := make([]string, len(sorted))
+	for i, f := range sorted {
+		parts[i] = strconv.Itoa(f)
+	}
+	return strings.Join(parts, ",")
+}
+
+// flowValues returns the nodes in m, in flow order.
+func flowValues(m map[int]string) []string {
+	flows := make([]int, 0, len(m))
+	for f := range m {
+		flows = append(flows, f)
+	}
+	sort.Ints(flows)
+	values := make([]string, len(flows))
+	for i, f := range flows {
+		values[i] = m[f]
+	}
+	return values
+}
+
+// distinct removes duplicates from list, keeping the first occurrence.
+func distinct(list []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// graphTitle describes the traces drawn in a graph.
+func graphTitle(results []*hop.TraceResult) string {
+	var targets []string
+	for _, tr := range results {
+		targets = append(targets, fmt.Sprintf("%s (%s)", tr.Target, tr.TargetIP))
+	}
+	return "Traceroute to " + strings.Join(distinct(targets), ", ")
+}
+
+// DOTExporter exports the traced topology as a Graphviz DOT graph.
+type DOTExporter struct{}
+
+// NewDOTExporter creates a new DOT exporter.
+func NewDOTExporter() *DOTExporter {
+	return &DOTExporter{}
+}
+
+// Export writes the trace topology as DOT to the writer.
+func (e *DOTExporter) Export(w io.Writer, tr *hop.TraceResult) error {
+	return e.ExportAll(w, []*hop.TraceResult{tr})
+}
+
+// ExportAll writes several traces as one DOT graph; shared hops merge.
+func (e *DOTExporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	t := buildTopology(results)
+	maxDelta := t.maxDelta()
+
+	var b strings.Builder
+	b.WriteString("digraph gtrace {\n")
+	fmt.Fprintf(&b, "  label=%s;\n  labelloc=t;\n  rankdir=TB;\n", dotQuote(graphTitle(results)))
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n  edge [fontname=\"Helvetica\", fontsize=10];\n\n")
+
+	for _, n := range t.nodes {
+		attrs := []string{"label=" + dotQuote(strings.Join(n.lines, "\n"))}
+		switch {
+		case n.source:
+			attrs = append(attrs, "shape=ellipse")
+		case n.silent:
+			attrs = append(attrs, "style=dashed")
+		}
+		if n.target {
+			attrs = append(attrs, "peripheries=2")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.id), strings.Join(attrs, ", "))
+	}
+	b.WriteString("\n")
+	for _, edge := range t.edges {
+		attrs := []string{fmt.Sprintf("penwidth=%.1f", edgeWidth(edge, maxDelta))}
+		if label := t.edgeLabel(edge); label != "" {
+			attrs = append([]string{"label=" + dotQuote(label)}, attrs...)
+		}
+		if t.out[edge.from] > 1 {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(edge.from), dotQuote(edge.to), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote quotes s as a DOT string; newlines become DOT line breaks.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// D2Exporter exports the traced topology as a D2 diagram.
+type D2Exporter struct{}
+
+// NewD2Exporter creates a new D2 exporter.
+func NewD2Exporter() *D2Exporter {
+	return &D2Exporter{}
+}
+
+// Export writes the trace topology as D2 to the writer.
+func (e *D2Exporter) Export(w io.Writer, tr *hop.TraceResult) error {
+	return e.ExportAll(w, []*hop.TraceResult{tr})
+}
+
+// ExportAll writes several traces as one D2 diagram; shared hops merge.
+func (e *D2Exporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	t := buildTopology(results)
+	maxDelta := t.maxDelta()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\ndirection: down\n\n", graphTitle(results))
+
+	for _, n := range t.nodes {
+		fmt.Fprintf(&b, "%s: %s", d2Quote(n.id), d2Quote(strings.Join(n.lines, "\n")))
+		var attrs []string
+		switch {
+		case n.source:
+			attrs = append(attrs, "shape: oval")
+		case n.silent:
+			attrs = append(attrs, "style.stroke-dash: 3")
+		}
+		if n.target {
+			attrs = append(attrs, "style.double-border: true")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " {%s}", strings.Join(attrs, "; "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	for _, edge := range t.edges {
+		fmt.Fprintf(&b, "%s -> %s", d2Quote(edge.from), d2Quote(edge.to))
+		if label := t.edgeLabel(edge); label != "" {
+			fmt.Fprintf(&b, ": %s", d2Quote(label))
+		}
+		attrs := []string{fmt.Sprintf("style.stroke-width: %d", int(edgeWidth(edge, maxDelta)+0.5))}
+		if t.out[edge.from] > 1 {
+			attrs = append(attrs, "style.stroke-dash: 3")
+		}
+		fmt.Fprintf(&b, " {%s}\n", strings.Join(attrs, "; "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// d2Quote quotes s as a D2 double-quoted string.
+func d2Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
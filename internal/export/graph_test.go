@@ -0,0 +1,146 @@
+package export
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// createECMPTrace returns a trace with 2 flows splitting at TTL 2, a silent
+// TTL 3 and merging again at the target.
+func createECMPTrace() *hop.TraceResult {
+	tr := hop.NewTraceResult("example.com", "10.3.0.1")
+	probe := func(ip string, ms int, flow int) hop.Probe {
+		return hop.Probe{IP: net.ParseIP(ip), RTT: time.Duration(ms) * time.Millisecond, FlowID: flow}
+	}
+
+	h1 := hop.NewHop(1)
+	h1.Probes = []hop.Probe{probe("10.0.0.1", 1, 1), probe("10.0.0.1", 1, 2)}
+	h1.SetEnrichment(hop.Enrichment{ASN: 64500, ASOrg: "Example \"Net\"", City: "Paris", Country: "FR"})
+	tr.AddHop(h1)
+
+	h2 := hop.NewHop(2)
+	h2.Probes = []hop.Probe{probe("10.1.0.1", 5, 1), probe("10.1.0.2", 9, 2)}
+	tr.AddHop(h2)
+
+	h3 := hop.NewHop(3)
+	h3.Probes = []hop.Probe{{Timeout: true, FlowID: 1}, {Timeout: true, FlowID: 2}}
+	tr.AddHop(h3)
+
+	h4 := hop.NewHop(4)
+	h4.Probes = []hop.Probe{probe("10.3.0.1", 20, 1), probe("10.3.0.1", 20, 2)}
+	tr.AddHop(h4)
+
+	tr.ReachedTarget = true
+	return tr
+}
+
+func TestBuildTopology_ECMPBranches(t *testing.T) {
+	topo := buildTopology([]*hop.TraceResult{createECMPTrace()})
+
+	if topo.out["10.0.0.1"] != 2 {
+		t.Errorf("expected 2 branches at 10.0.0.1, got %d", topo.out["10.0.0.1"])
+	}
+	e := topo.byKey[[2]string{"10.0.0.1", "10.1.0.2"}]
+	if e == nil {
+		t.Fatal("expected edge 10.0.0.1 -> 10.1.0.2")
+	}
+	if d, ok := e.delta(); !ok || d != 8*time.Millisecond {
+		t.Errorf("expected +8ms delta, got %v (%v)", d, ok)
+	}
+	if flowList(e.flows) != "2" {
+		t.Errorf("expected flow 2 on branch, got %v", e.flows)
+	}
+
+	silent := topo.byID["source_ttl3"]
+	if silent == nil || !silent.silent {
+		t.Fatal("expected silent node for TTL 3")
+	}
+	if topo.byKey[[2]string{"source_ttl3", "10.3.0.1"}] == nil {
+		t.Error("expected silent hop to link to the target")
+	}
+	if !topo.byID["10.3.0.1"].target {
+		t.Error("expected target node to be marked")
+	}
+}
+
+func TestBuildTopology_MergesSharedHops(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.Source = "Paris, FR"
+
+	topo := buildTopology([]*hop.TraceResult{a, b})
+
+	if topo.byID["source1"] == nil || topo.byID["source2"] == nil {
+		t.Fatal("expected one source node per trace")
+	}
+	if got := topo.byID["source2"].lines[0]; got != "Paris, FR" {
+		t.Errorf("expected source label from trace, got %q", got)
+	}
+	e := topo.byKey[[2]string{"192.168.1.1", "10.0.0.1"}]
+	if e == nil || e.deltas != 2 {
+		t.Fatalf("expected shared edge with a delta from each trace, got %+v", e)
+	}
+}
+
+func TestDOTExporter_Export(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewDOTExporter().Export(&buf, createECMPTrace()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"digraph gtrace {",
+		`label="Traceroute to example.com (10.3.0.1)"`,
+		`"source" [label="local", shape=ellipse];`,
+		`"10.0.0.1" [label="10.0.0.1\nAS64500 Example \"Net\"\nParis, FR"];`,
+		`"source_ttl3" [label="*\nTTL 3", style=dashed];`,
+		`"10.3.0.1" [label="10.3.0.1", peripheries=2];`,
+		`"10.0.0.1" -> "10.1.0.2" [label="+8.0 ms\nflows 2", penwidth=`,
+		`"source" -> "10.0.0.1" [label="+1.0 ms", penwidth=1.5];`,
+		`"10.1.0.2" -> "source_ttl3" [penwidth=1.0];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in DOT output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"source" -> "source_ttl3"`) {
+		t.Error("expected silent hop to be reached through TTL 2 only")
+	}
+	if !strings.HasSuffix(out, "}\n") {
+		t.Error("expected closed graph")
+	}
+}
+
+func TestD2Exporter_Export(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewD2Exporter().Export(&buf, createECMPTrace()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"direction: down",
+		`"source": "local" {shape: oval}`,
+		`"source_ttl3": "*\nTTL 3" {style.stroke-dash: 3}`,
+		`"10.3.0.1": "10.3.0.1" {style.double-border: true}`,
+		`"10.0.0.1" -> "10.1.0.1": "+4.0 ms\nflows 1" {style.stroke-width: `,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in D2 output:\n%s", want, out)
+		}
+	}
+}
+
+func TestDetectFormat_Graphs(t *testing.T) {
+	for file, want := range map[string]Format{"a.dot": FormatDOT, "a.gv": FormatDOT, "a.d2": FormatD2} {
+		if got := DetectFormat(file); got != want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package export
 import (
 	"encoding/json"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
@@ -11,40 +12,109 @@ import (
 
 // ExportedTrace is the JSON representation of a trace result.
 type ExportedTrace struct {
-	Target        string        `json:"target"`
-	TargetIP      string        `json:"targetIP"`
-	Protocol      string        `json:"protocol,omitempty"`
-	Source        string        `json:"source,omitempty"`
-	ReachedTarget bool          `json:"reachedTarget"`
-	StartTime     time.Time     `json:"startTime,omitempty"`
-	EndTime       time.Time     `json:"endTime,omitempty"`
-	Hops          []ExportedHop `json:"hops"`
+	Target          string        `json:"target"`
+	TargetIP        string        `json:"targetIP"`
+	Protocol        string        `json:"protocol,omitempty"`
+	Source          string        `json:"source,omitempty"`
+	ReachedTarget   bool          `json:"reachedTarget"`
+	StartTime       time.Time     `json:"startTime,omitempty"`
+	EndTime         time.Time     `json:"endTime,omitempty"`
+	StartSkewMs     float64       `json:"startSkewMs,omitempty"`     // Start offset from the earliest trace in a multi-trace export
+	TimestampSource string        `json:"timestampSource,omitempty"` // How RTTs were timed: kernel, kernel-receive or userspace
+	Hops            []ExportedHop `json:"hops"`
+
+	TargetFingerprint *ExportedFingerprint `json:"targetFingerprint,omitempty"` // SYN-ACK of the target (tcp)
+	Route             *ExportedRoute       `json:"route,omitempty"`             // Kernel route to the target (local traces)
+	Overlay           *ExportedOverlay     `json:"overlay,omitempty"`           // VPN or overlay network on the path
+}
+
+// ExportedOverlay is the JSON representation of a detected VPN or overlay.
+type ExportedOverlay struct {
+	Kind     string   `json:"kind"`
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// ExportedRoute is the JSON representation of the kernel route to the target.
+type ExportedRoute struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway,omitempty"`
+	Interface   string `json:"interface,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Metric      int    `json:"metric,omitempty"`
+	Table       string `json:"table,omitempty"`
+	MTU         int    `json:"mtu,omitempty"`
+}
+
+// ExportedFingerprint is the JSON representation of the target's SYN-ACK.
+type ExportedFingerprint struct {
+	TTL         int    `json:"ttl"`
+	InitialTTL  int    `json:"initialTtl"`
+	Window      int    `json:"window"`
+	MSS         int    `json:"mss,omitempty"`
+	WindowScale *int   `json:"windowScale,omitempty"`
+	SACK        bool   `json:"sack,omitempty"`
+	Timestamps  bool   `json:"timestamps,omitempty"`
+	Options     string `json:"options,omitempty"` // Option layout, e.g. "M,S,T,N,W"
+	OS          string `json:"os,omitempty"`      // Likely operating system
+	MSSClamped  bool   `json:"mssClamped,omitempty"`
 }
 
 // ExportedHop is the JSON representation of a single hop.
 type ExportedHop struct {
-	TTL         int             `json:"ttl"`
-	IP          string          `json:"ip,omitempty"`
-	Hostname    string          `json:"hostname,omitempty"`
-	ASN         uint32          `json:"asn,omitempty"`
-	ASOrg       string          `json:"asOrg,omitempty"`
-	Country     string          `json:"country,omitempty"`
-	City        string          `json:"city,omitempty"`
-	Probes      []ExportedProbe `json:"probes"`
-	MPLS        []ExportedMPLS  `json:"mpls,omitempty"`
-	AvgRTT      float64         `json:"avgRtt"`     // in ms
-	LossPercent float64         `json:"lossPercent"`
-	NAT         bool            `json:"nat,omitempty"`
-	MTU         int             `json:"mtu,omitempty"`
-	ICMPCode    string          `json:"icmpCode,omitempty"` // e.g. "port_unreachable"
+	TTL         int               `json:"ttl"`
+	IP          string            `json:"ip,omitempty"`
+	Hostname    string            `json:"hostname,omitempty"`
+	ASN         uint32            `json:"asn,omitempty"`
+	ASOrg       string            `json:"asOrg,omitempty"`
+	Country     string            `json:"country,omitempty"`
+	City        string            `json:"city,omitempty"`
+	IX          string            `json:"ix,omitempty"`            // Internet Exchange whose peering LAN the hop is on
+	Special     string            `json:"special,omitempty"`       // IANA special-purpose range, e.g. "Documentation"
+	Interface   string            `json:"interfaceHint,omitempty"` // Parsed from the hostname
+	Location    string            `json:"locationHint,omitempty"`  // Parsed from the hostname
+	BGP         *ExportedBGP      `json:"bgp,omitempty"`
+	Reputation  []ExportedListing `json:"reputation,omitempty"` // Blocklists the hop is on
+	Probes      []ExportedProbe   `json:"probes"`
+	MPLS        []ExportedMPLS    `json:"mpls,omitempty"`
+	SR          *ExportedSR       `json:"segmentRouting,omitempty"`
+	AvgRTT      float64           `json:"avgRtt"` // in ms
+	LossPercent float64           `json:"lossPercent"`
+	NAT         bool              `json:"nat,omitempty"`
+	GeoMismatch bool              `json:"geoMismatch,omitempty"`
+	MTU         int               `json:"mtu,omitempty"`
+	ICMPCode    string            `json:"icmpCode,omitempty"` // e.g. "port_unreachable"
+	Protocol    string            `json:"protocol,omitempty"` // Protocol that got the answers (--protocol auto)
+}
+
+// ExportedBGP is the JSON representation of a hop's BGP routing data.
+type ExportedBGP struct {
+	Prefix     string   `json:"prefix"`
+	OriginASN  uint32   `json:"originAsn,omitempty"`
+	ASPath     []uint32 `json:"asPath,omitempty"`
+	Visibility float64  `json:"visibility,omitempty"` // % of collector peers seeing the prefix
+}
+
+// ExportedListing is the JSON representation of a reputation listing.
+type ExportedListing struct {
+	List     string `json:"list"`
+	Category string `json:"category"` // bogon, hijack or listed
 }
 
 // ExportedProbe is the JSON representation of a single probe.
 type ExportedProbe struct {
-	IP      string                  `json:"ip,omitempty"`
-	RTT     float64                 `json:"rtt,omitempty"` // in ms
-	Timeout bool                    `json:"timeout,omitempty"`
-	Decode  *ExportedTransportInfo  `json:"decode,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	RTT       float64                `json:"rtt,omitempty"` // in ms
+	Timeout   bool                   `json:"timeout,omitempty"`
+	Decode    *ExportedTransportInfo `json:"decode,omitempty"`
+	Handshake *ExportedHandshake     `json:"handshake,omitempty"`
+}
+
+// ExportedHandshake is the JSON representation of a TCP handshake breakdown (all in ms).
+type ExportedHandshake struct {
+	SYNRTT      float64 `json:"synRtt"`
+	ConnectRTT  float64 `json:"connectRtt"`
+	TLSRTT      float64 `json:"tlsRtt,omitempty"`
+	ServerDelay float64 `json:"serverDelay,omitempty"`
 }
 
 // ExportedTransportInfo is the JSON representation of decoded transport header info.
@@ -70,6 +140,21 @@ type ExportedMPLS struct {
 	TTL   uint8  `json:"ttl"`
 }
 
+// ExportedSR is the JSON representation of a hop's segment routing domain.
+type ExportedSR struct {
+	Dataplane    string            `json:"dataplane"` // sr-mpls or srv6
+	Segments     []ExportedSegment `json:"segments,omitempty"`
+	SegmentsLeft int               `json:"segmentsLeft,omitempty"` // srv6 only
+}
+
+// ExportedSegment is the JSON representation of a segment list entry.
+type ExportedSegment struct {
+	SID       string `json:"sid,omitempty"`       // srv6
+	Label     uint32 `json:"label,omitempty"`     // sr-mpls
+	Index     *int   `json:"index,omitempty"`     // sr-mpls prefix segment: index in the SRGB
+	Adjacency bool   `json:"adjacency,omitempty"` // sr-mpls adjacency segment
+}
+
 // JSONExporter exports trace results to JSON format.
 type JSONExporter struct {
 	Pretty bool // Whether to pretty-print the JSON
@@ -94,23 +179,74 @@ func (e *JSONExporter) Export(w io.Writer, tr *hop.TraceResult) error {
 	return encoder.Encode(exported)
 }
 
+// ExportAll writes several trace results as a JSON array.
+func (e *JSONExporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	earliest := hop.EarliestStart(results)
+	exported := make([]*ExportedTrace, 0, len(results))
+	for _, tr := range results {
+		et := e.convert(tr)
+		et.StartSkewMs = float64(tr.StartSkew(earliest)) / float64(time.Millisecond)
+		exported = append(exported, et)
+	}
+
+	encoder := json.NewEncoder(w)
+	if e.Pretty {
+		encoder.SetIndent("", "  ")
+	}
+
+	return encoder.Encode(exported)
+}
+
 // convert transforms a TraceResult to an ExportedTrace.
 func (e *JSONExporter) convert(tr *hop.TraceResult) *ExportedTrace {
 	exported := &ExportedTrace{
-		Target:        tr.Target,
-		TargetIP:      tr.TargetIP,
-		Protocol:      tr.Protocol,
-		Source:        tr.Source,
-		ReachedTarget: tr.ReachedTarget,
-		StartTime:     tr.StartTime,
-		EndTime:       tr.EndTime,
-		Hops:          make([]ExportedHop, 0, len(tr.Hops)),
+		Target:          tr.Target,
+		TargetIP:        tr.TargetIP,
+		Protocol:        tr.Protocol,
+		Source:          tr.Source,
+		ReachedTarget:   tr.ReachedTarget,
+		StartTime:       tr.StartTime,
+		EndTime:         tr.EndTime,
+		TimestampSource: tr.TimestampSource,
+		Hops:            make([]ExportedHop, 0, len(tr.Hops)),
 	}
 
 	for _, h := range tr.Hops {
 		exported.Hops = append(exported.Hops, e.convertHop(h))
 	}
 
+	if fp := tr.TargetFingerprint(); fp != nil {
+		ef := &ExportedFingerprint{
+			TTL:        fp.TTL,
+			InitialTTL: fp.InitialTTL(),
+			Window:     fp.Window,
+			MSS:        fp.MSS,
+			SACK:       fp.SACK,
+			Timestamps: fp.Timestamps,
+			Options:    fp.Options,
+			OS:         fp.OS(),
+			MSSClamped: fp.MSSClamped(strings.Contains(tr.TargetIP, ":")),
+		}
+		if fp.WindowScale >= 0 {
+			ef.WindowScale = intPtr(fp.WindowScale)
+		}
+		exported.TargetFingerprint = ef
+	}
+	if r := tr.Route; r != nil {
+		exported.Route = &ExportedRoute{
+			Destination: r.Destination,
+			Gateway:     r.Gateway,
+			Interface:   r.Interface,
+			Source:      r.Source,
+			Metric:      r.Metric,
+			Table:       r.Table,
+			MTU:         r.MTU,
+		}
+	}
+	if o := tr.Overlay; o != nil {
+		exported.Overlay = &ExportedOverlay{Kind: o.Kind, Evidence: o.Evidence}
+	}
+
 	return exported
 }
 
@@ -129,14 +265,33 @@ func (e *JSONExporter) convertHop(h *hop.Hop) ExportedHop {
 		ASOrg:       h.Enrichment.ASOrg,
 		Country:     h.Enrichment.Country,
 		City:        h.Enrichment.City,
+		IX:          h.Enrichment.IX,
+		Special:     h.Enrichment.Special,
+		Interface:   h.Enrichment.InterfaceHint,
+		Location:    h.Enrichment.LocationHint,
 		Probes:      make([]ExportedProbe, 0, len(h.Probes)),
 		AvgRTT:      float64(h.AvgRTT()) / float64(time.Millisecond),
 		LossPercent: h.LossPercent(),
 		NAT:         h.NAT,
+		GeoMismatch: h.GeoMismatch,
+		Protocol:    h.Protocol,
 		MTU:         h.MTU,
 		ICMPCode:    icmpCodeForExport(h),
 	}
 
+	if bgp := h.Enrichment.BGP; bgp != nil {
+		exported.BGP = &ExportedBGP{
+			Prefix:     bgp.Prefix,
+			OriginASN:  bgp.OriginASN,
+			ASPath:     bgp.ASPath,
+			Visibility: bgp.Visibility,
+		}
+	}
+
+	for _, l := range h.Enrichment.Reputation {
+		exported.Reputation = append(exported.Reputation, ExportedListing{List: l.List, Category: l.Category})
+	}
+
 	for _, p := range h.Probes {
 		exported.Probes = append(exported.Probes, e.convertProbe(p))
 	}
@@ -150,6 +305,22 @@ func (e *JSONExporter) convertHop(h *hop.Hop) ExportedHop {
 		})
 	}
 
+	if sr := h.SegmentRouting; sr != nil {
+		exported.SR = &ExportedSR{Dataplane: sr.Dataplane, SegmentsLeft: sr.SegmentsLeft}
+		for _, seg := range sr.Segments {
+			es := ExportedSegment{Label: seg.Label}
+			switch {
+			case seg.SID != nil:
+				es.SID = seg.SID.String()
+			case seg.Index < 0:
+				es.Adjacency = true
+			default:
+				es.Index = intPtr(seg.Index)
+			}
+			exported.SR.Segments = append(exported.SR.Segments, es)
+		}
+	}
+
 	return exported
 }
 
@@ -183,6 +354,15 @@ func (e *JSONExporter) convertProbe(p hop.Probe) ExportedProbe {
 		}
 	}
 
+	if hs := p.Handshake; hs != nil {
+		exported.Handshake = &ExportedHandshake{
+			SYNRTT:      float64(hs.SYNRTT) / float64(time.Millisecond),
+			ConnectRTT:  float64(hs.ConnectRTT) / float64(time.Millisecond),
+			TLSRTT:      float64(hs.TLSRTT) / float64(time.Millisecond),
+			ServerDelay: float64(hs.ServerDelay()) / float64(time.Millisecond),
+		}
+	}
+
 	return exported
 }
 
@@ -231,6 +231,34 @@ func TestJSONExport_DecodeField(t *testing.T) {
 	}
 }
 
+func TestJSONExport_HandshakeField(t *testing.T) {
+	tr := &hop.TraceResult{
+		Hops: []*hop.Hop{{
+			TTL: 1,
+			Probes: []hop.Probe{{
+				IP:  net.ParseIP("1.2.3.4"),
+				RTT: 12 * time.Millisecond,
+				Handshake: &hop.Handshake{
+					SYNRTT:     10 * time.Millisecond,
+					ConnectRTT: 12 * time.Millisecond,
+					TLSRTT:     25 * time.Millisecond,
+				},
+			}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := NewJSONExporter().Export(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, `"synRtt":10`) || !strings.Contains(output, `"connectRtt":12`) {
+		t.Errorf("expected SYN and connect RTT in JSON output: %s", output)
+	}
+	if !strings.Contains(output, `"tlsRtt":25`) || !strings.Contains(output, `"serverDelay":15`) {
+		t.Errorf("expected TLS timing and server delay in JSON output: %s", output)
+	}
+}
+
 func TestJSONExport_NoDecodeWhenNil(t *testing.T) {
 	tr := &hop.TraceResult{
 		Hops: []*hop.Hop{{
@@ -279,3 +307,103 @@ func createTestTrace() *hop.TraceResult {
 
 	return tr
 }
+
+func TestJSONExport_RoutingFields(t *testing.T) {
+	h := hop.NewHop(1)
+	h.AddProbe(net.ParseIP("8.8.8.8"), time.Millisecond)
+	h.SetEnrichment(hop.Enrichment{
+		IX:         "DE-CIX Frankfurt",
+		Special:    "Documentation",
+		BGP:        &hop.BGPInfo{Prefix: "8.8.8.0/24", OriginASN: 15169, ASPath: []uint32{3356, 15169}, Visibility: 98},
+		Reputation: []hop.ReputationListing{{List: "spamhaus-drop", Category: hop.ReputationHijack}},
+	})
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().Export(&buf, &hop.TraceResult{Target: "8.8.8.8", Hops: []*hop.Hop{h}}); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, `"bgp":{"prefix":"8.8.8.0/24","originAsn":15169,"asPath":[3356,15169],"visibility":98}`) {
+		t.Errorf("expected BGP data in JSON output: %s", output)
+	}
+	if !strings.Contains(output, `"ix":"DE-CIX Frankfurt"`) {
+		t.Errorf("expected IX name in JSON output: %s", output)
+	}
+	if !strings.Contains(output, `"special":"Documentation"`) {
+		t.Errorf("expected special-purpose label in JSON output: %s", output)
+	}
+	if !strings.Contains(output, `"reputation":[{"list":"spamhaus-drop","category":"hijack"}]`) {
+		t.Errorf("expected reputation listing in JSON output: %s", output)
+	}
+
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := results[0].Hops[0].Enrichment.Reputation; len(got) != 1 || got[0].List != "spamhaus-drop" {
+		t.Errorf("reputation not restored: %+v", got)
+	}
+}
+
+func TestJSONExporter_Export_IncludesTimestampSource(t *testing.T) {
+	tr := createTestTrace()
+	tr.TimestampSource = hop.TimestampKernel
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().Export(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"timestampSource":"kernel"`) {
+		t.Errorf("expected timestamp source in JSON, got %s", buf.String())
+	}
+
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].TimestampSource != hop.TimestampKernel {
+		t.Errorf("expected timestamp source %q, got %q", hop.TimestampKernel, results[0].TimestampSource)
+	}
+}
+
+func TestJSONExporter_Export_IncludesRoute(t *testing.T) {
+	tr := createTestTrace()
+	tr.Route = &hop.RouteInfo{Destination: "default", Gateway: "192.168.1.1", Interface: "eth0", Source: "192.168.1.10", Metric: 100, Table: "main"}
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().Export(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"route":{"destination":"default","gateway":"192.168.1.1"`) {
+		t.Errorf("expected route in JSON, got %s", buf.String())
+	}
+
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := results[0].Route; got == nil || *got != *tr.Route {
+		t.Errorf("route not restored: %+v", got)
+	}
+}
+
+func TestJSONExporter_Export_IncludesOverlay(t *testing.T) {
+	tr := createTestTrace()
+	tr.Overlay = &hop.Overlay{Kind: "WireGuard", Evidence: []string{"route through wg0"}}
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().Export(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"overlay":{"kind":"WireGuard","evidence":["route through wg0"]}`) {
+		t.Errorf("expected overlay in JSON, got %s", buf.String())
+	}
+
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := results[0].Overlay; got == nil || got.Kind != "WireGuard" || len(got.Evidence) != 1 {
+		t.Errorf("overlay not restored: %+v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// ReadJSON reads trace results written by the JSON exporter: a single trace,
+// an array of traces (multi-target and compare exports) or one trace per
+// line (a monitor --history-file).
+func ReadJSON(r io.Reader) ([]*hop.TraceResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no traces found")
+	}
+
+	var exported []ExportedTrace
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &exported); err != nil {
+			return nil, fmt.Errorf("invalid trace JSON: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for dec.More() {
+			var single ExportedTrace
+			if err := dec.Decode(&single); err != nil {
+				return nil, fmt.Errorf("invalid trace JSON: %w", err)
+			}
+			exported = append(exported, single)
+		}
+	}
+
+	results := make([]*hop.TraceResult, 0, len(exported))
+	for i := range exported {
+		if exported[i].Target == "" && exported[i].TargetIP == "" {
+			return nil, fmt.Errorf("trace %d has no target: not a gtrace JSON export", i+1)
+		}
+		results = append(results, exported[i].ToTraceResult())
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no traces found")
+	}
+	return results, nil
+}
+
+// ToTraceResult converts an exported trace back to a TraceResult. Derived
+// values (average RTT, loss) are recomputed from the probes.
+func (et *ExportedTrace) ToTraceResult() *hop.TraceResult {
+	tr := hop.NewTraceResult(et.Target, et.TargetIP)
+	tr.Protocol = et.Protocol
+	tr.Source = et.Source
+	tr.ReachedTarget = et.ReachedTarget
+	tr.StartTime = et.StartTime
+	tr.EndTime = et.EndTime
+	tr.TimestampSource = et.TimestampSource
+	if er := et.Route; er != nil {
+		tr.Route = &hop.RouteInfo{
+			Destination: er.Destination,
+			Gateway:     er.Gateway,
+			Interface:   er.Interface,
+			Source:      er.Source,
+			Metric:      er.Metric,
+			Table:       er.Table,
+			MTU:         er.MTU,
+		}
+	}
+	if eo := et.Overlay; eo != nil {
+		tr.Overlay = &hop.Overlay{Kind: eo.Kind, Evidence: eo.Evidence}
+	}
+
+	for _, eh := range et.Hops {
+		tr.AddHop(eh.toHop())
+	}
+
+	// The fingerprint was captured by a probe answered by the target
+	if ef := et.TargetFingerprint; ef != nil && len(tr.Hops) > 0 {
+		fp := &hop.TCPFingerprint{TTL: ef.TTL, Window: ef.Window, MSS: ef.MSS, WindowScale: -1, SACK: ef.SACK, Timestamps: ef.Timestamps, Options: ef.Options}
+		if ef.WindowScale != nil {
+			fp.WindowScale = *ef.WindowScale
+		}
+		last := tr.Hops[len(tr.Hops)-1]
+		for i := range last.Probes {
+			if !last.Probes[i].Timeout {
+				last.Probes[i].Fingerprint = fp
+				break
+			}
+		}
+	}
+	return tr
+}
+
+// toHop converts an exported hop back to a Hop.
+func (eh *ExportedHop) toHop() *hop.Hop {
+	h := hop.NewHop(eh.TTL)
+	h.NAT = eh.NAT
+	h.GeoMismatch = eh.GeoMismatch
+	h.Protocol = eh.Protocol
+	h.MTU = eh.MTU
+	h.Enrichment = hop.Enrichment{
+		ASN:           eh.ASN,
+		ASOrg:         eh.ASOrg,
+		Country:       eh.Country,
+		City:          eh.City,
+		Hostname:      eh.Hostname,
+		IX:            eh.IX,
+		Special:       eh.Special,
+		InterfaceHint: eh.Interface,
+		LocationHint:  eh.Location,
+	}
+	if eh.BGP != nil {
+		h.Enrichment.BGP = &hop.BGPInfo{
+			Prefix:     eh.BGP.Prefix,
+			OriginASN:  eh.BGP.OriginASN,
+			ASPath:     eh.BGP.ASPath,
+			Visibility: eh.BGP.Visibility,
+		}
+	}
+	for _, l := range eh.Reputation {
+		h.Enrichment.Reputation = append(h.Enrichment.Reputation, hop.ReputationListing{List: l.List, Category: l.Category})
+	}
+
+	icmpCode, unreachable := icmpCodeFromExport(eh.ICMPCode)
+	for _, ep := range eh.Probes {
+		p := ep.toProbe()
+		if unreachable && !p.Timeout && p.IP.String() == eh.IP {
+			p.ICMPType, p.ICMPCode = 3, icmpCode
+		}
+		h.Probes = append(h.Probes, p)
+	}
+
+	for _, m := range eh.MPLS {
+		h.MPLS = append(h.MPLS, hop.MPLSLabel{Label: m.Label, Exp: m.Exp, S: m.S, TTL: m.TTL})
+	}
+	if eh.SR != nil {
+		h.SegmentRouting = &hop.SegmentRouting{Dataplane: eh.SR.Dataplane, SegmentsLeft: eh.SR.SegmentsLeft}
+		for _, es := range eh.SR.Segments {
+			seg := hop.Segment{SID: net.ParseIP(es.SID), Label: es.Label, Index: -1}
+			if es.Index != nil {
+				seg.Index = *es.Index
+			}
+			h.SegmentRouting.Segments = append(h.SegmentRouting.Segments, seg)
+		}
+	}
+	return h
+}
+
+// toProbe converts an exported probe back to a Probe.
+func (ep *ExportedProbe) toProbe() hop.Probe {
+	if ep.Timeout {
+		return hop.Probe{Timeout: true}
+	}
+	p := hop.Probe{
+		IP:  net.ParseIP(ep.IP),
+		RTT: fromMs(ep.RTT),
+	}
+	if d := ep.Decode; d != nil {
+		p.TransportInfo = &hop.TransportInfo{
+			DSCP:        d.DSCP,
+			ECN:         d.ECN,
+			DF:          d.DF,
+			TCPSrcPort:  d.TCPSrcPort,
+			TCPDstPort:  d.TCPDstPort,
+			TCPSeqNum:   d.TCPSeqNum,
+			TCPFlagsStr: d.TCPFlags,
+			UDPSrcPort:  d.UDPSrcPort,
+			UDPDstPort:  d.UDPDstPort,
+			UDPLength:   d.UDPLength,
+			UDPChecksum: d.UDPChecksum,
+		}
+	}
+	if hs := ep.Handshake; hs != nil {
+		p.Handshake = &hop.Handshake{
+			SYNRTT:     fromMs(hs.SYNRTT),
+			ConnectRTT: fromMs(hs.ConnectRTT),
+			TLSRTT:     fromMs(hs.TLSRTT),
+		}
+	}
+	return p
+}
+
+// icmpCodeFromExport reverses icmpCodeForExport: the Destination
+// Unreachable code of an exported hop, if any.
+func icmpCodeFromExport(s string) (int, bool) {
+	switch s {
+	case "network_unreachable":
+		return 0, true
+	case "host_unreachable":
+		return 1, true
+	case "port_unreachable":
+		return 3, true
+	case "fragmentation_needed":
+		return 4, true
+	case "admin_prohibited":
+		return 13, true
+	}
+	return 0, false
+}
+
+// fromMs converts milliseconds to a duration.
+func fromMs(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestReadJSON_RoundTripsSingleTrace(t *testing.T) {
+	tr := createTestTrace()
+	unreachable := hop.NewHop(3)
+	unreachable.Probes = append(unreachable.Probes, hop.Probe{IP: net.ParseIP("8.8.8.8"), RTT: 9 * time.Millisecond, ICMPType: 3, ICMPCode: 3})
+	unreachable.SetMPLS([]hop.MPLSLabel{{Label: 24001, S: true, TTL: 1}})
+	unreachable.Probes[0].Fingerprint = &hop.TCPFingerprint{TTL: 52, Window: 65160, MSS: 1460, WindowScale: 7, SACK: true, Options: "M,S,T,N,W"}
+	unreachable.SegmentRouting = &hop.SegmentRouting{Dataplane: hop.SRMPLS, Segments: []hop.Segment{{Label: 16001, Index: 1}, {Label: 15003, Index: -1}}}
+	tr.AddHop(unreachable)
+
+	var buf bytes.Buffer
+	if err := NewJSONExporter().Export(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 trace, got %d", len(results))
+	}
+
+	got := results[0]
+	if got.Target != "google.com" || got.TargetIP != "8.8.8.8" || got.Protocol != "icmp" || !got.ReachedTarget {
+		t.Errorf("trace fields not restored: %+v", got)
+	}
+	if !got.StartTime.Equal(tr.StartTime) {
+		t.Errorf("expected start time %v, got %v", tr.StartTime, got.StartTime)
+	}
+	if len(got.Hops) != 3 {
+		t.Fatalf("expected 3 hops, got %d", len(got.Hops))
+	}
+
+	h2 := got.Hops[1]
+	if h2.Enrichment.ASN != 12345 || h2.Enrichment.Hostname != "router.test.com" {
+		t.Errorf("enrichment not restored: %+v", h2.Enrichment)
+	}
+	if len(h2.Probes) != 3 || !h2.Probes[1].Timeout {
+		t.Errorf("expected probes with a timeout in the middle, got %+v", h2.Probes)
+	}
+	if h2.Probes[0].RTT != 5*time.Millisecond {
+		t.Errorf("expected 5ms RTT, got %v", h2.Probes[0].RTT)
+	}
+
+	h3 := got.Hops[2]
+	if h3.Probes[0].ICMPType != 3 || h3.Probes[0].ICMPCode != 3 {
+		t.Errorf("expected port unreachable, got type %d code %d", h3.Probes[0].ICMPType, h3.Probes[0].ICMPCode)
+	}
+	if len(h3.MPLS) != 1 || h3.MPLS[0].Label != 24001 {
+		t.Errorf("expected MPLS label 24001, got %+v", h3.MPLS)
+	}
+	if fp := got.TargetFingerprint(); fp == nil || fp.WindowScale != 7 || fp.Options != "M,S,T,N,W" || fp.OS() != "Linux" {
+		t.Errorf("expected the target fingerprint restored, got %+v", fp)
+	}
+	if sr := h3.SegmentRouting; sr == nil || sr.String() != "SR-MPLS 16001 (idx 1) > 15003 (adj)" {
+		t.Errorf("expected segment routing restored, got %+v", sr)
+	}
+}
+
+func TestReadJSON_ReadsArray(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewJSONExporter().ExportAll(&buf, []*hop.TraceResult{createTestTrace(), createTestTrace()}); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 traces, got %d", len(results))
+	}
+}
+
+func TestReadJSON_ReadsOneTracePerLine(t *testing.T) {
+	var buf bytes.Buffer
+	for range 3 {
+		if err := NewJSONExporter().Export(&buf, createTestTrace()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected 3 traces, got %d", len(results))
+	}
+}
+
+func TestReadJSON_RejectsOtherJSON(t *testing.T) {
+	for _, input := range []string{"", "not json", `{"foo":1}`, `[]`} {
+		if _, err := ReadJSON(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// OTLP span kinds and status codes (opentelemetry-proto trace/v1).
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+)
+
+// OTLPExporter exports trace results as an OTLP/JSON ExportTraceServiceRequest:
+// one OpenTelemetry trace per traceroute, with a root span covering the whole
+// trace and a child span per hop whose duration is the hop's average RTT.
+type OTLPExporter struct{}
+
+// NewOTLPExporter creates a new OTLP exporter.
+func NewOTLPExporter() *OTLPExporter {
+	return &OTLPExporter{}
+}
+
+// Export writes the OTLP/JSON request of a single trace result.
+func (e *OTLPExporter) Export(w io.Writer, tr *hop.TraceResult) error {
+	return e.ExportAll(w, []*hop.TraceResult{tr})
+}
+
+// ExportAll writes one OTLP/JSON request holding a trace per result.
+func (e *OTLPExporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	data, err := json.Marshal(otlpRequest(results))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// SendOTLP posts results as OpenTelemetry traces to an OTLP/HTTP collector.
+// An endpoint without a path gets the standard /v1/traces appended. Headers
+// from OTEL_EXPORTER_OTLP_HEADERS ("key=value,...") are sent along, for
+// collectors that require authentication.
+func SendOTLP(ctx context.Context, endpoint string, results []*hop.TraceResult) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	var body bytes.Buffer
+	if err := NewOTLPExporter().ExportAll(&body, results); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return fmt.Errorf("OTLP export failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range otlpEnvHeaders() {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP export failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP export failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpEnvHeaders parses OTEL_EXPORTER_OTLP_HEADERS, whose values may be
+// percent-encoded.
+func otlpEnvHeaders() map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if v, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = v
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	return headers
+}
+
+// OTLP/JSON encoding. 64-bit integers and timestamps are strings, IDs are
+// hex, as the protocol's JSON mapping requires.
+type (
+	otlpTraceRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func otlpString(key, v string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func otlpBool(key string, v bool) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &v}}
+}
+
+func otlpInt(key string, v int64) otlpKeyValue {
+	s := strconv.FormatInt(v, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpDouble(key string, v float64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &v}}
+}
+
+// otlpRequest builds the request holding one trace per result.
+func otlpRequest(results []*hop.TraceResult) otlpTraceRequest {
+	var spans []otlpSpan
+	for _, tr := range results {
+		spans = append(spans, otlpTraceSpans(tr)...)
+	}
+	return otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", "gtrace")}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/hervehildenbrand/gtrace"}, Spans: spans}},
+	}}}
+}
+
+// otlpTraceSpans returns the root span of tr followed by a span per hop.
+// Hops are probed concurrently, so every hop span starts with the trace.
+func otlpTraceSpans(tr *hop.TraceResult) []otlpSpan {
+	traceID := randomHex(16)
+	rootID := randomHex(8)
+
+	start := tr.StartTime
+	if start.IsZero() {
+		start = time.Now()
+	}
+	end := tr.EndTime
+	for _, h := range tr.Hops {
+		if e := start.Add(hopMaxRTT(h)); e.After(end) {
+			end = e
+		}
+	}
+
+	rootAttrs := []otlpKeyValue{
+		otlpString("gtrace.target", tr.Target),
+		otlpString("gtrace.target_ip", tr.TargetIP),
+		otlpString("gtrace.protocol", tr.Protocol),
+		otlpBool("gtrace.reached", tr.ReachedTarget),
+		otlpInt("gtrace.hops", int64(tr.TotalHops())),
+	}
+	if tr.Source != "" {
+		rootAttrs = append(rootAttrs, otlpString("gtrace.source", tr.Source))
+	}
+	root := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            rootID,
+		Name:              "traceroute " + tr.Target,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes:        rootAttrs,
+	}
+	if !tr.ReachedTarget {
+		root.Status = &otlpStatus{Code: otlpStatusError, Message: "target not reached"}
+	}
+
+	spans := []otlpSpan{root}
+	for _, h := range tr.Hops {
+		span := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            randomHex(8),
+			ParentSpanID:      rootID,
+			Name:              fmt.Sprintf("hop %d", h.TTL),
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: unixNano(start),
+			EndTimeUnixNano:   unixNano(start.Add(h.AvgRTT())),
+			Attributes:        otlpHopAttributes(h),
+		}
+		if ip := h.PrimaryIP(); ip != nil {
+			span.Name += " " + ip.String()
+		} else {
+			span.Status = &otlpStatus{Code: otlpStatusError, Message: "no reply"}
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// otlpHopAttributes returns the measurements and enrichment of h.
+func otlpHopAttributes(h *hop.Hop) []otlpKeyValue {
+	attrs := []otlpKeyValue{
+		otlpInt("gtrace.hop.ttl", int64(h.TTL)),
+		otlpDouble("gtrace.hop.loss_pct", h.LossPercent()),
+	}
+	if ip := h.PrimaryIP(); ip != nil {
+		attrs = append(attrs,
+			otlpString("gtrace.hop.ip", ip.String()),
+			otlpDouble("gtrace.hop.rtt_avg_ms", float64(h.AvgRTT())/float64(time.Millisecond)),
+			otlpDouble("gtrace.hop.rtt_max_ms", float64(hopMaxRTT(h))/float64(time.Millisecond)),
+		)
+	}
+
+	e := h.Enrichment
+	if e.Hostname != "" {
+		attrs = append(attrs, otlpString("gtrace.hop.hostname", e.Hostname))
+	}
+	if e.ASN != 0 {
+		attrs = append(attrs, otlpInt("gtrace.hop.asn", int64(e.ASN)))
+	}
+	if e.ASOrg != "" {
+		attrs = append(attrs, otlpString("gtrace.hop.as_org", e.ASOrg))
+	}
+	if e.Country != "" {
+		attrs = append(attrs, otlpString("gtrace.hop.country", e.Country))
+	}
+	if e.City != "" {
+		attrs = append(attrs, otlpString("gtrace.hop.city", e.City))
+	}
+	if e.IX != "" {
+		attrs = append(attrs, otlpString("gtrace.hop.ix", e.IX))
+	}
+	if len(h.MPLS) > 0 {
+		labels := make([]string, len(h.MPLS))
+		for i, l := range h.MPLS {
+			labels[i] = l.String()
+		}
+		attrs = append(attrs, otlpString("gtrace.hop.mpls", strings.Join(labels, "; ")))
+	}
+	if h.NAT {
+		attrs = append(attrs, otlpBool("gtrace.hop.nat", true))
+	}
+	if h.Protocol != "" {
+		attrs = append(attrs, otlpString("gtrace.hop.protocol", h.Protocol))
+	}
+	if h.MTU > 0 {
+		attrs = append(attrs, otlpInt("gtrace.hop.mtu", int64(h.MTU)))
+	}
+	return attrs
+}
+
+// hopMaxRTT returns the slowest answered probe of h.
+func hopMaxRTT(h *hop.Hop) time.Duration {
+	var worst time.Duration
+	for _, p := range h.Probes {
+		if !p.Timeout {
+			worst = max(worst, p.RTT)
+		}
+	}
+	return worst
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
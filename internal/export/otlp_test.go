@@ -0,0 +1,148 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// decodeOTLP exports results and returns the spans of the request.
+func decodeOTLP(t *testing.T, results ...*hop.TraceResult) []otlpSpan {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := NewOTLPExporter().ExportAll(&buf, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var req otlpTraceRequest
+	if err := json.Unmarshal(buf.Bytes(), &req); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request layout: %+v", req)
+	}
+	return req.ResourceSpans[0].ScopeSpans[0].Spans
+}
+
+// otlpAttr returns the attribute key of span, or nil.
+func otlpAttr(span otlpSpan, key string) *otlpAnyValue {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return &kv.Value
+		}
+	}
+	return nil
+}
+
+func TestOTLPExporter_Spans(t *testing.T) {
+	tr := createTestTrace()
+	tr.StartTime = time.Unix(1700000000, 0)
+	tr.EndTime = tr.StartTime.Add(time.Second)
+
+	spans := decodeOTLP(t, tr)
+	if len(spans) != 3 {
+		t.Fatalf("expected a root span and 2 hop spans, got %d", len(spans))
+	}
+	root, h2 := spans[0], spans[2]
+	if root.Name != "traceroute google.com" || root.ParentSpanID != "" || root.Status != nil {
+		t.Errorf("unexpected root span: %+v", root)
+	}
+	if len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Errorf("unexpected IDs: trace %q, span %q", root.TraceID, root.SpanID)
+	}
+	if root.StartTimeUnixNano != "1700000000000000000" || root.EndTimeUnixNano != "1700000001000000000" {
+		t.Errorf("unexpected root times: %s - %s", root.StartTimeUnixNano, root.EndTimeUnixNano)
+	}
+
+	if h2.TraceID != root.TraceID || h2.ParentSpanID != root.SpanID {
+		t.Errorf("hop span not in the trace: %+v", h2)
+	}
+	if h2.Name != "hop 2 10.0.0.1" {
+		t.Errorf("hop span name = %q", h2.Name)
+	}
+	// Average of 5ms and 6ms
+	if h2.EndTimeUnixNano != "1700000000005500000" {
+		t.Errorf("hop span end = %s, want start + 5.5ms", h2.EndTimeUnixNano)
+	}
+	if v := otlpAttr(h2, "gtrace.hop.asn"); v == nil || v.IntValue == nil || *v.IntValue != "12345" {
+		t.Errorf("gtrace.hop.asn = %+v", v)
+	}
+	if v := otlpAttr(h2, "gtrace.hop.hostname"); v == nil || v.StringValue == nil || *v.StringValue != "router.test.com" {
+		t.Errorf("gtrace.hop.hostname = %+v", v)
+	}
+	if v := otlpAttr(h2, "gtrace.hop.loss_pct"); v == nil || v.DoubleValue == nil || *v.DoubleValue < 33 || *v.DoubleValue > 34 {
+		t.Errorf("gtrace.hop.loss_pct = %+v", v)
+	}
+}
+
+func TestOTLPExporter_UnreachedAndSilentHop(t *testing.T) {
+	tr := hop.NewTraceResult("example.com", "93.184.216.34")
+	h := hop.NewHop(1)
+	h.AddTimeout()
+	tr.AddHop(h)
+
+	spans := decodeOTLP(t, tr)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Status == nil || spans[0].Status.Code != otlpStatusError {
+		t.Errorf("expected an error status on an unreached trace, got %+v", spans[0].Status)
+	}
+	if spans[1].Name != "hop 1" || spans[1].Status == nil || spans[1].Status.Message != "no reply" {
+		t.Errorf("unexpected silent hop span: %+v", spans[1])
+	}
+	if otlpAttr(spans[1], "gtrace.hop.ip") != nil {
+		t.Error("silent hop should have no IP attribute")
+	}
+}
+
+func TestOTLPExporter_OneTracePerResult(t *testing.T) {
+	spans := decodeOTLP(t, createTestTrace(), createTestTrace())
+	if len(spans) != 6 {
+		t.Fatalf("expected 6 spans, got %d", len(spans))
+	}
+	if spans[0].TraceID == spans[3].TraceID {
+		t.Error("expected a separate trace ID per result")
+	}
+}
+
+func TestSendOTLP(t *testing.T) {
+	var path, auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20secret")
+	if err := SendOTLP(context.Background(), srv.URL, []*hop.TraceResult{createTestTrace()}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/traces" {
+		t.Errorf("path = %q, want /v1/traces", path)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer secret")
+	}
+	if !json.Valid(body) {
+		t.Errorf("body is not JSON: %s", body)
+	}
+}
+
+func TestSendOTLP_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if err := SendOTLP(context.Background(), srv.URL+"/custom/traces", []*hop.TraceResult{createTestTrace()}); err == nil {
+		t.Error("expected an error for a 503 response")
+	}
+}
@@ -0,0 +1,216 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// ScamperTrace is a trace in scamper's JSON format, as written by
+// sc_warts2json, so results can be fed to tools built around scamper.
+type ScamperTrace struct {
+	Type       string       `json:"type"`
+	Version    string       `json:"version"`
+	Method     string       `json:"method"`
+	Dst        string       `json:"dst"`
+	StopReason string       `json:"stop_reason"`
+	StopData   int          `json:"stop_data"`
+	Start      ScamperTime  `json:"start"`
+	HopCount   int          `json:"hop_count"`
+	Attempts   int          `json:"attempts"`
+	HopLimit   int          `json:"hoplimit"`
+	FirstHop   int          `json:"firsthop"`
+	ProbeCount int          `json:"probe_count"`
+	Hops       []ScamperHop `json:"hops,omitempty"`
+}
+
+// ScamperTime is a timestamp in scamper's JSON format.
+type ScamperTime struct {
+	Sec   int64  `json:"sec"`
+	Usec  int64  `json:"usec"`
+	Ftime string `json:"ftime"`
+}
+
+// ScamperHop is one reply to a probe. Unlike gtrace hops, scamper lists
+// only the probes that got a reply, one entry each.
+type ScamperHop struct {
+	Addr     string           `json:"addr"`
+	Name     string           `json:"name,omitempty"`
+	ProbeTTL int              `json:"probe_ttl"`
+	ProbeID  int              `json:"probe_id"`
+	RTT      float64          `json:"rtt"`
+	ReplyTTL int              `json:"reply_ttl,omitempty"`
+	ICMPType *int             `json:"icmp_type,omitempty"`
+	ICMPCode *int             `json:"icmp_code,omitempty"`
+	ICMPQTTL int              `json:"icmp_q_ttl,omitempty"`
+	ICMPExt  []ScamperICMPExt `json:"icmpext,omitempty"`
+}
+
+// ScamperICMPExt is an ICMP extension object (RFC 4884); gtrace records
+// MPLS label stacks (class 1, type 1).
+type ScamperICMPExt struct {
+	ClassNum   int                `json:"ie_cn"`
+	ClassType  int                `json:"ie_ct"`
+	DataLength int                `json:"ie_dl"`
+	MPLSLabels []ScamperMPLSLabel `json:"mpls_labels"`
+}
+
+// ScamperMPLSLabel is an MPLS label stack entry.
+type ScamperMPLSLabel struct {
+	TTL   uint8  `json:"mpls_ttl"`
+	S     int    `json:"mpls_s"`
+	Exp   uint8  `json:"mpls_exp"`
+	Label uint32 `json:"mpls_label"`
+}
+
+// ScamperExporter exports trace results in scamper's JSON format.
+type ScamperExporter struct{}
+
+// NewScamperExporter creates a new scamper exporter.
+func NewScamperExporter() *ScamperExporter {
+	return &ScamperExporter{}
+}
+
+// Export writes the trace result as a scamper trace object on one line.
+func (e *ScamperExporter) Export(w io.Writer, tr *hop.TraceResult) error {
+	return e.ExportAll(w, []*hop.TraceResult{tr})
+}
+
+// ExportAll writes one scamper trace object per line, like sc_warts2json.
+func (e *ScamperExporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	enc := json.NewEncoder(w)
+	for _, tr := range results {
+		if err := enc.Encode(e.convert(tr)); err != nil {
+			return fmt.Errorf("failed to encode trace: %w", err)
+		}
+	}
+	return nil
+}
+
+// convert transforms a TraceResult to a ScamperTrace.
+func (e *ScamperExporter) convert(tr *hop.TraceResult) *ScamperTrace {
+	st := &ScamperTrace{
+		Type:       "trace",
+		Version:    "0.1",
+		Method:     scamperMethod(tr.Protocol),
+		Dst:        tr.TargetIP,
+		StopReason: scamperStopReason(tr),
+		FirstHop:   1,
+	}
+	if !tr.StartTime.IsZero() {
+		st.Start = ScamperTime{
+			Sec:   tr.StartTime.Unix(),
+			Usec:  int64(tr.StartTime.Nanosecond() / 1000),
+			Ftime: tr.StartTime.Format("2006-01-02 15:04:05"),
+		}
+	}
+
+	for i, h := range tr.Hops {
+		if i == 0 {
+			st.FirstHop = h.TTL
+		}
+		st.HopCount = max(st.HopCount, h.TTL)
+		st.Attempts = max(st.Attempts, len(h.Probes))
+		st.ProbeCount += len(h.Probes)
+
+		for j, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				continue
+			}
+			st.Hops = append(st.Hops, e.convertReply(tr, h, j, p))
+		}
+	}
+
+	return st
+}
+
+// convertReply transforms the reply to the probe-th probe of a hop.
+func (e *ScamperExporter) convertReply(tr *hop.TraceResult, h *hop.Hop, probe int, p hop.Probe) ScamperHop {
+	addr := p.IP.String()
+	sh := ScamperHop{
+		Addr:     addr,
+		ProbeTTL: h.TTL,
+		ProbeID:  probe + 1,
+		RTT:      math.Round(float64(p.RTT)/float64(time.Microsecond)) / 1000,
+		ReplyTTL: p.ResponseTTL,
+		ICMPQTTL: max(p.OriginalTTL, 0),
+	}
+	if primary := h.PrimaryIP(); primary != nil && primary.Equal(p.IP) {
+		sh.Name = h.Enrichment.Hostname
+	}
+
+	switch {
+	case p.ICMPType > 0:
+		sh.ICMPType, sh.ICMPCode = intPtr(p.ICMPType), intPtr(p.ICMPCode)
+	case tr.Protocol == "icmp" && addr == tr.TargetIP:
+		// Echo reply from the target
+		echoReply := 0
+		if p.IP.To4() == nil {
+			echoReply = 129
+		}
+		sh.ICMPType, sh.ICMPCode = intPtr(echoReply), intPtr(0)
+	}
+
+	if len(h.MPLS) > 0 {
+		ext := ScamperICMPExt{ClassNum: 1, ClassType: 1, DataLength: 4 * len(h.MPLS)}
+		for _, m := range h.MPLS {
+			s := 0
+			if m.S {
+				s = 1
+			}
+			ext.MPLSLabels = append(ext.MPLSLabels, ScamperMPLSLabel{TTL: m.TTL, S: s, Exp: m.Exp, Label: m.Label})
+		}
+		sh.ICMPExt = []ScamperICMPExt{ext}
+	}
+
+	return sh
+}
+
+// scamperMethod maps a gtrace protocol to a scamper trace method.
+func scamperMethod(protocol string) string {
+	switch protocol {
+	case "udp", "quic":
+		return "udp"
+	case "tcp":
+		return "tcp"
+	default:
+		return "icmp-echo"
+	}
+}
+
+// scamperStopReason tells why the trace stopped, in scamper's terms:
+// COMPLETED when the target answered, UNREACH on a Destination Unreachable
+// from a router, GAPLIMIT when it ended in silence, HOPLIMIT otherwise.
+func scamperStopReason(tr *hop.TraceResult) string {
+	if tr.ReachedTarget {
+		return "COMPLETED"
+	}
+	if len(tr.Hops) == 0 {
+		return "NONE"
+	}
+	last := tr.Hops[len(tr.Hops)-1]
+	if last.PrimaryIP() == nil {
+		return "GAPLIMIT"
+	}
+	for _, p := range last.Probes {
+		if p.Timeout || p.IP == nil {
+			continue
+		}
+		unreachable := 3
+		if p.IP.To4() == nil {
+			unreachable = 1
+		}
+		if p.ICMPType == unreachable && p.IP.String() != tr.TargetIP {
+			return "UNREACH"
+		}
+	}
+	return "HOPLIMIT"
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestScamperExporter_Export(t *testing.T) {
+	tr := createTestTrace()
+	tr.TargetIP = "10.0.0.1"
+	tr.StartTime = time.Unix(1700000000, 250000000)
+	tr.Hops[1].MPLS = []hop.MPLSLabel{{Label: 24005, Exp: 0, S: true, TTL: 1}}
+
+	var buf bytes.Buffer
+	if err := NewScamperExporter().Export(&buf, tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var st ScamperTrace
+	if err := json.Unmarshal(buf.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if st.Type != "trace" || st.Method != "icmp-echo" || st.Dst != "10.0.0.1" || st.StopReason != "COMPLETED" {
+		t.Errorf("unexpected trace header: %+v", st)
+	}
+	if st.Start.Sec != 1700000000 || st.Start.Usec != 250000 {
+		t.Errorf("unexpected start time: %+v", st.Start)
+	}
+	if st.HopCount != 2 || st.Attempts != 3 || st.ProbeCount != 6 {
+		t.Errorf("expected hop_count 2, attempts 3, probe_count 6, got %d, %d, %d", st.HopCount, st.Attempts, st.ProbeCount)
+	}
+
+	// 5 replies: the timeout at TTL 2 (probe 2) is not listed
+	if len(st.Hops) != 5 {
+		t.Fatalf("expected 5 replies, got %d", len(st.Hops))
+	}
+	last := st.Hops[4]
+	if last.Addr != "10.0.0.1" || last.ProbeTTL != 2 || last.ProbeID != 3 || last.RTT != 6 {
+		t.Errorf("unexpected reply: %+v", last)
+	}
+	if last.Name != "router.test.com" {
+		t.Errorf("expected hostname, got %q", last.Name)
+	}
+	if last.ICMPType == nil || *last.ICMPType != 0 {
+		t.Errorf("expected echo reply from the target, got %v", last.ICMPType)
+	}
+	if len(last.ICMPExt) != 1 || last.ICMPExt[0].MPLSLabels[0].Label != 24005 || last.ICMPExt[0].MPLSLabels[0].S != 1 {
+		t.Errorf("expected MPLS extension, got %+v", last.ICMPExt)
+	}
+	if st.Hops[0].ICMPType != nil {
+		t.Errorf("expected no ICMP type when unknown, got %v", *st.Hops[0].ICMPType)
+	}
+}
+
+func TestScamperExporter_ExportAllWritesOnePerLine(t *testing.T) {
+	a := createTestTrace()
+	b := createTestTrace()
+	b.TargetIP = "1.1.1.1"
+
+	var buf bytes.Buffer
+	if err := NewScamperExporter().ExportAll(&buf, []*hop.TraceResult{a, b}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var dsts []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var st ScamperTrace
+		if err := json.Unmarshal(scanner.Bytes(), &st); err != nil {
+			t.Fatalf("invalid JSON line: %v", err)
+		}
+		dsts = append(dsts, st.Dst)
+	}
+	if len(dsts) != 2 || dsts[1] != "1.1.1.1" {
+		t.Errorf("expected 2 traces, got %v", dsts)
+	}
+}
+
+func TestScamperStopReason(t *testing.T) {
+	unreach := hop.NewHop(3)
+	unreach.Probes = []hop.Probe{{IP: net.ParseIP("10.9.9.9"), RTT: time.Millisecond, ICMPType: 3, ICMPCode: 1}}
+	silent := hop.NewHop(3)
+	silent.AddTimeout()
+	router := hop.NewHop(3)
+	router.AddProbe(net.ParseIP("10.9.9.9"), time.Millisecond)
+
+	tests := []struct {
+		name string
+		last *hop.Hop
+		want string
+	}{
+		{"unreachable", unreach, "UNREACH"},
+		{"silent", silent, "GAPLIMIT"},
+		{"hop limit", router, "HOPLIMIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := hop.NewTraceResult("example.com", "192.0.2.1")
+			tr.AddHop(tt.last)
+			if got := scamperStopReason(tr); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -25,6 +25,18 @@ func (e *TextExporter) Export(w io.Writer, tr *hop.TraceResult) error {
 	if tr.Source != "" {
 		fmt.Fprintf(w, "Source: %s\n", tr.Source)
 	}
+	if !tr.StartTime.IsZero() {
+		fmt.Fprintf(w, "Started: %s\n", tr.StartTime.Format(time.RFC3339))
+	}
+	if tr.TimestampSource != "" {
+		fmt.Fprintf(w, "Timestamps: %s\n", tr.TimestampSource)
+	}
+	if tr.Route != nil {
+		fmt.Fprintf(w, "Route: %s\n", tr.Route)
+	}
+	if tr.Overlay != nil {
+		fmt.Fprintf(w, "Overlay: %s (%s)\n", tr.Overlay.Kind, strings.Join(tr.Overlay.Evidence, ", "))
+	}
 	fmt.Fprintln(w, strings.Repeat("=", 70))
 	fmt.Fprintln(w)
 
@@ -44,7 +56,41 @@ func (e *TextExporter) Export(w io.Writer, tr *hop.TraceResult) error {
 	if !tr.StartTime.IsZero() && !tr.EndTime.IsZero() {
 		fmt.Fprintf(w, "Duration: %v\n", tr.EndTime.Sub(tr.StartTime).Round(time.Millisecond))
 	}
+	if fp := tr.TargetFingerprint(); fp != nil {
+		fmt.Fprintf(w, "Target fingerprint: %s\n", fp)
+		if os := fp.OS(); os != "" {
+			fmt.Fprintf(w, "  Likely OS: %s\n", os)
+		}
+		if fp.MSSClamped(strings.Contains(tr.TargetIP, ":")) {
+			fmt.Fprintf(w, "  MSS %d is below a 1500 byte MTU: clamped on the path or a smaller MTU at the target\n", fp.MSS)
+		}
+	}
+
+	// Where the latency accumulates
+	if segs := tr.LatencyByAS(); len(segs) > 0 {
+		fmt.Fprintln(w, "Latency by AS:")
+		for _, seg := range segs {
+			fmt.Fprintf(w, "  %s\n", seg)
+		}
+	}
+
+	return nil
+}
 
+// ExportAll writes several trace results as consecutive text reports.
+// When the traces started at different times, the spread is noted first.
+func (e *TextExporter) ExportAll(w io.Writer, results []*hop.TraceResult) error {
+	if skew := hop.MaxStartSkew(results); skew > 0 {
+		fmt.Fprintf(w, "Start time skew across traces: %v\n\n", skew.Round(time.Millisecond))
+	}
+	for i, tr := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := e.Export(w, tr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -66,6 +112,21 @@ func (e *TextExporter) writeHop(w io.Writer, h *hop.Hop) {
 		line += fmt.Sprintf(" [AS%d %s]", h.Enrichment.ASN, h.Enrichment.ASOrg)
 	}
 
+	// Internet Exchange peering LAN
+	if h.Enrichment.IX != "" {
+		line += fmt.Sprintf(" [IX:%s]", h.Enrichment.IX)
+	}
+
+	// IANA special-purpose range
+	if h.Enrichment.Special != "" {
+		line += fmt.Sprintf(" [SPECIAL:%s]", h.Enrichment.Special)
+	}
+
+	// Blocklist listings
+	for _, l := range h.Enrichment.Reputation {
+		line += " " + l.Tag()
+	}
+
 	fmt.Fprintln(w, line)
 
 	// Timings
@@ -87,6 +148,9 @@ func (e *TextExporter) writeHop(w io.Writer, h *hop.Hop) {
 	for _, m := range h.MPLS {
 		fmt.Fprintf(w, "    MPLS: %s\n", m.String())
 	}
+	if h.SegmentRouting != nil {
+		fmt.Fprintf(w, "    Segment routing: %s\n", h.SegmentRouting.String())
+	}
 
 	// Geo info
 	if h.Enrichment.City != "" || h.Enrichment.Country != "" {
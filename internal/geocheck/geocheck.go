@@ -0,0 +1,163 @@
+// Package geocheck flags hop geolocations that are physically impossible
+// given the measured round-trip times.
+package geocheck
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// FiberKmPerMs is the speed of light in optical fiber (about 2/3 of c).
+const FiberKmPerMs = 200.0
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances.
+const earthRadiusKm = 6371.0
+
+// Finding is a pair of consecutive geolocated hops whose locations are too
+// far apart for the measured RTTs. Packets from the source reach both hops,
+// so the round trip between them can't exceed the sum of their RTTs:
+// MinRTT > RTTs means at least one of the two geolocations is wrong.
+type Finding struct {
+	PrevTTL    int
+	TTL        int
+	PrevPlace  string
+	Place      string
+	DistanceKm float64
+	MinRTT     time.Duration // Round trip between the two locations at fiber speed
+	RTTs       time.Duration // Sum of the two hops' best RTTs
+}
+
+// String formats the finding for the report.
+func (f Finding) String() string {
+	return fmt.Sprintf("hop %d %s -> hop %d %s: %.0f km needs >= %.1fms RTT, measured %.1fms combined",
+		f.PrevTTL, f.PrevPlace, f.TTL, f.Place, f.DistanceKm, ms(f.MinRTT), ms(f.RTTs))
+}
+
+// Distance returns the great-circle distance in km between two coordinates.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// MinRTT returns the shortest possible round trip over distanceKm of fiber.
+func MinRTT(distanceKm float64) time.Duration {
+	return time.Duration(2 * distanceKm / FiberKmPerMs * float64(time.Millisecond))
+}
+
+// Checker validates hops as they arrive, in TTL order.
+type Checker struct {
+	prev     *hop.Hop
+	checked  int
+	findings []Finding
+}
+
+// NewChecker creates a checker.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Check compares h with the previous geolocated hop, marks h.GeoMismatch
+// and returns the finding when the pair is inconsistent. Hops without a
+// city-level location or a response are skipped.
+func (c *Checker) Check(h *hop.Hop) *Finding {
+	rtt, ok := bestRTT(h)
+	if !ok || !located(h) {
+		return nil
+	}
+	prev := c.prev
+	c.prev = h
+	if prev == nil {
+		return nil
+	}
+
+	prevRTT, _ := bestRTT(prev)
+	e, pe := h.Enrichment, prev.Enrichment
+	distance := Distance(pe.Latitude, pe.Longitude, e.Latitude, e.Longitude)
+	c.checked++
+
+	f := Finding{
+		PrevTTL:    prev.TTL,
+		TTL:        h.TTL,
+		PrevPlace:  place(pe),
+		Place:      place(e),
+		DistanceKm: distance,
+		MinRTT:     MinRTT(distance),
+		RTTs:       prevRTT + rtt,
+	}
+	if f.MinRTT <= f.RTTs {
+		return nil
+	}
+	h.GeoMismatch = true
+	c.findings = append(c.findings, f)
+	return &f
+}
+
+// Findings returns the inconsistent pairs found so far.
+func (c *Checker) Findings() []Finding {
+	return c.findings
+}
+
+// Checked returns the number of hop pairs compared.
+func (c *Checker) Checked() int {
+	return c.checked
+}
+
+// WriteReport writes the --geo-validate report section.
+func (c *Checker) WriteReport(w io.Writer) {
+	fmt.Fprintf(w, "\nGeolocation check (speed of light in fiber, %.0f km/ms):\n", FiberKmPerMs)
+	if c.checked == 0 {
+		fmt.Fprintln(w, "  Not enough geolocated hops to compare")
+		return
+	}
+	if len(c.findings) == 0 {
+		fmt.Fprintf(w, "  All %d geolocated hop pairs are consistent with the measured RTTs\n", c.checked)
+		return
+	}
+	for _, f := range c.findings {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
+	fmt.Fprintf(w, "  %d of %d geolocated hop pairs are impossible; one location in each pair is likely wrong\n",
+		len(c.findings), c.checked)
+}
+
+// bestRTT returns the lowest RTT of the hop's responding probes.
+func bestRTT(h *hop.Hop) (time.Duration, bool) {
+	var best time.Duration
+	found := false
+	for _, p := range h.Probes {
+		if p.Timeout {
+			continue
+		}
+		if !found || p.RTT < best {
+			best, found = p.RTT, true
+		}
+	}
+	return best, found
+}
+
+// located reports whether the hop has a city-level location. Country-level
+// results point at a centroid and would produce false positives.
+func located(h *hop.Hop) bool {
+	e := h.Enrichment
+	return e.City != "" && (e.Latitude != 0 || e.Longitude != 0)
+}
+
+// place formats a hop location for the report.
+func place(e hop.Enrichment) string {
+	if e.Country == "" {
+		return e.City
+	}
+	return e.City + ", " + e.Country
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,114 @@
+package geocheck
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func locatedHop(ttl int, rtt time.Duration, city, country string, lat, lon float64) *hop.Hop {
+	h := hop.NewHop(ttl)
+	h.AddProbe(net.ParseIP("192.0.2.1"), rtt+time.Millisecond)
+	h.AddProbe(net.ParseIP("192.0.2.1"), rtt)
+	h.SetEnrichment(hop.Enrichment{City: city, Country: country, Latitude: lat, Longitude: lon})
+	return h
+}
+
+func TestDistance(t *testing.T) {
+	// Frankfurt to Tokyo is about 9,350 km
+	d := Distance(50.11, 8.68, 35.68, 139.69)
+	if math.Abs(d-9350) > 50 {
+		t.Errorf("Distance(Frankfurt, Tokyo) = %.0f km, want ~9350", d)
+	}
+	if d := Distance(50.11, 8.68, 50.11, 8.68); d != 0 {
+		t.Errorf("Distance to self = %f, want 0", d)
+	}
+}
+
+func TestMinRTT(t *testing.T) {
+	if got := MinRTT(1000); got != 10*time.Millisecond {
+		t.Errorf("MinRTT(1000 km) = %v, want 10ms", got)
+	}
+}
+
+func TestChecker_FlagsImpossibleHop(t *testing.T) {
+	c := NewChecker()
+	frankfurt := locatedHop(4, 10*time.Millisecond, "Frankfurt", "DE", 50.11, 8.68)
+	tokyo := locatedHop(5, 12*time.Millisecond, "Tokyo", "JP", 35.68, 139.69)
+
+	if f := c.Check(frankfurt); f != nil {
+		t.Fatalf("expected no finding for the first hop, got %+v", f)
+	}
+	f := c.Check(tokyo)
+	if f == nil {
+		t.Fatal("expected Frankfurt -> Tokyo in 22ms combined RTT to be flagged")
+	}
+	if !tokyo.GeoMismatch || frankfurt.GeoMismatch {
+		t.Error("expected only the later hop to be marked")
+	}
+	if f.PrevTTL != 4 || f.TTL != 5 || f.RTTs != 22*time.Millisecond {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+	if !strings.Contains(f.String(), "hop 4 Frankfurt, DE -> hop 5 Tokyo, JP") {
+		t.Errorf("unexpected finding text: %s", f)
+	}
+}
+
+func TestChecker_AcceptsFeasibleHops(t *testing.T) {
+	c := NewChecker()
+	c.Check(locatedHop(4, 10*time.Millisecond, "Frankfurt", "DE", 50.11, 8.68))
+	// Amsterdam is ~360 km away: needs 3.6ms, far below the combined RTTs
+	if f := c.Check(locatedHop(5, 12*time.Millisecond, "Amsterdam", "NL", 52.37, 4.90)); f != nil {
+		t.Errorf("expected no finding, got %+v", f)
+	}
+	if c.Checked() != 1 || len(c.Findings()) != 0 {
+		t.Errorf("expected 1 consistent pair, got checked=%d findings=%d", c.Checked(), len(c.Findings()))
+	}
+}
+
+func TestChecker_SkipsUnlocatedAndTimeouts(t *testing.T) {
+	c := NewChecker()
+	c.Check(locatedHop(1, 10*time.Millisecond, "Frankfurt", "DE", 50.11, 8.68))
+
+	// Country-level only: skipped
+	countryOnly := hop.NewHop(2)
+	countryOnly.AddProbe(net.ParseIP("192.0.2.2"), time.Millisecond)
+	countryOnly.SetEnrichment(hop.Enrichment{Country: "JP", Latitude: 36, Longitude: 138})
+	c.Check(countryOnly)
+
+	// All timeouts: skipped
+	timeout := hop.NewHop(3)
+	timeout.AddTimeout()
+	timeout.Enrichment = hop.Enrichment{City: "Tokyo", Latitude: 35.68, Longitude: 139.69}
+	c.Check(timeout)
+
+	if c.Checked() != 0 {
+		t.Errorf("expected no pairs compared, got %d", c.Checked())
+	}
+	if countryOnly.GeoMismatch || timeout.GeoMismatch {
+		t.Error("expected skipped hops not to be marked")
+	}
+}
+
+func TestChecker_WriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewChecker()
+	c.WriteReport(&buf)
+	if !strings.Contains(buf.String(), "Not enough geolocated hops") {
+		t.Errorf("unexpected empty report: %s", buf.String())
+	}
+
+	c.Check(locatedHop(4, 10*time.Millisecond, "Frankfurt", "DE", 50.11, 8.68))
+	c.Check(locatedHop(5, 12*time.Millisecond, "Tokyo", "JP", 35.68, 139.69))
+	buf.Reset()
+	c.WriteReport(&buf)
+	out := buf.String()
+	if !strings.Contains(out, "Geolocation check") || !strings.Contains(out, "1 of 1 geolocated hop pairs are impossible") {
+		t.Errorf("unexpected report: %s", out)
+	}
+}
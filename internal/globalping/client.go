@@ -7,8 +7,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/logging"
 )
 
 const (
@@ -31,6 +35,9 @@ const (
 // RetryCallback is called when a retry is about to happen.
 type RetryCallback func(attempt int, delay time.Duration)
 
+// RateLimitCallback is called when a response carries rate-limit headers.
+type RateLimitCallback func(info RateLimitInfo)
+
 // Client is a GlobalPing API client.
 type Client struct {
 	baseURL       string
@@ -40,6 +47,18 @@ type Client struct {
 	retryDelay    time.Duration
 	maxRetries    int
 	retryCallback RetryCallback
+
+	rateLimitCallback RateLimitCallback
+	mu                sync.Mutex
+	rateLimit         RateLimitInfo
+	measurements      map[string]*cachedMeasurement // Last response per polled measurement
+}
+
+// cachedMeasurement is the last response seen for a polled measurement.
+type cachedMeasurement struct {
+	etag      string
+	body      []byte
+	unchanged int // Consecutive polls answered with 304 Not Modified
 }
 
 // NewClient creates a new GlobalPing API client.
@@ -53,6 +72,7 @@ func NewClient(apiKey string) *Client {
 		pollInterval: DefaultPollInterval,
 		retryDelay:   DefaultRetryDelay,
 		maxRetries:   DefaultMaxRetries,
+		measurements: make(map[string]*cachedMeasurement),
 	}
 }
 
@@ -61,6 +81,93 @@ func (c *Client) SetRetryCallback(cb RetryCallback) {
 	c.retryCallback = cb
 }
 
+// SetRateLimitCallback sets a callback to be called whenever a response
+// reports rate-limit or credit information.
+func (c *Client) SetRateLimitCallback(cb RateLimitCallback) {
+	c.rateLimitCallback = cb
+}
+
+// RateLimit returns the rate-limit information from the most recent
+// response that carried rate-limit headers.
+func (c *Client) RateLimit() RateLimitInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimit
+}
+
+// do sends a request and records any rate-limit headers in the response.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, ok := parseRateLimitHeaders(resp.Header); ok {
+		c.mu.Lock()
+		c.rateLimit = info
+		c.mu.Unlock()
+		if c.rateLimitCallback != nil {
+			c.rateLimitCallback(info)
+		}
+	}
+	return resp, nil
+}
+
+// parseRateLimitHeaders extracts rate-limit and credit headers.
+// Returns false if none of them are present.
+func parseRateLimitHeaders(h http.Header) (RateLimitInfo, bool) {
+	var info RateLimitInfo
+	found := false
+
+	intHeader := func(name string, dst *int) {
+		if v := h.Get(name); v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				*dst = n
+				found = true
+			}
+		}
+	}
+
+	var resetSecs int
+	intHeader("X-RateLimit-Limit", &info.Limit)
+	intHeader("X-RateLimit-Remaining", &info.Remaining)
+	intHeader("X-RateLimit-Reset", &resetSecs)
+	intHeader("X-Request-Cost", &info.RequestCost)
+	intHeader("X-Credits-Consumed", &info.CreditsConsumed)
+	intHeader("X-Credits-Remaining", &info.CreditsRemaining)
+	info.Reset = time.Duration(resetSecs) * time.Second
+
+	return info, found
+}
+
+// GetLimits retrieves the current rate limits and credits from /v1/limits.
+func (c *Client) GetLimits(ctx context.Context) (*Limits, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/limits", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(httpReq)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var limits Limits
+	if err := json.NewDecoder(resp.Body).Decode(&limits); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &limits, nil
+}
+
 // CreateMeasurement creates a new measurement.
 func (c *Client) CreateMeasurement(ctx context.Context, req *MeasurementRequest) (*MeasurementResponse, error) {
 	if err := req.Validate(); err != nil {
@@ -79,7 +186,7 @@ func (c *Client) CreateMeasurement(ctx context.Context, req *MeasurementRequest)
 
 	c.setHeaders(httpReq)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -94,6 +201,9 @@ func (c *Client) CreateMeasurement(ctx context.Context, req *MeasurementRequest)
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	result.RateLimit, _ = parseRateLimitHeaders(resp.Header)
+	logging.FromContext(ctx).Debug("measurement created", logging.MeasurementIDKey, result.ID,
+		"type", req.Type, "target", req.Target, "probes", result.ProbesCount)
 
 	return &result, nil
 }
@@ -122,15 +232,18 @@ func (c *Client) GetMeasurement(ctx context.Context, id string) (*MeasurementRes
 		}
 
 		// Notify callback about retry
+		delay := c.retryDelayFor(err)
+		logging.FromContext(ctx).Debug("measurement poll rate limited", logging.MeasurementIDKey, id,
+			"attempt", attempt+1, "retry_in", delay)
 		if c.retryCallback != nil {
-			c.retryCallback(attempt+1, c.retryDelay)
+			c.retryCallback(attempt+1, delay)
 		}
 
 		// Wait before retry
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-time.After(c.retryDelay):
+		case <-time.After(delay):
 			// Continue to retry
 		}
 	}
@@ -140,6 +253,22 @@ func (c *Client) GetMeasurement(ctx context.Context, id string) (*MeasurementRes
 
 // getMeasurementOnce performs a single measurement retrieval.
 func (c *Client) getMeasurementOnce(ctx context.Context, id string) (*MeasurementResult, error) {
+	body, err := c.fetchMeasurement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var result MeasurementResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// fetchMeasurement retrieves the raw JSON of a measurement. The ETag of the
+// last response is sent as If-None-Match, and a 304 Not Modified reply is
+// answered from the cached body.
+func (c *Client) fetchMeasurement(ctx context.Context, id string) ([]byte, error) {
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/measurements/"+id, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -147,29 +276,96 @@ func (c *Client) getMeasurementOnce(ctx context.Context, id string) (*Measuremen
 
 	c.setHeaders(httpReq)
 
-	resp, err := c.httpClient.Do(httpReq)
+	c.mu.Lock()
+	cached := c.measurements[id]
+	c.mu.Unlock()
+	if cached != nil && cached.etag != "" {
+		httpReq.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.mu.Lock()
+		cached.unchanged++
+		c.mu.Unlock()
+		return cached.body, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
-	var result MeasurementResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return &result, nil
+	c.mu.Lock()
+	c.measurements[id] = &cachedMeasurement{etag: resp.Header.Get("ETag"), body: body}
+	c.mu.Unlock()
+
+	return body, nil
+}
+
+// pollDelay returns how long to wait before polling a measurement again.
+// The delay doubles (up to 4x the poll interval) while polls report no change.
+func (c *Client) pollDelay(id string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backoff := 0
+	if cached := c.measurements[id]; cached != nil {
+		backoff = min(cached.unchanged, 2)
+	}
+	return c.pollInterval << backoff
+}
+
+// forgetMeasurement drops the cached response of a measurement.
+func (c *Client) forgetMeasurement(id string) {
+	c.mu.Lock()
+	delete(c.measurements, id)
+	c.mu.Unlock()
+}
+
+// retryDelayFor returns the delay before retrying after err, preferring the
+// server's Retry-After hint over the default retry delay.
+func (c *Client) retryDelayFor(err error) time.Duration {
+	if apiErr, ok := err.(*APIError); ok && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	return c.retryDelay
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+// Returns 0 if the header is missing or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
 }
 
 // APIError represents an API error response.
 type APIError struct {
 	StatusCode int
 	Body       string
+	RetryAfter time.Duration // Server-requested wait before retrying (0 if not given)
 }
 
 func (e *APIError) Error() string {
@@ -186,23 +382,40 @@ func isRateLimitError(err error) bool {
 
 // WaitForMeasurement polls until the measurement is complete.
 func (c *Client) WaitForMeasurement(ctx context.Context, id string) (*MeasurementResult, error) {
-	ticker := time.NewTicker(c.pollInterval)
-	defer ticker.Stop()
+	return c.WaitForMeasurementProgress(ctx, id, nil)
+}
+
+// WaitForMeasurementProgress polls until the measurement is complete, calling
+// onUpdate with each partial result that changed since the previous poll.
+// Requests must set InProgressUpdates for the API to report partial results.
+func (c *Client) WaitForMeasurementProgress(ctx context.Context, id string, onUpdate func(*MeasurementResult)) (*MeasurementResult, error) {
+	defer c.forgetMeasurement(id)
+	ctx = logging.With(ctx, logging.MeasurementIDKey, id)
+	log := logging.FromContext(ctx)
 
+	var lastUpdate time.Time
 	for {
 		result, err := c.GetMeasurement(ctx, id)
 		if err != nil {
+			log.Debug("measurement poll failed", "err", err)
 			return nil, err
 		}
 
 		if result.Status.IsComplete() {
+			log.Debug("measurement finished", "status", result.Status, "results", len(result.Results))
 			return result, nil
 		}
+		log.Debug("measurement in progress", "results", len(result.Results))
+
+		if onUpdate != nil && !result.UpdatedAt.Equal(lastUpdate) {
+			lastUpdate = result.UpdatedAt
+			onUpdate(result)
+		}
 
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-ticker.C:
+		case <-time.After(c.pollDelay(id)):
 			// Continue polling
 		}
 	}
@@ -229,7 +442,7 @@ func (c *Client) ListProbes(ctx context.Context, filter *ProbeFilter) ([]Probe,
 
 	c.setHeaders(httpReq)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -271,302 +484,112 @@ func filterProbes(probes []Probe, filter *ProbeFilter) []Probe {
 		if filter.Tag != "" && !containsTag(p.Tags, filter.Tag) {
 			continue
 		}
+		if len(filter.From) > 0 && !matchesAnyLocation(p, filter.From) {
+			continue
+		}
 		result = append(result, p)
 	}
 	return result
 }
 
-// containsTag checks if a tag list contains a specific tag (case-insensitive).
-func containsTag(tags []string, tag string) bool {
-	for _, t := range tags {
-		if strings.EqualFold(t, tag) {
+// matchesAnyLocation reports whether a probe would be selected by any of the
+// given measurement locations.
+func matchesAnyLocation(p Probe, locs []Location) bool {
+	for _, loc := range locs {
+		if matchesLocation(p, loc) {
 			return true
 		}
 	}
 	return false
 }
 
-// setHeaders sets common request headers.
-func (c *Client) setHeaders(req *http.Request) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	if c.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+// matchesLocation approximates GlobalPing's location matching client-side.
+// Magic locations are split on "+" and every term must match some probe field.
+func matchesLocation(p Probe, loc Location) bool {
+	if loc.Country != "" && !strings.EqualFold(p.Location.Country, loc.Country) {
+		return false
 	}
-}
-
-// GetMTRMeasurement retrieves the current state of an MTR measurement.
-// Retries on rate limit (429) errors.
-func (c *Client) GetMTRMeasurement(ctx context.Context, id string) (*MTRMeasurementResult, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
-		result, err := c.getMTRMeasurementOnce(ctx, id)
-		if err == nil {
-			return result, nil
-		}
-
-		// Check if it's a rate limit error
-		if !isRateLimitError(err) {
-			return nil, err
-		}
-
-		lastErr = err
-
-		// Don't retry if we've exhausted retries
-		if attempt >= c.maxRetries {
-			break
-		}
-
-		// Notify callback about retry
-		if c.retryCallback != nil {
-			c.retryCallback(attempt+1, c.retryDelay)
-		}
-
-		// Wait before retry
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(c.retryDelay):
-			// Continue to retry
-		}
-	}
-
-	return nil, lastErr
-}
-
-// getMTRMeasurementOnce performs a single MTR measurement retrieval.
-func (c *Client) getMTRMeasurementOnce(ctx context.Context, id string) (*MTRMeasurementResult, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/measurements/"+id, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if loc.City != "" && !strings.EqualFold(p.Location.City, loc.City) {
+		return false
 	}
-
-	c.setHeaders(httpReq)
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	if loc.Region != "" && !strings.EqualFold(p.Location.Region, loc.Region) {
+		return false
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	if loc.ASN != 0 && p.Location.ASN != loc.ASN {
+		return false
 	}
-
-	var result MTRMeasurementResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if loc.Network != "" && !strings.Contains(strings.ToLower(p.Location.Network), strings.ToLower(loc.Network)) {
+		return false
 	}
-
-	return &result, nil
-}
-
-// WaitForMTRMeasurement polls until the MTR measurement is complete.
-func (c *Client) WaitForMTRMeasurement(ctx context.Context, id string) (*MTRMeasurementResult, error) {
-	ticker := time.NewTicker(c.pollInterval)
-	defer ticker.Stop()
-
-	for {
-		result, err := c.GetMTRMeasurement(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-
-		if result.Status.IsComplete() {
-			return result, nil
+	for _, tag := range loc.Tags {
+		if !containsTag(p.Tags, tag) {
+			return false
 		}
-
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-ticker.C:
-			// Continue polling
+	}
+	if loc.Magic != "" {
+		for _, term := range strings.Split(loc.Magic, "+") {
+			if term = strings.TrimSpace(term); term != "" && !matchesMagicTerm(p, term) {
+				return false
+			}
 		}
 	}
+	return true
 }
 
-// RunMTRMeasurement creates an MTR measurement and waits for completion.
-func (c *Client) RunMTRMeasurement(ctx context.Context, req *MeasurementRequest) (*MTRMeasurementResult, error) {
-	// Ensure we're using MTR type
-	req.Type = MeasurementTypeMTR
-
-	resp, err := c.CreateMeasurement(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create measurement: %w", err)
-	}
-
-	return c.WaitForMTRMeasurement(ctx, resp.ID)
+// matchesMagicTerm checks a single magic term against the probe's location
+// fields, country name, ASN ("AS13335") and tags.
+func matchesMagicTerm(p Probe, term string) bool {
+	l := p.Location
+	if strings.EqualFold(l.Country, term) || strings.EqualFold(l.Continent, term) ||
+		strings.EqualFold(l.State, term) || strings.EqualFold(CountryName(l.Country), term) {
+		return true
+	}
+	if strings.EqualFold(term, fmt.Sprintf("AS%d", l.ASN)) || containsTag(p.Tags, term) {
+		return true
+	}
+	t := strings.ToLower(term)
+	return strings.Contains(strings.ToLower(l.City), t) ||
+		strings.Contains(strings.ToLower(l.Region), t) ||
+		strings.Contains(strings.ToLower(l.Network), t)
 }
 
-// GetPingMeasurement retrieves the current state of a ping measurement.
-func (c *Client) GetPingMeasurement(ctx context.Context, id string) (*PingMeasurementResult, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
-		result, err := c.getPingMeasurementOnce(ctx, id)
-		if err == nil {
-			return result, nil
-		}
-		if !isRateLimitError(err) {
-			return nil, err
-		}
-		lastErr = err
-		if attempt >= c.maxRetries {
-			break
-		}
-		if c.retryCallback != nil {
-			c.retryCallback(attempt+1, c.retryDelay)
-		}
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(c.retryDelay):
+// containsTag checks if a tag list contains a specific tag (case-insensitive).
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
 		}
 	}
-	return nil, lastErr
+	return false
 }
 
-func (c *Client) getPingMeasurementOnce(ctx context.Context, id string) (*PingMeasurementResult, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/measurements/"+id, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	c.setHeaders(httpReq)
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
-	}
+// setHeaders sets common request headers.
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
 
-	var result PingMeasurementResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
-	return &result, nil
 }
 
-// WaitForPingMeasurement polls until the ping measurement is complete.
-func (c *Client) WaitForPingMeasurement(ctx context.Context, id string) (*PingMeasurementResult, error) {
-	ticker := time.NewTicker(c.pollInterval)
-	defer ticker.Stop()
-
-	for {
-		result, err := c.GetPingMeasurement(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		if result.Status.IsComplete() {
-			return result, nil
-		}
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-ticker.C:
-		}
-	}
+// RunMTRMeasurement creates an MTR measurement and waits for completion.
+// Each probe result carries its payload in ProbeResult.MTR.
+func (c *Client) RunMTRMeasurement(ctx context.Context, req *MeasurementRequest) (*MeasurementResult, error) {
+	req.Type = MeasurementTypeMTR
+	return c.RunMeasurement(ctx, req)
 }
 
 // RunPingMeasurement creates a ping measurement and waits for completion.
-func (c *Client) RunPingMeasurement(ctx context.Context, req *MeasurementRequest) (*PingMeasurementResult, error) {
+// Each probe result carries its payload in ProbeResult.Ping.
+func (c *Client) RunPingMeasurement(ctx context.Context, req *MeasurementRequest) (*MeasurementResult, error) {
 	req.Type = MeasurementTypePing
-
-	resp, err := c.CreateMeasurement(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create measurement: %w", err)
-	}
-	return c.WaitForPingMeasurement(ctx, resp.ID)
-}
-
-// GetDNSMeasurement retrieves the current state of a DNS measurement.
-func (c *Client) GetDNSMeasurement(ctx context.Context, id string) (*DNSMeasurementResult, error) {
-	var lastErr error
-
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
-		result, err := c.getDNSMeasurementOnce(ctx, id)
-		if err == nil {
-			return result, nil
-		}
-		if !isRateLimitError(err) {
-			return nil, err
-		}
-		lastErr = err
-		if attempt >= c.maxRetries {
-			break
-		}
-		if c.retryCallback != nil {
-			c.retryCallback(attempt+1, c.retryDelay)
-		}
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(c.retryDelay):
-		}
-	}
-	return nil, lastErr
-}
-
-func (c *Client) getDNSMeasurementOnce(ctx context.Context, id string) (*DNSMeasurementResult, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/measurements/"+id, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	c.setHeaders(httpReq)
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
-	}
-
-	var result DNSMeasurementResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-	return &result, nil
-}
-
-// WaitForDNSMeasurement polls until the DNS measurement is complete.
-func (c *Client) WaitForDNSMeasurement(ctx context.Context, id string) (*DNSMeasurementResult, error) {
-	ticker := time.NewTicker(c.pollInterval)
-	defer ticker.Stop()
-
-	for {
-		result, err := c.GetDNSMeasurement(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		if result.Status.IsComplete() {
-			return result, nil
-		}
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-ticker.C:
-		}
-	}
+	return c.RunMeasurement(ctx, req)
 }
 
 // RunDNSMeasurement creates a DNS measurement and waits for completion.
-func (c *Client) RunDNSMeasurement(ctx context.Context, req *MeasurementRequest) (*DNSMeasurementResult, error) {
+// Each probe result carries its payload in ProbeResult.DNS.
+func (c *Client) RunDNSMeasurement(ctx context.Context, req *MeasurementRequest) (*MeasurementResult, error) {
 	req.Type = MeasurementTypeDNS
-
-	resp, err := c.CreateMeasurement(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create measurement: %w", err)
-	}
-	return c.WaitForDNSMeasurement(ctx, resp.ID)
+	return c.RunMeasurement(ctx, req)
 }
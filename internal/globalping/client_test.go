@@ -1,12 +1,16 @@
 package globalping
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/logging"
 )
 
 func TestNewClient_CreatesClientWithDefaults(t *testing.T) {
@@ -89,8 +93,8 @@ func TestClient_CreateMeasurement_IncludesAPIKey(t *testing.T) {
 	client.baseURL = server.URL
 
 	req := &MeasurementRequest{
-		Type:   MeasurementTypeTraceroute,
-		Target: "google.com",
+		Type:      MeasurementTypeTraceroute,
+		Target:    "google.com",
 		Locations: []Location{{Magic: "London"}},
 	}
 
@@ -113,11 +117,12 @@ func TestClient_GetMeasurement_ReturnsResult(t *testing.T) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(MeasurementResult{
 			ID:     "test-id",
+			Type:   MeasurementTypeTraceroute,
 			Status: StatusFinished,
 			Results: []ProbeResult{
 				{
 					Probe: ProbeInfo{City: "London", Country: "GB"},
-					Result: TracerouteResult{
+					Traceroute: &TracerouteResult{
 						Status:          "finished",
 						ResolvedAddress: "8.8.8.8",
 						Hops: []TracerouteHop{
@@ -154,6 +159,9 @@ func TestClient_GetMeasurement_ReturnsResult(t *testing.T) {
 	if len(result.Results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(result.Results))
 	}
+	if result.Results[0].Traceroute == nil || len(result.Results[0].Traceroute.Hops) != 1 {
+		t.Fatalf("expected traceroute payload with 1 hop, got %+v", result.Results[0])
+	}
 }
 
 func TestClient_WaitForMeasurement_PollsUntilComplete(t *testing.T) {
@@ -191,6 +199,29 @@ func TestClient_WaitForMeasurement_PollsUntilComplete(t *testing.T) {
 	}
 }
 
+func TestClient_WaitForMeasurement_LogsMeasurementID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MeasurementResult{ID: "test-id", Status: StatusFinished})
+	}))
+	defer server.Close()
+
+	client := NewClient("")
+	client.baseURL = server.URL
+
+	var buf bytes.Buffer
+	l, _ := logging.New(&buf, logging.FormatText, true)
+	ctx := logging.With(logging.NewContext(context.Background(), l), logging.TraceIDKey, "abc123")
+	if _, err := client.WaitForMeasurement(ctx, "test-id"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "measurement finished") || !strings.Contains(out, "trace_id=abc123 measurement_id=test-id") {
+		t.Errorf("expected the measurement logged with both IDs, got %q", out)
+	}
+}
+
 func TestClient_WaitForMeasurement_RespectsContext(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -215,21 +246,21 @@ func TestClient_WaitForMeasurement_RespectsContext(t *testing.T) {
 	}
 }
 
-func TestClient_GetMTRMeasurement_ReturnsResult(t *testing.T) {
+func TestClient_GetMeasurement_DecodesMTRResult(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			t.Errorf("expected GET, got %s", r.Method)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(MTRMeasurementResult{
+		json.NewEncoder(w).Encode(MeasurementResult{
 			ID:     "test-mtr-id",
 			Type:   MeasurementTypeMTR,
 			Status: StatusFinished,
-			Results: []MTRProbeResult{
+			Results: []ProbeResult{
 				{
 					Probe: ProbeInfo{City: "London", Country: "GB"},
-					Result: MTRResult{
+					MTR: &MTRResult{
 						Status:          "finished",
 						ResolvedAddress: "8.8.8.8",
 						Hops: []MTRHop{
@@ -258,7 +289,7 @@ func TestClient_GetMTRMeasurement_ReturnsResult(t *testing.T) {
 	client := NewClient("")
 	client.baseURL = server.URL
 
-	result, err := client.GetMTRMeasurement(context.Background(), "test-mtr-id")
+	result, err := client.GetMeasurement(context.Background(), "test-mtr-id")
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -272,44 +303,11 @@ func TestClient_GetMTRMeasurement_ReturnsResult(t *testing.T) {
 	if len(result.Results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(result.Results))
 	}
-	if len(result.Results[0].Result.Hops) != 1 {
-		t.Fatalf("expected 1 hop, got %d", len(result.Results[0].Result.Hops))
-	}
-}
-
-func TestClient_WaitForMTRMeasurement_PollsUntilComplete(t *testing.T) {
-	calls := 0
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
-		status := StatusInProgress
-		if calls >= 2 {
-			status = StatusFinished
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(MTRMeasurementResult{
-			ID:     "test-mtr-id",
-			Type:   MeasurementTypeMTR,
-			Status: status,
-		})
-	}))
-	defer server.Close()
-
-	client := NewClient("")
-	client.baseURL = server.URL
-	client.pollInterval = 10 * time.Millisecond
-
-	result, err := client.WaitForMTRMeasurement(context.Background(), "test-mtr-id")
-
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if result.Status != StatusFinished {
-		t.Errorf("expected status 'finished', got %q", result.Status)
+	if result.Results[0].MTR == nil || result.Results[0].Traceroute != nil {
+		t.Fatalf("expected only the MTR payload to be set, got %+v", result.Results[0])
 	}
-	if calls < 2 {
-		t.Errorf("expected at least 2 calls, got %d", calls)
+	if len(result.Results[0].MTR.Hops) != 1 {
+		t.Fatalf("expected 1 hop, got %d", len(result.Results[0].MTR.Hops))
 	}
 }
 
@@ -580,3 +578,256 @@ func TestClient_GetMeasurement_FailsAfterMaxRetries(t *testing.T) {
 		t.Errorf("expected 4 calls (1 initial + 3 retries), got %d", calls)
 	}
 }
+
+func TestClient_ListProbes_FiltersByFromLocation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]Probe{
+			{Location: ProbeLocation{Country: "DE", City: "Frankfurt", ASN: 16509, Network: "Amazon.com"}, Tags: []string{"aws-eu-central-1"}},
+			{Location: ProbeLocation{Country: "DE", City: "Berlin", ASN: 3320, Network: "Deutsche Telekom"}},
+			{Location: ProbeLocation{Country: "US", City: "Ashburn", ASN: 13335, Network: "Cloudflare"}},
+			{Location: ProbeLocation{Country: "JP", City: "Tokyo", ASN: 2497, Network: "IIJ"}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient("")
+	client.baseURL = server.URL
+
+	tests := []struct {
+		from string
+		want int
+	}{
+		{"Germany", 2},
+		{"Germany+Telekom", 1},
+		{"aws-eu-central-1", 1},
+		{"Germany;AS13335", 3},
+		{"country:JP", 1},
+		{"Atlantis", 0},
+	}
+	for _, tt := range tests {
+		probes, err := client.ListProbes(context.Background(), &ProbeFilter{From: ParseLocationStrings(tt.from)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(probes) != tt.want {
+			t.Errorf("from %q: expected %d probes, got %d", tt.from, tt.want, len(probes))
+		}
+	}
+}
+
+func TestClient_CreateMeasurement_ParsesRateLimitHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Limit", "250")
+		w.Header().Set("X-RateLimit-Remaining", "20")
+		w.Header().Set("X-RateLimit-Reset", "1800")
+		w.Header().Set("X-Request-Cost", "3")
+		json.NewEncoder(w).Encode(MeasurementResponse{ID: "id"})
+	}))
+	defer server.Close()
+
+	var callbackInfo RateLimitInfo
+	client := NewClient("")
+	client.baseURL = server.URL
+	client.SetRateLimitCallback(func(info RateLimitInfo) { callbackInfo = info })
+
+	req := &MeasurementRequest{
+		Type:      MeasurementTypeTraceroute,
+		Target:    "google.com",
+		Locations: []Location{{Magic: "London"}},
+	}
+	resp, err := client.CreateMeasurement(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := RateLimitInfo{Limit: 250, Remaining: 20, Reset: 30 * time.Minute, RequestCost: 3}
+	if resp.RateLimit != want {
+		t.Errorf("expected response rate limit %+v, got %+v", want, resp.RateLimit)
+	}
+	if client.RateLimit() != want {
+		t.Errorf("expected client rate limit %+v, got %+v", want, client.RateLimit())
+	}
+	if callbackInfo != want {
+		t.Errorf("expected callback rate limit %+v, got %+v", want, callbackInfo)
+	}
+	if !resp.RateLimit.IsLow() {
+		t.Error("20/250 remaining should be reported as low")
+	}
+}
+
+func TestRateLimitInfo_IsLow(t *testing.T) {
+	tests := []struct {
+		info RateLimitInfo
+		want bool
+	}{
+		{RateLimitInfo{}, false},
+		{RateLimitInfo{Limit: 250, Remaining: 200}, false},
+		{RateLimitInfo{Limit: 250, Remaining: 5}, true},
+		{RateLimitInfo{Limit: 250, Remaining: 0, CreditsRemaining: 500}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.info.IsLow(); got != tt.want {
+			t.Errorf("%+v: expected IsLow %v, got %v", tt.info, tt.want, got)
+		}
+	}
+}
+
+func TestClient_GetLimits_DecodesResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/limits" {
+			t.Errorf("expected /v1/limits, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"rateLimit":{"measurements":{"create":{"type":"user","limit":500,"remaining":480,"reset":600}}},"credits":{"remaining":1000}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key")
+	client.baseURL = server.URL
+
+	limits, err := client.GetLimits(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	create := limits.RateLimit.Measurements.Create
+	if create.Type != "user" || create.Limit != 500 || create.Remaining != 480 || create.Reset != 600 {
+		t.Errorf("unexpected create limit: %+v", create)
+	}
+	if limits.Credits == nil || limits.Credits.Remaining != 1000 {
+		t.Errorf("expected 1000 credits, got %+v", limits.Credits)
+	}
+}
+
+func TestClient_WaitForMeasurement_UsesETag(t *testing.T) {
+	var polls, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		w.Header().Set("ETag", `"v1"`)
+		if polls < 3 && r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		status := StatusInProgress
+		if polls >= 3 {
+			status = StatusFinished
+		}
+		json.NewEncoder(w).Encode(MeasurementResult{ID: "m1", Status: status})
+	}))
+	defer server.Close()
+
+	client := NewClient("")
+	client.baseURL = server.URL
+	client.pollInterval = time.Millisecond
+
+	result, err := client.WaitForMeasurement(context.Background(), "m1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != StatusFinished {
+		t.Errorf("expected finished status, got %s", result.Status)
+	}
+	if notModified != 1 {
+		t.Errorf("expected 1 conditional poll answered with 304, got %d", notModified)
+	}
+	if len(client.measurements) != 0 {
+		t.Error("expected measurement cache to be cleared after completion")
+	}
+}
+
+func TestClient_PollDelay_BacksOffWhileUnchanged(t *testing.T) {
+	client := NewClient("")
+	client.pollInterval = 100 * time.Millisecond
+
+	if got := client.pollDelay("m1"); got != 100*time.Millisecond {
+		t.Errorf("expected base interval, got %v", got)
+	}
+	client.measurements["m1"] = &cachedMeasurement{unchanged: 1}
+	if got := client.pollDelay("m1"); got != 200*time.Millisecond {
+		t.Errorf("expected 2x interval, got %v", got)
+	}
+	client.measurements["m1"].unchanged = 10
+	if got := client.pollDelay("m1"); got != 400*time.Millisecond {
+		t.Errorf("expected backoff capped at 4x, got %v", got)
+	}
+}
+
+func TestClient_GetMeasurement_HonorsRetryAfter(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(MeasurementResult{ID: "m1", Status: StatusFinished})
+	}))
+	defer server.Close()
+
+	client := NewClient("")
+	client.baseURL = server.URL
+
+	var retryDelay time.Duration
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancel as soon as the retry is scheduled; only the delay matters here
+	client.SetRetryCallback(func(attempt int, delay time.Duration) {
+		retryDelay = delay
+		cancel()
+	})
+
+	_, _ = client.GetMeasurement(ctx, "m1")
+
+	if retryDelay != 2*time.Second {
+		t.Errorf("expected Retry-After delay of 2s, got %v", retryDelay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"garbage", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestClient_WaitForMeasurementProgress_ReportsChangedPartials(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	updates := []time.Time{base, base, base.Add(time.Second)}
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if polls < len(updates) {
+			json.NewEncoder(w).Encode(MeasurementResult{ID: "m1", Status: StatusInProgress, UpdatedAt: updates[polls]})
+		} else {
+			json.NewEncoder(w).Encode(MeasurementResult{ID: "m1", Status: StatusFinished})
+		}
+		polls++
+	}))
+	defer server.Close()
+
+	client := NewClient("")
+	client.baseURL = server.URL
+	client.pollInterval = time.Millisecond
+
+	var calls int
+	if _, err := client.WaitForMeasurementProgress(context.Background(), "m1", func(*MeasurementResult) { calls++ }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 progress updates for 2 distinct partial states, got %d", calls)
+	}
+}
@@ -9,21 +9,21 @@ import (
 	"time"
 )
 
-func TestClient_GetDNSMeasurement_ReturnsResult(t *testing.T) {
+func TestClient_GetMeasurement_DecodesDNSResult(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/measurements/dns-id" {
 			t.Errorf("expected /v1/measurements/dns-id, got %s", r.URL.Path)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(DNSMeasurementResult{
+		json.NewEncoder(w).Encode(MeasurementResult{
 			ID:     "dns-id",
 			Type:   MeasurementTypeDNS,
 			Status: StatusFinished,
-			Results: []DNSProbeResult{
+			Results: []ProbeResult{
 				{
 					Probe: ProbeInfo{City: "Paris", Country: "FR"},
-					Result: DNSResult{
+					DNS: &DNSResult{
 						Status:         "finished",
 						StatusCode:     0,
 						StatusCodeName: "NOERROR",
@@ -42,7 +42,7 @@ func TestClient_GetDNSMeasurement_ReturnsResult(t *testing.T) {
 	client := NewClient("")
 	client.baseURL = server.URL
 
-	result, err := client.GetDNSMeasurement(context.Background(), "dns-id")
+	result, err := client.GetMeasurement(context.Background(), "dns-id")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -55,43 +55,8 @@ func TestClient_GetDNSMeasurement_ReturnsResult(t *testing.T) {
 	if len(result.Results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(result.Results))
 	}
-	if len(result.Results[0].Result.Answers) != 1 {
-		t.Fatalf("expected 1 answer, got %d", len(result.Results[0].Result.Answers))
-	}
-}
-
-func TestClient_WaitForDNSMeasurement_PollsUntilComplete(t *testing.T) {
-	calls := 0
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
-		status := StatusInProgress
-		if calls >= 2 {
-			status = StatusFinished
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(DNSMeasurementResult{
-			ID:     "dns-id",
-			Type:   MeasurementTypeDNS,
-			Status: status,
-		})
-	}))
-	defer server.Close()
-
-	client := NewClient("")
-	client.baseURL = server.URL
-	client.pollInterval = 10 * time.Millisecond
-
-	result, err := client.WaitForDNSMeasurement(context.Background(), "dns-id")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if result.Status != StatusFinished {
-		t.Errorf("expected status 'finished', got %q", result.Status)
-	}
-	if calls < 2 {
-		t.Errorf("expected at least 2 calls, got %d", calls)
+	if len(result.Results[0].DNS.Answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(result.Results[0].DNS.Answers))
 	}
 }
 
@@ -110,14 +75,14 @@ func TestClient_RunDNSMeasurement_CreatesAndWaits(t *testing.T) {
 			return
 		}
 
-		json.NewEncoder(w).Encode(DNSMeasurementResult{
+		json.NewEncoder(w).Encode(MeasurementResult{
 			ID:     "dns-id",
 			Type:   MeasurementTypeDNS,
 			Status: StatusFinished,
-			Results: []DNSProbeResult{
+			Results: []ProbeResult{
 				{
 					Probe: ProbeInfo{City: "Paris"},
-					Result: DNSResult{
+					DNS: &DNSResult{
 						StatusCodeName: "NOERROR",
 						Answers: []DNSAnswer{
 							{Name: "example.com.", Type: "A", Value: "93.184.216.34"},
@@ -5,7 +5,7 @@ import (
 	"testing"
 )
 
-func TestDNSMeasurementResult_DeserializesFromJSON(t *testing.T) {
+func TestMeasurementResult_DeserializesDNSFromJSON(t *testing.T) {
 	raw := `{
 		"id": "dns-test-id",
 		"type": "dns",
@@ -42,7 +42,7 @@ func TestDNSMeasurementResult_DeserializesFromJSON(t *testing.T) {
 		]
 	}`
 
-	var result DNSMeasurementResult
+	var result MeasurementResult
 	if err := json.Unmarshal([]byte(raw), &result); err != nil {
 		t.Fatalf("failed to unmarshal: %v", err)
 	}
@@ -65,7 +65,10 @@ func TestDNSMeasurementResult_DeserializesFromJSON(t *testing.T) {
 		t.Errorf("expected City 'Paris', got %q", pr.Probe.City)
 	}
 
-	r := pr.Result
+	r := pr.DNS
+	if r == nil {
+		t.Fatal("expected DNS payload to be decoded")
+	}
 	if r.StatusCode != 0 {
 		t.Errorf("expected StatusCode 0, got %d", r.StatusCode)
 	}
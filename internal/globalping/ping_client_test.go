@@ -9,21 +9,21 @@ import (
 	"time"
 )
 
-func TestClient_GetPingMeasurement_ReturnsResult(t *testing.T) {
+func TestClient_GetMeasurement_DecodesPingResult(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/measurements/ping-id" {
 			t.Errorf("expected /v1/measurements/ping-id, got %s", r.URL.Path)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(PingMeasurementResult{
+		json.NewEncoder(w).Encode(MeasurementResult{
 			ID:     "ping-id",
 			Type:   MeasurementTypePing,
 			Status: StatusFinished,
-			Results: []PingProbeResult{
+			Results: []ProbeResult{
 				{
 					Probe: ProbeInfo{City: "Paris", Country: "FR"},
-					Result: PingResult{
+					Ping: &PingResult{
 						Status:          "finished",
 						ResolvedAddress: "8.8.8.8",
 						Stats: PingStats{
@@ -44,7 +44,7 @@ func TestClient_GetPingMeasurement_ReturnsResult(t *testing.T) {
 	client := NewClient("")
 	client.baseURL = server.URL
 
-	result, err := client.GetPingMeasurement(context.Background(), "ping-id")
+	result, err := client.GetMeasurement(context.Background(), "ping-id")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -57,43 +57,8 @@ func TestClient_GetPingMeasurement_ReturnsResult(t *testing.T) {
 	if len(result.Results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(result.Results))
 	}
-	if result.Results[0].Result.Stats.Total != 3 {
-		t.Errorf("expected Total 3, got %d", result.Results[0].Result.Stats.Total)
-	}
-}
-
-func TestClient_WaitForPingMeasurement_PollsUntilComplete(t *testing.T) {
-	calls := 0
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		calls++
-		status := StatusInProgress
-		if calls >= 2 {
-			status = StatusFinished
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(PingMeasurementResult{
-			ID:     "ping-id",
-			Type:   MeasurementTypePing,
-			Status: status,
-		})
-	}))
-	defer server.Close()
-
-	client := NewClient("")
-	client.baseURL = server.URL
-	client.pollInterval = 10 * time.Millisecond
-
-	result, err := client.WaitForPingMeasurement(context.Background(), "ping-id")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if result.Status != StatusFinished {
-		t.Errorf("expected status 'finished', got %q", result.Status)
-	}
-	if calls < 2 {
-		t.Errorf("expected at least 2 calls, got %d", calls)
+	if result.Results[0].Ping.Stats.Total != 3 {
+		t.Errorf("expected Total 3, got %d", result.Results[0].Ping.Stats.Total)
 	}
 }
 
@@ -114,14 +79,14 @@ func TestClient_RunPingMeasurement_CreatesAndWaits(t *testing.T) {
 		}
 
 		calls++
-		json.NewEncoder(w).Encode(PingMeasurementResult{
+		json.NewEncoder(w).Encode(MeasurementResult{
 			ID:     "ping-id",
 			Type:   MeasurementTypePing,
 			Status: StatusFinished,
-			Results: []PingProbeResult{
+			Results: []ProbeResult{
 				{
-					Probe:  ProbeInfo{City: "Paris"},
-					Result: PingResult{Stats: PingStats{Total: 3, Rcv: 3}},
+					Probe: ProbeInfo{City: "Paris"},
+					Ping:  &PingResult{Stats: PingStats{Total: 3, Rcv: 3}},
 				},
 			},
 		})
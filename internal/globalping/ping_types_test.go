@@ -5,7 +5,7 @@ import (
 	"testing"
 )
 
-func TestPingMeasurementResult_DeserializesFromJSON(t *testing.T) {
+func TestMeasurementResult_DeserializesPingFromJSON(t *testing.T) {
 	raw := `{
 		"id": "ping-test-id",
 		"type": "ping",
@@ -44,7 +44,7 @@ func TestPingMeasurementResult_DeserializesFromJSON(t *testing.T) {
 		]
 	}`
 
-	var result PingMeasurementResult
+	var result MeasurementResult
 	if err := json.Unmarshal([]byte(raw), &result); err != nil {
 		t.Fatalf("failed to unmarshal: %v", err)
 	}
@@ -70,7 +70,10 @@ func TestPingMeasurementResult_DeserializesFromJSON(t *testing.T) {
 		t.Errorf("expected ASN 16276, got %d", pr.Probe.ASN)
 	}
 
-	r := pr.Result
+	r := pr.Ping
+	if r == nil {
+		t.Fatal("expected ping payload to be decoded")
+	}
 	if r.ResolvedAddress != "8.8.8.8" {
 		t.Errorf("expected resolved address '8.8.8.8', got %q", r.ResolvedAddress)
 	}
@@ -2,6 +2,7 @@
 package globalping
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -54,6 +55,7 @@ type ProbeFilter struct {
 	ASN     int
 	Network string
 	Tag     string
+	From    []Location // Probe must match at least one location (same syntax as --from)
 }
 
 // MeasurementType represents the type of measurement.
@@ -83,14 +85,14 @@ func (s MeasurementStatus) IsComplete() bool {
 
 // Location specifies where to run the measurement.
 type Location struct {
-	Magic     string `json:"magic,omitempty"`     // Flexible location string
-	Country   string `json:"country,omitempty"`   // ISO country code
-	Region    string `json:"region,omitempty"`    // Geographic region
-	City      string `json:"city,omitempty"`      // City name
-	ASN       int    `json:"asn,omitempty"`       // AS number
-	Network   string `json:"network,omitempty"`   // Network/provider name
-	Tags      []string `json:"tags,omitempty"`    // Provider tags
-	Limit     int    `json:"limit,omitempty"`     // Max probes from this location
+	Magic   string   `json:"magic,omitempty"`   // Flexible location string
+	Country string   `json:"country,omitempty"` // ISO country code
+	Region  string   `json:"region,omitempty"`  // Geographic region
+	City    string   `json:"city,omitempty"`    // City name
+	ASN     int      `json:"asn,omitempty"`     // AS number
+	Network string   `json:"network,omitempty"` // Network/provider name
+	Tags    []string `json:"tags,omitempty"`    // Provider tags
+	Limit   int      `json:"limit,omitempty"`   // Max probes from this location
 }
 
 // isStructuredLocation checks if a string uses the key:value structured syntax.
@@ -221,12 +223,29 @@ type DNSQuery struct {
 
 // MeasurementRequest represents a request to create a measurement.
 type MeasurementRequest struct {
-	Type        MeasurementType    `json:"type"`
-	Target      string             `json:"target"`
-	Locations   []Location         `json:"locations"`
-	Options     MeasurementOptions `json:"measurementOptions,omitempty"`
-	Limit       int                `json:"limit,omitempty"`       // Total probe limit
-	InProgressUpdates bool         `json:"inProgressUpdates,omitempty"`
+	Type              MeasurementType    `json:"type"`
+	Target            string             `json:"target"`
+	Locations         []Location         `json:"locations"`
+	Options           MeasurementOptions `json:"measurementOptions,omitempty"`
+	Limit             int                `json:"limit,omitempty"` // Total probe limit
+	InProgressUpdates bool               `json:"inProgressUpdates,omitempty"`
+
+	// ProbesFrom reuses the probes of an earlier measurement, by ID, instead
+	// of selecting probes from Locations.
+	ProbesFrom string `json:"-"`
+}
+
+// MarshalJSON encodes the request, sending ProbesFrom as the locations
+// string the API takes to reuse a measurement's probes.
+func (r MeasurementRequest) MarshalJSON() ([]byte, error) {
+	type request MeasurementRequest // Without the MarshalJSON method
+	if r.ProbesFrom == "" {
+		return json.Marshal(request(r))
+	}
+	return json.Marshal(struct {
+		request
+		Locations string `json:"locations"`
+	}{request(r), r.ProbesFrom})
 }
 
 // Validate checks if the request is valid.
@@ -234,6 +253,9 @@ func (r *MeasurementRequest) Validate() error {
 	if r.Target == "" {
 		return errors.New("target is required")
 	}
+	if r.ProbesFrom != "" {
+		return nil
+	}
 	if len(r.Locations) == 0 {
 		return errors.New("at least one location is required")
 	}
@@ -245,24 +267,199 @@ func (r *MeasurementRequest) Validate() error {
 
 // MeasurementResponse is the response from creating a measurement.
 type MeasurementResponse struct {
-	ID          string   `json:"id"`
-	ProbesCount int      `json:"probesCount"`
+	ID          string        `json:"id"`
+	ProbesCount int           `json:"probesCount"`
+	RateLimit   RateLimitInfo `json:"-"` // Parsed from the response headers
 }
 
-// MeasurementResult contains the results of a measurement.
+// RateLimitInfo holds the rate-limit and credit state reported in GlobalPing
+// response headers. Fields are zero when the API did not send them.
+type RateLimitInfo struct {
+	Limit            int           `json:"limit"`            // Measurements allowed per window
+	Remaining        int           `json:"remaining"`        // Measurements left in the window
+	Reset            time.Duration `json:"reset"`            // Time until the window resets
+	RequestCost      int           `json:"requestCost"`      // Cost of the request (probes requested)
+	CreditsConsumed  int           `json:"creditsConsumed"`  // Credits spent beyond the free limit
+	CreditsRemaining int           `json:"creditsRemaining"` // Credits left on the account
+}
+
+// IsLow reports whether less than 10% of the rate limit is left and no
+// credits remain to cover further measurements.
+func (r RateLimitInfo) IsLow() bool {
+	return r.Limit > 0 && r.Remaining*10 < r.Limit && r.CreditsRemaining == 0
+}
+
+// Limits is the response of the /v1/limits endpoint.
+type Limits struct {
+	RateLimit struct {
+		Measurements struct {
+			Create CreateLimit `json:"create"`
+		} `json:"measurements"`
+	} `json:"rateLimit"`
+	Credits *CreditsInfo `json:"credits,omitempty"` // Only present for authenticated requests
+}
+
+// CreateLimit describes the measurement creation rate limit.
+type CreateLimit struct {
+	Type      string `json:"type"`      // "ip" (anonymous) or "user" (API key)
+	Limit     int    `json:"limit"`     // Measurements allowed per window
+	Remaining int    `json:"remaining"` // Measurements left in the window
+	Reset     int    `json:"reset"`     // Seconds until the window resets
+}
+
+// CreditsInfo describes the credits available to an authenticated user.
+type CreditsInfo struct {
+	Remaining int `json:"remaining"`
+}
+
+// MeasurementResult contains the results of a measurement. The per-probe
+// payload is decoded according to Type; see ProbeResult.
 type MeasurementResult struct {
-	ID        string              `json:"id"`
-	Type      MeasurementType     `json:"type"`
-	Status    MeasurementStatus   `json:"status"`
-	CreatedAt time.Time           `json:"createdAt"`
-	UpdatedAt time.Time           `json:"updatedAt"`
-	Results   []ProbeResult       `json:"results"`
+	ID        string            `json:"id"`
+	Type      MeasurementType   `json:"type"`
+	Status    MeasurementStatus `json:"status"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Results   []ProbeResult     `json:"results"`
+}
+
+// UnmarshalJSON decodes each probe's result into the payload matching the
+// measurement type.
+func (m *MeasurementResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID        string            `json:"id"`
+		Type      MeasurementType   `json:"type"`
+		Status    MeasurementStatus `json:"status"`
+		CreatedAt time.Time         `json:"createdAt"`
+		UpdatedAt time.Time         `json:"updatedAt"`
+		Results   []struct {
+			Probe  ProbeInfo       `json:"probe"`
+			Result json.RawMessage `json:"result"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = MeasurementResult{
+		ID:        raw.ID,
+		Type:      raw.Type,
+		Status:    raw.Status,
+		CreatedAt: raw.CreatedAt,
+		UpdatedAt: raw.UpdatedAt,
+		Results:   make([]ProbeResult, len(raw.Results)),
+	}
+	for i, r := range raw.Results {
+		m.Results[i].Probe = r.Probe
+		if err := m.Results[i].decode(raw.Type, r.Result); err != nil {
+			return fmt.Errorf("result %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Measurement is the type-specific result reported by a single probe.
+type Measurement interface {
+	// MeasurementType returns the kind of measurement the result belongs to.
+	MeasurementType() MeasurementType
+	// ResultStatus returns the probe's own status ("in-progress", "finished", "failed").
+	ResultStatus() string
+}
+
+// PathMeasurement is a Measurement that describes the network path to the
+// target and can be converted to our internal TraceResult type.
+type PathMeasurement interface {
+	Measurement
+	ToTraceResult(probe *ProbeInfo, target string) *hop.TraceResult
 }
 
-// ProbeResult contains results from a single probe.
+// ProbeResult contains results from a single probe. Exactly one of the
+// payload fields is set, matching the measurement type. Results of types
+// this package does not know are kept undecoded in Raw.
 type ProbeResult struct {
-	Probe     ProbeInfo        `json:"probe"`
-	Result    TracerouteResult `json:"result"`
+	Probe      ProbeInfo
+	Traceroute *TracerouteResult
+	MTR        *MTRResult
+	Ping       *PingResult
+	DNS        *DNSResult
+	HTTP       *HTTPResult
+	Raw        json.RawMessage
+}
+
+// decode unmarshals raw into the payload field for measurement type t.
+func (pr *ProbeResult) decode(t MeasurementType, raw json.RawMessage) error {
+	var payload any
+	switch t {
+	case MeasurementTypeTraceroute:
+		pr.Traceroute = &TracerouteResult{}
+		payload = pr.Traceroute
+	case MeasurementTypeMTR:
+		pr.MTR = &MTRResult{}
+		payload = pr.MTR
+	case MeasurementTypePing:
+		pr.Ping = &PingResult{}
+		payload = pr.Ping
+	case MeasurementTypeDNS:
+		pr.DNS = &DNSResult{}
+		payload = pr.DNS
+	case MeasurementTypeHTTP:
+		pr.HTTP = &HTTPResult{}
+		payload = pr.HTTP
+	default:
+		pr.Raw = raw
+		return nil
+	}
+
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return json.Unmarshal(raw, payload)
+}
+
+// Measurement returns the decoded payload, or nil if the result type is unknown.
+func (pr *ProbeResult) Measurement() Measurement {
+	switch {
+	case pr.Traceroute != nil:
+		return pr.Traceroute
+	case pr.MTR != nil:
+		return pr.MTR
+	case pr.Ping != nil:
+		return pr.Ping
+	case pr.DNS != nil:
+		return pr.DNS
+	case pr.HTTP != nil:
+		return pr.HTTP
+	}
+	return nil
+}
+
+// Status returns the probe's own status, or "" if the result type is unknown.
+func (pr *ProbeResult) Status() string {
+	if m := pr.Measurement(); m != nil {
+		return m.ResultStatus()
+	}
+	return ""
+}
+
+// ToTraceResult converts the probe's path to our internal TraceResult type.
+// Returns nil for measurement types that do not describe a path.
+func (pr *ProbeResult) ToTraceResult(target string) *hop.TraceResult {
+	if pm, ok := pr.Measurement().(PathMeasurement); ok {
+		return pm.ToTraceResult(&pr.Probe, target)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the probe result in the API's {"probe", "result"} shape.
+func (pr ProbeResult) MarshalJSON() ([]byte, error) {
+	var result any = pr.Raw
+	if m := pr.Measurement(); m != nil {
+		result = m
+	}
+	return json.Marshal(struct {
+		Probe  ProbeInfo `json:"probe"`
+		Result any       `json:"result"`
+	}{pr.Probe, result})
 }
 
 // ProbeInfo contains information about the probe.
@@ -279,10 +476,10 @@ type ProbeInfo struct {
 
 // TracerouteResult contains the traceroute data.
 type TracerouteResult struct {
-	Status      string          `json:"status"`
-	ResolvedAddress string      `json:"resolvedAddress"`
-	ResolvedHostname string     `json:"resolvedHostname"`
-	Hops        []TracerouteHop `json:"hops"`
+	Status           string          `json:"status"`
+	ResolvedAddress  string          `json:"resolvedAddress"`
+	ResolvedHostname string          `json:"resolvedHostname"`
+	Hops             []TracerouteHop `json:"hops"`
 }
 
 // TracerouteHop represents a single hop in the traceroute.
@@ -369,17 +566,23 @@ func (th *TracerouteHop) ToHop(ttl int) *hop.Hop {
 	return h
 }
 
-// ToTraceResult converts a ProbeResult to our internal TraceResult type.
-func (pr *ProbeResult) ToTraceResult(target string) *hop.TraceResult {
-	result := hop.NewTraceResult(target, pr.Result.ResolvedAddress)
-	result.Source = formatProbeLocation(&pr.Probe)
+// MeasurementType implements Measurement.
+func (r *TracerouteResult) MeasurementType() MeasurementType { return MeasurementTypeTraceroute }
 
-	for i, th := range pr.Result.Hops {
+// ResultStatus implements Measurement.
+func (r *TracerouteResult) ResultStatus() string { return r.Status }
+
+// ToTraceResult converts a traceroute result to our internal TraceResult type.
+func (r *TracerouteResult) ToTraceResult(probe *ProbeInfo, target string) *hop.TraceResult {
+	result := hop.NewTraceResult(target, r.ResolvedAddress)
+	result.Source = formatProbeLocation(probe)
+
+	for i, th := range r.Hops {
 		h := th.ToHop(i + 1)
 		result.AddHop(h)
 
 		// Check if we reached the target
-		if h.PrimaryIP() != nil && h.PrimaryIP().String() == pr.Result.ResolvedAddress {
+		if h.PrimaryIP() != nil && h.PrimaryIP().String() == r.ResolvedAddress {
 			result.ReachedTarget = true
 		}
 	}
@@ -505,29 +708,29 @@ func (mh *MTRHop) ToHop(ttl int) *hop.Hop {
 
 // MTRResult contains the MTR measurement data.
 type MTRResult struct {
-	Status          string   `json:"status"`
-	ResolvedAddress string   `json:"resolvedAddress"`
-	ResolvedHostname string  `json:"resolvedHostname"`
-	Hops            []MTRHop `json:"hops"`
+	Status           string   `json:"status"`
+	ResolvedAddress  string   `json:"resolvedAddress"`
+	ResolvedHostname string   `json:"resolvedHostname"`
+	Hops             []MTRHop `json:"hops"`
 }
 
-// MTRProbeResult contains MTR results from a single probe.
-type MTRProbeResult struct {
-	Probe  ProbeInfo `json:"probe"`
-	Result MTRResult `json:"result"`
-}
+// MeasurementType implements Measurement.
+func (r *MTRResult) MeasurementType() MeasurementType { return MeasurementTypeMTR }
+
+// ResultStatus implements Measurement.
+func (r *MTRResult) ResultStatus() string { return r.Status }
 
-// ToTraceResult converts an MTRProbeResult to our internal TraceResult type.
-func (pr *MTRProbeResult) ToTraceResult(target string) *hop.TraceResult {
-	result := hop.NewTraceResult(target, pr.Result.ResolvedAddress)
-	result.Source = formatProbeLocation(&pr.Probe)
+// ToTraceResult converts an MTR result to our internal TraceResult type.
+func (r *MTRResult) ToTraceResult(probe *ProbeInfo, target string) *hop.TraceResult {
+	result := hop.NewTraceResult(target, r.ResolvedAddress)
+	result.Source = formatProbeLocation(probe)
 
-	for i, mh := range pr.Result.Hops {
+	for i, mh := range r.Hops {
 		h := mh.ToHop(i + 1)
 		result.AddHop(h)
 
 		// Check if we reached the target
-		if h.PrimaryIP() != nil && h.PrimaryIP().String() == pr.Result.ResolvedAddress {
+		if h.PrimaryIP() != nil && h.PrimaryIP().String() == r.ResolvedAddress {
 			result.ReachedTarget = true
 		}
 	}
@@ -535,23 +738,13 @@ func (pr *MTRProbeResult) ToTraceResult(target string) *hop.TraceResult {
 	return result
 }
 
-// MTRMeasurementResult contains the full MTR measurement results.
-type MTRMeasurementResult struct {
-	ID        string           `json:"id"`
-	Type      MeasurementType  `json:"type"`
-	Status    MeasurementStatus `json:"status"`
-	CreatedAt time.Time        `json:"createdAt"`
-	UpdatedAt time.Time        `json:"updatedAt"`
-	Results   []MTRProbeResult `json:"results"`
-}
-
 // Ping measurement types
 
 // PingStats contains statistics for a ping measurement.
 type PingStats struct {
-	Min   *float64 `json:"min"`   // nullable — null when all packets lost
-	Avg   *float64 `json:"avg"`   // nullable
-	Max   *float64 `json:"max"`   // nullable
+	Min   *float64 `json:"min"` // nullable — null when all packets lost
+	Avg   *float64 `json:"avg"` // nullable
+	Max   *float64 `json:"max"` // nullable
 	Total int      `json:"total"`
 	Rcv   int      `json:"rcv"`
 	Drop  int      `json:"drop"`
@@ -574,21 +767,11 @@ type PingResult struct {
 	Timings          []PingTiming `json:"timings"`
 }
 
-// PingProbeResult contains ping results from a single probe.
-type PingProbeResult struct {
-	Probe  ProbeInfo  `json:"probe"`
-	Result PingResult `json:"result"`
-}
+// MeasurementType implements Measurement.
+func (r *PingResult) MeasurementType() MeasurementType { return MeasurementTypePing }
 
-// PingMeasurementResult contains the full ping measurement results.
-type PingMeasurementResult struct {
-	ID        string            `json:"id"`
-	Type      MeasurementType   `json:"type"`
-	Status    MeasurementStatus `json:"status"`
-	CreatedAt time.Time         `json:"createdAt"`
-	UpdatedAt time.Time         `json:"updatedAt"`
-	Results   []PingProbeResult `json:"results"`
-}
+// ResultStatus implements Measurement.
+func (r *PingResult) ResultStatus() string { return r.Status }
 
 // DNS measurement types
 
@@ -631,18 +814,38 @@ type DNSTraceResult struct {
 	Hops      []DNSTraceHop `json:"hops"`
 }
 
-// DNSProbeResult contains DNS results from a single probe.
-type DNSProbeResult struct {
-	Probe  ProbeInfo `json:"probe"`
-	Result DNSResult `json:"result"`
+// MeasurementType implements Measurement.
+func (r *DNSResult) MeasurementType() MeasurementType { return MeasurementTypeDNS }
+
+// ResultStatus implements Measurement.
+func (r *DNSResult) ResultStatus() string { return r.Status }
+
+// HTTP measurement types
+
+// HTTPTiming contains the phase timings of an HTTP request in ms.
+type HTTPTiming struct {
+	Total     *float64 `json:"total"`
+	DNS       *float64 `json:"dns"`
+	TCP       *float64 `json:"tcp"`
+	TLS       *float64 `json:"tls"` // null for plain HTTP
+	FirstByte *float64 `json:"firstByte"`
+	Download  *float64 `json:"download"`
 }
 
-// DNSMeasurementResult contains the full DNS measurement results.
-type DNSMeasurementResult struct {
-	ID        string           `json:"id"`
-	Type      MeasurementType  `json:"type"`
-	Status    MeasurementStatus `json:"status"`
-	CreatedAt time.Time        `json:"createdAt"`
-	UpdatedAt time.Time        `json:"updatedAt"`
-	Results   []DNSProbeResult `json:"results"`
+// HTTPResult contains the HTTP measurement data from a single probe.
+type HTTPResult struct {
+	Status          string         `json:"status"`
+	RawOutput       string         `json:"rawOutput"`
+	RawHeaders      string         `json:"rawHeaders"`
+	Headers         map[string]any `json:"headers"`
+	ResolvedAddress string         `json:"resolvedAddress"`
+	StatusCode      int            `json:"statusCode"`
+	StatusCodeName  string         `json:"statusCodeName"`
+	Timings         HTTPTiming     `json:"timings"`
 }
+
+// MeasurementType implements Measurement.
+func (r *HTTPResult) MeasurementType() MeasurementType { return MeasurementTypeHTTP }
+
+// ResultStatus implements Measurement.
+func (r *HTTPResult) ResultStatus() string { return r.Status }
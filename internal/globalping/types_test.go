@@ -1,6 +1,7 @@
 package globalping
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -151,14 +152,14 @@ func TestMTRHop_ToHop_ConvertsCorrectly(t *testing.T) {
 				ASN:      12345,
 				Network:  "Example Network",
 				Stats: MTRStats{
-					Total:  10,
-					Loss:   10.0,
-					Rcv:    9,
-					Drop:   1,
-					Min:    1.5,
-					Avg:    2.5,
-					Max:    5.0,
-					StDev:  0.8,
+					Total: 10,
+					Loss:  10.0,
+					Rcv:   9,
+					Drop:  1,
+					Min:   1.5,
+					Avg:   2.5,
+					Max:   5.0,
+					StDev: 0.8,
 				},
 			},
 		},
@@ -195,14 +196,14 @@ func TestMTRHop_ToHop_HandlesTimeout(t *testing.T) {
 	}
 }
 
-func TestMTRProbeResult_ToTraceResult_ConvertsCorrectly(t *testing.T) {
-	pr := &MTRProbeResult{
+func TestProbeResult_ToTraceResult_ConvertsMTR(t *testing.T) {
+	pr := &ProbeResult{
 		Probe: ProbeInfo{
 			City:    "London",
 			Country: "GB",
 			Network: "Test ISP",
 		},
-		Result: MTRResult{
+		MTR: &MTRResult{
 			Status:          "finished",
 			ResolvedAddress: "8.8.8.8",
 			Hops: []MTRHop{
@@ -446,3 +447,120 @@ func TestMeasurementOptions_IPVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestMeasurementResult_DecodesHTTPResult(t *testing.T) {
+	raw := `{
+		"id": "http-id",
+		"type": "http",
+		"status": "finished",
+		"results": [{
+			"probe": {"city": "Paris", "country": "FR"},
+			"result": {
+				"status": "finished",
+				"resolvedAddress": "93.184.216.34",
+				"statusCode": 200,
+				"statusCodeName": "OK",
+				"timings": {"total": 120, "dns": 5, "tcp": 20, "tls": null, "firstByte": 60, "download": 35}
+			}
+		}]
+	}`
+
+	var result MeasurementResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	pr := result.Results[0]
+	if pr.HTTP == nil {
+		t.Fatal("expected HTTP payload to be decoded")
+	}
+	if pr.HTTP.StatusCode != 200 {
+		t.Errorf("expected status code 200, got %d", pr.HTTP.StatusCode)
+	}
+	if pr.HTTP.Timings.TLS != nil {
+		t.Errorf("expected nil TLS timing, got %v", *pr.HTTP.Timings.TLS)
+	}
+	if pr.Status() != "finished" {
+		t.Errorf("expected status 'finished', got %q", pr.Status())
+	}
+	if pr.ToTraceResult("example.com") != nil {
+		t.Error("expected no trace result for an HTTP measurement")
+	}
+}
+
+func TestMeasurementResult_KeepsUnknownTypesRaw(t *testing.T) {
+	raw := `{"id": "x", "type": "future", "results": [{"probe": {"city": "Paris"}, "result": {"foo": 1}}]}`
+
+	var result MeasurementResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	pr := result.Results[0]
+	if pr.Measurement() != nil {
+		t.Errorf("expected no decoded payload, got %T", pr.Measurement())
+	}
+	if string(pr.Raw) != `{"foo": 1}` {
+		t.Errorf("expected raw result to be kept, got %s", pr.Raw)
+	}
+}
+
+func TestMeasurementResult_RoundTripsThroughJSON(t *testing.T) {
+	in := MeasurementResult{
+		ID:   "m1",
+		Type: MeasurementTypePing,
+		Results: []ProbeResult{
+			{Probe: ProbeInfo{City: "Tokyo"}, Ping: &PingResult{Status: "finished", ResolvedAddress: "8.8.8.8"}},
+		},
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"result":{"status":"finished"`) {
+		t.Errorf("expected payload under \"result\", got %s", data)
+	}
+
+	var out MeasurementResult
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if out.Results[0].Ping == nil || out.Results[0].Ping.ResolvedAddress != "8.8.8.8" {
+		t.Errorf("expected ping payload to round-trip, got %+v", out.Results[0])
+	}
+}
+
+func TestMeasurementRequest_ProbesFromReusesProbes(t *testing.T) {
+	req := &MeasurementRequest{
+		Type:       MeasurementTypeMTR,
+		Target:     "google.com",
+		Locations:  []Location{{Magic: "London"}},
+		ProbesFrom: "abc123",
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["locations"] != "abc123" {
+		t.Errorf("locations = %v, want the measurement ID", got["locations"])
+	}
+	if got["type"] != "mtr" || got["target"] != "google.com" {
+		t.Errorf("request fields lost: %s", data)
+	}
+
+	// Without ProbesFrom, locations stay a list
+	req.ProbesFrom = ""
+	data, _ = json.Marshal(req)
+	if !strings.Contains(string(data), `"locations":[{"magic":"London"}]`) {
+		t.Errorf("expected location list, got %s", data)
+	}
+}
@@ -0,0 +1,146 @@
+// Package hoststats samples the counters of the local network interface
+// during a trace, so that loss this host causes (a saturated or faulty
+// interface dropping probes or replies) is not blamed on the network.
+package hoststats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// ErrUnsupported is returned by Read on systems without a counter source.
+var ErrUnsupported = errors.New("interface counters are only available on Linux and macOS")
+
+// Counters are the error and drop counters of one interface, with the
+// system-wide TCP retransmissions.
+type Counters struct {
+	RxErrors   uint64
+	RxDrops    uint64
+	TxErrors   uint64
+	TxDrops    uint64
+	TCPRetrans uint64
+}
+
+// Sub returns the increase of c since o.
+func (c Counters) Sub(o Counters) Counters {
+	return Counters{
+		RxErrors:   c.RxErrors - o.RxErrors,
+		RxDrops:    c.RxDrops - o.RxDrops,
+		TxErrors:   c.TxErrors - o.TxErrors,
+		TxDrops:    c.TxDrops - o.TxDrops,
+		TCPRetrans: c.TCPRetrans - o.TCPRetrans,
+	}
+}
+
+// Faults returns the interface errors and drops, leaving out TCP
+// retransmissions, which other connections of the host cause too.
+func (c Counters) Faults() uint64 {
+	return c.RxErrors + c.RxDrops + c.TxErrors + c.TxDrops
+}
+
+// String formats the counters as increases, e.g. "rx errors +0, rx drops +12, ...".
+func (c Counters) String() string {
+	return fmt.Sprintf("rx errors +%d, rx drops +%d, tx errors +%d, tx drops +%d, TCP retransmits +%d (system-wide)",
+		c.RxErrors, c.RxDrops, c.TxErrors, c.TxDrops, c.TCPRetrans)
+}
+
+// window is the counter increase observed while a hop was probed.
+type window struct {
+	ttl   int
+	loss  float64
+	delta Counters
+}
+
+// Sampler reads the counters of an interface as hops arrive, in the order
+// they are probed, and attributes each increase to the hop probed meanwhile.
+type Sampler struct {
+	iface   string
+	read    func(iface string) (Counters, error)
+	start   Counters
+	last    Counters
+	err     error
+	windows []window
+}
+
+// NewSampler creates a sampler of iface and reads its counters.
+func NewSampler(iface string) *Sampler {
+	return newSampler(iface, Read)
+}
+
+func newSampler(iface string, read func(string) (Counters, error)) *Sampler {
+	s := &Sampler{iface: iface, read: read}
+	if iface == "" {
+		s.err = errors.New("no route to the target")
+		return s
+	}
+	s.start, s.err = read(iface)
+	s.last = s.start
+	return s
+}
+
+// Observe reads the counters after h was probed.
+func (s *Sampler) Observe(h *hop.Hop) {
+	if s.err != nil {
+		return
+	}
+	c, err := s.read(s.iface)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.windows = append(s.windows, window{ttl: h.TTL, loss: h.LossPercent(), delta: c.Sub(s.last)})
+	s.last = c
+}
+
+// Total returns the counter increase over the whole trace.
+func (s *Sampler) Total() Counters {
+	return s.last.Sub(s.start)
+}
+
+// Suspects returns the hops that lost probes while the interface counted
+// errors or drops.
+func (s *Sampler) Suspects() []int {
+	var ttls []int
+	for _, w := range s.windows {
+		if w.loss > 0 && w.delta.Faults() > 0 {
+			ttls = append(ttls, w.ttl)
+		}
+	}
+	return ttls
+}
+
+// WriteReport writes the --host-stats report section.
+func (s *Sampler) WriteReport(w io.Writer) {
+	if s.err != nil {
+		fmt.Fprintf(w, "\nHost stats: unavailable (%v)\n", s.err)
+		return
+	}
+	total := s.Total()
+	fmt.Fprintf(w, "\nHost stats (%s, during the trace):\n", s.iface)
+	fmt.Fprintf(w, "  %s\n", total)
+
+	lossy := false
+	for _, w := range s.windows {
+		lossy = lossy || w.loss > 0
+	}
+	suspects := s.Suspects()
+	switch {
+	case len(suspects) > 0:
+		hops := make([]string, len(suspects))
+		for i, ttl := range suspects {
+			hops[i] = fmt.Sprint(ttl)
+		}
+		fmt.Fprintf(w, "  Loss at hop %s coincided with interface errors or drops: this host may have lost the probes or replies, not the network\n",
+			strings.Join(hops, ", "))
+	case total.Faults() > 0:
+		fmt.Fprintln(w, "  The interface errors or drops did not coincide with loss")
+	case lossy:
+		fmt.Fprintln(w, "  No interface errors or drops: the loss is not on this host")
+	default:
+		fmt.Fprintln(w, "  No interface errors or drops")
+	}
+}
@@ -0,0 +1,154 @@
+package hoststats
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+const procNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: 987654    4321    3   17    0     0          0         5   123456    2345    1    2    0     0       0          0
+`
+
+const procNetSNMP = `Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 12345
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts
+Tcp: 1 200 120000 -1 100 20 3 4 5 6000 5000 42 0 7
+`
+
+const netstatInterface = `Name       Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll Drop
+en0        1500  <Link#6>    a4:83:e7:00:00:01  123456     4   98765432    65432     1    1234567     0    9
+en0        1500  192.168.1     192.168.1.10       123400     -   98700000    65400     -    1234000     -    -
+`
+
+const netstatTCP = `tcp:
+	65432 packets sent
+		54321 data packets (12345678 bytes)
+		12 data packets (3456 bytes) retransmitted
+		0 resends initiated by MTU discovery
+`
+
+func TestParseProcNetDev(t *testing.T) {
+	c, err := parseProcNetDev(strings.NewReader(procNetDev), "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Counters{RxErrors: 3, RxDrops: 17, TxErrors: 1, TxDrops: 2}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	if _, err := parseProcNetDev(strings.NewReader(procNetDev), "wlan0"); err == nil {
+		t.Error("expected an error for a missing interface")
+	}
+}
+
+func TestParseProcNetSNMP(t *testing.T) {
+	n, err := parseProcNetSNMP(strings.NewReader(procNetSNMP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("RetransSegs = %d, want 42", n)
+	}
+}
+
+func TestParseNetstatInterface(t *testing.T) {
+	c, err := parseNetstatInterface(strings.NewReader(netstatInterface), "en0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Counters{RxErrors: 4, TxErrors: 1, TxDrops: 9}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+}
+
+func TestParseNetstatTCP(t *testing.T) {
+	n, err := parseNetstatTCP(strings.NewReader(netstatTCP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 {
+		t.Errorf("retransmitted = %d, want 12", n)
+	}
+}
+
+// fakeCounters returns a read func that yields samples in turn.
+func fakeCounters(samples ...Counters) func(string) (Counters, error) {
+	return func(string) (Counters, error) {
+		c := samples[0]
+		if len(samples) > 1 {
+			samples = samples[1:]
+		}
+		return c, nil
+	}
+}
+
+func lossyHop(ttl int, lost bool) *hop.Hop {
+	h := hop.NewHop(ttl)
+	h.AddProbe(net.ParseIP("192.0.2.1"), 10*time.Millisecond)
+	if lost {
+		h.AddTimeout()
+	}
+	return h
+}
+
+func TestSampler_CorrelatesDropsWithLoss(t *testing.T) {
+	s := newSampler("eth0", fakeCounters(
+		Counters{RxDrops: 100},
+		Counters{RxDrops: 100},                // hop 1: clean
+		Counters{RxDrops: 112, TCPRetrans: 3}, // hop 2: drops and loss
+		Counters{RxDrops: 112, TCPRetrans: 3}, // hop 3: loss without drops
+	))
+	s.Observe(lossyHop(1, false))
+	s.Observe(lossyHop(2, true))
+	s.Observe(lossyHop(3, true))
+
+	if got := s.Suspects(); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Suspects() = %v, want [2]", got)
+	}
+	if total := s.Total(); total.RxDrops != 12 || total.TCPRetrans != 3 {
+		t.Errorf("Total() = %+v", total)
+	}
+
+	var buf bytes.Buffer
+	s.WriteReport(&buf)
+	out := buf.String()
+	for _, want := range []string{"Host stats (eth0", "rx drops +12", "Loss at hop 2 coincided"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSampler_CleanInterfaceClearsHost(t *testing.T) {
+	s := newSampler("eth0", fakeCounters(Counters{}))
+	s.Observe(lossyHop(1, true))
+
+	var buf bytes.Buffer
+	s.WriteReport(&buf)
+	if !strings.Contains(buf.String(), "the loss is not on this host") {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestSampler_Unavailable(t *testing.T) {
+	s := newSampler("eth0", func(string) (Counters, error) { return Counters{}, ErrUnsupported })
+	s.Observe(lossyHop(1, true))
+
+	var buf bytes.Buffer
+	s.WriteReport(&buf)
+	if !strings.Contains(buf.String(), "Host stats: unavailable") {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+	if !errors.Is(s.err, ErrUnsupported) {
+		t.Errorf("err = %v", s.err)
+	}
+}
@@ -0,0 +1,140 @@
+package hoststats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseProcNetDev reads the error and drop counters of iface from Linux
+// /proc/net/dev: after "iface:" come 8 receive then 8 transmit columns,
+// with errors and drops 3rd and 4th of each.
+func parseProcNetDev(r io.Reader, iface string) (Counters, error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		name, values, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(name) != iface {
+			continue
+		}
+		fields := strings.Fields(values)
+		if len(fields) < 12 {
+			return Counters{}, fmt.Errorf("unexpected /proc/net/dev line for %s", iface)
+		}
+		n, err := parseUints(fields[2], fields[3], fields[10], fields[11])
+		if err != nil {
+			return Counters{}, err
+		}
+		return Counters{RxErrors: n[0], RxDrops: n[1], TxErrors: n[2], TxDrops: n[3]}, nil
+	}
+	if err := sc.Err(); err != nil {
+		return Counters{}, err
+	}
+	return Counters{}, fmt.Errorf("interface %s not found", iface)
+}
+
+// parseProcNetSNMP reads RetransSegs from Linux /proc/net/snmp, where a
+// "Tcp:" line of names precedes the "Tcp:" line of values.
+func parseProcNetSNMP(r io.Reader) (uint64, error) {
+	sc := bufio.NewScanner(r)
+	var names []string
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+		if names == nil {
+			names = fields
+			continue
+		}
+		for i, name := range names {
+			if name == "RetransSegs" && i < len(fields) {
+				return strconv.ParseUint(fields[i], 10, 64)
+			}
+		}
+		break
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("RetransSegs not found")
+}
+
+// parseNetstatInterface reads the error and drop counters of iface from
+// the output of macOS "netstat -bdn -I iface": the link-level row, with
+// columns found by their header names (Idrop is missing on some releases).
+func parseNetstatInterface(r io.Reader, iface string) (Counters, error) {
+	sc := bufio.NewScanner(r)
+	var header []string
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if header == nil {
+			header = fields
+			continue
+		}
+		if fields[0] != iface || len(fields) < 3 || !strings.HasPrefix(fields[2], "<Link") || len(fields) != len(header) {
+			continue
+		}
+		var c Counters
+		for i, name := range header {
+			var dst *uint64
+			switch name {
+			case "Ierrs":
+				dst = &c.RxErrors
+			case "Idrop":
+				dst = &c.RxDrops
+			case "Oerrs":
+				dst = &c.TxErrors
+			case "Drop":
+				dst = &c.TxDrops
+			default:
+				continue
+			}
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return Counters{}, fmt.Errorf("invalid netstat %s %q", name, fields[i])
+			}
+			*dst = v
+		}
+		return c, nil
+	}
+	if err := sc.Err(); err != nil {
+		return Counters{}, err
+	}
+	return Counters{}, fmt.Errorf("interface %s not found", iface)
+}
+
+// netstatRetransmitted matches the retransmission line of macOS
+// "netstat -s -p tcp", e.g. "12 data packets (3456 bytes) retransmitted".
+var netstatRetransmitted = regexp.MustCompile(`(\d+) data packets? \(\d+ bytes?\) retransmitted`)
+
+// parseNetstatTCP reads the TCP retransmissions from the output of macOS
+// "netstat -s -p tcp".
+func parseNetstatTCP(r io.Reader) (uint64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m := netstatRetransmitted.FindSubmatch(data)
+	if m == nil {
+		return 0, fmt.Errorf("retransmitted packets not found")
+	}
+	return strconv.ParseUint(string(m[1]), 10, 64)
+}
+
+// parseUints parses decimal counters.
+func parseUints(values ...string) ([]uint64, error) {
+	n := make([]uint64, len(values))
+	for i, v := range values {
+		var err error
+		if n[i], err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid counter %q", v)
+		}
+	}
+	return n, nil
+}
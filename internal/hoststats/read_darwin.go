@@ -0,0 +1,24 @@
+package hoststats
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// Read returns the counters of iface from netstat.
+func Read(iface string) (Counters, error) {
+	out, err := exec.Command("netstat", "-bdn", "-I", iface).Output()
+	if err != nil {
+		return Counters{}, err
+	}
+	c, err := parseNetstatInterface(bytes.NewReader(out), iface)
+	if err != nil {
+		return Counters{}, err
+	}
+
+	// Retransmissions are context only: read what is there
+	if out, err := exec.Command("netstat", "-s", "-p", "tcp").Output(); err == nil {
+		c.TCPRetrans, _ = parseNetstatTCP(bytes.NewReader(out))
+	}
+	return c, nil
+}
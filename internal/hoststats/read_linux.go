@@ -0,0 +1,23 @@
+package hoststats
+
+import "os"
+
+// Read returns the counters of iface from /proc/net/dev and /proc/net/snmp.
+func Read(iface string) (Counters, error) {
+	dev, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return Counters{}, err
+	}
+	defer dev.Close()
+	c, err := parseProcNetDev(dev, iface)
+	if err != nil {
+		return Counters{}, err
+	}
+
+	// Retransmissions are context only: read what is there
+	if snmp, err := os.Open("/proc/net/snmp"); err == nil {
+		defer snmp.Close()
+		c.TCPRetrans, _ = parseProcNetSNMP(snmp)
+	}
+	return c, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package hoststats
+
+// Read returns ErrUnsupported.
+func Read(string) (Counters, error) {
+	return Counters{}, ErrUnsupported
+}
@@ -0,0 +1,77 @@
+// Package logging provides gtrace's structured diagnostic logs. The logger
+// travels in contexts, carrying the IDs of the trace or measurement being run,
+// so the trace, enrich and globalping packages log without extra parameters.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Attribute keys of the IDs threaded through contexts.
+const (
+	TraceIDKey       = "trace_id"
+	MeasurementIDKey = "measurement_id"
+)
+
+// New creates a logger writing to w in format (text or json). Warnings and
+// errors are logged by default; verbose adds info and debug messages.
+func New(w io.Writer, format string, verbose bool) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelWarn}
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+
+	switch format {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q: must be text or json", format)
+}
+
+// discard is the logger of contexts without one.
+var discard = slog.New(slog.DiscardHandler)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or a logger discarding
+// everything when there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return discard
+}
+
+// With returns a copy of ctx whose logger adds args (key-value pairs or
+// slog.Attr) to every message.
+func With(ctx context.Context, args ...any) context.Context {
+	if _, ok := ctx.Value(contextKey{}).(*slog.Logger); !ok {
+		return ctx
+	}
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+// NewID returns a random ID to correlate the messages of one trace.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
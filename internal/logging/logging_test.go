@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew_Levels(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, FormatText, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("probe sent")
+	l.Info("measurement created")
+	l.Warn("rate limited")
+
+	if out := buf.String(); strings.Contains(out, "probe sent") || strings.Contains(out, "measurement created") || !strings.Contains(out, "rate limited") {
+		t.Errorf("expected only warnings without verbose, got %q", out)
+	}
+
+	buf.Reset()
+	l, _ = New(&buf, FormatText, true)
+	l.Debug("probe sent")
+	if !strings.Contains(buf.String(), "probe sent") {
+		t.Errorf("expected debug messages in verbose mode, got %q", buf.String())
+	}
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", false); err == nil {
+		t.Error("expected error for an invalid format")
+	}
+}
+
+func TestContext_ThreadsIDs(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := New(&buf, FormatJSON, true)
+
+	ctx := NewContext(context.Background(), l)
+	ctx = With(ctx, TraceIDKey, "abc123")
+	ctx = With(ctx, MeasurementIDKey, "m-1")
+	FromContext(ctx).Debug("measurement finished")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	if rec["msg"] != "measurement finished" || rec[TraceIDKey] != "abc123" || rec[MeasurementIDKey] != "m-1" {
+		t.Errorf("unexpected record %v", rec)
+	}
+}
+
+func TestFromContext_DiscardsWithoutLogger(t *testing.T) {
+	ctx := With(context.Background(), TraceIDKey, "abc123")
+	if FromContext(ctx) == nil {
+		t.Fatal("expected a usable logger")
+	}
+	FromContext(ctx).Error("nobody listens")
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	if len(a) != 16 || a == b {
+		t.Errorf("expected distinct 16-char IDs, got %q and %q", a, b)
+	}
+}
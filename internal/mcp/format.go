@@ -80,6 +80,12 @@ func formatTraceResult(tr *hop.TraceResult) string {
 	if !tr.StartTime.IsZero() && !tr.EndTime.IsZero() {
 		fmt.Fprintf(&sb, "Duration: %v\n", tr.EndTime.Sub(tr.StartTime).Round(time.Millisecond))
 	}
+	if segs := tr.LatencyByAS(); len(segs) > 0 {
+		sb.WriteString("Latency by AS:\n")
+		for _, seg := range segs {
+			fmt.Fprintf(&sb, "  %s\n", seg)
+		}
+	}
 
 	return sb.String()
 }
@@ -120,6 +126,9 @@ func formatHop(sb *strings.Builder, h *hop.Hop) {
 	for _, m := range h.MPLS {
 		fmt.Fprintf(sb, "    MPLS: %s\n", m.String())
 	}
+	if h.SegmentRouting != nil {
+		fmt.Fprintf(sb, "    Segment routing: %s\n", h.SegmentRouting.String())
+	}
 
 	// Interface Info (RFC 5837)
 	if h.InterfaceInfo != nil {
@@ -165,6 +174,11 @@ func formatHop(sb *strings.Builder, h *hop.Hop) {
 		sb.WriteString("    [NAT detected]\n")
 	}
 
+	// Protocol that got the answers (--protocol auto)
+	if h.Protocol != "" {
+		fmt.Fprintf(sb, "    [Answered %s probes]\n", h.Protocol)
+	}
+
 	// MTU
 	if h.MTU > 0 {
 		fmt.Fprintf(sb, "    [MTU: %d]\n", h.MTU)
@@ -186,6 +200,16 @@ func formatHop(sb *strings.Builder, h *hop.Hop) {
 	if h.Enrichment.IX != "" {
 		fmt.Fprintf(sb, "    IX: %s\n", h.Enrichment.IX)
 	}
+
+	// IANA special-purpose range
+	if h.Enrichment.Special != "" {
+		fmt.Fprintf(sb, "    Special: %s\n", h.Enrichment.Special)
+	}
+
+	// Blocklist listings
+	for _, l := range h.Enrichment.Reputation {
+		fmt.Fprintf(sb, "    Listed: %s (%s)\n", l.List, l.Category)
+	}
 }
 
 // formatMTRStats formats MTR statistics as a text table.
@@ -241,12 +265,21 @@ func formatMTRStats(stats map[int]*display.HopStats, cycles int, target string)
 			s.LossPercent(),
 			s.Sent,
 			s.Recv,
-			float64(s.BestRTT)/float64(time.Millisecond),
-			float64(s.AvgRTT())/float64(time.Millisecond),
+			float64(s.FilteredBest)/float64(time.Millisecond),
+			float64(s.FilteredAvgRTT())/float64(time.Millisecond),
 			float64(s.WorstRTT)/float64(time.Millisecond),
-			float64(s.StdDev())/float64(time.Millisecond),
+			float64(s.FilteredStdDev())/float64(time.Millisecond),
 		)
 
+		// RTT outliers left out of Best/Avg/StDev
+		if s.Outliers > 0 {
+			fmt.Fprintf(&sb, "    [rtt_outliers: %d filtered, raw best/avg/stdev %.1f/%.1f/%.1f ms]\n",
+				s.Outliers,
+				float64(s.BestRTT)/float64(time.Millisecond),
+				float64(s.AvgRTT())/float64(time.Millisecond),
+				float64(s.StdDev())/float64(time.Millisecond))
+		}
+
 		// TTL manipulation indicator
 		if s.TTLManipulated {
 			sb.WriteString("    [ttl_manipulated: middlebox modified original datagram TTL]\n")
@@ -270,6 +303,21 @@ func formatMTRStats(stats map[int]*display.HopStats, cycles int, target string)
 			sb.WriteString("    [rate_limited: likely ICMP rate limiting, not real loss]\n")
 		}
 
+		// Loss that does not reach the final hop
+		if s.ControlPlaneLoss() {
+			fmt.Fprintf(&sb, "    [forward_loss: none, %s]\n", display.ControlPlaneLossNote)
+		}
+
+		// Loss pattern classification
+		switch s.LossPattern() {
+		case display.LossPeriodic:
+			sb.WriteString("    [loss_pattern: periodic, likely ICMP rate limiting]\n")
+		case display.LossBursty:
+			sb.WriteString("    [loss_pattern: bursty, loss clusters in runs]\n")
+		case display.LossRandom:
+			sb.WriteString("    [loss_pattern: random]\n")
+		}
+
 		// ECMP classification
 		if s.ECMPClassified != "" {
 			fmt.Fprintf(&sb, "    [ecmp_type: %s]\n", s.ECMPClassified)
@@ -329,7 +377,7 @@ type probeInfo struct {
 }
 
 // formatPingResults formats ping results from multiple probes.
-func formatPingResults(results []globalping.PingProbeResult, target string) string {
+func formatPingResults(results []globalping.ProbeResult, target string) string {
 	var sb strings.Builder
 
 	fmt.Fprintf(&sb, "Ping results for %s\n", target)
@@ -344,7 +392,10 @@ func formatPingResults(results []globalping.PingProbeResult, target string) stri
 		fmt.Fprintf(&sb, "=== Probe: %s, %s (AS%d %s) ===\n",
 			pr.Probe.City, pr.Probe.Country, pr.Probe.ASN, pr.Probe.Network)
 
-		r := pr.Result
+		r := pr.Ping
+		if r == nil {
+			continue
+		}
 		if r.ResolvedHostname != "" && r.ResolvedHostname != r.ResolvedAddress {
 			fmt.Fprintf(&sb, "Target: %s (%s)\n", r.ResolvedAddress, r.ResolvedHostname)
 		} else if r.ResolvedAddress != "" {
@@ -372,7 +423,7 @@ func formatPingResults(results []globalping.PingProbeResult, target string) stri
 }
 
 // formatDNSResults formats DNS results from multiple probes.
-func formatDNSResults(results []globalping.DNSProbeResult, target string, trace bool) string {
+func formatDNSResults(results []globalping.ProbeResult, target string, trace bool) string {
 	var sb strings.Builder
 
 	fmt.Fprintf(&sb, "DNS lookup for %s\n", target)
@@ -387,7 +438,10 @@ func formatDNSResults(results []globalping.DNSProbeResult, target string, trace
 		fmt.Fprintf(&sb, "=== Probe: %s, %s (AS%d %s) ===\n",
 			pr.Probe.City, pr.Probe.Country, pr.Probe.ASN, pr.Probe.Network)
 
-		r := pr.Result
+		r := pr.DNS
+		if r == nil {
+			continue
+		}
 
 		if trace && r.RawOutput != "" {
 			sb.WriteString(r.RawOutput)
@@ -235,6 +235,7 @@ func (h *handlers) handleMTR(ctx context.Context, req mcp.CallToolRequest) (*mcp
 
 	cycleCallback := func(cycle int, reached bool) {
 		completedCycles = cycle
+		display.CorrelateCycleLoss(stats)
 		if cycle >= cycles {
 			cancel()
 		}
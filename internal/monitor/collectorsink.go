@@ -0,0 +1,42 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hervehildenbrand/gtrace/internal/collector"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// collectorSink streams the trace result of every cycle to a gtrace
+// collector, which compares the paths of all its agents.
+type collectorSink struct {
+	pusher *collector.Pusher
+}
+
+func newCollectorSink(cfg MetricSinkConfig) (MetricSink, error) {
+	if cfg.ServerID == "" {
+		return nil, fmt.Errorf("collector sink requires the SPIFFE ID of the collector (serverId)")
+	}
+	id, err := collector.ParseID(cfg.ServerID)
+	if err != nil {
+		return nil, fmt.Errorf("collector sink: %w", err)
+	}
+	tlsCfg, err := collector.ClientTLSConfig(collector.TLSFiles{Cert: cfg.CertFile, Key: cfg.KeyFile, CA: cfg.CAFile}, id)
+	if err != nil {
+		return nil, fmt.Errorf("collector sink: %w", err)
+	}
+	pusher, err := collector.NewPusher(cfg.Address, tlsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("collector sink: %w", err)
+	}
+	return &collectorSink{pusher: pusher}, nil
+}
+
+// Push sends tr, unless the cycle overlapped a sleep or network change.
+func (s *collectorSink) Push(ctx context.Context, sum Summary, tr *hop.TraceResult) error {
+	if sum.Invalid {
+		return nil
+	}
+	return s.pusher.Push(ctx, collector.Report{Target: sum.Target, Time: sum.Time, Result: tr})
+}
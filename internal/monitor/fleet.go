@@ -0,0 +1,212 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// maxRecentAlerts is the number of alerts kept per target for the dashboard.
+const maxRecentAlerts = 5
+
+// FleetConfig holds fleet monitoring configuration.
+type FleetConfig struct {
+	Interval time.Duration // Time between rounds for each target
+	Workers  int           // Maximum concurrent traces
+	Monitor  *Config       // Change detection and alert rules applied to every target
+}
+
+// DefaultFleetConfig returns the default fleet configuration.
+func DefaultFleetConfig() *FleetConfig {
+	return &FleetConfig{
+		Interval: 30 * time.Second,
+		Workers:  4,
+		Monitor:  DefaultConfig(),
+	}
+}
+
+// TargetStatus is the latest known state of one monitored target.
+type TargetStatus struct {
+	Target    string
+	TargetIP  string
+	Cycles    int
+	LastRun   time.Time
+	Reached   bool
+	Hops      int
+	WorstHop  int           // TTL of the hop with the highest average RTT
+	WorstRTT  time.Duration // Average RTT of WorstHop
+	LastError error
+	Alerts    []Change // Most recent alerts, newest last
+}
+
+// FleetTraceFunc runs one trace for the given target.
+type FleetTraceFunc func(ctx context.Context, target string) (*hop.TraceResult, error)
+
+// Fleet monitors many targets with a bounded worker pool.
+type Fleet struct {
+	config   *FleetConfig
+	targets  []string
+	detector *Monitor
+	callback func(target string, changes []Change)
+
+	mu       sync.RWMutex
+	status   map[string]*TargetStatus
+	previous map[string]*hop.TraceResult
+	rules    map[string]*RuleSet // Alert rules of Config.Monitor, per target
+	inFlight map[string]bool
+}
+
+// NewFleet creates a fleet monitor for the given targets.
+func NewFleet(targets []string, cfg *FleetConfig) *Fleet {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.Monitor == nil {
+		cfg.Monitor = DefaultConfig()
+	}
+
+	status := make(map[string]*TargetStatus, len(targets))
+	rules := make(map[string]*RuleSet, len(targets))
+	for _, t := range targets {
+		status[t] = &TargetStatus{Target: t}
+		rules[t] = NewRuleSet(cfg.Monitor.Rules)
+	}
+
+	return &Fleet{
+		config:   cfg,
+		targets:  targets,
+		detector: NewMonitor(cfg.Monitor),
+		status:   status,
+		previous: make(map[string]*hop.TraceResult),
+		rules:    rules,
+		inFlight: make(map[string]bool),
+	}
+}
+
+// SetCallback sets the callback invoked when a target reports changes.
+func (f *Fleet) SetCallback(cb func(target string, changes []Change)) {
+	f.callback = cb
+}
+
+// Snapshot returns a copy of every target's status in input order.
+func (f *Fleet) Snapshot() []TargetStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	out := make([]TargetStatus, 0, len(f.targets))
+	for _, t := range f.targets {
+		s := *f.status[t]
+		s.Alerts = append([]Change(nil), s.Alerts...)
+		out = append(out, s)
+	}
+	return out
+}
+
+// Run traces every target once per interval until the context is cancelled.
+// A target whose previous trace is still running is skipped for that round.
+func (f *Fleet) Run(ctx context.Context, traceFn FleetTraceFunc) error {
+	jobs := make(chan string, len(f.targets))
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				f.runOne(ctx, target, traceFn)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(f.config.Interval)
+	defer ticker.Stop()
+
+	f.enqueue(jobs)
+	for {
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return ctx.Err()
+		case <-ticker.C:
+			f.enqueue(jobs)
+		}
+	}
+}
+
+// enqueue schedules every target that is not already being traced.
+func (f *Fleet) enqueue(jobs chan<- string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.targets {
+		if f.inFlight[t] {
+			continue
+		}
+		f.inFlight[t] = true
+		jobs <- t
+	}
+}
+
+// runOne traces a single target and records the outcome.
+func (f *Fleet) runOne(ctx context.Context, target string, traceFn FleetTraceFunc) {
+	defer func() {
+		f.mu.Lock()
+		f.inFlight[target] = false
+		f.mu.Unlock()
+	}()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	result, err := traceFn(ctx, target)
+
+	f.mu.Lock()
+	s := f.status[target]
+	s.Cycles++
+	s.LastRun = time.Now()
+	if err != nil {
+		s.LastError = err
+		f.mu.Unlock()
+		return
+	}
+
+	s.LastError = nil
+	s.TargetIP = result.TargetIP
+	s.Reached = result.ReachedTarget
+	s.Hops = result.TotalHops()
+	s.WorstHop, s.WorstRTT = worstHop(result)
+
+	var changes []Change
+	if prev := f.previous[target]; prev != nil {
+		changes = f.detector.DetectChanges(prev, result)
+	}
+	f.previous[target] = result
+	changes = append(changes, f.rules[target].Evaluate(result, s.LastRun)...)
+
+	s.Alerts = append(s.Alerts, changes...)
+	if len(s.Alerts) > maxRecentAlerts {
+		s.Alerts = s.Alerts[len(s.Alerts)-maxRecentAlerts:]
+	}
+	f.mu.Unlock()
+
+	if len(changes) > 0 && f.callback != nil {
+		f.callback(target, changes)
+	}
+}
+
+// worstHop returns the TTL and average RTT of the slowest responding hop.
+func worstHop(tr *hop.TraceResult) (int, time.Duration) {
+	var ttl int
+	var worst time.Duration
+	for _, h := range tr.Hops {
+		if avg := h.AvgRTT(); avg > worst {
+			worst = avg
+			ttl = h.TTL
+		}
+	}
+	return ttl, worst
+}
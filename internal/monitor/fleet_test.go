@@ -0,0 +1,163 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestFleet_TracesEveryTarget(t *testing.T) {
+	cfg := DefaultFleetConfig()
+	cfg.Interval = time.Hour
+	cfg.Workers = 2
+	fleet := NewFleet([]string{"a", "b", "c"}, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	done := make(chan struct{})
+	go func() {
+		fleet.Run(ctx, func(ctx context.Context, target string) (*hop.TraceResult, error) {
+			if atomic.AddInt32(&calls, 1) == 3 {
+				close(done)
+			}
+			tr := createTraceWithRTT("10.0.0.1", 5*time.Millisecond)
+			tr.ReachedTarget = true
+			return tr, nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for traces")
+	}
+	cancel()
+
+	// Wait for the last worker to record its result
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		ready := true
+		for _, s := range fleet.Snapshot() {
+			if s.Cycles != 1 {
+				ready = false
+			}
+		}
+		if ready {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	snap := fleet.Snapshot()
+	if len(snap) != 3 || snap[0].Target != "a" || snap[2].Target != "c" {
+		t.Fatalf("unexpected snapshot order: %+v", snap)
+	}
+	for _, s := range snap {
+		if s.Cycles != 1 || !s.Reached {
+			t.Errorf("target %s: expected 1 reached cycle, got %+v", s.Target, s)
+		}
+	}
+}
+
+func TestFleet_RecordsAlertsAndErrors(t *testing.T) {
+	fleet := NewFleet([]string{"a"}, DefaultFleetConfig())
+
+	var alerted []Change
+	fleet.SetCallback(func(target string, changes []Change) {
+		alerted = append(alerted, changes...)
+	})
+
+	routes := []string{"10.0.0.1", "10.0.0.2"}
+	call := 0
+	traceFn := func(ctx context.Context, target string) (*hop.TraceResult, error) {
+		defer func() { call++ }()
+		if call == 2 {
+			return nil, errors.New("boom")
+		}
+		return createTrace([]string{routes[call]}), nil
+	}
+
+	ctx := context.Background()
+	fleet.runOne(ctx, "a", traceFn)
+	fleet.runOne(ctx, "a", traceFn)
+
+	s := fleet.Snapshot()[0]
+	if len(s.Alerts) == 0 || s.Alerts[0].Type != ChangeTypeRoute {
+		t.Fatalf("expected a route change alert, got %+v", s.Alerts)
+	}
+	if len(alerted) != len(s.Alerts) {
+		t.Errorf("callback should receive the same changes, got %d vs %d", len(alerted), len(s.Alerts))
+	}
+
+	fleet.runOne(ctx, "a", traceFn)
+	s = fleet.Snapshot()[0]
+	if s.LastError == nil {
+		t.Error("expected last error to be recorded")
+	}
+	if s.Cycles != 3 {
+		t.Errorf("expected 3 cycles, got %d", s.Cycles)
+	}
+}
+
+func TestFleet_KeepsOnlyRecentAlerts(t *testing.T) {
+	fleet := NewFleet([]string{"a"}, DefaultFleetConfig())
+
+	call := 0
+	traceFn := func(ctx context.Context, target string) (*hop.TraceResult, error) {
+		call++
+		return createTrace([]string{net.IPv4(10, 0, 0, byte(call)).String()}), nil
+	}
+	for i := 0; i < maxRecentAlerts+3; i++ {
+		fleet.runOne(context.Background(), "a", traceFn)
+	}
+
+	if got := len(fleet.Snapshot()[0].Alerts); got != maxRecentAlerts {
+		t.Errorf("expected %d alerts kept, got %d", maxRecentAlerts, got)
+	}
+}
+
+func TestFleet_EvaluatesRulesPerTarget(t *testing.T) {
+	cfg := DefaultFleetConfig()
+	cfg.Monitor.Rules = []*Rule{mustParseRule(t, "hop(last).loss > 50% for 2 cycles")}
+	fleet := NewFleet([]string{"lossy", "clean"}, cfg)
+
+	traceFn := func(ctx context.Context, target string) (*hop.TraceResult, error) {
+		if target == "lossy" {
+			return createTraceWithLoss("8.8.8.8", 2), nil
+		}
+		return createTraceWithLoss("8.8.8.8", 0), nil
+	}
+	ctx := context.Background()
+	// Interleaved cycles must not add up across targets
+	for i := 0; i < 2; i++ {
+		fleet.runOne(ctx, "lossy", traceFn)
+		fleet.runOne(ctx, "clean", traceFn)
+	}
+
+	snap := fleet.Snapshot()
+	if len(snap[0].Alerts) != 1 || snap[0].Alerts[0].Rule == nil {
+		t.Errorf("expected one rule alert for the lossy target, got %v", snap[0].Alerts)
+	}
+	if len(snap[1].Alerts) != 0 {
+		t.Errorf("expected no alert for the clean target, got %v", snap[1].Alerts)
+	}
+}
+
+func TestWorstHop_PicksHighestAverage(t *testing.T) {
+	tr := hop.NewTraceResult("t", "10.0.0.3")
+	for i, rtt := range []time.Duration{5, 40, 20} {
+		h := hop.NewHop(i + 1)
+		h.AddProbe(net.ParseIP("10.0.0.1"), rtt*time.Millisecond)
+		tr.AddHop(h)
+	}
+
+	ttl, rtt := worstHop(tr)
+	if ttl != 2 || rtt != 40*time.Millisecond {
+		t.Errorf("expected hop 2 at 40ms, got hop %d at %v", ttl, rtt)
+	}
+}
@@ -0,0 +1,43 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hervehildenbrand/gtrace/internal/export"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// History is an append-only store of monitored traces: a file holding the
+// JSON export of one trace per line, which export.ReadJSON reads back.
+type History struct {
+	mu       sync.Mutex
+	f        *os.File
+	exporter *export.JSONExporter
+}
+
+// OpenHistory opens the history file at path, creating it if needed. Traces
+// are appended to those of earlier runs.
+func OpenHistory(path string) (*History, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	return &History{f: f, exporter: export.NewJSONExporter()}, nil
+}
+
+// Append writes tr as the last line of the history.
+func (h *History) Append(tr *hop.TraceResult) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.exporter.Export(h.f, tr); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Close closes the history file.
+func (h *History) Close() error {
+	return h.f.Close()
+}
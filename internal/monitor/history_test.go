@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/internal/export"
+)
+
+func TestHistory_AppendsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	for run := 0; run < 2; run++ {
+		h, err := OpenHistory(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			if err := h.Append(createTrace([]string{ip, "8.8.8.8"})); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	results, err := export.ReadJSON(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 traces, got %d", len(results))
+	}
+	if got := results[3].Hops[0].PrimaryIP().String(); got != "10.0.0.2" {
+		t.Errorf("expected the last trace last, got first hop %s", got)
+	}
+}
+
+func TestOpenHistory_MissingDirectory(t *testing.T) {
+	if _, err := OpenHistory(filepath.Join(t.TempDir(), "missing", "history.jsonl")); err == nil {
+		t.Error("expected error for a missing directory")
+	}
+}
@@ -0,0 +1,384 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// MetricSink stores the per-cycle path and hop metrics of the monitor in a
+// time-series database.
+type MetricSink interface {
+	Push(ctx context.Context, s Summary, tr *hop.TraceResult) error
+}
+
+// MetricSinkConfig configures a metric sink in the user configuration file.
+type MetricSinkConfig struct {
+	Type string `json:"type"` // influxdb, graphite, statsd, dogstatsd, mqtt or collector
+
+	// influxdb: line protocol POSTed to URL, the full write endpoint
+	// (".../write?db=gtrace" for 1.x, ".../api/v2/write?org=o&bucket=b" for 2.x)
+	URL   string `json:"url,omitempty"`
+	Token string `json:"token,omitempty"` // Sent as "Authorization: Token ..." when set
+
+	// graphite: plaintext protocol to Address (host:port) over Network;
+	// statsd, dogstatsd: gauges in UDP datagrams to Address
+	Network string `json:"network,omitempty"` // graphite: tcp (default) or udp
+	Address string `json:"address,omitempty"`
+	Prefix  string `json:"prefix,omitempty"` // Default: gtrace
+
+	// dogstatsd: tags added to every metric, e.g. "env:prod"
+	Tags []string `json:"tags,omitempty"`
+
+	// mqtt: cycle summaries published as JSON to Topic (default
+	// gtrace/HOSTNAME/summary), as for mqtt alert sinks
+	Broker   string `json:"broker,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	QoS      int    `json:"qos,omitempty"` // 0 or 1
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	ClientID string `json:"clientId,omitempty"`
+	CAFile   string `json:"caFile,omitempty"`
+
+	// collector: trace results streamed to a gtrace collector at Address
+	// over mutual TLS, with the SVID in CertFile and KeyFile, verified by
+	// a collector presenting the SPIFFE ID ServerID issued by CAFile
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	ServerID string `json:"serverId,omitempty"`
+}
+
+// NewMetricSink creates the metric sink described by cfg.
+func NewMetricSink(cfg MetricSinkConfig) (MetricSink, error) {
+	switch cfg.Type {
+	case "influxdb":
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("influxdb sink requires an http(s) write url")
+		}
+		return &influxSink{url: cfg.URL, token: cfg.Token}, nil
+
+	case "graphite":
+		s := &graphiteSink{network: cfg.Network, address: cfg.Address, prefix: cfg.Prefix}
+		if s.network == "" {
+			s.network = "tcp"
+		}
+		if s.network != "tcp" && s.network != "udp" {
+			return nil, fmt.Errorf("invalid graphite network %q: must be tcp or udp", s.network)
+		}
+		if _, _, err := net.SplitHostPort(s.address); err != nil {
+			return nil, fmt.Errorf("graphite sink requires an address (host:port)")
+		}
+		if s.prefix == "" {
+			s.prefix = "gtrace"
+		}
+		return s, nil
+
+	case "statsd", "dogstatsd":
+		if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+			return nil, fmt.Errorf("%s sink requires an address (host:port)", cfg.Type)
+		}
+		s := &statsdSink{address: cfg.Address, prefix: cfg.Prefix, tagged: cfg.Type == "dogstatsd", tags: cfg.Tags}
+		if s.prefix == "" {
+			s.prefix = "gtrace"
+		}
+		return s, nil
+
+	case "mqtt":
+		return newMQTTMetricSink(cfg)
+
+	case "collector":
+		return newCollectorSink(cfg)
+	}
+	return nil, fmt.Errorf("unknown metric sink type %q: must be influxdb, graphite, statsd, dogstatsd, mqtt or collector", cfg.Type)
+}
+
+// PushMetrics sends the metrics of one cycle to every sink, each bounded by
+// the action timeout so a slow database cannot stall the monitoring loop.
+func PushMetrics(ctx context.Context, sinks []MetricSink, s Summary, tr *hop.TraceResult) error {
+	var errs []error
+	for _, sink := range sinks {
+		pushCtx, cancel := context.WithTimeout(ctx, actionTimeout)
+		errs = append(errs, sink.Push(pushCtx, s, tr))
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// hopMetrics are the metrics of one hop in one cycle.
+type hopMetrics struct {
+	ttl        int
+	ip         string // "" when no probe was answered
+	asn        uint32
+	sent, recv int
+	loss       float64
+	avg, best  float64 // in ms, only when recv > 0
+	worst      float64
+}
+
+// cycleHopMetrics computes the metrics of every probed hop of tr.
+func cycleHopMetrics(tr *hop.TraceResult) []hopMetrics {
+	var hops []hopMetrics
+	for _, h := range tr.Hops {
+		if len(h.Probes) == 0 {
+			continue
+		}
+		m := hopMetrics{ttl: h.TTL, asn: h.Enrichment.ASN, sent: len(h.Probes), loss: h.LossPercent()}
+		if ip := h.PrimaryIP(); ip != nil {
+			m.ip = ip.String()
+		}
+		for _, p := range h.Probes {
+			if p.Timeout {
+				continue
+			}
+			rtt := hop.Milliseconds(p.RTT)
+			if m.recv == 0 || rtt < m.best {
+				m.best = rtt
+			}
+			m.worst = max(m.worst, rtt)
+			m.recv++
+		}
+		if m.recv > 0 {
+			m.avg = hop.Milliseconds(h.AvgRTT())
+		}
+		hops = append(hops, m)
+	}
+	return hops
+}
+
+// metricValue is a named metric of one cycle.
+type metricValue struct {
+	name  string
+	value float64
+}
+
+// pathValues returns the path metrics of a cycle.
+func pathValues(sum Summary) []metricValue {
+	reached := 0.0
+	if sum.Reached {
+		reached = 1
+	}
+	values := []metricValue{
+		{"reached", reached},
+		{"hops", float64(sum.Hops)},
+		{"loss_pct", sum.LossPercent},
+		{"stability_pct", sum.Stability},
+	}
+	if sum.Reached {
+		values = append(values, metricValue{"e2e_avg_ms", sum.AvgRTT}, metricValue{"e2e_p95_ms", sum.P95RTT})
+	}
+	return values
+}
+
+// values returns the metrics of the hop; RTTs only when it answered.
+func (m hopMetrics) values() []metricValue {
+	values := []metricValue{
+		{"sent", float64(m.sent)},
+		{"recv", float64(m.recv)},
+		{"loss_pct", m.loss},
+	}
+	if m.recv > 0 {
+		values = append(values, metricValue{"avg_ms", m.avg}, metricValue{"best_ms", m.best}, metricValue{"worst_ms", m.worst})
+	}
+	return values
+}
+
+// formatMetricValue formats a value without trailing zeros.
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// influxSink writes metrics in InfluxDB line protocol.
+type influxSink struct {
+	url   string
+	token string
+}
+
+// influxTagEscaper escapes measurement tag keys and values.
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// format returns the line protocol of one cycle: a gtrace_path point and a
+// gtrace_hop point per probed hop, in nanosecond precision.
+func (s *influxSink) format(sum Summary, tr *hop.TraceResult) string {
+	var b strings.Builder
+	ts := sum.Time.UnixNano()
+	target := influxTagEscaper.Replace(sum.Target)
+
+	fmt.Fprintf(&b, "gtrace_path,target=%s reached=%t,hops=%di,loss_pct=%g,stability_pct=%g",
+		target, sum.Reached, sum.Hops, sum.LossPercent, sum.Stability)
+	if sum.Reached {
+		fmt.Fprintf(&b, ",e2e_avg_ms=%g,e2e_p95_ms=%g", sum.AvgRTT, sum.P95RTT)
+	}
+	fmt.Fprintf(&b, " %d\n", ts)
+
+	for _, m := range cycleHopMetrics(tr) {
+		fmt.Fprintf(&b, "gtrace_hop,target=%s,hop=%d", target, m.ttl)
+		if m.ip != "" {
+			fmt.Fprintf(&b, ",ip=%s", influxTagEscaper.Replace(m.ip))
+		}
+		if m.asn != 0 {
+			fmt.Fprintf(&b, ",asn=%d", m.asn)
+		}
+		fmt.Fprintf(&b, " sent=%di,recv=%di,loss_pct=%g", m.sent, m.recv, m.loss)
+		if m.recv > 0 {
+			fmt.Fprintf(&b, ",avg_ms=%g,best_ms=%g,worst_ms=%g", m.avg, m.best, m.worst)
+		}
+		fmt.Fprintf(&b, " %d\n", ts)
+	}
+	return b.String()
+}
+
+func (s *influxSink) Push(ctx context.Context, sum Summary, tr *hop.TraceResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(s.format(sum, tr)))
+	if err != nil {
+		return fmt.Errorf("influxdb: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("influxdb: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("influxdb: %s", resp.Status)
+	}
+	return nil
+}
+
+// graphiteSink writes metrics in the Graphite plaintext protocol.
+type graphiteSink struct {
+	network string
+	address string
+	prefix  string
+}
+
+// graphiteEscaper replaces the characters Graphite treats as separators in
+// a metric path component.
+var graphiteEscaper = strings.NewReplacer(".", "_", ":", "_", " ", "_", "/", "_")
+
+// format returns the plaintext lines of one cycle, under
+// PREFIX.TARGET.path.* and PREFIX.TARGET.hop.TTL.*, in second precision.
+func (s *graphiteSink) format(sum Summary, tr *hop.TraceResult) string {
+	var b strings.Builder
+	ts := sum.Time.Unix()
+	base := s.prefix + "." + graphiteEscaper.Replace(sum.Target)
+	for _, v := range pathValues(sum) {
+		fmt.Fprintf(&b, "%s.path.%s %s %d\n", base, v.name, formatMetricValue(v.value), ts)
+	}
+	for _, m := range cycleHopMetrics(tr) {
+		for _, v := range m.values() {
+			fmt.Fprintf(&b, "%s.hop.%02d.%s %s %d\n", base, m.ttl, v.name, formatMetricValue(v.value), ts)
+		}
+	}
+	return b.String()
+}
+
+func (s *graphiteSink) Push(ctx context.Context, sum Summary, tr *hop.TraceResult) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("graphite: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte(s.format(sum, tr))); err != nil {
+		return fmt.Errorf("graphite: %w", err)
+	}
+	return nil
+}
+
+// statsdMaxPacket keeps datagrams within a typical 1500 byte MTU.
+const statsdMaxPacket = 1432
+
+// statsdSink sends metrics as StatsD gauges over UDP. Plain StatsD has no
+// tags, so the target and hop go in the metric name, as for Graphite;
+// DogStatsD gets them as tags instead.
+type statsdSink struct {
+	address string
+	prefix  string
+	tagged  bool     // DogStatsD tags instead of names
+	tags    []string // Extra DogStatsD tags
+}
+
+// lines returns the gauges of one cycle, one per line.
+func (s *statsdSink) lines(sum Summary, tr *hop.TraceResult) []string {
+	var lines []string
+	if !s.tagged {
+		base := s.prefix + "." + graphiteEscaper.Replace(sum.Target)
+		for _, v := range pathValues(sum) {
+			lines = append(lines, fmt.Sprintf("%s.path.%s:%s|g", base, v.name, formatMetricValue(v.value)))
+		}
+		for _, m := range cycleHopMetrics(tr) {
+			for _, v := range m.values() {
+				lines = append(lines, fmt.Sprintf("%s.hop.%02d.%s:%s|g", base, m.ttl, v.name, formatMetricValue(v.value)))
+			}
+		}
+		return lines
+	}
+
+	pathTags := append([]string{"target:" + sum.Target}, s.tags...)
+	for _, v := range pathValues(sum) {
+		lines = append(lines, fmt.Sprintf("%s.path.%s:%s|g|#%s", s.prefix, v.name, formatMetricValue(v.value), strings.Join(pathTags, ",")))
+	}
+	for _, m := range cycleHopMetrics(tr) {
+		tags := []string{"target:" + sum.Target, "hop:" + strconv.Itoa(m.ttl)}
+		if m.ip != "" {
+			tags = append(tags, "ip:"+m.ip)
+		}
+		if m.asn != 0 {
+			tags = append(tags, "asn:"+strconv.FormatUint(uint64(m.asn), 10))
+		}
+		tags = append(tags, s.tags...)
+		for _, v := range m.values() {
+			lines = append(lines, fmt.Sprintf("%s.hop.%s:%s|g|#%s", s.prefix, v.name, formatMetricValue(v.value), strings.Join(tags, ",")))
+		}
+	}
+	return lines
+}
+
+func (s *statsdSink) Push(ctx context.Context, sum Summary, tr *hop.TraceResult) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	defer conn.Close()
+
+	// Newline-separated gauges, packed into as few datagrams as fit
+	var packet []byte
+	flush := func() error {
+		if len(packet) == 0 {
+			return nil
+		}
+		_, err := conn.Write(packet)
+		packet = packet[:0]
+		return err
+	}
+	for _, line := range s.lines(sum, tr) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			if err := flush(); err != nil {
+				return fmt.Errorf("statsd: %w", err)
+			}
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+	return nil
+}
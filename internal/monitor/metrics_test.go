@@ -0,0 +1,242 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// metricsTrace is a reached two-hop trace with a silent hop 2 in between.
+func metricsTrace() (Summary, *hop.TraceResult) {
+	tr := hop.NewTraceResult("example.com", "93.184.216.34")
+	h1 := hop.NewHop(1)
+	h1.AddProbe(net.ParseIP("192.168.1.1"), time.Millisecond)
+	h1.AddProbe(net.ParseIP("192.168.1.1"), 3*time.Millisecond)
+	tr.AddHop(h1)
+	h2 := hop.NewHop(2)
+	h2.AddTimeout()
+	h2.AddTimeout()
+	tr.AddHop(h2)
+	h3 := hop.NewHop(3)
+	h3.AddProbe(net.ParseIP("93.184.216.34"), 20*time.Millisecond)
+	h3.AddTimeout()
+	h3.SetEnrichment(hop.Enrichment{ASN: 15133})
+	tr.AddHop(h3)
+	tr.ReachedTarget = true
+
+	return Summarize(tr, time.Unix(1700000000, 0)), tr
+}
+
+func TestNewMetricSink_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     MetricSinkConfig
+		wantErr string
+	}{
+		{"influxdb", MetricSinkConfig{Type: "influxdb", URL: "https://influx.example.com/write?db=gtrace"}, ""},
+		{"graphite", MetricSinkConfig{Type: "graphite", Address: "graphite.example.com:2003"}, ""},
+		{"graphite udp", MetricSinkConfig{Type: "graphite", Network: "udp", Address: "graphite.example.com:2003"}, ""},
+		{"statsd", MetricSinkConfig{Type: "statsd", Address: "127.0.0.1:8125"}, ""},
+		{"dogstatsd", MetricSinkConfig{Type: "dogstatsd", Address: "127.0.0.1:8125", Tags: []string{"env:prod"}}, ""},
+		{"unknown type", MetricSinkConfig{Type: "prometheus"}, "unknown metric sink type"},
+		{"influxdb without url", MetricSinkConfig{Type: "influxdb"}, "requires an http(s) write url"},
+		{"influxdb bad scheme", MetricSinkConfig{Type: "influxdb", URL: "udp://influx:8089"}, "requires an http(s) write url"},
+		{"graphite without port", MetricSinkConfig{Type: "graphite", Address: "graphite.example.com"}, "requires an address"},
+		{"dogstatsd without address", MetricSinkConfig{Type: "dogstatsd"}, "dogstatsd sink requires an address"},
+		{"graphite bad network", MetricSinkConfig{Type: "graphite", Network: "sctp", Address: "g:2003"}, "invalid graphite network"},
+		{"collector without server id", MetricSinkConfig{Type: "collector", Address: "collector.example.net:9443"}, "requires the SPIFFE ID"},
+		{"collector bad server id", MetricSinkConfig{Type: "collector", Address: "collector.example.net:9443", ServerID: "https://collector"}, "invalid SPIFFE ID"},
+		{"collector without certificate", MetricSinkConfig{Type: "collector", Address: "collector.example.net:9443", ServerID: "spiffe://example.org/collector"}, "required for mutual TLS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMetricSink(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInfluxSink_Format(t *testing.T) {
+	sum, tr := metricsTrace()
+	got := (&influxSink{}).format(sum, tr)
+	want := "gtrace_path,target=example.com reached=true,hops=3i,loss_pct=50,stability_pct=100,e2e_avg_ms=20,e2e_p95_ms=20 1700000000000000000\n" +
+		"gtrace_hop,target=example.com,hop=1,ip=192.168.1.1 sent=2i,recv=2i,loss_pct=0,avg_ms=2,best_ms=1,worst_ms=3 1700000000000000000\n" +
+		"gtrace_hop,target=example.com,hop=2 sent=2i,recv=0i,loss_pct=100 1700000000000000000\n" +
+		"gtrace_hop,target=example.com,hop=3,ip=93.184.216.34,asn=15133 sent=2i,recv=1i,loss_pct=50,avg_ms=20,best_ms=20,worst_ms=20 1700000000000000000\n"
+	if got != want {
+		t.Errorf("format() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInfluxSink_Push(t *testing.T) {
+	var body, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, auth = string(data), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := NewMetricSink(MetricSinkConfig{Type: "influxdb", URL: srv.URL + "/api/v2/write?org=o&bucket=b", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, tr := metricsTrace()
+	if err := PushMetrics(context.Background(), []MetricSink{sink}, sum, tr); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Token secret" {
+		t.Errorf("Authorization = %q, want %q", auth, "Token secret")
+	}
+	if !strings.HasPrefix(body, "gtrace_path,target=example.com ") {
+		t.Errorf("body = %q, want line protocol", body)
+	}
+}
+
+func TestInfluxSink_PushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	sink, err := NewMetricSink(MetricSinkConfig{Type: "influxdb", URL: srv.URL + "/write?db=missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, tr := metricsTrace()
+	if err := sink.Push(context.Background(), sum, tr); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Push() error = %v, want 404", err)
+	}
+}
+
+func TestGraphiteSink_Format(t *testing.T) {
+	sum, tr := metricsTrace()
+	got := (&graphiteSink{prefix: "gtrace"}).format(sum, tr)
+	for _, line := range []string{
+		"gtrace.example_com.path.reached 1 1700000000\n",
+		"gtrace.example_com.path.loss_pct 50 1700000000\n",
+		"gtrace.example_com.path.e2e_avg_ms 20 1700000000\n",
+		"gtrace.example_com.hop.01.avg_ms 2 1700000000\n",
+		"gtrace.example_com.hop.02.loss_pct 100 1700000000\n",
+		"gtrace.example_com.hop.03.worst_ms 20 1700000000\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("format() missing %q in\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "hop.02.avg_ms") {
+		t.Error("silent hop should have no RTT metrics")
+	}
+}
+
+func TestGraphiteSink_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	sink, err := NewMetricSink(MetricSinkConfig{Type: "graphite", Address: ln.Addr().String(), Prefix: "net.gtrace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, tr := metricsTrace()
+	if err := sink.Push(context.Background(), sum, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case line := <-lines:
+		if line != "net.gtrace.example_com.path.reached 1 1700000000\n" {
+			t.Errorf("first line = %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no metrics received")
+	}
+}
+
+func TestStatsdSink_Lines(t *testing.T) {
+	sum, tr := metricsTrace()
+
+	plain := (&statsdSink{prefix: "gtrace"}).lines(sum, tr)
+	for _, want := range []string{
+		"gtrace.example_com.path.loss_pct:50|g",
+		"gtrace.example_com.hop.01.avg_ms:2|g",
+		"gtrace.example_com.hop.02.loss_pct:100|g",
+	} {
+		if !slices.Contains(plain, want) {
+			t.Errorf("statsd lines missing %q in %v", want, plain)
+		}
+	}
+
+	tagged := (&statsdSink{prefix: "gtrace", tagged: true, tags: []string{"env:prod"}}).lines(sum, tr)
+	for _, want := range []string{
+		"gtrace.path.loss_pct:50|g|#target:example.com,env:prod",
+		"gtrace.hop.avg_ms:2|g|#target:example.com,hop:1,ip:192.168.1.1,env:prod",
+		"gtrace.hop.loss_pct:100|g|#target:example.com,hop:2,env:prod",
+		"gtrace.hop.worst_ms:20|g|#target:example.com,hop:3,ip:93.184.216.34,asn:15133,env:prod",
+	} {
+		if !slices.Contains(tagged, want) {
+			t.Errorf("dogstatsd lines missing %q in %v", want, tagged)
+		}
+	}
+}
+
+func TestStatsdSink_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	sink, err := NewMetricSink(MetricSinkConfig{Type: "dogstatsd", Address: pc.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, tr := metricsTrace()
+	if err := sink.Push(context.Background(), sum, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n > statsdMaxPacket {
+		t.Errorf("datagram of %d bytes exceeds %d", n, statsdMaxPacket)
+	}
+	if first := strings.SplitN(string(buf[:n]), "\n", 2)[0]; first != "gtrace.path.reached:1|g|#target:example.com" {
+		t.Errorf("first gauge = %q", first)
+	}
+}
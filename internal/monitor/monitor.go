@@ -4,8 +4,12 @@ package monitor
 import (
 	"context"
 	"fmt"
+	"net"
+	"slices"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/diff"
+	"github.com/hervehildenbrand/gtrace/internal/netwatch"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -18,6 +22,8 @@ const (
 	ChangeTypeLoss    ChangeType = "loss"
 	ChangeTypeMPLS    ChangeType = "mpls"
 	ChangeTypeASN     ChangeType = "asn"
+	ChangeTypeFlap    ChangeType = "flap"
+	ChangeTypeTarget  ChangeType = "target"
 )
 
 // Change represents a detected change between traces.
@@ -28,6 +34,7 @@ type Change struct {
 	Timestamp time.Time
 	OldValue  interface{}
 	NewValue  interface{}
+	Rule      *Rule // Alert rule that fired, nil for detected changes
 }
 
 // String formats the change for display.
@@ -38,185 +45,200 @@ func (c Change) String() string {
 // Config holds monitoring configuration.
 type Config struct {
 	Interval         time.Duration // Time between traces
+	Cycles           int           // Stop after this many traces (0 = until cancelled)
 	LatencyThreshold time.Duration // Alert if latency exceeds this
 	LossThreshold    float64       // Alert if loss % exceeds this
 	AlertOnRoute     bool          // Alert on route changes
 	AlertOnMPLS      bool          // Alert on MPLS changes
 	AlertOnASN       bool          // Alert on AS path changes
+	FlapThreshold    float64       // Alert if a hop's address changes in this % of the last FlapWindow cycles (0 = disabled)
+	Schedule         *Schedule     // Trace at these times instead of every Interval (nil = use Interval)
+	Rules            []*Rule       // Alert rules evaluated on every valid trace
+	ConfirmAnomalies bool          // Re-trace before reporting latency and loss anomalies, keeping only those reproduced
 }
 
 // DefaultConfig returns the default monitoring configuration.
 func DefaultConfig() *Config {
 	return &Config{
-		Interval:     10 * time.Second,
-		AlertOnRoute: true,
-		AlertOnMPLS:  true,
-		AlertOnASN:   true,
+		Interval:      10 * time.Second,
+		AlertOnRoute:  true,
+		AlertOnMPLS:   true,
+		AlertOnASN:    true,
+		FlapThreshold: DefaultFlapThreshold,
 	}
 }
 
 // ChangeCallback is called when changes are detected.
 type ChangeCallback func([]Change)
 
+// CycleCallback is called with every trace result. Invalid results overlapped
+// a system sleep or network change and are excluded from change detection.
+// Valid results are already counted in Monitor.Stability.
+type CycleCallback func(result *hop.TraceResult, invalid bool)
+
+// NetworkCallback is called when a system sleep or network change is detected.
+type NetworkCallback func(netwatch.Event)
+
+// ConfirmCallback is called with the anomalies a confirmation re-trace did
+// not reproduce, and that are therefore not reported.
+type ConfirmCallback func([]Change)
+
+// TargetResolver returns the current addresses of the monitored hostname.
+type TargetResolver func(ctx context.Context) ([]net.IP, error)
+
 // Monitor performs continuous traceroute monitoring.
 type Monitor struct {
-	config   *Config
-	callback ChangeCallback
-	previous *hop.TraceResult
+	config    *Config
+	callback  ChangeCallback
+	onCycle   CycleCallback
+	watcher   *netwatch.Watcher
+	onNetwork NetworkCallback
+	onConfirm ConfirmCallback
+	previous  *hop.TraceResult
+	rules     *RuleSet
+	stability *Stability
+
+	target       net.IP // Address being traced
+	resolve      TargetResolver
+	resolveEvery int // Re-resolve the target every this many cycles
 }
 
 // NewMonitor creates a new monitor with the given configuration.
 func NewMonitor(cfg *Config) *Monitor {
 	return &Monitor{
-		config: cfg,
+		config:    cfg,
+		rules:     NewRuleSet(cfg.Rules),
+		stability: NewStability(),
 	}
 }
 
+// Stability returns the route stability tracked over the valid traces so far.
+func (m *Monitor) Stability() *Stability {
+	return m.stability
+}
+
 // SetCallback sets the callback for change notifications.
 func (m *Monitor) SetCallback(cb ChangeCallback) {
 	m.callback = cb
 }
 
-// DetectChanges compares two traces and returns detected changes.
-func (m *Monitor) DetectChanges(prev, curr *hop.TraceResult) []Change {
-	if prev == nil {
-		return nil
-	}
-
-	var changes []Change
-
-	// Compare hops
-	maxHops := len(prev.Hops)
-	if len(curr.Hops) > maxHops {
-		maxHops = len(curr.Hops)
-	}
-
-	for i := 0; i < maxHops; i++ {
-		var prevHop, currHop *hop.Hop
-
-		if i < len(prev.Hops) {
-			prevHop = prev.Hops[i]
-		}
-		if i < len(curr.Hops) {
-			currHop = curr.Hops[i]
-		}
+// SetCycleCallback sets the callback called after each trace.
+func (m *Monitor) SetCycleCallback(cb CycleCallback) {
+	m.onCycle = cb
+}
 
-		hopChanges := m.compareHops(i+1, prevHop, currHop)
-		changes = append(changes, hopChanges...)
-	}
+// SetNetworkWatcher checks w after each trace. When the system slept or the
+// network changed since the previous check, the trace is marked invalid, cb is
+// called, and change detection restarts from the next valid trace rather than
+// alerting on the outage.
+func (m *Monitor) SetNetworkWatcher(w *netwatch.Watcher, cb NetworkCallback) {
+	m.watcher = w
+	m.onNetwork = cb
+}
 
-	return changes
+// SetConfirmCallback sets the callback called with the anomalies dropped by
+// Config.ConfirmAnomalies.
+func (m *Monitor) SetConfirmCallback(cb ConfirmCallback) {
+	m.onConfirm = cb
 }
 
-// compareHops compares two hops and returns changes.
-func (m *Monitor) compareHops(hopNum int, prev, curr *hop.Hop) []Change {
-	var changes []Change
+// SetTargetResolver sets the address the target is traced at and re-resolves
+// the target with resolve every `every` cycles. When the address stops being
+// among the resolved ones (a CDN or GSLB flip), a ChangeTypeTarget change is
+// reported, TargetIP switches to the first resolved address, the network
+// watcher follows the route to it, and change detection restarts against the
+// path to it. Failed resolutions keep the current address.
+func (m *Monitor) SetTargetResolver(ip net.IP, every int, resolve TargetResolver) {
+	m.target = ip
+	m.resolveEvery = every
+	m.resolve = resolve
+}
 
-	// New hop appeared
-	if prev == nil && curr != nil {
-		changes = append(changes, Change{
-			Type:      ChangeTypeRoute,
-			Hop:       hopNum,
-			Message:   fmt.Sprintf("New hop appeared: %s", formatIP(curr.PrimaryIP())),
-			Timestamp: time.Now(),
-		})
-		return changes
-	}
+// TargetIP returns the address the target is currently traced at.
+func (m *Monitor) TargetIP() net.IP {
+	return m.target
+}
 
-	// Hop disappeared
-	if prev != nil && curr == nil {
-		changes = append(changes, Change{
-			Type:      ChangeTypeRoute,
-			Hop:       hopNum,
-			Message:   fmt.Sprintf("Hop disappeared: %s", formatIP(prev.PrimaryIP())),
-			Timestamp: time.Now(),
-		})
-		return changes
+// DetectChanges compares two traces and returns detected changes. Hops are
+// aligned by address and AS, so a hop inserted early in the path is reported
+// once rather than as a change at every later TTL.
+func (m *Monitor) DetectChanges(prev, curr *hop.TraceResult) []Change {
+	if prev == nil {
+		return nil
 	}
 
-	if prev == nil || curr == nil {
-		return changes
+	opts := diff.Options{
+		LatencyThreshold: m.config.LatencyThreshold,
+		LossThreshold:    m.config.LossThreshold,
 	}
 
-	// Route change (IP changed)
-	if m.config.AlertOnRoute {
-		prevIP := prev.PrimaryIP()
-		currIP := curr.PrimaryIP()
-		if prevIP != nil && currIP != nil && !prevIP.Equal(currIP) {
-			changes = append(changes, Change{
-				Type:      ChangeTypeRoute,
-				Hop:       hopNum,
-				Message:   fmt.Sprintf("IP changed from %s to %s", prevIP, currIP),
-				Timestamp: time.Now(),
-				OldValue:  prevIP.String(),
-				NewValue:  currIP.String(),
-			})
+	var changes []Change
+	now := time.Now()
+	for _, c := range diff.Compare(prev, curr, opts).Changes {
+		changeType, ok := m.changeType(c.Kind)
+		if !ok {
+			continue
 		}
-	}
-
-	// Latency change
-	if m.config.LatencyThreshold > 0 {
-		prevRTT := prev.AvgRTT()
-		currRTT := curr.AvgRTT()
-		if currRTT > m.config.LatencyThreshold && currRTT > prevRTT {
-			changes = append(changes, Change{
-				Type:      ChangeTypeLatency,
-				Hop:       hopNum,
-				Message:   fmt.Sprintf("Latency increased from %.1fms to %.1fms (threshold: %.1fms)", msec(prevRTT), msec(currRTT), msec(m.config.LatencyThreshold)),
-				Timestamp: time.Now(),
-				OldValue:  prevRTT,
-				NewValue:  currRTT,
-			})
+		change := Change{
+			Type:      changeType,
+			Hop:       c.TTL(),
+			Message:   c.Message,
+			Timestamp: now,
 		}
+		change.OldValue, change.NewValue = changeValues(changeType, c)
+		changes = append(changes, change)
 	}
 
-	// Loss change
-	if m.config.LossThreshold > 0 {
-		prevLoss := prev.LossPercent()
-		currLoss := curr.LossPercent()
-		if currLoss > m.config.LossThreshold && currLoss > prevLoss {
-			changes = append(changes, Change{
-				Type:      ChangeTypeLoss,
-				Hop:       hopNum,
-				Message:   fmt.Sprintf("Loss increased from %.1f%% to %.1f%% (threshold: %.1f%%)", prevLoss, currLoss, m.config.LossThreshold),
-				Timestamp: time.Now(),
-				OldValue:  prevLoss,
-				NewValue:  currLoss,
-			})
-		}
-	}
+	return changes
+}
 
-	// MPLS change
-	if m.config.AlertOnMPLS {
-		if !mplsEqual(prev.MPLS, curr.MPLS) {
-			changes = append(changes, Change{
-				Type:      ChangeTypeMPLS,
-				Hop:       hopNum,
-				Message:   "MPLS label stack changed",
-				Timestamp: time.Now(),
-			})
-		}
+// changeType maps a diff classification to the change type reported, or
+// false when alerts of that type are disabled.
+func (m *Monitor) changeType(kind diff.Kind) (ChangeType, bool) {
+	switch kind {
+	case diff.KindInserted, diff.KindRemoved, diff.KindReplaced:
+		return ChangeTypeRoute, m.config.AlertOnRoute
+	case diff.KindASN:
+		return ChangeTypeASN, m.config.AlertOnASN
+	case diff.KindMPLS:
+		return ChangeTypeMPLS, m.config.AlertOnMPLS
+	case diff.KindLatency:
+		return ChangeTypeLatency, true
+	case diff.KindLoss:
+		return ChangeTypeLoss, true
 	}
+	return "", false
+}
 
-	// ASN change
-	if m.config.AlertOnASN {
-		if prev.Enrichment.ASN != curr.Enrichment.ASN && prev.Enrichment.ASN > 0 && curr.Enrichment.ASN > 0 {
-			changes = append(changes, Change{
-				Type:      ChangeTypeASN,
-				Hop:       hopNum,
-				Message:   fmt.Sprintf("ASN changed from AS%d to AS%d", prev.Enrichment.ASN, curr.Enrichment.ASN),
-				Timestamp: time.Now(),
-				OldValue:  prev.Enrichment.ASN,
-				NewValue:  curr.Enrichment.ASN,
-			})
+// changeValues returns the old and new values of the changed property.
+func changeValues(changeType ChangeType, c diff.Change) (interface{}, interface{}) {
+	value := func(ref *diff.HopRef) interface{} {
+		if ref == nil {
+			return nil
+		}
+		switch changeType {
+		case ChangeTypeRoute:
+			return ref.IP
+		case ChangeTypeASN:
+			return ref.ASN
+		case ChangeTypeLatency:
+			return time.Duration(ref.AvgRTT * float64(time.Millisecond))
+		case ChangeTypeLoss:
+			return ref.LossPercent
 		}
+		return nil
 	}
-
-	return changes
+	return value(c.Old), value(c.New)
 }
 
-// Run starts the monitoring loop.
+// Run starts the monitoring loop. It returns when ctx is cancelled, or with
+// nil once Config.Cycles traces have run. With a Config.Schedule, traces run
+// at the scheduled times instead of every Config.Interval.
 func (m *Monitor) Run(ctx context.Context, traceFn func(context.Context) (*hop.TraceResult, error)) error {
+	if m.config.Schedule != nil {
+		return m.runScheduled(ctx, traceFn)
+	}
+
 	ticker := time.NewTicker(m.config.Interval)
 	defer ticker.Stop()
 
@@ -225,50 +247,225 @@ func (m *Monitor) Run(ctx context.Context, traceFn func(context.Context) (*hop.T
 	if err != nil {
 		return fmt.Errorf("initial trace failed: %w", err)
 	}
-	m.previous = result
+	m.observe(ctx, result, traceFn)
 
-	for {
+	for cycles := 1; m.config.Cycles == 0 || cycles < m.config.Cycles; cycles++ {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
+			m.rediscover(ctx, cycles)
 			result, err := traceFn(ctx)
 			if err != nil {
 				// Log error but continue
 				continue
 			}
+			m.observe(ctx, result, traceFn)
+		}
+	}
+	return nil
+}
 
-			changes := m.DetectChanges(m.previous, result)
-			if len(changes) > 0 && m.callback != nil {
-				m.callback(changes)
-			}
+// runScheduled traces at every time of Config.Schedule. Unlike Run, a failed
+// first trace is skipped like any other: a scheduled run must outlive an
+// outage at the time it starts.
+func (m *Monitor) runScheduled(ctx context.Context, traceFn func(context.Context) (*hop.TraceResult, error)) error {
+	for cycles := 0; m.config.Cycles == 0 || cycles < m.config.Cycles; cycles++ {
+		timer := time.NewTimer(time.Until(m.config.Schedule.Next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		m.rediscover(ctx, cycles)
+		result, err := traceFn(ctx)
+		if err != nil {
+			continue
+		}
+		m.observe(ctx, result, traceFn)
+	}
+	return nil
+}
+
+// observe runs change detection and the alert rules on a trace, and makes it
+// the baseline of the next one. With Config.ConfirmAnomalies, suspicious
+// hops are first re-traced with traceFn.
+func (m *Monitor) observe(ctx context.Context, result *hop.TraceResult, traceFn func(context.Context) (*hop.TraceResult, error)) {
+	result = m.confirm(ctx, result, traceFn)
+	if !m.completeCycle(result) {
+		return
+	}
+	changes := m.DetectChanges(m.previous, result)
+	changes = append(changes, m.flapChanges()...)
+	m.report(append(changes, m.rules.Evaluate(result, time.Now())...))
+	m.previous = result
+}
+
+// confirm re-traces when result has hops with suspicious measurements: a
+// latency or loss change from the previous trace, an alert rule about to
+// fire, or some but not all probes lost. Each suspicious hop takes the probes
+// of the re-trace, so a one-off outlier or lost reply is not reported while
+// an anomaly seen twice is. Returns result unchanged when nothing is
+// suspicious or the re-trace fails.
+func (m *Monitor) confirm(ctx context.Context, result *hop.TraceResult, traceFn func(context.Context) (*hop.TraceResult, error)) *hop.TraceResult {
+	if !m.config.ConfirmAnomalies {
+		return result
+	}
+	anomalies := m.anomalies(result)
+	ttls := make(map[int]bool)
+	for _, c := range anomalies {
+		ttls[c.Hop] = true
+	}
+	for _, h := range result.Hops {
+		if loss := h.LossPercent(); loss > 0 && loss < 100 {
+			ttls[h.TTL] = true
+		}
+	}
+	if len(ttls) == 0 {
+		return result
+	}
+
+	retry, err := traceFn(ctx)
+	if err != nil {
+		return result
+	}
+	confirmed := mergeConfirmation(result, retry, ttls)
+
+	var dropped []Change
+	kept := m.anomalies(confirmed)
+	for _, c := range anomalies {
+		if !slices.ContainsFunc(kept, func(k Change) bool { return k.Type == c.Type && k.Hop == c.Hop && k.Rule == c.Rule }) {
+			dropped = append(dropped, c)
+		}
+	}
+	if len(dropped) > 0 && m.onConfirm != nil {
+		m.onConfirm(dropped)
+	}
+	return confirmed
+}
 
-			m.previous = result
+// anomalies returns the latency and loss changes and the rule alerts result
+// would raise, the ones a noisy measurement can cause.
+func (m *Monitor) anomalies(result *hop.TraceResult) []Change {
+	changes := anomalyChanges(m.DetectChanges(m.previous, result))
+	return append(changes, m.rules.Pending(result, time.Now())...)
+}
+
+// anomalyChanges returns the latency and loss changes among changes, the
+// ones a noisy measurement can cause.
+func anomalyChanges(changes []Change) []Change {
+	var anomalies []Change
+	for _, c := range changes {
+		if c.Type == ChangeTypeLatency || c.Type == ChangeTypeLoss {
+			anomalies = append(anomalies, c)
 		}
 	}
+	return anomalies
 }
 
-// Helper functions
+// mergeConfirmation returns a copy of result whose hops at ttls carry the
+// probes of the same hop in retry. A hop answered from another address in
+// retry took another path and is kept as it was.
+func mergeConfirmation(result, retry *hop.TraceResult, ttls map[int]bool) *hop.TraceResult {
+	merged := *result
+	merged.Hops = slices.Clone(result.Hops)
+	for i, h := range merged.Hops {
+		r := retry.GetHop(h.TTL)
+		if !ttls[h.TTL] || r == nil {
+			continue
+		}
+		ip, rip := h.PrimaryIP(), r.PrimaryIP()
+		switch {
+		case ip == nil:
+			merged.Hops[i] = r
+		case rip == nil || rip.Equal(ip):
+			c := *h
+			c.Probes = r.Probes
+			merged.Hops[i] = &c
+		}
+	}
+	return &merged
+}
+
+// rediscover re-resolves the target when cycles completed traces are a
+// multiple of the resolver period, and rebases the monitor on a new address.
+func (m *Monitor) rediscover(ctx context.Context, cycles int) {
+	if m.resolve == nil || m.resolveEvery <= 0 || cycles == 0 || cycles%m.resolveEvery != 0 {
+		return
+	}
+	ips, err := m.resolve(ctx)
+	if err != nil || len(ips) == 0 || slices.ContainsFunc(ips, m.target.Equal) {
+		return
+	}
 
-func formatIP(ip interface{}) string {
-	if ip == nil {
-		return "*"
+	old := m.target
+	m.target = ips[0]
+	if m.watcher != nil {
+		// The route to the new address may leave by another interface
+		m.watcher.Retarget(m.target)
 	}
-	return fmt.Sprintf("%v", ip)
+	m.previous = nil
+	m.rules.Reset()
+	m.stability.Reset()
+	m.report([]Change{{
+		Type:      ChangeTypeTarget,
+		Message:   fmt.Sprintf("target address changed from %s to %s, path baseline restarted", old, m.target),
+		Timestamp: time.Now(),
+		OldValue:  old,
+		NewValue:  m.target,
+	}})
 }
 
-func msec(d time.Duration) float64 {
-	return float64(d) / float64(time.Millisecond)
+// flapChanges returns a change for every hop whose address started flapping
+// past Config.FlapThreshold.
+func (m *Monitor) flapChanges() []Change {
+	if m.config.FlapThreshold <= 0 {
+		return nil
+	}
+	var changes []Change
+	now := time.Now()
+	for _, ttl := range m.stability.NewFlaps(m.config.FlapThreshold) {
+		rate := m.stability.FlapRate(ttl)
+		changes = append(changes, Change{
+			Type:      ChangeTypeFlap,
+			Hop:       ttl,
+			Message:   fmt.Sprintf("route flapping: address changed in %.0f%% of the last %d cycles (hop stability %.0f%%)", rate, FlapWindow, m.stability.HopScore(ttl)),
+			Timestamp: now,
+			NewValue:  rate,
+		})
+	}
+	return changes
+}
+
+// report passes changes to the callback.
+func (m *Monitor) report(changes []Change) {
+	if len(changes) > 0 && m.callback != nil {
+		m.callback(changes)
+	}
 }
 
-func mplsEqual(a, b []hop.MPLSLabel) bool {
-	if len(a) != len(b) {
-		return false
+// completeCycle checks the network watcher, reports the cycle, and returns
+// whether the result is valid. An invalid result clears the baseline.
+func (m *Monitor) completeCycle(result *hop.TraceResult) bool {
+	var event *netwatch.Event
+	if m.watcher != nil {
+		event = m.watcher.Check()
 	}
-	for i := range a {
-		if a[i].Label != b[i].Label || a[i].TTL != b[i].TTL {
-			return false
+	if event != nil {
+		m.previous = nil
+		m.rules.Reset()
+		m.stability.Reset()
+		if m.onNetwork != nil {
+			m.onNetwork(*event)
 		}
 	}
-	return true
+	if event == nil {
+		m.stability.ObserveTrace(result)
+	}
+	if m.onCycle != nil {
+		m.onCycle(result, event != nil)
+	}
+	return event == nil
 }
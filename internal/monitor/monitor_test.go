@@ -1,10 +1,12 @@
 package monitor
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/netwatch"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -72,6 +74,22 @@ func TestMonitor_DetectChanges_DetectsNewHop(t *testing.T) {
 	}
 }
 
+func TestMonitor_DetectChanges_InsertedHopReportedOnce(t *testing.T) {
+	m := NewMonitor(DefaultConfig())
+
+	prev := createTrace([]string{"192.168.1.1", "10.0.0.1", "10.0.0.2", "8.8.8.8"})
+	curr := createTrace([]string{"192.168.1.1", "10.0.0.9", "10.0.0.1", "10.0.0.2", "8.8.8.8"})
+
+	changes := m.DetectChanges(prev, curr)
+
+	if len(changes) != 1 {
+		t.Fatalf("expected a single change for the inserted hop, got %v", changes)
+	}
+	if changes[0].Type != ChangeTypeRoute || changes[0].Hop != 2 {
+		t.Errorf("expected route change at hop 2, got %v", changes[0])
+	}
+}
+
 func TestMonitor_DetectChanges_DetectsLatencyIncrease(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.LatencyThreshold = 50 * time.Millisecond
@@ -143,6 +161,64 @@ func TestChange_String_FormatsNicely(t *testing.T) {
 	}
 }
 
+func TestMonitor_Run_NetworkChangeInvalidatesCycle(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = 5 * time.Millisecond
+	cfg.LossThreshold = 5.0
+	m := NewMonitor(cfg)
+
+	// Cycle 2 runs while the route moves from wlan0 to eth0 and loses everything
+	traces := []*hop.TraceResult{
+		createTraceWithLoss("8.8.8.8", 0),
+		createTraceWithLoss("8.8.8.8", 3),
+		createTraceWithLoss("8.8.8.8", 0),
+		createTraceWithLoss("8.8.8.8", 0),
+	}
+	states := []netwatch.State{{Interface: "wlan0"}, {Interface: "wlan0"}, {Interface: "eth0"}, {Interface: "eth0"}, {Interface: "eth0"}}
+	checks := 0
+	m.SetNetworkWatcher(netwatch.NewWithStateFunc(net.ParseIP("8.8.8.8"), func(net.IP) netwatch.State {
+		st := states[checks]
+		if checks < len(states)-1 {
+			checks++
+		}
+		return st
+	}), func(ev netwatch.Event) {})
+
+	var invalid []bool
+	m.SetCycleCallback(func(_ *hop.TraceResult, inv bool) {
+		invalid = append(invalid, inv)
+	})
+	var alerts []Change
+	m.SetCallback(func(changes []Change) {
+		alerts = append(alerts, changes...)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	m.Run(ctx, func(context.Context) (*hop.TraceResult, error) {
+		tr := traces[n]
+		n++
+		if n == len(traces) {
+			cancel()
+		}
+		return tr, nil
+	})
+
+	want := []bool{false, true, false, false}
+	if len(invalid) != len(want) {
+		t.Fatalf("expected %d cycles, got %v", len(want), invalid)
+	}
+	for i := range want {
+		if invalid[i] != want[i] {
+			t.Errorf("cycle %d: invalid = %v, want %v", i+1, invalid[i], want[i])
+		}
+	}
+	if len(alerts) != 0 {
+		t.Errorf("expected no alerts for the outage, got %v", alerts)
+	}
+}
+
 // Helper functions
 
 func createTrace(ips []string) *hop.TraceResult {
@@ -178,3 +254,234 @@ func createTraceWithLoss(ip string, lossCount int) *hop.TraceResult {
 	tr.AddHop(h)
 	return tr
 }
+
+func TestMonitor_Run_StopsAfterCycles(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 3
+	m := NewMonitor(cfg)
+
+	n := 0
+	err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		n++
+		return createTraceWithLoss("8.8.8.8", 0), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 traces, got %d", n)
+	}
+}
+
+func TestMonitor_Run_TargetAddressChangeRebases(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 5
+	m := NewMonitor(cfg)
+
+	oldIP, newIP := net.ParseIP("192.0.2.10"), net.ParseIP("198.51.100.20")
+	resolutions := 0
+	m.SetTargetResolver(oldIP, 2, func(context.Context) ([]net.IP, error) {
+		resolutions++
+		if resolutions == 1 {
+			// Round robin still returning the traced address is no flip
+			return []net.IP{net.ParseIP("192.0.2.11"), oldIP}, nil
+		}
+		return []net.IP{newIP}, nil
+	})
+
+	var alerts []Change
+	m.SetCallback(func(changes []Change) {
+		alerts = append(alerts, changes...)
+	})
+	var traced []string
+	err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		target := m.TargetIP().String()
+		traced = append(traced, target)
+		return createTrace([]string{"10.0.0.1", "10.1." + target[len(target)-2:] + ".1", target}), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resolutions != 2 {
+		t.Errorf("expected resolutions after cycles 2 and 4, got %d", resolutions)
+	}
+	want := []string{"192.0.2.10", "192.0.2.10", "192.0.2.10", "192.0.2.10", "198.51.100.20"}
+	if len(traced) != len(want) {
+		t.Fatalf("traced %v, want %v", traced, want)
+	}
+	for i := range want {
+		if traced[i] != want[i] {
+			t.Errorf("trace %d to %s, want %s", i+1, traced[i], want[i])
+		}
+	}
+
+	// The new path is the new baseline, not a route change
+	if len(alerts) != 1 || alerts[0].Type != ChangeTypeTarget {
+		t.Fatalf("expected a single target change, got %v", alerts)
+	}
+	if !alerts[0].OldValue.(net.IP).Equal(oldIP) || !alerts[0].NewValue.(net.IP).Equal(newIP) {
+		t.Errorf("unexpected change values: %v -> %v", alerts[0].OldValue, alerts[0].NewValue)
+	}
+}
+
+func TestMonitor_Run_TargetAddressChangeRetargetsWatcher(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 3
+	m := NewMonitor(cfg)
+
+	oldIP, newIP := net.ParseIP("192.0.2.10"), net.ParseIP("198.51.100.20")
+	m.SetTargetResolver(oldIP, 1, func(context.Context) ([]net.IP, error) {
+		return []net.IP{newIP}, nil
+	})
+	// The new address is reached through another interface
+	var watched []string
+	m.SetNetworkWatcher(netwatch.NewWithStateFunc(oldIP, func(ip net.IP) netwatch.State {
+		watched = append(watched, ip.String())
+		if ip.Equal(newIP) {
+			return netwatch.State{Interface: "eth0"}
+		}
+		return netwatch.State{Interface: "wlan0"}
+	}), func(ev netwatch.Event) {
+		t.Errorf("unexpected network event: %v", ev)
+	})
+	m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		return createTrace([]string{"10.0.0.1", m.TargetIP().String()}), nil
+	})
+
+	if got := watched[len(watched)-1]; got != newIP.String() {
+		t.Errorf("watcher checks the route to %s, want %s", got, newIP)
+	}
+}
+
+func TestMonitor_Run_TargetResolutionFailureKeepsAddress(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 3
+	m := NewMonitor(cfg)
+
+	ip := net.ParseIP("192.0.2.10")
+	m.SetTargetResolver(ip, 1, func(context.Context) ([]net.IP, error) {
+		return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	})
+	var alerts []Change
+	m.SetCallback(func(changes []Change) {
+		alerts = append(alerts, changes...)
+	})
+	m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		return createTrace([]string{"10.0.0.1", m.TargetIP().String()}), nil
+	})
+
+	if !m.TargetIP().Equal(ip) || len(alerts) != 0 {
+		t.Errorf("target = %v, alerts = %v, want the original address and no alerts", m.TargetIP(), alerts)
+	}
+}
+
+func TestMonitor_Run_ConfirmAnomaliesDropsOneOffOutlier(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 2
+	cfg.LatencyThreshold = 50 * time.Millisecond
+	cfg.ConfirmAnomalies = true
+	m := NewMonitor(cfg)
+
+	var alerts, dropped []Change
+	m.SetCallback(func(changes []Change) { alerts = append(alerts, changes...) })
+	m.SetConfirmCallback(func(changes []Change) { dropped = append(dropped, changes...) })
+
+	// Second trace spikes, its confirmation re-trace does not
+	rtts := []time.Duration{5 * time.Millisecond, 200 * time.Millisecond, 6 * time.Millisecond}
+	n := 0
+	err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		n++
+		return createTraceWithRTT("8.8.8.8", rtts[n-1]), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 2 traces and a re-trace, got %d traces", n)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("expected the outlier not reported, got %v", alerts)
+	}
+	if len(dropped) != 1 || dropped[0].Type != ChangeTypeLatency {
+		t.Errorf("expected the latency change dropped, got %v", dropped)
+	}
+}
+
+func TestMonitor_Run_ConfirmAnomaliesReportsReproduced(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 2
+	cfg.LatencyThreshold = 50 * time.Millisecond
+	cfg.ConfirmAnomalies = true
+	m := NewMonitor(cfg)
+
+	var alerts []Change
+	m.SetCallback(func(changes []Change) { alerts = append(alerts, changes...) })
+
+	rtts := []time.Duration{5 * time.Millisecond, 200 * time.Millisecond, 190 * time.Millisecond}
+	n := 0
+	err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		n++
+		return createTraceWithRTT("8.8.8.8", rtts[n-1]), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Type != ChangeTypeLatency {
+		t.Fatalf("expected the reproduced latency change reported, got %v", alerts)
+	}
+	if got := alerts[0].NewValue.(time.Duration); got != 190*time.Millisecond {
+		t.Errorf("expected the re-traced RTT reported, got %v", got)
+	}
+}
+
+func TestMonitor_Run_ConfirmAnomaliesRetracesPartialLoss(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 1
+	cfg.ConfirmAnomalies = true
+	m := NewMonitor(cfg)
+
+	var summaries []*hop.TraceResult
+	m.SetCycleCallback(func(result *hop.TraceResult, invalid bool) { summaries = append(summaries, result) })
+
+	losses := []int{1, 0}
+	n := 0
+	err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		n++
+		return createTraceWithLoss("8.8.8.8", losses[n-1]), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected a re-trace of the hop with a lost reply, got %d traces", n)
+	}
+	if len(summaries) != 1 || summaries[0].Hops[0].LossPercent() != 0 {
+		t.Errorf("expected the cycle to carry the re-traced probes, got %+v", summaries)
+	}
+}
+
+func TestMergeConfirmation_KeepsHopsOnAnotherPath(t *testing.T) {
+	result := createTrace([]string{"10.0.0.1", "10.0.0.2", "8.8.8.8"})
+	result.Hops[0].Enrichment.ASN = 64500
+	retry := createTrace([]string{"10.0.0.1", "10.9.9.9", "8.8.8.8"})
+	retry.Hops[0].Probes[0].RTT = time.Millisecond
+
+	merged := mergeConfirmation(result, retry, map[int]bool{1: true, 2: true})
+	if merged.Hops[0].Probes[0].RTT != time.Millisecond || merged.Hops[0].Enrichment.ASN != 64500 {
+		t.Errorf("expected hop 1 to take the re-traced probes and keep its enrichment, got %+v", merged.Hops[0])
+	}
+	if merged.Hops[1] != result.Hops[1] {
+		t.Error("expected hop 2 answered from another address kept as it was")
+	}
+	if result.Hops[0].Probes[0].RTT != 5*time.Millisecond {
+		t.Error("merging must not modify the original trace")
+	}
+}
@@ -0,0 +1,277 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// MQTT 3.1.1 control packet types, in the high nibble of the first byte.
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttDisconnect = 14
+)
+
+// mqttKeepAlive is the keep-alive announced in CONNECT, in seconds. Each
+// publish uses its own short connection, so it never needs a ping.
+const mqttKeepAlive = 60
+
+// mqttPublisher publishes messages to one topic of an MQTT broker, over a
+// new connection per message like the syslog sink. QoS 0 and 1 are
+// supported; at QoS 1 the broker's acknowledgement is awaited.
+type mqttPublisher struct {
+	address  string
+	tls      *tls.Config // nil for plain TCP
+	topic    string
+	qos      byte
+	username string
+	password string
+	clientID string
+}
+
+// newMQTTPublisher validates an MQTT sink configuration. broker is
+// mqtt://host[:1883] or mqtts://host[:8883]; caFile, when set, replaces the
+// system roots for verifying the broker.
+func newMQTTPublisher(broker, topic string, qos int, username, password, clientID, caFile string) (*mqttPublisher, error) {
+	u, err := url.Parse(broker)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("mqtt sink requires a broker (mqtt://host:port or mqtts://host:port)")
+	}
+	p := &mqttPublisher{topic: topic, qos: byte(qos), username: username, password: password, clientID: clientID}
+
+	port := u.Port()
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		if port == "" {
+			port = "1883"
+		}
+	case "mqtts", "ssl", "tls":
+		if port == "" {
+			port = "8883"
+		}
+		p.tls = &tls.Config{ServerName: u.Hostname()}
+		if caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("mqtt CA file: %w", err)
+			}
+			p.tls.RootCAs = x509.NewCertPool()
+			if !p.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("mqtt CA file %s holds no PEM certificate", caFile)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid mqtt broker scheme %q: must be mqtt or mqtts", u.Scheme)
+	}
+	p.address = net.JoinHostPort(u.Hostname(), port)
+
+	if qos != 0 && qos != 1 {
+		return nil, fmt.Errorf("invalid mqtt qos %d: must be 0 or 1", qos)
+	}
+	if p.topic == "" {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "gtrace"
+		}
+		p.topic = "gtrace/" + host
+	}
+	if p.clientID == "" {
+		b := make([]byte, 4)
+		rand.Read(b)
+		p.clientID = "gtrace-" + hex.EncodeToString(b)
+	}
+	return p, nil
+}
+
+// publish sends payload to the topic.
+func (p *mqttPublisher) publish(ctx context.Context, payload []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if p.tls != nil {
+		tlsConn := tls.Client(conn, p.tls)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("mqtt: %w", err)
+		}
+		conn = tlsConn
+	}
+	r := bufio.NewReader(conn)
+
+	if _, err := conn.Write(p.connectPacket()); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	typ, body, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	if typ != mqttConnack || len(body) != 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", typ)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("mqtt: broker refused connection (return code %d)", body[1])
+	}
+
+	const packetID = 1
+	if _, err := conn.Write(p.publishPacket(payload, packetID)); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	if p.qos == 1 {
+		typ, body, err := readMQTTPacket(r)
+		if err != nil {
+			return fmt.Errorf("mqtt: %w", err)
+		}
+		if typ != mqttPuback || len(body) != 2 || binary.BigEndian.Uint16(body) != packetID {
+			return fmt.Errorf("mqtt: expected PUBACK, got packet type %d", typ)
+		}
+	}
+
+	conn.Write([]byte{mqttDisconnect << 4, 0})
+	return nil
+}
+
+// connectPacket returns the CONNECT packet: a clean session with the
+// client ID and optional credentials.
+func (p *mqttPublisher) connectPacket() []byte {
+	var flags byte = 0x02 // Clean session
+	body := mqttString(nil, "MQTT")
+	payload := mqttString(nil, p.clientID)
+	if p.username != "" {
+		flags |= 0x80
+		payload = mqttString(payload, p.username)
+		if p.password != "" {
+			flags |= 0x40
+			payload = mqttString(payload, p.password)
+		}
+	}
+	body = append(body, 4, flags) // Protocol level 4 = 3.1.1
+	body = binary.BigEndian.AppendUint16(body, mqttKeepAlive)
+	return mqttPacket(mqttConnect<<4, append(body, payload...))
+}
+
+// publishPacket returns the PUBLISH packet of payload.
+func (p *mqttPublisher) publishPacket(payload []byte, packetID uint16) []byte {
+	body := mqttString(nil, p.topic)
+	if p.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	return mqttPacket(mqttPublish<<4|p.qos<<1, append(body, payload...))
+}
+
+// mqttString appends s with its 16-bit length prefix.
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket frames body with the fixed header: the first byte and the
+// variable-length remaining length.
+func mqttPacket(first byte, body []byte) []byte {
+	pkt := []byte{first}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		pkt = append(pkt, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(pkt, body...)
+}
+
+// readMQTTPacket reads one packet and returns its type and the bytes after
+// the fixed header.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var n, shift int
+	for {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return first >> 4, body, nil
+}
+
+// mqttSink publishes rule alerts as JSON, the body webhooks receive.
+type mqttSink struct {
+	pub *mqttPublisher
+}
+
+func newMQTTSink(cfg SinkConfig) (Sink, error) {
+	pub, err := newMQTTPublisher(cfg.Broker, cfg.Topic, cfg.QoS, cfg.Username, cfg.Password, cfg.ClientID, cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Topic == "" {
+		pub.topic += "/alerts"
+	}
+	return &mqttSink{pub: pub}, nil
+}
+
+func (s *mqttSink) Send(ctx context.Context, ev AlertEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.pub.publish(ctx, body)
+}
+
+// mqttMetricSink publishes cycle summaries as JSON, the --json line.
+type mqttMetricSink struct {
+	pub *mqttPublisher
+}
+
+func newMQTTMetricSink(cfg MetricSinkConfig) (MetricSink, error) {
+	pub, err := newMQTTPublisher(cfg.Broker, cfg.Topic, cfg.QoS, cfg.Username, cfg.Password, cfg.ClientID, cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Topic == "" {
+		pub.topic += "/summary"
+	}
+	return &mqttMetricSink{pub: pub}, nil
+}
+
+func (s *mqttMetricSink) Push(ctx context.Context, sum Summary, tr *hop.TraceResult) error {
+	return s.pub.publish(ctx, []byte(sum.JSON()))
+}
@@ -0,0 +1,214 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mqttMessage is what the fake broker received in one session.
+type mqttMessage struct {
+	clientID, username, password string
+	topic                        string
+	qos                          byte
+	payload                      string
+}
+
+// fakeMQTTBroker accepts one session, answers CONNECT with returnCode and
+// acknowledges a QoS 1 PUBLISH.
+func fakeMQTTBroker(t *testing.T, returnCode byte) (string, <-chan mqttMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	msgs := make(chan mqttMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+
+		var msg mqttMessage
+		typ, body, err := readMQTTPacket(r)
+		if err != nil || typ != mqttConnect {
+			return
+		}
+		// Protocol name (6), level (1), flags (1), keep-alive (2)
+		flags := body[7]
+		fields := readMQTTStrings(body[10:])
+		msg.clientID = fields[0]
+		if flags&0x80 != 0 {
+			msg.username = fields[1]
+		}
+		if flags&0x40 != 0 {
+			msg.password = fields[2]
+		}
+		conn.Write([]byte{mqttConnack << 4, 2, 0, returnCode})
+		if returnCode != 0 {
+			return
+		}
+
+		first, _ := r.Peek(1)
+		typ, body, err = readMQTTPacket(r)
+		if err != nil || typ != mqttPublish {
+			return
+		}
+		msg.qos = first[0] >> 1 & 0x03
+		n := int(binary.BigEndian.Uint16(body))
+		msg.topic = string(body[2 : 2+n])
+		body = body[2+n:]
+		if msg.qos > 0 {
+			conn.Write([]byte{mqttPuback << 4, 2, body[0], body[1]})
+			body = body[2:]
+		}
+		msg.payload = string(body)
+		msgs <- msg
+	}()
+	return ln.Addr().String(), msgs
+}
+
+// readMQTTStrings splits length-prefixed strings.
+func readMQTTStrings(b []byte) []string {
+	var out []string
+	for len(b) >= 2 {
+		n := int(binary.BigEndian.Uint16(b))
+		out = append(out, string(b[2:2+n]))
+		b = b[2+n:]
+	}
+	return append(out, "", "")
+}
+
+func receiveMQTT(t *testing.T, msgs <-chan mqttMessage) mqttMessage {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("broker received no message")
+	}
+	return mqttMessage{}
+}
+
+func TestMQTTSink_AlertQoS1(t *testing.T) {
+	addr, msgs := fakeMQTTBroker(t, 0)
+	sink, err := NewSink(SinkConfig{
+		Type: "mqtt", Broker: "mqtt://" + addr, Topic: "edge/paris-01/alerts", QoS: 1,
+		Username: "device", Password: "secret", ClientID: "paris-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Send(ctx, testAlertEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := receiveMQTT(t, msgs)
+	if msg.clientID != "paris-01" || msg.username != "device" || msg.password != "secret" {
+		t.Errorf("unexpected session: %+v", msg)
+	}
+	if msg.topic != "edge/paris-01/alerts" || msg.qos != 1 {
+		t.Errorf("topic = %q, qos = %d", msg.topic, msg.qos)
+	}
+	if !strings.Contains(msg.payload, `"type":"loss","hop":12`) {
+		t.Errorf("payload = %s", msg.payload)
+	}
+}
+
+func TestMQTTMetricSink_SummaryDefaultTopic(t *testing.T) {
+	addr, msgs := fakeMQTTBroker(t, 0)
+	sink, err := NewMetricSink(MetricSinkConfig{Type: "mqtt", Broker: "mqtt://" + addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, tr := metricsTrace()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Push(ctx, sum, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := receiveMQTT(t, msgs)
+	if !strings.HasPrefix(msg.topic, "gtrace/") || !strings.HasSuffix(msg.topic, "/summary") {
+		t.Errorf("topic = %q, want gtrace/HOSTNAME/summary", msg.topic)
+	}
+	if msg.qos != 0 || !strings.HasPrefix(msg.clientID, "gtrace-") {
+		t.Errorf("qos = %d, client ID = %q", msg.qos, msg.clientID)
+	}
+	if msg.payload != sum.JSON() {
+		t.Errorf("payload = %s, want %s", msg.payload, sum.JSON())
+	}
+}
+
+func TestMQTTSink_Refused(t *testing.T) {
+	addr, _ := fakeMQTTBroker(t, 5)
+	sink, err := NewSink(SinkConfig{Type: "mqtt", Broker: "mqtt://" + addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Send(ctx, testAlertEvent()); err == nil || !strings.Contains(err.Error(), "return code 5") {
+		t.Errorf("Send() error = %v, want a refused connection", err)
+	}
+}
+
+func TestNewMQTTPublisher_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		broker  string
+		qos     int
+		caFile  string
+		wantErr string
+		address string
+	}{
+		{"default port", "mqtt://broker.example.com", 0, "", "", "broker.example.com:1883"},
+		{"tls default port", "mqtts://broker.example.com", 1, "", "", "broker.example.com:8883"},
+		{"no broker", "", 0, "", "requires a broker", ""},
+		{"bad scheme", "http://broker.example.com", 0, "", "invalid mqtt broker scheme", ""},
+		{"qos 2", "mqtt://broker.example.com", 2, "", "invalid mqtt qos", ""},
+		{"missing CA file", "mqtts://broker.example.com", 0, "/nonexistent/ca.pem", "mqtt CA file", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newMQTTPublisher(tt.broker, "t", tt.qos, "", "", "", tt.caFile)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if p.address != tt.address {
+					t.Errorf("address = %q, want %q", p.address, tt.address)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMQTTPacket_RemainingLength(t *testing.T) {
+	body := bytes.Repeat([]byte{'x'}, 321)
+	pkt := mqttPacket(mqttPublish<<4, body)
+	// 321 = 0x41 + 2*128
+	if !bytes.Equal(pkt[:3], []byte{mqttPublish << 4, 0xC1, 0x02}) {
+		t.Errorf("fixed header = % x", pkt[:3])
+	}
+	typ, got, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(pkt)))
+	if err != nil || typ != mqttPublish || !bytes.Equal(got, body) {
+		t.Errorf("readMQTTPacket() = %d, %d bytes, %v", typ, len(got), err)
+	}
+}
@@ -0,0 +1,458 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// RuleWindow is the number of cycles of RTT samples kept per hop for the
+// percentile and jitter metrics.
+const RuleWindow = 10
+
+// Rule is an alert rule such as "hop(last).loss > 5% for 3 cycles": a hop
+// selector, a metric, a comparison and how many consecutive cycles the
+// comparison must hold before the rule fires.
+//
+// Selectors: hop(last) (the final hop), hop(N) (TTL N), hop(ADDRESS) (the hop
+// answering from an address) and any(hop) (any hop of the path).
+//
+// Metrics: loss (percent), rtt, rtt_min and rtt_max over the probes of the
+// cycle, rtt_pNN (percentile) and jitter (mean difference between
+// consecutive samples) over the last RuleWindow cycles.
+type Rule struct {
+	Condition string  // The rule without its action, as written
+	Selector  string  // last, any, a TTL or an address
+	Metric    string  // loss, rtt, rtt_min, rtt_max, rtt_pNN or jitter
+	Op        string  // >, >=, < or <=
+	Threshold float64 // Percent for loss, milliseconds otherwise
+	Cycles    int     // Consecutive cycles the comparison must hold
+	Action    *Action // Run when the rule fires, in addition to the alert
+	Sinks     []Sink  // Also receive the alert (set from the configuration file)
+}
+
+var ruleRe = regexp.MustCompile(`^(?:hop\(([^)]+)\)|(any)\(hop\))\.([a-z0-9_]+)\s*(>=|<=|>|<)\s*(\S+)(?:\s+for\s+(\d+)\s+cycles?)?$`)
+
+var percentileRe = regexp.MustCompile(`^rtt_p(\d{1,2})$`)
+
+// ParseRule parses a rule: SELECTOR.METRIC OP VALUE [for N cycles]
+// [then exec COMMAND | then webhook URL].
+func ParseRule(s string) (*Rule, error) {
+	cond, action, hasAction := strings.Cut(strings.TrimSpace(s), " then ")
+	cond = strings.ToLower(strings.TrimSpace(cond))
+
+	m := ruleRe.FindStringSubmatch(cond)
+	if m == nil {
+		return nil, fmt.Errorf("invalid alert rule %q: expected SELECTOR.METRIC OP VALUE [for N cycles], e.g. hop(last).loss > 5%% for 3 cycles", s)
+	}
+
+	r := &Rule{Condition: cond, Metric: m[3], Op: m[4], Cycles: 1}
+	switch {
+	case m[2] != "":
+		r.Selector = "any"
+	case m[1] == "last":
+		r.Selector = "last"
+	default:
+		if ttl, err := strconv.Atoi(m[1]); err == nil && ttl > 0 {
+			r.Selector = m[1]
+		} else if net.ParseIP(m[1]) != nil {
+			r.Selector = m[1]
+		} else {
+			return nil, fmt.Errorf("invalid alert rule %q: hop selector must be last, a TTL or an address", s)
+		}
+	}
+
+	switch {
+	case r.Metric == "loss":
+		loss, err := strconv.ParseFloat(strings.TrimSuffix(m[5], "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %q: loss must be compared with a percentage", s)
+		}
+		r.Threshold = loss
+	case r.Metric == "rtt" || r.Metric == "rtt_min" || r.Metric == "rtt_max" || r.Metric == "jitter" || percentileRe.MatchString(r.Metric):
+		d, err := time.ParseDuration(m[5])
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %q: %s must be compared with a duration (e.g. 150ms)", s, r.Metric)
+		}
+		r.Threshold = hop.Milliseconds(d)
+	default:
+		return nil, fmt.Errorf("invalid alert rule %q: unknown metric %q (loss, rtt, rtt_min, rtt_max, rtt_pNN, jitter)", s, r.Metric)
+	}
+
+	if m[6] != "" {
+		cycles, err := strconv.Atoi(m[6])
+		if err != nil || cycles < 1 {
+			return nil, fmt.Errorf("invalid alert rule %q: cycles must be >= 1", s)
+		}
+		r.Cycles = cycles
+	}
+
+	if hasAction {
+		a, err := parseAction(strings.TrimSpace(action))
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %q: %w", s, err)
+		}
+		r.Action = a
+	}
+	return r, nil
+}
+
+// String returns the rule as written, without its action.
+func (r *Rule) String() string {
+	return r.Condition
+}
+
+// changeType returns the change type alerts of the rule are reported as.
+func (r *Rule) changeType() ChangeType {
+	if r.Metric == "loss" {
+		return ChangeTypeLoss
+	}
+	return ChangeTypeLatency
+}
+
+// holds reports whether value satisfies the comparison.
+func (r *Rule) holds(value float64) bool {
+	switch r.Op {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	default:
+		return value <= r.Threshold
+	}
+}
+
+// RuleSet evaluates alert rules against the consecutive traces of a target.
+type RuleSet struct {
+	rules   []*Rule
+	streaks []int
+	samples map[int][][]time.Duration // RTT samples per TTL, one slice per cycle
+}
+
+// NewRuleSet creates a rule set. A nil rule set evaluates nothing.
+func NewRuleSet(rules []*Rule) *RuleSet {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &RuleSet{
+		rules:   rules,
+		streaks: make([]int, len(rules)),
+		samples: make(map[int][][]time.Duration),
+	}
+}
+
+// Reset forgets the RTT history and the cycles each rule has held for.
+func (s *RuleSet) Reset() {
+	if s == nil {
+		return
+	}
+	clear(s.streaks)
+	clear(s.samples)
+}
+
+// Evaluate records tr and returns an alert for every rule that has now held
+// for its number of cycles. A rule fires once per episode: it fires again
+// only after its comparison stopped holding.
+func (s *RuleSet) Evaluate(tr *hop.TraceResult, now time.Time) []Change {
+	if s == nil || tr == nil {
+		return nil
+	}
+	s.record(tr)
+
+	var changes []Change
+	for i, r := range s.rules {
+		h, value, ok := s.match(r, tr)
+		if !ok {
+			s.streaks[i] = 0
+			continue
+		}
+		s.streaks[i]++
+		if s.streaks[i] != r.Cycles {
+			continue
+		}
+		changes = append(changes, ruleAlert(r, h, value, now))
+	}
+	return changes
+}
+
+// Pending returns the alerts Evaluate would raise for tr, without recording
+// it: windowed metrics only cover the traces evaluated so far.
+func (s *RuleSet) Pending(tr *hop.TraceResult, now time.Time) []Change {
+	if s == nil || tr == nil {
+		return nil
+	}
+	var changes []Change
+	for i, r := range s.rules {
+		if s.streaks[i]+1 != r.Cycles {
+			continue
+		}
+		if h, value, ok := s.match(r, tr); ok {
+			changes = append(changes, ruleAlert(r, h, value, now))
+		}
+	}
+	return changes
+}
+
+// ruleAlert returns the alert of rule r holding with value at hop h.
+func ruleAlert(r *Rule, h *hop.Hop, value float64, now time.Time) Change {
+	c := Change{
+		Type:      r.changeType(),
+		Hop:       h.TTL,
+		Message:   fmt.Sprintf("%s: %s %s at %s", r, r.Metric, formatRuleValue(r, value), hopAddress(h)),
+		Timestamp: now,
+		Rule:      r,
+	}
+	if c.Type == ChangeTypeLoss {
+		c.NewValue = value
+	} else {
+		c.NewValue = time.Duration(value * float64(time.Millisecond))
+	}
+	return c
+}
+
+// record adds the RTT samples of tr to the per-hop history.
+func (s *RuleSet) record(tr *hop.TraceResult) {
+	for _, h := range tr.Hops {
+		var rtts []time.Duration
+		for _, p := range h.Probes {
+			if !p.Timeout && p.IP != nil {
+				rtts = append(rtts, p.RTT)
+			}
+		}
+		window := append(s.samples[h.TTL], rtts)
+		if len(window) > RuleWindow {
+			window = window[len(window)-RuleWindow:]
+		}
+		s.samples[h.TTL] = window
+	}
+}
+
+// match returns the first selected hop whose metric satisfies the rule.
+func (s *RuleSet) match(r *Rule, tr *hop.TraceResult) (*hop.Hop, float64, bool) {
+	for _, h := range selectHops(r.Selector, tr) {
+		if value, ok := s.metric(r.Metric, h); ok && r.holds(value) {
+			return h, value, true
+		}
+	}
+	return nil, 0, false
+}
+
+// selectHops returns the hops of tr a selector designates.
+func selectHops(selector string, tr *hop.TraceResult) []*hop.Hop {
+	if len(tr.Hops) == 0 {
+		return nil
+	}
+	switch selector {
+	case "any":
+		return tr.Hops
+	case "last":
+		return tr.Hops[len(tr.Hops)-1:]
+	}
+	if ttl, err := strconv.Atoi(selector); err == nil {
+		if h := tr.GetHop(ttl); h != nil {
+			return []*hop.Hop{h}
+		}
+		return nil
+	}
+	ip := net.ParseIP(selector)
+	for _, h := range tr.Hops {
+		for _, p := range h.Probes {
+			if p.IP.Equal(ip) {
+				return []*hop.Hop{h}
+			}
+		}
+	}
+	return nil
+}
+
+// metric computes a metric of h, and whether it is defined: RTT metrics
+// need replies.
+func (s *RuleSet) metric(name string, h *hop.Hop) (float64, bool) {
+	if len(h.Probes) == 0 {
+		return 0, false
+	}
+	if name == "loss" {
+		return h.LossPercent(), true
+	}
+
+	var cycle []float64
+	for _, p := range h.Probes {
+		if !p.Timeout && p.IP != nil {
+			cycle = append(cycle, hop.Milliseconds(p.RTT))
+		}
+	}
+	var window []time.Duration
+	for _, rtts := range s.samples[h.TTL] {
+		window = append(window, rtts...)
+	}
+
+	switch name {
+	case "rtt":
+		if len(cycle) == 0 {
+			return 0, false
+		}
+		return hop.Milliseconds(h.AvgRTT()), true
+	case "rtt_min":
+		if len(cycle) == 0 {
+			return 0, false
+		}
+		return slices.Min(cycle), true
+	case "rtt_max":
+		if len(cycle) == 0 {
+			return 0, false
+		}
+		return slices.Max(cycle), true
+	case "jitter":
+		if len(window) < 2 {
+			return 0, false
+		}
+		sum := 0.0
+		for i := 1; i < len(window); i++ {
+			sum += math.Abs(hop.Milliseconds(window[i] - window[i-1]))
+		}
+		return sum / float64(len(window)-1), true
+	}
+
+	m := percentileRe.FindStringSubmatch(name)
+	if m == nil || len(window) == 0 {
+		return 0, false
+	}
+	p, _ := strconv.Atoi(m[1])
+	return hop.Milliseconds(percentile(window, float64(p))), true
+}
+
+// formatRuleValue formats a metric value in the unit of its rule.
+func formatRuleValue(r *Rule, value float64) string {
+	if r.Metric == "loss" {
+		return fmt.Sprintf("%.1f%%", value)
+	}
+	return fmt.Sprintf("%.1fms", value)
+}
+
+// hopAddress returns the address a hop answered from, or "*".
+func hopAddress(h *hop.Hop) string {
+	if ip := h.PrimaryIP(); ip != nil {
+		return ip.String()
+	}
+	return "*"
+}
+
+// Action is run when a rule fires.
+type Action struct {
+	Kind string // exec or webhook
+	Arg  string // Shell command or URL
+}
+
+// actionTimeout bounds how long an action may delay the monitoring loop.
+const actionTimeout = 10 * time.Second
+
+// parseAction parses "exec COMMAND" or "webhook URL".
+func parseAction(s string) (*Action, error) {
+	kind, arg, _ := strings.Cut(s, " ")
+	arg = strings.TrimSpace(arg)
+	switch {
+	case kind == "exec" && arg != "":
+		return &Action{Kind: kind, Arg: arg}, nil
+	case kind == "webhook" && (strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")):
+		return &Action{Kind: kind, Arg: arg}, nil
+	}
+	return nil, fmt.Errorf("action must be \"exec COMMAND\" or \"webhook http(s)://URL\"")
+}
+
+// AlertEvent describes a fired rule to actions: the JSON body of webhooks,
+// and GTRACE_ALERT_* environment variables of commands.
+type AlertEvent struct {
+	Target    string     `json:"target"`
+	Rule      string     `json:"rule"`
+	Type      ChangeType `json:"type"`
+	Hop       int        `json:"hop"`
+	Value     float64    `json:"value"` // Percent for loss, milliseconds otherwise
+	Message   string     `json:"message"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// newAlertEvent describes the alert c of a rule on target.
+func newAlertEvent(target string, c Change) AlertEvent {
+	ev := AlertEvent{
+		Target:    target,
+		Type:      c.Type,
+		Hop:       c.Hop,
+		Message:   c.Message,
+		Timestamp: c.Timestamp,
+	}
+	if c.Rule != nil {
+		ev.Rule = c.Rule.String()
+	}
+	switch v := c.NewValue.(type) {
+	case float64:
+		ev.Value = v
+	case time.Duration:
+		ev.Value = hop.Milliseconds(v)
+	}
+	return ev
+}
+
+// Run executes the action for the alert c on target.
+func (a *Action) Run(ctx context.Context, target string, c Change) error {
+	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
+	defer cancel()
+	ev := newAlertEvent(target, c)
+
+	switch a.Kind {
+	case "exec":
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		cmd := exec.CommandContext(ctx, shell, flag, a.Arg)
+		cmd.Env = append(os.Environ(),
+			"GTRACE_ALERT_TARGET="+ev.Target,
+			"GTRACE_ALERT_RULE="+ev.Rule,
+			"GTRACE_ALERT_TYPE="+string(ev.Type),
+			"GTRACE_ALERT_HOP="+strconv.Itoa(ev.Hop),
+			"GTRACE_ALERT_VALUE="+strconv.FormatFloat(ev.Value, 'f', -1, 64),
+			"GTRACE_ALERT_MESSAGE="+ev.Message,
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("alert command failed: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+
+	case "webhook":
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Arg, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("alert webhook failed: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("alert webhook failed: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("alert webhook failed: %s", resp.Status)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown alert action %q", a.Kind)
+}
@@ -0,0 +1,262 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func mustParseRule(t *testing.T, s string) *Rule {
+	t.Helper()
+	r, err := ParseRule(s)
+	if err != nil {
+		t.Fatalf("ParseRule(%q): %v", s, err)
+	}
+	return r
+}
+
+// createRuleTrace builds a path through 10.0.0.1 to the target, whose probes
+// answer with rtts (0 for a timeout).
+func createRuleTrace(rtts ...time.Duration) *hop.TraceResult {
+	tr := hop.NewTraceResult("target", "93.184.216.34")
+	first := hop.NewHop(1)
+	first.AddProbe(net.ParseIP("10.0.0.1"), time.Millisecond)
+	tr.AddHop(first)
+
+	last := hop.NewHop(2)
+	for _, rtt := range rtts {
+		if rtt == 0 {
+			last.AddTimeout()
+		} else {
+			last.AddProbe(net.ParseIP("93.184.216.34"), rtt)
+		}
+	}
+	tr.AddHop(last)
+	return tr
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule      string
+		selector  string
+		metric    string
+		op        string
+		threshold float64
+		cycles    int
+	}{
+		{"hop(last).loss > 5% for 3 cycles", "last", "loss", ">", 5, 3},
+		{"any(hop).rtt_p95 > 150ms", "any", "rtt_p95", ">", 150, 1},
+		{"hop(3).rtt>=1s for 1 cycle", "3", "rtt", ">=", 1000, 1},
+		{"hop(2001:db8::1).jitter < 20ms", "2001:db8::1", "jitter", "<", 20, 1},
+		{"HOP(LAST).LOSS > 5", "last", "loss", ">", 5, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r := mustParseRule(t, tt.rule)
+			if r.Selector != tt.selector || r.Metric != tt.metric || r.Op != tt.op || r.Threshold != tt.threshold || r.Cycles != tt.cycles {
+				t.Errorf("unexpected rule %+v", r)
+			}
+		})
+	}
+}
+
+func TestParseRule_Invalid(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{"loss > 5%", "expected SELECTOR.METRIC"},
+		{"hop(first).loss > 5%", "hop selector"},
+		{"hop(0).loss > 5%", "hop selector"},
+		{"hop(last).mos > 4", "unknown metric"},
+		{"hop(last).rtt > 150", "duration"},
+		{"hop(last).loss > lots", "percentage"},
+		{"hop(last).loss > 5% for 0 cycles", "cycles must be >= 1"},
+		{"hop(last).loss > 5% then page oncall", "action must be"},
+		{"hop(last).loss > 5% then webhook example.com", "action must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := ParseRule(tt.rule)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseRule_Action(t *testing.T) {
+	r := mustParseRule(t, "hop(last).loss > 5% then exec notify-send 'Loss to $GTRACE_ALERT_TARGET'")
+	if r.Action == nil || r.Action.Kind != "exec" || r.Action.Arg != "notify-send 'Loss to $GTRACE_ALERT_TARGET'" {
+		t.Errorf("unexpected action %+v", r.Action)
+	}
+	if r.String() != "hop(last).loss > 5%" {
+		t.Errorf("expected the condition without its action, got %q", r.String())
+	}
+}
+
+func TestRuleSet_FiresAfterConsecutiveCycles(t *testing.T) {
+	s := NewRuleSet([]*Rule{mustParseRule(t, "hop(last).loss > 5% for 3 cycles")})
+	lossy := createRuleTrace(10*time.Millisecond, 0, 0)
+	clean := createRuleTrace(10*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond)
+	now := time.Now()
+
+	var fired []int
+	for i, tr := range []*hop.TraceResult{lossy, lossy, clean, lossy, lossy, lossy, lossy} {
+		if changes := s.Evaluate(tr, now); len(changes) > 0 {
+			fired = append(fired, i)
+		}
+	}
+	// The clean cycle restarts the count; the rule then fires once per episode
+	if len(fired) != 1 || fired[0] != 5 {
+		t.Errorf("expected the rule to fire once at cycle 5, got %v", fired)
+	}
+}
+
+func TestRuleSet_AlertDescribesMatch(t *testing.T) {
+	s := NewRuleSet([]*Rule{mustParseRule(t, "hop(last).loss > 5%")})
+
+	changes := s.Evaluate(createRuleTrace(10*time.Millisecond, 0, 0), time.Now())
+	if len(changes) != 1 {
+		t.Fatalf("expected one alert, got %v", changes)
+	}
+	c := changes[0]
+	if c.Type != ChangeTypeLoss || c.Hop != 2 || c.Rule == nil {
+		t.Errorf("unexpected alert %+v", c)
+	}
+	if c.Message != "hop(last).loss > 5%: loss 66.7% at 93.184.216.34" {
+		t.Errorf("unexpected message %q", c.Message)
+	}
+}
+
+func TestRuleSet_AnyHopAndPercentile(t *testing.T) {
+	s := NewRuleSet([]*Rule{mustParseRule(t, "any(hop).rtt_p95 > 100ms")})
+
+	// 12 samples over 4 cycles: the slowest decides the 95th percentile
+	for i, rtt := range []time.Duration{20, 20, 20, 150} {
+		tr := createRuleTrace(rtt*time.Millisecond, 20*time.Millisecond, 20*time.Millisecond)
+		changes := s.Evaluate(tr, time.Now())
+		if i < 3 && len(changes) > 0 {
+			t.Fatalf("cycle %d: unexpected alert %v", i, changes)
+		}
+		if i == 3 && (len(changes) != 1 || changes[0].Hop != 2 || changes[0].NewValue != 150*time.Millisecond) {
+			t.Errorf("expected p95 of 150ms at hop 2, got %v", changes)
+		}
+	}
+}
+
+func TestRuleSet_RTTNeedsReplies(t *testing.T) {
+	s := NewRuleSet([]*Rule{mustParseRule(t, "hop(last).rtt < 50ms")})
+	if changes := s.Evaluate(createRuleTrace(0, 0, 0), time.Now()); len(changes) != 0 {
+		t.Errorf("expected no alert without replies, got %v", changes)
+	}
+}
+
+func TestMonitor_Run_EvaluatesRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 2
+	cfg.Rules = []*Rule{mustParseRule(t, "hop(last).loss > 50% for 2 cycles")}
+	m := NewMonitor(cfg)
+
+	var alerts []Change
+	m.SetCallback(func(changes []Change) {
+		alerts = append(alerts, changes...)
+	})
+	m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		return createTraceWithLoss("8.8.8.8", 2), nil
+	})
+
+	if len(alerts) != 1 || alerts[0].Rule == nil {
+		t.Errorf("expected one rule alert, got %v", alerts)
+	}
+}
+
+func TestMonitor_Run_ConfirmAnomaliesChecksRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 3
+	cfg.Rules = []*Rule{mustParseRule(t, "hop(last).rtt > 50ms")}
+	cfg.ConfirmAnomalies = true
+	m := NewMonitor(cfg)
+
+	var alerts, dropped []Change
+	m.SetCallback(func(changes []Change) { alerts = append(alerts, changes...) })
+	m.SetConfirmCallback(func(changes []Change) { dropped = append(dropped, changes...) })
+
+	// The first spike is not reproduced, the second is
+	rtts := []time.Duration{200, 6, 5, 200, 190}
+	n := 0
+	m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		n++
+		return createTraceWithRTT("8.8.8.8", rtts[n-1]*time.Millisecond), nil
+	})
+
+	if len(dropped) != 1 || dropped[0].Rule == nil {
+		t.Errorf("expected the one-off rule match dropped, got %v", dropped)
+	}
+	if len(alerts) != 1 || alerts[0].NewValue != 190*time.Millisecond {
+		t.Errorf("expected the reproduced rule alert with the re-traced RTT, got %v", alerts)
+	}
+}
+
+func TestAction_Webhook(t *testing.T) {
+	var got AlertEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	r := mustParseRule(t, "hop(last).loss > 5% then webhook "+srv.URL)
+	c := NewRuleSet([]*Rule{r}).Evaluate(createRuleTrace(10*time.Millisecond, 0, 0), time.Now())[0]
+	if err := r.Action.Run(context.Background(), "example.com", c); err != nil {
+		t.Fatal(err)
+	}
+	if got.Target != "example.com" || got.Rule != "hop(last).loss > 5%" || got.Type != ChangeTypeLoss || got.Hop != 2 {
+		t.Errorf("unexpected webhook payload %+v", got)
+	}
+}
+
+func TestAction_WebhookRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	a := &Action{Kind: "webhook", Arg: srv.URL}
+	if err := a.Run(context.Background(), "example.com", Change{}); err == nil {
+		t.Error("expected error for a failed webhook")
+	}
+}
+
+func TestAction_Exec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	out := filepath.Join(t.TempDir(), "alert")
+	a := &Action{Kind: "exec", Arg: `echo "$GTRACE_ALERT_TARGET $GTRACE_ALERT_HOP $GTRACE_ALERT_VALUE" > ` + out}
+
+	c := Change{Type: ChangeTypeLatency, Hop: 4, NewValue: 150 * time.Millisecond}
+	if err := a.Run(context.Background(), "example.com", c); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != "example.com 4 150" {
+		t.Errorf("unexpected command environment %q", data)
+	}
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Errors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * *", "expected 5 fields"},
+		{"60 * * * *", "invalid minute"},
+		{"* 24 * * *", "invalid hour"},
+		{"* * 0 * *", "invalid day of month"},
+		{"* * * foo *", "invalid month"},
+		{"*/0 * * * *", "invalid step"},
+		{"30-10 * * * *", "invalid range"},
+		{"0 0 30 feb *", "never matches"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseSchedule(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSchedule(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// Wednesday 15 January 2025, 10:07:30
+	from := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2025, 1, 15, 10, 10, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2025, 1, 15, 10, 8, 0, 0, time.UTC)},
+		{"7 10 * * *", time.Date(2025, 1, 16, 10, 7, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 8 * * sat,sun", time.Date(2025, 1, 18, 8, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 20th or any Friday
+		{"0 0 20 * fri", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := ParseSchedule(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) error = %v", tt.expr, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_NextIsStrictlyAfter(t *testing.T) {
+	s, err := ParseSchedule("*/5 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 1, 15, 10, 5, 0, 0, time.UTC)
+	if got, want := s.Next(at), at.Add(5*time.Minute); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", at, got, want)
+	}
+	if got := s.String(); got != "*/5 * * * *" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Sink delivers rule alerts outside gtrace, in addition to the ALERT lines
+// printed by the monitor.
+type Sink interface {
+	Send(ctx context.Context, ev AlertEvent) error
+}
+
+// SinkConfig configures a sink in the user configuration file.
+type SinkConfig struct {
+	Type string `json:"type"` // syslog, email or mqtt
+
+	// syslog: the local daemon when Network is empty, otherwise RFC 5424
+	// messages over udp or tcp to Address
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Facility string `json:"facility,omitempty"` // Default: daemon
+	Tag      string `json:"tag,omitempty"`      // Default: gtrace
+
+	// email: sent through the SMTP server at Server (host:port), which is
+	// upgraded to TLS when it supports STARTTLS
+	Server   string   `json:"server,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// mqtt: published as JSON to Topic (default gtrace/HOSTNAME/alerts) on
+	// Broker (mqtt://host:port, or mqtts:// for TLS verified against CAFile
+	// or the system roots), with Username and Password when set
+	Broker   string `json:"broker,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	QoS      int    `json:"qos,omitempty"` // 0 or 1
+	ClientID string `json:"clientId,omitempty"`
+	CAFile   string `json:"caFile,omitempty"`
+}
+
+// NewSink creates the sink described by cfg.
+func NewSink(cfg SinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case "syslog":
+		return newSyslogSink(cfg)
+	case "email":
+		return newEmailSink(cfg)
+	case "mqtt":
+		return newMQTTSink(cfg)
+	}
+	return nil, fmt.Errorf("unknown sink type %q: must be syslog, email or mqtt", cfg.Type)
+}
+
+// Notify runs the action of the rule that raised c and sends c to its sinks.
+func (r *Rule) Notify(ctx context.Context, target string, c Change) error {
+	var errs []error
+	if r.Action != nil {
+		errs = append(errs, r.Action.Run(ctx, target, c))
+	}
+	ev := newAlertEvent(target, c)
+	for _, s := range r.Sinks {
+		sendCtx, cancel := context.WithTimeout(ctx, actionTimeout)
+		errs = append(errs, s.Send(sendCtx, ev))
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// syslogFacilities maps facility names to their RFC 5424 codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogWarning is the severity of alerts (RFC 5424: 4 = warning).
+const syslogWarning = 4
+
+// syslogSink sends alerts to a syslog server or the local daemon.
+type syslogSink struct {
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+}
+
+func newSyslogSink(cfg SinkConfig) (Sink, error) {
+	s := &syslogSink{network: cfg.Network, address: cfg.Address, tag: cfg.Tag}
+	if s.tag == "" {
+		s.tag = "gtrace"
+	}
+	name := cfg.Facility
+	if name == "" {
+		name = "daemon"
+	}
+	facility, ok := syslogFacilities[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	s.facility = facility
+
+	switch s.network {
+	case "":
+		return newLocalSyslogSink(s)
+	case "udp", "tcp":
+		if s.address == "" {
+			return nil, fmt.Errorf("syslog over %s requires an address", s.network)
+		}
+	default:
+		return nil, fmt.Errorf("invalid syslog network %q: must be udp, tcp, or empty for the local daemon", s.network)
+	}
+	s.hostname, _ = os.Hostname()
+	return s, nil
+}
+
+// format returns the RFC 5424 message of ev.
+func (s *syslogSink) format(ev AlertEvent) string {
+	hostname := s.hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		s.facility*8+syslogWarning, ev.Timestamp.UTC().Format(time.RFC3339Nano),
+		hostname, s.tag, os.Getpid(), alertLine(ev))
+}
+
+func (s *syslogSink) Send(ctx context.Context, ev AlertEvent) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := s.format(ev)
+	if s.network == "tcp" {
+		// Octet-counting framing (RFC 6587)
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	return nil
+}
+
+// alertLine formats an alert as a single line.
+func alertLine(ev AlertEvent) string {
+	return fmt.Sprintf("ALERT target=%s type=%s hop=%d %s", ev.Target, ev.Type, ev.Hop, ev.Message)
+}
+
+// emailSink sends alerts by email.
+type emailSink struct {
+	cfg SinkConfig
+}
+
+func newEmailSink(cfg SinkConfig) (Sink, error) {
+	if cfg.Server == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email sink requires server, from and to")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		return nil, fmt.Errorf("invalid email server %q: must be host:port", cfg.Server)
+	}
+	return &emailSink{cfg: cfg}, nil
+}
+
+// message returns the email of ev, headers included.
+func (s *emailSink) message(ev AlertEvent) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: gtrace alert: %s: %s\r\n", ev.Target, ev.Rule)
+	fmt.Fprintf(&b, "Date: %s\r\n", ev.Timestamp.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Target: %s\r\n", ev.Target)
+	fmt.Fprintf(&b, "Rule:   %s\r\n", ev.Rule)
+	fmt.Fprintf(&b, "Hop:    %d\r\n", ev.Hop)
+	fmt.Fprintf(&b, "Time:   %s\r\n\r\n", ev.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&b, "%s\r\n", ev.Message)
+	return []byte(b.String())
+}
+
+func (s *emailSink) Send(ctx context.Context, ev AlertEvent) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(s.cfg.Server)
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+
+	// net/smtp has no context support: give up waiting at the deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.cfg.Server, auth, s.cfg.From, s.cfg.To, s.message(ev))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("email: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,160 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAlertEvent() AlertEvent {
+	return AlertEvent{
+		Target:    "example.com",
+		Rule:      "hop(last).loss > 5%",
+		Type:      ChangeTypeLoss,
+		Hop:       12,
+		Value:     33.3,
+		Message:   "hop(last).loss > 5%: loss 33.3% at 93.184.216.34",
+		Timestamp: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestNewSink_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SinkConfig
+		wantErr string
+	}{
+		{"local syslog", SinkConfig{Type: "syslog"}, ""},
+		{"remote syslog", SinkConfig{Type: "syslog", Network: "tcp", Address: "logs.example.com:6514", Facility: "local3"}, ""},
+		{"email", SinkConfig{Type: "email", Server: "smtp.example.com:25", From: "a@example.com", To: []string{"b@example.com"}}, ""},
+		{"unknown type", SinkConfig{Type: "pager"}, "unknown sink type"},
+		{"unknown facility", SinkConfig{Type: "syslog", Facility: "local9"}, "unknown syslog facility"},
+		{"bad network", SinkConfig{Type: "syslog", Network: "sctp", Address: "x:514"}, "invalid syslog network"},
+		{"no address", SinkConfig{Type: "syslog", Network: "udp"}, "requires an address"},
+		{"email without recipients", SinkConfig{Type: "email", Server: "smtp.example.com:25", From: "a@example.com"}, "requires server, from and to"},
+		{"email server without port", SinkConfig{Type: "email", Server: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}, "host:port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSink(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	sink, err := NewSink(SinkConfig{Type: "syslog", Network: "udp", Address: pc.LocalAddr().String(), Facility: "local0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), testAlertEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	// local0 (16) * 8 + warning (4)
+	if !strings.HasPrefix(msg, "<132>1 2024-03-01T09:30:00Z ") {
+		t.Errorf("expected an RFC 5424 header, got %q", msg)
+	}
+	if !strings.Contains(msg, " gtrace ") || !strings.HasSuffix(msg, "ALERT target=example.com type=loss hop=12 hop(last).loss > 5%: loss 33.3% at 93.184.216.34") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestSyslogSink_TCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('>')
+		got <- line
+	}()
+
+	sink, _ := NewSink(SinkConfig{Type: "syslog", Network: "tcp", Address: ln.Addr().String()})
+	if err := sink.Send(context.Background(), testAlertEvent()); err != nil {
+		t.Fatal(err)
+	}
+	// Octet count, then the message: "<PRI>..." with daemon (3) * 8 + warning (4)
+	if line := <-got; !strings.HasSuffix(line, " <28>") {
+		t.Errorf("expected an octet-counted frame, got %q", line)
+	}
+}
+
+func TestEmailSink_Message(t *testing.T) {
+	sink, err := NewSink(SinkConfig{Type: "email", Server: "smtp.example.com:587", From: "gtrace@example.com", To: []string{"a@example.com", "b@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(sink.(*emailSink).message(testAlertEvent()))
+
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: gtrace alert: example.com: hop(last).loss > 5%\r\n",
+		"\r\n\r\nTarget: example.com\r\n",
+		"loss 33.3% at 93.184.216.34",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in message:\n%s", want, msg)
+		}
+	}
+}
+
+// recordingSink records the alerts it receives, failing when err is set.
+type recordingSink struct {
+	events []AlertEvent
+	err    error
+}
+
+func (s *recordingSink) Send(_ context.Context, ev AlertEvent) error {
+	s.events = append(s.events, ev)
+	return s.err
+}
+
+func TestRule_NotifySendsToEverySink(t *testing.T) {
+	ok := &recordingSink{}
+	failing := &recordingSink{err: errors.New("server down")}
+	r := mustParseRule(t, "hop(last).loss > 5%")
+	r.Sinks = []Sink{failing, ok}
+
+	c := Change{Type: ChangeTypeLoss, Hop: 2, NewValue: 66.7, Rule: r}
+	err := r.Notify(context.Background(), "example.com", c)
+	if err == nil || !strings.Contains(err.Error(), "server down") {
+		t.Errorf("expected the sink error, got %v", err)
+	}
+	if len(ok.events) != 1 || ok.events[0].Target != "example.com" || ok.events[0].Value != 66.7 {
+		t.Errorf("expected the alert delivered despite the failing sink, got %+v", ok.events)
+	}
+}
@@ -0,0 +1,174 @@
+package monitor
+
+import (
+	"slices"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// FlapWindow is the number of recent cycles a hop's flap rate is measured over.
+const FlapWindow = 10
+
+// DefaultFlapThreshold is the default share of FlapWindow cycles, in percent,
+// in which a hop's addresses must change for it to be reported flapping.
+const DefaultFlapThreshold = 30.0
+
+// minFlapCycles is the number of compared cycles a hop needs before it can
+// be reported flapping.
+const minFlapCycles = 5
+
+// addressWindow is the number of previous answering cycles whose addresses
+// a hop's new addresses are checked against. Per-packet load balancing
+// answers from a few addresses in turn; only an address none of them used
+// counts as a change.
+const addressWindow = 3
+
+// Stability tracks how often the set of addresses answering at each TTL
+// changes from cycle to cycle, per hop and for the whole path.
+type Stability struct {
+	hops          map[int]*hopStability
+	observed      int // Cycles observed
+	changedCycles int // Cycles in which at least one hop changed
+}
+
+// hopStability is the address history of one TTL.
+type hopStability struct {
+	recent   [][]string // Addresses of the last addressWindow answering cycles
+	changes  []bool     // Whether the addresses changed, for the last FlapWindow compared cycles
+	compared int        // Cycles compared with the ones before
+	changed  int        // Compared cycles in which the addresses changed
+	flapping bool       // Reported flapping and not yet back below the threshold
+}
+
+// NewStability creates an empty stability tracker.
+func NewStability() *Stability {
+	return &Stability{hops: make(map[int]*hopStability)}
+}
+
+// Reset forgets every observed cycle, e.g. after a network change.
+func (s *Stability) Reset() {
+	*s = *NewStability()
+}
+
+// Observe records the addresses that answered at each TTL in one cycle and
+// returns the TTLs whose addresses changed, in order. TTLs with no answer
+// this cycle keep their history.
+func (s *Stability) Observe(addrs map[int][]string) []int {
+	var changed []int
+	for ttl, ips := range addrs {
+		if len(ips) == 0 {
+			continue
+		}
+		h := s.hops[ttl]
+		if h == nil {
+			h = &hopStability{}
+			s.hops[ttl] = h
+		}
+		if len(h.recent) > 0 {
+			isNew := slices.ContainsFunc(ips, func(ip string) bool {
+				return !slices.ContainsFunc(h.recent, func(prev []string) bool { return slices.Contains(prev, ip) })
+			})
+			h.compared++
+			if isNew {
+				h.changed++
+				changed = append(changed, ttl)
+			}
+			h.changes = pushWindow(h.changes, isNew, FlapWindow)
+		}
+		h.recent = pushWindow(h.recent, ips, addressWindow)
+	}
+
+	s.observed++
+	if len(changed) > 0 {
+		s.changedCycles++
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// ObserveTrace records the addresses of every hop of one trace.
+func (s *Stability) ObserveTrace(tr *hop.TraceResult) []int {
+	addrs := make(map[int][]string, len(tr.Hops))
+	for _, h := range tr.Hops {
+		for _, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				continue
+			}
+			if ip := p.IP.String(); !slices.Contains(addrs[h.TTL], ip) {
+				addrs[h.TTL] = append(addrs[h.TTL], ip)
+			}
+		}
+	}
+	return s.Observe(addrs)
+}
+
+// Score returns the path stability: the percentage of cycles after the first
+// in which no hop changed address. A path seen once is fully stable.
+func (s *Stability) Score() float64 {
+	if s.observed < 2 {
+		return 100
+	}
+	return 100 * (1 - float64(s.changedCycles)/float64(s.observed-1))
+}
+
+// HopScore returns the stability of one TTL: the percentage of its compared
+// cycles in which its addresses did not change.
+func (s *Stability) HopScore(ttl int) float64 {
+	h := s.hops[ttl]
+	if h == nil || h.compared == 0 {
+		return 100
+	}
+	return 100 * (1 - float64(h.changed)/float64(h.compared))
+}
+
+// HopChanges returns how many times the addresses at ttl changed and in how
+// many compared cycles.
+func (s *Stability) HopChanges(ttl int) (changed, compared int) {
+	if h := s.hops[ttl]; h != nil {
+		return h.changed, h.compared
+	}
+	return 0, 0
+}
+
+// FlapRate returns the percentage of the last FlapWindow compared cycles in
+// which the addresses at ttl changed, or 0 before minFlapCycles.
+func (s *Stability) FlapRate(ttl int) float64 {
+	h := s.hops[ttl]
+	if h == nil || len(h.changes) < minFlapCycles {
+		return 0
+	}
+	n := 0
+	for _, c := range h.changes {
+		if c {
+			n++
+		}
+	}
+	return 100 * float64(n) / float64(len(h.changes))
+}
+
+// NewFlaps returns the TTLs whose flap rate reached threshold (in percent)
+// since the last call, in order. A hop is reported again only after its
+// rate fell back below the threshold.
+func (s *Stability) NewFlaps(threshold float64) []int {
+	var flaps []int
+	for ttl, h := range s.hops {
+		rate := s.FlapRate(ttl)
+		switch {
+		case rate >= threshold && !h.flapping:
+			h.flapping = true
+			flaps = append(flaps, ttl)
+		case rate < threshold:
+			h.flapping = false
+		}
+	}
+	slices.Sort(flaps)
+	return flaps
+}
+
+// pushWindow appends v to buf, dropping the oldest entry once size is reached.
+func pushWindow[T any](buf []T, v T, size int) []T {
+	if len(buf) >= size {
+		buf = append(buf[:0], buf[len(buf)-size+1:]...)
+	}
+	return append(buf, v)
+}
@@ -0,0 +1,131 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestStability_ECMPIsStable(t *testing.T) {
+	s := NewStability()
+	// Per-packet load balancing: two addresses in turn at TTL 2
+	for i := range 10 {
+		addr := "10.0.0.1"
+		if i%2 == 1 {
+			addr = "10.0.0.2"
+		}
+		s.Observe(map[int][]string{1: {"192.168.1.1"}, 2: {addr}})
+	}
+
+	// Only the first appearance of the second address is a change
+	if changed, compared := s.HopChanges(2); changed != 1 || compared != 9 {
+		t.Errorf("HopChanges(2) = %d of %d, want 1 of 9", changed, compared)
+	}
+	if got := s.HopScore(1); got != 100 {
+		t.Errorf("HopScore(1) = %.0f, want 100", got)
+	}
+	if flaps := s.NewFlaps(DefaultFlapThreshold); len(flaps) != 0 {
+		t.Errorf("NewFlaps() = %v, want none", flaps)
+	}
+}
+
+func TestStability_Flapping(t *testing.T) {
+	s := NewStability()
+	addrs := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	for i := range 6 {
+		changed := s.Observe(map[int][]string{1: {"192.168.1.1"}, 2: {addrs[i%len(addrs)]}})
+		if i > 0 && (len(changed) != 1 || changed[0] != 2) {
+			t.Fatalf("cycle %d: changed = %v, want [2]", i, changed)
+		}
+	}
+
+	if got := s.Score(); got != 0 {
+		t.Errorf("Score() = %.0f, want 0 (every cycle changed)", got)
+	}
+	if got := s.FlapRate(2); got != 100 {
+		t.Errorf("FlapRate(2) = %.0f, want 100", got)
+	}
+	if flaps := s.NewFlaps(DefaultFlapThreshold); len(flaps) != 1 || flaps[0] != 2 {
+		t.Errorf("NewFlaps() = %v, want [2]", flaps)
+	}
+	// Reported once per episode
+	if flaps := s.NewFlaps(DefaultFlapThreshold); len(flaps) != 0 {
+		t.Errorf("second NewFlaps() = %v, want none", flaps)
+	}
+
+	s.Reset()
+	if got := s.Score(); got != 100 {
+		t.Errorf("Score() after Reset = %.0f, want 100", got)
+	}
+}
+
+func TestStability_SilentCyclesKeepHistory(t *testing.T) {
+	s := NewStability()
+	s.Observe(map[int][]string{1: {"10.0.0.1"}})
+	s.Observe(map[int][]string{})
+	if changed := s.Observe(map[int][]string{1: {"10.0.0.1"}}); len(changed) != 0 {
+		t.Errorf("changed = %v after a silent cycle, want none", changed)
+	}
+}
+
+func TestMonitor_Run_ReportsFlapOnce(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 8
+	m := NewMonitor(cfg)
+
+	var flaps []Change
+	m.SetCallback(func(changes []Change) {
+		for _, c := range changes {
+			if c.Type == ChangeTypeFlap {
+				flaps = append(flaps, c)
+			}
+		}
+	})
+
+	addrs := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	n := 0
+	err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		tr := createTrace([]string{"192.168.1.1", addrs[n%len(addrs)], "8.8.8.8"})
+		n++
+		return tr, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(flaps) != 1 || flaps[0].Hop != 2 {
+		t.Fatalf("flap changes = %v, want one at hop 2", flaps)
+	}
+	if got := m.Stability().Score(); got != 0 {
+		t.Errorf("path stability = %.0f, want 0", got)
+	}
+}
+
+func TestMonitor_Run_FlapAlertsDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Millisecond
+	cfg.Cycles = 8
+	cfg.FlapThreshold = 0
+	m := NewMonitor(cfg)
+
+	m.SetCallback(func(changes []Change) {
+		for _, c := range changes {
+			if c.Type == ChangeTypeFlap {
+				t.Errorf("unexpected flap change: %s", c)
+			}
+		}
+	})
+
+	addrs := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	n := 0
+	if err := m.Run(context.Background(), func(context.Context) (*hop.TraceResult, error) {
+		tr := createTrace([]string{"192.168.1.1", addrs[n%len(addrs)], "8.8.8.8"})
+		n++
+		return tr, nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Summary is a stable, machine-readable digest of one monitoring cycle.
+// Field names are part of the output format and must not be renamed.
+type Summary struct {
+	Time          time.Time `json:"time"`
+	Target        string    `json:"target"`
+	TargetIP      string    `json:"ip"`
+	Hops          int       `json:"hops"`
+	Reached       bool      `json:"reached"`
+	AvgRTT        float64   `json:"e2e_avg_ms"`        // in ms, 0 if target not reached
+	P95RTT        float64   `json:"e2e_p95_ms"`        // in ms, 0 if target not reached
+	LossPercent   float64   `json:"loss_pct"`          // end-to-end loss
+	PathSignature string    `json:"path"`              // short hash of the hop IP sequence
+	Stability     float64   `json:"stability_pct"`     // cycles without an address change, see Stability.Score
+	Invalid       bool      `json:"invalid,omitempty"` // cycle overlapped a sleep or network change
+}
+
+// Summarize computes the cycle summary for a trace result.
+// End-to-end figures are taken from the final hop when the target was reached;
+// otherwise loss is reported as 100%.
+func Summarize(tr *hop.TraceResult, ts time.Time) Summary {
+	s := Summary{
+		Time:          ts,
+		Target:        tr.Target,
+		TargetIP:      tr.TargetIP,
+		Hops:          tr.TotalHops(),
+		Reached:       tr.ReachedTarget,
+		LossPercent:   100,
+		PathSignature: PathSignature(tr),
+		Stability:     100,
+	}
+
+	if !tr.ReachedTarget || len(tr.Hops) == 0 {
+		return s
+	}
+
+	last := tr.Hops[len(tr.Hops)-1]
+	var rtts []time.Duration
+	for _, p := range last.Probes {
+		if !p.Timeout {
+			rtts = append(rtts, p.RTT)
+		}
+	}
+
+	s.LossPercent = last.LossPercent()
+	s.AvgRTT = hop.Milliseconds(last.AvgRTT())
+	s.P95RTT = hop.Milliseconds(percentile(rtts, 95))
+	return s
+}
+
+// String formats the summary as space-separated key=value pairs.
+// invalid=true is appended only for invalid cycles.
+func (s Summary) String() string {
+	line := fmt.Sprintf("time=%s target=%s ip=%s hops=%d reached=%t e2e_avg_ms=%.2f e2e_p95_ms=%.2f loss_pct=%.1f path=%s stability_pct=%.0f",
+		s.Time.Format(time.RFC3339), s.Target, s.TargetIP, s.Hops, s.Reached,
+		s.AvgRTT, s.P95RTT, s.LossPercent, s.PathSignature, s.Stability)
+	if s.Invalid {
+		line += " invalid=true"
+	}
+	return line
+}
+
+// JSON formats the summary as a single-line JSON object.
+func (s Summary) JSON() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// PathSignature returns a short, stable hash of the responding IP at each hop.
+// Non-responding hops contribute "*" so a new silent hop changes the signature.
+func PathSignature(tr *hop.TraceResult) string {
+	parts := make([]string, 0, len(tr.Hops))
+	for _, h := range tr.Hops {
+		if ip := h.PrimaryIP(); ip != nil {
+			parts = append(parts, ip.String())
+		} else {
+			parts = append(parts, "*")
+		}
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(parts, ",")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of the samples.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestSummarize_ReachedTarget(t *testing.T) {
+	tr := hop.NewTraceResult("example.com", "10.0.0.3")
+	h1 := hop.NewHop(1)
+	h1.AddProbe(net.ParseIP("10.0.0.1"), 1*time.Millisecond)
+	tr.AddHop(h1)
+	h2 := hop.NewHop(2)
+	h2.AddProbe(net.ParseIP("10.0.0.3"), 10*time.Millisecond)
+	h2.AddProbe(net.ParseIP("10.0.0.3"), 20*time.Millisecond)
+	h2.AddProbe(net.ParseIP("10.0.0.3"), 30*time.Millisecond)
+	h2.AddTimeout()
+	tr.AddHop(h2)
+	tr.ReachedTarget = true
+
+	s := Summarize(tr, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	if s.Hops != 2 || !s.Reached {
+		t.Errorf("unexpected hops/reached: %d/%v", s.Hops, s.Reached)
+	}
+	if s.AvgRTT != 20 {
+		t.Errorf("expected avg 20ms, got %.2f", s.AvgRTT)
+	}
+	if s.P95RTT != 30 {
+		t.Errorf("expected p95 30ms, got %.2f", s.P95RTT)
+	}
+	if s.LossPercent != 25 {
+		t.Errorf("expected 25%% loss, got %.1f", s.LossPercent)
+	}
+}
+
+func TestSummarize_NotReachedReportsFullLoss(t *testing.T) {
+	tr := createTrace([]string{"10.0.0.1", "10.0.0.2"})
+
+	s := Summarize(tr, time.Now())
+
+	if s.LossPercent != 100 {
+		t.Errorf("expected 100%% loss when target not reached, got %.1f", s.LossPercent)
+	}
+	if s.AvgRTT != 0 || s.P95RTT != 0 {
+		t.Errorf("expected zero e2e RTT, got avg=%.2f p95=%.2f", s.AvgRTT, s.P95RTT)
+	}
+}
+
+func TestSummary_StringIsKeyValue(t *testing.T) {
+	tr := createTrace([]string{"10.0.0.1", "10.0.0.2"})
+	tr.ReachedTarget = true
+
+	line := Summarize(tr, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)).String()
+
+	for _, want := range []string{"time=2024-01-02T03:04:05Z", "hops=2", "reached=true", "e2e_avg_ms=5.00", "e2e_p95_ms=5.00", "loss_pct=0.0", "path=", "stability_pct=100"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+}
+
+func TestSummary_StringMarksInvalidCycles(t *testing.T) {
+	s := Summarize(createTrace([]string{"10.0.0.1"}), time.Now())
+	if strings.Contains(s.String(), "invalid") {
+		t.Errorf("valid cycle should not mention invalid: %q", s.String())
+	}
+
+	s.Invalid = true
+	if !strings.HasSuffix(s.String(), " invalid=true") {
+		t.Errorf("expected invalid=true suffix in %q", s.String())
+	}
+	if !strings.Contains(s.JSON(), `"invalid":true`) {
+		t.Errorf("expected invalid in JSON %s", s.JSON())
+	}
+}
+
+func TestSummary_JSONRoundTrips(t *testing.T) {
+	tr := createTrace([]string{"10.0.0.1"})
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(Summarize(tr, time.Now()).JSON()), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"time", "target", "hops", "reached", "e2e_avg_ms", "e2e_p95_ms", "loss_pct", "path", "stability_pct"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("missing key %q", key)
+		}
+	}
+}
+
+func TestPathSignature_ChangesWithRoute(t *testing.T) {
+	a := PathSignature(createTrace([]string{"10.0.0.1", "10.0.0.2"}))
+	b := PathSignature(createTrace([]string{"10.0.0.1", "10.0.0.2"}))
+	c := PathSignature(createTrace([]string{"10.0.0.1", "10.0.0.9"}))
+
+	if a != b {
+		t.Error("identical paths should have identical signatures")
+	}
+	if a == c {
+		t.Error("different paths should have different signatures")
+	}
+	if len(a) != 8 {
+		t.Errorf("expected 8-char signature, got %q", a)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package monitor
+
+import "fmt"
+
+func newLocalSyslogSink(*syslogSink) (Sink, error) {
+	return nil, fmt.Errorf("no local syslog daemon on this system: set network and address")
+}
@@ -0,0 +1,38 @@
+//go:build !windows && !plan9
+
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/syslog"
+)
+
+// localSyslogSink sends alerts to the local syslog daemon. It connects on
+// the first alert, so a missing daemon doesn't prevent monitoring.
+type localSyslogSink struct {
+	priority syslog.Priority
+	tag      string
+	w        *syslog.Writer
+}
+
+func newLocalSyslogSink(s *syslogSink) (Sink, error) {
+	return &localSyslogSink{
+		priority: syslog.Priority(s.facility<<3) | syslog.LOG_WARNING,
+		tag:      s.tag,
+	}, nil
+}
+
+func (s *localSyslogSink) Send(ctx context.Context, ev AlertEvent) error {
+	if s.w == nil {
+		w, err := syslog.New(s.priority, s.tag)
+		if err != nil {
+			return fmt.Errorf("syslog: %w", err)
+		}
+		s.w = w
+	}
+	if err := s.w.Warning(alertLine(ev)); err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package netwatch detects system sleep/resume and local network changes
+// during long-running trace sessions.
+package netwatch
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultSleepThreshold is the minimum suspended time reported as a sleep.
+// Smaller gaps between the wall and monotonic clocks are clock adjustments.
+const DefaultSleepThreshold = 2 * time.Second
+
+// State describes how the host reaches a target: the outgoing interface of
+// the route to it and the source address used.
+type State struct {
+	Interface string
+	Source    net.IP
+}
+
+// String returns the interface name, or "none" when there is no route.
+func (s State) String() string {
+	if s.Interface == "" {
+		return "none"
+	}
+	return s.Interface
+}
+
+// Equal reports whether two states use the same interface and source address.
+func (s State) Equal(o State) bool {
+	return s.Interface == o.Interface && s.Source.Equal(o.Source)
+}
+
+// EventType identifies what was detected.
+type EventType string
+
+const (
+	EventSleep   EventType = "sleep"   // The system was suspended
+	EventNetwork EventType = "network" // The route to the target moved to another interface or address
+)
+
+// Event is a sleep/resume or network change detected between two checks.
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Slept time.Duration // EventSleep: time spent suspended
+	From  State         // EventNetwork: previous state
+	To    State         // EventNetwork: new state
+}
+
+// String formats the event for display, e.g. "network changed (wlan0 → eth0)".
+func (e Event) String() string {
+	if e.Type == EventSleep {
+		return fmt.Sprintf("system resumed after sleeping %s", e.Slept.Round(time.Second))
+	}
+	if e.From.Interface == e.To.Interface && e.To.Interface != "" {
+		return fmt.Sprintf("network changed (%s %s → %s)", e.To.Interface, e.From.Source, e.To.Source)
+	}
+	return fmt.Sprintf("network changed (%s → %s)", e.From, e.To)
+}
+
+// Watcher checks for sleep/resume and route changes towards one target.
+// It is polled by the session loop, typically once per cycle.
+type Watcher struct {
+	target         net.IP
+	state          State
+	wall           time.Time
+	mono           time.Duration
+	sleepThreshold time.Duration
+
+	// Overridable for testing
+	stateFn func(net.IP) State
+	clock   func() (wall time.Time, mono time.Duration)
+}
+
+// New creates a watcher for target and records the current state.
+func New(target net.IP) *Watcher {
+	return NewWithStateFunc(target, CurrentState)
+}
+
+// NewWithStateFunc creates a watcher that looks up the route to target with
+// stateFn instead of CurrentState.
+func NewWithStateFunc(target net.IP, stateFn func(net.IP) State) *Watcher {
+	return newWatcher(target, stateFn, systemClock)
+}
+
+func newWatcher(target net.IP, stateFn func(net.IP) State, clock func() (time.Time, time.Duration)) *Watcher {
+	w := &Watcher{
+		target:         target,
+		sleepThreshold: DefaultSleepThreshold,
+		stateFn:        stateFn,
+		clock:          clock,
+	}
+	w.state = stateFn(target)
+	w.wall, w.mono = clock()
+	return w
+}
+
+// State returns the state recorded at the last check.
+func (w *Watcher) State() State {
+	return w.state
+}
+
+// Retarget makes the watcher follow the route to target from now on. The
+// state of the new route is recorded rather than reported as a change.
+func (w *Watcher) Retarget(target net.IP) {
+	w.target = target
+	w.state = w.stateFn(target)
+}
+
+// Check returns the change detected since the previous check, or nil.
+// A sleep is reported in preference to a network change, since resuming
+// often also brings the network up on another interface.
+func (w *Watcher) Check() *Event {
+	wall, mono := w.clock()
+	state := w.stateFn(w.target)
+
+	// The monotonic clock stops while the system is suspended; the wall clock doesn't
+	suspended := wall.Sub(w.wall) - (mono - w.mono)
+	prev := w.state
+	w.wall, w.mono, w.state = wall, mono, state
+
+	if suspended > w.sleepThreshold {
+		return &Event{Type: EventSleep, Time: wall, Slept: suspended, From: prev, To: state}
+	}
+	if !prev.Equal(state) {
+		return &Event{Type: EventNetwork, Time: wall, From: prev, To: state}
+	}
+	return nil
+}
+
+// processStart anchors monotonic readings.
+var processStart = time.Now()
+
+// systemClock returns the wall clock time and the monotonic time since start.
+func systemClock() (time.Time, time.Duration) {
+	now := time.Now()
+	return now.Round(0), now.Sub(processStart)
+}
+
+// CurrentState returns the interface and source address the kernel would use
+// to reach target. Connecting a UDP socket only performs the route lookup; no
+// packet is sent. Returns the zero State when there is no route.
+func CurrentState(target net.IP) State {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: target, Port: 33434})
+	if err != nil {
+		return State{}
+	}
+	src := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	state := State{Source: src}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return state
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(src) {
+				state.Interface = iface.Name
+				return state
+			}
+		}
+	}
+	return state
+}
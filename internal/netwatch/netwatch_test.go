@@ -0,0 +1,151 @@
+package netwatch
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests advance the wall and monotonic clocks independently.
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (c *fakeClock) now() (time.Time, time.Duration) { return c.wall, c.mono }
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.wall = c.wall.Add(d)
+	c.mono += d
+}
+
+// sleep advances only the wall clock, as happens while suspended.
+func (c *fakeClock) sleep(d time.Duration) {
+	c.wall = c.wall.Add(d)
+}
+
+func testWatcher(state *State, clock *fakeClock) *Watcher {
+	return newWatcher(net.ParseIP("8.8.8.8"), func(net.IP) State { return *state }, clock.now)
+}
+
+var (
+	wlan = State{Interface: "wlan0", Source: net.ParseIP("192.168.1.10")}
+	eth  = State{Interface: "eth0", Source: net.ParseIP("10.0.0.5")}
+)
+
+func TestWatcher_Check_NoChange(t *testing.T) {
+	state := wlan
+	clock := &fakeClock{wall: time.Unix(1700000000, 0)}
+	w := testWatcher(&state, clock)
+
+	clock.advance(30 * time.Second)
+	if ev := w.Check(); ev != nil {
+		t.Errorf("expected no event, got %v", ev)
+	}
+}
+
+func TestWatcher_Check_DetectsSleep(t *testing.T) {
+	state := wlan
+	clock := &fakeClock{wall: time.Unix(1700000000, 0)}
+	w := testWatcher(&state, clock)
+
+	clock.advance(time.Second)
+	clock.sleep(10 * time.Minute)
+	ev := w.Check()
+	if ev == nil || ev.Type != EventSleep {
+		t.Fatalf("expected sleep event, got %v", ev)
+	}
+	if ev.Slept != 10*time.Minute {
+		t.Errorf("expected 10m slept, got %v", ev.Slept)
+	}
+
+	// The next check starts from the resumed clocks
+	clock.advance(time.Second)
+	if ev := w.Check(); ev != nil {
+		t.Errorf("expected no event after resume, got %v", ev)
+	}
+}
+
+func TestWatcher_Check_IgnoresSmallClockAdjustments(t *testing.T) {
+	state := wlan
+	clock := &fakeClock{wall: time.Unix(1700000000, 0)}
+	w := testWatcher(&state, clock)
+
+	clock.advance(time.Second)
+	clock.sleep(500 * time.Millisecond) // NTP slew
+	if ev := w.Check(); ev != nil {
+		t.Errorf("expected no event, got %v", ev)
+	}
+}
+
+func TestWatcher_Check_DetectsNetworkChange(t *testing.T) {
+	state := wlan
+	clock := &fakeClock{wall: time.Unix(1700000000, 0)}
+	w := testWatcher(&state, clock)
+
+	state = eth
+	clock.advance(time.Second)
+	ev := w.Check()
+	if ev == nil || ev.Type != EventNetwork {
+		t.Fatalf("expected network event, got %v", ev)
+	}
+	if got := ev.String(); got != "network changed (wlan0 → eth0)" {
+		t.Errorf("String() = %q", got)
+	}
+	if w.State().Interface != "eth0" {
+		t.Errorf("expected state to follow the change, got %v", w.State())
+	}
+}
+
+func TestWatcher_Retarget_FollowsNewRoute(t *testing.T) {
+	routes := map[string]State{"8.8.8.8": wlan, "192.0.2.1": eth}
+	clock := &fakeClock{wall: time.Unix(1700000000, 0)}
+	w := newWatcher(net.ParseIP("8.8.8.8"), func(ip net.IP) State { return routes[ip.String()] }, clock.now)
+
+	w.Retarget(net.ParseIP("192.0.2.1"))
+	clock.advance(time.Second)
+	if ev := w.Check(); ev != nil {
+		t.Errorf("expected the new route not to be reported, got %v", ev)
+	}
+	if w.State().Interface != "eth0" {
+		t.Errorf("expected the route to the new target, got %v", w.State())
+	}
+
+	routes["192.0.2.1"] = wlan
+	clock.advance(time.Second)
+	if ev := w.Check(); ev == nil || ev.Type != EventNetwork {
+		t.Errorf("expected a change of the route to the new target, got %v", ev)
+	}
+}
+
+func TestEvent_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"sleep", Event{Type: EventSleep, Slept: 3*time.Minute + 12*time.Second}, "system resumed after sleeping 3m12s"},
+		{"lost route", Event{Type: EventNetwork, From: wlan, To: State{}}, "network changed (wlan0 → none)"},
+		{"new address", Event{Type: EventNetwork, From: wlan, To: State{Interface: "wlan0", Source: net.ParseIP("192.168.1.11")}},
+			"network changed (wlan0 192.168.1.10 → 192.168.1.11)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentState_Loopback(t *testing.T) {
+	state := CurrentState(net.ParseIP("127.0.0.1"))
+	if !state.Source.IsLoopback() {
+		t.Skipf("no loopback route in this environment: %v", state)
+	}
+	if state.Interface == "" || strings.Contains(state.String(), "none") {
+		t.Errorf("expected loopback interface, got %v", state)
+	}
+}
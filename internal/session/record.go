@@ -0,0 +1,137 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+)
+
+// Recorder writes the events of a session as they happen. Its methods are
+// safe for concurrent use and do nothing on a nil Recorder, so callers can
+// record unconditionally.
+type Recorder struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	enc     *json.Encoder
+	closer  io.Closer
+	started time.Time
+	now     func() time.Time
+	stream  bool  // Flush every event, not just cycles
+	err     error // First write error, returned by Close
+}
+
+// Create creates the session file at path and writes h to it. Started is
+// set to the current time when zero.
+func Create(path string, h Header) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session file: %w", err)
+	}
+	r, err := NewRecorder(f, h)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewRecorder writes h to w and returns a Recorder writing the session
+// events after it.
+func NewRecorder(w io.Writer, h Header) (*Recorder, error) {
+	h.Version = Version
+	if h.Started.IsZero() {
+		h.Started = time.Now()
+	}
+	if h.Host == "" {
+		h.Host, _ = os.Hostname()
+	}
+	bw := bufio.NewWriter(w)
+	r := &Recorder{w: bw, enc: json.NewEncoder(bw), started: h.Started, now: time.Now}
+	if err := r.enc.Encode(h); err != nil {
+		return nil, fmt.Errorf("failed to write session header: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write session header: %w", err)
+	}
+	return r, nil
+}
+
+// NewStreamRecorder is like NewRecorder but writes every event at once, for
+// a session read live at the other end of a pipe.
+func NewStreamRecorder(w io.Writer, h Header) (*Recorder, error) {
+	r, err := NewRecorder(w, h)
+	if err != nil {
+		return nil, err
+	}
+	r.stream = true
+	return r, nil
+}
+
+// Probe records a probe result.
+func (r *Recorder) Probe(msg display.ProbeResultMsg) {
+	r.record(Event{Kind: KindProbe, Probe: &msg})
+}
+
+// Cycle records a completed cycle. The file is flushed after each cycle so
+// that a session interrupted abruptly keeps all but its last cycle.
+func (r *Recorder) Cycle(msg display.CycleCompleteMsg) {
+	r.record(Event{Kind: KindCycle, Cycle: &msg})
+}
+
+// Enrichment records enrichment resolved after the probe that showed the
+// address.
+func (r *Recorder) Enrichment(msg display.EnrichmentMsg) {
+	r.record(Event{Kind: KindEnrich, Enrichment: &msg})
+}
+
+// Target records a switch to another target.
+func (r *Recorder) Target(target, targetIP string) {
+	r.record(Event{Kind: KindTarget, Target: &Target{Target: target, TargetIP: targetIP}})
+}
+
+func (r *Recorder) record(ev Event) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	ev.At = r.now().Sub(r.started)
+	r.err = r.enc.Encode(ev)
+	if r.err == nil && (r.stream || ev.Kind != KindProbe) {
+		r.err = r.w.Flush()
+	}
+}
+
+// Close flushes the recorded events and closes the file. It returns the
+// first error met while recording.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if ferr := r.w.Flush(); err == nil {
+		err = ferr
+	}
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+		r.closer = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Player replays the events of a session with their recorded timing.
+type Player struct {
+	session *Session
+	speed   float64 // Replay speed factor (0 = no delays)
+
+	mu      sync.Mutex
+	paused  bool
+	changed chan struct{} // Closed when paused changes
+}
+
+// NewPlayer returns a Player replaying s speed times faster than recorded,
+// e.g. 1 for real time, 10 for ten times faster and 0 as fast as possible.
+func NewPlayer(s *Session, speed float64) *Player {
+	return &Player{session: s, speed: speed, changed: make(chan struct{})}
+}
+
+// SetPaused pauses or resumes the replay.
+func (p *Player) SetPaused(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused == paused {
+		return
+	}
+	p.paused = paused
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Play sends the events of the session to feed as TUI messages, each after
+// the delay separating it from the previous one divided by the speed. It
+// returns when every event is sent or ctx is done.
+func (p *Player) Play(ctx context.Context, feed chan<- tea.Msg) error {
+	var last time.Duration
+	for _, ev := range p.session.Events {
+		if p.speed > 0 {
+			if err := p.sleep(ctx, time.Duration(float64(ev.At-last)/p.speed)); err != nil {
+				return err
+			}
+		}
+		last = ev.At
+
+		select {
+		case feed <- ev.Msg():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// sleep waits for d, not counting the time spent paused.
+func (p *Player) sleep(ctx context.Context, d time.Duration) error {
+	for {
+		p.mu.Lock()
+		paused, changed := p.paused, p.changed
+		p.mu.Unlock()
+
+		if paused {
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if d <= 0 {
+			return nil
+		}
+
+		start := time.Now()
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+			return nil
+		case <-changed:
+			timer.Stop()
+			d -= time.Since(start)
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
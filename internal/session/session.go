@@ -0,0 +1,194 @@
+// Package session records the probe events of an MTR session to a file and
+// replays them later through the TUI.
+//
+// A session file holds one JSON object per line: a Header, then one Event
+// per probe result, completed cycle, late enrichment or target switch, in
+// the order the TUI received them.
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+)
+
+// Version is the session file format version.
+const Version = 1
+
+// Header describes the recorded session.
+type Header struct {
+	Version  int           `json:"version"`
+	Target   string        `json:"target"`
+	TargetIP string        `json:"targetIP"`
+	Protocol string        `json:"protocol,omitempty"`
+	Interval time.Duration `json:"interval,omitempty"` // Interval between cycles when recording started
+	History  int           `json:"history,omitempty"`
+	Warmup   int           `json:"warmup,omitempty"`
+	Host     string        `json:"host,omitempty"` // Machine the session was recorded on
+	Started  time.Time     `json:"started"`
+}
+
+// Kind is the type of a recorded event.
+type Kind string
+
+// Recorded event kinds.
+const (
+	KindProbe  Kind = "probe"
+	KindCycle  Kind = "cycle"
+	KindEnrich Kind = "enrich"
+	KindTarget Kind = "target"
+)
+
+// Event is one message received by the TUI. Exactly one of Probe, Cycle,
+// Enrichment and Target is set, according to Kind.
+type Event struct {
+	At         time.Duration             `json:"at"` // Since Header.Started
+	Kind       Kind                      `json:"kind"`
+	Probe      *display.ProbeResultMsg   `json:"probe,omitempty"`
+	Cycle      *display.CycleCompleteMsg `json:"cycle,omitempty"`
+	Enrichment *display.EnrichmentMsg    `json:"enrichment,omitempty"`
+	Target     *Target                   `json:"target,omitempty"`
+}
+
+// Target is a switch to another target with the 't' key of the TUI.
+type Target struct {
+	Target   string `json:"target"`
+	TargetIP string `json:"targetIP"`
+}
+
+// Session is a recorded session read back from a file.
+type Session struct {
+	Header Header
+	Events []Event
+}
+
+// Duration returns the time between the start of the recording and its last
+// event.
+func (s *Session) Duration() time.Duration {
+	if len(s.Events) == 0 {
+		return 0
+	}
+	return s.Events[len(s.Events)-1].At
+}
+
+// Cycles returns the number of completed cycles recorded.
+func (s *Session) Cycles() int {
+	n := 0
+	for _, ev := range s.Events {
+		if ev.Kind == KindCycle {
+			n++
+		}
+	}
+	return n
+}
+
+// Msg returns the TUI message of ev, or nil when the event is malformed.
+func (ev *Event) Msg() tea.Msg {
+	switch {
+	case ev.Kind == KindProbe && ev.Probe != nil:
+		return *ev.Probe
+	case ev.Kind == KindCycle && ev.Cycle != nil:
+		return *ev.Cycle
+	case ev.Kind == KindEnrich && ev.Enrichment != nil:
+		return *ev.Enrichment
+	case ev.Kind == KindTarget && ev.Target != nil:
+		return display.TargetChangedMsg{Target: ev.Target.Target, TargetIP: ev.Target.TargetIP}
+	}
+	return nil
+}
+
+// Open reads the session file at path.
+func Open(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads a session file. A session cut short, e.g. by a crash of the
+// recording machine, ends at its last complete event.
+func Read(r io.Reader) (*Session, error) {
+	d, err := NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{Header: d.Header}
+	for {
+		ev, err := d.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Events = append(s.Events, ev)
+	}
+}
+
+// Decoder reads the events of a session as they are written, e.g. streamed
+// by a remote agent.
+type Decoder struct {
+	Header Header
+
+	r    *bufio.Reader
+	line int
+}
+
+// NewDecoder reads the header of the session in r.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{r: bufio.NewReader(r), line: 1}
+	line, err := d.r.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty session file")
+		}
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	if err := json.Unmarshal(line, &d.Header); err != nil {
+		return nil, fmt.Errorf("not a gtrace session file: %w", err)
+	}
+	if d.Header.Version < 1 {
+		return nil, fmt.Errorf("not a gtrace session file: missing version")
+	}
+	if d.Header.Version > Version {
+		return nil, fmt.Errorf("session file version %d is newer than supported (%d), upgrade gtrace", d.Header.Version, Version)
+	}
+	return d, nil
+}
+
+// Next returns the next event. It returns io.EOF at the end of the session
+// and io.ErrUnexpectedEOF when its last line was only partly written.
+func (d *Decoder) Next() (Event, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) > 0 {
+			d.line++
+			var ev Event
+			if jerr := json.Unmarshal(line, &ev); jerr != nil {
+				if err == io.EOF {
+					return Event{}, io.ErrUnexpectedEOF
+				}
+				return Event{}, fmt.Errorf("line %d: %w", d.line, jerr)
+			}
+			if ev.Msg() == nil {
+				return Event{}, fmt.Errorf("line %d: invalid %q event", d.line, ev.Kind)
+			}
+			return ev, nil
+		}
+		if err == io.EOF {
+			return Event{}, io.EOF
+		}
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to read session: %w", err)
+		}
+	}
+}
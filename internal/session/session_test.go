@@ -0,0 +1,282 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// recordSample records two probes, a cycle, a late hostname and a target
+// switch, 100ms apart.
+func recordSample(t *testing.T, buf *bytes.Buffer) {
+	t.Helper()
+	start := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	r, err := NewRecorder(buf, Header{Target: "example.com", TargetIP: "93.184.216.34", Protocol: "icmp", Host: "laptop", Started: start})
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	now := start
+	r.now = func() time.Time {
+		now = now.Add(100 * time.Millisecond)
+		return now
+	}
+
+	r.Probe(display.ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), RTT: 2 * time.Millisecond, OriginalTTL: -1,
+		MPLS: []hop.MPLSLabel{{Label: 24001, S: true, TTL: 1}}})
+	r.Probe(display.ProbeResultMsg{TTL: 2, Timeout: true, OriginalTTL: -1})
+	r.Cycle(display.CycleCompleteMsg{Cycle: 1})
+	r.Enrichment(display.EnrichmentMsg{IP: net.ParseIP("192.168.1.1"), Enrichment: hop.Enrichment{Hostname: "gw.lan"}})
+	r.Target("example.org", "93.184.215.14")
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	recordSample(t, &buf)
+
+	s, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if s.Header.Version != Version || s.Header.Target != "example.com" || s.Header.Host != "laptop" {
+		t.Errorf("unexpected header: %+v", s.Header)
+	}
+	if len(s.Events) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(s.Events))
+	}
+	if s.Duration() != 500*time.Millisecond {
+		t.Errorf("expected 500ms duration, got %v", s.Duration())
+	}
+	if s.Cycles() != 1 {
+		t.Errorf("expected 1 cycle, got %d", s.Cycles())
+	}
+
+	probe, ok := s.Events[0].Msg().(display.ProbeResultMsg)
+	if !ok {
+		t.Fatalf("expected a probe, got %T", s.Events[0].Msg())
+	}
+	if !probe.IP.Equal(net.ParseIP("192.168.1.1")) || probe.RTT != 2*time.Millisecond || len(probe.MPLS) != 1 || probe.MPLS[0].Label != 24001 {
+		t.Errorf("probe not preserved: %+v", probe)
+	}
+	if e, ok := s.Events[3].Msg().(display.EnrichmentMsg); !ok || e.Enrichment.Hostname != "gw.lan" {
+		t.Errorf("expected enrichment with hostname, got %+v", s.Events[3].Msg())
+	}
+	if tc, ok := s.Events[4].Msg().(display.TargetChangedMsg); !ok || tc.Target != "example.org" || tc.TargetIP != "93.184.215.14" {
+		t.Errorf("expected target switch, got %+v", s.Events[4].Msg())
+	}
+}
+
+func TestRead_TruncatedLastLine(t *testing.T) {
+	var buf bytes.Buffer
+	recordSample(t, &buf)
+	data := buf.String()
+	data = data[:len(data)-10]
+
+	s, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(s.Events) != 4 {
+		t.Errorf("expected the 4 complete events, got %d", len(s.Events))
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"empty", "", "empty session file"},
+		{"not json", "hello\n", "not a gtrace session file"},
+		{"no version", `{"target":"example.com"}` + "\n", "missing version"},
+		{"newer version", `{"version":99}` + "\n", "newer than supported"},
+		{"bad event", `{"version":1}` + "\n" + `{"kind":"probe"}` + "\n", `line 2: invalid "probe" event`},
+		{"garbage", `{"version":1}` + "\n" + "oops\n" + `{"kind":"cycle","cycle":{"Cycle":1}}` + "\n", "line 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStreamRecorder_Decoder(t *testing.T) {
+	// Each event reaches the other end of the pipe as soon as it is recorded
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		r, err := NewStreamRecorder(pw, Header{Target: "example.com", TargetIP: "93.184.216.34"})
+		if err != nil {
+			errc <- err
+			return
+		}
+		r.Probe(display.ProbeResultMsg{TTL: 1, IP: net.ParseIP("192.168.1.1"), OriginalTTL: -1})
+		errc <- nil
+	}()
+
+	d, err := NewDecoder(pr)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	if d.Header.TargetIP != "93.184.216.34" {
+		t.Errorf("unexpected header: %+v", d.Header)
+	}
+	ev, err := d.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if probe, ok := ev.Msg().(display.ProbeResultMsg); !ok || probe.TTL != 1 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("NewStreamRecorder: %v", err)
+	}
+
+	pw.Close()
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestCreate_WritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.gtr")
+	r, err := Create(path, Header{Target: "example.com", TargetIP: "93.184.216.34"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	r.Cycle(display.CycleCompleteMsg{Cycle: 1, Reached: true})
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if s.Header.Started.IsZero() || s.Cycles() != 1 {
+		t.Errorf("unexpected session: %+v", s)
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+	r.Probe(display.ProbeResultMsg{TTL: 1})
+	r.Cycle(display.CycleCompleteMsg{Cycle: 1})
+	if err := r.Close(); err != nil {
+		t.Errorf("Close on nil recorder: %v", err)
+	}
+}
+
+func TestPlayer_SendsEventsInOrder(t *testing.T) {
+	var buf bytes.Buffer
+	recordSample(t, &buf)
+	s, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	feed := make(chan tea.Msg, len(s.Events))
+	if err := NewPlayer(s, 0).Play(context.Background(), feed); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	close(feed)
+
+	var kinds []string
+	for msg := range feed {
+		switch msg.(type) {
+		case display.ProbeResultMsg:
+			kinds = append(kinds, "probe")
+		case display.CycleCompleteMsg:
+			kinds = append(kinds, "cycle")
+		case display.EnrichmentMsg:
+			kinds = append(kinds, "enrich")
+		case display.TargetChangedMsg:
+			kinds = append(kinds, "target")
+		}
+	}
+	if got := strings.Join(kinds, ","); got != "probe,probe,cycle,enrich,target" {
+		t.Errorf("unexpected order: %s", got)
+	}
+}
+
+func TestPlayer_Speed(t *testing.T) {
+	var buf bytes.Buffer
+	recordSample(t, &buf)
+	s, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	// 500ms of session at 10x
+	feed := make(chan tea.Msg, len(s.Events))
+	start := time.Now()
+	if err := NewPlayer(s, 10).Play(context.Background(), feed); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("expected about 50ms at 10x, took %v", elapsed)
+	}
+}
+
+func TestPlayer_Pause(t *testing.T) {
+	var buf bytes.Buffer
+	recordSample(t, &buf)
+	s, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	p := NewPlayer(s, 10)
+	p.SetPaused(true)
+	feed := make(chan tea.Msg, len(s.Events))
+	done := make(chan error, 1)
+	go func() { done <- p.Play(context.Background(), feed) }()
+
+	time.Sleep(100 * time.Millisecond)
+	if len(feed) != 0 {
+		t.Fatalf("expected no event while paused, got %d", len(feed))
+	}
+	p.SetPaused(false)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Play: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replay did not resume")
+	}
+	if len(feed) != len(s.Events) {
+		t.Errorf("expected %d events, got %d", len(s.Events), len(feed))
+	}
+}
+
+func TestPlayer_Cancel(t *testing.T) {
+	var buf bytes.Buffer
+	recordSample(t, &buf)
+	s, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewPlayer(s, 1).Play(ctx, make(chan tea.Msg)); err == nil {
+		t.Error("expected an error once cancelled")
+	}
+}
@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"context"
+	"net"
+
+	"github.com/hervehildenbrand/gtrace/internal/logging"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// AutoTCPPort is the port TCP probes of --protocol auto go to: HTTPS is the
+// one port nearly every firewall lets through.
+const AutoTCPPort = 443
+
+// autoProtocols is the order AutoTracer tries protocols in.
+var autoProtocols = []Protocol{ProtocolICMP, ProtocolUDP, ProtocolTCP}
+
+// AutoTracer traces with ICMP probes first and falls back to UDP, then TCP
+// to port 443, while the path blackholes the probes before the target. The
+// hops of every attempt are merged, each TTL taken from the protocol that got
+// the most answers there, and tagged with that protocol.
+type AutoTracer struct {
+	config *Config
+}
+
+// NewAutoTracer creates a tracer for --protocol auto. UDP probes go to
+// cfg.Port, TCP probes to AutoTCPPort.
+func NewAutoTracer(cfg *Config) *AutoTracer {
+	return &AutoTracer{config: cfg}
+}
+
+// Trace runs the attempts and calls callback with each merged hop once the
+// last attempt is done: a hop of an earlier attempt may be replaced by a
+// later one.
+func (t *AutoTracer) Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+	log := logging.FromContext(ctx).With("target", target.String())
+
+	var attempts []*hop.TraceResult
+	for _, p := range autoProtocols {
+		tracer, err := NewLocalTracer(t.attemptConfig(p))
+		if err == nil {
+			var tr *hop.TraceResult
+			if tr, err = tracer.Trace(ctx, target, nil); err == nil {
+				for _, h := range tr.Hops {
+					h.Protocol = string(p)
+				}
+				tr.Protocol = string(p)
+				attempts = append(attempts, tr)
+				if tr.ReachedTarget {
+					break
+				}
+				log.Debug("probes blackholed before the target, falling back", "protocol", p, "hops", tr.TotalHops())
+				continue
+			}
+		}
+		if len(attempts) == 0 || ctx.Err() != nil {
+			return nil, err
+		}
+		log.Warn("fallback trace failed", "protocol", p, "err", err)
+		break
+	}
+
+	result := mergeAutoAttempts(attempts)
+	if callback != nil {
+		for _, h := range result.Hops {
+			callback(h)
+		}
+	}
+	return result, nil
+}
+
+// attemptConfig returns the configuration of the attempt with protocol p.
+func (t *AutoTracer) attemptConfig(p Protocol) *Config {
+	cfg := *t.config
+	cfg.Protocol = p
+	switch p {
+	case ProtocolICMP:
+		cfg.Shards = 0
+	case ProtocolTCP:
+		cfg.Port = AutoTCPPort
+	}
+	return &cfg
+}
+
+// mergeAutoAttempts merges the hops of attempts, in the order they were
+// run. Each TTL comes from the attempt whose probes got the most answers
+// there, the earliest on a tie. When an attempt reached the target, hops
+// past it are dropped and the target hop is its own.
+func mergeAutoAttempts(attempts []*hop.TraceResult) *hop.TraceResult {
+	first, last := attempts[0], attempts[len(attempts)-1]
+	result := hop.NewTraceResult(first.Target, first.TargetIP)
+	result.Protocol = string(ProtocolAuto)
+	result.StartTime = first.StartTime
+	result.EndTime = last.EndTime
+	result.TimestampSource = first.TimestampSource
+	result.ReachedTarget = last.ReachedTarget
+
+	maxTTL := 0
+	for _, tr := range attempts {
+		for _, h := range tr.Hops {
+			maxTTL = max(maxTTL, h.TTL)
+		}
+	}
+	if last.ReachedTarget {
+		maxTTL = last.Hops[len(last.Hops)-1].TTL
+	}
+
+	for ttl := 1; ttl <= maxTTL; ttl++ {
+		var best *hop.Hop
+		bestAnswered := -1
+		for _, tr := range attempts {
+			h := tr.GetHop(ttl)
+			if h == nil {
+				continue
+			}
+			if n := answeredProbes(h); n > bestAnswered || (ttl == maxTTL && last.ReachedTarget && tr == last) {
+				best, bestAnswered = h, n
+			}
+		}
+		if best != nil {
+			result.AddHop(best)
+		}
+	}
+	return result
+}
+
+// answeredProbes counts the probes of h that got an answer.
+func answeredProbes(h *hop.Hop) int {
+	n := 0
+	for _, p := range h.Probes {
+		if !p.Timeout {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestMergeAutoAttempts_FallbackReachesTarget(t *testing.T) {
+	icmp := quicTestTrace(2, 8)
+	icmp.Protocol = "icmp"
+	for _, h := range icmp.Hops {
+		h.Protocol = "icmp"
+	}
+	udp := quicTestTrace(5, 5)
+	udp.Hops[1].Probes[0].Timeout = true
+	udp.Hops[1].Probes[0].IP = nil
+	udp.ReachedTarget = true
+	for _, h := range udp.Hops {
+		h.Protocol = "udp"
+	}
+
+	got := mergeAutoAttempts([]*hop.TraceResult{icmp, udp})
+	if !got.ReachedTarget || got.Protocol != "auto" {
+		t.Errorf("ReachedTarget = %v, Protocol = %q, want a reached auto trace", got.ReachedTarget, got.Protocol)
+	}
+	if len(got.Hops) != 5 {
+		t.Fatalf("expected hops past the target dropped, got %d hops", len(got.Hops))
+	}
+	want := []string{"icmp", "icmp", "udp", "udp", "udp"}
+	for i, h := range got.Hops {
+		if h.Protocol != want[i] {
+			t.Errorf("hop %d: protocol %q, want %q", h.TTL, h.Protocol, want[i])
+		}
+	}
+}
+
+func TestMergeAutoAttempts_NoneReached(t *testing.T) {
+	icmp := quicTestTrace(2, 6)
+	udp := quicTestTrace(3, 6)
+	tcp := quicTestTrace(1, 6)
+
+	got := mergeAutoAttempts([]*hop.TraceResult{icmp, udp, tcp})
+	if got.ReachedTarget {
+		t.Error("expected the target not reached")
+	}
+	if len(got.Hops) != 6 {
+		t.Fatalf("expected 6 hops, got %d", len(got.Hops))
+	}
+	if got.Hops[2] != udp.Hops[2] {
+		t.Error("expected hop 3 from the udp attempt, the only one answered there")
+	}
+	if got.Hops[0] != icmp.Hops[0] {
+		t.Error("expected hop 1 from the icmp attempt on a tie")
+	}
+}
+
+func TestAutoTracer_AttemptConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Protocol = ProtocolAuto
+	cfg.Shards = 4
+	tracer := NewAutoTracer(cfg)
+
+	if c := tracer.attemptConfig(ProtocolICMP); c.Shards != 0 || c.Protocol != ProtocolICMP {
+		t.Errorf("icmp attempt: protocol %s, %d shards", c.Protocol, c.Shards)
+	}
+	if c := tracer.attemptConfig(ProtocolUDP); c.Port != cfg.Port || c.Shards != 4 {
+		t.Errorf("udp attempt: port %d, %d shards", c.Port, c.Shards)
+	}
+	if c := tracer.attemptConfig(ProtocolTCP); c.Port != AutoTCPPort {
+		t.Errorf("tcp attempt: port %d, want %d", c.Port, AutoTCPPort)
+	}
+	if cfg.Protocol != ProtocolAuto {
+		t.Error("attempts must not modify the auto config")
+	}
+}
+
+func TestNewLocalTracer_Auto(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Protocol = ProtocolAuto
+	tracer, err := NewLocalTracer(cfg)
+	if err != nil {
+		t.Fatalf("NewLocalTracer() error = %v", err)
+	}
+	if _, ok := tracer.(*AutoTracer); !ok {
+		t.Errorf("expected an AutoTracer, got %T", tracer)
+	}
+}
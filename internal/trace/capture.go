@@ -0,0 +1,280 @@
+package trace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// IP protocol numbers used when synthesizing captured packets.
+const (
+	ipProtoTCP = 6
+	ipProtoUDP = 17
+)
+
+// captureSnapLen is the snapshot length advertised in the pcap file header.
+const captureSnapLen = 65535
+
+// CaptureSink receives raw IP packets observed during a trace.
+// Implementations must be safe for concurrent use, since several tracers
+// may share one sink (compare mode, multi-target MTR).
+type CaptureSink interface {
+	WritePacket(ts time.Time, data []byte) error
+}
+
+// PcapWriter writes captured packets to a pcap file using the raw IP link type,
+// so both IPv4 and IPv6 packets can be stored in the same capture.
+type PcapWriter struct {
+	mu     sync.Mutex
+	w      *pcapgo.Writer
+	closer io.Closer
+}
+
+// NewPcapWriter creates a pcap file at path and writes the file header.
+func NewPcapWriter(path string) (*PcapWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pcap file: %w", err)
+	}
+
+	pw, err := newPcapWriter(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	pw.closer = f
+	return pw, nil
+}
+
+// newPcapWriter wraps an io.Writer with a pcap writer and writes the file header.
+func newPcapWriter(w io.Writer) (*PcapWriter, error) {
+	pw := pcapgo.NewWriter(w)
+	if err := pw.WriteFileHeader(captureSnapLen, layers.LinkTypeRaw); err != nil {
+		return nil, fmt.Errorf("failed to write pcap header: %w", err)
+	}
+	return &PcapWriter{w: pw}, nil
+}
+
+// WritePacket appends a single raw IP packet to the capture.
+func (p *PcapWriter) WritePacket(ts time.Time, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ci := gopacket.CaptureInfo{
+		Timestamp:     ts,
+		CaptureLength: len(data),
+		Length:        len(data),
+	}
+	return p.w.WritePacket(ci, data)
+}
+
+// Close closes the underlying file, if any.
+func (p *PcapWriter) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
+
+// capturePacket wraps payload in a synthesized IP header and hands it to sink.
+// Tracers only see transport payloads (the kernel builds the IP header), so the
+// header is reconstructed from what is known about the probe or response.
+// Capture is best-effort: write errors never interrupt a trace.
+func capturePacket(sink CaptureSink, ts time.Time, src, dst net.IP, proto, ttl int, payload []byte) {
+	if sink == nil {
+		return
+	}
+	_ = sink.WritePacket(ts, BuildIPPacket(src, dst, proto, ttl, payload))
+}
+
+// captureResponse records an ICMP response from peer addressed to local.
+// The response TTL is only known when NAT detection enables control messages,
+// so a typical initial TTL is recorded otherwise.
+func captureResponse(sink CaptureSink, ts time.Time, peer, local net.IP, responseTTL int, msg []byte) {
+	if responseTTL <= 0 {
+		responseTTL = 64
+	}
+	capturePacket(sink, ts, peer, local, ICMPProtocolNum(peer), responseTTL, msg)
+}
+
+// captureTCPReply records the SYN-ACK or RST target sent from targetPort to
+// local's localPort. The TTL it arrived with is known from its fingerprint
+// only, so a typical initial TTL is recorded otherwise.
+func captureTCPReply(sink CaptureSink, ts time.Time, target, local net.IP, targetPort, localPort int, accepted bool, fp *hop.TCPFingerprint) {
+	ttl := 64
+	if fp != nil && fp.TTL > 0 {
+		ttl = fp.TTL
+	}
+	capturePacket(sink, ts, target, local, ipProtoTCP, ttl, buildTCPReply(target, local, targetPort, localPort, accepted))
+}
+
+// captureSourceIP returns the local address the kernel of network namespace
+// netns would use to reach target, or the unspecified address if it cannot be
+// determined. No packets are sent: connecting a UDP socket only performs a
+// route lookup.
+func captureSourceIP(netns string, target net.IP) net.IP {
+	var conn net.Conn
+	err := inNetNS(netns, func() (err error) {
+		conn, err = net.Dial(udpNetwork(target), net.JoinHostPort(target.String(), "9"))
+		return err
+	})
+	if err != nil {
+		if IsIPv6(target) {
+			return net.IPv6unspecified
+		}
+		return net.IPv4zero
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+// udpNetwork returns "udp4" or "udp6" for the given IP.
+func udpNetwork(ip net.IP) string {
+	if IsIPv6(ip) {
+		return "udp6"
+	}
+	return "udp4"
+}
+
+// BuildIPPacket prepends an IPv4 or IPv6 header to payload.
+// The address family is taken from dst. IPv4 headers carry a valid checksum.
+func BuildIPPacket(src, dst net.IP, proto, ttl int, payload []byte) []byte {
+	if IsIPv6(dst) {
+		pkt := make([]byte, 40+len(payload))
+		pkt[0] = 0x60
+		binary.BigEndian.PutUint16(pkt[4:6], uint16(len(payload)))
+		pkt[6] = byte(proto)
+		pkt[7] = byte(ttl)
+		copy(pkt[8:24], src.To16())
+		copy(pkt[24:40], dst.To16())
+		copy(pkt[40:], payload)
+		return pkt
+	}
+
+	pkt := make([]byte, 20+len(payload))
+	pkt[0] = 0x45
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	pkt[8] = byte(ttl)
+	pkt[9] = byte(proto)
+	if s := src.To4(); s != nil {
+		copy(pkt[12:16], s)
+	}
+	copy(pkt[16:20], dst.To4())
+	binary.BigEndian.PutUint16(pkt[10:12], internetChecksum(pkt[:20], 0))
+	copy(pkt[20:], payload)
+	return pkt
+}
+
+// buildUDPSegment builds a UDP header plus payload with a valid checksum.
+func buildUDPSegment(src, dst net.IP, srcPort, dstPort int, payload []byte) []byte {
+	seg := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint16(seg[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(seg[2:4], uint16(dstPort))
+	binary.BigEndian.PutUint16(seg[4:6], uint16(len(seg)))
+	copy(seg[8:], payload)
+
+	sum := internetChecksum(seg, pseudoHeaderSum(src, dst, ipProtoUDP, len(seg)))
+	if sum == 0 {
+		sum = 0xffff // RFC 768: transmitted as all ones
+	}
+	binary.BigEndian.PutUint16(seg[6:8], sum)
+	return seg
+}
+
+// buildTCPSyn builds a minimal TCP SYN header with a valid checksum.
+// The kernel chooses the real sequence number, so it is recorded as zero.
+func buildTCPSyn(src, dst net.IP, srcPort, dstPort int) []byte {
+	return buildTCPSegment(src, dst, srcPort, dstPort, tcpFlagSYN)
+}
+
+// buildTCPReply builds a minimal TCP header of the answer to a SYN: a
+// SYN-ACK when the connection was accepted, a RST otherwise. The kernel
+// consumes the real segment, so its sequence numbers are recorded as zero.
+func buildTCPReply(src, dst net.IP, srcPort, dstPort int, accepted bool) []byte {
+	if accepted {
+		return buildTCPSegment(src, dst, srcPort, dstPort, tcpFlagSYN|tcpFlagACK)
+	}
+	return buildTCPSegment(src, dst, srcPort, dstPort, tcpFlagRST|tcpFlagACK)
+}
+
+// buildTCPSegment builds a 20-byte TCP header with flags and a valid checksum.
+func buildTCPSegment(src, dst net.IP, srcPort, dstPort int, flags byte) []byte {
+	seg := make([]byte, 20)
+	binary.BigEndian.PutUint16(seg[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(seg[2:4], uint16(dstPort))
+	seg[12] = 5 << 4 // Data offset: 5 words
+	seg[13] = flags
+	binary.BigEndian.PutUint16(seg[14:16], 65535)
+
+	sum := internetChecksum(seg, pseudoHeaderSum(src, dst, ipProtoTCP, len(seg)))
+	binary.BigEndian.PutUint16(seg[16:18], sum)
+	return seg
+}
+
+// icmpv6WithChecksum returns a copy of an ICMPv6 message with its checksum filled in.
+// The kernel computes ICMPv6 checksums on send, so marshalled probes carry zero.
+func icmpv6WithChecksum(src, dst net.IP, msg []byte) []byte {
+	if len(msg) < 4 {
+		return msg
+	}
+	out := make([]byte, len(msg))
+	copy(out, msg)
+	out[2], out[3] = 0, 0
+	sum := internetChecksum(out, pseudoHeaderSum(src, dst, ICMPProtocolNum(dst), len(out)))
+	binary.BigEndian.PutUint16(out[2:4], sum)
+	return out
+}
+
+// pseudoHeaderSum returns the unfolded one's complement sum of the
+// IPv4 or IPv6 pseudo-header used by UDP and TCP checksums.
+func pseudoHeaderSum(src, dst net.IP, proto, length int) uint32 {
+	var ph []byte
+	if IsIPv6(dst) {
+		ph = make([]byte, 40)
+		copy(ph[0:16], src.To16())
+		copy(ph[16:32], dst.To16())
+		binary.BigEndian.PutUint32(ph[32:36], uint32(length))
+		ph[39] = byte(proto)
+	} else {
+		ph = make([]byte, 12)
+		if s := src.To4(); s != nil {
+			copy(ph[0:4], s)
+		}
+		copy(ph[4:8], dst.To4())
+		ph[9] = byte(proto)
+		binary.BigEndian.PutUint16(ph[10:12], uint16(length))
+	}
+
+	var sum uint32
+	for i := 0; i < len(ph); i += 2 {
+		sum += uint32(ph[i])<<8 | uint32(ph[i+1])
+	}
+	return sum
+}
+
+// internetChecksum computes the RFC 1071 checksum of data, seeded with initial.
+func internetChecksum(data []byte, initial uint32) uint16 {
+	sum := initial
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,193 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBuildIPPacket_IPv4Header(t *testing.T) {
+	src := net.ParseIP("192.0.2.1")
+	dst := net.ParseIP("198.51.100.7")
+	payload := []byte{8, 0, 0, 0, 0, 1, 0, 1}
+
+	pkt := BuildIPPacket(src, dst, 1, 5, payload)
+
+	if len(pkt) != 20+len(payload) {
+		t.Fatalf("len = %d, want %d", len(pkt), 20+len(payload))
+	}
+	if pkt[0] != 0x45 {
+		t.Errorf("version/IHL = %#x, want 0x45", pkt[0])
+	}
+	if got := binary.BigEndian.Uint16(pkt[2:4]); int(got) != len(pkt) {
+		t.Errorf("total length = %d, want %d", got, len(pkt))
+	}
+	if pkt[8] != 5 {
+		t.Errorf("TTL = %d, want 5", pkt[8])
+	}
+	if pkt[9] != 1 {
+		t.Errorf("protocol = %d, want 1", pkt[9])
+	}
+	if !net.IP(pkt[12:16]).Equal(src) || !net.IP(pkt[16:20]).Equal(dst) {
+		t.Errorf("addresses = %v -> %v", net.IP(pkt[12:16]), net.IP(pkt[16:20]))
+	}
+	// A valid header checksums to zero
+	if sum := internetChecksum(pkt[:20], 0); sum != 0 {
+		t.Errorf("header checksum invalid: %#x", sum)
+	}
+	if !bytes.Equal(pkt[20:], payload) {
+		t.Error("payload not copied")
+	}
+}
+
+func TestBuildIPPacket_IPv6Header(t *testing.T) {
+	src := net.ParseIP("2001:db8::1")
+	dst := net.ParseIP("2001:db8::2")
+	payload := []byte{128, 0, 0, 0}
+
+	pkt := BuildIPPacket(src, dst, 58, 7, payload)
+
+	if len(pkt) != 40+len(payload) {
+		t.Fatalf("len = %d, want %d", len(pkt), 40+len(payload))
+	}
+	if pkt[0]>>4 != 6 {
+		t.Errorf("version = %d, want 6", pkt[0]>>4)
+	}
+	if got := binary.BigEndian.Uint16(pkt[4:6]); int(got) != len(payload) {
+		t.Errorf("payload length = %d, want %d", got, len(payload))
+	}
+	if pkt[6] != 58 || pkt[7] != 7 {
+		t.Errorf("next header/hop limit = %d/%d, want 58/7", pkt[6], pkt[7])
+	}
+	if !net.IP(pkt[8:24]).Equal(src) || !net.IP(pkt[24:40]).Equal(dst) {
+		t.Error("addresses not set")
+	}
+}
+
+func TestBuildUDPSegment_ValidChecksum(t *testing.T) {
+	src := net.ParseIP("192.0.2.1")
+	dst := net.ParseIP("198.51.100.7")
+
+	seg := buildUDPSegment(src, dst, 40000, 33434, []byte("gtr-1-2-3"))
+
+	if got := binary.BigEndian.Uint16(seg[2:4]); got != 33434 {
+		t.Errorf("dst port = %d, want 33434", got)
+	}
+	if sum := internetChecksum(seg, pseudoHeaderSum(src, dst, ipProtoUDP, len(seg))); sum != 0 {
+		t.Errorf("UDP checksum invalid: %#x", sum)
+	}
+}
+
+func TestBuildTCPSyn_SetsSYNFlag(t *testing.T) {
+	src := net.ParseIP("2001:db8::1")
+	dst := net.ParseIP("2001:db8::2")
+
+	seg := buildTCPSyn(src, dst, 40000, 443)
+
+	if seg[13] != 0x02 {
+		t.Errorf("flags = %#x, want SYN", seg[13])
+	}
+	if sum := internetChecksum(seg, pseudoHeaderSum(src, dst, ipProtoTCP, len(seg))); sum != 0 {
+		t.Errorf("TCP checksum invalid: %#x", sum)
+	}
+}
+
+func TestBuildTCPReply_SetsFlags(t *testing.T) {
+	src := net.ParseIP("192.0.2.1")
+	dst := net.ParseIP("192.0.2.2")
+
+	tests := []struct {
+		accepted bool
+		want     byte
+	}{
+		{true, tcpFlagSYN | tcpFlagACK},
+		{false, tcpFlagRST | tcpFlagACK},
+	}
+	for _, tt := range tests {
+		seg := buildTCPReply(src, dst, 443, 40000, tt.accepted)
+		if seg[13] != tt.want {
+			t.Errorf("accepted=%v: flags = %#x, want %#x", tt.accepted, seg[13], tt.want)
+		}
+		if got := binary.BigEndian.Uint16(seg[0:2]); got != 443 {
+			t.Errorf("accepted=%v: src port = %d, want 443", tt.accepted, got)
+		}
+		if sum := internetChecksum(seg, pseudoHeaderSum(src, dst, ipProtoTCP, len(seg))); sum != 0 {
+			t.Errorf("accepted=%v: TCP checksum invalid: %#x", tt.accepted, sum)
+		}
+	}
+}
+
+func TestICMPv6WithChecksum_DoesNotModifyInput(t *testing.T) {
+	src := net.ParseIP("2001:db8::1")
+	dst := net.ParseIP("2001:db8::2")
+	msg := []byte{128, 0, 0, 0, 0, 1, 0, 1}
+
+	out := icmpv6WithChecksum(src, dst, msg)
+
+	if msg[2] != 0 || msg[3] != 0 {
+		t.Error("input message was modified")
+	}
+	if sum := internetChecksum(out, pseudoHeaderSum(src, dst, 58, len(out))); sum != 0 {
+		t.Errorf("ICMPv6 checksum invalid: %#x", sum)
+	}
+}
+
+func TestPcapWriter_WritesHeaderAndPackets(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newPcapWriter(&buf)
+	if err != nil {
+		t.Fatalf("newPcapWriter: %v", err)
+	}
+
+	// Global header: 24 bytes, raw IP link type (101)
+	if buf.Len() != 24 {
+		t.Fatalf("header len = %d, want 24", buf.Len())
+	}
+	if lt := binary.LittleEndian.Uint32(buf.Bytes()[20:24]); lt != 101 {
+		t.Errorf("link type = %d, want 101", lt)
+	}
+
+	pkt := BuildIPPacket(net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"), 1, 1, []byte{8, 0, 0, 0})
+	if err := pw.WritePacket(time.Now(), pkt); err != nil {
+		t.Fatalf("WritePacket: %v", err)
+	}
+
+	// Record header (16 bytes) + packet data
+	if buf.Len() != 24+16+len(pkt) {
+		t.Errorf("file len = %d, want %d", buf.Len(), 24+16+len(pkt))
+	}
+	if err := pw.Close(); err != nil {
+		t.Errorf("Close on writer without file: %v", err)
+	}
+}
+
+type recordingSink struct {
+	packets [][]byte
+}
+
+func (r *recordingSink) WritePacket(ts time.Time, data []byte) error {
+	r.packets = append(r.packets, data)
+	return nil
+}
+
+func TestCapturePacket_NilSinkIsNoop(t *testing.T) {
+	// Must not panic
+	capturePacket(nil, time.Now(), nil, net.ParseIP("192.0.2.1"), 1, 1, nil)
+}
+
+func TestCaptureResponse_DefaultsTTL(t *testing.T) {
+	sink := &recordingSink{}
+	captureResponse(sink, time.Now(), net.ParseIP("192.0.2.9"), net.ParseIP("192.0.2.1"), 0, []byte{11, 0, 0, 0})
+
+	if len(sink.packets) != 1 {
+		t.Fatalf("captured %d packets, want 1", len(sink.packets))
+	}
+	if ttl := sink.packets[0][8]; ttl != 64 {
+		t.Errorf("TTL = %d, want 64", ttl)
+	}
+	if !net.IP(sink.packets[0][12:16]).Equal(net.ParseIP("192.0.2.9")) {
+		t.Error("response source should be the peer")
+	}
+}
@@ -3,23 +3,29 @@ package trace
 import (
 	"context"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/netwatch"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
 // ProbeResult represents a single probe result for continuous tracing.
 type ProbeResult struct {
-	TTL           int
-	IP            net.IP
-	RTT           time.Duration
-	Timeout       bool
-	MPLS          []hop.MPLSLabel
-	ICMPType      int
-	ICMPCode      int
-	OriginalTTL   int
-	FlowID        int
-	TransportInfo *hop.TransportInfo
+	TTL            int
+	IP             net.IP
+	RTT            time.Duration
+	Timeout        bool
+	MPLS           []hop.MPLSLabel
+	ICMPType       int
+	ICMPCode       int
+	OriginalTTL    int
+	FlowID         int
+	TransportInfo  *hop.TransportInfo
+	NAT            bool                // NAT detected at this hop
+	MTU            int                 // Discovered MTU at this hop (0 = unknown)
+	SegmentRouting *hop.SegmentRouting // Segment routing domain of this hop (nil if none seen)
 }
 
 // ProbeCallback is called for each probe result.
@@ -28,19 +34,153 @@ type ProbeCallback func(ProbeResult)
 // CycleCallback is called when a trace cycle completes.
 type CycleCallback func(cycle int, reached bool)
 
-// ContinuousTracer runs traces continuously in a loop.
+// NetworkCallback is called instead of the CycleCallback when a cycle
+// overlapped a system sleep or a network change, so its results are invalid.
+type NetworkCallback func(cycle int, event netwatch.Event)
+
+// Adaptive max hops: once the target answered at hop K, cycles probe up to
+// K+adaptiveHopMargin, so a lost reply from the target no longer costs up to
+// MaxHops timeouts. Cycles probe up to MaxHops again after
+// adaptiveExpandMisses cycles in a row without reaching the target, e.g.
+// when the path got longer.
+const (
+	adaptiveHopMargin    = 2
+	adaptiveExpandMisses = 3
+)
+
+// ContinuousTracer runs traces continuously in a loop. Its interval and
+// probe size can be changed while it runs.
 type ContinuousTracer struct {
-	config   *Config
-	tracer   Tracer
-	interval time.Duration
+	config    atomic.Pointer[Config] // Replaced, never modified, when the probe size changes
+	tracer    Tracer
+	newTracer func(*Config) (Tracer, error) // Creates the tracer of a changed config
+	interval  atomic.Int64                  // time.Duration between the starts of cycles
+	sweeps    int                           // Traces per cycle, each probing every hop once (0 = 1)
+	adaptive  bool                          // Limit hops to just past the target once reached
+
+	watcher   *netwatch.Watcher
+	onNetwork NetworkCallback
+
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{} // Closed when a pause ends
 }
 
 // NewContinuousTracer creates a new continuous tracer.
 func NewContinuousTracer(cfg *Config, tracer Tracer, interval time.Duration) *ContinuousTracer {
-	return &ContinuousTracer{
-		config:   cfg,
-		tracer:   tracer,
-		interval: interval,
+	ct := &ContinuousTracer{tracer: tracer, newTracer: NewLocalTracer}
+	ct.config.Store(cfg)
+	ct.interval.Store(int64(interval))
+	return ct
+}
+
+// Interval returns the time between the starts of cycles.
+func (ct *ContinuousTracer) Interval() time.Duration {
+	return time.Duration(ct.interval.Load())
+}
+
+// SetInterval changes the time between the starts of cycles, from the
+// next wait between cycles on.
+func (ct *ContinuousTracer) SetInterval(d time.Duration) {
+	ct.interval.Store(int64(d))
+}
+
+// ProbeSize returns the size of probes in bytes.
+func (ct *ContinuousTracer) ProbeSize() int {
+	return ct.config.Load().ProbeSize
+}
+
+// SetProbeSize changes the size of probes from the next cycle on, which
+// traces with a tracer created from the updated config (see
+// SetTracerFactory).
+func (ct *ContinuousTracer) SetProbeSize(size int) {
+	for {
+		old := ct.config.Load()
+		next := *old
+		next.ProbeSize = size
+		if ct.config.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// SetNetworkWatcher checks w after every cycle. A cycle during which (or
+// before which, since the previous cycle) the system slept or the route to the
+// target changed is reported to cb instead of the cycle callback.
+func (ct *ContinuousTracer) SetNetworkWatcher(w *netwatch.Watcher, cb NetworkCallback) {
+	ct.watcher = w
+	ct.onNetwork = cb
+}
+
+// SetPacketsPerHop makes every cycle probe each hop n times. Rather than
+// sending the n probes of a hop back to back, which routers rate-limiting
+// their ICMP replies would partly ignore, the cycle runs n traces of the
+// tracer (which must send one probe per hop) spread evenly over the
+// interval. Must be called before Run.
+func (ct *ContinuousTracer) SetPacketsPerHop(n int) {
+	ct.sweeps = n
+}
+
+// SetTracerFactory makes the tracers of changed configs, after a probe size
+// change or while adaptive max hops limits the hops, be created by f instead
+// of NewLocalTracer. Must be called before Run.
+func (ct *ContinuousTracer) SetTracerFactory(f func(*Config) (Tracer, error)) {
+	ct.newTracer = f
+}
+
+// SetAdaptiveMaxHops makes cycles after the target answered probe only a
+// few hops past it, instead of up to MaxHops. Must be called before Run.
+func (ct *ContinuousTracer) SetAdaptiveMaxHops(on bool) {
+	ct.adaptive = on
+}
+
+// limitHops returns cfg probing up to limit hops, or cfg itself when limit
+// is 0 or not below its MaxHops.
+func limitHops(cfg *Config, limit int) *Config {
+	if limit <= 0 || limit >= cfg.MaxHops {
+		return cfg
+	}
+	limited := *cfg
+	limited.MaxHops = limit
+	return &limited
+}
+
+// SetPaused stops or resumes probing. A cycle in progress when probing is
+// paused runs to completion; the next one starts once probing resumes.
+func (ct *ContinuousTracer) SetPaused(paused bool) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if paused == ct.paused {
+		return
+	}
+	ct.paused = paused
+	if paused {
+		ct.resume = make(chan struct{})
+	} else {
+		close(ct.resume)
+	}
+}
+
+// Paused returns whether probing is paused.
+func (ct *ContinuousTracer) Paused() bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.paused
+}
+
+// waitResumed blocks while probing is paused.
+func (ct *ContinuousTracer) waitResumed(ctx context.Context) error {
+	ct.mu.Lock()
+	paused, resume := ct.paused, ct.resume
+	ct.mu.Unlock()
+	if !paused {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resume:
+		return nil
 	}
 }
 
@@ -49,6 +189,32 @@ func NewContinuousTracer(cfg *Config, tracer Tracer, interval time.Duration) *Co
 // The function returns when the context is cancelled.
 func (ct *ContinuousTracer) Run(ctx context.Context, target net.IP, probeCallback ProbeCallback, cycleCallback CycleCallback) error {
 	cycle := 0
+	tracer, tracerCfg, tracerLimit := ct.tracer, ct.config.Load(), 0
+	hopLimit, misses := 0, 0 // Adaptive max hops (0 = MaxHops) and cycles since the target answered
+
+	// Convert hop probes to ProbeResults
+	onHop := func(h *hop.Hop) {
+		for _, p := range h.Probes {
+			pr := ProbeResult{
+				TTL:            h.TTL,
+				IP:             p.IP,
+				RTT:            p.RTT,
+				Timeout:        p.Timeout,
+				MPLS:           h.MPLS,
+				ICMPType:       p.ICMPType,
+				ICMPCode:       p.ICMPCode,
+				OriginalTTL:    p.OriginalTTL,
+				FlowID:         p.FlowID,
+				TransportInfo:  p.TransportInfo,
+				NAT:            h.NAT,
+				MTU:            h.MTU,
+				SegmentRouting: h.SegmentRouting,
+			}
+			if probeCallback != nil {
+				probeCallback(pr)
+			}
+		}
+	}
 
 	for {
 		select {
@@ -56,31 +222,43 @@ func (ct *ContinuousTracer) Run(ctx context.Context, target net.IP, probeCallbac
 			return ctx.Err()
 		default:
 		}
+		if err := ct.waitResumed(ctx); err != nil {
+			return err
+		}
+
+		// Trace with the probe size and hop limit set since the last cycle
+		if cfg := ct.config.Load(); cfg != tracerCfg || hopLimit != tracerLimit {
+			if next, err := ct.newTracer(limitHops(cfg, hopLimit)); err == nil {
+				tracer = next
+			}
+			tracerCfg, tracerLimit = cfg, hopLimit
+		}
 
 		cycle++
 		cycleStart := time.Now()
 
-		// Run a single trace
-		result, err := ct.tracer.Trace(ctx, target, func(h *hop.Hop) {
-			// Convert hop probes to ProbeResults
-			for _, p := range h.Probes {
-				pr := ProbeResult{
-					TTL:           h.TTL,
-					IP:            p.IP,
-					RTT:           p.RTT,
-					Timeout:       p.Timeout,
-					MPLS:          h.MPLS,
-					ICMPType:      p.ICMPType,
-					ICMPCode:      p.ICMPCode,
-					OriginalTTL:   p.OriginalTTL,
-					FlowID:        p.FlowID,
-					TransportInfo: p.TransportInfo,
-				}
-				if probeCallback != nil {
-					probeCallback(pr)
+		// Run the traces of the cycle, paced over the interval
+		reached, reachedAt := false, 0
+		var err error
+		for sweep := 0; sweep < max(ct.sweeps, 1); sweep++ {
+			if sweep > 0 {
+				start := cycleStart.Add(ct.Interval() * time.Duration(sweep) / time.Duration(ct.sweeps))
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Until(start)):
 				}
 			}
-		})
+			var result *hop.TraceResult
+			result, err = tracer.Trace(ctx, target, onHop)
+			if err != nil {
+				break
+			}
+			if result != nil && result.ReachedTarget && len(result.Hops) > 0 {
+				reached = true
+				reachedAt = max(reachedAt, result.Hops[len(result.Hops)-1].TTL)
+			}
+		}
 
 		if err != nil {
 			if ctx.Err() != nil {
@@ -90,19 +268,39 @@ func (ct *ContinuousTracer) Run(ctx context.Context, target net.IP, probeCallbac
 			continue
 		}
 
-		// Notify cycle complete
-		reached := result != nil && result.ReachedTarget
-		if cycleCallback != nil {
+		// Notify cycle complete, unless the network changed underneath it
+		var event *netwatch.Event
+		if ct.watcher != nil {
+			event = ct.watcher.Check()
+		}
+		if event != nil {
+			if ct.onNetwork != nil {
+				ct.onNetwork(cycle, *event)
+			}
+		} else if cycleCallback != nil {
 			cycleCallback(cycle, reached)
 		}
 
+		if ct.adaptive {
+			switch {
+			case event != nil:
+				hopLimit, misses = 0, 0
+			case reached:
+				hopLimit, misses = reachedAt+adaptiveHopMargin, 0
+			default:
+				if misses++; misses >= adaptiveExpandMisses {
+					hopLimit, misses = 0, 0
+				}
+			}
+		}
+
 		// Wait for next cycle interval
 		elapsed := time.Since(cycleStart)
-		if elapsed < ct.interval {
+		if interval := ct.Interval(); elapsed < interval {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case <-time.After(ct.interval - elapsed):
+			case <-time.After(interval - elapsed):
 			}
 		}
 	}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/netwatch"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -28,8 +29,8 @@ func TestNewContinuousTracer(t *testing.T) {
 	if ct == nil {
 		t.Fatal("expected non-nil ContinuousTracer")
 	}
-	if ct.interval != time.Second {
-		t.Errorf("expected interval 1s, got %v", ct.interval)
+	if ct.Interval() != time.Second {
+		t.Errorf("expected interval 1s, got %v", ct.Interval())
 	}
 }
 
@@ -349,3 +350,222 @@ func TestContinuousTracer_Run_TimeoutProbe(t *testing.T) {
 		t.Error("expected nil IP for timeout probe")
 	}
 }
+
+func TestContinuousTracer_Run_NetworkChangeInvalidatesCycle(t *testing.T) {
+	cfg := DefaultConfig()
+
+	mockTracer := &mockContinuousTracer{
+		traceFn: func(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+			result := hop.NewTraceResult(target.String(), target.String())
+			result.ReachedTarget = true
+			return result, nil
+		},
+	}
+
+	// The route moves to eth0 during the second cycle
+	checks := 0
+	watcher := netwatch.NewWithStateFunc(net.ParseIP("8.8.8.8"), func(net.IP) netwatch.State {
+		checks++
+		if checks > 2 {
+			return netwatch.State{Interface: "eth0"}
+		}
+		return netwatch.State{Interface: "wlan0"}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var valid, invalid []int
+	var event netwatch.Event
+	ct := NewContinuousTracer(cfg, mockTracer, time.Millisecond)
+	ct.SetNetworkWatcher(watcher, func(cycle int, ev netwatch.Event) {
+		invalid = append(invalid, cycle)
+		event = ev
+	})
+	ct.Run(ctx, net.ParseIP("8.8.8.8"), nil, func(cycle int, reached bool) {
+		valid = append(valid, cycle)
+		if cycle == 3 {
+			cancel()
+		}
+	})
+
+	if len(invalid) != 1 || invalid[0] != 2 {
+		t.Fatalf("expected cycle 2 to be invalid, got %v", invalid)
+	}
+	if len(valid) != 2 || valid[0] != 1 || valid[1] != 3 {
+		t.Errorf("expected cycles 1 and 3 to complete, got %v", valid)
+	}
+	if event.String() != "network changed (wlan0 → eth0)" {
+		t.Errorf("unexpected event %q", event.String())
+	}
+}
+
+func TestContinuousTracer_SetPaused_StopsProbing(t *testing.T) {
+	cfg := DefaultConfig()
+
+	var traces int
+	var mu sync.Mutex
+	mockTracer := &mockContinuousTracer{
+		traceFn: func(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+			mu.Lock()
+			traces++
+			mu.Unlock()
+			return hop.NewTraceResult(target.String(), target.String()), nil
+		},
+	}
+
+	ct := NewContinuousTracer(cfg, mockTracer, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	cycles := make(chan int, 10)
+	go ct.Run(ctx, net.ParseIP("8.8.8.8"), nil, func(cycle int, _ bool) {
+		if cycle == 1 {
+			ct.SetPaused(true)
+		}
+		cycles <- cycle
+	})
+
+	<-cycles
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	paused := traces
+	mu.Unlock()
+	if paused != 1 || !ct.Paused() {
+		t.Fatalf("expected probing to stop after the first cycle, got %d traces", paused)
+	}
+
+	ct.SetPaused(false)
+	select {
+	case cycle := <-cycles:
+		if cycle != 2 {
+			t.Errorf("expected cycle 2 after resuming, got %d", cycle)
+		}
+	case <-ctx.Done():
+		t.Fatal("probing did not resume")
+	}
+}
+
+func TestContinuousTracer_SetIntervalAndProbeSize(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ProbeSize = 64
+	ct := NewContinuousTracer(cfg, &mockContinuousTracer{}, time.Second)
+
+	ct.SetInterval(250 * time.Millisecond)
+	ct.SetProbeSize(1400)
+
+	if ct.Interval() != 250*time.Millisecond {
+		t.Errorf("expected interval 250ms, got %v", ct.Interval())
+	}
+	if ct.ProbeSize() != 1400 {
+		t.Errorf("expected probe size 1400, got %d", ct.ProbeSize())
+	}
+	if cfg.ProbeSize != 64 {
+		t.Errorf("expected the caller's config left unchanged, got probe size %d", cfg.ProbeSize)
+	}
+}
+
+func TestContinuousTracer_SetPacketsPerHop_PacesTraces(t *testing.T) {
+	cfg := DefaultConfig()
+
+	var starts []time.Time
+	var mu sync.Mutex
+	mockTracer := &mockContinuousTracer{
+		traceFn: func(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+			h := hop.NewHop(1)
+			h.AddProbe(net.ParseIP("192.168.1.1"), time.Millisecond)
+			callback(h)
+			return hop.NewTraceResult(target.String(), target.String()), nil
+		},
+	}
+
+	ct := NewContinuousTracer(cfg, mockTracer, 300*time.Millisecond)
+	ct.SetPacketsPerHop(3)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var probes int
+	done := make(chan struct{})
+	go ct.Run(ctx, net.ParseIP("8.8.8.8"), func(ProbeResult) {
+		mu.Lock()
+		probes++
+		mu.Unlock()
+	}, func(cycle int, _ bool) {
+		if cycle == 1 {
+			close(done)
+		}
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("first cycle did not complete")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(starts) != 3 || probes != 3 {
+		t.Fatalf("expected 3 traces and 3 probes in the first cycle, got %d traces and %d probes", len(starts), probes)
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < 80*time.Millisecond {
+			t.Errorf("expected traces spread over the interval, trace %d started %v after the previous one", i+1, gap)
+		}
+	}
+}
+
+func TestContinuousTracer_AdaptiveMaxHops(t *testing.T) {
+	cfg := DefaultConfig()
+
+	// The target answers at hop 5 in the first cycle only
+	var mu sync.Mutex
+	traces := 0
+	var maxHops []int
+	trace := func(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces++
+		result := hop.NewTraceResult(target.String(), target.String())
+		h := hop.NewHop(5)
+		if traces == 1 {
+			h.AddProbe(target, time.Millisecond)
+			result.ReachedTarget = true
+		} else {
+			h.AddTimeout()
+		}
+		result.AddHop(h)
+		return result, nil
+	}
+
+	ct := NewContinuousTracer(cfg, &mockContinuousTracer{traceFn: trace}, time.Millisecond)
+	ct.newTracer = func(c *Config) (Tracer, error) {
+		mu.Lock()
+		maxHops = append(maxHops, c.MaxHops)
+		mu.Unlock()
+		return &mockContinuousTracer{traceFn: trace}, nil
+	}
+	ct.SetAdaptiveMaxHops(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go ct.Run(ctx, net.ParseIP("8.8.8.8"), nil, func(cycle int, _ bool) {
+		if cycle == 5 {
+			close(done)
+		}
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("cycles did not complete")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// Cycle 2 probes up to hop 7; after misses in cycles 2-4, cycle 5 probes up to MaxHops again
+	if len(maxHops) != 2 || maxHops[0] != 5+adaptiveHopMargin || maxHops[1] != cfg.MaxHops {
+		t.Errorf("expected tracers limited to %d then %d hops, got %v", 5+adaptiveHopMargin, cfg.MaxHops, maxHops)
+	}
+}
@@ -29,7 +29,7 @@ func ExtractTransportInfo(data []byte, ipHdrSize int, protocol string) *hop.Tran
 	switch protocol {
 	case "tcp":
 		extractTCP(info, transport)
-	case "udp":
+	case "udp", "quic":
 		extractUDP(info, transport)
 	}
 
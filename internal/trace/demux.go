@@ -0,0 +1,160 @@
+package trace
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// demuxPoll bounds each read of the receive loop, so it notices when the
+// demultiplexer is closed.
+const demuxPoll = 50 * time.Millisecond
+
+// errDemuxClosed is returned to probes waiting on a closed demultiplexer.
+var errDemuxClosed = errors.New("receive loop stopped")
+
+// replyReader reads the next reply to a probe of a trace before deadline. It
+// returns the reply without its RTT, the key of the probe it answers and when
+// it arrived.
+type replyReader func(deadline time.Time) (*probeResult, int, time.Time, error)
+
+// demuxReply is a reply delivered by a demux.
+type demuxReply struct {
+	key int
+	pr  *probeResult
+	at  time.Time
+}
+
+// demux is the single receive loop of a trace. It reads the ICMP socket in
+// one goroutine and hands each reply to the probe waiting for its key (ICMP
+// echo sequence number, UDP destination port or TCP source port), so
+// overlapping probes never consume each other's replies. Replies nobody waits
+// for, such as late replies to timed out probes, are dropped.
+type demux struct {
+	conn *icmp.PacketConn // Woken up on close (nil in tests)
+	read replyReader
+
+	mu      sync.Mutex
+	pending map[int]chan<- demuxReply
+	err     error // Read error that stopped the loop
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newDemux starts a receive loop reading replies from conn with read.
+func newDemux(conn *icmp.PacketConn, read replyReader) *demux {
+	d := &demux{
+		conn:    conn,
+		read:    read,
+		pending: make(map[int]chan<- demuxReply),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+func (d *demux) run() {
+	defer close(d.done)
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+
+		pr, key, at, err := d.read(time.Now().Add(demuxPoll))
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			select {
+			case <-d.stop:
+			default:
+				d.mu.Lock()
+				d.err = err
+				d.mu.Unlock()
+			}
+			return
+		}
+
+		d.mu.Lock()
+		ch, ok := d.pending[key]
+		delete(d.pending, key)
+		d.mu.Unlock()
+		if ok {
+			ch <- demuxReply{key: key, pr: pr, at: at}
+		}
+	}
+}
+
+// register delivers the reply to the probe with key to ch, which must have
+// room for it. A later registration of the same key replaces it.
+func (d *demux) register(key int, ch chan<- demuxReply) {
+	d.mu.Lock()
+	d.pending[key] = ch
+	d.mu.Unlock()
+}
+
+// unregister stops waiting for the reply to the probe with key.
+func (d *demux) unregister(key int) {
+	d.mu.Lock()
+	delete(d.pending, key)
+	d.mu.Unlock()
+}
+
+// expect registers a probe about to be sent and returns the channel its reply
+// will be delivered to. The caller unregisters the probe once done with it.
+func (d *demux) expect(key int) <-chan demuxReply {
+	ch := make(chan demuxReply, 1)
+	d.register(key, ch)
+	return ch
+}
+
+// wait returns the reply delivered to ch before deadline.
+func (d *demux) wait(ch <-chan demuxReply, deadline time.Time) (*probeResult, time.Time, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.pr, r.at, nil
+	case <-d.done:
+		return nil, time.Time{}, d.failure()
+	case <-timer.C:
+		return nil, time.Time{}, &timeoutError{}
+	}
+}
+
+// failure returns why the receive loop stopped.
+func (d *demux) failure() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	return errDemuxClosed
+}
+
+// close stops the receive loop and waits for it to exit.
+func (d *demux) close() {
+	close(d.stop)
+	if d.conn != nil {
+		// Interrupt the pending read instead of waiting for its deadline
+		_ = d.conn.SetReadDeadline(time.Now())
+	}
+	<-d.done
+}
+
+// quotedDstIs reports whether the IP header quoted in an ICMP error is
+// addressed to target, so replies to probes of concurrent traces to other
+// targets are told apart.
+func quotedDstIs(data []byte, target net.IP) bool {
+	if IsIPv6(target) {
+		return len(data) >= 40 && net.IP(data[24:40]).Equal(target)
+	}
+	return len(data) >= 20 && net.IP(data[16:20]).Equal(target)
+}
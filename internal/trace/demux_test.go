@@ -0,0 +1,104 @@
+package trace
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeReplies returns a replyReader serving the replies sent on ch.
+func fakeReplies(ch <-chan demuxReply) replyReader {
+	return func(deadline time.Time) (*probeResult, int, time.Time, error) {
+		select {
+		case r := <-ch:
+			return r.pr, r.key, r.at, nil
+		case <-time.After(time.Until(deadline)):
+			return nil, 0, time.Time{}, &timeoutError{}
+		}
+	}
+}
+
+func TestDemux_DeliversRepliesToTheirProbes(t *testing.T) {
+	in := make(chan demuxReply, 4)
+	d := newDemux(nil, fakeReplies(in))
+	defer d.close()
+
+	first := d.expect(1)
+	second := d.expect(2)
+
+	// Replies arrive in the opposite order of the probes
+	in <- demuxReply{key: 2, pr: &probeResult{IP: net.ParseIP("10.0.0.2")}, at: time.Now()}
+	in <- demuxReply{key: 1, pr: &probeResult{IP: net.ParseIP("10.0.0.1")}, at: time.Now()}
+
+	for key, ch := range map[int]<-chan demuxReply{1: first, 2: second} {
+		pr, _, err := d.wait(ch, time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatalf("probe %d: unexpected error: %v", key, err)
+		}
+		if want := net.IPv4(10, 0, 0, byte(key)); !pr.IP.Equal(want) {
+			t.Errorf("probe %d got reply from %s, want %s", key, pr.IP, want)
+		}
+	}
+}
+
+func TestDemux_DropsUnexpectedReplies(t *testing.T) {
+	in := make(chan demuxReply, 4)
+	d := newDemux(nil, fakeReplies(in))
+	defer d.close()
+
+	ch := d.expect(1)
+	d.unregister(1)
+	in <- demuxReply{key: 1, pr: &probeResult{}, at: time.Now()}
+	in <- demuxReply{key: 7, pr: &probeResult{}, at: time.Now()}
+
+	if _, _, err := d.wait(ch, time.Now().Add(100*time.Millisecond)); !isTimeout(err) {
+		t.Errorf("err = %v, want timeout", err)
+	}
+}
+
+func TestDemux_WaitTimesOut(t *testing.T) {
+	d := newDemux(nil, fakeReplies(nil))
+	defer d.close()
+
+	start := time.Now()
+	_, _, err := d.wait(d.expect(1), start.Add(20*time.Millisecond))
+	if !isTimeout(err) {
+		t.Errorf("err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait took %v", elapsed)
+	}
+}
+
+func TestDemux_ReadErrorStopsWaiters(t *testing.T) {
+	readErr := errors.New("socket closed")
+	d := newDemux(nil, func(time.Time) (*probeResult, int, time.Time, error) {
+		return nil, 0, time.Time{}, readErr
+	})
+	defer d.close()
+
+	if _, _, err := d.wait(d.expect(1), time.Now().Add(time.Second)); !errors.Is(err, readErr) {
+		t.Errorf("err = %v, want %v", err, readErr)
+	}
+}
+
+func TestQuotedDstIs(t *testing.T) {
+	v4 := make([]byte, 28)
+	copy(v4[16:20], net.ParseIP("192.0.2.1").To4())
+	if !quotedDstIs(v4, net.ParseIP("192.0.2.1")) {
+		t.Error("expected IPv4 destination to match")
+	}
+	if quotedDstIs(v4, net.ParseIP("192.0.2.2")) {
+		t.Error("expected other IPv4 destination not to match")
+	}
+
+	v6 := make([]byte, 48)
+	copy(v6[24:40], net.ParseIP("2001:db8::1"))
+	if !quotedDstIs(v6, net.ParseIP("2001:db8::1")) {
+		t.Error("expected IPv6 destination to match")
+	}
+	if quotedDstIs(v6[:30], net.ParseIP("2001:db8::1")) {
+		t.Error("expected truncated header not to match")
+	}
+}
@@ -0,0 +1,60 @@
+package trace
+
+import (
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// FilterDiagnosis compares a TCP or UDP trace that never reached its target
+// with an ICMP trace of the same path. Routers answer expired probes of
+// every protocol alike, so the hop after which only the TCP or UDP probes
+// go unanswered is where a firewall or ACL filters them.
+type FilterDiagnosis struct {
+	LastHop      *hop.Hop // Last hop that answered the TCP or UDP probes (nil if none did)
+	ICMPLastHop  *hop.Hop // Last hop that answered the ICMP probes (nil if none did)
+	ICMPReached  bool     // Whether the ICMP probes reached the target
+	ICMPFurther  bool     // Whether the ICMP probes got answered past LastHop
+	NextICMPHop  *hop.Hop // First hop past LastHop that answered ICMP (nil if none did)
+	ProbeReached bool     // Whether the TCP or UDP probes reached the target
+}
+
+// Filtered reports whether the TCP or UDP probes are filtered: ICMP probes
+// got answered past the last hop that answered them.
+func (d *FilterDiagnosis) Filtered() bool {
+	return !d.ProbeReached && d.ICMPFurther
+}
+
+// DiagnoseFilter compares probe, a trace with TCP or UDP probes, with icmp,
+// an ICMP trace to the same target. Paths can differ per protocol under
+// ECMP, so the verdict locates the filter to within a hop or two.
+func DiagnoseFilter(probe, icmp *hop.TraceResult) *FilterDiagnosis {
+	d := &FilterDiagnosis{
+		LastHop:      lastAnsweringHop(probe),
+		ICMPLastHop:  lastAnsweringHop(icmp),
+		ICMPReached:  icmp.ReachedTarget,
+		ProbeReached: probe.ReachedTarget,
+	}
+	last := 0
+	if d.LastHop != nil {
+		last = d.LastHop.TTL
+	}
+	for _, h := range icmp.Hops {
+		if h.TTL > last && h.PrimaryIP() != nil {
+			d.NextICMPHop = h
+			break
+		}
+	}
+	d.ICMPFurther = d.NextICMPHop != nil
+	return d
+}
+
+// lastAnsweringHop returns the hop with the highest TTL that answered at
+// least one probe, or nil when none did.
+func lastAnsweringHop(tr *hop.TraceResult) *hop.Hop {
+	var last *hop.Hop
+	for _, h := range tr.Hops {
+		if h.PrimaryIP() != nil && (last == nil || h.TTL > last.TTL) {
+			last = h
+		}
+	}
+	return last
+}
@@ -0,0 +1,45 @@
+package trace
+
+import (
+	"testing"
+)
+
+func TestDiagnoseFilter_FilteredMidPath(t *testing.T) {
+	probe := quicTestTrace(3, 8)
+	icmp := quicTestTrace(6, 6)
+	icmp.ReachedTarget = true
+
+	d := DiagnoseFilter(probe, icmp)
+	if !d.Filtered() {
+		t.Fatal("expected the probes to be filtered")
+	}
+	if d.LastHop == nil || d.LastHop.TTL != 3 {
+		t.Errorf("expected the last answering hop to be 3, got %+v", d.LastHop)
+	}
+	if d.NextICMPHop == nil || d.NextICMPHop.TTL != 4 {
+		t.Errorf("expected icmp answered again at hop 4, got %+v", d.NextICMPHop)
+	}
+	if d.ICMPLastHop.TTL != 6 || !d.ICMPReached {
+		t.Errorf("expected icmp to reach hop 6 and the target, got hop %d, reached %v", d.ICMPLastHop.TTL, d.ICMPReached)
+	}
+}
+
+func TestDiagnoseFilter_PathBrokenForICMPToo(t *testing.T) {
+	d := DiagnoseFilter(quicTestTrace(4, 8), quicTestTrace(4, 8))
+	if d.Filtered() {
+		t.Error("expected no filtering when icmp stops at the same hop")
+	}
+	if d.ICMPLastHop == nil || d.ICMPLastHop.TTL != 4 {
+		t.Errorf("expected icmp to stop at hop 4, got %+v", d.ICMPLastHop)
+	}
+}
+
+func TestDiagnoseFilter_NoProbeAnswered(t *testing.T) {
+	d := DiagnoseFilter(quicTestTrace(0, 5), quicTestTrace(2, 5))
+	if !d.Filtered() || d.LastHop != nil {
+		t.Fatalf("expected filtering before the first hop, got %+v", d)
+	}
+	if d.NextICMPHop.TTL != 1 {
+		t.Errorf("expected icmp answered from hop 1, got %d", d.NextICMPHop.TTL)
+	}
+}
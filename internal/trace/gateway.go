@@ -0,0 +1,78 @@
+package trace
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// localSendError returns why this host could not send a probe when err says
+// so, or "" for timeouts and other errors. Only the ICMP tracer reports
+// these: on its raw sockets such errors come from the local stack, while TCP
+// and UDP sockets also surface unreachables sent by routers through them.
+func localSendError(err error) string {
+	switch {
+	case errors.Is(err, syscall.ENETDOWN):
+		return "network interface down"
+	case errors.Is(err, syscall.ENETUNREACH):
+		return "no route to the target (no default gateway?)"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.EHOSTDOWN):
+		return "gateway not answering ARP/neighbor discovery"
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return "source address not available"
+	}
+	return ""
+}
+
+// GatewayDiagnosis tells whether a trace that went wrong went wrong on this
+// host or its LAN, up to the first hop (the default gateway), rather than
+// upstream.
+type GatewayDiagnosis struct {
+	LocalError    string   // Why this host could not send probes (empty if it sent them all)
+	Gateway       *hop.Hop // First hop (nil when the trace has none)
+	GatewaySilent bool     // Nothing answered, not even the gateway
+	GatewayLoss   float64  // Loss at the gateway when the target loses as much (0 otherwise)
+	TargetLoss    float64  // Loss at the target, 100% when not reached
+}
+
+// Local reports whether the trace points at this host or its LAN.
+func (d *GatewayDiagnosis) Local() bool {
+	return d.LocalError != "" || d.GatewaySilent || d.GatewayLoss > 0
+}
+
+// DiagnoseGateway looks for first-hop problems in tr: probes this host could
+// not send, a gateway that does not answer while nothing else does either,
+// or loss that starts at the gateway and carries through to the target.
+// A first hop that is silent while later hops answer only ignores probes.
+func DiagnoseGateway(tr *hop.TraceResult) *GatewayDiagnosis {
+	d := &GatewayDiagnosis{TargetLoss: 100}
+	if len(tr.Hops) == 0 {
+		return d
+	}
+	d.Gateway = tr.Hops[0]
+	if tr.ReachedTarget {
+		d.TargetLoss = tr.Hops[len(tr.Hops)-1].LossPercent()
+	}
+
+	for _, h := range tr.Hops {
+		for _, p := range h.Probes {
+			if p.LocalError != "" {
+				d.LocalError = p.LocalError
+				return d
+			}
+		}
+	}
+
+	if !tr.ReachedTarget && lastAnsweringHop(tr) == nil {
+		d.GatewaySilent = true
+		return d
+	}
+
+	if d.Gateway.TTL == 1 && d.Gateway.PrimaryIP() != nil {
+		if loss := d.Gateway.LossPercent(); loss > 0 && d.TargetLoss >= loss {
+			d.GatewayLoss = loss
+		}
+	}
+	return d
+}
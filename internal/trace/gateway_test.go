@@ -0,0 +1,87 @@
+package trace
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// gatewayTestTrace returns a trace whose hops lost the given number of
+// their 4 probes; 4 is a silent hop. The last hop is the target when reached.
+func gatewayTestTrace(reached bool, lost ...int) *hop.TraceResult {
+	tr := hop.NewTraceResult("example.com", "192.0.2.1")
+	for i, n := range lost {
+		h := hop.NewHop(i + 1)
+		for p := 0; p < 4; p++ {
+			if p < n {
+				h.AddTimeout()
+			} else {
+				h.AddProbe(net.ParseIP(fmt.Sprintf("10.0.%d.1", i)), time.Millisecond)
+			}
+		}
+		tr.AddHop(h)
+	}
+	tr.ReachedTarget = reached
+	return tr
+}
+
+func TestLocalSendError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("failed to send ICMP: %w", &net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.ENETUNREACH)}), "no route to the target (no default gateway?)"},
+		{fmt.Errorf("failed to send ICMP: %w", syscall.EHOSTUNREACH), "gateway not answering ARP/neighbor discovery"},
+		{syscall.ENETDOWN, "network interface down"},
+		{os.ErrDeadlineExceeded, ""},
+	}
+	for _, tt := range tests {
+		if got := localSendError(tt.err); got != tt.want {
+			t.Errorf("localSendError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnoseGateway_LocalError(t *testing.T) {
+	tr := gatewayTestTrace(false)
+	h := hop.NewHop(1)
+	h.AddLocalError("network interface down")
+	tr.AddHop(h)
+
+	d := DiagnoseGateway(tr)
+	if !d.Local() || d.LocalError != "network interface down" {
+		t.Errorf("expected the local error reported, got %+v", d)
+	}
+}
+
+func TestDiagnoseGateway_NothingAnswered(t *testing.T) {
+	d := DiagnoseGateway(gatewayTestTrace(false, 4, 4, 4))
+	if !d.GatewaySilent || !d.Local() {
+		t.Errorf("expected a silent gateway, got %+v", d)
+	}
+}
+
+func TestDiagnoseGateway_SilentFirstHopIsNotAFault(t *testing.T) {
+	d := DiagnoseGateway(gatewayTestTrace(true, 4, 0, 0))
+	if d.Local() {
+		t.Errorf("expected a first hop ignoring probes not to be reported, got %+v", d)
+	}
+}
+
+func TestDiagnoseGateway_LossFromTheGateway(t *testing.T) {
+	d := DiagnoseGateway(gatewayTestTrace(true, 1, 1, 2))
+	if d.GatewayLoss != 25 || d.TargetLoss != 50 {
+		t.Errorf("expected 25%% loss at the gateway and 50%% at the target, got %+v", d)
+	}
+
+	// Loss the target does not share is the gateway rate-limiting its replies
+	d = DiagnoseGateway(gatewayTestTrace(true, 2, 0, 0))
+	if d.Local() {
+		t.Errorf("expected loss only at the gateway not to be reported, got %+v", d)
+	}
+}
@@ -2,7 +2,6 @@ package trace
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -18,6 +17,8 @@ import (
 type ICMPTracer struct {
 	config *Config
 	id     int
+	srcIP  net.IP           // Local source address, resolved only when capturing
+	stamps timestampTracker // How the probes of the current trace were timed
 }
 
 // NewICMPTracer creates a new ICMP tracer with the given configuration.
@@ -31,19 +32,60 @@ func NewICMPTracer(cfg *Config) *ICMPTracer {
 // Trace performs an ICMP traceroute to the target IP.
 // Supports both IPv4 and IPv6 targets.
 func (t *ICMPTracer) Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+	if err := t.config.IPv6.check(target); err != nil {
+		return nil, err
+	}
+
 	result := hop.NewTraceResult(target.String(), target.String())
 	result.Protocol = string(ProtocolICMP)
 	result.StartTime = time.Now()
+	t.stamps.reset(true)
+	log, callback := startTrace(ctx, t.config, target, callback)
 
 	// Open ICMP connection based on IP version
-	proto := ICMPProtocol(target)
-	listenAddr := ListenAddress(target)
-	conn, err := icmp.ListenPacket(proto, listenAddr)
+	conn, err := listenICMP(t.config.NetNS, target)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open ICMP socket: %w (try running with sudo)", err)
+		return nil, err
 	}
 	defer conn.Close()
 
+	if t.config.IPv6 != nil {
+		if err := setListenerIPv6Options(conn, target, t.config.IPv6); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without fragmentation, oversized Echo Requests keep drawing Packet Too Big
+	if t.config.DiscoverMTU && IsIPv6(target) {
+		if raw := rawIPConn(conn, target); raw != nil {
+			if sc, err := raw.SyscallConn(); err == nil {
+				_ = sc.Control(func(fd uintptr) { _ = setDontFragmentIPv6(socketFD(fd)) })
+			}
+		}
+	}
+
+	if t.config.Capture != nil {
+		t.srcIP = captureSourceIP(t.config.NetNS, target)
+	}
+
+	// A single receive loop hands each reply to the probe it answers
+	reply := make([]byte, 1500)
+	d := newDemux(conn, func(deadline time.Time) (*probeResult, int, time.Time, error) {
+		return t.readReply(conn, target, reply, deadline)
+	})
+	defer d.close()
+
+	if t.config.Parallel {
+		p := &icmpParallelProber{t: t, conn: conn, target: target, probeCount: probesPerTTL(t.config)}
+		if uniqueKeys(p, t.config.MaxHops, p.probeCount) {
+			err := traceParallel(ctx, t.config, target, p, d, callback, result)
+			result.EndTime = time.Now()
+			result.TimestampSource = t.stamps.source()
+			finishTrace(log, result, err)
+			return result, err
+		}
+	}
+
 	for ttl := 1; ttl <= t.config.MaxHops; ttl++ {
 		select {
 		case <-ctx.Done():
@@ -51,26 +93,29 @@ func (t *ICMPTracer) Trace(ctx context.Context, target net.IP, callback HopCallb
 		default:
 		}
 
+		if err := nextTTL(ctx, t.config.Scheduler, ttl); err != nil {
+			return result, err
+		}
+
 		h := hop.NewHop(ttl)
 		reached := false
 
 		// When ECMP flows are enabled, use them as probe count with flow IDs
-		probeCount := t.config.PacketsPerHop
-		if t.config.ECMPFlows > 0 {
-			probeCount = t.config.ECMPFlows
-		}
+		probeCount := probesPerTTL(t.config)
 
 		for i := 0; i < probeCount; i++ {
 			flowID := 0
 			if t.config.ECMPFlows > 0 {
 				flowID = i + 1
 			}
-			pr, err := t.sendProbe(conn, target, ttl, i, flowID)
+			pr, err := scheduleProbe(ctx, t.config.Scheduler, func() (*probeResult, error) {
+				return t.sendProbe(conn, d, target, ttl, probeSeq(ttl, i, probeCount), flowID)
+			})
 			if err != nil {
-				if errors.Is(err, context.DeadlineExceeded) || isTimeout(err) {
-					h.AddTimeout()
+				if reason := localSendError(err); reason != "" {
+					h.AddLocalError(reason)
 				} else {
-					// Other errors - still record as timeout for display
+					// Timeouts and other errors
 					h.AddTimeout()
 				}
 				continue
@@ -93,6 +138,9 @@ func (t *ICMPTracer) Trace(ctx context.Context, target net.IP, callback HopCallb
 			if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
 				h.InterfaceInfo = pr.InterfaceInfo
 			}
+			if pr.SegmentRouting != nil && h.SegmentRouting == nil {
+				h.SegmentRouting = pr.SegmentRouting
+			}
 
 			if pr.IP.Equal(target) {
 				reached = true
@@ -131,22 +179,27 @@ func (t *ICMPTracer) Trace(ctx context.Context, target net.IP, callback HopCallb
 	}
 
 	result.EndTime = time.Now()
+	result.TimestampSource = t.stamps.source()
+	finishTrace(log, result, nil)
 	return result, nil
 }
 
 // probeResult holds the result of a single probe including MPLS labels.
 type probeResult struct {
-	IP            net.IP
-	RTT           time.Duration
-	MPLS          []hop.MPLSLabel
-	ResponseTTL   int                // TTL from response packet (for NAT detection)
-	MTU           int                // Discovered MTU from Fragmentation Needed
-	IPID          uint16             // IP ID from original datagram in ICMP error
-	ICMPType      int                // ICMP response message type
-	ICMPCode      int                // ICMP response message code
-	OriginalTTL   int                // TTL from original datagram in ICMP error (-1 = not set)
-	InterfaceInfo *hop.InterfaceInfo // RFC 5837 interface info (nil if not available)
-	TransportInfo *hop.TransportInfo // Decoded transport header info (nil if --decode not used)
+	IP             net.IP
+	RTT            time.Duration
+	MPLS           []hop.MPLSLabel
+	ResponseTTL    int                 // TTL from response packet (for NAT detection)
+	MTU            int                 // Discovered MTU from Fragmentation Needed
+	IPID           uint16              // IP ID from original datagram in ICMP error
+	ICMPType       int                 // ICMP response message type
+	ICMPCode       int                 // ICMP response message code
+	OriginalTTL    int                 // TTL from original datagram in ICMP error (-1 = not set)
+	InterfaceInfo  *hop.InterfaceInfo  // RFC 5837 interface info (nil if not available)
+	SegmentRouting *hop.SegmentRouting // Segment routing seen in the reply (nil if none)
+	TransportInfo  *hop.TransportInfo  // Decoded transport header info (nil if --decode not used)
+	Handshake      *hop.Handshake      // TCP handshake timing (nil unless connected to the target)
+	Fingerprint    *hop.TCPFingerprint // Target's SYN-ACK (nil unless connected and captured)
 }
 
 // ExtractIPID extracts the IP Identification field from an original IP header
@@ -158,19 +211,39 @@ func ExtractIPID(data []byte) uint16 {
 	return uint16(data[4])<<8 | uint16(data[5])
 }
 
-// sendProbe sends a single ICMP probe and waits for response.
-// Supports both IPv4 and IPv6 targets. flowID > 0 varies the payload for ECMP diversity.
-func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, flowID int) (*probeResult, error) {
+// sendProbe sends a single ICMP probe and waits for the reply d hands to its
+// sequence number. Supports both IPv4 and IPv6 targets. flowID > 0 varies the
+// payload for ECMP diversity.
+func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, d *demux, target net.IP, ttl, seq, flowID int) (*probeResult, error) {
+	ch := d.expect(seq)
+	defer d.unregister(seq)
+
+	start, err := t.sendEcho(conn, target, ttl, seq, flowID)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, end, err := d.wait(ch, start.Add(t.config.Timeout))
+	if err != nil {
+		return nil, err
+	}
+	pr.RTT = t.calculateRTT(start, end)
+	return pr, nil
+}
+
+// sendEcho sends an ICMP Echo Request with the given TTL and sequence number
+// and returns when it was sent.
+func (t *ICMPTracer) sendEcho(conn *icmp.PacketConn, target net.IP, ttl, seq, flowID int) (time.Time, error) {
 	isV6 := IsIPv6(target)
 
 	// Set TTL/Hop Limit for this probe
 	if isV6 {
 		if err := conn.IPv6PacketConn().SetHopLimit(ttl); err != nil {
-			return nil, fmt.Errorf("failed to set hop limit: %w", err)
+			return time.Time{}, fmt.Errorf("failed to set hop limit: %w", err)
 		}
 	} else {
 		if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
-			return nil, fmt.Errorf("failed to set TTL: %w", err)
+			return time.Time{}, fmt.Errorf("failed to set TTL: %w", err)
 		}
 	}
 
@@ -178,20 +251,36 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 	msg := t.buildEchoRequestForIP(ttl, seq, target, flowID)
 	msgBytes, err := msg.Marshal(nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal ICMP message: %w", err)
+		return time.Time{}, fmt.Errorf("failed to marshal ICMP message: %w", err)
 	}
 
 	start := time.Now()
 
-	_, err = conn.WriteTo(msgBytes, &net.IPAddr{IP: target})
+	if t.config.IPv6.leased() {
+		err = writeEchoFlow(conn, msgBytes, target, t.config.IPv6)
+	} else {
+		_, err = conn.WriteTo(msgBytes, &net.IPAddr{IP: target})
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to send ICMP: %w", err)
+		return time.Time{}, fmt.Errorf("failed to send ICMP: %w", err)
 	}
 
-	// Set read deadline
-	deadline := start.Add(t.config.Timeout)
+	if t.config.Capture != nil {
+		sent := msgBytes
+		if isV6 {
+			sent = icmpv6WithChecksum(t.srcIP, target, msgBytes)
+		}
+		capturePacket(t.config.Capture, start, t.srcIP, target, ICMPProtocolNum(target), ttl, sent)
+	}
+	return start, nil
+}
+
+// readReply reads ICMP messages until one answers a probe of this tracer to
+// target or deadline passes. It returns the reply without its RTT, the
+// sequence number of the probe it answers and when it arrived.
+func (t *ICMPTracer) readReply(conn *icmp.PacketConn, target net.IP, reply []byte, deadline time.Time) (*probeResult, int, time.Time, error) {
 	if err := conn.SetReadDeadline(deadline); err != nil {
-		return nil, fmt.Errorf("failed to set deadline: %w", err)
+		return nil, 0, time.Time{}, fmt.Errorf("failed to set deadline: %w", err)
 	}
 
 	// Protocol number for parsing ICMP messages
@@ -199,33 +288,20 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 	// IP header size for extracting original packet info
 	ipHdrSize := IPHeaderSize(target)
 
-	// Enable TTL control messages for NAT detection (IPv4 only)
-	if !isV6 && t.config.DetectNAT {
-		_ = conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
-	}
-
 	// Wait for response
-	reply := make([]byte, 1500)
 	for {
-		var n int
-		var peer net.Addr
-		var responseTTL int
-
-		if !isV6 && t.config.DetectNAT {
-			var cm *ipv4.ControlMessage
-			n, cm, peer, err = conn.IPv4PacketConn().ReadFrom(reply)
-			if cm != nil {
-				responseTTL = cm.TTL
-			}
-		} else {
-			n, peer, err = conn.ReadFrom(reply)
-		}
+		m, err := readICMP(conn, target, reply)
 		if err != nil {
-			return nil, err
+			return nil, 0, time.Time{}, err
 		}
+		t.stamps.received(m)
+		n, peerIP, end := m.n, m.peer, m.at
 
-		end := time.Now()
-		rtt := t.calculateRTT(start, end)
+		// Response TTL for NAT detection (IPv4 only)
+		var responseTTL int
+		if t.config.DetectNAT {
+			responseTTL = m.ttl
+		}
 
 		// Parse the response
 		rm, err := icmp.ParseMessage(protoNum, reply[:n])
@@ -233,13 +309,15 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 			continue // Ignore malformed packets
 		}
 
-		peerIP := peer.(*net.IPAddr).IP
+		// Probes steered with SRv6 are quoted behind a Segment Routing Header
+		sr := unwrapSRv6(rm, target)
 
 		// Check for Echo Reply (target reached)
 		if isEchoReply(rm.Type, target) {
 			if body, ok := rm.Body.(*icmp.Echo); ok {
-				if body.ID == t.id {
-					return &probeResult{IP: peerIP, RTT: rtt, ResponseTTL: responseTTL}, nil
+				if body.ID == t.id && peerIP.Equal(target) {
+					captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+					return &probeResult{IP: peerIP, ResponseTTL: responseTTL}, body.Seq, end, nil
 				}
 			}
 		}
@@ -254,7 +332,7 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 				if len(body.Data) >= minLen {
 					// Original ICMP ID is at offset ipHdrSize+4 and ipHdrSize+5
 					origID := int(body.Data[ipHdrSize+4])<<8 | int(body.Data[ipHdrSize+5])
-					if origID == t.id {
+					if origID == t.id && quotedDstIs(body.Data, target) {
 						// Extract ICMP extensions (MPLS + Interface Info)
 						var mplsLabels []hop.MPLSLabel
 						var ifInfo *hop.InterfaceInfo
@@ -262,6 +340,9 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 							if ext := ExtractICMPExtensionsFromData(reply[8:n]); ext != nil {
 								mplsLabels = ext.MPLS
 								ifInfo = ext.InterfaceInfo
+								if sr == nil {
+									sr = ext.SegmentRouting
+								}
 							}
 						}
 						ipid := ExtractIPID(body.Data)
@@ -270,7 +351,8 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 						if t.config.Decode {
 							transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 						}
-						return &probeResult{IP: peerIP, RTT: rtt, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, TransportInfo: transportInfo}, nil
+						captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+						return &probeResult{IP: peerIP, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, SegmentRouting: sr, TransportInfo: transportInfo}, quotedEchoSeq(body.Data, ipHdrSize), end, nil
 					}
 				}
 			}
@@ -282,7 +364,7 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 				minLen := ipHdrSize + 8
 				if len(body.Data) >= minLen {
 					origID := int(body.Data[ipHdrSize+4])<<8 | int(body.Data[ipHdrSize+5])
-					if origID == t.id {
+					if origID == t.id && quotedDstIs(body.Data, target) {
 						// Check for Fragmentation Needed (Code 4) with MTU discovery
 						var mtu int
 						if rm.Code == 4 && t.config.DiscoverMTU && n >= 8 {
@@ -298,19 +380,48 @@ func (t *ICMPTracer) sendProbe(conn *icmp.PacketConn, target net.IP, ttl, seq, f
 						if t.config.Decode {
 							transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 						}
-						return &probeResult{IP: peerIP, RTT: rtt, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, TransportInfo: transportInfo}, nil
+						captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+						return &probeResult{IP: peerIP, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, SegmentRouting: sr, TransportInfo: transportInfo}, quotedEchoSeq(body.Data, ipHdrSize), end, nil
 					}
 				}
 			}
 		}
 
+		// Check for Packet Too Big (IPv6 routers never fragment)
+		if body, ok := rm.Body.(*icmp.PacketTooBig); ok && IsIPv6(target) {
+			if t.quotesOurEcho(body.Data, ipHdrSize, target) {
+				pr := packetTooBigResult(body, peerIP, t.config.DiscoverMTU)
+				pr.SegmentRouting = sr
+				if t.config.Decode {
+					pr.TransportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
+				}
+				captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+				return pr, quotedEchoSeq(body.Data, ipHdrSize), end, nil
+			}
+		}
+
 		// Check if we've exceeded deadline
 		if time.Now().After(deadline) {
-			return nil, context.DeadlineExceeded
+			return nil, 0, time.Time{}, &net.OpError{Op: "read", Err: &timeoutError{}}
 		}
 	}
 }
 
+// quotesOurEcho reports whether the data of an ICMP error quotes an Echo
+// Request of this tracer to target, after its ipHdrSize-byte IP header.
+func (t *ICMPTracer) quotesOurEcho(data []byte, ipHdrSize int, target net.IP) bool {
+	if len(data) < ipHdrSize+8 {
+		return false
+	}
+	return int(data[ipHdrSize+4])<<8|int(data[ipHdrSize+5]) == t.id && quotedDstIs(data, target)
+}
+
+// quotedEchoSeq returns the sequence number of the Echo Request quoted in an
+// ICMP error, after its ipHdrSize-byte IP header.
+func quotedEchoSeq(data []byte, ipHdrSize int) int {
+	return int(data[ipHdrSize+6])<<8 | int(data[ipHdrSize+7])
+}
+
 // buildEchoRequest creates an ICMP Echo Request message (IPv4 only, for backward compatibility).
 func (t *ICMPTracer) buildEchoRequest(ttl, seq int) *icmp.Message {
 	return &icmp.Message{
@@ -14,8 +14,9 @@ const (
 
 // ICMPExtensionResult holds parsed ICMP extension objects.
 type ICMPExtensionResult struct {
-	MPLS          []hop.MPLSLabel
-	InterfaceInfo *hop.InterfaceInfo
+	MPLS           []hop.MPLSLabel
+	InterfaceInfo  *hop.InterfaceInfo
+	SegmentRouting *hop.SegmentRouting // From MPLS labels in segment routing ranges
 }
 
 // ParseICMPExtensions parses ICMP extension data (RFC 4884) and returns
@@ -47,7 +48,7 @@ func ParseICMPExtensions(data []byte) *ICMPExtensionResult {
 
 		switch classNum {
 		case classNumMPLS:
-			result.MPLS = parseMPLSObject(data[pos:pos+dataLen])
+			result.MPLS = parseMPLSObject(data[pos : pos+dataLen])
 		case classNumInterfaceInfo:
 			result.InterfaceInfo = parseInterfaceInfoObject(data[pos:pos+dataLen], cType)
 		}
@@ -59,6 +60,7 @@ func ParseICMPExtensions(data []byte) *ICMPExtensionResult {
 	if len(result.MPLS) == 0 && result.InterfaceInfo == nil {
 		return nil
 	}
+	result.SegmentRouting = SegmentRoutingFromMPLS(result.MPLS)
 
 	return result
 }
@@ -0,0 +1,163 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+)
+
+// IPv6 options extension headers accepted by ParseIPv6ExtHeaders.
+const (
+	ExtHopByHop    = "hbh" // Hop-by-Hop Options, examined by every router on the path
+	ExtDestination = "dst" // Destination Options, examined by the target only
+)
+
+// MaxFlowLabel is the largest IPv6 flow label (20 bits).
+const MaxFlowLabel = 0xFFFFF
+
+// maxExtHeaderSize is the largest options header: its length field counts
+// 8-byte units beyond the first in one byte.
+const maxExtHeaderSize = 256 * 8
+
+// IPv6Options sets the flow label and extension headers of IPv6 probes, to
+// see how routers along the path treat them.
+type IPv6Options struct {
+	FlowLabel  int             // Flow label of every probe (-1 = kernel default)
+	ExtHeaders []IPv6ExtHeader // Options headers added to every probe
+}
+
+// IPv6ExtHeader is an options extension header filled with padding.
+type IPv6ExtHeader struct {
+	Type string // ExtHopByHop or ExtDestination
+	Size int    // Length in bytes, a multiple of 8
+}
+
+// String returns the header as given to ParseIPv6ExtHeaders.
+func (h IPv6ExtHeader) String() string {
+	return fmt.Sprintf("%s:%d", h.Type, h.Size)
+}
+
+// ParseIPv6ExtHeaders parses a comma-separated list of options headers, each
+// a type with an optional size in bytes: "hbh", "dst:64" or "hbh:16,dst".
+// The kernel sends at most one header of each type, so types can't repeat.
+func ParseIPv6ExtHeaders(spec string) ([]IPv6ExtHeader, error) {
+	var headers []IPv6ExtHeader
+	for _, part := range strings.Split(spec, ",") {
+		typ, size, hasSize := strings.Cut(strings.TrimSpace(part), ":")
+		if typ != ExtHopByHop && typ != ExtDestination {
+			return nil, fmt.Errorf("unknown header %q (must be %s or %s)", typ, ExtHopByHop, ExtDestination)
+		}
+		h := IPv6ExtHeader{Type: typ, Size: 8}
+		if hasSize {
+			n, err := strconv.Atoi(size)
+			if err != nil || n < 8 || n > maxExtHeaderSize || n%8 != 0 {
+				return nil, fmt.Errorf("invalid %s size %q (must be a multiple of 8 from 8 to %d)", typ, size, maxExtHeaderSize)
+			}
+			h.Size = n
+		}
+		for _, prev := range headers {
+			if prev.Type == typ {
+				return nil, fmt.Errorf("%s given more than once", typ)
+			}
+		}
+		headers = append(headers, h)
+	}
+	return headers, nil
+}
+
+// bytes returns the header with its options area filled with PadN options
+// (and a trailing Pad1 when one byte is left). The kernel sets the next
+// header field.
+func (h IPv6ExtHeader) bytes() []byte {
+	b := make([]byte, h.Size)
+	b[1] = byte(h.Size/8 - 1)
+	for pad := b[2:]; len(pad) > 0; {
+		n := min(len(pad), 2+255)
+		if n == 1 {
+			pad[0] = 0 // Pad1
+		} else {
+			pad[0] = 1 // PadN
+			pad[1] = byte(n - 2)
+		}
+		pad = pad[n:]
+	}
+	return b
+}
+
+// check returns an error when o is set for a target it can't apply to.
+func (o *IPv6Options) check(target net.IP) error {
+	if o != nil && !IsIPv6(target) {
+		return errors.New("flow label and extension headers only apply to IPv6 targets")
+	}
+	return nil
+}
+
+// leased reports whether probes carry a flow label leased from the kernel,
+// which is then passed with every send. Label 0 needs no lease: it only
+// turns automatic flow labels off.
+func (o *IPv6Options) leased() bool {
+	return o != nil && o.FlowLabel > 0
+}
+
+// apply applies o to the probe socket fd for target, if o is set.
+func (o *IPv6Options) apply(fd socketFD, target net.IP) error {
+	if o == nil {
+		return nil
+	}
+	return setIPv6Options(int(fd), target, o)
+}
+
+// sendProbeTo sends data to port on target from fd, with the flow label of o.
+func sendProbeTo(fd socketFD, data []byte, target net.IP, port int, o *IPv6Options) error {
+	if o.leased() {
+		return sendToFlow(int(fd), data, target, port, o.FlowLabel)
+	}
+	return sendToSocket(fd, data, 0, buildSockaddr(target, port))
+}
+
+// connectProbe connects fd to port on target, with the flow label of o.
+func connectProbe(fd socketFD, target net.IP, port int, o *IPv6Options) error {
+	if o.leased() {
+		return connectFlow(int(fd), target, port, o.FlowLabel)
+	}
+	return connectSocket(fd, buildSockaddr(target, port))
+}
+
+// setListenerIPv6Options applies o to the raw ICMPv6 socket under conn,
+// which the ICMP tracer sends its Echo Requests from.
+func setListenerIPv6Options(conn *icmp.PacketConn, target net.IP, o *IPv6Options) error {
+	raw := rawIPConn(conn, target)
+	if raw == nil {
+		return errors.New("flow label and extension headers need a raw ICMPv6 socket")
+	}
+	sc, err := raw.SyscallConn()
+	if err != nil {
+		return err
+	}
+	if cerr := sc.Control(func(fd uintptr) { err = setIPv6Options(int(fd), target, o) }); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// writeEchoFlow sends an ICMPv6 message to target from the raw socket under
+// conn with the flow label of o.
+func writeEchoFlow(conn *icmp.PacketConn, msg []byte, target net.IP, o *IPv6Options) error {
+	sc, err := rawIPConn(conn, target).SyscallConn()
+	if err != nil {
+		return err
+	}
+	werr := sc.Write(func(fd uintptr) bool {
+		err = sendToFlow(int(fd), msg, target, 0, o.FlowLabel)
+		return err != syscall.EAGAIN
+	})
+	if werr != nil {
+		return werr
+	}
+	return err
+}
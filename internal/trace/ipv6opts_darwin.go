@@ -0,0 +1,27 @@
+//go:build darwin
+
+package trace
+
+import (
+	"errors"
+	"net"
+)
+
+// errIPv6Options is returned when IPv6 options are set: Darwin has no flow
+// label manager to send a chosen label with.
+var errIPv6Options = errors.New("flow label and extension headers are only supported on Linux")
+
+// setIPv6Options reports that IPv6 options are unavailable.
+func setIPv6Options(fd int, target net.IP, o *IPv6Options) error {
+	return errIPv6Options
+}
+
+// sendToFlow reports that flow labels are unavailable.
+func sendToFlow(fd int, data []byte, target net.IP, port, label int) error {
+	return errIPv6Options
+}
+
+// connectFlow reports that flow labels are unavailable.
+func connectFlow(fd int, target net.IP, port, label int) error {
+	return errIPv6Options
+}
@@ -0,0 +1,94 @@
+//go:build linux
+
+package trace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Flow label manager constants from linux/in6.h.
+const (
+	ipv6FlowLabelMgr = 32 // IPV6_FLOWLABEL_MGR
+	ipv6FlowInfoSend = 33 // IPV6_FLOWINFO_SEND
+	flActionGet      = 0  // IPV6_FL_A_GET
+	flShareUser      = 3  // IPV6_FL_S_USER: every socket of this user may join
+	flFlagCreate     = 1  // IPV6_FL_F_CREATE
+)
+
+// setIPv6Options adds the extension headers of o to every packet sent from
+// the IPv6 socket fd, as sticky options, and sets up its flow label. Linux
+// only sends a label the socket leased from its flow label manager, read
+// from the destination address passed to sendToFlow or connectFlow.
+func setIPv6Options(fd int, target net.IP, o *IPv6Options) error {
+	for _, h := range o.ExtHeaders {
+		opt := unix.IPV6_HOPOPTS
+		if h.Type == ExtDestination {
+			opt = unix.IPV6_DSTOPTS
+		}
+		if err := unix.SetsockoptString(fd, unix.IPPROTO_IPV6, opt, string(h.bytes())); err != nil {
+			return fmt.Errorf("failed to add %s header: %w", h, err)
+		}
+	}
+
+	switch {
+	case o.FlowLabel == 0:
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_AUTOFLOWLABEL, 0); err != nil {
+			return fmt.Errorf("failed to clear flow label: %w", err)
+		}
+	case o.FlowLabel > 0:
+		// struct in6_flowlabel_req
+		var req [32]byte
+		copy(req[:16], target.To16())
+		binary.BigEndian.PutUint32(req[16:], uint32(o.FlowLabel))
+		req[20] = flActionGet
+		req[21] = flShareUser
+		binary.NativeEndian.PutUint16(req[22:], flFlagCreate)
+		if err := unix.SetsockoptString(fd, unix.IPPROTO_IPV6, ipv6FlowLabelMgr, string(req[:])); err != nil {
+			return fmt.Errorf("failed to lease flow label 0x%05x: %w", o.FlowLabel, err)
+		}
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, ipv6FlowInfoSend, 1); err != nil {
+			return fmt.Errorf("failed to set flow label: %w", err)
+		}
+	}
+	return nil
+}
+
+// flowSockaddr returns the address of port on target with flow label label.
+// syscall.SockaddrInet6 has no flow information field.
+func flowSockaddr(target net.IP, port, label int) *unix.RawSockaddrInet6 {
+	sa := &unix.RawSockaddrInet6{Family: unix.AF_INET6}
+	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&sa.Port))[:], uint16(port))
+	binary.BigEndian.PutUint32((*[4]byte)(unsafe.Pointer(&sa.Flowinfo))[:], uint32(label))
+	copy(sa.Addr[:], target.To16())
+	return sa
+}
+
+// sendToFlow sends data to port on target from fd with flow label label.
+func sendToFlow(fd int, data []byte, target net.IP, port, label int) error {
+	sa := flowSockaddr(target, port, label)
+	var p unsafe.Pointer
+	if len(data) > 0 {
+		p = unsafe.Pointer(&data[0])
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_SENDTO, uintptr(fd), uintptr(p), uintptr(len(data)), 0,
+		uintptr(unsafe.Pointer(sa)), unix.SizeofSockaddrInet6)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// connectFlow connects fd to port on target with flow label label.
+func connectFlow(fd int, target net.IP, port, label int) error {
+	sa := flowSockaddr(target, port, label)
+	_, _, errno := unix.Syscall(unix.SYS_CONNECT, uintptr(fd), uintptr(unsafe.Pointer(sa)), unix.SizeofSockaddrInet6)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package trace
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// udp6Socket returns a UDP socket on loopback, skipping the test without IPv6.
+func udp6Socket(t *testing.T) socketFD {
+	t.Helper()
+	fd, err := createRawSocket(syscall.AF_INET6, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		t.Skipf("no IPv6 sockets: %v", err)
+	}
+	t.Cleanup(func() { closeSocket(fd) })
+	return fd
+}
+
+func TestSetIPv6Options_AddsStickyHeaders(t *testing.T) {
+	fd := udp6Socket(t)
+	o := &IPv6Options{FlowLabel: -1, ExtHeaders: []IPv6ExtHeader{{ExtHopByHop, 16}, {ExtDestination, 8}}}
+	if err := o.apply(fd, net.IPv6loopback); err != nil {
+		t.Skipf("sticky options unavailable: %v", err)
+	}
+
+	// unix.GetsockoptString stops at the first zero byte
+	got := make([]byte, 64)
+	n := uint32(len(got))
+	if _, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.IPPROTO_IPV6, unix.IPV6_HOPOPTS,
+		uintptr(unsafe.Pointer(&got[0])), uintptr(unsafe.Pointer(&n)), 0); errno != 0 {
+		t.Fatalf("getsockopt: %v", errno)
+	}
+	got = got[:n]
+	// The kernel fills in the next header field
+	if want := (IPv6ExtHeader{ExtHopByHop, 16}).bytes(); len(got) != len(want) || !bytes.Equal(got[1:], want[1:]) {
+		t.Errorf("hop-by-hop header = %v, want %v", got, want)
+	}
+}
+
+func TestSendProbeTo_WithFlowLabel(t *testing.T) {
+	ln, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer ln.Close()
+	port := ln.LocalAddr().(*net.UDPAddr).Port
+
+	fd := udp6Socket(t)
+	o := &IPv6Options{FlowLabel: 0x12345}
+	if err := o.apply(fd, net.IPv6loopback); err != nil {
+		t.Skipf("flow label manager unavailable: %v", err)
+	}
+	if err := sendProbeTo(fd, []byte("probe"), net.IPv6loopback, port, o); err != nil {
+		t.Fatalf("sendProbeTo: %v", err)
+	}
+
+	ln.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 16)
+	n, _, err := ln.ReadFrom(b)
+	if err != nil || string(b[:n]) != "probe" {
+		t.Errorf("received %q, %v; want the probe", b[:n], err)
+	}
+
+	// Without a lease, the kernel refuses the label
+	other := udp6Socket(t)
+	if err := unix.SetsockoptInt(int(other), unix.IPPROTO_IPV6, ipv6FlowInfoSend, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendToFlow(int(other), []byte("probe"), net.IPv6loopback, port, 0x54321); err == nil {
+		t.Error("expected an error sending an unleased flow label")
+	}
+}
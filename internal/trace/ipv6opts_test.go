@@ -0,0 +1,84 @@
+package trace
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestParseIPv6ExtHeaders(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []IPv6ExtHeader
+		wantErr bool
+	}{
+		{"hbh", []IPv6ExtHeader{{ExtHopByHop, 8}}, false},
+		{"dst:64", []IPv6ExtHeader{{ExtDestination, 64}}, false},
+		{"hbh:16, dst", []IPv6ExtHeader{{ExtHopByHop, 16}, {ExtDestination, 8}}, false},
+		{"dst:2048", []IPv6ExtHeader{{ExtDestination, 2048}}, false},
+		{"dst:12", nil, true},
+		{"dst:0", nil, true},
+		{"hbh:2056", nil, true},
+		{"hbh,hbh:16", nil, true},
+		{"rthdr", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseIPv6ExtHeaders(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseIPv6ExtHeaders(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("header %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIPv6ExtHeader_Bytes(t *testing.T) {
+	// 8 bytes: length 0 (one 8-byte unit), PadN covering the 6 option bytes
+	if got, want := (IPv6ExtHeader{ExtHopByHop, 8}).bytes(), []byte{0, 0, 1, 4, 0, 0, 0, 0}; !bytes.Equal(got, want) {
+		t.Errorf("bytes = %v, want %v", got, want)
+	}
+
+	// 264 bytes: a full PadN (257 bytes) leaves 5 bytes for a second one
+	b := (IPv6ExtHeader{ExtDestination, 264}).bytes()
+	if len(b) != 264 || b[1] != 32 {
+		t.Fatalf("length = %d, field %d; want 264, 32", len(b), b[1])
+	}
+	if b[2] != 1 || b[3] != 255 || b[259] != 1 || b[260] != 3 {
+		t.Errorf("options = PadN %d then PadN %d, want 255 then 3", b[3], b[260])
+	}
+
+	// 2+257+1: the last byte is a Pad1
+	if b := (IPv6ExtHeader{ExtDestination, 260}).bytes(); b[259] != 0 {
+		t.Errorf("last option = %d, want Pad1", b[259])
+	}
+}
+
+func TestIPv6Options_Check(t *testing.T) {
+	var none *IPv6Options
+	if err := none.check(net.ParseIP("192.0.2.1")); err != nil {
+		t.Errorf("unset options: unexpected error: %v", err)
+	}
+	o := &IPv6Options{FlowLabel: 1}
+	if err := o.check(net.ParseIP("2001:db8::1")); err != nil {
+		t.Errorf("IPv6 target: unexpected error: %v", err)
+	}
+	if err := o.check(net.ParseIP("192.0.2.1")); err == nil {
+		t.Error("expected an error for an IPv4 target")
+	}
+}
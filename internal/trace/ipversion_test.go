@@ -207,3 +207,96 @@ func TestIPHeaderSize(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectDualStack_PicksOnePerFamily(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("2001:db8::2"),
+	}
+
+	v4, v6, err := selectDualStack(ips)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v4.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("v4 = %v, want 192.0.2.1", v4)
+	}
+	if !v6.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("v6 = %v, want 2001:db8::1", v6)
+	}
+}
+
+func TestSelectDualStack_RequiresBothFamilies(t *testing.T) {
+	tests := []struct {
+		name string
+		ips  []net.IP
+	}{
+		{"IPv4 only", []net.IP{net.ParseIP("192.0.2.1")}},
+		{"IPv6 only", []net.IP{net.ParseIP("2001:db8::1")}},
+		{"empty", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := selectDualStack(tt.ips); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestResolveDualStack_RejectsIPLiteral(t *testing.T) {
+	if _, _, err := ResolveDualStack("8.8.8.8"); err == nil {
+		t.Error("expected error for IP literal")
+	}
+}
+
+func TestSelectAllAddresses_OrdersAndFilters(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("192.0.2.2"),
+	}
+
+	tests := []struct {
+		name string
+		af   AddressFamily
+		want []string
+	}{
+		{"auto", AddressFamilyAuto, []string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "2001:db8::2"}},
+		{"IPv4", AddressFamilyIPv4, []string{"192.0.2.1", "192.0.2.2"}},
+		{"IPv6", AddressFamilyIPv6, []string{"2001:db8::1", "2001:db8::2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectAllAddresses(ips, tt.af)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i, ip := range got {
+				if ip.String() != tt.want[i] {
+					t.Errorf("got[%d] = %v, want %v", i, ip, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSelectAllAddresses_RequiresMatchingFamily(t *testing.T) {
+	if _, err := selectAllAddresses([]net.IP{net.ParseIP("192.0.2.1")}, AddressFamilyIPv6); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestResolveAllAddresses_RejectsIPLiteral(t *testing.T) {
+	if _, err := ResolveAllAddresses("8.8.8.8", AddressFamilyAuto); err == nil {
+		t.Error("expected error for IP literal")
+	}
+}
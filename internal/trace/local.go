@@ -17,6 +17,10 @@ func NewLocalTracer(cfg *Config) (Tracer, error) {
 		return NewUDPTracer(cfg), nil
 	case ProtocolTCP:
 		return NewTCPTracer(cfg), nil
+	case ProtocolQUIC:
+		return NewQUICTracer(cfg), nil
+	case ProtocolAuto:
+		return NewAutoTracer(cfg), nil
 	default:
 		return nil, fmt.Errorf("unsupported protocol: %s", cfg.Protocol)
 	}
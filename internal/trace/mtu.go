@@ -3,6 +3,9 @@ package trace
 
 import (
 	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
 )
 
 // MTU constants
@@ -13,6 +16,9 @@ const (
 	// MinMTU is the minimum MTU for IPv4 (RFC 791)
 	MinMTU = 68
 
+	// MinMTUv6 is the minimum link MTU for IPv6 (RFC 8200)
+	MinMTUv6 = 1280
+
 	// JumboMTU threshold - MTUs above this are considered jumbo frames
 	JumboMTU = 1500
 )
@@ -90,6 +96,52 @@ func ParseMTUFromICMP(data []byte) (int, bool) {
 	return mtu, true
 }
 
+// ParseMTUFromICMPv6 extracts the MTU value from an ICMPv6 Packet Too Big
+// message.
+//
+// ICMPv6 message structure for Type 2:
+// - Type (1 byte): 2 (Packet Too Big)
+// - Code (1 byte): 0
+// - Checksum (2 bytes)
+// - MTU (4 bytes) - big-endian
+// - As much of the original packet as fits in the minimum IPv6 MTU
+//
+// Returns the MTU value and true if successfully parsed, or 0 and false
+// otherwise. MTUs below the IPv6 minimum are invalid (RFC 8200).
+func ParseMTUFromICMPv6(data []byte) (int, bool) {
+	if len(data) < 8 || data[0] != 2 || data[1] != 0 {
+		return 0, false
+	}
+
+	mtu := int(data[4])<<24 | int(data[5])<<16 | int(data[6])<<8 | int(data[7])
+	if mtu < MinMTUv6 {
+		return 0, false
+	}
+	return mtu, true
+}
+
+// packetTooBigResult returns the result of a probe answered with an ICMPv6
+// Packet Too Big message: the router at peer could not forward it over its
+// next link. It is reported like its IPv4 counterpart, a Destination
+// Unreachable (Fragmentation Needed), with the MTU when discovering it.
+func packetTooBigResult(body *icmp.PacketTooBig, peer net.IP, discoverMTU bool) *probeResult {
+	pr := &probeResult{IP: peer, ICMPType: 3, ICMPCode: 4}
+	if discoverMTU && body.MTU >= MinMTUv6 {
+		pr.MTU = body.MTU
+	}
+	return pr
+}
+
+// setDontFragmentFor stops fragmentation of probes to target sent on fd, so
+// probes larger than the path MTU are answered with Fragmentation Needed or
+// Packet Too Big.
+func setDontFragmentFor(fd socketFD, target net.IP) error {
+	if IsIPv6(target) {
+		return setDontFragmentIPv6(fd)
+	}
+	return setDontFragment(fd)
+}
+
 // MTUSearchMidpoint calculates the midpoint for binary search MTU discovery.
 func MTUSearchMidpoint(low, high int) int {
 	return (low + high) / 2
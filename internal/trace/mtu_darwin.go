@@ -9,3 +9,9 @@ import "syscall"
 func setDontFragment(fd socketFD) error {
 	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, 28, 1)
 }
+
+// setDontFragmentIPv6 stops the kernel from fragmenting packets sent on an
+// IPv6 socket. On macOS/BSD this uses IPV6_DONTFRAG (62).
+func setDontFragmentIPv6(fd socketFD) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, 62, 1)
+}
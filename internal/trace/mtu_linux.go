@@ -13,3 +13,10 @@ func setDontFragment(fd socketFD) error {
 	)
 	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, ipMTUDiscover, ipPMTUDiscDo)
 }
+
+// setDontFragmentIPv6 stops the kernel from fragmenting packets sent on an
+// IPv6 socket once it learns a smaller path MTU, so oversized probes keep
+// drawing Packet Too Big messages. This uses IPV6_DONTFRAG (62).
+func setDontFragmentIPv6(fd socketFD) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, 62, 1)
+}
@@ -1,7 +1,14 @@
 package trace
 
 import (
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"golang.org/x/net/icmp"
 )
 
 func TestMTUInfo_String(t *testing.T) {
@@ -176,3 +183,121 @@ func TestCommonMTUValues(t *testing.T) {
 		t.Errorf("MinMTU = %d, want 68 (IPv4 minimum)", MinMTU)
 	}
 }
+
+// readICMPv6Fixture decodes a captured ICMPv6 message from testdata: hex
+// bytes with whitespace, after # comment lines.
+func readICMPv6Fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "icmpv6", name+".hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var digits strings.Builder
+	for _, line := range strings.Split(string(raw), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			digits.WriteString(strings.Join(strings.Fields(line), ""))
+		}
+	}
+	data, err := hex.DecodeString(digits.String())
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return data
+}
+
+func TestParseMTUFromICMPv6(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		expected int
+		ok       bool
+	}{
+		{"ptb_udp", 1476, true},
+		{"ptb_tcp", 1400, true},
+		{"ptb_echo", 1280, true},
+		{"ptb_below_minimum", 0, false},
+		{"unreach_port", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			mtu, ok := ParseMTUFromICMPv6(readICMPv6Fixture(t, tt.fixture))
+			if ok != tt.ok || mtu != tt.expected {
+				t.Errorf("ParseMTUFromICMPv6() = %d, %v; want %d, %v", mtu, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+
+	if _, ok := ParseMTUFromICMPv6([]byte{2, 0, 0, 0}); ok {
+		t.Error("expected a truncated message to be rejected")
+	}
+}
+
+// parsePacketTooBig parses a Packet Too Big fixture as the tracers do.
+func parsePacketTooBig(t *testing.T, fixture string) *icmp.PacketTooBig {
+	t.Helper()
+	rm, err := icmp.ParseMessage(ICMPProtocolNum(ptbTarget), readICMPv6Fixture(t, fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, ok := rm.Body.(*icmp.PacketTooBig)
+	if !ok {
+		t.Fatalf("%s: body is %T, want Packet Too Big", fixture, rm.Body)
+	}
+	if !quotedDstIs(body.Data, ptbTarget) {
+		t.Errorf("%s: quoted packet is not to %s", fixture, ptbTarget)
+	}
+	return body
+}
+
+// ptbTarget is the destination of the probes quoted by the fixtures.
+var ptbTarget = net.ParseIP("2001:db8:ffff::1")
+
+func TestPacketTooBig_MatchesProbes(t *testing.T) {
+	ipHdrSize := IPHeaderSize(ptbTarget)
+
+	if port, ok := quotedDstPort(parsePacketTooBig(t, "ptb_udp").Data, ipHdrSize); !ok || port != 33437 {
+		t.Errorf("UDP destination port = %d, %v; want 33437", port, ok)
+	}
+
+	tcpBody := parsePacketTooBig(t, "ptb_tcp")
+	tcp := &TCPTracer{config: &Config{Port: 443}}
+	if !tcp.isOurProbeForIP(tcpBody.Data, 443, ptbTarget) || quotedSrcPort(tcpBody.Data, ipHdrSize) != 51000 {
+		t.Errorf("TCP probe to 443 from 51000 not matched")
+	}
+
+	echoBody := parsePacketTooBig(t, "ptb_echo")
+	if !(&ICMPTracer{id: 0x1234}).quotesOurEcho(echoBody.Data, ipHdrSize, ptbTarget) {
+		t.Error("Echo Request id 0x1234 not matched")
+	}
+	if (&ICMPTracer{id: 0x4321}).quotesOurEcho(echoBody.Data, ipHdrSize, ptbTarget) {
+		t.Error("Echo Request of another tracer matched")
+	}
+	if seq := quotedEchoSeq(echoBody.Data, ipHdrSize); seq != 7 {
+		t.Errorf("Echo sequence = %d, want 7", seq)
+	}
+}
+
+func TestPacketTooBigResult(t *testing.T) {
+	peer := net.ParseIP("2001:db8:200::1")
+	tests := []struct {
+		fixture     string
+		discoverMTU bool
+		wantMTU     int
+	}{
+		{"ptb_udp", true, 1476},
+		{"ptb_echo", true, 1280},
+		{"ptb_udp", false, 0},
+		{"ptb_below_minimum", true, 0},
+	}
+
+	for _, tt := range tests {
+		pr := packetTooBigResult(parsePacketTooBig(t, tt.fixture), peer, tt.discoverMTU)
+		if pr.MTU != tt.wantMTU {
+			t.Errorf("%s (discover %v): MTU = %d, want %d", tt.fixture, tt.discoverMTU, pr.MTU, tt.wantMTU)
+		}
+		// Reported like Fragmentation Needed, so displays flag it the same way
+		if !pr.IP.Equal(peer) || pr.ICMPType != 3 || pr.ICMPCode != 4 {
+			t.Errorf("%s: result = %+v, want Fragmentation Needed from %s", tt.fixture, pr, peer)
+		}
+	}
+}
@@ -19,15 +19,58 @@ type MultiContinuousTracer struct {
 	tracers  []Tracer
 	targets  []net.IP
 	interval time.Duration
+	cts      []*ContinuousTracer
 }
 
 // NewMultiContinuousTracer creates a new multi-target continuous tracer.
 func NewMultiContinuousTracer(cfg *Config, tracers []Tracer, targets []net.IP, interval time.Duration) *MultiContinuousTracer {
+	cts := make([]*ContinuousTracer, len(targets))
+	for i := range targets {
+		cts[i] = NewContinuousTracer(cfg, tracers[i], interval)
+	}
 	return &MultiContinuousTracer{
 		config:   cfg,
 		tracers:  tracers,
 		targets:  targets,
 		interval: interval,
+		cts:      cts,
+	}
+}
+
+// SetInterval changes the time between cycles of every target.
+func (mct *MultiContinuousTracer) SetInterval(d time.Duration) {
+	for _, ct := range mct.cts {
+		ct.SetInterval(d)
+	}
+}
+
+// SetProbeSize changes the size of the probes to every target.
+func (mct *MultiContinuousTracer) SetProbeSize(size int) {
+	for _, ct := range mct.cts {
+		ct.SetProbeSize(size)
+	}
+}
+
+// SetPacketsPerHop makes every cycle probe each hop of every target n times.
+// Must be called before Run.
+func (mct *MultiContinuousTracer) SetPacketsPerHop(n int) {
+	for _, ct := range mct.cts {
+		ct.SetPacketsPerHop(n)
+	}
+}
+
+// SetAdaptiveMaxHops limits the hops probed to every target to just past it
+// once it answered. Must be called before Run.
+func (mct *MultiContinuousTracer) SetAdaptiveMaxHops(on bool) {
+	for _, ct := range mct.cts {
+		ct.SetAdaptiveMaxHops(on)
+	}
+}
+
+// SetPaused stops or resumes probing of every target.
+func (mct *MultiContinuousTracer) SetPaused(paused bool) {
+	for _, ct := range mct.cts {
+		ct.SetPaused(paused)
 	}
 }
 
@@ -40,7 +83,7 @@ func (mct *MultiContinuousTracer) Run(ctx context.Context, probeCallback MultiPr
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
-			ct := NewContinuousTracer(mct.config, mct.tracers[idx], mct.interval)
+			ct := mct.cts[idx]
 
 			pcb := func(pr ProbeResult) {
 				if probeCallback != nil {
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package trace
+
+import "errors"
+
+// errNoNetNS is returned when a network namespace is requested on a platform
+// without them.
+var errNoNetNS = errors.New("network namespaces are only supported on Linux")
+
+// checkNetNS returns an error: Darwin has no network namespaces.
+func checkNetNS(name string) error {
+	return errNoNetNS
+}
+
+// inNetNS runs f when name is empty, and fails otherwise.
+func inNetNS(name string, f func() error) error {
+	if name != "" {
+		return errNoNetNS
+	}
+	return f()
+}
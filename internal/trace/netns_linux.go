@@ -0,0 +1,70 @@
+//go:build linux
+
+package trace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// netnsDir holds the network namespaces named by ip-netns(8).
+const netnsDir = "/var/run/netns"
+
+// netnsPath returns the file of the network namespace name: an ip-netns(8)
+// name, or a path such as /proc/<pid>/ns/net.
+func netnsPath(name string) string {
+	if strings.ContainsRune(name, '/') {
+		return name
+	}
+	return filepath.Join(netnsDir, name)
+}
+
+// checkNetNS returns an error if the network namespace name does not exist.
+func checkNetNS(name string) error {
+	if _, err := os.Stat(netnsPath(name)); err != nil {
+		return fmt.Errorf("network namespace %q not found: %w", name, err)
+	}
+	return nil
+}
+
+// inNetNS runs f on an OS thread switched to the network namespace name, so
+// the sockets f opens belong to that namespace for their whole life. An empty
+// name runs f in the current namespace.
+func inNetNS(name string, f func() error) error {
+	if name == "" {
+		return f()
+	}
+
+	ns, err := os.Open(netnsPath(name))
+	if err != nil {
+		return fmt.Errorf("failed to open network namespace %q: %w", name, err)
+	}
+	defer ns.Close()
+
+	// setns only switches the calling thread
+	runtime.LockOSThread()
+	orig, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("failed to open current network namespace: %w", err)
+	}
+	defer orig.Close()
+
+	if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("failed to enter network namespace %q: %w (try running with sudo)", name, err)
+	}
+	defer func() {
+		// A thread stuck in the namespace stays locked, so the runtime
+		// discards it when the goroutine exits instead of reusing it
+		if unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	return f()
+}
@@ -0,0 +1,54 @@
+//go:build linux
+
+package trace
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestNetNSPath(t *testing.T) {
+	if got := netnsPath("blue"); got != "/var/run/netns/blue" {
+		t.Errorf("netnsPath(blue) = %q", got)
+	}
+	if got := netnsPath("/proc/1/ns/net"); got != "/proc/1/ns/net" {
+		t.Errorf("netnsPath(/proc/1/ns/net) = %q", got)
+	}
+}
+
+func TestCheckNetNS_Missing(t *testing.T) {
+	err := checkNetNS(filepath.Join(t.TempDir(), "missing"))
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("err = %v, want not found", err)
+	}
+}
+
+func TestInNetNS_EmptyRunsInPlace(t *testing.T) {
+	ran := false
+	if err := inNetNS("", func() error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("ran = %v, err = %v", ran, err)
+	}
+}
+
+func TestInNetNS_CurrentNamespace(t *testing.T) {
+	// Entering the namespace we are in exercises the switch and restore
+	var fd socketFD
+	err := inNetNS("/proc/self/ns/net", func() (err error) {
+		fd, err = createRawSocket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+		return err
+	})
+	if err != nil {
+		t.Skipf("cannot switch network namespace: %v", err)
+	}
+	closeSocket(fd)
+}
+
+func TestConfigValidate_MissingNetNS(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NetNS = filepath.Join(t.TempDir(), "missing")
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a missing network namespace")
+	}
+}
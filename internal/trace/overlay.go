@@ -0,0 +1,105 @@
+package trace
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Overlay kinds, from the most to the least specific.
+const (
+	OverlayTailscale = "Tailscale"
+	OverlayWireGuard = "WireGuard"
+	OverlayVPN       = "VPN"
+	OverlayCGNAT     = "CGNAT"
+)
+
+// Default tunnel MTUs that identify an overlay when seen on the outgoing
+// interface or discovered at a hop.
+const (
+	tailscaleMTU = 1280
+	wireGuardMTU = 1420
+)
+
+// tailscaleServiceIP is the address of Tailscale's MagicDNS resolver and
+// in-client services, in the range Tailscale gives its nodes.
+var tailscaleServiceIP = net.ParseIP("100.100.100.100")
+
+// DetectOverlay looks for signs that the path of tr goes through a VPN or an
+// overlay network: a route through a tunnel interface, hops in the CGNAT
+// shared range (100.64.0.0/10) that Tailscale also numbers its nodes from,
+// and the default MTUs of WireGuard (1420) and Tailscale (1280). Returns nil
+// when there are none.
+func DetectOverlay(tr *hop.TraceResult) *hop.Overlay {
+	var evidence []string
+	kind := ""
+	mtu := 0
+
+	if r := tr.Route; r != nil {
+		if isVPNInterface(r.Interface) {
+			kind = tunnelKind(r.Interface)
+			evidence = append(evidence, "route through "+r.Interface)
+		}
+		mtu = r.MTU
+	}
+	if mtu != tailscaleMTU && mtu != wireGuardMTU {
+		mtu = 0
+		for _, h := range tr.Hops {
+			if h.MTU == tailscaleMTU || h.MTU == wireGuardMTU {
+				mtu = h.MTU
+				break
+			}
+		}
+	}
+
+	cgnat := 0
+	tailnet := false
+	for _, h := range tr.Hops {
+		ip := h.PrimaryIP()
+		if !IsCGNATAddress(ip) {
+			continue
+		}
+		if cgnat == 0 {
+			evidence = append(evidence, fmt.Sprintf("hop %d %s in 100.64.0.0/10", h.TTL, ip))
+		}
+		cgnat++
+		tailnet = tailnet || ip.Equal(tailscaleServiceIP)
+	}
+
+	switch mtu {
+	case wireGuardMTU:
+		if kind == "" || kind == OverlayVPN {
+			kind = OverlayWireGuard
+		}
+		evidence = append(evidence, "MTU 1420, the WireGuard default")
+	case tailscaleMTU:
+		// 1280 is also the IPv6 minimum MTU: only a hint alongside other signs
+		if kind != "" || cgnat > 0 {
+			kind = OverlayTailscale
+			evidence = append(evidence, "MTU 1280, the Tailscale default")
+		}
+	}
+	if tailnet {
+		kind = OverlayTailscale
+	}
+	if kind == "" && cgnat > 0 {
+		kind = OverlayCGNAT
+	}
+	if kind == "" {
+		return nil
+	}
+	return &hop.Overlay{Kind: kind, Evidence: evidence}
+}
+
+// tunnelKind names the overlay behind a tunnel interface.
+func tunnelKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, "tailscale"):
+		return OverlayTailscale
+	case strings.HasPrefix(name, "wg"), strings.HasPrefix(name, "nordlynx"):
+		return OverlayWireGuard
+	}
+	return OverlayVPN
+}
@@ -0,0 +1,69 @@
+package trace
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// overlayTestTrace returns a reached trace answered by ips, one hop each.
+func overlayTestTrace(route *hop.RouteInfo, ips ...string) *hop.TraceResult {
+	tr := hop.NewTraceResult("example.com", ips[len(ips)-1])
+	for i, ip := range ips {
+		h := hop.NewHop(i + 1)
+		h.AddProbe(net.ParseIP(ip), time.Millisecond)
+		tr.AddHop(h)
+	}
+	tr.ReachedTarget = true
+	tr.Route = route
+	return tr
+}
+
+func TestDetectOverlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		tr       *hop.TraceResult
+		wantKind string
+		evidence int
+	}{
+		{"plain path", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "eth0", MTU: 1500}, "192.168.1.1", "203.0.113.1", "93.184.215.14"), "", 0},
+		{"wireguard", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "wg0", MTU: 1420}, "10.2.0.1", "93.184.215.14"), OverlayWireGuard, 2},
+		{"wireguard mtu on a generic tunnel", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "utun4", MTU: 1420}, "10.2.0.1", "93.184.215.14"), OverlayWireGuard, 2},
+		{"tailscale exit node", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "utun3", MTU: 1280}, "100.101.102.103", "93.184.215.14"), OverlayTailscale, 3},
+		{"tailscale interface", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "tailscale0", MTU: 1500}, "100.101.102.103", "93.184.215.14"), OverlayTailscale, 2},
+		{"openvpn", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "tun0", MTU: 1500}, "10.8.0.1", "93.184.215.14"), OverlayVPN, 1},
+		{"carrier nat", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "eth0", MTU: 1500}, "192.168.1.1", "100.72.0.1", "100.72.0.2", "93.184.215.14"), OverlayCGNAT, 1},
+		{"ipv6 minimum mtu alone", overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "eth0", MTU: 1280}, "192.168.1.1", "93.184.215.14"), "", 0},
+		{"no route", overlayTestTrace(nil, "192.168.1.1", "93.184.215.14"), "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DetectOverlay(tt.tr)
+			if tt.wantKind == "" {
+				if o != nil {
+					t.Errorf("expected no overlay, got %+v", o)
+				}
+				return
+			}
+			if o == nil || o.Kind != tt.wantKind {
+				t.Fatalf("expected %s, got %+v", tt.wantKind, o)
+			}
+			if len(o.Evidence) != tt.evidence {
+				t.Errorf("expected %d evidence entries, got %q", tt.evidence, o.Evidence)
+			}
+		})
+	}
+}
+
+func TestDetectOverlay_DiscoveredHopMTU(t *testing.T) {
+	tr := overlayTestTrace(&hop.RouteInfo{Destination: "default", Interface: "eth0", MTU: 1500}, "192.168.1.1", "10.0.0.1", "93.184.215.14")
+	tr.Hops[1].MTU = 1420
+
+	o := DetectOverlay(tr)
+	if o == nil || o.Kind != OverlayWireGuard {
+		t.Fatalf("expected WireGuard from the hop MTU, got %+v", o)
+	}
+}
@@ -0,0 +1,325 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/net/icmp"
+)
+
+// parallelPoll is how often a parallel trace checks for timed out probes and
+// completion while waiting for replies.
+const parallelPoll = 50 * time.Millisecond
+
+// parallelProber sends the probes of a parallel trace. Probes are identified
+// by a key unique within the trace, under which the demux delivers their
+// replies: the ICMP echo sequence number or the UDP destination port.
+type parallelProber interface {
+	// key returns the key of probe index of ttl.
+	key(ttl, index int) int
+	// send sends probe index of ttl and returns when it was sent. A non-nil
+	// result is the probe's final outcome, known without waiting for a reply.
+	send(ttl, index int) (time.Time, *probeResult, error)
+}
+
+// parallelProbe tracks one probe of a parallel trace.
+type parallelProbe struct {
+	ttl    int
+	sent   time.Time
+	result *probeResult
+	done   bool
+}
+
+// traceParallel sends the probes of every TTL without waiting for replies and
+// collects the replies d delivers as they arrive, so a trace takes about one
+// RTT plus the timeout instead of up to MaxHops timeouts. Hops are appended to
+// result and passed to callback in TTL order once all replies are in; TTLs
+// past the first one at which the target answered are discarded.
+func traceParallel(ctx context.Context, cfg *Config, target net.IP, p parallelProber, d *demux, callback HopCallback, result *hop.TraceResult) error {
+	probeCount := probesPerTTL(cfg)
+	replies := make(chan demuxReply, cfg.MaxHops*probeCount)
+
+	var mu sync.Mutex
+	probes := make(map[int]*parallelProbe, cfg.MaxHops*probeCount)
+	reachedAt := cfg.MaxHops + 1 // Lowest TTL at which the target answered
+	sending := true
+
+	// resolve records the outcome of pr. Must be called with mu held.
+	resolve := func(pr *parallelProbe, res *probeResult) {
+		pr.result, pr.done = res, true
+		if res != nil && res.IP != nil && res.IP.Equal(target) && pr.ttl < reachedAt {
+			reachedAt = pr.ttl
+		}
+		if cfg.Scheduler != nil {
+			cfg.Scheduler.Done()
+		}
+	}
+
+	// complete reports whether every probe up to the target was answered or
+	// timed out. Must be called with mu held.
+	complete := func() bool {
+		if sending {
+			return false
+		}
+		for _, pr := range probes {
+			if pr.ttl <= reachedAt && !pr.done {
+				return false
+			}
+		}
+		return true
+	}
+
+	// expire resolves probes unanswered for Timeout as lost, which also frees
+	// their scheduler slots for the probes still to send. Must be called with
+	// mu held.
+	expire := func(now time.Time) {
+		for _, pr := range probes {
+			if !pr.done && now.Sub(pr.sent) > cfg.Timeout {
+				resolve(pr, nil)
+			}
+		}
+	}
+
+	sendCtx, stopSending := context.WithCancel(ctx)
+	defer stopSending()
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendParallel(sendCtx, cfg, p, probeCount, func(ttl, index int) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if ttl > reachedAt {
+				return false
+			}
+			// Registered before sending so the reply cannot arrive first
+			key := p.key(ttl, index)
+			probes[key] = &parallelProbe{ttl: ttl, sent: time.Now()}
+			d.register(key, replies)
+			return true
+		}, func(ttl, index int, sent time.Time, res *probeResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			pr := probes[p.key(ttl, index)]
+			if pr.done {
+				return // Answered before the send time was recorded
+			}
+			pr.sent = sent
+			if res != nil {
+				resolve(pr, res)
+			}
+		})
+		mu.Lock()
+		sending = false
+		mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(parallelPoll)
+	defer ticker.Stop()
+	var err error
+wait:
+	for {
+		mu.Lock()
+		expire(time.Now())
+		done := complete()
+		mu.Unlock()
+		if done {
+			break
+		}
+
+		select {
+		case r := <-replies:
+			mu.Lock()
+			// Replies after the timeout count as lost, as in sequential traces
+			if pr := probes[r.key]; !pr.done && r.at.Sub(pr.sent) <= cfg.Timeout {
+				r.pr.RTT = r.at.Sub(pr.sent)
+				resolve(pr, r.pr)
+			}
+			mu.Unlock()
+		case <-ticker.C:
+		case <-d.done:
+			err = d.failure()
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	stopSending()
+	if sendFailed := <-sendErr; err == nil && ctx.Err() == nil {
+		err = sendFailed
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, pr := range probes {
+		if !pr.done {
+			resolve(pr, nil)
+		}
+	}
+
+hops:
+	for ttl := 1; ttl <= min(reachedAt, cfg.MaxHops); ttl++ {
+		h := hop.NewHop(ttl)
+		for index := 0; index < probeCount; index++ {
+			pr, ok := probes[p.key(ttl, index)]
+			if !ok {
+				// Not sent: the trace was interrupted
+				break hops
+			}
+			flowID := 0
+			if cfg.ECMPFlows > 0 {
+				flowID = index + 1
+			}
+			addProbeResult(h, pr.result, flowID)
+		}
+		if cfg.DetectNAT {
+			detectHopNAT(h)
+		}
+		result.AddHop(h)
+		if callback != nil {
+			callback(h)
+		}
+		if ttl == reachedAt {
+			result.ReachedTarget = true
+		}
+	}
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// sendParallel sends probeCount probes per TTL, in TTL order. Before each
+// probe, next records it and reports whether it is still needed; sending
+// stops at the first one that is not. Each sent probe is reported to sent.
+func sendParallel(ctx context.Context, cfg *Config, p parallelProber, probeCount int, next func(ttl, index int) bool, sent func(ttl, index int, at time.Time, res *probeResult)) error {
+	for ttl := 1; ttl <= cfg.MaxHops; ttl++ {
+		if err := nextTTL(ctx, cfg.Scheduler, ttl); err != nil {
+			return err
+		}
+		for index := 0; index < probeCount; index++ {
+			if cfg.Scheduler != nil {
+				if err := cfg.Scheduler.Wait(ctx); err != nil {
+					return err
+				}
+			} else if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if !next(ttl, index) {
+				if cfg.Scheduler != nil {
+					cfg.Scheduler.Done()
+				}
+				return nil
+			}
+			at, res, err := p.send(ttl, index)
+			if err != nil {
+				// Like in a sequential trace, a failed send is a lost probe
+				at, res = time.Now(), &probeResult{}
+			}
+			sent(ttl, index, at, res)
+		}
+	}
+	return nil
+}
+
+// addProbeResult records at h the outcome of a probe, nil when it was lost.
+func addProbeResult(h *hop.Hop, pr *probeResult, flowID int) {
+	// A nil IP is an MTU-only result (local EMSGSIZE), recorded as a timeout
+	if pr == nil || pr.IP == nil {
+		if pr != nil && pr.MTU > 0 && h.MTU == 0 {
+			h.MTU = pr.MTU
+		}
+		h.AddTimeout()
+		return
+	}
+
+	probe := hop.Probe{IP: pr.IP, RTT: pr.RTT, ResponseTTL: pr.ResponseTTL, IPID: pr.IPID, ICMPType: pr.ICMPType, ICMPCode: pr.ICMPCode, OriginalTTL: pr.OriginalTTL, FlowID: flowID, TransportInfo: pr.TransportInfo}
+	h.Probes = append(h.Probes, probe)
+
+	// First probe with labels, MTU or interface info wins
+	if len(pr.MPLS) > 0 && len(h.MPLS) == 0 {
+		h.SetMPLS(pr.MPLS)
+	}
+	if pr.MTU > 0 && h.MTU == 0 {
+		h.MTU = pr.MTU
+	}
+	if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
+		h.InterfaceInfo = pr.InterfaceInfo
+	}
+	if pr.SegmentRouting != nil && h.SegmentRouting == nil {
+		h.SegmentRouting = pr.SegmentRouting
+	}
+}
+
+// detectHopNAT flags h when a reply reveals NAT, by IP (Tier 1) or TTL
+// (Tier 2). See icmp.go for why IP ID analysis (Tier 3) is not used.
+func detectHopNAT(h *hop.Hop) {
+	for _, p := range h.Probes {
+		if p.Timeout || p.IP == nil {
+			continue
+		}
+		if DetectNATFromIP(p.IP, h.TTL) || (p.ResponseTTL > 0 && DetectNATFromTTL(h.TTL, p.ResponseTTL)) {
+			h.NAT = true
+			return
+		}
+	}
+}
+
+// icmpParallelProber sends the Echo Requests of a parallel ICMP trace.
+type icmpParallelProber struct {
+	t          *ICMPTracer
+	conn       *icmp.PacketConn
+	target     net.IP
+	probeCount int
+}
+
+// key returns the echo sequence number of the probe.
+func (p *icmpParallelProber) key(ttl, index int) int {
+	return probeSeq(ttl, index, p.probeCount)
+}
+
+func (p *icmpParallelProber) send(ttl, index int) (time.Time, *probeResult, error) {
+	flowID := 0
+	if p.t.config.ECMPFlows > 0 {
+		flowID = index + 1
+	}
+	at, err := p.t.sendEcho(p.conn, p.target, ttl, p.key(ttl, index), flowID)
+	return at, nil, err
+}
+
+// udpParallelProber sends the datagrams of a parallel UDP trace.
+type udpParallelProber struct {
+	t          *UDPTracer
+	target     net.IP
+	probeCount int
+}
+
+// key returns the destination port of the probe.
+func (p *udpParallelProber) key(ttl, index int) int {
+	return p.t.getPort(probeSeq(ttl, index, p.probeCount))
+}
+
+func (p *udpParallelProber) send(ttl, index int) (time.Time, *probeResult, error) {
+	_, at, res, err := p.t.sendDatagram(p.target, ttl, probeSeq(ttl, index, p.probeCount))
+	return at, res, err
+}
+
+// uniqueKeys reports whether every probe of a trace has its own key, so
+// replies can be told apart.
+func uniqueKeys(p parallelProber, maxHops, probeCount int) bool {
+	seen := make(map[int]bool, maxHops*probeCount)
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		for index := 0; index < probeCount; index++ {
+			k := p.key(ttl, index)
+			if seen[k] {
+				return false
+			}
+			seen[k] = true
+		}
+	}
+	return true
+}
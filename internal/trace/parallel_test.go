@@ -0,0 +1,225 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+var parallelTarget = net.ParseIP("192.0.2.1")
+
+// fakeParallelProber answers probes after a per-TTL delay: routers 10.0.0.ttl
+// below targetTTL, the target from targetTTL on. TTLs in lost never answer.
+type fakeParallelProber struct {
+	targetTTL int
+	delay     func(ttl int) time.Duration
+	lost      map[int]bool
+	replies   chan demuxReply
+
+	mu   sync.Mutex
+	sent []int
+}
+
+func newFakeParallelProber(targetTTL int, delay func(ttl int) time.Duration) *fakeParallelProber {
+	return &fakeParallelProber{targetTTL: targetTTL, delay: delay, lost: map[int]bool{}, replies: make(chan demuxReply, 1024)}
+}
+
+func (f *fakeParallelProber) key(ttl, index int) int {
+	return ttl*100 + index
+}
+
+func (f *fakeParallelProber) send(ttl, index int) (time.Time, *probeResult, error) {
+	f.mu.Lock()
+	f.sent = append(f.sent, ttl)
+	f.mu.Unlock()
+
+	if !f.lost[ttl] {
+		ip := net.ParseIP(fmt.Sprintf("10.0.0.%d", ttl))
+		if ttl >= f.targetTTL {
+			ip = parallelTarget
+		}
+		key := f.key(ttl, index)
+		time.AfterFunc(f.delay(ttl), func() {
+			f.replies <- demuxReply{key: key, pr: &probeResult{IP: ip}, at: time.Now()}
+		})
+	}
+	return time.Now(), nil, nil
+}
+
+func (f *fakeParallelProber) read(deadline time.Time) (*probeResult, int, time.Time, error) {
+	select {
+	case r := <-f.replies:
+		return r.pr, r.key, r.at, nil
+	case <-time.After(time.Until(deadline)):
+		return nil, 0, time.Time{}, &timeoutError{}
+	}
+}
+
+// traceFake runs a parallel trace with p, receiving its replies through a demux.
+func traceFake(ctx context.Context, cfg *Config, p *fakeParallelProber, callback HopCallback, result *hop.TraceResult) error {
+	d := newDemux(nil, p.read)
+	defer d.close()
+	return traceParallel(ctx, cfg, parallelTarget, p, d, callback, result)
+}
+
+func TestTraceParallel_DeliversHopsInOrder(t *testing.T) {
+	cfg := &Config{MaxHops: 30, PacketsPerHop: 2, Timeout: time.Second}
+	result := hop.NewTraceResult("target", parallelTarget.String())
+	var delivered []int
+
+	// Later TTLs answer faster so replies arrive out of TTL order
+	p := newFakeParallelProber(8, func(ttl int) time.Duration {
+		return time.Duration(20-ttl) * time.Millisecond
+	})
+
+	err := traceFake(context.Background(), cfg, p, func(h *hop.Hop) {
+		delivered = append(delivered, h.TTL)
+	}, result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !result.ReachedTarget {
+		t.Error("expected target reached")
+	}
+	if len(delivered) != 8 {
+		t.Fatalf("delivered %d hops, want 8", len(delivered))
+	}
+	for i, ttl := range delivered {
+		if ttl != i+1 {
+			t.Errorf("delivered[%d] = TTL %d, want %d", i, ttl, i+1)
+		}
+	}
+	for _, h := range result.Hops {
+		if len(h.Probes) != 2 {
+			t.Fatalf("hop %d has %d probes, want 2", h.TTL, len(h.Probes))
+		}
+		want := fmt.Sprintf("10.0.0.%d", h.TTL)
+		if h.TTL == 8 {
+			want = parallelTarget.String()
+		}
+		if got := h.Probes[0].IP.String(); got != want {
+			t.Errorf("hop %d IP = %s, want %s", h.TTL, got, want)
+		}
+		if h.Probes[0].RTT <= 0 {
+			t.Errorf("hop %d RTT = %v, want > 0", h.TTL, h.Probes[0].RTT)
+		}
+	}
+}
+
+func TestTraceParallel_FinishesWithoutWaitingForTimeout(t *testing.T) {
+	cfg := &Config{MaxHops: 30, PacketsPerHop: 1, Timeout: 5 * time.Second}
+	result := hop.NewTraceResult("target", parallelTarget.String())
+
+	p := newFakeParallelProber(5, func(int) time.Duration { return 5 * time.Millisecond })
+
+	start := time.Now()
+	if err := traceFake(context.Background(), cfg, p, nil, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("trace took %v, want it to end once every hop answered", elapsed)
+	}
+	if result.TotalHops() != 5 {
+		t.Errorf("TotalHops = %d, want 5", result.TotalHops())
+	}
+}
+
+func TestTraceParallel_LostProbesTimeOut(t *testing.T) {
+	cfg := &Config{MaxHops: 6, PacketsPerHop: 1, Timeout: 100 * time.Millisecond}
+	result := hop.NewTraceResult("target", parallelTarget.String())
+
+	p := newFakeParallelProber(5, func(int) time.Duration { return time.Millisecond })
+	p.lost[3] = true
+
+	if err := traceFake(context.Background(), cfg, p, nil, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.TotalHops() != 5 {
+		t.Fatalf("TotalHops = %d, want 5", result.TotalHops())
+	}
+	if h := result.Hops[2]; len(h.Probes) != 1 || !h.Probes[0].Timeout {
+		t.Errorf("hop 3 probes = %+v, want one timeout", h.Probes)
+	}
+}
+
+func TestTraceParallel_LateRepliesCountAsLost(t *testing.T) {
+	cfg := &Config{MaxHops: 3, PacketsPerHop: 1, Timeout: 50 * time.Millisecond}
+	result := hop.NewTraceResult("target", parallelTarget.String())
+
+	p := newFakeParallelProber(30, func(ttl int) time.Duration {
+		if ttl == 2 {
+			return 80 * time.Millisecond
+		}
+		return time.Millisecond
+	})
+
+	if err := traceFake(context.Background(), cfg, p, nil, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.ReachedTarget {
+		t.Error("expected target not reached")
+	}
+	if result.TotalHops() != 3 {
+		t.Fatalf("TotalHops = %d, want 3", result.TotalHops())
+	}
+	if !result.Hops[1].Probes[0].Timeout {
+		t.Error("expected late reply at hop 2 to count as a timeout")
+	}
+}
+
+func TestTraceParallel_ReturnsContextError(t *testing.T) {
+	cfg := &Config{MaxHops: 30, PacketsPerHop: 1, Timeout: time.Second}
+	result := hop.NewTraceResult("target", parallelTarget.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := newFakeParallelProber(5, func(int) time.Duration { return time.Millisecond })
+	if err := traceFake(ctx, cfg, p, nil, result); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestTraceParallel_ReleasesSchedulerSlots(t *testing.T) {
+	pacer, err := NewPacer(PacingConfig{MaxInFlight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{MaxHops: 10, PacketsPerHop: 1, Timeout: 50 * time.Millisecond, Scheduler: pacer}
+	result := hop.NewTraceResult("target", parallelTarget.String())
+
+	p := newFakeParallelProber(30, func(int) time.Duration { return time.Millisecond })
+	p.lost[4] = true
+
+	if err := traceFake(context.Background(), cfg, p, nil, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.TotalHops() != 10 {
+		t.Errorf("TotalHops = %d, want 10", result.TotalHops())
+	}
+	if n := len(pacer.inFlight); n != 0 {
+		t.Errorf("%d probes still in flight, want 0", n)
+	}
+}
+
+func TestUniqueKeys(t *testing.T) {
+	icmpCfg := &Config{MaxHops: 30, PacketsPerHop: 3}
+	if !uniqueKeys(&icmpParallelProber{probeCount: 3}, icmpCfg.MaxHops, 3) {
+		t.Error("expected unique ICMP sequence numbers")
+	}
+
+	udp := &udpParallelProber{t: NewUDPTracer(&Config{Port: 33434}), probeCount: 3}
+	if !uniqueKeys(udp, 30, 3) {
+		t.Error("expected unique UDP ports")
+	}
+}
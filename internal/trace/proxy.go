@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ConnectProbe is the outcome of one TCP connect to a target through a proxy.
+// TTL-limited probes cannot traverse a proxy, so only the proxy leg and the
+// end-to-end connect time are measured.
+type ConnectProbe struct {
+	Seq        int
+	ProxyRTT   time.Duration // TCP connect time to the proxy itself
+	ConnectRTT time.Duration // Time until the proxy reported the target connected
+	Err        error
+}
+
+// BeyondProxy returns the share of the connect time spent past the proxy.
+func (p ConnectProbe) BeyondProxy() time.Duration {
+	if p.ConnectRTT <= p.ProxyRTT {
+		return 0
+	}
+	return p.ConnectRTT - p.ProxyRTT
+}
+
+// ProxyProber measures TCP connect latency to a target through a SOCKS5 proxy.
+// The target hostname is passed to the proxy unresolved, as applications do.
+type ProxyProber struct {
+	ProxyAddr string        // SOCKS5 proxy host:port
+	Timeout   time.Duration // Per-probe timeout
+}
+
+// NewProxyProber creates a prober for the given SOCKS5 proxy address.
+func NewProxyProber(proxyAddr string, timeout time.Duration) *ProxyProber {
+	return &ProxyProber{ProxyAddr: proxyAddr, Timeout: timeout}
+}
+
+// Probe opens one TCP connection to target (host:port) through the proxy.
+func (p *ProxyProber) Probe(ctx context.Context, seq int, target string) ConnectProbe {
+	result := ConnectProbe{Seq: seq}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	forward := &timingDialer{}
+	dialer, err := proxy.SOCKS5("tcp", p.ProxyAddr, nil, forward)
+	if err != nil {
+		result.Err = fmt.Errorf("invalid proxy: %w", err)
+		return result
+	}
+	cd, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		result.Err = errors.New("proxy dialer does not support contexts")
+		return result
+	}
+
+	start := time.Now()
+	conn, err := cd.DialContext(ctx, "tcp", target)
+	result.ProxyRTT = forward.rtt
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.ConnectRTT = time.Since(start)
+	conn.Close()
+
+	return result
+}
+
+// timingDialer dials the proxy directly and records how long that took.
+type timingDialer struct {
+	rtt time.Duration
+}
+
+func (d *timingDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *timingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var nd net.Dialer
+	start := time.Now()
+	conn, err := nd.DialContext(ctx, network, addr)
+	d.rtt = time.Since(start)
+	return conn, err
+}
+
+// SSHTunnel is a dynamic SOCKS5 forward opened with "ssh -D" through a bastion.
+type SSHTunnel struct {
+	Addr string // Local SOCKS5 listen address
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// CheckSSHDest validates an ssh destination, [user@]host.
+func CheckSSHDest(dest string) error {
+	user, host := "", dest
+	if i := strings.LastIndex(dest, "@"); i >= 0 {
+		user, host = dest[:i], dest[i+1:]
+	}
+	if host == "" {
+		return fmt.Errorf("invalid ssh destination %q: must be [user@]host", dest)
+	}
+	// ssh would take them for options
+	if strings.HasPrefix(host, "-") || strings.HasPrefix(user, "-") {
+		return fmt.Errorf("invalid ssh destination %q: user and host must not start with '-'", dest)
+	}
+	return nil
+}
+
+// StartSSHTunnel runs "ssh -N -D" to dest (user@host) and waits until the
+// local SOCKS5 port accepts connections. The ssh process inherits the
+// terminal so password or host key prompts still work.
+func StartSSHTunnel(ctx context.Context, dest string, timeout time.Duration) (*SSHTunnel, error) {
+	if err := CheckSSHDest(dest); err != nil {
+		return nil, err
+	}
+	addr, err := freeLocalAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "ssh", "-N", "-D", addr, "-o", "ExitOnForwardFailure=yes", "--", dest)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+
+	t := &SSHTunnel{Addr: addr, cmd: cmd, done: make(chan error, 1)}
+	go func() { t.done <- cmd.Wait() }()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond); err == nil {
+			conn.Close()
+			return t, nil
+		}
+		select {
+		case err := <-t.done:
+			return nil, fmt.Errorf("ssh to %s exited before the tunnel was ready: %v", dest, err)
+		case <-ctx.Done():
+			t.Close()
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	t.Close()
+	return nil, fmt.Errorf("timed out waiting for ssh tunnel to %s", dest)
+}
+
+// Close stops the ssh process.
+func (t *SSHTunnel) Close() error {
+	if t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+	<-t.done
+	return nil
+}
+
+// freeLocalAddr returns a loopback address with a currently unused port.
+func freeLocalAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free local port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
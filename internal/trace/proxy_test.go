@@ -0,0 +1,169 @@
+package trace
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSOCKS5 runs a minimal no-auth SOCKS5 server that supports CONNECT.
+func startSOCKS5(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(c)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveSOCKS5(c net.Conn) {
+	defer c.Close()
+	buf := make([]byte, 262)
+
+	// Greeting: VER NMETHODS METHODS...
+	if _, err := io.ReadFull(c, buf[:2]); err != nil {
+		return
+	}
+	io.ReadFull(c, buf[:buf[1]])
+	c.Write([]byte{5, 0})
+
+	// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	if _, err := io.ReadFull(c, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(c, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(c, buf[:1])
+		n := buf[0]
+		io.ReadFull(c, buf[:n])
+		host = string(buf[:n])
+	default:
+		return
+	}
+	io.ReadFull(c, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // connection refused
+		return
+	}
+	upstream.Close()
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+}
+
+func TestProxyProber_ConnectsThroughSOCKS5(t *testing.T) {
+	proxyAddr := startSOCKS5(t)
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			c, err := target.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	p := NewProxyProber(proxyAddr, 2*time.Second).Probe(context.Background(), 1, target.Addr().String())
+
+	if p.Err != nil {
+		t.Fatalf("unexpected error: %v", p.Err)
+	}
+	if p.ProxyRTT <= 0 || p.ConnectRTT < p.ProxyRTT {
+		t.Errorf("expected 0 < proxy RTT <= connect RTT, got %v / %v", p.ProxyRTT, p.ConnectRTT)
+	}
+}
+
+func TestProxyProber_ReportsRefusedTarget(t *testing.T) {
+	proxyAddr := startSOCKS5(t)
+
+	// Reserve a port and close it so nothing is listening
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := l.Addr().String()
+	l.Close()
+
+	p := NewProxyProber(proxyAddr, 2*time.Second).Probe(context.Background(), 1, closed)
+
+	if p.Err == nil {
+		t.Fatal("expected error for refused target")
+	}
+	if p.ProxyRTT <= 0 {
+		t.Error("proxy leg should still be timed")
+	}
+}
+
+func TestProxyProber_UnreachableProxy(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := l.Addr().String()
+	l.Close()
+
+	p := NewProxyProber(closed, time.Second).Probe(context.Background(), 1, "example.com:443")
+
+	if p.Err == nil {
+		t.Fatal("expected error when proxy is down")
+	}
+}
+
+func TestConnectProbe_BeyondProxy(t *testing.T) {
+	p := ConnectProbe{ProxyRTT: 2 * time.Millisecond, ConnectRTT: 30 * time.Millisecond}
+	if p.BeyondProxy() != 28*time.Millisecond {
+		t.Errorf("expected 28ms, got %v", p.BeyondProxy())
+	}
+	if (ConnectProbe{ProxyRTT: 5 * time.Millisecond}).BeyondProxy() != 0 {
+		t.Error("failed probe should have no time beyond proxy")
+	}
+}
+
+func TestCheckSSHDest(t *testing.T) {
+	tests := []struct {
+		dest    string
+		wantErr bool
+	}{
+		{"bastion.example.com", false},
+		{"ops@bastion.example.com", false},
+		{"ops@10.0.0.1", false},
+		{"", true},
+		{"ops@", true},
+		{"-oProxyCommand=id", true},
+		{"-oProxyCommand=id@bastion", true},
+		{"ops@-oProxyCommand=id", true},
+	}
+	for _, tt := range tests {
+		if err := CheckSSHDest(tt.dest); (err != nil) != tt.wantErr {
+			t.Errorf("CheckSSHDest(%q) error = %v, wantErr %v", tt.dest, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStartSSHTunnel_RejectsOptionDest(t *testing.T) {
+	_, err := StartSSHTunnel(context.Background(), "-oProxyCommand=id", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "must not start with '-'") {
+		t.Errorf("expected the destination to be rejected, got %v", err)
+	}
+}
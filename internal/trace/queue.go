@@ -0,0 +1,213 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// DefaultQueueProbeSize is the size of the large probes of a queueing test,
+// below the 1500 byte Ethernet MTU with room for tunnel overhead.
+const DefaultQueueProbeSize = 1400
+
+// minQueueStep is the smallest inflation step reported as a bottleneck;
+// anything below is within the jitter of router control planes.
+const minQueueStep = time.Millisecond
+
+// HopQueueing holds the RTTs one hop answered small and large probes with.
+// Large probes take longer to serialize and queue behind more bytes, so the
+// gap between the two grows at the links where a buffer fills or a policer
+// drops.
+type HopQueueing struct {
+	TTL       int
+	IP        net.IP          // First address that answered
+	Small     []time.Duration // RTTs of the answered small probes
+	Large     []time.Duration // RTTs of the answered large probes
+	SmallSent int
+	LargeSent int
+	Step      time.Duration // Inflation added since the previous answering hop
+}
+
+// SmallMedian returns the median RTT of the small probes (0 when none answered).
+func (q *HopQueueing) SmallMedian() time.Duration {
+	return medianRTT(q.Small)
+}
+
+// LargeMedian returns the median RTT of the large probes (0 when none answered).
+func (q *HopQueueing) LargeMedian() time.Duration {
+	return medianRTT(q.Large)
+}
+
+// Inflation returns how much longer the large probes took than the small
+// ones, or 0 when either size went unanswered.
+func (q *HopQueueing) Inflation() time.Duration {
+	if len(q.Small) == 0 || len(q.Large) == 0 {
+		return 0
+	}
+	return q.LargeMedian() - q.SmallMedian()
+}
+
+// SmallLoss returns the percentage of small probes that went unanswered.
+func (q *HopQueueing) SmallLoss() float64 {
+	return lossPercent(q.SmallSent, len(q.Small))
+}
+
+// LargeLoss returns the percentage of large probes that went unanswered.
+func (q *HopQueueing) LargeLoss() float64 {
+	return lossPercent(q.LargeSent, len(q.Large))
+}
+
+// Answered reports whether the hop answered probes of both sizes.
+func (q *HopQueueing) Answered() bool {
+	return len(q.Small) > 0 && len(q.Large) > 0
+}
+
+// QueueProber measures per-hop queueing by alternating traces of small and
+// large probes to the same target.
+type QueueProber struct {
+	small Tracer
+	large Tracer
+}
+
+// NewQueueProber creates a prober whose small probes are cfg.ProbeSize bytes
+// and whose large probes are largeSize bytes.
+func NewQueueProber(cfg *Config, largeSize int) (*QueueProber, error) {
+	if cfg.Protocol == ProtocolTCP {
+		return nil, fmt.Errorf("queueing test requires icmp or udp probes")
+	}
+	if largeSize <= cfg.ProbeSize {
+		return nil, fmt.Errorf("large probe size %d must exceed the small probe size %d", largeSize, cfg.ProbeSize)
+	}
+
+	small, err := NewLocalTracer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	largeCfg := *cfg
+	largeCfg.ProbeSize = largeSize
+	large, err := NewLocalTracer(&largeCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &QueueProber{small: small, large: large}, nil
+}
+
+// Run traces target rounds times with each probe size and returns the hops
+// that answered, ordered by TTL. The size that goes first alternates every
+// round so neither benefits from a path warmed up by the other. onRound, if
+// non-nil, is called after each round.
+func (p *QueueProber) Run(ctx context.Context, target net.IP, rounds int, onRound func(round int)) ([]*HopQueueing, error) {
+	byTTL := make(map[int]*HopQueueing)
+	for round := 1; round <= rounds; round++ {
+		order := []bool{false, true}
+		if round%2 == 0 {
+			order = []bool{true, false}
+		}
+		for _, large := range order {
+			tracer := p.small
+			if large {
+				tracer = p.large
+			}
+			tr, err := tracer.Trace(ctx, target, nil)
+			if err != nil {
+				if ctx.Err() != nil {
+					return SummarizeQueueing(byTTL), nil
+				}
+				return nil, err
+			}
+			recordQueueing(byTTL, tr, large)
+		}
+		if onRound != nil {
+			onRound(round)
+		}
+	}
+	return SummarizeQueueing(byTTL), nil
+}
+
+// recordQueueing adds the probes of one trace to the per-TTL samples.
+func recordQueueing(byTTL map[int]*HopQueueing, tr *hop.TraceResult, large bool) {
+	for _, h := range tr.Hops {
+		q := byTTL[h.TTL]
+		if q == nil {
+			q = &HopQueueing{TTL: h.TTL}
+			byTTL[h.TTL] = q
+		}
+		for _, probe := range h.Probes {
+			if large {
+				q.LargeSent++
+			} else {
+				q.SmallSent++
+			}
+			if probe.Timeout {
+				continue
+			}
+			if q.IP == nil {
+				q.IP = probe.IP
+			}
+			if large {
+				q.Large = append(q.Large, probe.RTT)
+			} else {
+				q.Small = append(q.Small, probe.RTT)
+			}
+		}
+	}
+}
+
+// SummarizeQueueing orders the samples by TTL and fills in the inflation step
+// of every hop relative to the previous hop that answered both sizes.
+func SummarizeQueueing(byTTL map[int]*HopQueueing) []*HopQueueing {
+	hops := make([]*HopQueueing, 0, len(byTTL))
+	for _, q := range byTTL {
+		hops = append(hops, q)
+	}
+	slices.SortFunc(hops, func(a, b *HopQueueing) int { return a.TTL - b.TTL })
+
+	var prev time.Duration
+	for _, q := range hops {
+		if !q.Answered() {
+			continue
+		}
+		q.Step = q.Inflation() - prev
+		prev = q.Inflation()
+	}
+	return hops
+}
+
+// QueueBottleneck returns the hop whose inflation step is the largest, or nil
+// when no hop adds at least a millisecond. The link into that hop is where
+// large packets start to queue or get policed.
+func QueueBottleneck(hops []*HopQueueing) *HopQueueing {
+	var worst *HopQueueing
+	for _, q := range hops {
+		if q.Answered() && q.Step >= minQueueStep && (worst == nil || q.Step > worst.Step) {
+			worst = q
+		}
+	}
+	return worst
+}
+
+// medianRTT returns the median of rtts without reordering it.
+func medianRTT(rtts []time.Duration) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(rtts)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// lossPercent returns the share of sent probes that got no answer.
+func lossPercent(sent, answered int) float64 {
+	if sent == 0 {
+		return 0
+	}
+	return float64(sent-answered) / float64(sent) * 100
+}
@@ -0,0 +1,155 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// fakeTracer returns the same result on every trace and counts the calls.
+type fakeTracer struct {
+	result *hop.TraceResult
+	calls  int
+}
+
+func (f *fakeTracer) Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+	f.calls++
+	return f.result, nil
+}
+
+// queueTrace builds a trace whose hop i answers from 10.0.0.i with rtts[i-1]
+// (0 = timeout).
+func queueTrace(rtts ...time.Duration) *hop.TraceResult {
+	tr := hop.NewTraceResult("target", "")
+	for i, rtt := range rtts {
+		h := hop.NewHop(i + 1)
+		if rtt == 0 {
+			h.AddTimeout()
+		} else {
+			h.AddProbe(net.IPv4(10, 0, 0, byte(i+1)), rtt)
+		}
+		tr.AddHop(h)
+	}
+	return tr
+}
+
+func TestMedianRTT(t *testing.T) {
+	tests := []struct {
+		name string
+		rtts []time.Duration
+		want time.Duration
+	}{
+		{"empty", nil, 0},
+		{"odd", []time.Duration{30, 10, 20}, 20},
+		{"even", []time.Duration{40, 10, 20, 30}, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := medianRTT(tt.rtts); got != tt.want {
+				t.Errorf("medianRTT(%v) = %v, want %v", tt.rtts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeQueueing_StepsAndBottleneck(t *testing.T) {
+	ms := time.Millisecond
+	byTTL := make(map[int]*HopQueueing)
+	recordQueueing(byTTL, queueTrace(1*ms, 5*ms, 0, 12*ms), false)
+	recordQueueing(byTTL, queueTrace(1*ms+200*time.Microsecond, 6*ms, 0, 40*ms), true)
+
+	hops := SummarizeQueueing(byTTL)
+	if len(hops) != 4 {
+		t.Fatalf("got %d hops, want 4", len(hops))
+	}
+	if hops[2].Answered() {
+		t.Error("hop 3 timed out but reports answers")
+	}
+	if got := hops[1].Step; got != 800*time.Microsecond {
+		t.Errorf("hop 2 step = %v, want 800µs", got)
+	}
+	// Hop 4 steps from hop 2, skipping the silent hop 3
+	if got := hops[3].Step; got != 27*ms {
+		t.Errorf("hop 4 step = %v, want 27ms", got)
+	}
+
+	worst := QueueBottleneck(hops)
+	if worst == nil || worst.TTL != 4 {
+		t.Fatalf("bottleneck = %+v, want hop 4", worst)
+	}
+	if !worst.IP.Equal(net.IPv4(10, 0, 0, 4)) {
+		t.Errorf("bottleneck IP = %v, want 10.0.0.4", worst.IP)
+	}
+}
+
+func TestQueueBottleneck_NoneBelowThreshold(t *testing.T) {
+	byTTL := make(map[int]*HopQueueing)
+	recordQueueing(byTTL, queueTrace(time.Millisecond, 2*time.Millisecond), false)
+	recordQueueing(byTTL, queueTrace(time.Millisecond+100*time.Microsecond, 2*time.Millisecond+300*time.Microsecond), true)
+
+	if worst := QueueBottleneck(SummarizeQueueing(byTTL)); worst != nil {
+		t.Errorf("bottleneck = hop %d, want none", worst.TTL)
+	}
+}
+
+func TestHopQueueing_Loss(t *testing.T) {
+	byTTL := make(map[int]*HopQueueing)
+	recordQueueing(byTTL, queueTrace(time.Millisecond), false)
+	recordQueueing(byTTL, queueTrace(time.Millisecond), false)
+	recordQueueing(byTTL, queueTrace(2*time.Millisecond), true)
+	recordQueueing(byTTL, queueTrace(0), true)
+
+	q := byTTL[1]
+	if got := q.SmallLoss(); got != 0 {
+		t.Errorf("SmallLoss() = %v, want 0", got)
+	}
+	if got := q.LargeLoss(); got != 50 {
+		t.Errorf("LargeLoss() = %v, want 50", got)
+	}
+	if got := q.Inflation(); got != time.Millisecond {
+		t.Errorf("Inflation() = %v, want 1ms", got)
+	}
+}
+
+func TestQueueProber_Run(t *testing.T) {
+	small := &fakeTracer{result: queueTrace(time.Millisecond)}
+	large := &fakeTracer{result: queueTrace(3 * time.Millisecond)}
+	p := &QueueProber{small: small, large: large}
+
+	var rounds []int
+	hops, err := p.Run(context.Background(), net.IPv4(10, 0, 0, 1), 3, func(round int) {
+		rounds = append(rounds, round)
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if small.calls != 3 || large.calls != 3 {
+		t.Errorf("traces = %d small, %d large, want 3 each", small.calls, large.calls)
+	}
+	if len(rounds) != 3 {
+		t.Errorf("onRound called %d times, want 3", len(rounds))
+	}
+	if len(hops) != 1 || hops[0].SmallSent != 3 || hops[0].LargeSent != 3 {
+		t.Fatalf("hops = %+v, want one hop with 3 probes of each size", hops)
+	}
+	if got := hops[0].Inflation(); got != 2*time.Millisecond {
+		t.Errorf("Inflation() = %v, want 2ms", got)
+	}
+}
+
+func TestNewQueueProber_Validation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ProbeSize = 64
+
+	tcp := *cfg
+	tcp.Protocol = ProtocolTCP
+	if _, err := NewQueueProber(&tcp, DefaultQueueProbeSize); err == nil {
+		t.Error("expected error for tcp probes")
+	}
+	if _, err := NewQueueProber(cfg, 64); err == nil {
+		t.Error("expected error when large probes are not larger")
+	}
+}
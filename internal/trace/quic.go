@@ -0,0 +1,441 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/net/icmp"
+)
+
+// QUICPort is the port of HTTP/3 servers, where QUIC probes go by default.
+const QUICPort = 443
+
+// quicConnIDLen is the length of the connection IDs of QUIC probes.
+const quicConnIDLen = 8
+
+// QUICTracer implements traceroute using UDP datagrams carrying QUIC Initial
+// packets, so middleboxes treat the probes like the start of an HTTP/3
+// connection. Every probe goes to the same port, so replies are
+// demultiplexed by the source port of the probe, as for TCP. A QUIC server
+// answering the Initial on the probe socket means the target was reached.
+type QUICTracer struct {
+	config *Config
+	plain  bool             // Send plain UDP datagrams of the same size instead, as a baseline
+	srcIP  net.IP           // Local source address, resolved only when capturing
+	stamps timestampTracker // How the probes of the current trace were timed
+}
+
+// NewQUICTracer creates a new QUIC tracer with the given configuration.
+func NewQUICTracer(cfg *Config) *QUICTracer {
+	return &QUICTracer{config: cfg}
+}
+
+// newPlainUDPTracer creates a tracer sending datagrams the size of QUIC
+// probes to the same port but without a QUIC packet in them.
+func newPlainUDPTracer(cfg *Config) *QUICTracer {
+	return &QUICTracer{config: cfg, plain: true}
+}
+
+// Trace performs a QUIC traceroute to the target IP.
+// Supports both IPv4 and IPv6 targets.
+func (t *QUICTracer) Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+	if err := t.config.IPv6.check(target); err != nil {
+		return nil, err
+	}
+
+	result := hop.NewTraceResult(target.String(), target.String())
+	result.Protocol = string(ProtocolQUIC)
+	if t.plain {
+		result.Protocol = string(ProtocolUDP)
+	}
+	result.StartTime = time.Now()
+	t.stamps.reset(false)
+	log, callback := startTrace(ctx, t.config, target, callback)
+
+	if t.config.Capture != nil {
+		t.srcIP = captureSourceIP(t.config.NetNS, target)
+	}
+
+	// Spread TTLs across concurrent workers when sharding is enabled
+	if t.config.Shards > 1 {
+		err := traceSharded(ctx, t.config.NetNS, target, t.config.Shards, t.config.MaxHops, t.replyReader(target), t.probeHop, callback, result)
+		result.EndTime = time.Now()
+		result.TimestampSource = t.stamps.source()
+		finishTrace(log, result, err)
+		return result, err
+	}
+
+	// Open raw socket for receiving ICMP responses based on IP version
+	icmpConn, err := listenICMP(t.config.NetNS, target)
+	if err != nil {
+		return nil, err
+	}
+	defer icmpConn.Close()
+
+	// A single receive loop hands each reply to the probe it answers
+	d := newDemux(icmpConn, t.replyReader(target)(icmpConn))
+	defer d.close()
+
+	for ttl := 1; ttl <= t.config.MaxHops; ttl++ {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		h, reached := t.probeHop(ctx, d, target, ttl)
+
+		result.AddHop(h)
+		if callback != nil {
+			callback(h)
+		}
+
+		if reached {
+			result.ReachedTarget = true
+			break
+		}
+	}
+
+	result.EndTime = time.Now()
+	result.TimestampSource = t.stamps.source()
+	finishTrace(log, result, nil)
+	return result, nil
+}
+
+// probeHop sends all probes for a single TTL and returns the resulting hop.
+func (t *QUICTracer) probeHop(ctx context.Context, d *demux, target net.IP, ttl int) (*hop.Hop, bool) {
+	h := hop.NewHop(ttl)
+	reached := false
+	if err := nextTTL(ctx, t.config.Scheduler, ttl); err != nil {
+		return h, false
+	}
+
+	for i := 0; i < t.config.PacketsPerHop; i++ {
+		pr, err := scheduleProbe(ctx, t.config.Scheduler, func() (*probeResult, error) {
+			return t.sendProbe(d, target, ttl)
+		})
+		if err != nil {
+			h.AddTimeout()
+			continue
+		}
+
+		// Set MTU if discovered (may come from EMSGSIZE with nil IP)
+		if pr.MTU > 0 && h.MTU == 0 {
+			h.MTU = pr.MTU
+		}
+
+		// EMSGSIZE returns nil IP - record as timeout
+		if pr.IP == nil {
+			h.AddTimeout()
+			continue
+		}
+
+		probe := hop.Probe{IP: pr.IP, RTT: pr.RTT, ResponseTTL: pr.ResponseTTL, IPID: pr.IPID, ICMPType: pr.ICMPType, ICMPCode: pr.ICMPCode, OriginalTTL: pr.OriginalTTL, TransportInfo: pr.TransportInfo}
+		h.Probes = append(h.Probes, probe)
+
+		// Set MPLS labels if discovered (first probe with labels wins)
+		if len(pr.MPLS) > 0 && len(h.MPLS) == 0 {
+			h.SetMPLS(pr.MPLS)
+		}
+
+		// Set interface info if discovered
+		if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
+			h.InterfaceInfo = pr.InterfaceInfo
+		}
+		if pr.SegmentRouting != nil && h.SegmentRouting == nil {
+			h.SegmentRouting = pr.SegmentRouting
+		}
+
+		if pr.IP.Equal(target) {
+			reached = true
+		}
+	}
+
+	// NAT detection: IP-based (Tier 1) and TTL-based (Tier 2) only.
+	// See icmp.go comment for why IP ID analysis (Tier 3) is not used.
+	if t.config.DetectNAT {
+		for _, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				continue
+			}
+			if DetectNATFromIP(p.IP, ttl) {
+				h.NAT = true
+				break
+			}
+			if p.ResponseTTL > 0 && DetectNATFromTTL(ttl, p.ResponseTTL) {
+				h.NAT = true
+				break
+			}
+		}
+	}
+
+	return h, reached
+}
+
+// sendProbe sends a single QUIC probe and waits for a datagram from the
+// target on the probe socket or for the ICMP response d hands to its source
+// port. Supports both IPv4 and IPv6 targets.
+func (t *QUICTracer) sendProbe(d *demux, target net.IP, ttl int) (*probeResult, error) {
+	port := t.config.Port
+
+	// Create UDP socket with specific TTL/Hop Limit
+	domain := SocketDomain(target)
+	fd, err := createSocketIn(t.config.NetNS, domain, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create UDP socket: %w", err)
+	}
+	defer closeSocket(fd)
+
+	level := ProtocolLevel(target)
+	opt := TTLSocketOption(target)
+	if err := setSocketTTL(fd, level, opt, ttl); err != nil {
+		return nil, fmt.Errorf("failed to set TTL/hop limit: %w", err)
+	}
+
+	// Set Don't Fragment bit for MTU discovery
+	if t.config.DiscoverMTU {
+		if err := setDontFragmentFor(fd, target); err != nil {
+			return nil, fmt.Errorf("failed to set DF bit: %w", err)
+		}
+	}
+
+	if err := t.config.IPv6.apply(fd, target); err != nil {
+		return nil, err
+	}
+
+	// The target's answer is read without blocking between ICMP polls
+	if err := setSocketNonBlocking(fd); err != nil {
+		return nil, fmt.Errorf("failed to set non-blocking: %w", err)
+	}
+
+	// Bind before sending: replies are demultiplexed by source port, so
+	// the probe must be registered under it before the datagram goes out
+	if err := bindSocket(fd, target); err != nil {
+		return nil, fmt.Errorf("failed to bind UDP socket: %w", err)
+	}
+	srcPort := socketLocalPort(fd)
+	ch := d.expect(srcPort)
+	defer d.unregister(srcPort)
+
+	payload, err := t.buildPayload(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the kernel's send timestamp to the time read before sending
+	kernelSend := enableTxTimestamps(fd)
+	start := time.Now()
+
+	if err := sendProbeTo(fd, payload, target, port, t.config.IPv6); err != nil {
+		// EMSGSIZE means packet exceeds local interface MTU with DF bit set
+		if t.config.DiscoverMTU && isEMSGSIZE(err) {
+			return &probeResult{MTU: StandardMTU}, nil
+		}
+		return nil, fmt.Errorf("failed to send UDP: %w", err)
+	}
+	if kernelSend {
+		var sent time.Time
+		if sent, kernelSend = txTimestamp(fd); kernelSend {
+			start = sent
+		}
+	}
+	if !kernelSend {
+		t.stamps.userSend.Store(true)
+	}
+	if t.config.Capture != nil {
+		seg := buildUDPSegment(t.srcIP, target, srcPort, port, payload)
+		capturePacket(t.config.Capture, start, t.srcIP, target, ipProtoUDP, ttl, seg)
+	}
+
+	deadline := start.Add(t.config.Timeout)
+
+	// Wait for the ICMP response in short intervals so we can poll the UDP
+	// socket in between, as the TCP tracer does for SYN-ACKs
+	const icmpPollInterval = 5 * time.Millisecond
+	buf := make([]byte, 1500)
+
+	for {
+		if t.targetAnswered(fd, buf, target) {
+			return &probeResult{IP: target, RTT: time.Since(start)}, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, &timeoutError{}
+		}
+
+		icmpDeadline := time.Now().Add(icmpPollInterval)
+		if icmpDeadline.After(deadline) {
+			icmpDeadline = deadline
+		}
+		pr, end, err := d.wait(ch, icmpDeadline)
+		if err == nil {
+			pr.RTT = end.Sub(start)
+			return pr, nil
+		}
+		if !isTimeout(err) {
+			return nil, err
+		}
+	}
+}
+
+// targetAnswered reports whether a datagram from target is waiting on the
+// probe socket fd: a QUIC server's Initial, Retry or Version Negotiation.
+func (t *QUICTracer) targetAnswered(fd socketFD, buf []byte, target net.IP) bool {
+	for {
+		n, from, err := syscall.Recvfrom(int(fd), buf, 0)
+		if err != nil {
+			return false
+		}
+		var ip net.IP
+		switch a := from.(type) {
+		case *syscall.SockaddrInet4:
+			ip = net.IP(a.Addr[:])
+		case *syscall.SockaddrInet6:
+			ip = net.IP(a.Addr[:])
+		}
+		if n > 0 && ip.Equal(target) {
+			return true
+		}
+	}
+}
+
+// buildPayload returns the datagram of a probe to target: a QUIC Initial
+// padded to the probe size, or a plain payload of the same size.
+func (t *QUICTracer) buildPayload(target net.IP) ([]byte, error) {
+	size := QUICMinDatagram
+	if overhead := IPHeaderSize(target) + 8; t.config.ProbeSize-overhead > size {
+		size = t.config.ProbeSize - overhead
+	}
+
+	if t.plain {
+		payload := make([]byte, size)
+		copy(payload, fmt.Sprintf("gtr-%d", time.Now().UnixNano()))
+		return payload, nil
+	}
+
+	ids := make([]byte, 2*quicConnIDLen)
+	if _, err := rand.Read(ids); err != nil {
+		return nil, err
+	}
+	return BuildQUICInitial(ids[:quicConnIDLen], ids[quicConnIDLen:], t.config.ServerName, size)
+}
+
+// replyReader returns a reader of the replies to probes to target arriving on
+// an ICMP listener, for a demux.
+func (t *QUICTracer) replyReader(target net.IP) func(*icmp.PacketConn) replyReader {
+	return func(icmpConn *icmp.PacketConn) replyReader {
+		reply := make([]byte, 1500)
+		return func(deadline time.Time) (*probeResult, int, time.Time, error) {
+			return t.readReply(icmpConn, target, reply, deadline)
+		}
+	}
+}
+
+// readReply reads ICMP messages until one answers a QUIC probe to target or
+// deadline passes. It returns the reply without its RTT, the source port of
+// the probe it answers and when it arrived.
+func (t *QUICTracer) readReply(icmpConn *icmp.PacketConn, target net.IP, reply []byte, deadline time.Time) (*probeResult, int, time.Time, error) {
+	if err := icmpConn.SetReadDeadline(deadline); err != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	// Protocol number for parsing ICMP messages
+	protoNum := ICMPProtocolNum(target)
+	ipHdrSize := IPHeaderSize(target)
+	ours := func(data []byte) bool {
+		port, ok := quotedDstPort(data, ipHdrSize)
+		return ok && port == t.config.Port && quotedDstIs(data, target)
+	}
+
+	for {
+		m, err := readICMP(icmpConn, target, reply)
+		if err != nil {
+			return nil, 0, time.Time{}, err
+		}
+		t.stamps.received(m)
+		n, peerIP, end := m.n, m.peer, m.at
+
+		// Response TTL for NAT detection (IPv4 only)
+		var responseTTL int
+		if t.config.DetectNAT {
+			responseTTL = m.ttl
+		}
+
+		// Parse the ICMP response
+		rm, err := icmp.ParseMessage(protoNum, reply[:n])
+		if err != nil {
+			continue
+		}
+
+		// Probes steered with SRv6 are quoted behind a Segment Routing Header
+		sr := unwrapSRv6(rm, target)
+
+		// Check for Time Exceeded (intermediate hop)
+		if isTimeExceeded(rm.Type, target) {
+			if body, ok := rm.Body.(*icmp.TimeExceeded); ok && ours(body.Data) {
+				var mplsLabels []hop.MPLSLabel
+				var ifInfo *hop.InterfaceInfo
+				if n > 8 {
+					if ext := ExtractICMPExtensionsFromData(reply[8:n]); ext != nil {
+						mplsLabels = ext.MPLS
+						ifInfo = ext.InterfaceInfo
+						if sr == nil {
+							sr = ext.SegmentRouting
+						}
+					}
+				}
+				ipid := ExtractIPID(body.Data)
+				origTTL := ExtractOriginalTTL(body.Data)
+				var transportInfo *hop.TransportInfo
+				if t.config.Decode {
+					transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
+				}
+				captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+				return &probeResult{IP: peerIP, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, SegmentRouting: sr, TransportInfo: transportInfo}, quotedSrcPort(body.Data, ipHdrSize), end, nil
+			}
+		}
+
+		// Check for Destination Unreachable (no QUIC server, or filtered)
+		if isDestUnreachable(rm.Type, target) {
+			if body, ok := rm.Body.(*icmp.DstUnreach); ok && ours(body.Data) {
+				// Check for Fragmentation Needed (Code 4) with MTU discovery
+				var mtu int
+				if rm.Code == 4 && t.config.DiscoverMTU && n >= 8 {
+					mtu = int(reply[6])<<8 | int(reply[7])
+					if mtu < MinMTU {
+						mtu = 0
+					}
+				}
+				ipid := ExtractIPID(body.Data)
+				origTTL := ExtractOriginalTTL(body.Data)
+				var transportInfo *hop.TransportInfo
+				if t.config.Decode {
+					transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
+				}
+				captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+				return &probeResult{IP: peerIP, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, SegmentRouting: sr, TransportInfo: transportInfo}, quotedSrcPort(body.Data, ipHdrSize), end, nil
+			}
+		}
+
+		// Check for Packet Too Big (IPv6 routers never fragment)
+		if body, ok := rm.Body.(*icmp.PacketTooBig); ok && IsIPv6(target) && ours(body.Data) {
+			pr := packetTooBigResult(body, peerIP, t.config.DiscoverMTU)
+			pr.SegmentRouting = sr
+			if t.config.Decode {
+				pr.TransportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
+			}
+			captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+			return pr, quotedSrcPort(body.Data, ipHdrSize), end, nil
+		}
+
+		// Check deadline
+		if time.Now().After(deadline) {
+			return nil, 0, time.Time{}, &net.OpError{Op: "read", Err: &timeoutError{}}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// HopQUIC holds how one hop answered QUIC probes and plain UDP probes of the
+// same size to the same port. A hop that answers plain UDP but not QUIC is
+// past a middlebox that drops QUIC.
+type HopQUIC struct {
+	TTL          int
+	IP           net.IP // First address that answered
+	QUICSent     int
+	QUICAnswered int
+	UDPSent      int
+	UDPAnswered  int
+}
+
+// QUICLoss returns the percentage of QUIC probes that went unanswered.
+func (q *HopQUIC) QUICLoss() float64 {
+	return lossPercent(q.QUICSent, q.QUICAnswered)
+}
+
+// UDPLoss returns the percentage of plain UDP probes that went unanswered.
+func (q *HopQUIC) UDPLoss() float64 {
+	return lossPercent(q.UDPSent, q.UDPAnswered)
+}
+
+// QUICComparer locates where QUIC gets dropped by alternating traces of QUIC
+// probes and plain UDP probes to the same target and port.
+type QUICComparer struct {
+	quic  Tracer
+	plain Tracer
+}
+
+// NewQUICComparer creates a comparer whose QUIC and plain UDP probes go to
+// cfg.Port. cfg.Protocol must be quic.
+func NewQUICComparer(cfg *Config) (*QUICComparer, error) {
+	if cfg.Protocol != ProtocolQUIC {
+		return nil, fmt.Errorf("QUIC comparison requires quic probes")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &QUICComparer{quic: NewQUICTracer(cfg), plain: newPlainUDPTracer(cfg)}, nil
+}
+
+// Run traces target rounds times with each kind of probe and returns every
+// hop probed, ordered by TTL. The kind that goes first alternates every
+// round so neither benefits from state the other set up on the path, such
+// as a firewall flow entry. onRound, if non-nil, is called after each round.
+func (c *QUICComparer) Run(ctx context.Context, target net.IP, rounds int, onRound func(round int)) ([]*HopQUIC, error) {
+	byTTL := make(map[int]*HopQUIC)
+	for round := 1; round <= rounds; round++ {
+		order := []bool{true, false}
+		if round%2 == 0 {
+			order = []bool{false, true}
+		}
+		for _, quic := range order {
+			tracer := c.plain
+			if quic {
+				tracer = c.quic
+			}
+			tr, err := tracer.Trace(ctx, target, nil)
+			if err != nil {
+				if ctx.Err() != nil {
+					return sortQUICHops(byTTL), nil
+				}
+				return nil, err
+			}
+			recordQUIC(byTTL, tr, quic)
+		}
+		if onRound != nil {
+			onRound(round)
+		}
+	}
+	return sortQUICHops(byTTL), nil
+}
+
+// recordQUIC adds the probes of one trace to the per-TTL counts.
+func recordQUIC(byTTL map[int]*HopQUIC, tr *hop.TraceResult, quic bool) {
+	for _, h := range tr.Hops {
+		q := byTTL[h.TTL]
+		if q == nil {
+			q = &HopQUIC{TTL: h.TTL}
+			byTTL[h.TTL] = q
+		}
+		for _, probe := range h.Probes {
+			if quic {
+				q.QUICSent++
+			} else {
+				q.UDPSent++
+			}
+			if probe.Timeout {
+				continue
+			}
+			if q.IP == nil {
+				q.IP = probe.IP
+			}
+			if quic {
+				q.QUICAnswered++
+			} else {
+				q.UDPAnswered++
+			}
+		}
+	}
+}
+
+// sortQUICHops orders the counts by TTL.
+func sortQUICHops(byTTL map[int]*HopQUIC) []*HopQUIC {
+	hops := make([]*HopQUIC, 0, len(byTTL))
+	for _, q := range byTTL {
+		hops = append(hops, q)
+	}
+	slices.SortFunc(hops, func(a, b *HopQUIC) int { return a.TTL - b.TTL })
+	return hops
+}
+
+// QUICDropHop returns the first hop past the last one that answered QUIC
+// probes that still answered plain UDP, or nil when QUIC got as far as
+// plain UDP. The link into that hop, or the hop itself, drops QUIC.
+func QUICDropHop(hops []*HopQUIC) *HopQUIC {
+	lastQUIC := 0
+	for _, q := range hops {
+		if q.QUICAnswered > 0 {
+			lastQUIC = q.TTL
+		}
+	}
+	for _, q := range hops {
+		if q.TTL > lastQUIC && q.UDPAnswered > 0 {
+			return q
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package trace
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// quicTestTrace builds a trace whose hops up to answered reply from 10.0.0.TTL
+// and whose later hops up to maxTTL time out.
+func quicTestTrace(answered, maxTTL int) *hop.TraceResult {
+	tr := hop.NewTraceResult("t", "192.0.2.1")
+	for ttl := 1; ttl <= maxTTL; ttl++ {
+		h := hop.NewHop(ttl)
+		if ttl <= answered {
+			h.Probes = append(h.Probes, hop.Probe{IP: net.IPv4(10, 0, 0, byte(ttl)), RTT: time.Millisecond})
+		} else {
+			h.AddTimeout()
+		}
+		tr.AddHop(h)
+	}
+	return tr
+}
+
+func TestQUICDropHop_QUICFilteredMidPath(t *testing.T) {
+	byTTL := make(map[int]*HopQUIC)
+	recordQUIC(byTTL, quicTestTrace(3, 6), true)
+	recordQUIC(byTTL, quicTestTrace(6, 6), false)
+	hops := sortQUICHops(byTTL)
+
+	if len(hops) != 6 {
+		t.Fatalf("expected 6 hops, got %d", len(hops))
+	}
+	if hops[3].QUICLoss() != 100 || hops[3].UDPLoss() != 0 {
+		t.Errorf("expected hop 4 to lose QUIC only, got %.0f%%/%.0f%%", hops[3].QUICLoss(), hops[3].UDPLoss())
+	}
+	drop := QUICDropHop(hops)
+	if drop == nil || drop.TTL != 4 {
+		t.Fatalf("expected QUIC dropped from hop 4, got %+v", drop)
+	}
+	if !drop.IP.Equal(net.IPv4(10, 0, 0, 4)) {
+		t.Errorf("expected the address that answered UDP, got %s", drop.IP)
+	}
+}
+
+func TestQUICDropHop_NoFiltering(t *testing.T) {
+	byTTL := make(map[int]*HopQUIC)
+	recordQUIC(byTTL, quicTestTrace(5, 6), true)
+	recordQUIC(byTTL, quicTestTrace(4, 6), false)
+	if drop := QUICDropHop(sortQUICHops(byTTL)); drop != nil {
+		t.Errorf("expected no drop when QUIC gets further than UDP, got hop %d", drop.TTL)
+	}
+}
+
+func TestNewQUICComparer_RequiresQUIC(t *testing.T) {
+	cfg := DefaultConfig()
+	if _, err := NewQUICComparer(cfg); err == nil {
+		t.Error("expected an error for icmp probes")
+	}
+	cfg.Protocol = ProtocolQUIC
+	if _, err := NewQUICComparer(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestQUICTracer_BuildPayload(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Protocol = ProtocolQUIC
+	cfg.ProbeSize = 64
+	target := net.ParseIP("192.0.2.1")
+
+	quic, err := NewQUICTracer(cfg).buildPayload(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := newPlainUDPTracer(cfg).buildPayload(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quic) != QUICMinDatagram || len(plain) != len(quic) {
+		t.Errorf("expected %d byte datagrams for both, got %d and %d", QUICMinDatagram, len(quic), len(plain))
+	}
+	if plain[0]&0x80 != 0 {
+		t.Error("expected the plain payload not to look like a QUIC long header")
+	}
+
+	cfg.ProbeSize = 1500
+	if quic, _ = NewQUICTracer(cfg).buildPayload(target); len(quic) != 1500-28 {
+		t.Errorf("expected the probe size to be honored, got %d bytes", len(quic))
+	}
+}
@@ -0,0 +1,220 @@
+package trace
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net"
+)
+
+// QUICMinDatagram is the size clients pad datagrams carrying an Initial
+// packet to: servers drop smaller ones (RFC 9000, Section 14.1).
+const QUICMinDatagram = 1200
+
+// quicVersion1 is the version of the Initial packets sent (RFC 9000).
+const quicVersion1 = 0x00000001
+
+// quicV1Salt derives the Initial keys of QUIC version 1 from the destination
+// connection ID (RFC 9001, Section 5.2).
+var quicV1Salt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+const (
+	quicPNLen       = 4      // Packet number length, so the header protection sample is always in the payload
+	quicTagLen      = 16     // AES-128-GCM tag
+	quicMaxLen      = 0x3fff // Largest value of the 2-byte Length field
+	quicFrameCrypto = 0x06
+)
+
+// quicKeys are the packet protection keys of one direction.
+type quicKeys struct {
+	key, iv, hp []byte
+}
+
+// quicClientInitialKeys derives the keys protecting client Initial packets
+// sent to dcid. Anyone on the path can derive them, which is what lets
+// middleboxes inspect the ClientHello.
+func quicClientInitialKeys(dcid []byte) (*quicKeys, error) {
+	initial, err := hkdf.Extract(sha256.New, dcid, quicV1Salt)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hkdfExpandLabel(initial, "client in", sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	k := &quicKeys{}
+	if k.key, err = hkdfExpandLabel(secret, "quic key", 16); err != nil {
+		return nil, err
+	}
+	if k.iv, err = hkdfExpandLabel(secret, "quic iv", 12); err != nil {
+		return nil, err
+	}
+	if k.hp, err = hkdfExpandLabel(secret, "quic hp", 16); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// hkdfExpandLabel is the HKDF-Expand-Label function of TLS 1.3 (RFC 8446,
+// Section 7.1) with an empty context.
+func hkdfExpandLabel(secret []byte, label string, length int) ([]byte, error) {
+	full := "tls13 " + label
+	info := []byte{byte(length >> 8), byte(length), byte(len(full))}
+	info = append(info, full...)
+	info = append(info, 0)
+	return hkdf.Expand(sha256.New, secret, string(info), length)
+}
+
+// BuildQUICInitial returns a datagram holding a QUIC version 1 client
+// Initial packet from scid to dcid: a ClientHello offering HTTP/3 to
+// serverName (no SNI when empty or an IP address), padded to size bytes and
+// protected as RFC 9001 requires. Middleboxes that inspect QUIC see a
+// genuine connection attempt, and QUIC servers answer it. Sizes below
+// QUICMinDatagram are raised to it.
+func BuildQUICInitial(dcid, scid []byte, serverName string, size int) ([]byte, error) {
+	hello, err := quicClientHello(scid, serverName)
+	if err != nil {
+		return nil, err
+	}
+	size = max(size, QUICMinDatagram)
+
+	header := []byte{0xc0 | (quicPNLen - 1)} // Long header, Initial
+	header = append(header, byte(quicVersion1>>24), byte(quicVersion1>>16), byte(quicVersion1>>8), byte(quicVersion1))
+	header = append(header, byte(len(dcid)))
+	header = append(header, dcid...)
+	header = append(header, byte(len(scid)))
+	header = append(header, scid...)
+	header = append(header, 0) // No token
+
+	// Length (a 2-byte varint) covers the packet number and the payload
+	payloadLen := size - len(header) - 2 - quicPNLen - quicTagLen
+	length := quicPNLen + payloadLen + quicTagLen
+	if length > quicMaxLen {
+		return nil, fmt.Errorf("QUIC Initial of %d bytes is too large", size)
+	}
+	header = append(header, 0x40|byte(length>>8), byte(length))
+	pnOffset := len(header)
+	header = append(header, make([]byte, quicPNLen)...) // Packet number 0
+
+	// CRYPTO frame with the ClientHello, then PADDING frames (zero bytes)
+	payload := []byte{quicFrameCrypto, 0}
+	payload = appendQUICVarint(payload, uint64(len(hello)))
+	payload = append(payload, hello...)
+	if len(payload) > payloadLen {
+		return nil, fmt.Errorf("ClientHello does not fit a %d byte datagram", size)
+	}
+	payload = append(payload, make([]byte, payloadLen-len(payload))...)
+
+	keys, err := quicClientInitialKeys(dcid)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(keys.key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// The nonce is the IV XORed with packet number 0, the IV itself
+	packet := aead.Seal(header, keys.iv, payload, header)
+
+	// Header protection masks the packet number and the low bits of the
+	// first byte with a sample of the ciphertext
+	hp, err := aes.NewCipher(keys.hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	hp.Encrypt(mask, packet[pnOffset+4:pnOffset+4+aes.BlockSize])
+	packet[0] ^= mask[0] & 0x0f
+	for i := 0; i < quicPNLen; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+	return packet, nil
+}
+
+// quicClientHello returns a TLS 1.3 ClientHello handshake message as sent
+// in a QUIC Initial: TLS_AES_128_GCM_SHA256 with an X25519 key share, ALPN
+// h3 and the transport parameters QUIC requires.
+func quicClientHello(scid []byte, serverName string) ([]byte, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	var ext []byte
+	if serverName != "" && net.ParseIP(serverName) == nil {
+		name := []byte{0} // host_name
+		name = appendUint16(name, len(serverName))
+		name = append(name, serverName...)
+		ext = appendTLSExtension(ext, 0x0000, appendUint16(nil, len(name)), name) // server_name
+	}
+	ext = appendTLSExtension(ext, 0x000a, []byte{0, 2, 0x00, 0x1d})                         // supported_groups: x25519
+	ext = appendTLSExtension(ext, 0x000d, []byte{0, 6, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01}) // signature_algorithms
+	ext = appendTLSExtension(ext, 0x0010, []byte{0, 3, 2, 'h', '3'})                        // application_layer_protocol_negotiation
+	ext = appendTLSExtension(ext, 0x002b, []byte{2, 0x03, 0x04})                            // supported_versions: TLS 1.3
+	share := appendUint16([]byte{0x00, 0x1d}, len(priv.PublicKey().Bytes()))
+	share = append(share, priv.PublicKey().Bytes()...)
+	ext = appendTLSExtension(ext, 0x0033, appendUint16(nil, len(share)), share) // key_share
+
+	// The only transport parameter clients must send: initial_source_connection_id
+	params := appendQUICVarint(nil, 0x0f)
+	params = appendQUICVarint(params, uint64(len(scid)))
+	params = append(params, scid...)
+	ext = appendTLSExtension(ext, 0x0039, params) // quic_transport_parameters
+
+	body := []byte{0x03, 0x03} // legacy_version
+	body = append(body, random...)
+	body = append(body, 0)                // Empty legacy_session_id, as QUIC requires
+	body = append(body, 0, 2, 0x13, 0x01) // cipher_suites: TLS_AES_128_GCM_SHA256
+	body = append(body, 1, 0)             // legacy_compression_methods: null
+	body = appendUint16(body, len(ext))
+	body = append(body, ext...)
+
+	msg := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))} // client_hello
+	return append(msg, body...), nil
+}
+
+// appendTLSExtension appends an extension of type typ made of parts.
+func appendTLSExtension(b []byte, typ int, parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	b = appendUint16(appendUint16(b, typ), n)
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// appendUint16 appends v in network byte order.
+func appendUint16(b []byte, v int) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendQUICVarint appends v as a QUIC variable-length integer
+// (RFC 9000, Section 16), in the shortest encoding up to 4 bytes.
+func appendQUICVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, 0x40|byte(v>>8), byte(v))
+	default:
+		return append(b, 0x80|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
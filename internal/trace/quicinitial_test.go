@@ -0,0 +1,148 @@
+package trace
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func TestQUICClientInitialKeys_RFC9001Vectors(t *testing.T) {
+	// RFC 9001, Appendix A.1
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	keys, err := quicClientInitialKeys(dcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		got  []byte
+		want string
+	}{
+		"key": {keys.key, "1f369613dd76d5467730efcbe3b1a22d"},
+		"iv":  {keys.iv, "fa044b2f42a3fd3b46fb255c"},
+		"hp":  {keys.hp, "9f50449e04a0e810283a1e9933adedd2"},
+	} {
+		if got := hex.EncodeToString(tc.got); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", name, tc.want, got)
+		}
+	}
+}
+
+// openQUICInitial removes the protection of a client Initial built by
+// BuildQUICInitial and returns its decrypted payload.
+func openQUICInitial(t *testing.T, packet, dcid []byte) []byte {
+	t.Helper()
+	keys, err := quicClientInitialKeys(dcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scidLen := int(packet[6+len(dcid)])
+	pnOffset := 6 + len(dcid) + 1 + scidLen + 1 + 2
+
+	hp, _ := aes.NewCipher(keys.hp)
+	mask := make([]byte, aes.BlockSize)
+	hp.Encrypt(mask, packet[pnOffset+4:pnOffset+4+aes.BlockSize])
+	header := append([]byte(nil), packet[:pnOffset+quicPNLen]...)
+	header[0] ^= mask[0] & 0x0f
+	for i := 0; i < quicPNLen; i++ {
+		header[pnOffset+i] ^= mask[1+i]
+	}
+	if header[0] != 0xc3 {
+		t.Fatalf("expected an Initial with a 4-byte packet number, got first byte %#x", header[0])
+	}
+	if !bytes.Equal(header[pnOffset:], make([]byte, quicPNLen)) {
+		t.Fatalf("expected packet number 0, got %x", header[pnOffset:])
+	}
+
+	block, _ := aes.NewCipher(keys.key)
+	aead, _ := cipher.NewGCM(block)
+	payload, err := aead.Open(nil, keys.iv, packet[len(header):], header)
+	if err != nil {
+		t.Fatalf("failed to decrypt the Initial: %v", err)
+	}
+	return payload
+}
+
+func TestBuildQUICInitial(t *testing.T) {
+	dcid := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	scid := []byte{9, 10, 11, 12, 13, 14, 15, 16}
+	packet, err := BuildQUICInitial(dcid, scid, "www.example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) != QUICMinDatagram {
+		t.Errorf("expected a %d byte datagram, got %d", QUICMinDatagram, len(packet))
+	}
+	if packet[0]&0xf0 != 0xc0 {
+		t.Errorf("expected a long header Initial, got first byte %#x", packet[0])
+	}
+	if !bytes.Equal(packet[1:5], []byte{0, 0, 0, 1}) {
+		t.Errorf("expected QUIC version 1, got %x", packet[1:5])
+	}
+	if packet[5] != 8 || !bytes.Equal(packet[6:14], dcid) {
+		t.Errorf("expected the destination connection ID in the header, got %x", packet[5:14])
+	}
+
+	payload := openQUICInitial(t, packet, dcid)
+	if payload[0] != quicFrameCrypto || payload[1] != 0 {
+		t.Fatalf("expected a CRYPTO frame at offset 0, got %x", payload[:2])
+	}
+	hello := payload[4:] // 2-byte varint length
+	if hello[0] != 0x01 {
+		t.Errorf("expected a ClientHello, got handshake type %d", hello[0])
+	}
+	for _, want := range []string{"www.example.com", "h3"} {
+		if !bytes.Contains(hello, []byte(want)) {
+			t.Errorf("expected %q in the ClientHello", want)
+		}
+	}
+	if !bytes.Contains(hello, scid) {
+		t.Error("expected the source connection ID in the transport parameters")
+	}
+}
+
+func TestBuildQUICInitial_NoSNIForAddresses(t *testing.T) {
+	dcid := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	withName, _ := BuildQUICInitial(dcid, dcid, "a.example", 0)
+	withIP, err := BuildQUICInitial(dcid, dcid, "192.0.2.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(openQUICInitial(t, withIP, dcid), []byte("192.0.2.1")) {
+		t.Error("expected no SNI for an IP address")
+	}
+	if !bytes.Contains(openQUICInitial(t, withName, dcid), []byte("a.example")) {
+		t.Error("expected an SNI for a hostname")
+	}
+}
+
+func TestBuildQUICInitial_Sizes(t *testing.T) {
+	dcid := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	packet, err := BuildQUICInitial(dcid, dcid, "", 1400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) != 1400 {
+		t.Errorf("expected 1400 bytes, got %d", len(packet))
+	}
+	if _, err := BuildQUICInitial(dcid, dcid, "", 20000); err == nil {
+		t.Error("expected an error for a datagram too large for the length field")
+	}
+}
+
+func TestAppendQUICVarint(t *testing.T) {
+	tests := []struct {
+		v    uint64
+		want string
+	}{
+		{37, "25"},
+		{15293, "7bbd"},
+		{494878333, "9d7f3e7d"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(appendQUICVarint(nil, tt.v)); got != tt.want {
+			t.Errorf("appendQUICVarint(%d) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
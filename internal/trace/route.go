@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// referenceIPv4 and referenceIPv6 are documentation addresses no specific
+// route usually covers: the route the kernel picks for them is the default
+// route.
+var (
+	referenceIPv4 = net.ParseIP("203.0.113.1")
+	referenceIPv6 = net.ParseIP("2001:db8::1")
+)
+
+// vpnInterfacePrefixes are the name prefixes of tunnel interfaces set up by
+// VPN clients and overlays.
+var vpnInterfacePrefixes = []string{"tun", "tap", "wg", "utun", "ppp", "ipsec", "tailscale", "zt", "nordlynx", "proton"}
+
+// LookupRoute returns the route the kernel selects to reach target. No
+// packet is sent.
+func LookupRoute(target net.IP) (*hop.RouteInfo, error) {
+	route, err := lookupRoute(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Routes without a preferred source leave the choice to the kernel,
+	// which a connected UDP socket reveals
+	if route.Source == "" {
+		if conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: target, Port: 33434}); err == nil {
+			route.Source = conn.LocalAddr().(*net.UDPAddr).IP.String()
+			conn.Close()
+		}
+	}
+	if iface, err := net.InterfaceByName(route.Interface); err == nil {
+		route.MTU = iface.MTU
+	}
+	return route, nil
+}
+
+// DefaultRoute returns the route the kernel selects for addresses without a
+// more specific route, in the address family of target.
+func DefaultRoute(target net.IP) (*hop.RouteInfo, error) {
+	if target.To4() != nil {
+		return lookupRoute(referenceIPv4)
+	}
+	return lookupRoute(referenceIPv6)
+}
+
+// RouteWarnings explains why probes following route may not go where the
+// user expects: a VPN tunnel, policy routing to another table, or a path
+// other than the default route. def is the route returned by DefaultRoute,
+// ignored unless it is the default route or nil.
+func RouteWarnings(route, def *hop.RouteInfo) []string {
+	var warnings []string
+	if isVPNInterface(route.Interface) {
+		warnings = append(warnings, fmt.Sprintf("probes leave through %s, which looks like a VPN tunnel: the trace shows the tunnel's path, not the local network's", route.Interface))
+	}
+	if route.Table != "" && route.Table != "main" && route.Table != "local" {
+		warnings = append(warnings, fmt.Sprintf("the route comes from table %s: policy routing sends probes to this target away from the main table", route.Table))
+	}
+	if def != nil && def.Destination == "default" && def.Interface != "" && route.Interface != "" && route.Interface != def.Interface && route.Destination != "default" {
+		warnings = append(warnings, fmt.Sprintf("the route to this target (%s) leaves through %s, not the default route's %s", route.Destination, route.Interface, def.Interface))
+	}
+	return warnings
+}
+
+// isVPNInterface reports whether name looks like a VPN tunnel interface.
+func isVPNInterface(name string) bool {
+	for _, prefix := range vpnInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeTableName returns the name of a Linux routing table as ip route
+// shows it.
+func routeTableName(id uint32) string {
+	switch id {
+	case 0:
+		return ""
+	case 253:
+		return "default"
+	case 254:
+		return "main"
+	case 255:
+		return "local"
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// parseRouteGet parses the output of macOS "route -n get", e.g.
+//
+//	   route to: 8.8.8.8
+//	destination: default
+//	    gateway: 192.168.1.1
+//	  interface: en0
+func parseRouteGet(r io.Reader) (*hop.RouteInfo, error) {
+	route := &hop.RouteInfo{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "destination":
+			route.Destination = value
+		case "mask":
+			if ones, _ := net.IPMask(net.ParseIP(value).To4()).Size(); ones > 0 && route.Destination != "default" {
+				route.Destination = fmt.Sprintf("%s/%d", route.Destination, ones)
+			}
+		case "gateway":
+			route.Gateway = value
+		case "interface":
+			route.Interface = value
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if route.Interface == "" {
+		return nil, fmt.Errorf("no route to host")
+	}
+	return route, nil
+}
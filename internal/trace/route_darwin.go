@@ -0,0 +1,26 @@
+//go:build darwin
+
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// lookupRoute asks the routing socket for the route to target through
+// "route -n get".
+func lookupRoute(target net.IP) (*hop.RouteInfo, error) {
+	args := []string{"-n", "get"}
+	if target.To4() == nil {
+		args = append(args, "-inet6")
+	}
+	out, err := exec.Command("route", append(args, target.String())...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("route get %s: %w", target, err)
+	}
+	return parseRouteGet(bytes.NewReader(out))
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package trace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// lookupRoute asks the kernel over rtnetlink for the route to target, as
+// "ip route get fibmatch" does: the FIB entry that matches, with its prefix,
+// table and metric. Kernels before 4.13 lack fibmatch and answer with the
+// resolved route instead.
+func lookupRoute(target net.IP) (*hop.RouteInfo, error) {
+	route, err := getRoute(target, unix.RTM_F_FIB_MATCH|unix.RTM_F_LOOKUP_TABLE)
+	if err == syscall.EINVAL {
+		route, err = getRoute(target, unix.RTM_F_LOOKUP_TABLE)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("route lookup for %s: %w", target, err)
+	}
+	return route, nil
+}
+
+// getRoute sends one RTM_GETROUTE request and parses the answer.
+func getRoute(target net.IP, flags uint32) (*hop.RouteInfo, error) {
+	family, addr := unix.AF_INET6, target.To16()
+	if ip4 := target.To4(); ip4 != nil {
+		family, addr = unix.AF_INET, ip4
+	}
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	// nlmsghdr, rtmsg, then the RTA_DST attribute
+	attrLen := unix.SizeofRtAttr + len(addr)
+	msgLen := unix.NLMSG_HDRLEN + unix.SizeofRtMsg + attrLen
+	req := make([]byte, msgLen)
+	binary.NativeEndian.PutUint32(req[0:], uint32(msgLen))
+	binary.NativeEndian.PutUint16(req[4:], unix.RTM_GETROUTE)
+	binary.NativeEndian.PutUint16(req[6:], unix.NLM_F_REQUEST)
+	binary.NativeEndian.PutUint32(req[8:], 1)
+	rtm := req[unix.NLMSG_HDRLEN:]
+	rtm[0] = byte(family)
+	rtm[1] = byte(len(addr) * 8)
+	binary.NativeEndian.PutUint32(rtm[8:], flags)
+	attr := rtm[unix.SizeofRtMsg:]
+	binary.NativeEndian.PutUint16(attr[0:], uint16(attrLen))
+	binary.NativeEndian.PutUint16(attr[2:], unix.RTA_DST)
+	copy(attr[unix.SizeofRtAttr:], addr)
+
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 8192)
+	n, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		switch m.Header.Type {
+		case unix.NLMSG_ERROR:
+			if len(m.Data) >= 4 {
+				if errno := int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+			}
+		case unix.RTM_NEWROUTE:
+			return parseRouteMessage(&m)
+		}
+	}
+	return nil, fmt.Errorf("no route in the netlink answer")
+}
+
+// parseRouteMessage converts an RTM_NEWROUTE message to a RouteInfo.
+func parseRouteMessage(m *syscall.NetlinkMessage) (*hop.RouteInfo, error) {
+	if len(m.Data) < unix.SizeofRtMsg {
+		return nil, fmt.Errorf("short route message")
+	}
+	dstLen := int(m.Data[1])
+	table := uint32(m.Data[4])
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return nil, err
+	}
+
+	route := &hop.RouteInfo{Destination: "default"}
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case unix.RTA_DST:
+			if dstLen > 0 {
+				route.Destination = fmt.Sprintf("%s/%d", net.IP(a.Value), dstLen)
+			}
+		case unix.RTA_GATEWAY:
+			route.Gateway = net.IP(a.Value).String()
+		case unix.RTA_OIF:
+			if len(a.Value) >= 4 {
+				if iface, err := net.InterfaceByIndex(int(binary.NativeEndian.Uint32(a.Value))); err == nil {
+					route.Interface = iface.Name
+				}
+			}
+		case unix.RTA_PREFSRC:
+			route.Source = net.IP(a.Value).String()
+		case unix.RTA_PRIORITY:
+			if len(a.Value) >= 4 {
+				route.Metric = int(binary.NativeEndian.Uint32(a.Value))
+			}
+		case unix.RTA_TABLE:
+			if len(a.Value) >= 4 {
+				table = binary.NativeEndian.Uint32(a.Value)
+			}
+		}
+	}
+	route.Table = routeTableName(table)
+	return route, nil
+}
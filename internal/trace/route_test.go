@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestParseRouteGet(t *testing.T) {
+	out := `   route to: 10.8.0.5
+destination: 10.8.0.0
+       mask: 255.255.255.0
+    gateway: 10.8.0.1
+  interface: utun3
+      flags: <UP,GATEWAY,DONE,STATIC,PRCLONING>
+ recvpipe  sendpipe  ssthresh  rtt,msec    rttvar  hopcount      mtu     expire
+       0         0         0         0         0         0      1380         0
+`
+	route, err := parseRouteGet(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := hop.RouteInfo{Destination: "10.8.0.0/24", Gateway: "10.8.0.1", Interface: "utun3"}
+	if *route != want {
+		t.Errorf("got %+v, want %+v", *route, want)
+	}
+
+	if _, err := parseRouteGet(strings.NewReader("route: writing to routing socket: not in table\n")); err == nil {
+		t.Error("expected an error without a route")
+	}
+}
+
+func TestRouteWarnings(t *testing.T) {
+	def := &hop.RouteInfo{Destination: "default", Gateway: "192.168.1.1", Interface: "eth0", Table: "main"}
+
+	tests := []struct {
+		name  string
+		route *hop.RouteInfo
+		want  []string
+	}{
+		{"default route", def, nil},
+		{"vpn", &hop.RouteInfo{Destination: "default", Interface: "wg0", Table: "main"}, []string{"looks like a VPN tunnel"}},
+		{"policy routing", &hop.RouteInfo{Destination: "default", Gateway: "10.0.0.1", Interface: "eth0", Table: "100"}, []string{"table 100"}},
+		{"split route", &hop.RouteInfo{Destination: "10.0.0.0/8", Gateway: "172.16.0.1", Interface: "eth1", Table: "main"}, []string{"not the default route's eth0"}},
+		{"on-link", &hop.RouteInfo{Destination: "192.168.1.0/24", Interface: "eth0", Table: "main"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RouteWarnings(tt.route, def)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %d warning(s)", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("warning %q does not contain %q", got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupRoute_Loopback(t *testing.T) {
+	route, err := LookupRoute(net.ParseIP("127.0.0.1"))
+	if err != nil {
+		t.Skipf("route lookup unavailable: %v", err)
+	}
+	if route.Interface == "" || route.Source != "127.0.0.1" {
+		t.Errorf("unexpected loopback route: %+v", route)
+	}
+}
@@ -0,0 +1,168 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Scheduler decides when probes are sent. Tracers call NextTTL before probing
+// each TTL, Wait before each probe and Done once the probe was answered or
+// timed out. One scheduler may be shared by concurrent traces and shards, so
+// its limits apply to all of them together.
+type Scheduler interface {
+	// NextTTL blocks until probing of ttl may start.
+	NextTTL(ctx context.Context, ttl int) error
+	// Wait blocks until a probe may be sent.
+	Wait(ctx context.Context) error
+	// Done reports that a probe allowed by Wait completed.
+	Done()
+}
+
+// PacingConfig configures a Pacer.
+type PacingConfig struct {
+	Rate        float64       // Maximum probes per second (0 = unlimited)
+	Burst       int           // Probes that may be sent back to back within Rate (0 = 1)
+	MaxInFlight int           // Maximum probes awaiting a reply (0 = unlimited)
+	TTLInterval time.Duration // Pause before probing each TTL after the first
+}
+
+// Validate checks the pacing configuration.
+func (c PacingConfig) Validate() error {
+	if c.Rate < 0 {
+		return fmt.Errorf("rate must be >= 0")
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("burst must be >= 0")
+	}
+	if c.Burst > 0 && c.Rate == 0 {
+		return fmt.Errorf("burst requires a rate")
+	}
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("max in flight must be >= 0")
+	}
+	if c.TTLInterval < 0 {
+		return fmt.Errorf("TTL interval must be >= 0")
+	}
+	return nil
+}
+
+// IsZero reports whether c sets no limit.
+func (c PacingConfig) IsZero() bool {
+	return c == PacingConfig{}
+}
+
+// Pacer is a Scheduler enforcing a packets-per-second cap with a token
+// bucket, a cap on probes in flight and a pause between TTLs.
+type Pacer struct {
+	cfg      PacingConfig
+	inFlight chan struct{} // Semaphore of probes in flight (nil = unlimited)
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewPacer creates a pacer enforcing cfg.
+func NewPacer(cfg PacingConfig) (*Pacer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Burst == 0 {
+		cfg.Burst = 1
+	}
+	p := &Pacer{cfg: cfg, tokens: float64(cfg.Burst)}
+	if cfg.MaxInFlight > 0 {
+		p.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return p, nil
+}
+
+// NextTTL pauses for the TTL interval, except before the first TTL.
+func (p *Pacer) NextTTL(ctx context.Context, ttl int) error {
+	if ttl <= 1 || p.cfg.TTLInterval == 0 {
+		return ctx.Err()
+	}
+	return sleep(ctx, p.cfg.TTLInterval)
+}
+
+// Wait blocks until a probe fits in the rate and in-flight limits.
+func (p *Pacer) Wait(ctx context.Context) error {
+	if p.inFlight != nil {
+		select {
+		case p.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if p.cfg.Rate > 0 {
+		if err := sleep(ctx, p.reserve(time.Now())); err != nil {
+			p.Done()
+			return err
+		}
+	}
+	return nil
+}
+
+// Done frees the in-flight slot of a probe.
+func (p *Pacer) Done() {
+	if p.inFlight != nil {
+		<-p.inFlight
+	}
+}
+
+// reserve takes a token from the bucket and returns how long to wait until
+// it is available.
+func (p *Pacer) reserve(now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.last.IsZero() {
+		p.tokens += now.Sub(p.last).Seconds() * p.cfg.Rate
+		p.tokens = min(p.tokens, float64(p.cfg.Burst))
+	}
+	p.last = now
+	p.tokens--
+	if p.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-p.tokens / p.cfg.Rate * float64(time.Second))
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// nextTTL calls NextTTL of s, if any.
+func nextTTL(ctx context.Context, s Scheduler, ttl int) error {
+	if s == nil {
+		return nil
+	}
+	return s.NextTTL(ctx, ttl)
+}
+
+// scheduleProbe runs send once s allows it, returning the scheduler's error
+// without sending when ctx is done first.
+func scheduleProbe[T any](ctx context.Context, s Scheduler, send func() (T, error)) (T, error) {
+	if s == nil {
+		return send()
+	}
+	if err := s.Wait(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+	defer s.Done()
+	return send()
+}
@@ -0,0 +1,129 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPacingConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PacingConfig
+		wantErr bool
+	}{
+		{"unlimited", PacingConfig{}, false},
+		{"rate with burst", PacingConfig{Rate: 10, Burst: 5}, false},
+		{"negative rate", PacingConfig{Rate: -1}, true},
+		{"burst without rate", PacingConfig{Burst: 5}, true},
+		{"negative in flight", PacingConfig{MaxInFlight: -1}, true},
+		{"negative TTL interval", PacingConfig{TTLInterval: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPacer_ReserveHonorsRateAndBurst(t *testing.T) {
+	p, _ := NewPacer(PacingConfig{Rate: 10, Burst: 3})
+	now := time.Now()
+
+	// The burst goes out at once, then probes are spaced by 1/rate
+	for i := 0; i < 3; i++ {
+		if d := p.reserve(now); d != 0 {
+			t.Fatalf("probe %d: expected no wait within the burst, got %v", i, d)
+		}
+	}
+	if d := p.reserve(now); d != 100*time.Millisecond {
+		t.Errorf("expected 100ms wait after the burst, got %v", d)
+	}
+	if d := p.reserve(now); d != 200*time.Millisecond {
+		t.Errorf("expected 200ms wait for the next probe, got %v", d)
+	}
+
+	// Idle time refills the bucket, up to the burst
+	if d := p.reserve(now.Add(10 * time.Second)); d != 0 {
+		t.Errorf("expected no wait after idling, got %v", d)
+	}
+}
+
+func TestPacer_WaitPacesProbes(t *testing.T) {
+	p, _ := NewPacer(PacingConfig{Rate: 100})
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := p.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		p.Done()
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("expected 5 probes at 100pps to take ~40ms, took %v", elapsed)
+	}
+}
+
+func TestPacer_MaxInFlight(t *testing.T) {
+	p, _ := NewPacer(PacingConfig{MaxInFlight: 1})
+	if err := p.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second probe to wait for a slot, got %v", err)
+	}
+
+	p.Done()
+	if err := p.Wait(context.Background()); err != nil {
+		t.Errorf("expected a free slot after Done, got %v", err)
+	}
+}
+
+func TestPacer_NextTTL(t *testing.T) {
+	p, _ := NewPacer(PacingConfig{TTLInterval: 30 * time.Millisecond})
+
+	start := time.Now()
+	p.NextTTL(context.Background(), 1)
+	if time.Since(start) >= 30*time.Millisecond {
+		t.Error("expected no pause before the first TTL")
+	}
+	p.NextTTL(context.Background(), 2)
+	if time.Since(start) < 30*time.Millisecond {
+		t.Error("expected a pause before the second TTL")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.NextTTL(ctx, 3); err == nil {
+		t.Error("expected error for a cancelled context")
+	}
+}
+
+func TestScheduleProbe(t *testing.T) {
+	// Without a scheduler, probes are sent right away
+	got, err := scheduleProbe(context.Background(), nil, func() (int, error) { return 42, nil })
+	if err != nil || got != 42 {
+		t.Errorf("expected 42, got %d (%v)", got, err)
+	}
+
+	p, _ := NewPacer(PacingConfig{MaxInFlight: 1})
+	scheduleProbe(context.Background(), p, func() (int, error) { return 0, nil })
+	if len(p.inFlight) != 0 {
+		t.Error("expected the in-flight slot freed after the probe")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sent := false
+	p, _ = NewPacer(PacingConfig{Rate: 1, Burst: 1})
+	p.reserve(time.Now())
+	if _, err := scheduleProbe(ctx, p, func() (int, error) { sent = true; return 0, nil }); err == nil || sent {
+		t.Errorf("expected the probe not sent once cancelled, got sent=%v err=%v", sent, err)
+	}
+}
@@ -0,0 +1,131 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Service is a protocol and destination port of a service matrix.
+type Service struct {
+	Protocol Protocol
+	Port     int // Unused for icmp
+}
+
+// DefaultServices are the probes of the usual troubleshooting sequence:
+// ping, then DNS, HTTP and HTTPS.
+var DefaultServices = []Service{
+	{Protocol: ProtocolICMP},
+	{Protocol: ProtocolUDP, Port: 53},
+	{Protocol: ProtocolTCP, Port: 80},
+	{Protocol: ProtocolTCP, Port: 443},
+}
+
+// String formats the service as "icmp", or "protocol:port", e.g. "tcp:443".
+func (s Service) String() string {
+	if s.Protocol == ProtocolICMP {
+		return string(s.Protocol)
+	}
+	return fmt.Sprintf("%s:%d", s.Protocol, s.Port)
+}
+
+// ParseService parses "icmp" or "protocol:port" for udp, tcp and quic.
+func ParseService(s string) (Service, error) {
+	proto, port, hasPort := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	switch Protocol(proto) {
+	case ProtocolICMP:
+		if hasPort {
+			return Service{}, fmt.Errorf("invalid service %q: icmp takes no port", s)
+		}
+		return Service{Protocol: ProtocolICMP}, nil
+	case ProtocolUDP, ProtocolTCP, ProtocolQUIC:
+		n, err := strconv.Atoi(port)
+		if !hasPort || err != nil || n < 1 || n > 65535 {
+			return Service{}, fmt.Errorf("invalid service %q: expected %s:<port 1-65535>", s, proto)
+		}
+		return Service{Protocol: Protocol(proto), Port: n}, nil
+	}
+	return Service{}, fmt.Errorf("invalid service %q: expected icmp, udp:<port>, tcp:<port> or quic:<port>", s)
+}
+
+// ServiceTrace is the trace of a target over one service.
+type ServiceTrace struct {
+	Service Service
+	Result  *hop.TraceResult // nil when the trace failed
+	Err     error
+}
+
+// TraceServices traces target over every service at the same time, each
+// with cfg for all but the protocol and port, and returns the traces in the
+// order of services.
+func TraceServices(ctx context.Context, cfg *Config, target net.IP, services []Service) []ServiceTrace {
+	traces := make([]ServiceTrace, len(services))
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		traces[i].Service = svc
+		c := *cfg
+		c.Protocol = svc.Protocol
+		c.Port = svc.Port
+		if svc.Protocol == ProtocolICMP {
+			c.Shards = 0
+		}
+		wg.Add(1)
+		go func(st *ServiceTrace) {
+			defer wg.Done()
+			tracer, err := NewLocalTracer(&c)
+			if err != nil {
+				st.Err = err
+				return
+			}
+			st.Result, st.Err = tracer.Trace(ctx, target, nil)
+		}(&traces[i])
+	}
+	wg.Wait()
+	return traces
+}
+
+// PathDivergence returns the first TTL at which two of the traces got
+// answers from different addresses, with those addresses by service, or 0
+// when the paths agree wherever they were both answered. Routers often
+// balance flows by port, so a divergence alone is not a problem: it tells
+// where the paths to a service that fails leave the ones that work.
+func PathDivergence(traces []ServiceTrace) (int, map[Service]net.IP) {
+	maxTTL := 0
+	for _, st := range traces {
+		if st.Result != nil {
+			for _, h := range st.Result.Hops {
+				maxTTL = max(maxTTL, h.TTL)
+			}
+		}
+	}
+	for ttl := 1; ttl <= maxTTL; ttl++ {
+		addrs := make(map[Service]net.IP)
+		var first net.IP
+		differ := false
+		for _, st := range traces {
+			if st.Result == nil {
+				continue
+			}
+			h := st.Result.GetHop(ttl)
+			if h == nil || h.PrimaryIP() == nil {
+				continue
+			}
+			ip := h.PrimaryIP()
+			addrs[st.Service] = ip
+			if first == nil {
+				first = ip
+			} else if !first.Equal(ip) {
+				differ = true
+			}
+		}
+		if differ {
+			return ttl, addrs
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,83 @@
+package trace
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestParseService(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Service
+		wantErr bool
+	}{
+		{"icmp", Service{Protocol: ProtocolICMP}, false},
+		{"udp:53", Service{Protocol: ProtocolUDP, Port: 53}, false},
+		{" TCP:443 ", Service{Protocol: ProtocolTCP, Port: 443}, false},
+		{"quic:443", Service{Protocol: ProtocolQUIC, Port: 443}, false},
+		{"icmp:1", Service{}, true},
+		{"tcp", Service{}, true},
+		{"tcp:0", Service{}, true},
+		{"tcp:http", Service{}, true},
+		{"sctp:80", Service{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseService(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseService(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestService_String(t *testing.T) {
+	var got []string
+	for _, s := range DefaultServices {
+		got = append(got, s.String())
+	}
+	want := []string{"icmp", "udp:53", "tcp:80", "tcp:443"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("DefaultServices = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+// serviceTestTrace returns a trace answered by ips, one hop each; "" is a
+// silent hop.
+func serviceTestTrace(svc Service, ips ...string) ServiceTrace {
+	tr := hop.NewTraceResult("example.com", "192.0.2.1")
+	for i, ip := range ips {
+		h := hop.NewHop(i + 1)
+		if ip == "" {
+			h.AddTimeout()
+		} else {
+			h.AddProbe(net.ParseIP(ip), time.Millisecond)
+		}
+		tr.AddHop(h)
+	}
+	return ServiceTrace{Service: svc, Result: tr}
+}
+
+func TestPathDivergence(t *testing.T) {
+	icmp := serviceTestTrace(DefaultServices[0], "10.0.0.1", "10.0.1.1", "10.0.2.1")
+	udp := serviceTestTrace(DefaultServices[1], "10.0.0.1", "", "10.0.2.9")
+	tcp := serviceTestTrace(DefaultServices[3], "10.0.0.1", "10.0.1.1")
+	failed := ServiceTrace{Service: DefaultServices[2], Err: net.ErrClosed}
+
+	ttl, addrs := PathDivergence([]ServiceTrace{icmp, udp, failed, tcp})
+	if ttl != 3 {
+		t.Fatalf("expected the paths to diverge at hop 3, got %d", ttl)
+	}
+	if len(addrs) != 2 || addrs[DefaultServices[1]].String() != "10.0.2.9" {
+		t.Errorf("unexpected addresses: %v", addrs)
+	}
+
+	if ttl, _ := PathDivergence([]ServiceTrace{icmp, tcp}); ttl != 0 {
+		t.Errorf("expected no divergence, got hop %d", ttl)
+	}
+}
@@ -0,0 +1,106 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/net/icmp"
+)
+
+// hopProbeFunc probes a single TTL, receiving replies through d.
+// It returns the completed hop and whether the target answered.
+type hopProbeFunc func(ctx context.Context, d *demux, target net.IP, ttl int) (*hop.Hop, bool)
+
+// traceSharded probes TTLs concurrently across several workers, each with its
+// own ICMP listener in the network namespace netns, receive loop reading it
+// with newReader, and per-probe sockets (and therefore source ports).
+//
+// Every raw ICMP socket receives a copy of each ICMP message, and probes are
+// matched on their embedded ports, so workers never consume each other's
+// responses. TTLs are interleaved across workers (worker k owns TTLs k+1,
+// k+1+shards, ...) so all workers advance toward the target together.
+// Probe identities depend only on the TTL, so flows are identical to a
+// sequential trace. Hops are appended to result and passed to callback in
+// TTL order; hops beyond the first one that reaches the target are discarded.
+func traceSharded(ctx context.Context, netns string, target net.IP, shards, maxHops int, newReader func(*icmp.PacketConn) replyReader, probeHop hopProbeFunc, callback HopCallback, result *hop.TraceResult) error {
+	if shards > maxHops {
+		shards = maxHops
+	}
+
+	demuxes := make([]*demux, 0, shards)
+	defer func() {
+		for _, d := range demuxes {
+			d.close()
+			d.conn.Close()
+		}
+	}()
+	for i := 0; i < shards; i++ {
+		conn, err := listenICMP(netns, target)
+		if err != nil {
+			return err
+		}
+		demuxes = append(demuxes, newDemux(conn, newReader(conn)))
+	}
+
+	return runSharded(ctx, shards, maxHops, func(worker, ttl int) (*hop.Hop, bool) {
+		return probeHop(ctx, demuxes[worker], target, ttl)
+	}, callback, result)
+}
+
+// runSharded schedules TTLs across workers and delivers hops in TTL order.
+// probe is called concurrently from different workers, but never twice for the same TTL.
+func runSharded(ctx context.Context, shards, maxHops int, probe func(worker, ttl int) (*hop.Hop, bool), callback HopCallback, result *hop.TraceResult) error {
+	var mu sync.Mutex
+	hops := make([]*hop.Hop, maxHops+1)
+	reachedAt := maxHops + 1 // Lowest TTL at which the target answered
+	next := 1                // Next TTL to deliver in order
+
+	// flush delivers contiguous completed hops. Must be called with mu held.
+	flush := func() {
+		for next <= maxHops && next <= reachedAt && hops[next] != nil {
+			result.AddHop(hops[next])
+			if callback != nil {
+				callback(hops[next])
+			}
+			if next == reachedAt {
+				result.ReachedTarget = true
+			}
+			next++
+		}
+	}
+
+	var wg sync.WaitGroup
+	for k := 0; k < shards; k++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for ttl := worker + 1; ttl <= maxHops; ttl += shards {
+				if ctx.Err() != nil {
+					return
+				}
+
+				mu.Lock()
+				done := ttl > reachedAt
+				mu.Unlock()
+				if done {
+					return
+				}
+
+				h, reached := probe(worker, ttl)
+
+				mu.Lock()
+				hops[ttl] = h
+				if reached && ttl < reachedAt {
+					reachedAt = ttl
+				}
+				flush()
+				mu.Unlock()
+			}
+		}(k)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
@@ -0,0 +1,115 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestRunSharded_DeliversHopsInOrder(t *testing.T) {
+	result := hop.NewTraceResult("target", "192.0.2.1")
+	var delivered []int
+
+	// Later TTLs answer faster so completion order differs from TTL order
+	probe := func(worker, ttl int) (*hop.Hop, bool) {
+		time.Sleep(time.Duration(10-ttl) * time.Millisecond)
+		h := hop.NewHop(ttl)
+		h.AddProbe(net.ParseIP("10.0.0.1"), time.Millisecond)
+		return h, false
+	}
+
+	err := runSharded(context.Background(), 3, 8, probe, func(h *hop.Hop) {
+		delivered = append(delivered, h.TTL)
+	}, result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(delivered) != 8 {
+		t.Fatalf("delivered %d hops, want 8", len(delivered))
+	}
+	for i, ttl := range delivered {
+		if ttl != i+1 {
+			t.Errorf("delivered[%d] = TTL %d, want %d", i, ttl, i+1)
+		}
+	}
+	if result.ReachedTarget {
+		t.Error("expected target not reached")
+	}
+}
+
+func TestRunSharded_StopsAtTarget(t *testing.T) {
+	result := hop.NewTraceResult("target", "192.0.2.1")
+
+	probe := func(worker, ttl int) (*hop.Hop, bool) {
+		return hop.NewHop(ttl), ttl == 5
+	}
+
+	if err := runSharded(context.Background(), 4, 30, probe, nil, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !result.ReachedTarget {
+		t.Error("expected target reached")
+	}
+	if result.TotalHops() != 5 {
+		t.Errorf("TotalHops = %d, want 5", result.TotalHops())
+	}
+}
+
+func TestRunSharded_EachTTLProbedOnceByOwningWorker(t *testing.T) {
+	result := hop.NewTraceResult("target", "192.0.2.1")
+	var mu sync.Mutex
+	owner := make(map[int]int)
+
+	probe := func(worker, ttl int) (*hop.Hop, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, dup := owner[ttl]; dup {
+			t.Errorf("TTL %d probed twice", ttl)
+		}
+		owner[ttl] = worker
+		return hop.NewHop(ttl), false
+	}
+
+	if err := runSharded(context.Background(), 3, 9, probe, nil, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for ttl, worker := range owner {
+		if want := (ttl - 1) % 3; worker != want {
+			t.Errorf("TTL %d probed by worker %d, want %d", ttl, worker, want)
+		}
+	}
+}
+
+func TestRunSharded_ReturnsContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runSharded(ctx, 2, 10, func(worker, ttl int) (*hop.Hop, bool) {
+		return hop.NewHop(ttl), false
+	}, nil, hop.NewTraceResult("target", "192.0.2.1"))
+
+	if err == nil {
+		t.Error("expected context error")
+	}
+}
+
+func TestConfig_Validate_RejectsShardedICMP(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Shards = 4
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for sharded ICMP")
+	}
+
+	cfg.Protocol = ProtocolUDP
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error for sharded UDP: %v", err)
+	}
+}
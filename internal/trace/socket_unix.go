@@ -3,6 +3,8 @@
 package trace
 
 import (
+	"net"
+	"os"
 	"syscall"
 )
 
@@ -21,6 +23,17 @@ func createRawSocket(domain, sockType, proto int) (socketFD, error) {
 	return socketFD(fd), nil
 }
 
+// createSocketIn creates a socket in the network namespace netns, or in the
+// current one if netns is empty.
+func createSocketIn(netns string, domain, sockType, proto int) (socketFD, error) {
+	fd := invalidSocket
+	err := inNetNS(netns, func() (err error) {
+		fd, err = createRawSocket(domain, sockType, proto)
+		return err
+	})
+	return fd, err
+}
+
 // closeSocket closes the socket.
 func closeSocket(fd socketFD) error {
 	return syscall.Close(int(fd))
@@ -64,3 +77,40 @@ func isEMSGSIZE(err error) bool {
 func socketFDInt(fd socketFD) int {
 	return int(fd)
 }
+
+// socketLocalPort returns the local port bound to the socket, or 0 if unknown.
+func socketLocalPort(fd socketFD) int {
+	sa, err := syscall.Getsockname(int(fd))
+	if err != nil {
+		return 0
+	}
+	switch a := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return a.Port
+	case *syscall.SockaddrInet6:
+		return a.Port
+	}
+	return 0
+}
+
+// socketConn wraps a duplicate of the socket in a net.Conn. The caller closes
+// the returned conn and remains responsible for closing fd.
+func socketConn(fd socketFD) (net.Conn, error) {
+	dup, err := syscall.Dup(int(fd))
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(dup), "probe")
+	defer f.Close()
+	return net.FileConn(f)
+}
+
+// bindSocket binds the socket to an ephemeral port on the wildcard address of
+// target's family, so its local port is known before connecting.
+func bindSocket(fd socketFD, target net.IP) error {
+	var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+	if IsIPv6(target) {
+		sa = &syscall.SockaddrInet6{}
+	}
+	return syscall.Bind(int(fd), sa)
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"net"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/net/icmp"
+)
+
+// Default segment routing label ranges. Most vendors allocate prefix SIDs
+// from an SRGB of 16000-23999 and adjacency SIDs from an SRLB of
+// 15000-15999, below the dynamic range LDP and RSVP-TE labels come from.
+const (
+	SRGBBase = 16000
+	SRGBEnd  = 23999
+	SRLBBase = 15000
+	SRLBEnd  = 15999
+)
+
+// IPv6 next header values met in front of a Segment Routing Header.
+const (
+	nextHeaderHopByHop    = 0
+	nextHeaderIPv6        = 41
+	nextHeaderRouting     = 43
+	nextHeaderDestOptions = 60
+
+	routingTypeSRH = 4 // RFC 8754
+	srhFixedSize   = 8 // Header before the segment list
+	sidSize        = 16
+)
+
+// SegmentRoutingFromMPLS recognizes an SR-MPLS domain from a label stack:
+// labels in the default SRGB are prefix segments, decoded to their index,
+// and labels in the default SRLB adjacency segments. Other labels, such as
+// a VPN service label at the bottom of the stack, are left out. Returns nil
+// if no label is in a segment routing range.
+func SegmentRoutingFromMPLS(labels []hop.MPLSLabel) *hop.SegmentRouting {
+	var segments []hop.Segment
+	for _, l := range labels {
+		switch {
+		case l.Label >= SRGBBase && l.Label <= SRGBEnd:
+			segments = append(segments, hop.Segment{Label: l.Label, Index: int(l.Label - SRGBBase)})
+		case l.Label >= SRLBBase && l.Label <= SRLBEnd:
+			segments = append(segments, hop.Segment{Label: l.Label, Index: -1})
+		}
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	return &hop.SegmentRouting{Dataplane: hop.SRMPLS, Segments: segments}
+}
+
+// ParseSRH parses the Segment Routing Header of the IPv6 packet quoted in
+// an ICMPv6 error, skipping Hop-by-Hop and Destination Options headers in
+// front of it. It returns the segment routing information, the offset of
+// the header following the SRH and its type, or nil if there is no SRH.
+func ParseSRH(quoted []byte) (sr *hop.SegmentRouting, next, nextHeader int) {
+	if len(quoted) < 40 || quoted[0]>>4 != 6 {
+		return nil, 0, 0
+	}
+	nh, pos := int(quoted[6]), 40
+	for nh == nextHeaderHopByHop || nh == nextHeaderDestOptions {
+		if pos+2 > len(quoted) {
+			return nil, 0, 0
+		}
+		nh, pos = int(quoted[pos]), pos+(int(quoted[pos+1])+1)*8
+	}
+	if nh != nextHeaderRouting || pos+srhFixedSize > len(quoted) || quoted[pos+2] != routingTypeSRH {
+		return nil, 0, 0
+	}
+
+	srh := quoted[pos:]
+	size := (int(srh[1]) + 1) * 8
+	lastEntry := int(srh[4])
+	if srhFixedSize+(lastEntry+1)*sidSize > min(size, len(srh)) {
+		return nil, 0, 0
+	}
+
+	// The segment list is encoded in reverse: entry 0 is the last segment
+	sr = &hop.SegmentRouting{Dataplane: hop.SRv6, SegmentsLeft: int(srh[3])}
+	for i := lastEntry; i >= 0; i-- {
+		off := srhFixedSize + i*sidSize
+		sr.Segments = append(sr.Segments, hop.Segment{SID: net.IP(append([]byte(nil), srh[off:off+sidSize]...))})
+	}
+	return sr, pos + size, int(srh[0])
+}
+
+// unwrapSRv6 returns the segment routing information of an ICMPv6 error
+// quoting a packet steered with SRv6, and replaces the quoted packet in rm
+// with the probe as it was sent: the inner packet when the probe was
+// encapsulated, or, when the SRH was inserted into it, the packet without
+// the SRH and with its final segment as destination. Probes then match as
+// if the error quoted them directly. Returns nil if there is no SRH.
+func unwrapSRv6(rm *icmp.Message, target net.IP) *hop.SegmentRouting {
+	if !IsIPv6(target) {
+		return nil
+	}
+	var data *[]byte
+	switch body := rm.Body.(type) {
+	case *icmp.TimeExceeded:
+		data = &body.Data
+	case *icmp.DstUnreach:
+		data = &body.Data
+	case *icmp.PacketTooBig:
+		data = &body.Data
+	default:
+		return nil
+	}
+
+	quoted := *data
+	sr, next, nextHeader := ParseSRH(quoted)
+	if sr == nil || next > len(quoted) {
+		return sr
+	}
+	if nextHeader == nextHeaderIPv6 {
+		*data = quoted[next:]
+		return sr
+	}
+	probe := append([]byte(nil), quoted[:40]...)
+	probe[6] = byte(nextHeader)
+	copy(probe[24:40], sr.Segments[len(sr.Segments)-1].SID)
+	*data = append(probe, quoted[next:]...)
+	return sr
+}
@@ -0,0 +1,161 @@
+package trace
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+func TestSegmentRoutingFromMPLS(t *testing.T) {
+	sr := SegmentRoutingFromMPLS([]hop.MPLSLabel{
+		{Label: 16005, TTL: 1},
+		{Label: 15002, TTL: 1},
+		{Label: 24001, S: true, TTL: 1}, // VPN service label
+	})
+	if sr == nil {
+		t.Fatal("expected an SR-MPLS domain")
+	}
+	if sr.Dataplane != hop.SRMPLS {
+		t.Errorf("expected dataplane %q, got %q", hop.SRMPLS, sr.Dataplane)
+	}
+	if len(sr.Segments) != 2 {
+		t.Fatalf("expected 2 segments, got %+v", sr.Segments)
+	}
+	if sr.Segments[0].Index != 5 || sr.Segments[1].Index != -1 {
+		t.Errorf("expected prefix index 5 then an adjacency, got %+v", sr.Segments)
+	}
+	if got := sr.String(); got != "SR-MPLS 16005 (idx 5) > 15002 (adj)" {
+		t.Errorf("unexpected String(): %q", got)
+	}
+}
+
+func TestSegmentRoutingFromMPLS_IgnoresOtherLabels(t *testing.T) {
+	if sr := SegmentRoutingFromMPLS([]hop.MPLSLabel{{Label: 24015, S: true}, {Label: 299776}}); sr != nil {
+		t.Errorf("expected nil for LDP labels, got %+v", sr)
+	}
+}
+
+// srv6Packet builds the start of an IPv6 packet as quoted in an ICMPv6
+// error: a header to dst followed by an SRH with segments (in traversal
+// order) and then a header of type next with payload.
+func srv6Packet(dst net.IP, segments []net.IP, segmentsLeft, next int, payload []byte) []byte {
+	pkt := make([]byte, 40)
+	pkt[0] = 6 << 4
+	pkt[6] = nextHeaderRouting
+	pkt[7] = 1
+	copy(pkt[8:24], net.ParseIP("2001:db8::1"))
+	copy(pkt[24:40], dst)
+
+	srh := make([]byte, srhFixedSize+len(segments)*sidSize)
+	srh[0] = byte(next)
+	srh[1] = byte(len(segments) * 2)
+	srh[2] = routingTypeSRH
+	srh[3] = byte(segmentsLeft)
+	srh[4] = byte(len(segments) - 1)
+	for i, seg := range segments {
+		copy(srh[srhFixedSize+(len(segments)-1-i)*sidSize:], seg.To16())
+	}
+	return append(append(pkt, srh...), payload...)
+}
+
+func TestParseSRH(t *testing.T) {
+	segs := []net.IP{net.ParseIP("fc00:1::e"), net.ParseIP("fc00:2::e"), net.ParseIP("2001:db8::99")}
+	pkt := srv6Packet(segs[0], segs, 2, 17, []byte{0x82, 0x9a, 0x82, 0x9b})
+
+	sr, next, nextHeader := ParseSRH(pkt)
+	if sr == nil {
+		t.Fatal("expected an SRH")
+	}
+	if sr.Dataplane != hop.SRv6 || sr.SegmentsLeft != 2 {
+		t.Errorf("unexpected SRH: %+v", sr)
+	}
+	if len(sr.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(sr.Segments))
+	}
+	for i, seg := range segs {
+		if !sr.Segments[i].SID.Equal(seg) {
+			t.Errorf("segment %d: expected %s, got %s", i, seg, sr.Segments[i].SID)
+		}
+	}
+	if next != 40+srhFixedSize+3*sidSize || nextHeader != 17 {
+		t.Errorf("expected UDP after the SRH at %d, got %d at %d", 40+srhFixedSize+3*sidSize, nextHeader, next)
+	}
+}
+
+func TestParseSRH_SkipsHopByHop(t *testing.T) {
+	segs := []net.IP{net.ParseIP("fc00:1::e"), net.ParseIP("2001:db8::99")}
+	pkt := srv6Packet(segs[0], segs, 1, 17, nil)
+	hbh := []byte{nextHeaderRouting, 0, 1, 4, 0, 0, 0, 0}
+	pkt = append(append(append([]byte(nil), pkt[:40]...), hbh...), pkt[40:]...)
+	pkt[6] = nextHeaderHopByHop
+
+	if sr, _, _ := ParseSRH(pkt); sr == nil || len(sr.Segments) != 2 {
+		t.Errorf("expected the SRH behind Hop-by-Hop options, got %+v", sr)
+	}
+}
+
+func TestParseSRH_NoSRH(t *testing.T) {
+	pkt := make([]byte, 48)
+	pkt[0] = 6 << 4
+	pkt[6] = 17
+	if sr, _, _ := ParseSRH(pkt); sr != nil {
+		t.Errorf("expected nil without an SRH, got %+v", sr)
+	}
+	if sr, _, _ := ParseSRH(pkt[:20]); sr != nil {
+		t.Errorf("expected nil for a truncated packet, got %+v", sr)
+	}
+}
+
+func TestUnwrapSRv6_InsertedSRH(t *testing.T) {
+	target := net.ParseIP("2001:db8::99")
+	segs := []net.IP{net.ParseIP("fc00:1::e"), target}
+	udp := []byte{0x82, 0x9a, 0x82, 0x9b, 0, 8, 0, 0}
+	rm := &icmp.Message{Type: ipv6.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: srv6Packet(segs[0], segs, 1, 17, udp)}}
+
+	sr := unwrapSRv6(rm, target)
+	if sr == nil || len(sr.Segments) != 2 {
+		t.Fatalf("expected 2 SRv6 segments, got %+v", sr)
+	}
+	data := rm.Body.(*icmp.TimeExceeded).Data
+	if !quotedDstIs(data, target) {
+		t.Errorf("expected the quoted packet addressed to the final segment, got %s", net.IP(data[24:40]))
+	}
+	if data[6] != 17 {
+		t.Errorf("expected next header UDP, got %d", data[6])
+	}
+	if port, ok := quotedDstPort(data, 40); !ok || port != 0x829b {
+		t.Errorf("expected port %d, got %d", 0x829b, port)
+	}
+}
+
+func TestUnwrapSRv6_Encapsulated(t *testing.T) {
+	target := net.ParseIP("2001:db8::99")
+	inner := make([]byte, 48)
+	inner[0] = 6 << 4
+	inner[6] = 17
+	copy(inner[24:40], target)
+	inner[42], inner[43] = 0x82, 0x9c
+	segs := []net.IP{net.ParseIP("fc00:1::e"), net.ParseIP("fc00:9::d6")}
+	rm := &icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Body: &icmp.DstUnreach{Data: srv6Packet(segs[0], segs, 1, nextHeaderIPv6, inner)}}
+
+	if sr := unwrapSRv6(rm, target); sr == nil {
+		t.Fatal("expected an SRv6 domain")
+	}
+	data := rm.Body.(*icmp.DstUnreach).Data
+	if !quotedDstIs(data, target) {
+		t.Error("expected the inner packet to be quoted")
+	}
+	if port, ok := quotedDstPort(data, 40); !ok || port != 0x829c {
+		t.Errorf("expected port %d, got %d", 0x829c, port)
+	}
+}
+
+func TestUnwrapSRv6_IgnoresIPv4(t *testing.T) {
+	rm := &icmp.Message{Body: &icmp.TimeExceeded{Data: make([]byte, 28)}}
+	if sr := unwrapSRv6(rm, net.ParseIP("192.0.2.1")); sr != nil {
+		t.Errorf("expected nil for IPv4, got %+v", sr)
+	}
+}
@@ -0,0 +1,71 @@
+package trace
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// TCP flags of a SYN-ACK or RST answering a SYN.
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+// ParseSYNACK returns the fingerprint of the SYN-ACK whose TCP header is
+// seg and that arrived with ttl, or nil if seg is not a SYN-ACK.
+func ParseSYNACK(seg []byte, ttl int) *hop.TCPFingerprint {
+	if len(seg) < 20 || seg[13]&(tcpFlagSYN|tcpFlagACK) != tcpFlagSYN|tcpFlagACK {
+		return nil
+	}
+	hdrLen := int(seg[12]>>4) * 4
+	if hdrLen < 20 || hdrLen > len(seg) {
+		return nil
+	}
+
+	f := &hop.TCPFingerprint{
+		TTL:         ttl,
+		Window:      int(seg[14])<<8 | int(seg[15]),
+		WindowScale: -1,
+	}
+	var layout []string
+	opts := seg[20:hdrLen]
+	for len(opts) > 0 {
+		kind := opts[0]
+		switch kind {
+		case 0: // End of option list
+			layout = append(layout, "E")
+			opts = nil
+			continue
+		case 1: // No-operation
+			layout = append(layout, "N")
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || int(opts[1]) < 2 || int(opts[1]) > len(opts) {
+			break
+		}
+		data := opts[2:opts[1]]
+		switch {
+		case kind == 2 && len(data) == 2:
+			layout = append(layout, "M")
+			f.MSS = int(data[0])<<8 | int(data[1])
+		case kind == 3 && len(data) == 1:
+			layout = append(layout, "W")
+			f.WindowScale = int(data[0])
+		case kind == 4:
+			layout = append(layout, "S")
+			f.SACK = true
+		case kind == 8:
+			layout = append(layout, "T")
+			f.Timestamps = true
+		default:
+			layout = append(layout, strconv.Itoa(int(kind)))
+		}
+		opts = opts[opts[1]:]
+	}
+	f.Options = strings.Join(layout, ",")
+	return f
+}
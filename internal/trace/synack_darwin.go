@@ -0,0 +1,24 @@
+//go:build darwin
+
+package trace
+
+import (
+	"net"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// synAckSniffer is unsupported on Darwin: BSD raw sockets never receive
+// TCP segments, so the SYN-ACK of a probe can't be read.
+type synAckSniffer struct{}
+
+// openSYNACKSniffer returns nil: see synAckSniffer.
+func openSYNACKSniffer(netns string, target net.IP) *synAckSniffer {
+	return nil
+}
+
+func (s *synAckSniffer) read(target net.IP, srcPort, dstPort int) *hop.TCPFingerprint {
+	return nil
+}
+
+func (s *synAckSniffer) close() {}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package trace
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/sys/unix"
+)
+
+// synAckSniffer receives a copy of every TCP segment arriving for the host
+// on a raw socket, to read the SYN-ACK of a probe the kernel handles.
+type synAckSniffer struct {
+	fd   socketFD
+	ipv6 bool
+}
+
+// openSYNACKSniffer opens a sniffer for SYN-ACKs from target, or returns
+// nil when raw TCP sockets are unavailable: fingerprints are best effort.
+func openSYNACKSniffer(netns string, target net.IP) *synAckSniffer {
+	fd, err := createSocketIn(netns, SocketDomain(target), syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil
+	}
+	s := &synAckSniffer{fd: fd, ipv6: IsIPv6(target)}
+	// IPv6 raw sockets don't pass the IP header: ask for the hop limit
+	if s.ipv6 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_RECVHOPLIMIT, 1); err != nil {
+			s.close()
+			return nil
+		}
+	}
+	if err := setSocketNonBlocking(fd); err != nil {
+		s.close()
+		return nil
+	}
+	return s
+}
+
+// read returns the fingerprint of the SYN-ACK target sent from dstPort to
+// srcPort among the segments received so far, or nil if there is none.
+func (s *synAckSniffer) read(target net.IP, srcPort, dstPort int) *hop.TCPFingerprint {
+	if s == nil {
+		return nil
+	}
+	buf := make([]byte, 1500)
+	oob := make([]byte, 64)
+	for {
+		n, oobn, _, from, err := unix.Recvmsg(int(s.fd), buf, oob, 0)
+		if err != nil {
+			return nil
+		}
+		pkt := buf[:n]
+
+		var src net.IP
+		var ttl int
+		if s.ipv6 {
+			if a, ok := from.(*unix.SockaddrInet6); ok {
+				src = net.IP(a.Addr[:])
+			}
+			ttl = hopLimitFromCmsg(oob[:oobn])
+		} else {
+			if len(pkt) < 20 || int(pkt[0]&0x0f)*4 > len(pkt) {
+				continue
+			}
+			src, ttl = net.IP(pkt[12:16]), int(pkt[8])
+			pkt = pkt[int(pkt[0]&0x0f)*4:]
+		}
+		if len(pkt) < 4 || !src.Equal(target) {
+			continue
+		}
+		if int(pkt[0])<<8|int(pkt[1]) != dstPort || int(pkt[2])<<8|int(pkt[3]) != srcPort {
+			continue
+		}
+		if f := ParseSYNACK(pkt, ttl); f != nil {
+			return f
+		}
+	}
+}
+
+// close closes the raw socket.
+func (s *synAckSniffer) close() {
+	if s != nil {
+		closeSocket(s.fd)
+	}
+}
+
+// hopLimitFromCmsg returns the IPV6_HOPLIMIT control message in oob, or 0.
+func hopLimitFromCmsg(oob []byte) int {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range msgs {
+		if m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_HOPLIMIT && len(m.Data) >= 4 {
+			return int(int32(binary.NativeEndian.Uint32(m.Data)))
+		}
+	}
+	return 0
+}
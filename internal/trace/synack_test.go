@@ -0,0 +1,68 @@
+package trace
+
+import "testing"
+
+// synAckSegment builds a SYN-ACK TCP header from port 443 to 40000 with
+// window and options (padded to a multiple of 4 bytes).
+func synAckSegment(window int, opts []byte) []byte {
+	for len(opts)%4 != 0 {
+		opts = append(opts, 0)
+	}
+	seg := make([]byte, 20, 20+len(opts))
+	seg[0], seg[1] = 0x01, 0xbb
+	seg[2], seg[3] = 0x9c, 0x40
+	seg[12] = byte((20+len(opts))/4) << 4
+	seg[13] = tcpFlagSYN | tcpFlagACK
+	seg[14], seg[15] = byte(window>>8), byte(window)
+	return append(seg, opts...)
+}
+
+func TestParseSYNACK_Linux(t *testing.T) {
+	// MSS 1460, SACK permitted, timestamps, NOP, window scale 7
+	opts := []byte{2, 4, 0x05, 0xb4, 4, 2, 8, 10, 0, 0, 0, 1, 0, 0, 0, 2, 1, 3, 3, 7}
+	f := ParseSYNACK(synAckSegment(65160, opts), 52)
+	if f == nil {
+		t.Fatal("expected a fingerprint")
+	}
+	if f.TTL != 52 || f.Window != 65160 || f.MSS != 1460 || f.WindowScale != 7 || !f.SACK || !f.Timestamps {
+		t.Errorf("unexpected fingerprint: %+v", f)
+	}
+	if f.Options != "M,S,T,N,W" {
+		t.Errorf("expected option layout M,S,T,N,W, got %q", f.Options)
+	}
+	if f.OS() != "Linux" {
+		t.Errorf("expected Linux, got %q", f.OS())
+	}
+}
+
+func TestParseSYNACK_NoOptions(t *testing.T) {
+	f := ParseSYNACK(synAckSegment(8192, nil), 117)
+	if f == nil {
+		t.Fatal("expected a fingerprint")
+	}
+	if f.MSS != 0 || f.WindowScale != -1 || f.Options != "" {
+		t.Errorf("expected no options, got %+v", f)
+	}
+}
+
+func TestParseSYNACK_RejectsOtherSegments(t *testing.T) {
+	seg := synAckSegment(1024, nil)
+	seg[13] = tcpFlagACK
+	if f := ParseSYNACK(seg, 64); f != nil {
+		t.Errorf("expected nil for a plain ACK, got %+v", f)
+	}
+	if f := ParseSYNACK(seg[:12], 64); f != nil {
+		t.Errorf("expected nil for a truncated header, got %+v", f)
+	}
+}
+
+func TestParseSYNACK_MalformedOptions(t *testing.T) {
+	// MSS option claiming more bytes than the header holds
+	f := ParseSYNACK(synAckSegment(1024, []byte{1, 2, 40, 0x05}), 64)
+	if f == nil {
+		t.Fatal("expected a fingerprint despite malformed options")
+	}
+	if f.Options != "N" || f.MSS != 0 {
+		t.Errorf("expected parsing to stop at the malformed option, got %+v", f)
+	}
+}
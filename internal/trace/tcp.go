@@ -2,6 +2,7 @@ package trace
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
@@ -10,13 +11,14 @@ import (
 
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
 // TCPTracer implements traceroute using TCP SYN probes.
 type TCPTracer struct {
 	config *Config
 	id     int
+	srcIP  net.IP           // Local source address, resolved only when capturing
+	stamps timestampTracker // How the probes of the current trace were timed
 }
 
 // NewTCPTracer creates a new TCP tracer with the given configuration.
@@ -30,19 +32,40 @@ func NewTCPTracer(cfg *Config) *TCPTracer {
 // Trace performs a TCP traceroute to the target IP.
 // Supports both IPv4 and IPv6 targets.
 func (t *TCPTracer) Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+	if err := t.config.IPv6.check(target); err != nil {
+		return nil, err
+	}
+
 	result := hop.NewTraceResult(target.String(), target.String())
 	result.Protocol = string(ProtocolTCP)
 	result.StartTime = time.Now()
+	t.stamps.reset(true)
+	log, callback := startTrace(ctx, t.config, target, callback)
+
+	if t.config.Capture != nil {
+		t.srcIP = captureSourceIP(t.config.NetNS, target)
+	}
+
+	// Spread TTLs across concurrent workers when sharding is enabled
+	if t.config.Shards > 1 {
+		err := traceSharded(ctx, t.config.NetNS, target, t.config.Shards, t.config.MaxHops, t.replyReader(target), t.probeHop, callback, result)
+		result.EndTime = time.Now()
+		result.TimestampSource = t.stamps.source()
+		finishTrace(log, result, err)
+		return result, err
+	}
 
 	// Open raw socket for receiving ICMP responses based on IP version
-	proto := ICMPProtocol(target)
-	listenAddr := ListenAddress(target)
-	icmpConn, err := icmp.ListenPacket(proto, listenAddr)
+	icmpConn, err := listenICMP(t.config.NetNS, target)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open ICMP socket: %w (try running with sudo)", err)
+		return nil, err
 	}
 	defer icmpConn.Close()
 
+	// A single receive loop hands each reply to the probe it answers
+	d := newDemux(icmpConn, t.replyReader(target)(icmpConn))
+	defer d.close()
+
 	for ttl := 1; ttl <= t.config.MaxHops; ttl++ {
 		select {
 		case <-ctx.Done():
@@ -50,84 +73,98 @@ func (t *TCPTracer) Trace(ctx context.Context, target net.IP, callback HopCallba
 		default:
 		}
 
-		h := hop.NewHop(ttl)
-		reached := false
+		h, reached := t.probeHop(ctx, d, target, ttl)
 
-		for i := 0; i < t.config.PacketsPerHop; i++ {
-			pr, err := t.sendProbe(icmpConn, target, ttl, i)
-			if err != nil {
-				if isTimeout(err) {
-					h.AddTimeout()
-				} else {
-					h.AddTimeout()
-				}
-				continue
-			}
+		result.AddHop(h)
+		if callback != nil {
+			callback(h)
+		}
 
-			probe := hop.Probe{IP: pr.IP, RTT: pr.RTT, ResponseTTL: pr.ResponseTTL, IPID: pr.IPID, ICMPType: pr.ICMPType, ICMPCode: pr.ICMPCode, OriginalTTL: pr.OriginalTTL, TransportInfo: pr.TransportInfo}
-			h.Probes = append(h.Probes, probe)
+		if reached {
+			result.ReachedTarget = true
+			break
+		}
+	}
 
-			// Set MPLS labels if discovered (first probe with labels wins)
-			if len(pr.MPLS) > 0 && len(h.MPLS) == 0 {
-				h.SetMPLS(pr.MPLS)
-			}
+	result.EndTime = time.Now()
+	result.TimestampSource = t.stamps.source()
+	finishTrace(log, result, nil)
+	return result, nil
+}
 
-			// Set MTU if discovered
-			if pr.MTU > 0 && h.MTU == 0 {
-				h.MTU = pr.MTU
-			}
+// probeHop sends all probes for a single TTL and returns the resulting hop.
+func (t *TCPTracer) probeHop(ctx context.Context, d *demux, target net.IP, ttl int) (*hop.Hop, bool) {
+	h := hop.NewHop(ttl)
+	reached := false
+	if err := nextTTL(ctx, t.config.Scheduler, ttl); err != nil {
+		return h, false
+	}
 
-			// Set interface info if discovered
-			if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
-				h.InterfaceInfo = pr.InterfaceInfo
-			}
+	for i := 0; i < t.config.PacketsPerHop; i++ {
+		pr, err := scheduleProbe(ctx, t.config.Scheduler, func() (*probeResult, error) {
+			return t.sendProbe(d, target, ttl, i)
+		})
+		if err != nil {
+			h.AddTimeout()
+			continue
+		}
 
-			if pr.IP.Equal(target) {
-				reached = true
-			}
+		probe := hop.Probe{IP: pr.IP, RTT: pr.RTT, ResponseTTL: pr.ResponseTTL, IPID: pr.IPID, ICMPType: pr.ICMPType, ICMPCode: pr.ICMPCode, OriginalTTL: pr.OriginalTTL, TransportInfo: pr.TransportInfo, Handshake: pr.Handshake, Fingerprint: pr.Fingerprint}
+		h.Probes = append(h.Probes, probe)
+
+		// Set MPLS labels if discovered (first probe with labels wins)
+		if len(pr.MPLS) > 0 && len(h.MPLS) == 0 {
+			h.SetMPLS(pr.MPLS)
 		}
 
-		// NAT detection: IP-based (Tier 1) and TTL-based (Tier 2) only.
-		// See icmp.go comment for why IP ID analysis (Tier 3) is not used.
-		if t.config.DetectNAT {
-			for _, p := range h.Probes {
-				if p.Timeout || p.IP == nil {
-					continue
-				}
-				if DetectNATFromIP(p.IP, ttl) {
-					h.NAT = true
-					break
-				}
-				if p.ResponseTTL > 0 && DetectNATFromTTL(ttl, p.ResponseTTL) {
-					h.NAT = true
-					break
-				}
-			}
+		// Set MTU if discovered
+		if pr.MTU > 0 && h.MTU == 0 {
+			h.MTU = pr.MTU
 		}
 
-		result.AddHop(h)
-		if callback != nil {
-			callback(h)
+		// Set interface info if discovered
+		if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
+			h.InterfaceInfo = pr.InterfaceInfo
+		}
+		if pr.SegmentRouting != nil && h.SegmentRouting == nil {
+			h.SegmentRouting = pr.SegmentRouting
 		}
 
-		if reached {
-			result.ReachedTarget = true
-			break
+		if pr.IP.Equal(target) {
+			reached = true
 		}
 	}
 
-	result.EndTime = time.Now()
-	return result, nil
+	// NAT detection: IP-based (Tier 1) and TTL-based (Tier 2) only.
+	// See icmp.go comment for why IP ID analysis (Tier 3) is not used.
+	if t.config.DetectNAT {
+		for _, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				continue
+			}
+			if DetectNATFromIP(p.IP, ttl) {
+				h.NAT = true
+				break
+			}
+			if p.ResponseTTL > 0 && DetectNATFromTTL(ttl, p.ResponseTTL) {
+				h.NAT = true
+				break
+			}
+		}
+	}
+
+	return h, reached
 }
 
-// sendProbe sends a single TCP SYN probe and waits for response.
+// sendProbe sends a single TCP SYN probe and waits for the connection to
+// complete or for the ICMP response d hands to its source port.
 // Supports both IPv4 and IPv6 targets.
-func (t *TCPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq int) (*probeResult, error) {
+func (t *TCPTracer) sendProbe(d *demux, target net.IP, ttl, seq int) (*probeResult, error) {
 	port := t.getPort()
 
 	// Create TCP socket
 	domain := SocketDomain(target)
-	fd, err := createRawSocket(domain, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	fd, err := createSocketIn(t.config.NetNS, domain, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create TCP socket: %w", err)
 	}
@@ -140,92 +177,134 @@ func (t *TCPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 		return nil, fmt.Errorf("failed to set TTL/hop limit: %w", err)
 	}
 
-	// Set Don't Fragment bit for MTU discovery (IPv4 only)
-	if t.config.DiscoverMTU && !IsIPv6(target) {
-		if err := setDontFragment(fd); err != nil {
+	// Set Don't Fragment bit for MTU discovery
+	if t.config.DiscoverMTU {
+		if err := setDontFragmentFor(fd, target); err != nil {
 			return nil, fmt.Errorf("failed to set DF bit: %w", err)
 		}
 	}
 
+	if err := t.config.IPv6.apply(fd, target); err != nil {
+		return nil, err
+	}
+
 	// Set non-blocking
 	if err := setSocketNonBlocking(fd); err != nil {
 		return nil, fmt.Errorf("failed to set non-blocking: %w", err)
 	}
 
-	// Build destination address
-	sa := buildSockaddr(target, port)
+	// Bind before connecting: replies are demultiplexed by source port, so
+	// the probe must be registered under it before the SYN goes out
+	if err := bindSocket(fd, target); err != nil {
+		return nil, fmt.Errorf("failed to bind TCP socket: %w", err)
+	}
+	srcPort := socketLocalPort(fd)
+	ch := d.expect(srcPort)
+	defer d.unregister(srcPort)
+
+	// The kernel consumes the SYN-ACK: a raw socket gets a copy of it to
+	// fingerprint the target
+	sniffer := openSYNACKSniffer(t.config.NetNS, target)
+	defer sniffer.close()
 
 	start := time.Now()
 
 	// Initiate TCP connection (will send SYN)
-	err = connectSocket(fd, sa)
+	err = connectProbe(fd, target, port, t.config.IPv6)
+	if t.config.Capture != nil {
+		seg := buildTCPSyn(t.srcIP, target, srcPort, port)
+		capturePacket(t.config.Capture, start, t.srcIP, target, ipProtoTCP, ttl, seg)
+	}
 	// Connect will return EINPROGRESS for non-blocking socket
 	if err != nil && !isErrInProgress(err) {
 		// Check if we got a connection refused (RST) - means target reached
 		if isErrConnRefused(err) {
-			return &probeResult{IP: target, RTT: time.Since(start)}, nil
+			rtt := time.Since(start)
+			if t.config.Capture != nil {
+				captureTCPReply(t.config.Capture, start.Add(rtt), target, t.srcIP, port, srcPort, false, nil)
+			}
+			return &probeResult{IP: target, RTT: rtt}, nil
 		}
 	}
 
 	deadline := start.Add(t.config.Timeout)
 
-	// Protocol number for parsing ICMP messages
-	protoNum := ICMPProtocolNum(target)
-
-	// Enable TTL control messages for NAT detection (IPv4 only)
-	isV6 := IsIPv6(target)
-	if !isV6 && t.config.DetectNAT {
-		_ = icmpConn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
-	}
-
-	// Wait for ICMP response or TCP connection.
-	// Use short ICMP read intervals so we can poll the TCP socket
-	// between reads. Without this, SYN-ACK detection would be delayed
+	// Wait for the ICMP response in short intervals so we can poll the TCP
+	// socket in between. Without this, SYN-ACK detection would be delayed
 	// until the full ICMP timeout expires.
 	const icmpPollInterval = 5 * time.Millisecond
 
-	reply := make([]byte, 1500)
 	for {
-		// Check if TCP connection completed (SYN-ACK received)
-		if t.checkTCPConnection(fd) {
-			return &probeResult{IP: target, RTT: time.Since(start)}, nil
+		// Check if TCP connection completed (SYN-ACK or RST received)
+		if done, connected := t.checkTCPConnection(fd); done {
+			rtt := time.Since(start)
+			pr := &probeResult{IP: target, RTT: rtt}
+			if connected {
+				pr.Fingerprint = sniffer.read(target, srcPort, port)
+				pr.Handshake = t.measureHandshake(fd, rtt)
+			}
+			if t.config.Capture != nil {
+				captureTCPReply(t.config.Capture, start.Add(rtt), target, t.srcIP, port, srcPort, connected, pr.Fingerprint)
+			}
+			return pr, nil
 		}
 
 		if time.Now().After(deadline) {
 			return nil, &timeoutError{}
 		}
 
-		// Use a short read deadline so we can poll TCP between ICMP reads
 		icmpDeadline := time.Now().Add(icmpPollInterval)
 		if icmpDeadline.After(deadline) {
 			icmpDeadline = deadline
 		}
-		if err := icmpConn.SetReadDeadline(icmpDeadline); err != nil {
-			return nil, fmt.Errorf("failed to set deadline: %w", err)
+		pr, end, err := d.wait(ch, icmpDeadline)
+		if err == nil {
+			pr.RTT = end.Sub(start)
+			return pr, nil
 		}
+		if !isTimeout(err) {
+			return nil, err
+		}
+	}
+}
 
-		var n int
-		var peer net.Addr
-		var responseTTL int
-
-		if !isV6 && t.config.DetectNAT {
-			var cm *ipv4.ControlMessage
-			n, cm, peer, err = icmpConn.IPv4PacketConn().ReadFrom(reply)
-			if cm != nil {
-				responseTTL = cm.TTL
-			}
-		} else {
-			n, peer, err = icmpConn.ReadFrom(reply)
+// replyReader returns a reader of the replies to probes to target arriving on
+// an ICMP listener, for a demux.
+func (t *TCPTracer) replyReader(target net.IP) func(*icmp.PacketConn) replyReader {
+	return func(icmpConn *icmp.PacketConn) replyReader {
+		reply := make([]byte, 1500)
+		return func(deadline time.Time) (*probeResult, int, time.Time, error) {
+			return t.readReply(icmpConn, target, reply, deadline)
 		}
+	}
+}
+
+// readReply reads ICMP messages until one answers a TCP probe to target or
+// deadline passes. It returns the reply without its RTT, the source port of
+// the probe it answers and when it arrived.
+func (t *TCPTracer) readReply(icmpConn *icmp.PacketConn, target net.IP, reply []byte, deadline time.Time) (*probeResult, int, time.Time, error) {
+	if err := icmpConn.SetReadDeadline(deadline); err != nil {
+		return nil, 0, time.Time{}, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	// Protocol number for parsing ICMP messages
+	protoNum := ICMPProtocolNum(target)
+	ipHdrSize := IPHeaderSize(target)
+	port := t.getPort()
+
+	for {
+		m, err := readICMP(icmpConn, target, reply)
 		if err != nil {
-			if isTimeout(err) {
-				continue // Loop back to check TCP and deadline
-			}
-			continue
+			return nil, 0, time.Time{}, err
 		}
+		t.stamps.received(m)
+		n, peerIP, end := m.n, m.peer, m.at
 
-		end := time.Now()
-		rtt := end.Sub(start)
+		// Response TTL for NAT detection (IPv4 only)
+		var responseTTL int
+		if t.config.DetectNAT {
+			responseTTL = m.ttl
+		}
 
 		// Parse the ICMP response
 		rm, err := icmp.ParseMessage(protoNum, reply[:n])
@@ -233,28 +312,32 @@ func (t *TCPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 			continue
 		}
 
-		peerIP := peer.(*net.IPAddr).IP
+		// Probes steered with SRv6 are quoted behind a Segment Routing Header
+		sr := unwrapSRv6(rm, target)
 
 		// Check for Time Exceeded (intermediate hop)
 		if isTimeExceeded(rm.Type, target) {
 			if body, ok := rm.Body.(*icmp.TimeExceeded); ok {
-				if t.isOurProbeForIP(body.Data, port, target) {
+				if t.isOurProbeForIP(body.Data, port, target) && quotedDstIs(body.Data, target) {
 					var mplsLabels []hop.MPLSLabel
 					var ifInfo *hop.InterfaceInfo
 					if n > 8 {
 						if ext := ExtractICMPExtensionsFromData(reply[8:n]); ext != nil {
 							mplsLabels = ext.MPLS
 							ifInfo = ext.InterfaceInfo
+							if sr == nil {
+								sr = ext.SegmentRouting
+							}
 						}
 					}
 					ipid := ExtractIPID(body.Data)
 					origTTL := ExtractOriginalTTL(body.Data)
-					ipHdrSize := IPHeaderSize(target)
 					var transportInfo *hop.TransportInfo
 					if t.config.Decode {
 						transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 					}
-					return &probeResult{IP: peerIP, RTT: rtt, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, TransportInfo: transportInfo}, nil
+					captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+					return &probeResult{IP: peerIP, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, SegmentRouting: sr, TransportInfo: transportInfo}, quotedSrcPort(body.Data, ipHdrSize), end, nil
 				}
 			}
 		}
@@ -262,7 +345,7 @@ func (t *TCPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 		// Check for Destination Unreachable (target reached but filtered)
 		if isDestUnreachable(rm.Type, target) {
 			if body, ok := rm.Body.(*icmp.DstUnreach); ok {
-				if t.isOurProbeForIP(body.Data, port, target) {
+				if t.isOurProbeForIP(body.Data, port, target) && quotedDstIs(body.Data, target) {
 					// Check for Fragmentation Needed (Code 4) with MTU discovery
 					var mtu int
 					if rm.Code == 4 && t.config.DiscoverMTU && n >= 8 {
@@ -273,19 +356,42 @@ func (t *TCPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 					}
 					ipid := ExtractIPID(body.Data)
 					origTTL := ExtractOriginalTTL(body.Data)
-					ipHdrSize := IPHeaderSize(target)
 					var transportInfo *hop.TransportInfo
 					if t.config.Decode {
 						transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 					}
-					return &probeResult{IP: peerIP, RTT: rtt, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, TransportInfo: transportInfo}, nil
+					captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+					return &probeResult{IP: peerIP, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, SegmentRouting: sr, TransportInfo: transportInfo}, quotedSrcPort(body.Data, ipHdrSize), end, nil
+				}
+			}
+		}
+
+		// Check for Packet Too Big (IPv6 routers never fragment)
+		if body, ok := rm.Body.(*icmp.PacketTooBig); ok && IsIPv6(target) {
+			if t.isOurProbeForIP(body.Data, port, target) && quotedDstIs(body.Data, target) {
+				pr := packetTooBigResult(body, peerIP, t.config.DiscoverMTU)
+				pr.SegmentRouting = sr
+				if t.config.Decode {
+					pr.TransportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 				}
+				captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+				return pr, quotedSrcPort(body.Data, ipHdrSize), end, nil
 			}
 		}
 
+		// Check deadline
+		if time.Now().After(deadline) {
+			return nil, 0, time.Time{}, &net.OpError{Op: "read", Err: &timeoutError{}}
+		}
 	}
 }
 
+// quotedSrcPort returns the source port of the TCP header quoted in an ICMP
+// error, after its ipHdrSize-byte IP header. The caller checks the length.
+func quotedSrcPort(data []byte, ipHdrSize int) int {
+	return int(data[ipHdrSize])<<8 | int(data[ipHdrSize+1])
+}
+
 // getPort returns the TCP destination port.
 func (t *TCPTracer) getPort() int {
 	return t.config.Port
@@ -296,22 +402,78 @@ func (t *TCPTracer) getTCPID() int {
 	return t.id
 }
 
-// checkTCPConnection checks if the TCP connection has completed.
-func (t *TCPTracer) checkTCPConnection(fd socketFD) bool {
+// checkTCPConnection checks if the TCP connection has completed. connected
+// is true for a full handshake and false when the target answered with RST.
+func (t *TCPTracer) checkTCPConnection(fd socketFD) (done, connected bool) {
 	// Use select with zero timeout to check if socket is writable
 	// A non-blocking socket becomes writable when connection completes (or fails)
 	ready, err := selectWrite(socketFDInt(fd))
 	if err != nil || !ready {
-		return false
+		return false, false
 	}
 
 	// Socket is writable - check SO_ERROR to see if connection succeeded or failed
 	val, err := getSocketError(fd)
 	if err != nil {
-		return false
+		return false, false
 	}
 	// val == 0 means connected, ECONNREFUSED means RST received (target reached)
-	return val == 0 || val == int(errConnRefused)
+	return val == 0 || val == int(errConnRefused), val == 0
+}
+
+// measureHandshake breaks a completed connect down into the network round
+// trip and the connect completion seen by us. The kernel times SYN to SYN-ACK
+// itself, so its RTT estimate excludes our polling delay. With EndToEnd, a TLS
+// handshake on the same connection shows how quickly the server answers.
+func (t *TCPTracer) measureHandshake(fd socketFD, connectRTT time.Duration) *hop.Handshake {
+	hs := &hop.Handshake{SYNRTT: connectRTT, ConnectRTT: connectRTT}
+	if rtt, ok := kernelSYNRTT(fd); ok && rtt > 0 && rtt <= connectRTT {
+		hs.SYNRTT = rtt
+	}
+
+	if t.config.EndToEnd {
+		if d, err := t.tlsHandshake(fd); err == nil {
+			hs.TLSRTT = d
+		}
+	}
+	return hs
+}
+
+// tlsHandshake performs a TLS handshake over the connected probe socket and
+// returns how long it took. Certificates are not verified: only timing matters.
+func (t *TCPTracer) tlsHandshake(fd socketFD) (time.Duration, error) {
+	conn, err := socketConn(fd)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         t.config.ServerName,
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.SetDeadline(time.Now().Add(t.config.Timeout)); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if err := tlsConn.Handshake(); err != nil {
+		return 0, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	return time.Since(start), nil
+}
+
+// isOurSourcePort checks that the embedded TCP header carries the probe's source port.
+// A zero srcPort (unknown) matches any packet.
+func (t *TCPTracer) isOurSourcePort(data []byte, srcPort int, target net.IP) bool {
+	if srcPort == 0 {
+		return true
+	}
+	ipHdrSize := IPHeaderSize(target)
+	if len(data) < ipHdrSize+2 {
+		return false
+	}
+	return int(data[ipHdrSize])<<8|int(data[ipHdrSize+1]) == srcPort
 }
 
 // isOurProbe checks if the ICMP response contains our original TCP packet (IPv4 only, for backward compatibility).
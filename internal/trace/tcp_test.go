@@ -1,8 +1,14 @@
 package trace
 
 import (
+	"io"
+	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestNewTCPTracer_CreatesTracer(t *testing.T) {
@@ -95,3 +101,57 @@ func TestTCPTracer_IsOurProbe_IPv6(t *testing.T) {
 		t.Error("expected different port to not match")
 	}
 }
+
+func TestTCPTracer_IsOurSourcePort(t *testing.T) {
+	tracer := NewTCPTracer(DefaultConfig())
+	target := net.ParseIP("192.0.2.1")
+
+	data := make([]byte, 28)
+	data[20] = 0x9c // Source port 40000
+	data[21] = 0x40
+
+	if !tracer.isOurSourcePort(data, 40000, target) {
+		t.Error("expected matching source port")
+	}
+	if tracer.isOurSourcePort(data, 40001, target) {
+		t.Error("expected mismatched source port to be rejected")
+	}
+	if !tracer.isOurSourcePort(data, 0, target) {
+		t.Error("unknown source port should match any packet")
+	}
+}
+
+func TestTCPTracer_MeasureHandshake_TimesTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // The probe hangs up right after the handshake
+	srv.StartTLS()
+	defer srv.Close()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+
+	fd, err := createRawSocket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	defer closeSocket(fd)
+	if err := connectSocket(fd, &syscall.SockaddrInet4{Port: addr.Port, Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Protocol = ProtocolTCP
+	cfg.Timeout = 2 * time.Second
+	cfg.EndToEnd = true
+	connectRTT := 50 * time.Millisecond
+
+	hs := NewTCPTracer(cfg).measureHandshake(fd, connectRTT)
+
+	if hs.ConnectRTT != connectRTT {
+		t.Errorf("expected connect RTT %v, got %v", connectRTT, hs.ConnectRTT)
+	}
+	if hs.SYNRTT <= 0 || hs.SYNRTT > connectRTT {
+		t.Errorf("expected SYN RTT in (0, %v], got %v", connectRTT, hs.SYNRTT)
+	}
+	if hs.TLSRTT <= 0 {
+		t.Error("expected TLS handshake to be timed")
+	}
+}
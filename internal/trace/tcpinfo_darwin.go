@@ -0,0 +1,20 @@
+//go:build darwin
+
+package trace
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelSYNRTT returns the kernel's RTT estimate for a freshly connected
+// socket. Right after the handshake it is the SYN to SYN-ACK round trip.
+// Darwin reports it with millisecond resolution only.
+func kernelSYNRTT(fd socketFD) (time.Duration, bool) {
+	info, err := unix.GetsockoptTCPConnectionInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_CONNECTION_INFO)
+	if err != nil || info.Rttcur == 0 {
+		return 0, false
+	}
+	return time.Duration(info.Rttcur) * time.Millisecond, true
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package trace
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelSYNRTT returns the kernel's RTT estimate for a freshly connected
+// socket. Right after the handshake it is the SYN to SYN-ACK round trip.
+func kernelSYNRTT(fd socketFD) (time.Duration, bool) {
+	info, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	if err != nil || info.Rtt == 0 {
+		return 0, false
+	}
+	return time.Duration(info.Rtt) * time.Microsecond, true
+}
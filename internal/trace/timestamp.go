@@ -0,0 +1,125 @@
+package trace
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+	"golang.org/x/net/icmp"
+)
+
+// listenICMP opens the listener for ICMP messages from target's family in
+// the network namespace netns (the current one if empty), asking the kernel
+// to timestamp received packets where supported.
+func listenICMP(netns string, target net.IP) (*icmp.PacketConn, error) {
+	var conn *icmp.PacketConn
+	err := inNetNS(netns, func() (err error) {
+		conn, err = icmp.ListenPacket(ICMPProtocol(target), ListenAddress(target))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMP socket: %w (try running with sudo)", err)
+	}
+	if raw := rawIPConn(conn, target); raw != nil && kernelRxTimestamps {
+		if sc, err := raw.SyscallConn(); err == nil {
+			// Without timestamps, replies are timed when read
+			_ = sc.Control(func(fd uintptr) { _ = enableRxTimestamps(int(fd)) })
+		}
+	}
+	return conn, nil
+}
+
+// rawIPConn returns the raw IP socket under conn, or nil if it is not one.
+func rawIPConn(conn *icmp.PacketConn, target net.IP) *net.IPConn {
+	var pc net.PacketConn
+	if IsIPv6(target) {
+		if p := conn.IPv6PacketConn(); p != nil {
+			pc = p.PacketConn
+		}
+	} else if p := conn.IPv4PacketConn(); p != nil {
+		pc = p.PacketConn
+	}
+	raw, _ := pc.(*net.IPConn)
+	return raw
+}
+
+// icmpMessage is an ICMP message read by readICMP.
+type icmpMessage struct {
+	n          int       // Length of the message at the start of the buffer
+	peer       net.IP    // Sender
+	ttl        int       // TTL of the IPv4 packet (0 if unknown)
+	at         time.Time // When the message was received
+	kernelTime bool      // Whether at is a kernel timestamp
+}
+
+// readICMP reads the next ICMP message from target's family into b. The
+// message is timestamped by the kernel when the listener was opened with
+// listenICMP on a supporting platform, otherwise when read.
+func readICMP(conn *icmp.PacketConn, target net.IP, b []byte) (icmpMessage, error) {
+	raw := rawIPConn(conn, target)
+	if raw == nil {
+		n, peer, err := conn.ReadFrom(b)
+		if err != nil {
+			return icmpMessage{}, err
+		}
+		return icmpMessage{n: n, peer: peer.(*net.IPAddr).IP, at: time.Now()}, nil
+	}
+
+	var oob [128]byte
+	n, oobn, _, addr, err := raw.ReadMsgIP(b, oob[:])
+	if err != nil {
+		return icmpMessage{}, err
+	}
+	m := icmpMessage{n: n, peer: addr.IP}
+	m.at, m.kernelTime = rxTimestamp(oob[:oobn])
+	if !m.kernelTime {
+		m.at = time.Now()
+	}
+
+	// Raw IPv4 sockets deliver the IP header, which carries the TTL
+	if !IsIPv6(target) {
+		hdrLen := int(b[0]&0x0f) << 2
+		if n < hdrLen || hdrLen < 20 {
+			m.n = 0 // Malformed: fails to parse as ICMP
+			return m, nil
+		}
+		m.ttl = int(b[8])
+		m.n = copy(b, b[hdrLen:n])
+	}
+	return m, nil
+}
+
+// timestampTracker records whether the probes of a trace were timed in user
+// space, to report the precision of its RTTs.
+type timestampTracker struct {
+	userSend    atomic.Bool
+	userReceive atomic.Bool
+}
+
+// reset starts tracking a trace. userSend tells whether its probes are timed
+// in user space when sent, as for every protocol without send timestamps.
+func (s *timestampTracker) reset(userSend bool) {
+	s.userSend.Store(userSend || !kernelTxTimestamps)
+	s.userReceive.Store(!kernelRxTimestamps)
+}
+
+// received records how a reply was timed.
+func (s *timestampTracker) received(m icmpMessage) {
+	if !m.kernelTime {
+		s.userReceive.Store(true)
+	}
+}
+
+// source returns the least precise timestamp source used by the trace.
+func (s *timestampTracker) source() string {
+	switch {
+	case s.userReceive.Load():
+		return hop.TimestampUserspace
+	case s.userSend.Load():
+		return hop.TimestampKernelReceive
+	default:
+		return hop.TimestampKernel
+	}
+}
@@ -0,0 +1,53 @@
+//go:build darwin
+
+package trace
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelRxTimestamps and kernelTxTimestamps report whether the kernel can
+// timestamp received and sent packets. Darwin has no send timestamps.
+const (
+	kernelRxTimestamps = true
+	kernelTxTimestamps = false
+)
+
+// enableRxTimestamps asks the kernel to timestamp packets received on fd,
+// with microsecond resolution.
+func enableRxTimestamps(fd int) error {
+	return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMP, 1)
+}
+
+// rxTimestamp returns the receive timestamp in the control messages oob.
+func rxTimestamp(oob []byte) (time.Time, bool) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, m := range msgs {
+		if m.Header.Level != unix.SOL_SOCKET || m.Header.Type != unix.SCM_TIMESTAMP {
+			continue
+		}
+		var tv unix.Timeval
+		if len(m.Data) < int(unsafe.Sizeof(tv)) {
+			return time.Time{}, false
+		}
+		tv = *(*unix.Timeval)(unsafe.Pointer(&m.Data[0]))
+		return time.Unix(tv.Unix()), true
+	}
+	return time.Time{}, false
+}
+
+// enableTxTimestamps reports that send timestamps are unavailable.
+func enableTxTimestamps(fd socketFD) bool {
+	return false
+}
+
+// txTimestamp reports that send timestamps are unavailable.
+func txTimestamp(fd socketFD) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package trace
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelRxTimestamps and kernelTxTimestamps report whether the kernel can
+// timestamp received and sent packets.
+const (
+	kernelRxTimestamps = true
+	kernelTxTimestamps = true
+)
+
+// txTimestampWait bounds how long to wait for the send timestamp of a probe,
+// which the kernel queues when the packet leaves the network stack.
+const txTimestampWait = 2 * time.Millisecond
+
+// enableRxTimestamps asks the kernel to timestamp packets received on fd,
+// with nanosecond resolution.
+func enableRxTimestamps(fd int) error {
+	return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
+}
+
+// rxTimestamp returns the receive timestamp in the control messages oob.
+func rxTimestamp(oob []byte) (time.Time, bool) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, m := range msgs {
+		if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPNS {
+			return timespecTime(m.Data)
+		}
+	}
+	return time.Time{}, false
+}
+
+// enableTxTimestamps asks the kernel to timestamp packets sent on fd with
+// SO_TIMESTAMPING, reporting whether it accepted.
+func enableTxTimestamps(fd socketFD) bool {
+	flags := unix.SOF_TIMESTAMPING_TX_SOFTWARE | unix.SOF_TIMESTAMPING_SOFTWARE | unix.SOF_TIMESTAMPING_OPT_TSONLY
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_TIMESTAMPING, flags) == nil
+}
+
+// txTimestamp returns the send timestamp of the packet just sent on fd, read
+// from its error queue.
+func txTimestamp(fd socketFD) (time.Time, bool) {
+	var b [64]byte
+	var oob [512]byte
+	deadline := time.Now().Add(txTimestampWait)
+	for {
+		_, oobn, _, _, err := unix.Recvmsg(int(fd), b[:], oob[:], unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+		if err == nil {
+			return sendTimestamp(oob[:oobn])
+		}
+		if err != unix.EAGAIN || time.Now().After(deadline) {
+			return time.Time{}, false
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// sendTimestamp returns the software timestamp of an SCM_TIMESTAMPING
+// control message in oob.
+func sendTimestamp(oob []byte) (time.Time, bool) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, m := range msgs {
+		// The first of its three timestamps is the software one
+		if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPING {
+			return timespecTime(m.Data)
+		}
+	}
+	return time.Time{}, false
+}
+
+// timespecTime decodes the timespec at the start of data.
+func timespecTime(data []byte) (time.Time, bool) {
+	var ts unix.Timespec
+	if len(data) < int(unsafe.Sizeof(ts)) {
+		return time.Time{}, false
+	}
+	ts = *(*unix.Timespec)(unsafe.Pointer(&data[0]))
+	if ts.Sec == 0 && ts.Nsec == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(ts.Unix()), true
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package trace
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketControlMessage builds a SOL_SOCKET control message of type typ
+// carrying data.
+func socketControlMessage(typ int32, data []byte) []byte {
+	b := make([]byte, unix.CmsgSpace(len(data)))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = unix.SOL_SOCKET
+	h.Type = typ
+	h.SetLen(unix.CmsgLen(len(data)))
+	copy(b[unix.CmsgLen(0):], data)
+	return b
+}
+
+func timespecBytes(t time.Time) []byte {
+	ts := unix.NsecToTimespec(t.UnixNano())
+	return unsafe.Slice((*byte)(unsafe.Pointer(&ts)), unsafe.Sizeof(ts))
+}
+
+func TestRxTimestamp_ParsesTimestampNS(t *testing.T) {
+	want := time.Unix(1700000000, 123456789)
+	got, ok := rxTimestamp(socketControlMessage(unix.SCM_TIMESTAMPNS, timespecBytes(want)))
+	if !ok || !got.Equal(want) {
+		t.Errorf("rxTimestamp = %v, %v; want %v", got, ok, want)
+	}
+}
+
+func TestRxTimestamp_MissingTimestamp(t *testing.T) {
+	if _, ok := rxTimestamp(nil); ok {
+		t.Error("expected no timestamp without control messages")
+	}
+	if _, ok := rxTimestamp(socketControlMessage(unix.SCM_CREDENTIALS, make([]byte, 12))); ok {
+		t.Error("expected no timestamp from another control message")
+	}
+}
+
+func TestSendTimestamp_UsesSoftwareTimestamp(t *testing.T) {
+	want := time.Unix(1700000000, 5000)
+	data := append(timespecBytes(want), make([]byte, 2*unsafe.Sizeof(unix.Timespec{}))...)
+	got, ok := sendTimestamp(socketControlMessage(unix.SCM_TIMESTAMPING, data))
+	if !ok || !got.Equal(want) {
+		t.Errorf("sendTimestamp = %v, %v; want %v", got, ok, want)
+	}
+}
+
+func TestSendTimestamp_IgnoresEmptyTimestamp(t *testing.T) {
+	data := make([]byte, 3*unsafe.Sizeof(unix.Timespec{}))
+	if _, ok := sendTimestamp(socketControlMessage(unix.SCM_TIMESTAMPING, data)); ok {
+		t.Error("expected a zero timestamp to be rejected")
+	}
+}
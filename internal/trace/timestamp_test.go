@@ -0,0 +1,37 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+func TestTimestampTracker_Source(t *testing.T) {
+	var s timestampTracker
+
+	s.reset(false)
+	s.received(icmpMessage{kernelTime: true})
+	want := hop.TimestampKernel
+	if !kernelTxTimestamps {
+		want = hop.TimestampKernelReceive
+	}
+	if got := s.source(); got != want {
+		t.Errorf("kernel-timed trace source = %q, want %q", got, want)
+	}
+
+	s.reset(true)
+	s.received(icmpMessage{kernelTime: true})
+	if got := s.source(); got != hop.TimestampKernelReceive {
+		t.Errorf("user-space send source = %q, want %q", got, hop.TimestampKernelReceive)
+	}
+
+	s.received(icmpMessage{})
+	if got := s.source(); got != hop.TimestampUserspace {
+		t.Errorf("user-space receive source = %q, want %q", got, hop.TimestampUserspace)
+	}
+
+	s.reset(true)
+	if got := s.source(); got == hop.TimestampUserspace {
+		t.Error("expected reset to clear user-space receive timing")
+	}
+}
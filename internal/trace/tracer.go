@@ -4,9 +4,11 @@ package trace
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"net"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/logging"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -17,6 +19,8 @@ const (
 	ProtocolICMP Protocol = "icmp"
 	ProtocolUDP  Protocol = "udp"
 	ProtocolTCP  Protocol = "tcp"
+	ProtocolQUIC Protocol = "quic" // UDP datagrams carrying QUIC Initial packets
+	ProtocolAuto Protocol = "auto" // ICMP, falling back to UDP then TCP when blackholed
 )
 
 // AddressFamily specifies the preferred IP version for target resolution.
@@ -37,13 +41,21 @@ type Config struct {
 	MaxHops       int
 	PacketsPerHop int
 	Timeout       time.Duration
-	Port          int    // For UDP/TCP
-	SourceAddr    string // Source address to use
-	DetectNAT     bool   // Enable NAT detection via TTL analysis
-	ECMPFlows     int    // ECMP flow variations per hop (0=disabled)
-	DiscoverMTU   bool   // Enable Path MTU Discovery
-	ProbeSize     int    // Probe packet size in bytes
-	Decode        bool   // Extract transport header info from ICMP errors
+	Port          int          // For UDP/TCP
+	SourceAddr    string       // Source address to use
+	DetectNAT     bool         // Enable NAT detection via TTL analysis
+	ECMPFlows     int          // ECMP flow variations per hop (0=disabled)
+	DiscoverMTU   bool         // Enable Path MTU Discovery
+	ProbeSize     int          // Probe packet size in bytes
+	Decode        bool         // Extract transport header info from ICMP errors
+	Capture       CaptureSink  // Receives raw probe/response packets (nil = disabled)
+	Shards        int          // Concurrent UDP/TCP probe workers per trace (0/1 = sequential)
+	EndToEnd      bool         // TCP: complete a TLS handshake when the target accepts
+	ServerName    string       // TLS server name for EndToEnd and QUIC probes (default: target IP, no SNI for QUIC)
+	Scheduler     Scheduler    // Paces probes, possibly across traces (nil = as fast as replies allow)
+	Parallel      bool         // ICMP/UDP: probe all TTLs at once instead of one after another
+	NetNS         string       // Linux network namespace to trace from (name or path, empty = current)
+	IPv6          *IPv6Options // Flow label and extension headers of IPv6 probes (nil = kernel defaults)
 }
 
 // DefaultConfig returns the default traceroute configuration.
@@ -61,10 +73,10 @@ func DefaultConfig() *Config {
 // Validate checks if the configuration is valid.
 func (c *Config) Validate() error {
 	switch c.Protocol {
-	case ProtocolICMP, ProtocolUDP, ProtocolTCP:
+	case ProtocolICMP, ProtocolUDP, ProtocolTCP, ProtocolQUIC, ProtocolAuto:
 		// Valid
 	default:
-		return errors.New("invalid protocol: must be icmp, udp, or tcp")
+		return errors.New("invalid protocol: must be icmp, udp, tcp, quic or auto")
 	}
 
 	if c.MaxHops <= 0 {
@@ -79,12 +91,49 @@ func (c *Config) Validate() error {
 		return errors.New("timeout must be positive")
 	}
 
+	if c.Shards < 0 {
+		return errors.New("shards must not be negative")
+	}
+
+	if c.Shards > 1 && c.Protocol == ProtocolICMP {
+		return errors.New("sharding is only supported for udp, tcp and quic")
+	}
+
+	if c.NetNS != "" {
+		if err := checkNetNS(c.NetNS); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // HopCallback is called when a hop is received during tracing.
 type HopCallback func(*hop.Hop)
 
+// startTrace logs the start of a trace with the logger of ctx and returns
+// callback wrapped to also log each hop, at debug level.
+func startTrace(ctx context.Context, cfg *Config, target net.IP, callback HopCallback) (*slog.Logger, HopCallback) {
+	log := logging.FromContext(ctx).With("target", target.String(), "protocol", string(cfg.Protocol))
+	log.Debug("trace started", "max_hops", cfg.MaxHops, "packets", cfg.PacketsPerHop, "timeout", cfg.Timeout)
+	return log, func(h *hop.Hop) {
+		log.Debug("hop probed", "ttl", h.TTL, "ip", h.PrimaryIP(), "avg_rtt", h.AvgRTT(), "loss", h.LossPercent())
+		if callback != nil {
+			callback(h)
+		}
+	}
+}
+
+// finishTrace logs the end of the trace described by result.
+func finishTrace(log *slog.Logger, result *hop.TraceResult, err error) {
+	if err != nil {
+		log.Debug("trace failed", "hops", len(result.Hops), "err", err)
+		return
+	}
+	log.Debug("trace finished", "hops", len(result.Hops), "reached", result.ReachedTarget,
+		"duration", result.EndTime.Sub(result.StartTime))
+}
+
 // Tracer is the interface for traceroute implementations.
 type Tracer interface {
 	// Trace performs a traceroute to the target IP.
@@ -92,12 +141,36 @@ type Tracer interface {
 	Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error)
 }
 
+// resolver resolves target hostnames: the system resolver unless replaced
+// with SetResolver.
+var resolver = net.DefaultResolver
+
+// SetResolver makes target resolution use r, e.g. to query a specific DNS
+// server instead of the system's. A nil r restores the system resolver.
+func SetResolver(r *net.Resolver) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	resolver = r
+}
+
 // ResolveTarget resolves a hostname or IP string to a net.IP.
 // The af parameter controls IP version preference:
 //   - AddressFamilyAuto: Prefer IPv4, fall back to IPv6
 //   - AddressFamilyIPv4: Only return IPv4 addresses
 //   - AddressFamilyIPv6: Only return IPv6 addresses
 func ResolveTarget(target string, af AddressFamily) (net.IP, error) {
+	ips, err := ResolveTargetAll(target, af)
+	if err != nil {
+		return nil, err
+	}
+	return ips[0], nil
+}
+
+// ResolveTargetAll resolves a hostname or IP string to every address of the
+// family ResolveTarget would pick, in resolver order. ResolveTarget returns
+// the first one.
+func ResolveTargetAll(target string, af AddressFamily) ([]net.IP, error) {
 	// First, try to parse as an IP address
 	ip := net.ParseIP(target)
 	if ip != nil {
@@ -113,11 +186,11 @@ func ResolveTarget(target string, af AddressFamily) (net.IP, error) {
 				return nil, errors.New("IPv4 address provided but IPv6 required (-6 flag)")
 			}
 		}
-		return ip, nil
+		return []net.IP{ip}, nil
 	}
 
 	// Otherwise, resolve as hostname
-	ips, err := net.LookupIP(target)
+	ips, err := resolver.LookupIP(context.Background(), "ip", target)
 	if err != nil {
 		return nil, err
 	}
@@ -127,34 +200,116 @@ func ResolveTarget(target string, af AddressFamily) (net.IP, error) {
 	}
 
 	// Filter and select based on address family
-	var v4Addrs, v6Addrs []net.IP
-	for _, ip := range ips {
-		if ip.To4() != nil {
-			v4Addrs = append(v4Addrs, ip)
-		} else {
-			v6Addrs = append(v6Addrs, ip)
-		}
-	}
+	v4Addrs, v6Addrs := splitAddressFamilies(ips)
 
 	switch af {
 	case AddressFamilyIPv4:
 		if len(v4Addrs) == 0 {
 			return nil, errors.New("no IPv4 address found for hostname (try without -4 flag)")
 		}
-		return v4Addrs[0], nil
+		return v4Addrs, nil
 	case AddressFamilyIPv6:
 		if len(v6Addrs) == 0 {
 			return nil, errors.New("no IPv6 address found for hostname (try without -6 flag)")
 		}
-		return v6Addrs[0], nil
+		return v6Addrs, nil
 	default: // AddressFamilyAuto
 		// Prefer IPv4
 		if len(v4Addrs) > 0 {
-			return v4Addrs[0], nil
+			return v4Addrs, nil
 		}
 		if len(v6Addrs) > 0 {
-			return v6Addrs[0], nil
+			return v6Addrs, nil
 		}
 		return nil, errors.New("no IP addresses found for hostname")
 	}
 }
+
+// ResolveDualStack resolves a hostname to one IPv4 and one IPv6 address.
+// Returns an error unless the target has both A and AAAA records.
+// IP literals are rejected since they only carry a single address family.
+func ResolveDualStack(target string) (v4, v6 net.IP, err error) {
+	if net.ParseIP(target) != nil {
+		return nil, nil, errors.New("dual-stack tracing requires a hostname, not an IP address")
+	}
+
+	ips, err := resolver.LookupIP(context.Background(), "ip", target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return selectDualStack(ips)
+}
+
+// selectDualStack picks the first IPv4 and first IPv6 address from ips.
+func selectDualStack(ips []net.IP) (v4, v6 net.IP, err error) {
+	v4Addrs, v6Addrs := splitAddressFamilies(ips)
+	if len(v4Addrs) == 0 {
+		return nil, nil, errors.New("no IPv4 (A) address found for hostname")
+	}
+	if len(v6Addrs) == 0 {
+		return nil, nil, errors.New("no IPv6 (AAAA) address found for hostname")
+	}
+	return v4Addrs[0], v6Addrs[0], nil
+}
+
+// ResolveAllAddresses resolves a hostname to every A and AAAA record, IPv4
+// first, limited to af when it is not AddressFamilyAuto. Used to trace each
+// address of an anycast or multi-CDN name.
+func ResolveAllAddresses(target string, af AddressFamily) ([]net.IP, error) {
+	if net.ParseIP(target) != nil {
+		return nil, errors.New("tracing all addresses requires a hostname, not an IP address")
+	}
+
+	ips, err := resolver.LookupIP(context.Background(), "ip", target)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectAllAddresses(ips, af)
+}
+
+// selectAllAddresses orders ips IPv4 first and keeps those of family af.
+func selectAllAddresses(ips []net.IP, af AddressFamily) ([]net.IP, error) {
+	v4Addrs, v6Addrs := splitAddressFamilies(ips)
+	switch af {
+	case AddressFamilyIPv4:
+		v6Addrs = nil
+	case AddressFamilyIPv6:
+		v4Addrs = nil
+	}
+	all := append(v4Addrs, v6Addrs...)
+	if len(all) == 0 {
+		return nil, errors.New("no matching IP address found for hostname")
+	}
+	return all, nil
+}
+
+// splitAddressFamilies partitions ips into IPv4 and IPv6 addresses, preserving order.
+func splitAddressFamilies(ips []net.IP) (v4, v6 []net.IP) {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	return v4, v6
+}
+
+// probesPerTTL returns the number of probes per TTL: one per ECMP flow when
+// enabled, PacketsPerHop otherwise.
+func probesPerTTL(cfg *Config) int {
+	if cfg.ECMPFlows > 0 {
+		return cfg.ECMPFlows
+	}
+	return cfg.PacketsPerHop
+}
+
+// probeSeq returns the number of probe index of ttl, unique within a trace of
+// probeCount probes per TTL and at most 16 bits (the ICMP sequence number).
+// Probes share it whether TTLs are probed one after another, sharded or in
+// parallel.
+func probeSeq(ttl, index, probeCount int) int {
+	return ((ttl-1)*probeCount + index + 1) & 0xffff
+}
@@ -1,11 +1,14 @@
 package trace
 
 import (
+	"bytes"
 	"context"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/hervehildenbrand/gtrace/internal/logging"
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 )
 
@@ -83,6 +86,22 @@ func TestResolveTarget_AcceptsIPAddress(t *testing.T) {
 	}
 }
 
+func TestResolveTargetAll_LocalhostFamilies(t *testing.T) {
+	ips, err := ResolveTargetAll("localhost", AddressFamilyIPv4)
+	if err != nil {
+		t.Skipf("localhost does not resolve: %v", err)
+	}
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			t.Errorf("expected only IPv4 addresses, got %v", ips)
+		}
+	}
+	first, err := ResolveTarget("localhost", AddressFamilyIPv4)
+	if err != nil || !first.Equal(ips[0]) {
+		t.Errorf("ResolveTarget() = %v, %v, want the first of %v", first, err, ips)
+	}
+}
+
 func TestResolveTarget_RejectsInvalidHostname(t *testing.T) {
 	_, err := ResolveTarget("this.hostname.definitely.does.not.exist.invalid", AddressFamilyAuto)
 
@@ -189,3 +208,26 @@ func TestTracer_Interface_AcceptsCallback(t *testing.T) {
 		t.Errorf("expected 1 hop, got %d", result.TotalHops())
 	}
 }
+
+func TestStartTrace_LogsHopsWithContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := logging.New(&buf, logging.FormatText, true)
+	ctx := logging.With(logging.NewContext(context.Background(), l), logging.TraceIDKey, "abc123")
+
+	var called bool
+	log, callback := startTrace(ctx, DefaultConfig(), net.ParseIP("8.8.8.8"), func(*hop.Hop) { called = true })
+	h := hop.NewHop(1)
+	h.AddProbe(net.ParseIP("192.168.1.1"), 5*time.Millisecond)
+	callback(h)
+	finishTrace(log, hop.NewTraceResult("8.8.8.8", "8.8.8.8"), nil)
+
+	if !called {
+		t.Error("expected the wrapped callback to be called")
+	}
+	out := buf.String()
+	for _, want := range []string{"trace started", "hop probed", "ip=192.168.1.1", "trace finished", "trace_id=abc123", "target=8.8.8.8"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in logs:\n%s", want, out)
+		}
+	}
+}
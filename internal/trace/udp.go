@@ -10,13 +10,14 @@ import (
 
 	"github.com/hervehildenbrand/gtrace/pkg/hop"
 	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
 // UDPTracer implements traceroute using UDP probes.
 type UDPTracer struct {
 	config *Config
 	id     int
+	srcIP  net.IP           // Local source address, resolved only when capturing
+	stamps timestampTracker // How the probes of the current trace were timed
 }
 
 // NewUDPTracer creates a new UDP tracer with the given configuration.
@@ -30,20 +31,51 @@ func NewUDPTracer(cfg *Config) *UDPTracer {
 // Trace performs a UDP traceroute to the target IP.
 // Supports both IPv4 and IPv6 targets.
 func (t *UDPTracer) Trace(ctx context.Context, target net.IP, callback HopCallback) (*hop.TraceResult, error) {
+	if err := t.config.IPv6.check(target); err != nil {
+		return nil, err
+	}
+
 	result := hop.NewTraceResult(target.String(), target.String())
 	result.Protocol = string(ProtocolUDP)
 	result.StartTime = time.Now()
+	t.stamps.reset(false)
+	log, callback := startTrace(ctx, t.config, target, callback)
+
+	if t.config.Capture != nil {
+		t.srcIP = captureSourceIP(t.config.NetNS, target)
+	}
+
+	// Spread TTLs across concurrent workers when sharding is enabled
+	if t.config.Shards > 1 {
+		err := traceSharded(ctx, t.config.NetNS, target, t.config.Shards, t.config.MaxHops, t.replyReader(target), t.probeHop, callback, result)
+		result.EndTime = time.Now()
+		result.TimestampSource = t.stamps.source()
+		finishTrace(log, result, err)
+		return result, err
+	}
 
 	// Open raw socket for receiving ICMP responses based on IP version
-	proto := ICMPProtocol(target)
-	listenAddr := ListenAddress(target)
-	icmpConn, err := icmp.ListenPacket(proto, listenAddr)
+	icmpConn, err := listenICMP(t.config.NetNS, target)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open ICMP socket: %w (try running with sudo)", err)
+		return nil, err
 	}
 	defer icmpConn.Close()
 
-	probeNum := 0
+	// A single receive loop hands each reply to the probe it answers
+	d := newDemux(icmpConn, t.replyReader(target)(icmpConn))
+	defer d.close()
+
+	if t.config.Parallel {
+		p := &udpParallelProber{t: t, target: target, probeCount: probesPerTTL(t.config)}
+		if uniqueKeys(p, t.config.MaxHops, p.probeCount) {
+			err := traceParallel(ctx, t.config, target, p, d, callback, result)
+			result.EndTime = time.Now()
+			result.TimestampSource = t.stamps.source()
+			finishTrace(log, result, err)
+			return result, err
+		}
+	}
+
 	for ttl := 1; ttl <= t.config.MaxHops; ttl++ {
 		select {
 		case <-ctx.Done():
@@ -51,102 +83,135 @@ func (t *UDPTracer) Trace(ctx context.Context, target net.IP, callback HopCallba
 		default:
 		}
 
-		h := hop.NewHop(ttl)
-		reached := false
+		h, reached := t.probeHop(ctx, d, target, ttl)
 
-		probeCount := t.config.PacketsPerHop
-		if t.config.ECMPFlows > 0 {
-			probeCount = t.config.ECMPFlows
+		result.AddHop(h)
+		if callback != nil {
+			callback(h)
 		}
 
-		for i := 0; i < probeCount; i++ {
-			probeNum++
-			flowID := 0
-			if t.config.ECMPFlows > 0 {
-				flowID = i + 1
-			}
-			pr, err := t.sendProbe(icmpConn, target, ttl, probeNum)
-			if err != nil {
-				if isTimeout(err) {
-					h.AddTimeout()
-				} else {
-					h.AddTimeout()
-				}
-				continue
-			}
+		if reached {
+			result.ReachedTarget = true
+			break
+		}
+	}
 
-			// Set MTU if discovered (may come from EMSGSIZE with nil IP)
-			if pr.MTU > 0 && h.MTU == 0 {
-				h.MTU = pr.MTU
-			}
+	result.EndTime = time.Now()
+	result.TimestampSource = t.stamps.source()
+	finishTrace(log, result, nil)
+	return result, nil
+}
 
-			// EMSGSIZE returns nil IP - record as timeout
-			if pr.IP == nil {
-				h.AddTimeout()
-				continue
-			}
+// probeHop sends all probes for a single TTL and returns the resulting hop.
+// The probe sequence number is derived from the TTL, so destination ports are
+// the same whether hops are probed sequentially or sharded across workers.
+func (t *UDPTracer) probeHop(ctx context.Context, d *demux, target net.IP, ttl int) (*hop.Hop, bool) {
+	h := hop.NewHop(ttl)
+	reached := false
+	if err := nextTTL(ctx, t.config.Scheduler, ttl); err != nil {
+		return h, false
+	}
 
-			probe := hop.Probe{IP: pr.IP, RTT: pr.RTT, ResponseTTL: pr.ResponseTTL, IPID: pr.IPID, ICMPType: pr.ICMPType, ICMPCode: pr.ICMPCode, OriginalTTL: pr.OriginalTTL, FlowID: flowID, TransportInfo: pr.TransportInfo}
-			h.Probes = append(h.Probes, probe)
+	probeCount := probesPerTTL(t.config)
 
-			// Set MPLS labels if discovered (first probe with labels wins)
-			if len(pr.MPLS) > 0 && len(h.MPLS) == 0 {
-				h.SetMPLS(pr.MPLS)
-			}
+	for i := 0; i < probeCount; i++ {
+		probeNum := probeSeq(ttl, i, probeCount)
+		flowID := 0
+		if t.config.ECMPFlows > 0 {
+			flowID = i + 1
+		}
+		pr, err := scheduleProbe(ctx, t.config.Scheduler, func() (*probeResult, error) {
+			return t.sendProbe(d, target, ttl, probeNum)
+		})
+		if err != nil {
+			h.AddTimeout()
+			continue
+		}
 
-			// Set interface info if discovered
-			if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
-				h.InterfaceInfo = pr.InterfaceInfo
-			}
+		// Set MTU if discovered (may come from EMSGSIZE with nil IP)
+		if pr.MTU > 0 && h.MTU == 0 {
+			h.MTU = pr.MTU
+		}
 
-			if pr.IP.Equal(target) {
-				reached = true
-			}
+		// EMSGSIZE returns nil IP - record as timeout
+		if pr.IP == nil {
+			h.AddTimeout()
+			continue
 		}
 
-		// NAT detection: IP-based (Tier 1) and TTL-based (Tier 2) only.
-		// See icmp.go comment for why IP ID analysis (Tier 3) is not used.
-		if t.config.DetectNAT {
-			for _, p := range h.Probes {
-				if p.Timeout || p.IP == nil {
-					continue
-				}
-				if DetectNATFromIP(p.IP, ttl) {
-					h.NAT = true
-					break
-				}
-				if p.ResponseTTL > 0 && DetectNATFromTTL(ttl, p.ResponseTTL) {
-					h.NAT = true
-					break
-				}
-			}
+		probe := hop.Probe{IP: pr.IP, RTT: pr.RTT, ResponseTTL: pr.ResponseTTL, IPID: pr.IPID, ICMPType: pr.ICMPType, ICMPCode: pr.ICMPCode, OriginalTTL: pr.OriginalTTL, FlowID: flowID, TransportInfo: pr.TransportInfo}
+		h.Probes = append(h.Probes, probe)
+
+		// Set MPLS labels if discovered (first probe with labels wins)
+		if len(pr.MPLS) > 0 && len(h.MPLS) == 0 {
+			h.SetMPLS(pr.MPLS)
 		}
 
-		result.AddHop(h)
-		if callback != nil {
-			callback(h)
+		// Set interface info if discovered
+		if pr.InterfaceInfo != nil && h.InterfaceInfo == nil {
+			h.InterfaceInfo = pr.InterfaceInfo
+		}
+		if pr.SegmentRouting != nil && h.SegmentRouting == nil {
+			h.SegmentRouting = pr.SegmentRouting
 		}
 
-		if reached {
-			result.ReachedTarget = true
-			break
+		if pr.IP.Equal(target) {
+			reached = true
 		}
 	}
 
-	result.EndTime = time.Now()
-	return result, nil
+	// NAT detection: IP-based (Tier 1) and TTL-based (Tier 2) only.
+	// See icmp.go comment for why IP ID analysis (Tier 3) is not used.
+	if t.config.DetectNAT {
+		for _, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				continue
+			}
+			if DetectNATFromIP(p.IP, ttl) {
+				h.NAT = true
+				break
+			}
+			if p.ResponseTTL > 0 && DetectNATFromTTL(ttl, p.ResponseTTL) {
+				h.NAT = true
+				break
+			}
+		}
+	}
+
+	return h, reached
 }
 
-// sendProbe sends a single UDP probe and waits for ICMP response.
-// Supports both IPv4 and IPv6 targets.
-func (t *UDPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq int) (*probeResult, error) {
+// sendProbe sends a single UDP probe and waits for the ICMP response d hands
+// to its destination port. Supports both IPv4 and IPv6 targets.
+func (t *UDPTracer) sendProbe(d *demux, target net.IP, ttl, seq int) (*probeResult, error) {
 	port := t.getPort(seq)
+	ch := d.expect(port)
+	defer d.unregister(port)
+
+	_, start, pr, err := t.sendDatagram(target, ttl, seq)
+	if err != nil || pr != nil {
+		return pr, err
+	}
+
+	pr, end, err := d.wait(ch, start.Add(t.config.Timeout))
+	if err != nil {
+		return nil, err
+	}
+	pr.RTT = end.Sub(start)
+	return pr, nil
+}
+
+// sendDatagram sends a UDP probe with the given TTL to the port of seq and
+// returns the port and when the probe was sent. The result is already known
+// (pr non-nil) when the datagram exceeds the local MTU during MTU discovery.
+func (t *UDPTracer) sendDatagram(target net.IP, ttl, seq int) (port int, start time.Time, pr *probeResult, err error) {
+	port = t.getPort(seq)
 
 	// Create UDP socket with specific TTL/Hop Limit
 	domain := SocketDomain(target)
-	fd, err := createRawSocket(domain, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	fd, err := createSocketIn(t.config.NetNS, domain, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create UDP socket: %w", err)
+		return port, start, nil, fmt.Errorf("failed to create UDP socket: %w", err)
 	}
 	defer closeSocket(fd)
 
@@ -154,70 +219,89 @@ func (t *UDPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 	level := ProtocolLevel(target)
 	opt := TTLSocketOption(target)
 	if err := setSocketTTL(fd, level, opt, ttl); err != nil {
-		return nil, fmt.Errorf("failed to set TTL/hop limit: %w", err)
+		return port, start, nil, fmt.Errorf("failed to set TTL/hop limit: %w", err)
 	}
 
-	// Set Don't Fragment bit for MTU discovery (IPv4 only)
-	if t.config.DiscoverMTU && !IsIPv6(target) {
-		if err := setDontFragment(fd); err != nil {
-			return nil, fmt.Errorf("failed to set DF bit: %w", err)
+	// Set Don't Fragment bit for MTU discovery
+	if t.config.DiscoverMTU {
+		if err := setDontFragmentFor(fd, target); err != nil {
+			return port, start, nil, fmt.Errorf("failed to set DF bit: %w", err)
 		}
 	}
 
-	// Build destination address
-	sa := buildSockaddr(target, port)
+	if err := t.config.IPv6.apply(fd, target); err != nil {
+		return port, start, nil, err
+	}
 
 	// Build payload
 	payload := t.buildPayload(ttl, seq)
 
-	start := time.Now()
+	// Prefer the kernel's send timestamp to the time read before sending
+	kernelSend := enableTxTimestamps(fd)
+	start = time.Now()
 
 	// Send UDP packet
-	if err := sendToSocket(fd, payload, 0, sa); err != nil {
+	if err := sendProbeTo(fd, payload, target, port, t.config.IPv6); err != nil {
 		// EMSGSIZE means packet exceeds local interface MTU with DF bit set
 		if t.config.DiscoverMTU && isEMSGSIZE(err) {
-			return &probeResult{MTU: StandardMTU}, nil
+			return port, start, &probeResult{MTU: StandardMTU}, nil
+		}
+		return port, start, nil, fmt.Errorf("failed to send UDP: %w", err)
+	}
+	if kernelSend {
+		var sent time.Time
+		if sent, kernelSend = txTimestamp(fd); kernelSend {
+			start = sent
 		}
-		return nil, fmt.Errorf("failed to send UDP: %w", err)
+	}
+	if !kernelSend {
+		t.stamps.userSend.Store(true)
 	}
 
-	// Set read deadline on ICMP socket
-	deadline := start.Add(t.config.Timeout)
+	if t.config.Capture != nil {
+		seg := buildUDPSegment(t.srcIP, target, socketLocalPort(fd), port, payload)
+		capturePacket(t.config.Capture, start, t.srcIP, target, ipProtoUDP, ttl, seg)
+	}
+	return port, start, nil, nil
+}
+
+// replyReader returns a reader of the replies to probes to target arriving on
+// an ICMP listener, for a demux.
+func (t *UDPTracer) replyReader(target net.IP) func(*icmp.PacketConn) replyReader {
+	return func(icmpConn *icmp.PacketConn) replyReader {
+		reply := make([]byte, 1500)
+		return func(deadline time.Time) (*probeResult, int, time.Time, error) {
+			return t.readReply(icmpConn, target, reply, deadline)
+		}
+	}
+}
+
+// readReply reads ICMP messages until one answers a UDP probe to target or
+// deadline passes. It returns the reply without its RTT, the destination port of the
+// probe it answers and when it arrived.
+func (t *UDPTracer) readReply(icmpConn *icmp.PacketConn, target net.IP, reply []byte, deadline time.Time) (*probeResult, int, time.Time, error) {
 	if err := icmpConn.SetReadDeadline(deadline); err != nil {
-		return nil, fmt.Errorf("failed to set deadline: %w", err)
+		return nil, 0, time.Time{}, fmt.Errorf("failed to set deadline: %w", err)
 	}
 
 	// Protocol number for parsing ICMP messages
 	protoNum := ICMPProtocolNum(target)
-
-	// Enable TTL control messages for NAT detection (IPv4 only)
-	isV6 := IsIPv6(target)
-	if !isV6 && t.config.DetectNAT {
-		_ = icmpConn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
-	}
+	ipHdrSize := IPHeaderSize(target)
 
 	// Wait for ICMP response
-	reply := make([]byte, 1500)
 	for {
-		var n int
-		var peer net.Addr
-		var responseTTL int
-
-		if !isV6 && t.config.DetectNAT {
-			var cm *ipv4.ControlMessage
-			n, cm, peer, err = icmpConn.IPv4PacketConn().ReadFrom(reply)
-			if cm != nil {
-				responseTTL = cm.TTL
-			}
-		} else {
-			n, peer, err = icmpConn.ReadFrom(reply)
-		}
+		m, err := readICMP(icmpConn, target, reply)
 		if err != nil {
-			return nil, err
+			return nil, 0, time.Time{}, err
 		}
+		t.stamps.received(m)
+		n, peerIP, end := m.n, m.peer, m.at
 
-		end := time.Now()
-		rtt := end.Sub(start)
+		// Response TTL for NAT detection (IPv4 only)
+		var responseTTL int
+		if t.config.DetectNAT {
+			responseTTL = m.ttl
+		}
 
 		// Parse the ICMP response
 		rm, err := icmp.ParseMessage(protoNum, reply[:n])
@@ -225,28 +309,32 @@ func (t *UDPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 			continue
 		}
 
-		peerIP := peer.(*net.IPAddr).IP
+		// Probes steered with SRv6 are quoted behind a Segment Routing Header
+		sr := unwrapSRv6(rm, target)
 
 		// Check for Time Exceeded (intermediate hop)
 		if isTimeExceeded(rm.Type, target) {
 			if body, ok := rm.Body.(*icmp.TimeExceeded); ok {
-				if t.isOurProbeForIP(body.Data, port, target) {
+				if port, ok := quotedDstPort(body.Data, ipHdrSize); ok && quotedDstIs(body.Data, target) {
 					var mplsLabels []hop.MPLSLabel
 					var ifInfo *hop.InterfaceInfo
 					if n > 8 {
 						if ext := ExtractICMPExtensionsFromData(reply[8:n]); ext != nil {
 							mplsLabels = ext.MPLS
 							ifInfo = ext.InterfaceInfo
+							if sr == nil {
+								sr = ext.SegmentRouting
+							}
 						}
 					}
 					ipid := ExtractIPID(body.Data)
 					origTTL := ExtractOriginalTTL(body.Data)
-					ipHdrSize := IPHeaderSize(target)
 					var transportInfo *hop.TransportInfo
 					if t.config.Decode {
 						transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 					}
-					return &probeResult{IP: peerIP, RTT: rtt, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, TransportInfo: transportInfo}, nil
+					captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+					return &probeResult{IP: peerIP, MPLS: mplsLabels, ResponseTTL: responseTTL, IPID: ipid, ICMPType: 11, ICMPCode: rm.Code, OriginalTTL: origTTL, InterfaceInfo: ifInfo, SegmentRouting: sr, TransportInfo: transportInfo}, port, end, nil
 				}
 			}
 		}
@@ -254,7 +342,7 @@ func (t *UDPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 		// Check for Destination Unreachable (target reached, port unreachable)
 		if isDestUnreachable(rm.Type, target) {
 			if body, ok := rm.Body.(*icmp.DstUnreach); ok {
-				if t.isOurProbeForIP(body.Data, port, target) {
+				if port, ok := quotedDstPort(body.Data, ipHdrSize); ok && quotedDstIs(body.Data, target) {
 					// Check for Fragmentation Needed (Code 4) with MTU discovery
 					var mtu int
 					if rm.Code == 4 && t.config.DiscoverMTU && n >= 8 {
@@ -265,23 +353,48 @@ func (t *UDPTracer) sendProbe(icmpConn *icmp.PacketConn, target net.IP, ttl, seq
 					}
 					ipid := ExtractIPID(body.Data)
 					origTTL := ExtractOriginalTTL(body.Data)
-					ipHdrSize := IPHeaderSize(target)
 					var transportInfo *hop.TransportInfo
 					if t.config.Decode {
 						transportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 					}
-					return &probeResult{IP: peerIP, RTT: rtt, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, TransportInfo: transportInfo}, nil
+					captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+					return &probeResult{IP: peerIP, ResponseTTL: responseTTL, MTU: mtu, IPID: ipid, ICMPType: 3, ICMPCode: rm.Code, OriginalTTL: origTTL, SegmentRouting: sr, TransportInfo: transportInfo}, port, end, nil
+				}
+			}
+		}
+
+		// Check for Packet Too Big (IPv6 routers never fragment)
+		if body, ok := rm.Body.(*icmp.PacketTooBig); ok && IsIPv6(target) {
+			if port, ok := quotedDstPort(body.Data, ipHdrSize); ok && quotedDstIs(body.Data, target) {
+				pr := packetTooBigResult(body, peerIP, t.config.DiscoverMTU)
+				pr.SegmentRouting = sr
+				if t.config.Decode {
+					pr.TransportInfo = ExtractTransportInfo(body.Data, ipHdrSize, string(t.config.Protocol))
 				}
+				captureResponse(t.config.Capture, end, peerIP, t.srcIP, responseTTL, reply[:n])
+				return pr, port, end, nil
 			}
 		}
 
 		// Check deadline
 		if time.Now().After(deadline) {
-			return nil, &net.OpError{Op: "read", Err: &timeoutError{}}
+			return nil, 0, time.Time{}, &net.OpError{Op: "read", Err: &timeoutError{}}
 		}
 	}
 }
 
+// quotedDstPort returns the destination port of the UDP or TCP header quoted
+// in an ICMP error, after its ipHdrSize-byte IP header.
+func quotedDstPort(data []byte, ipHdrSize int) (int, bool) {
+	// Need IP header + at least 4 bytes of UDP header (for dest port)
+	if len(data) < ipHdrSize+4 {
+		return 0, false
+	}
+	// UDP dest port is at offset 2 in UDP header
+	portOffset := ipHdrSize + 2
+	return int(data[portOffset])<<8 | int(data[portOffset+1]), true
+}
+
 // getPort returns the UDP destination port for a given sequence number.
 // When ECMP flows are enabled, uses GenerateFlowID for port diversity.
 func (t *UDPTracer) getPort(seq int) int {
@@ -0,0 +1,55 @@
+// Package tracetest builds trace results for the tests of the packages that
+// analyze them.
+package tracetest
+
+import (
+	"net"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+// Target is the address of example.com, where test traces go.
+const Target = "93.184.216.34"
+
+// Hop describes one hop of a test trace.
+type Hop struct {
+	IP       string        // Responding address, "" for a timeout
+	RTT      time.Duration // 0: the TTL in milliseconds
+	ASN      uint32
+	Hostname string
+}
+
+// Trace builds an ICMP trace to example.com over hops, one probe each. It
+// reached the target when the last hop is Target.
+func Trace(hops ...Hop) *hop.TraceResult {
+	tr := hop.NewTraceResult("example.com", Target)
+	tr.Protocol = "icmp"
+	tr.StartTime = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	for i, th := range hops {
+		h := hop.NewHop(i + 1)
+		if th.IP == "" {
+			h.AddTimeout()
+		} else {
+			rtt := th.RTT
+			if rtt == 0 {
+				rtt = time.Duration(i+1) * time.Millisecond
+			}
+			h.AddProbe(net.ParseIP(th.IP), rtt)
+			h.SetEnrichment(hop.Enrichment{ASN: th.ASN, Hostname: th.Hostname})
+		}
+		tr.AddHop(h)
+	}
+	tr.ReachedTarget = len(hops) > 0 && hops[len(hops)-1].IP == Target
+	return tr
+}
+
+// Path builds a trace through routers to Target, each hop answering with rtt
+// times its TTL.
+func Path(rtt time.Duration, routers ...string) *hop.TraceResult {
+	hops := make([]Hop, 0, len(routers)+1)
+	for i, ip := range append(routers, Target) {
+		hops = append(hops, Hop{IP: ip, RTT: time.Duration(i+1) * rtt})
+	}
+	return Trace(hops...)
+}
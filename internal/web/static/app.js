@@ -0,0 +1,165 @@
+// Polls the live MTR statistics and renders a table and RTT chart per target.
+"use strict";
+
+const POLL_MS = 1000;
+const COLUMNS = ["Hop", "Host", "ASN", "Loss%", "Snt", "Last", "Avg", "Best", "Wrst", "StDev", "RTT"];
+
+// Hop selected for the detailed chart, per target
+const selected = {};
+
+function ms(v) {
+  return v.toFixed(1);
+}
+
+function lossClass(loss) {
+  if (loss >= 50) return "bad";
+  if (loss > 0) return "warn";
+  return "";
+}
+
+function cell(row, text, cls) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (cls) td.className = cls;
+  return td;
+}
+
+// drawRTT plots samples (ms, -1 for a lost probe) as a line, with lost
+// probes marked in red at the bottom.
+function drawRTT(canvas, samples, axis) {
+  const ctx = canvas.getContext("2d");
+  const w = canvas.width, h = canvas.height;
+  const pad = axis ? 4 : 1;
+  ctx.clearRect(0, 0, w, h);
+
+  const replies = samples.filter((s) => s >= 0);
+  const top = Math.max(1, ...replies) * 1.1;
+  const step = samples.length > 1 ? (w - 2 * pad) / (samples.length - 1) : 0;
+  const y = (v) => h - pad - (v / top) * (h - 2 * pad);
+
+  ctx.strokeStyle = "#89b4fa";
+  ctx.lineWidth = axis ? 2 : 1;
+  ctx.beginPath();
+  let drawing = false;
+  samples.forEach((s, i) => {
+    const x = pad + i * step;
+    if (s < 0) {
+      ctx.fillStyle = "#f38ba8";
+      ctx.fillRect(x - 1, h - pad - 3, 3, 3);
+      drawing = false;
+      return;
+    }
+    if (drawing) ctx.lineTo(x, y(s));
+    else ctx.moveTo(x, y(s));
+    drawing = true;
+  });
+  ctx.stroke();
+
+  if (axis) {
+    ctx.fillStyle = "#a6adc8";
+    ctx.font = "11px monospace";
+    ctx.fillText(ms(top) + " ms", pad + 2, 12);
+    ctx.fillText("0 ms", pad + 2, h - pad - 4);
+  }
+}
+
+function renderTarget(t) {
+  const section = document.createElement("section");
+
+  const title = document.createElement("h2");
+  title.textContent = t.name + (t.ip && t.ip !== t.name ? " (" + t.ip + ")" : "");
+  const meta = document.createElement("span");
+  meta.className = "meta";
+  let status = " — " + t.cycles + " cycles";
+  if (t.cycles > 0) status += t.reached ? ", reached" : ", not reached";
+  meta.textContent = status;
+  title.appendChild(meta);
+  section.appendChild(title);
+
+  if (t.error) {
+    const err = document.createElement("div");
+    err.className = "error";
+    err.textContent = t.error;
+    section.appendChild(err);
+  }
+
+  const table = document.createElement("table");
+  const head = table.createTHead().insertRow();
+  COLUMNS.forEach((c) => {
+    const th = document.createElement("th");
+    th.textContent = c;
+    if (c === "Host") th.className = "host";
+    head.appendChild(th);
+  });
+
+  const body = table.createTBody();
+  t.hops.forEach((h) => {
+    const row = body.insertRow();
+    if (selected[t.name] === h.ttl) row.className = "selected";
+    row.onclick = () => {
+      selected[t.name] = selected[t.name] === h.ttl ? undefined : h.ttl;
+      refresh();
+    };
+
+    cell(row, h.ttl);
+    let host = h.hostname || h.ip || "???";
+    if (h.hostname && h.ip) host += " (" + h.ip + ")";
+    if (h.ecmp) host += " [" + h.ecmp + " paths]";
+    cell(row, host, "host");
+    cell(row, h.asn ? "AS" + h.asn : "");
+    cell(row, h.loss.toFixed(1), lossClass(h.loss));
+    cell(row, h.sent);
+    if (h.recv > 0) {
+      cell(row, ms(h.last));
+      cell(row, ms(h.avg));
+      cell(row, ms(h.best));
+      cell(row, ms(h.worst));
+      cell(row, ms(h.stdDev));
+    } else {
+      for (let i = 0; i < 5; i++) cell(row, "-");
+    }
+
+    const spark = document.createElement("canvas");
+    spark.width = 120;
+    spark.height = 16;
+    row.insertCell().appendChild(spark);
+    drawRTT(spark, h.samples, false);
+  });
+  section.appendChild(table);
+
+  const hop = t.hops.find((h) => h.ttl === selected[t.name]);
+  if (hop) {
+    const chart = document.createElement("canvas");
+    chart.className = "chart";
+    chart.width = 720;
+    chart.height = 160;
+    section.appendChild(chart);
+    drawRTT(chart, hop.samples, true);
+  }
+  return section;
+}
+
+let latest = [];
+
+function refresh() {
+  const main = document.getElementById("targets");
+  main.replaceChildren(...latest.map(renderTarget));
+}
+
+async function poll() {
+  const status = document.getElementById("status");
+  try {
+    const resp = await fetch("api/targets", { cache: "no-store" });
+    if (!resp.ok) throw new Error(resp.status + " " + resp.statusText);
+    latest = await resp.json();
+    refresh();
+    status.textContent = "updated " + new Date().toLocaleTimeString();
+    status.className = "";
+  } catch (e) {
+    status.textContent = "disconnected: " + e.message;
+    status.className = "error";
+  }
+  setTimeout(poll, POLL_MS);
+}
+
+poll();
@@ -0,0 +1,207 @@
+// Package web serves a browser dashboard of live MTR statistics, so traces
+// can be followed by people without terminal access.
+package web
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/display"
+	"github.com/hervehildenbrand/gtrace/pkg/hop"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Hop is the MTR statistics of one TTL, as served to the dashboard. RTTs are
+// in milliseconds.
+type Hop struct {
+	TTL      int       `json:"ttl"`
+	IP       string    `json:"ip,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	ASN      uint32    `json:"asn,omitempty"`
+	ASOrg    string    `json:"asOrg,omitempty"`
+	ECMP     int       `json:"ecmp,omitempty"` // Addresses seen at this TTL when more than one
+	Loss     float64   `json:"loss"`
+	Sent     int       `json:"sent"`
+	Recv     int       `json:"recv"`
+	Last     float64   `json:"last"`
+	Avg      float64   `json:"avg"` // Avg, Best and StdDev leave out RTT outliers
+	Best     float64   `json:"best"`
+	Worst    float64   `json:"worst"`
+	StdDev   float64   `json:"stdDev"`
+	Outliers int       `json:"outliers,omitempty"` // Probes left out as RTT outliers
+	Samples  []float64 `json:"samples"`            // Recent probes, oldest first; -1 for a lost probe
+}
+
+// Target is the live state of one traced target.
+type Target struct {
+	Name    string    `json:"name"`
+	IP      string    `json:"ip,omitempty"`
+	Cycles  int       `json:"cycles"`
+	LastRun time.Time `json:"lastRun"`
+	Reached bool      `json:"reached"`
+	Error   string    `json:"error,omitempty"` // Error of the last cycle, if it failed
+	Hops    []Hop     `json:"hops"`
+}
+
+// session accumulates the cycles of one target.
+type session struct {
+	ip      string
+	cycles  int
+	lastRun time.Time
+	reached bool
+	err     string
+	maxTTL  int
+	hops    map[int]*display.HopStats
+}
+
+// Recorder accumulates the traces of each target into MTR statistics. It is
+// safe for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	history  int
+	order    []string
+	sessions map[string]*session
+}
+
+// NewRecorder creates a recorder for targets, keeping history samples per
+// hop for the RTT charts.
+func NewRecorder(targets []string, history int) *Recorder {
+	r := &Recorder{
+		history:  history,
+		order:    targets,
+		sessions: make(map[string]*session, len(targets)),
+	}
+	for _, t := range targets {
+		r.sessions[t] = &session{hops: make(map[int]*display.HopStats)}
+	}
+	return r
+}
+
+// Record adds a completed trace of target.
+func (r *Recorder) Record(target string, tr *hop.TraceResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[target]
+	if !ok {
+		return
+	}
+
+	s.ip = tr.TargetIP
+	s.cycles++
+	s.lastRun = time.Now()
+	s.reached = tr.ReachedTarget
+	s.err = ""
+	// The path may get shorter: only show TTLs up to the latest trace's end
+	s.maxTTL = 0
+	for _, h := range tr.Hops {
+		s.maxTTL = max(s.maxTTL, h.TTL)
+		stats, ok := s.hops[h.TTL]
+		if !ok {
+			stats = display.NewHopStatsWithHistory(h.TTL, r.history)
+			s.hops[h.TTL] = stats
+		}
+		for _, p := range h.Probes {
+			if p.Timeout || p.IP == nil {
+				stats.AddTimeout()
+				continue
+			}
+			stats.AddProbe(p.IP, p.RTT)
+			if h.Enrichment.ASN != 0 || h.Enrichment.Hostname != "" {
+				stats.SetIPEnrichment(p.IP, h.Enrichment)
+			}
+		}
+	}
+}
+
+// RecordError records a failed cycle of target.
+func (r *Recorder) RecordError(target string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[target]; ok {
+		s.cycles++
+		s.lastRun = time.Now()
+		s.err = err.Error()
+	}
+}
+
+// Snapshot returns the state of every target, in the order given to
+// NewRecorder.
+func (r *Recorder) Snapshot() []Target {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	targets := make([]Target, 0, len(r.order))
+	for _, name := range r.order {
+		s := r.sessions[name]
+		t := Target{
+			Name:    name,
+			IP:      s.ip,
+			Cycles:  s.cycles,
+			LastRun: s.lastRun,
+			Reached: s.reached,
+			Error:   s.err,
+			Hops:    []Hop{},
+		}
+		for ttl := 1; ttl <= s.maxTTL; ttl++ {
+			if stats, ok := s.hops[ttl]; ok {
+				t.Hops = append(t.Hops, hopRow(stats))
+			}
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// hopRow converts the statistics of a TTL for the dashboard.
+func hopRow(s *display.HopStats) Hop {
+	h := Hop{
+		TTL:      s.TTL,
+		Loss:     s.LossPercent(),
+		Sent:     s.Sent,
+		Recv:     s.Recv,
+		Last:     hop.Milliseconds(s.LastRTT),
+		Avg:      hop.Milliseconds(s.FilteredAvgRTT()),
+		Best:     hop.Milliseconds(s.FilteredBest),
+		Worst:    hop.Milliseconds(s.WorstRTT),
+		StdDev:   hop.Milliseconds(s.FilteredStdDev()),
+		Outliers: s.Outliers,
+	}
+	if ip := s.PrimaryIP(); ip != nil {
+		h.IP = ip.String()
+		e := s.PrimaryEnrichment()
+		h.Hostname, h.ASN, h.ASOrg = e.Hostname, e.ASN, e.ASOrg
+	}
+	if n := s.UniqueIPCount(); n > 1 {
+		h.ECMP = n
+	}
+	h.Samples = make([]float64, 0, len(s.Samples))
+	for _, sample := range s.Samples {
+		if sample.Lost {
+			h.Samples = append(h.Samples, -1)
+		} else {
+			h.Samples = append(h.Samples, hop.Milliseconds(sample.RTT))
+		}
+	}
+	return h
+}
+
+// Handler serves the dashboard: the embedded page at / and the live state
+// of the targets returned by snapshot as JSON at /api/targets.
+func Handler(snapshot func() []Target) http.Handler {
+	mux := http.NewServeMux()
+
+	assets, _ := fs.Sub(staticFiles, "static")
+	mux.Handle("GET /", http.FileServer(http.FS(assets)))
+	mux.HandleFunc("GET /api/targets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(snapshot())
+	})
+	return mux
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hervehildenbrand/gtrace/internal/tracetest"
+)
+
+// gateway is the first hop of the test traces.
+var gateway = tracetest.Hop{IP: "192.168.1.1", Hostname: "gw.local"}
+
+func TestRecorder_AggregatesCycles(t *testing.T) {
+	r := NewRecorder([]string{"example.com"}, 10)
+	r.Record("example.com", tracetest.Trace(gateway, tracetest.Hop{IP: tracetest.Target, RTT: 10 * time.Millisecond}))
+	r.Record("example.com", tracetest.Trace(gateway, tracetest.Hop{}))
+	r.Record("example.com", tracetest.Trace(gateway, tracetest.Hop{IP: tracetest.Target, RTT: 30 * time.Millisecond}))
+
+	targets := r.Snapshot()
+	if len(targets) != 1 {
+		t.Fatalf("expected one target, got %d", len(targets))
+	}
+	tgt := targets[0]
+	if tgt.Cycles != 3 || !tgt.Reached || tgt.IP != "93.184.216.34" || len(tgt.Hops) != 2 {
+		t.Fatalf("unexpected target %+v", tgt)
+	}
+
+	if tgt.Hops[0].Hostname != "gw.local" {
+		t.Errorf("expected enrichment of hop 1, got %+v", tgt.Hops[0])
+	}
+	h := tgt.Hops[1]
+	if h.Sent != 3 || h.Recv != 2 || h.Avg != 20 || h.Best != 10 || h.Worst != 30 || h.Last != 30 {
+		t.Errorf("unexpected statistics %+v", h)
+	}
+	if want := []float64{10, -1, 30}; len(h.Samples) != 3 || h.Samples[0] != want[0] || h.Samples[1] != want[1] || h.Samples[2] != want[2] {
+		t.Errorf("expected samples %v, got %v", want, h.Samples)
+	}
+}
+
+func TestRecorder_ShowsLatestPathLength(t *testing.T) {
+	r := NewRecorder([]string{"example.com"}, 10)
+	long := tracetest.Trace(gateway, tracetest.Hop{IP: tracetest.Target, RTT: 10 * time.Millisecond}, tracetest.Hop{})
+	r.Record("example.com", long)
+	r.Record("example.com", tracetest.Trace(gateway, tracetest.Hop{IP: tracetest.Target, RTT: 10 * time.Millisecond}))
+
+	if hops := r.Snapshot()[0].Hops; len(hops) != 2 {
+		t.Errorf("expected the hops of the latest trace, got %d", len(hops))
+	}
+}
+
+func TestRecorder_RecordError(t *testing.T) {
+	r := NewRecorder([]string{"a.example", "b.example"}, 10)
+	r.RecordError("b.example", errors.New("failed to resolve target"))
+	r.Record("unknown.example", tracetest.Trace(gateway, tracetest.Hop{IP: tracetest.Target, RTT: 10 * time.Millisecond}))
+
+	targets := r.Snapshot()
+	if len(targets) != 2 || targets[0].Name != "a.example" || targets[1].Name != "b.example" {
+		t.Fatalf("expected targets in order, got %+v", targets)
+	}
+	if targets[1].Error != "failed to resolve target" || targets[1].Cycles != 1 {
+		t.Errorf("expected the error recorded, got %+v", targets[1])
+	}
+	if targets[0].Hops == nil {
+		t.Error("expected an empty hop list, not null")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRecorder([]string{"example.com"}, 10)
+	r.Record("example.com", tracetest.Trace(gateway, tracetest.Hop{IP: tracetest.Target, RTT: 10 * time.Millisecond}))
+	srv := httptest.NewServer(Handler(r.Snapshot))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the dashboard page, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(srv.URL + "/api/targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var targets []Target
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || len(targets[0].Hops) != 2 || targets[0].Hops[1].IP != "93.184.216.34" {
+		t.Errorf("unexpected targets %+v", targets)
+	}
+}
@@ -4,6 +4,8 @@ package hop
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,13 +36,116 @@ type Probe struct {
 	IP            net.IP
 	RTT           time.Duration
 	Timeout       bool
-	ResponseTTL   int            // TTL from response packet (for NAT detection)
-	IPID          uint16         // IP ID from original datagram in ICMP error
-	ICMPType      int            // ICMP message type (0 = not set)
-	ICMPCode      int            // ICMP message code (meaningful for Dest Unreachable)
-	OriginalTTL   int            // TTL from original datagram in ICMP error (-1 = not set)
-	FlowID        int            // ECMP flow identifier (0 = not tracked)
-	TransportInfo *TransportInfo // Decoded header info (nil if --decode not used)
+	ResponseTTL   int             // TTL from response packet (for NAT detection)
+	IPID          uint16          // IP ID from original datagram in ICMP error
+	ICMPType      int             // ICMP message type (0 = not set)
+	ICMPCode      int             // ICMP message code (meaningful for Dest Unreachable)
+	OriginalTTL   int             // TTL from original datagram in ICMP error (-1 = not set)
+	FlowID        int             // ECMP flow identifier (0 = not tracked)
+	TransportInfo *TransportInfo  // Decoded header info (nil if --decode not used)
+	Handshake     *Handshake      // TCP handshake timing (nil unless a TCP probe connected to the target)
+	Fingerprint   *TCPFingerprint // Target's SYN-ACK (nil unless captured for a TCP probe that connected)
+	LocalError    string          // Why this host could not send the probe, e.g. no route (empty = sent)
+}
+
+// Handshake breaks down the latency of a TCP probe that completed a
+// handshake with the target.
+type Handshake struct {
+	SYNRTT     time.Duration // SYN to SYN-ACK round trip, from the kernel's RTT estimate
+	ConnectRTT time.Duration // Until connect() reported completion
+	TLSRTT     time.Duration // TLS handshake after connect (0 = not measured)
+}
+
+// ServerDelay estimates how long the server took to answer beyond network
+// latency: the TLS handshake minus the one round trip it needs.
+// Returns 0 when no TLS handshake was measured.
+func (h Handshake) ServerDelay() time.Duration {
+	if h.TLSRTT <= h.SYNRTT {
+		return 0
+	}
+	return h.TLSRTT - h.SYNRTT
+}
+
+// TCPFingerprint describes the SYN-ACK a target answered a TCP probe with.
+// Operating systems differ in the initial TTL, window and TCP options they
+// answer with, and an MSS below what the link allows hints at clamping.
+type TCPFingerprint struct {
+	TTL         int    // IP TTL or hop limit the SYN-ACK arrived with
+	Window      int    // Advertised receive window (unscaled)
+	MSS         int    // Maximum segment size option (0 = absent)
+	WindowScale int    // Window scale shift (-1 = absent)
+	SACK        bool   // SACK permitted
+	Timestamps  bool   // Timestamps option present
+	Options     string // Option layout in order: M(SS), N(OP), W(indow scale), S(ACK), T(imestamps), E(OL), or the kind number
+}
+
+// InitialTTL returns the TTL the target most likely sent the SYN-ACK with:
+// the next common initial TTL at or above the one it arrived with.
+func (f TCPFingerprint) InitialTTL() int {
+	for _, initial := range []int{32, 64, 128} {
+		if f.TTL <= initial {
+			return initial
+		}
+	}
+	return 255
+}
+
+// Distance returns how many hops the SYN-ACK crossed to reach us.
+func (f TCPFingerprint) Distance() int {
+	return f.InitialTTL() - f.TTL
+}
+
+// OS returns the likely operating system of the target from its initial TTL
+// and option layout, or "" when they match no known stack. Load balancers
+// and SYN proxies answer with their own stack, not the server's.
+func (f TCPFingerprint) OS() string {
+	switch f.InitialTTL() {
+	case 64:
+		switch {
+		case strings.HasPrefix(f.Options, "M,S,T,N,W"), strings.HasPrefix(f.Options, "M,N,N,S,N,W"):
+			return "Linux"
+		case strings.HasPrefix(f.Options, "M,N,W"):
+			return "macOS or BSD"
+		}
+		return "Linux or Unix"
+	case 128:
+		return "Windows"
+	case 255:
+		return "network device or Solaris"
+	}
+	return ""
+}
+
+// MSSClamped reports whether the MSS is below what a 1500 byte Ethernet MTU
+// allows, as when a router clamps it for PPPoE or a tunnel, or the target
+// sits behind a smaller MTU.
+func (f TCPFingerprint) MSSClamped(ipv6 bool) bool {
+	full := 1460
+	if ipv6 {
+		full = 1440
+	}
+	return f.MSS > 0 && f.MSS < full
+}
+
+// String formats the fingerprint for display.
+func (f TCPFingerprint) String() string {
+	s := fmt.Sprintf("TTL %d (initial %d, %d hops), window %d", f.TTL, f.InitialTTL(), f.Distance(), f.Window)
+	if f.MSS > 0 {
+		s += fmt.Sprintf(", MSS %d", f.MSS)
+	}
+	if f.WindowScale >= 0 {
+		s += fmt.Sprintf(", WS %d", f.WindowScale)
+	}
+	if f.SACK {
+		s += ", SACK"
+	}
+	if f.Timestamps {
+		s += ", TS"
+	}
+	if f.Options != "" {
+		s += ", options " + f.Options
+	}
+	return s
 }
 
 // MPLSLabel represents an MPLS label from ICMP extensions (RFC 4950).
@@ -60,6 +165,55 @@ func (m MPLSLabel) String() string {
 	return fmt.Sprintf("L=%d E=%d S=%d TTL=%d", m.Label, m.Exp, s, m.TTL)
 }
 
+// Segment routing dataplanes.
+const (
+	SRMPLS = "sr-mpls" // Segments are MPLS labels (RFC 8660)
+	SRv6   = "srv6"    // Segments are IPv6 addresses in a Segment Routing Header (RFC 8754)
+)
+
+// SegmentRouting describes the segment routing domain a hop is part of,
+// recognized from labels in the segment routing label ranges or from a
+// Segment Routing Header quoted in an ICMPv6 error.
+type SegmentRouting struct {
+	Dataplane    string    // SRMPLS or SRv6
+	Segments     []Segment // Segment list in the order the packet visits them
+	SegmentsLeft int       // SRv6: segments still to visit after the active one
+}
+
+// Segment is one entry of a segment list.
+type Segment struct {
+	SID   net.IP // SRv6: segment identifier
+	Label uint32 // SR-MPLS: label value
+	Index int    // SR-MPLS prefix segment: index in the SRGB (-1 for an adjacency segment)
+}
+
+// String formats the segment for display.
+func (s Segment) String() string {
+	switch {
+	case s.SID != nil:
+		return s.SID.String()
+	case s.Index < 0:
+		return fmt.Sprintf("%d (adj)", s.Label)
+	}
+	return fmt.Sprintf("%d (idx %d)", s.Label, s.Index)
+}
+
+// String formats the segment list for display.
+func (sr SegmentRouting) String() string {
+	segs := make([]string, len(sr.Segments))
+	for i, s := range sr.Segments {
+		segs[i] = s.String()
+	}
+	name := "SR-MPLS"
+	if sr.Dataplane == SRv6 {
+		name = "SRv6"
+	}
+	if len(segs) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(segs, " > ")
+}
+
 // InterfaceInfo contains router interface information from RFC 5837 ICMP extensions.
 type InterfaceInfo struct {
 	Name string // Interface name (e.g., "GigabitEthernet0/1")
@@ -74,18 +228,77 @@ type Enrichment struct {
 	Country  string
 	City     string
 	Hostname string
-	IX       string // Internet Exchange name if applicable
+	IX       string   // Internet Exchange name if applicable
+	Special  string   // IANA special-purpose range, e.g. "Documentation" or "6to4"
+	BGP      *BGPInfo // Routing data from a BGP looking glass (nil if not queried)
+
+	// Reputation lists the IP is on (nil if clean or not checked)
+	Reputation []ReputationListing
+
+	// GeoIP coordinates of City (0, 0 when unknown)
+	Latitude  float64
+	Longitude float64
+
+	// Best-effort hints parsed from the hostname (router PTR naming conventions)
+	InterfaceHint string // e.g. "xe-0-0-0 (10GE, core)"
+	LocationHint  string // e.g. "Frankfurt, DE (fra)"
+}
+
+// BGPInfo describes how the prefix covering a hop is announced in BGP,
+// as seen by a route collector looking glass.
+type BGPInfo struct {
+	Prefix     string   // Most specific announced prefix covering the IP
+	OriginASN  uint32   // AS originating the prefix
+	ASPath     []uint32 // Most common path from the collectors' peers, prepends collapsed
+	Visibility float64  // Percentage of collector peers seeing the prefix (0-100)
+}
+
+// PathString formats the AS path as space-separated AS numbers.
+func (b BGPInfo) PathString() string {
+	parts := make([]string, len(b.ASPath))
+	for i, asn := range b.ASPath {
+		parts[i] = strconv.FormatUint(uint64(asn), 10)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Categories of reputation lists.
+const (
+	ReputationBogon  = "bogon"  // Unallocated or reserved space, never legitimately routed
+	ReputationHijack = "hijack" // Hijacked or criminal netblocks (e.g. Spamhaus DROP)
+	ReputationListed = "listed" // Custom blocklist
+)
+
+// ReputationListing records that an address is on a reputation list.
+type ReputationListing struct {
+	List     string // Name of the list, e.g. "spamhaus-drop"
+	Category string // Reputation* category
+}
+
+// Tag returns the indicator shown next to a listed hop.
+func (l ReputationListing) Tag() string {
+	switch l.Category {
+	case ReputationBogon:
+		return "[BOGON]"
+	case ReputationHijack:
+		return "[HIJACK?:" + l.List + "]"
+	default:
+		return "[LISTED:" + l.List + "]"
+	}
 }
 
 // Hop represents a single hop in a traceroute.
 type Hop struct {
-	TTL           int
-	Probes        []Probe
-	MPLS          []MPLSLabel
-	Enrichment    Enrichment
-	InterfaceInfo *InterfaceInfo // RFC 5837 interface information (nil if not available)
-	MTU           int            // Discovered MTU at this hop
-	NAT           bool           // NAT detected at this hop
+	TTL            int
+	Probes         []Probe
+	MPLS           []MPLSLabel
+	Enrichment     Enrichment
+	InterfaceInfo  *InterfaceInfo  // RFC 5837 interface information (nil if not available)
+	SegmentRouting *SegmentRouting // Segment routing domain the hop is part of (nil if none seen)
+	MTU            int             // Discovered MTU at this hop
+	NAT            bool            // NAT detected at this hop
+	GeoMismatch    bool            // Geolocation impossible given the measured RTTs (--geo-validate)
+	Protocol       string          // Protocol of the probes this hop answered, when it differs per hop (--protocol auto)
 }
 
 // NewHop creates a new Hop with the given TTL.
@@ -120,6 +333,14 @@ func (h *Hop) AddTimeout() {
 	})
 }
 
+// AddLocalError records a probe this host could not send, as a timeout.
+func (h *Hop) AddLocalError(reason string) {
+	h.Probes = append(h.Probes, Probe{
+		Timeout:    true,
+		LocalError: reason,
+	})
+}
+
 // AvgRTT calculates the average RTT excluding timeouts.
 func (h *Hop) AvgRTT() time.Duration {
 	var total time.Duration
@@ -138,6 +359,36 @@ func (h *Hop) AvgRTT() time.Duration {
 	return total / time.Duration(count)
 }
 
+// AvgHandshake averages the handshake timings of probes that connected.
+// Returns false if no probe completed a handshake.
+func (h *Hop) AvgHandshake() (Handshake, bool) {
+	var total Handshake
+	var count, tlsCount int
+
+	for _, p := range h.Probes {
+		if p.Handshake == nil {
+			continue
+		}
+		total.SYNRTT += p.Handshake.SYNRTT
+		total.ConnectRTT += p.Handshake.ConnectRTT
+		if p.Handshake.TLSRTT > 0 {
+			total.TLSRTT += p.Handshake.TLSRTT
+			tlsCount++
+		}
+		count++
+	}
+
+	if count == 0 {
+		return Handshake{}, false
+	}
+	total.SYNRTT /= time.Duration(count)
+	total.ConnectRTT /= time.Duration(count)
+	if tlsCount > 0 {
+		total.TLSRTT /= time.Duration(tlsCount)
+	}
+	return total, true
+}
+
 // LossPercent calculates the packet loss percentage.
 func (h *Hop) LossPercent() float64 {
 	if len(h.Probes) == 0 {
@@ -185,16 +436,67 @@ func (h *Hop) SetEnrichment(e Enrichment) {
 	h.Enrichment = e
 }
 
+// Timestamp sources of probe RTTs, from most to least precise.
+const (
+	TimestampKernel        = "kernel"         // Kernel send and receive timestamps
+	TimestampKernelReceive = "kernel-receive" // Kernel receive, user-space send timestamps
+	TimestampUserspace     = "userspace"      // User-space send and receive timestamps
+)
+
 // TraceResult contains the complete result of a traceroute.
 type TraceResult struct {
-	Target        string    // Target hostname
-	TargetIP      string    // Resolved target IP
-	Hops          []*Hop    // Ordered list of hops
-	ReachedTarget bool      // Whether the target was reached
-	Protocol      string    // Protocol used (icmp, udp, tcp)
-	Source        string    // Source location (empty for local)
-	StartTime     time.Time // When the trace started
-	EndTime       time.Time // When the trace completed
+	Target          string     // Target hostname
+	TargetIP        string     // Resolved target IP
+	Hops            []*Hop     // Ordered list of hops
+	ReachedTarget   bool       // Whether the target was reached
+	Protocol        string     // Protocol used (icmp, udp, tcp)
+	Source          string     // Source location (empty for local)
+	StartTime       time.Time  // When the trace started
+	EndTime         time.Time  // When the trace completed
+	TimestampSource string     // Where probe RTTs were timed (Timestamp*, empty if unknown)
+	Route           *RouteInfo // Kernel route to the target when the trace started (local traces)
+	Overlay         *Overlay   // VPN or overlay network the path goes through (nil if none seen)
+}
+
+// Overlay is a VPN or overlay network detected on the path. Inside a tunnel
+// the routers it crosses are hidden: the trace shows the tunnel as one hop.
+type Overlay struct {
+	Kind     string   // "WireGuard", "Tailscale", "VPN" or "CGNAT"
+	Evidence []string // What gave it away, e.g. "route through wg0"
+}
+
+// RouteInfo is the route the kernel selected to reach a target.
+type RouteInfo struct {
+	Destination string // Matched prefix, "default" for the default route
+	Gateway     string // Next hop, empty when the target is on-link
+	Interface   string // Outgoing interface
+	Source      string // Source address of the probes
+	Metric      int    // Route priority (Linux)
+	Table       string // Routing table, e.g. "main" (Linux)
+	MTU         int    // MTU of the outgoing interface
+}
+
+// String formats the route like "ip route", e.g.
+// "default via 192.168.1.1 dev eth0 src 192.168.1.10 metric 100 table main".
+func (r *RouteInfo) String() string {
+	var b strings.Builder
+	b.WriteString(r.Destination)
+	if r.Gateway != "" {
+		b.WriteString(" via " + r.Gateway)
+	}
+	if r.Interface != "" {
+		b.WriteString(" dev " + r.Interface)
+	}
+	if r.Source != "" {
+		b.WriteString(" src " + r.Source)
+	}
+	if r.Metric != 0 {
+		fmt.Fprintf(&b, " metric %d", r.Metric)
+	}
+	if r.Table != "" {
+		b.WriteString(" table " + r.Table)
+	}
+	return strings.TrimSpace(b.String())
 }
 
 // NewTraceResult creates a new TraceResult for the given target.
@@ -226,7 +528,141 @@ func (tr *TraceResult) IsComplete() bool {
 	return tr.ReachedTarget
 }
 
+// TargetFingerprint returns the SYN-ACK fingerprint of the target: the first
+// one captured at the last hop, or nil if no TCP probe captured one.
+func (tr *TraceResult) TargetFingerprint() *TCPFingerprint {
+	if len(tr.Hops) == 0 {
+		return nil
+	}
+	for _, p := range tr.Hops[len(tr.Hops)-1].Probes {
+		if p.Fingerprint != nil {
+			return p.Fingerprint
+		}
+	}
+	return nil
+}
+
 // TotalHops returns the number of hops in the trace.
 func (tr *TraceResult) TotalHops() int {
 	return len(tr.Hops)
 }
+
+// Milliseconds returns d in milliseconds, the unit RTTs are reported in.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// EarliestStart returns the earliest known StartTime among results,
+// or the zero time if none is set.
+func EarliestStart(results []*TraceResult) time.Time {
+	var earliest time.Time
+	for _, tr := range results {
+		if tr == nil || tr.StartTime.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || tr.StartTime.Before(earliest) {
+			earliest = tr.StartTime
+		}
+	}
+	return earliest
+}
+
+// StartSkew returns how long after ref the trace started.
+// Returns 0 if either time is unknown.
+func (tr *TraceResult) StartSkew(ref time.Time) time.Duration {
+	if tr.StartTime.IsZero() || ref.IsZero() {
+		return 0
+	}
+	return tr.StartTime.Sub(ref)
+}
+
+// MaxStartSkew returns the spread between the earliest and latest known start times.
+func MaxStartSkew(results []*TraceResult) time.Duration {
+	earliest := EarliestStart(results)
+	var skew time.Duration
+	for _, tr := range results {
+		if tr != nil {
+			skew = max(skew, tr.StartSkew(earliest))
+		}
+	}
+	return skew
+}
+
+// WindowsOverlap reports whether the time windows of all timed results share
+// a common instant. Results without start or end times are ignored.
+func WindowsOverlap(results []*TraceResult) bool {
+	var latestStart, earliestEnd time.Time
+	for _, tr := range results {
+		if tr == nil || tr.StartTime.IsZero() || tr.EndTime.IsZero() {
+			continue
+		}
+		if tr.StartTime.After(latestStart) {
+			latestStart = tr.StartTime
+		}
+		if earliestEnd.IsZero() || tr.EndTime.Before(earliestEnd) {
+			earliestEnd = tr.EndTime
+		}
+	}
+	return earliestEnd.IsZero() || !latestStart.After(earliestEnd)
+}
+
+// ASLatency is the latency a trace accumulates inside one AS.
+type ASLatency struct {
+	ASN   uint32
+	Org   string        // AS organization, if enriched
+	Hops  int           // Hops in the AS, silent ones included
+	Delta time.Duration // RTT of the last responding hop minus the first
+}
+
+// String formats the segment as "AS1299 Arelion: 6 hops, 78.0ms".
+func (a ASLatency) String() string {
+	name := fmt.Sprintf("AS%d", a.ASN)
+	if a.Org != "" {
+		name += " " + a.Org
+	}
+	hops := "hops"
+	if a.Hops == 1 {
+		hops = "hop"
+	}
+	return fmt.Sprintf("%s: %d %s, %.1fms", name, a.Hops, hops, float64(a.Delta)/float64(time.Millisecond))
+}
+
+// LatencyByAS splits the trace into runs of consecutive hops in the same AS
+// and returns the latency added inside each, in path order. Hops of unknown
+// AS (no reply, private space) join the run before them; leading ones are
+// left out. A delta below zero, from routers slow to answer ICMP, counts as 0.
+func (tr *TraceResult) LatencyByAS() []ASLatency {
+	var segs []ASLatency
+	var first, last time.Duration // RTTs of the first and last responding hop of the run
+	flush := func() {
+		if n := len(segs); n > 0 {
+			segs[n-1].Delta = max(last-first, 0)
+		}
+	}
+
+	for _, h := range tr.Hops {
+		asn := h.Enrichment.ASN
+		if asn != 0 && (len(segs) == 0 || asn != segs[len(segs)-1].ASN) {
+			flush()
+			segs = append(segs, ASLatency{ASN: asn})
+			first, last = 0, 0
+		}
+		if len(segs) == 0 {
+			continue
+		}
+
+		seg := &segs[len(segs)-1]
+		seg.Hops++
+		if seg.Org == "" {
+			seg.Org = h.Enrichment.ASOrg
+		}
+		if rtt := h.AvgRTT(); rtt > 0 {
+			if first == 0 {
+				first = rtt
+			}
+			last = rtt
+		}
+	}
+	flush()
+	return segs
+}
@@ -162,6 +162,39 @@ func TestHop_HasMultipleIPs_FalseForSingleIP(t *testing.T) {
 	}
 }
 
+func TestHop_AvgHandshake_AveragesConnectedProbes(t *testing.T) {
+	h := NewHop(5)
+	h.Probes = append(h.Probes,
+		Probe{RTT: 12 * time.Millisecond, Handshake: &Handshake{SYNRTT: 10 * time.Millisecond, ConnectRTT: 12 * time.Millisecond, TLSRTT: 30 * time.Millisecond}},
+		Probe{RTT: 14 * time.Millisecond, Handshake: &Handshake{SYNRTT: 12 * time.Millisecond, ConnectRTT: 14 * time.Millisecond}},
+		Probe{Timeout: true},
+	)
+
+	hs, ok := h.AvgHandshake()
+	if !ok {
+		t.Fatal("expected a handshake")
+	}
+	if hs.SYNRTT != 11*time.Millisecond || hs.ConnectRTT != 13*time.Millisecond {
+		t.Errorf("expected SYN 11ms / connect 13ms, got %v / %v", hs.SYNRTT, hs.ConnectRTT)
+	}
+	// Only probes that measured TLS count towards its average
+	if hs.TLSRTT != 30*time.Millisecond {
+		t.Errorf("expected TLS 30ms, got %v", hs.TLSRTT)
+	}
+	if hs.ServerDelay() != 19*time.Millisecond {
+		t.Errorf("expected server delay 19ms, got %v", hs.ServerDelay())
+	}
+}
+
+func TestHop_AvgHandshake_FalseWithoutHandshakes(t *testing.T) {
+	h := NewHop(1)
+	h.AddProbe(net.ParseIP("192.168.1.1"), 10*time.Millisecond)
+
+	if _, ok := h.AvgHandshake(); ok {
+		t.Error("expected no handshake")
+	}
+}
+
 func TestMPLSLabel_String_FormatsCorrectly(t *testing.T) {
 	label := MPLSLabel{
 		Label: 24015,
@@ -305,3 +338,183 @@ func TestProbeTransportInfoNilByDefault(t *testing.T) {
 		t.Error("TransportInfo should be nil by default")
 	}
 }
+
+func TestMaxStartSkew_IgnoresUntimedResults(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	local := &TraceResult{StartTime: base, EndTime: base.Add(5 * time.Second)}
+	remote := &TraceResult{StartTime: base.Add(3 * time.Minute), EndTime: base.Add(3*time.Minute + 20*time.Second)}
+	untimed := &TraceResult{}
+
+	results := []*TraceResult{remote, untimed, local}
+
+	if got := EarliestStart(results); !got.Equal(base) {
+		t.Errorf("expected earliest start %v, got %v", base, got)
+	}
+	if got := MaxStartSkew(results); got != 3*time.Minute {
+		t.Errorf("expected skew 3m, got %v", got)
+	}
+	if untimed.StartSkew(base) != 0 {
+		t.Error("untimed result should have zero skew")
+	}
+}
+
+func TestWindowsOverlap(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	a := &TraceResult{StartTime: base, EndTime: base.Add(10 * time.Second)}
+	b := &TraceResult{StartTime: base.Add(5 * time.Second), EndTime: base.Add(30 * time.Second)}
+	c := &TraceResult{StartTime: base.Add(time.Minute), EndTime: base.Add(2 * time.Minute)}
+
+	if !WindowsOverlap([]*TraceResult{a, b}) {
+		t.Error("expected overlapping windows")
+	}
+	if WindowsOverlap([]*TraceResult{a, b, c}) {
+		t.Error("expected disjoint windows")
+	}
+	if !WindowsOverlap([]*TraceResult{a, {}}) {
+		t.Error("untimed results should be ignored")
+	}
+}
+
+func TestReputationListing_Tag(t *testing.T) {
+	tests := []struct {
+		listing ReputationListing
+		want    string
+	}{
+		{ReputationListing{List: "cymru-fullbogons", Category: ReputationBogon}, "[BOGON]"},
+		{ReputationListing{List: "spamhaus-drop", Category: ReputationHijack}, "[HIJACK?:spamhaus-drop]"},
+		{ReputationListing{List: "corp-deny", Category: ReputationListed}, "[LISTED:corp-deny]"},
+	}
+	for _, tt := range tests {
+		if got := tt.listing.Tag(); got != tt.want {
+			t.Errorf("Tag() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestTraceResult_LatencyByAS(t *testing.T) {
+	tr := NewTraceResult("example.com", "93.184.216.34")
+	add := func(asn uint32, org string, rtt time.Duration) {
+		h := NewHop(len(tr.Hops) + 1)
+		if rtt == 0 {
+			h.AddTimeout()
+		} else {
+			h.AddProbe(net.ParseIP("192.0.2.1"), rtt)
+		}
+		h.Enrichment.ASN = asn
+		h.Enrichment.ASOrg = org
+		tr.AddHop(h)
+	}
+	add(0, "", time.Millisecond) // Home router, no AS
+	add(3215, "Orange", 3*time.Millisecond)
+	add(0, "", 0) // Silent hop inside Orange
+	add(3215, "Orange", 5100*time.Microsecond)
+	add(1299, "Arelion", 10*time.Millisecond)
+	add(1299, "Arelion", 88*time.Millisecond)
+	add(15169, "", 87*time.Millisecond) // Faster than the hop before
+
+	got := tr.LatencyByAS()
+
+	want := []string{
+		"AS3215 Orange: 3 hops, 2.1ms",
+		"AS1299 Arelion: 2 hops, 78.0ms",
+		"AS15169: 1 hop, 0.0ms",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d segments, got %v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].String() != w {
+			t.Errorf("segment %d: expected %q, got %q", i, w, got[i].String())
+		}
+	}
+}
+
+func TestTraceResult_LatencyByAS_NoASN(t *testing.T) {
+	tr := NewTraceResult("example.com", "93.184.216.34")
+	h := NewHop(1)
+	h.AddProbe(net.ParseIP("192.168.1.1"), time.Millisecond)
+	tr.AddHop(h)
+
+	if got := tr.LatencyByAS(); len(got) != 0 {
+		t.Errorf("expected no segments without AS data, got %v", got)
+	}
+}
+
+func TestTCPFingerprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       TCPFingerprint
+		initial int
+		os      string
+	}{
+		{"linux", TCPFingerprint{TTL: 52, Options: "M,S,T,N,W"}, 64, "Linux"},
+		{"bsd", TCPFingerprint{TTL: 60, Options: "M,N,W,N,N,T,S"}, 64, "macOS or BSD"},
+		{"windows", TCPFingerprint{TTL: 113, Options: "M,N,W,S"}, 128, "Windows"},
+		{"router", TCPFingerprint{TTL: 250, Options: "M"}, 255, "network device or Solaris"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.InitialTTL(); got != tt.initial {
+				t.Errorf("InitialTTL() = %d, want %d", got, tt.initial)
+			}
+			if got := tt.f.OS(); got != tt.os {
+				t.Errorf("OS() = %q, want %q", got, tt.os)
+			}
+		})
+	}
+}
+
+func TestTCPFingerprint_MSSClamped(t *testing.T) {
+	f := TCPFingerprint{MSS: 1452}
+	if !f.MSSClamped(false) {
+		t.Error("expected MSS 1452 to be clamped on IPv4")
+	}
+	f.MSS = 1440
+	if f.MSSClamped(true) {
+		t.Error("expected MSS 1440 to be full size on IPv6")
+	}
+	if (TCPFingerprint{}).MSSClamped(false) {
+		t.Error("expected no clamping without an MSS option")
+	}
+}
+
+func TestTCPFingerprint_String(t *testing.T) {
+	f := TCPFingerprint{TTL: 52, Window: 65160, MSS: 1460, WindowScale: 7, SACK: true, Timestamps: true, Options: "M,S,T,N,W"}
+	want := "TTL 52 (initial 64, 12 hops), window 65160, MSS 1460, WS 7, SACK, TS, options M,S,T,N,W"
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestTraceResult_TargetFingerprint(t *testing.T) {
+	tr := NewTraceResult("example.com", "192.0.2.1")
+	if tr.TargetFingerprint() != nil {
+		t.Error("expected nil without hops")
+	}
+	h := NewHop(1)
+	h.AddTimeout()
+	h.Probes = append(h.Probes, Probe{IP: net.ParseIP("192.0.2.1"), Fingerprint: &TCPFingerprint{TTL: 60}})
+	tr.AddHop(h)
+	if fp := tr.TargetFingerprint(); fp == nil || fp.TTL != 60 {
+		t.Errorf("expected the last hop's fingerprint, got %+v", fp)
+	}
+}
+
+func TestRouteInfo_String(t *testing.T) {
+	r := &RouteInfo{Destination: "default", Gateway: "192.168.1.1", Interface: "eth0", Source: "192.168.1.10", Metric: 100, Table: "main"}
+	want := "default via 192.168.1.1 dev eth0 src 192.168.1.10 metric 100 table main"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	onLink := &RouteInfo{Destination: "192.168.1.0/24", Interface: "eth0"}
+	if got := onLink.String(); got != "192.168.1.0/24 dev eth0" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestMilliseconds(t *testing.T) {
+	if got := Milliseconds(1500 * time.Microsecond); got != 1.5 {
+		t.Errorf("Milliseconds(1.5ms) = %v, want 1.5", got)
+	}
+}